@@ -0,0 +1,422 @@
+// Package chmigrate applies versioned schema migrations to a ClickHouse
+// database. It is shared by the DeepDrift and TraceCore storage packages, which
+// embed their own migration files:
+//
+//	migrations/0001_initial.up.sql    applies version 1
+//	migrations/0001_initial.down.sql  rolls it back
+//
+// ClickHouse has no transactional DDL, so a migration that fails halfway is
+// not undone. Statements use IF [NOT] EXISTS so the migration can be run again.
+// Applied migrations must not be edited; change the schema with a new one.
+package chmigrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+var (
+	// ErrChecksumMismatch is returned when an applied migration was edited afterwards
+	ErrChecksumMismatch = errors.New("migration was changed after it was applied")
+
+	// ErrUnknownMigration is returned when the database has a migration this
+	// version does not know, e.g. one applied by a newer version
+	ErrUnknownMigration = errors.New("database has a migration unknown to this version")
+
+	// ErrIrreversible is returned when rolling back a migration without a down step
+	ErrIrreversible = errors.New("migration has no down step")
+)
+
+// schemaMigrationsTable records the applied migrations. Applying or rolling
+// back a migration inserts a new version of its row.
+const schemaMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version UInt32,
+		name String,
+		checksum String,
+		applied Bool,
+		updated_at DateTime64(6)
+	) ENGINE = ReplacingMergeTree(updated_at)
+	ORDER BY version
+`
+
+// Migration is a versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+
+	// Up applies the migration and Down rolls it back ("" if it cannot be
+	// rolled back). Both may hold several statements separated by semicolons.
+	Up   string
+	Down string
+
+	// Checksum is the SHA-256 of Up; it detects migrations edited after they were applied
+	Checksum string
+}
+
+// String returns the file name prefix of the migration, e.g. 0001_initial
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// migrationFileName matches <version>_<name>.up.sql and <version>_<name>.down.sql
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// LoadMigrations reads the migrations in a directory of fsys, ordered by version
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version", entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(data)
+			sum := sha256.Sum256(data)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Checksum == "" {
+			return nil, fmt.Errorf("migration %s has no up step", migration)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Step is a migration to apply or roll back
+type Step struct {
+	Migration
+	Rollback bool
+}
+
+// Statements returns the SQL statements of the step
+func (s Step) Statements() []string {
+	if s.Rollback {
+		return splitStatements(s.Down)
+	}
+	return splitStatements(s.Up)
+}
+
+// String describes the step, e.g. "up 0001_initial"
+func (s Step) String() string {
+	if s.Rollback {
+		return "down " + s.Migration.String()
+	}
+	return "up " + s.Migration.String()
+}
+
+// MigrationStatus is the state of a migration in the database
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+
+	// Changed is set when the migration was edited after it was applied
+	Changed bool
+
+	// Unknown is set for applied migrations this version does not have;
+	// only Version and Name are known
+	Unknown bool
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	name     string
+	checksum string
+	at       time.Time
+}
+
+// Conn is the part of a ClickHouse client the migrator uses
+type Conn interface {
+	Exec(ctx context.Context, query string, args ...interface{}) error
+	Query(ctx context.Context, query string, args ...interface{}) (driver.Rows, error)
+	QueryRow(ctx context.Context, query string, args ...interface{}) driver.Row
+}
+
+// Migrator applies schema migrations to the connected database
+type Migrator struct {
+	client     Conn
+	migrations []Migration
+}
+
+// NewMigrator returns a migrator for the migrations in a directory of fsys
+func NewMigrator(client Conn, fsys fs.FS, dir string) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{client: client, migrations: migrations}, nil
+}
+
+// Migrations returns the known migrations, ordered by version
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Status returns the state of every known migration, followed by applied
+// migrations this version does not know
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	known := make(map[int]bool)
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		status := MigrationStatus{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = row.at
+			status.Changed = row.checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+
+	var unknown []int
+	for version := range applied {
+		if !known[version] {
+			unknown = append(unknown, version)
+		}
+	}
+	sort.Ints(unknown)
+	for _, version := range unknown {
+		row := applied[version]
+		statuses = append(statuses, MigrationStatus{
+			Migration: Migration{Version: version, Name: row.name, Checksum: row.checksum},
+			Applied:   true,
+			AppliedAt: row.at,
+			Unknown:   true,
+		})
+	}
+	return statuses, nil
+}
+
+// PlanUp returns the steps that apply the pending migrations up to version
+// to (all of them if to <= 0), without changing the database
+func (m *Migrator) PlanUp(ctx context.Context, to int) ([]Step, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return planSteps(m.migrations, applied, to, false)
+}
+
+// PlanDown returns the steps that roll back the applied migrations above
+// version to, newest first, without changing the database
+func (m *Migrator) PlanDown(ctx context.Context, to int) ([]Step, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return planSteps(m.migrations, applied, to, true)
+}
+
+// Up applies the pending migrations up to version to (all of them if to <= 0)
+func (m *Migrator) Up(ctx context.Context, to int) ([]Step, error) {
+	steps, err := m.PlanUp(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	return steps, m.Apply(ctx, steps)
+}
+
+// Down rolls back the applied migrations above version to
+func (m *Migrator) Down(ctx context.Context, to int) ([]Step, error) {
+	steps, err := m.PlanDown(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	return steps, m.Apply(ctx, steps)
+}
+
+// Apply runs the statements of the steps in order and records each step in
+// schema_migrations. It stops at the first failing statement.
+func (m *Migrator) Apply(ctx context.Context, steps []Step) error {
+	if len(steps) == 0 {
+		return nil
+	}
+	if err := m.client.Exec(ctx, schemaMigrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	for _, step := range steps {
+		for _, statement := range step.Statements() {
+			if err := m.client.Exec(ctx, statement); err != nil {
+				return fmt.Errorf("migration %s failed: %w", step, err)
+			}
+		}
+		if err := m.client.Exec(ctx,
+			"INSERT INTO schema_migrations (version, name, checksum, applied, updated_at) VALUES (?, ?, ?, ?, ?)",
+			uint32(step.Version), step.Name, step.Checksum, !step.Rollback, time.Now(),
+		); err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", step, err)
+		}
+	}
+	return nil
+}
+
+// applied returns the applied migrations by version
+func (m *Migrator) applied(ctx context.Context) (map[int]appliedMigration, error) {
+	applied := make(map[int]appliedMigration)
+
+	var exists uint8
+	if err := m.client.QueryRow(ctx, "EXISTS TABLE schema_migrations").Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check schema_migrations: %w", err)
+	}
+	if exists == 0 {
+		return applied, nil
+	}
+
+	rows, err := m.client.Query(ctx, "SELECT version, name, checksum, applied, updated_at FROM schema_migrations FINAL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version uint32
+		var row appliedMigration
+		var isApplied bool
+		if err := rows.Scan(&version, &row.name, &row.checksum, &isApplied, &row.at); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		if isApplied {
+			applied[int(version)] = row
+		}
+	}
+	return applied, rows.Err()
+}
+
+// planSteps returns the steps that migrate the database from the applied
+// migrations up to or down to version to
+func planSteps(migrations []Migration, applied map[int]appliedMigration, to int, down bool) ([]Step, error) {
+	known := make(map[int]bool, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = true
+		if row, ok := applied[migration.Version]; ok && row.checksum != migration.Checksum {
+			return nil, fmt.Errorf("%w: %s", ErrChecksumMismatch, migration)
+		}
+	}
+	for version, row := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("%w: %04d_%s", ErrUnknownMigration, version, row.name)
+		}
+	}
+
+	var steps []Step
+	if !down {
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; !ok && (to <= 0 || migration.Version <= to) {
+				steps = append(steps, Step{Migration: migration})
+			}
+		}
+		return steps, nil
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok || migration.Version <= to {
+			continue
+		}
+		if strings.TrimSpace(migration.Down) == "" {
+			return nil, fmt.Errorf("%w: %s", ErrIrreversible, migration)
+		}
+		steps = append(steps, Step{Migration: migration, Rollback: true})
+	}
+	return steps, nil
+}
+
+// splitStatements splits SQL into statements at semicolons, dropping
+// comments. Semicolons in quoted strings and identifiers do not split.
+func splitStatements(sql string) []string {
+	var statements []string
+	var current strings.Builder
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			current.WriteByte('\n')
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 3
+			}
+			current.WriteByte(' ')
+		case c == '\'' || c == '"' || c == '`':
+			// Copy the quoted text; a backslash escapes the next character
+			current.WriteByte(c)
+			for i++; i < len(sql); i++ {
+				current.WriteByte(sql[i])
+				if sql[i] == '\\' && i+1 < len(sql) {
+					i++
+					current.WriteByte(sql[i])
+				} else if sql[i] == c {
+					break
+				}
+			}
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
@@ -0,0 +1,121 @@
+package chmigrate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_b.up.sql":   {Data: []byte("CREATE TABLE b (x UInt8)")},
+		"m/0001_a.up.sql":   {Data: []byte("CREATE TABLE a (x UInt8)")},
+		"m/0001_a.down.sql": {Data: []byte("DROP TABLE a")},
+		"m/README.md":       {Data: []byte("not a migration")},
+	}
+	migrations, err := LoadMigrations(fsys, "m")
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].String() != "0001_a" || migrations[1].String() != "0002_b" {
+		t.Fatalf("migrations = %v", migrations)
+	}
+	if migrations[0].Down != "DROP TABLE a" || migrations[1].Down != "" || migrations[0].Checksum == migrations[1].Checksum {
+		t.Errorf("migrations = %+v", migrations)
+	}
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"no up step":     {"m/0001_a.down.sql": {Data: []byte("DROP TABLE a")}},
+		"duplicate":      {"m/0001_a.up.sql": {Data: []byte("SELECT 1")}, "m/0001_b.up.sql": {Data: []byte("SELECT 1")}},
+		"version zero":   {"m/0000_a.up.sql": {Data: []byte("SELECT 1")}},
+		"missing folder": {},
+	} {
+		if _, err := LoadMigrations(fsys, "m"); err == nil {
+			t.Errorf("%s: LoadMigrations succeeded", name)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	sql := `
+		-- Comment; not a statement
+		CREATE TABLE a (x String DEFAULT ';') ENGINE = Memory; /* block; comment */
+		ALTER TABLE a ADD COLUMN IF NOT EXISTS "y;z" String DEFAULT 'it\'s;'  -- trailing
+		;;
+		SELECT 1
+	`
+	statements := splitStatements(sql)
+	if len(statements) != 3 {
+		t.Fatalf("statements = %q", statements)
+	}
+	if !strings.HasPrefix(statements[0], "CREATE TABLE a (x String DEFAULT ';')") {
+		t.Errorf("statement 0 = %q", statements[0])
+	}
+	if !strings.Contains(statements[1], `"y;z" String DEFAULT 'it\'s;'`) || strings.Contains(statements[1], "trailing") {
+		t.Errorf("statement 1 = %q", statements[1])
+	}
+	if statements[2] != "SELECT 1" {
+		t.Errorf("statement 2 = %q", statements[2])
+	}
+}
+
+func TestPlanSteps(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "a", Up: "A", Down: "-A", Checksum: "1"},
+		{Version: 2, Name: "b", Up: "B", Down: "-B", Checksum: "2"},
+		{Version: 3, Name: "c", Up: "C", Checksum: "3"},
+	}
+	applied := func(versions ...int) map[int]appliedMigration {
+		rows := make(map[int]appliedMigration)
+		for _, version := range versions {
+			rows[version] = appliedMigration{name: migrations[version-1].Name, checksum: migrations[version-1].Checksum}
+		}
+		return rows
+	}
+
+	tests := []struct {
+		name    string
+		applied map[int]appliedMigration
+		to      int
+		down    bool
+		want    string
+	}{
+		{"up from empty", applied(), 0, false, "[up 0001_a up 0002_b up 0003_c]"},
+		{"up to version", applied(), 2, false, "[up 0001_a up 0002_b]"},
+		{"up pending", applied(1, 2), 0, false, "[up 0003_c]"},
+		{"up missed older", applied(2), 0, false, "[up 0001_a up 0003_c]"},
+		{"up to date", applied(1, 2, 3), 0, false, "[]"},
+		{"down to version", applied(1, 2), 1, true, "[down 0002_b]"},
+		{"down all", applied(1, 2), 0, true, "[down 0002_b down 0001_a]"},
+		{"down nothing", applied(1), 1, true, "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := planSteps(migrations, tt.applied, tt.to, tt.down)
+			if err != nil {
+				t.Fatalf("planSteps: %v", err)
+			}
+			if got := fmt.Sprint(steps); got != tt.want {
+				t.Errorf("steps = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := planSteps(migrations, applied(1, 2, 3), 2, true); !errors.Is(err, ErrIrreversible) {
+		t.Errorf("rolling back 0003_c: error = %v, want ErrIrreversible", err)
+	}
+
+	changed := applied(1)
+	changed[1] = appliedMigration{name: "a", checksum: "edited"}
+	if _, err := planSteps(migrations, changed, 0, false); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("edited migration: error = %v, want ErrChecksumMismatch", err)
+	}
+
+	newer := applied(1, 2, 3)
+	newer[4] = appliedMigration{name: "d", checksum: "4"}
+	if _, err := planSteps(migrations, newer, 0, false); !errors.Is(err, ErrUnknownMigration) {
+		t.Errorf("newer database: error = %v, want ErrUnknownMigration", err)
+	}
+}
@@ -7,25 +7,49 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/higakikeita/airdig/chmigrate"
 	"github.com/higakikeita/airdig/deepdrift/pkg/api"
+	"github.com/higakikeita/airdig/deepdrift/pkg/auth"
+	"github.com/higakikeita/airdig/deepdrift/pkg/cloudtrail"
+	"github.com/higakikeita/airdig/deepdrift/pkg/drift"
 	"github.com/higakikeita/airdig/deepdrift/pkg/impact"
+	"github.com/higakikeita/airdig/deepdrift/pkg/notify"
+	"github.com/higakikeita/airdig/deepdrift/pkg/overlay"
+	"github.com/higakikeita/airdig/deepdrift/pkg/remediation"
+	"github.com/higakikeita/airdig/deepdrift/pkg/severity"
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage"
 	"github.com/higakikeita/airdig/deepdrift/pkg/storage/clickhouse"
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage/embedded"
+	"github.com/higakikeita/airdig/deepdrift/pkg/terraform"
+	"github.com/higakikeita/airdig/deepdrift/pkg/terraform/backend"
 	"github.com/higakikeita/airdig/deepdrift/pkg/tfdrift"
 	"github.com/higakikeita/airdig/deepdrift/pkg/types"
 	"github.com/higakikeita/airdig/skygraph/pkg/graph"
 )
 
 var (
-	command       = flag.String("command", "detect", "Command to run: detect, impact, watch, server")
-	stateFile     = flag.String("state", "terraform.tfstate", "Terraform state file path")
+	command       = flag.String("command", "detect", "Command to run: detect, impact, plan-impact, overlay, watch, remediate, server, migrate")
+	stateFile     = flag.String("state", "terraform.tfstate", "Terraform state file path or URL (s3://bucket/key, https://..., remote://host/org/workspace)")
+	workspaceFile = flag.String("workspaces", "", "Workspace config file (YAML) listing Terraform states to check (overrides --state)")
 	graphFile     = flag.String("graph", "", "SkyGraph JSON file path (required for impact analysis)")
+	engine        = flag.String("engine", "tfdrift", "Drift detection engine: tfdrift, native (compares --state with --graph)")
 	tfdriftPath   = flag.String("tfdrift", "", "TFDrift binary path (default: ~/tfdrift-falco/bin/tfdrift)")
 	configPath    = flag.String("config", "", "TFDrift config file path")
-	output        = flag.String("output", "", "Output file path (default: stdout)")
+	output        = flag.String("output", "", "Output file path (default: stdout; the overlay command writes Mermaid to .mmd files, JSON otherwise)")
 	watchInterval = flag.Duration("interval", 5*time.Minute, "Watch interval for continuous monitoring")
+	policyFile    = flag.String("suppressions", "", "Suppression policy file (YAML): attributes, resources and tags whose drift is ignored")
+	severityFile  = flag.String("severity-rules", "", "Severity rule file (YAML) replacing the default severity rules")
+	trailLocation = flag.String("cloudtrail", "", "CloudTrail logs or CloudTrail Lake exports to find the root cause of drift (directory, file or s3://bucket/prefix)")
+	trailWindow   = flag.Duration("cloudtrail-window", cloudtrail.DefaultWindow, "How far back from detection to search CloudTrail for the root cause")
+	strategy      = flag.String("strategy", "accept", "Remediation strategy: accept (patch Terraform code) or revert (restore the Terraform values)")
+	remediateDir  = flag.String("remediation-dir", "remediation", "Directory the remediate command writes the remediation plans to")
+	planFile      = flag.String("plan", "", "Terraform plan JSON (terraform show -json <planfile>, - for stdin) for plan-impact")
+	impactDepth   = flag.Int("impact-depth", impact.DefaultMaxDepth, "Maximum number of hops impact analysis follows from a drifted resource")
+	notifyFile    = flag.String("notify", "", "Notification config file (YAML): Slack, webhook and email sinks new drifts are sent to (watch, server)")
 
 	// Server flags
 	serverPort      = flag.Int("port", 8080, "API server port")
@@ -33,6 +57,36 @@ var (
 	clickhouseHost  = flag.String("clickhouse-host", "localhost", "ClickHouse host")
 	clickhousePort  = flag.Int("clickhouse-port", 9000, "ClickHouse port")
 	clickhouseDB    = flag.String("clickhouse-db", "deepdrift", "ClickHouse database name")
+	storageBackend  = flag.String("storage", "clickhouse", "Storage backend of the API server: clickhouse, embedded (local file, no database needed)")
+	storagePath     = flag.String("storage-path", "deepdrift.db", "Data file of the embedded storage backend")
+	authFile        = flag.String("auth", "", "Auth config file (YAML): API tokens, OIDC, mTLS, roles and the audit log (default: no authentication)")
+	tlsCert         = flag.String("tls-cert", "", "TLS certificate file of the API server (required for mTLS)")
+	tlsKey          = flag.String("tls-key", "", "TLS private key file of the API server")
+	skyGraphURL     = flag.String("skygraph-url", api.DefaultSkyGraphURL, "SkyGraph API the server reads the resource graph from")
+	corsOrigins     = flag.String("cors-origins", "", "Comma-separated origins allowed to call the API from a browser (default: same origin only)")
+	detectInterval  = flag.Duration("detect-interval", 0, "Run drift detection in the API server at this interval (0 disables)")
+	autoMigrate     = flag.Bool("auto-migrate", false, "Apply pending ClickHouse schema migrations when the API server starts")
+
+	// Migrate flags
+	migrateTo = flag.Int("migrate-to", -1, "Target schema version of the migrate command (default: latest for up, one migration back for down)")
+	dryRun    = flag.Bool("dry-run", false, "Print the statements the migrate command would run without running them")
+)
+
+var (
+	// policy は --suppressions の抑制ポリシー（未指定の場合は nil で、何も抑制しない）
+	policy *drift.Policy
+
+	// suppressor は policy を検出した drift に適用し、抑制した drift を記録する
+	suppressor = drift.NewSuppressor(nil)
+
+	// severityRules は --severity-rules の深刻度ルール（未指定の場合は nil で、既定のルールを使う）
+	severityRules *severity.Engine
+
+	// correlator は --cloudtrail から drift の根本原因を特定する（未指定の場合は nil）
+	correlator *cloudtrail.Correlator
+
+	// notifier は --notify の設定で新しい drift を通知する（未指定の場合は nil で、何も通知しない）
+	notifier *notify.Notifier
 )
 
 func main() {
@@ -46,6 +100,43 @@ func main() {
 
 	ctx := context.Background()
 
+	if *policyFile != "" {
+		var err error
+		if policy, err = drift.LoadPolicy(*policyFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		suppressor = drift.NewSuppressor(policy)
+	}
+
+	if *severityFile != "" {
+		var err error
+		if severityRules, err = severity.Load(*severityFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *trailLocation != "" {
+		source, err := cloudtrail.NewSource(*trailLocation)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		correlator = cloudtrail.NewCorrelator(source, *trailWindow)
+	}
+
+	if *notifyFile != "" {
+		config, err := notify.LoadConfig(*notifyFile)
+		if err == nil {
+			notifier, err = notify.New(config)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	switch *command {
 	case "detect":
 		if err := runDetect(ctx); err != nil {
@@ -59,42 +150,76 @@ func main() {
 			os.Exit(1)
 		}
 
+	case "plan-impact":
+		if err := runPlanImpact(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "overlay":
+		if err := runOverlay(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
 	case "watch":
 		if err := runWatch(ctx); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
+	case "remediate":
+		if err := runRemediate(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
 	case "server":
 		if err := runServer(ctx); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
+	case "migrate":
+		if err := runMigrate(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", *command)
-		fmt.Fprintf(os.Stderr, "Available commands: detect, impact, watch, server\n")
+		fmt.Fprintf(os.Stderr, "Available commands: detect, impact, plan-impact, overlay, watch, remediate, server, migrate\n")
 		os.Exit(1)
 	}
 }
 
 func runDetect(ctx context.Context) error {
 	fmt.Println("Running drift detection...")
-	fmt.Printf("State file: %s\n", *stateFile)
+	if *workspaceFile != "" {
+		fmt.Printf("Workspaces: %s\n", *workspaceFile)
+	} else {
+		fmt.Printf("State: %s\n", *stateFile)
+	}
 	fmt.Println()
 
-	// TFDrift adapter を作成
-	adapter := tfdrift.NewTFDriftAdapter(*tfdriftPath, *configPath)
-
 	// Drift detection を実行
-	events, err := adapter.DetectDrift(ctx, *stateFile)
+	events, _, err := detectDrift(ctx)
 	if err != nil {
 		return fmt.Errorf("drift detection failed: %w", err)
 	}
+	annotateRootCauses(ctx, events)
 
 	// 結果を表示
 	fmt.Printf("Found %d drift events\n\n", len(events))
 
+	if suppressed := suppressor.Drain(); len(suppressed) > 0 {
+		fmt.Printf("Suppressed %d drift events by policy:\n", len(suppressed))
+		for _, s := range suppressed {
+			fmt.Printf("  - [%s] %s (%s): %s\n", s.Reason, s.ResourceID, s.ResourceType, s.Rule)
+		}
+		fmt.Println()
+	}
+
 	if len(events) == 0 {
 		fmt.Println("✅ No drift detected")
 		return nil
@@ -102,10 +227,16 @@ func runDetect(ctx context.Context) error {
 
 	for i, event := range events {
 		fmt.Printf("%d. [%s] %s (%s)\n", i+1, event.Type, event.ResourceID, event.ResourceType)
-		fmt.Printf("   Severity: %s\n", event.Severity)
+		if event.TerraformAddress != "" {
+			fmt.Printf("   Address: %s (%s)\n", event.TerraformAddress, event.Workspace)
+		}
+		fmt.Printf("   Severity: %s%s\n", event.Severity, firedRules(event.SeverityRules))
 		if event.RootCause != nil {
 			fmt.Printf("   User: %s\n", event.RootCause.UserIdentity)
 			fmt.Printf("   Event: %s\n", event.RootCause.EventName)
+			if event.RootCause.Confidence > 0 {
+				fmt.Printf("   Confidence: %.2f (%d candidates)\n", event.RootCause.Confidence, len(event.RootCauseCandidates))
+			}
 		}
 		fmt.Println()
 	}
@@ -136,11 +267,11 @@ func runImpact(ctx context.Context) error {
 	fmt.Printf("Loaded graph: %d nodes, %d edges\n\n", g.NodeCount(), g.EdgeCount())
 
 	// Drift detection を実行
-	adapter := tfdrift.NewTFDriftAdapter(*tfdriftPath, *configPath)
-	events, err := adapter.DetectDrift(ctx, *stateFile)
+	events, _, err := detectDriftWithGraph(ctx, g)
 	if err != nil {
 		return fmt.Errorf("drift detection failed: %w", err)
 	}
+	annotateRootCauses(ctx, events)
 
 	if len(events) == 0 {
 		fmt.Println("✅ No drift detected")
@@ -149,6 +280,8 @@ func runImpact(ctx context.Context) error {
 
 	// Impact analysis を実行
 	analyzer := impact.NewAnalyzer(g)
+	analyzer.SetSeverityEngine(severityRules)
+	analyzer.SetMaxDepth(*impactDepth)
 	results, err := analyzer.AnalyzeBatch(events)
 	if err != nil {
 		return fmt.Errorf("impact analysis failed: %w", err)
@@ -160,9 +293,14 @@ func runImpact(ctx context.Context) error {
 	for i, result := range results {
 		event := events[i]
 		fmt.Printf("%d. [%s] %s (%s)\n", i+1, event.Type, event.ResourceID, event.ResourceType)
-		fmt.Printf("   Severity: %s\n", result.Severity)
+		fmt.Printf("   Severity: %s%s\n", result.Severity, firedRules(result.SeverityRules))
 		fmt.Printf("   Affected resources: %d\n", result.AffectedResourceCount)
 		fmt.Printf("   Blast radius: %d hops\n", result.BlastRadius)
+		fmt.Printf("   Impact score: %.2f\n", result.ImpactScore)
+
+		for _, resource := range result.AffectedResources {
+			fmt.Printf("     - %s (%.2f): %s\n", resource.ResourceID, resource.ImpactScore, propagationLabel(resource.PropagationPath))
+		}
 
 		if len(result.Recommendations) > 0 {
 			fmt.Println("   Recommendations:")
@@ -181,27 +319,503 @@ func runImpact(ctx context.Context) error {
 	return nil
 }
 
+// runPlanImpact は Terraform の plan を適用した場合のインパクトを現在のグラフで分析する
+func runPlanImpact(ctx context.Context) error {
+	if *graphFile == "" {
+		return fmt.Errorf("--graph is required for plan impact analysis")
+	}
+	if *planFile == "" {
+		return fmt.Errorf("--plan is required for plan impact analysis")
+	}
+
+	fmt.Println("Running plan impact analysis...")
+	fmt.Printf("Plan file: %s\n", *planFile)
+	fmt.Printf("Graph file: %s\n", *graphFile)
+	fmt.Println()
+
+	plan, err := terraform.LoadPlan(*planFile)
+	if err != nil {
+		return fmt.Errorf("failed to load plan: %w", err)
+	}
+
+	g, err := loadGraph(*graphFile)
+	if err != nil {
+		return fmt.Errorf("failed to load graph: %w", err)
+	}
+
+	fmt.Printf("Loaded graph: %d nodes, %d edges\n\n", g.NodeCount(), g.EdgeCount())
+
+	analyzer := impact.NewAnalyzer(g)
+	analyzer.SetSeverityEngine(severityRules)
+	analyzer.SetMaxDepth(*impactDepth)
+	report, err := analyzer.Simulate(plan)
+	if err != nil {
+		return fmt.Errorf("plan impact analysis failed: %w", err)
+	}
+
+	if report.ChangeCount == 0 {
+		fmt.Println("✅ No changes planned")
+		return nil
+	}
+
+	fmt.Printf("Analyzed %d planned changes\n\n", report.ChangeCount)
+
+	for i, change := range report.Changes {
+		result := change.Impact
+		fmt.Printf("%d. [%s] %s (%s)\n", i+1, change.Action, change.Address, change.Event.ResourceType)
+		fmt.Printf("   Severity: %s%s\n", result.Severity, firedRules(result.SeverityRules))
+		fmt.Printf("   Affected resources: %d\n", result.AffectedResourceCount)
+		fmt.Printf("   Blast radius: %d hops\n", result.BlastRadius)
+		fmt.Printf("   Impact score: %.2f\n", result.ImpactScore)
+
+		for _, resource := range result.AffectedResources {
+			fmt.Printf("     - %s (%.2f): %s\n", resource.ResourceID, resource.ImpactScore, propagationLabel(resource.PropagationPath))
+		}
+
+		if len(result.Recommendations) > 0 {
+			fmt.Println("   Recommendations:")
+			for _, rec := range result.Recommendations {
+				fmt.Printf("     • %s\n", rec)
+			}
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("Risk: %s\n", report.Severity)
+	fmt.Printf("  Changes: %d create, %d update, %d replace, %d delete\n",
+		report.Actions[terraform.ActionCreate], report.Actions[terraform.ActionUpdate],
+		report.Actions[terraform.ActionReplace], report.Actions[terraform.ActionDelete])
+	fmt.Printf("  Affected resources: %d (blast radius %d hops, impact score %.2f)\n",
+		report.AffectedResourceCount, report.BlastRadius, report.ImpactScore)
+
+	// 出力ファイルに保存
+	if *output != "" {
+		return saveJSON(report, *output)
+	}
+
+	return nil
+}
+
+// propagationLabel は影響が伝わった経路を "a -[ownership]-> b" の形に整形
+func propagationLabel(path []types.PropagationStep) string {
+	if len(path) == 0 {
+		return ""
+	}
+	label := path[0].From
+	for _, step := range path {
+		label += fmt.Sprintf(" -[%s]-> %s", step.RelationType, step.To)
+	}
+	return label
+}
+
+// runOverlay は意図した構成（全ワークスペースの state）と --graph の実際の構成を重ねたグラフを作成
+// --output が .mmd の場合は Mermaid の図、それ以外は JSON で保存する
+func runOverlay(ctx context.Context) error {
+	if *graphFile == "" {
+		return fmt.Errorf("--graph is required for the overlay")
+	}
+
+	fmt.Println("Building the intended vs actual overlay...")
+	fmt.Printf("Graph file: %s\n", *graphFile)
+	fmt.Println()
+
+	g, err := loadGraph(*graphFile)
+	if err != nil {
+		return fmt.Errorf("failed to load graph: %w", err)
+	}
+
+	targets, err := workspaces()
+	if err != nil {
+		return err
+	}
+	states := make([]overlay.Workspace, 0, len(targets))
+	names := make([]string, 0, len(targets))
+	for _, ws := range targets {
+		state, err := loadState(ctx, ws)
+		if err != nil {
+			if len(targets) == 1 {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "workspace %s: %v\n", ws.Name, err)
+			continue
+		}
+		states = append(states, overlay.Workspace{Name: ws.Name, State: state})
+		names = append(names, ws.Name)
+	}
+
+	// drift を検出してノードとエッジに紐づける
+	events, _, err := detectDriftWithGraph(ctx, g)
+	if err != nil {
+		return fmt.Errorf("drift detection failed: %w", err)
+	}
+
+	result := overlay.Build(overlay.IntendedGraph(states), g, events)
+	result.Workspaces = names
+
+	// 結果を表示
+	fmt.Printf("Nodes: %s\n", overlaySummary(result.Summary.Nodes))
+	fmt.Printf("Edges: %s\n\n", overlaySummary(result.Summary.Edges))
+
+	if result.IsInSync() {
+		fmt.Println("✅ The actual architecture matches Terraform")
+	}
+	for _, node := range result.Nodes {
+		if node.Status == overlay.StatusInSync {
+			continue
+		}
+		fmt.Printf("  - [%s] %s (%s)", node.Status, node.ID, node.Type)
+		if node.TerraformAddress != "" {
+			fmt.Printf(" %s", node.TerraformAddress)
+		}
+		if len(node.DriftEventIDs) > 0 {
+			fmt.Printf(" drifts: %s", strings.Join(node.DriftEventIDs, ", "))
+		}
+		fmt.Println()
+	}
+	for _, edge := range result.Edges {
+		if edge.Status != overlay.StatusInSync {
+			fmt.Printf("  - [%s] %s -[%s]-> %s\n", edge.Status, edge.From, edge.Type, edge.To)
+		}
+	}
+	fmt.Println()
+
+	// 出力ファイルに保存
+	if *output == "" {
+		return nil
+	}
+	if strings.HasSuffix(*output, ".mmd") || strings.HasSuffix(*output, ".mermaid") {
+		var b strings.Builder
+		if err := result.WriteMermaid(&b); err != nil {
+			return err
+		}
+		if err := os.WriteFile(*output, []byte(b.String()), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Saved to: %s\n", *output)
+		return nil
+	}
+	return saveJSON(result, *output)
+}
+
+// loadState はワークスペースの state を読み込む
+func loadState(ctx context.Context, ws backend.Workspace) (*terraform.State, error) {
+	source, err := backend.NewSource(ws)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Load(ctx, source)
+}
+
+// overlaySummary は Status ごとの数を整形
+func overlaySummary(counts map[overlay.Status]int) string {
+	parts := make([]string, 0, len(overlay.Statuses))
+	for _, status := range overlay.Statuses {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[status], status))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// driftLabel は watch の出力用に drift のリソースとワークスペースを整形
+func driftLabel(event *types.DriftEvent) string {
+	label := fmt.Sprintf("%s (%s)", event.ResourceID, event.ResourceType)
+	if event.Workspace != "" {
+		label += " @" + event.Workspace
+	}
+	return label
+}
+
+func runRemediate(ctx context.Context) error {
+	s, err := remediation.ParseStrategy(*strategy)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Generating %s remediation plans...\n", s)
+	fmt.Printf("Output directory: %s\n", *remediateDir)
+	fmt.Println()
+
+	// Drift detection を実行
+	events, _, err := detectDrift(ctx)
+	if err != nil {
+		return fmt.Errorf("drift detection failed: %w", err)
+	}
+
+	if len(events) == 0 {
+		fmt.Println("✅ No drift detected")
+		return nil
+	}
+
+	plans := make([]*remediation.Plan, 0, len(events))
+	for _, event := range events {
+		plan, err := remediation.Generate(event, s)
+		if err != nil {
+			return fmt.Errorf("failed to generate remediation for %s: %w", event.ID, err)
+		}
+		plans = append(plans, plan)
+	}
+
+	files, err := remediation.WriteFiles(*remediateDir, plans)
+	if err != nil {
+		return err
+	}
+
+	for i, plan := range plans {
+		fmt.Printf("%d. [%s] %s\n", i+1, plan.DriftType, driftLabel(events[i]))
+		fmt.Printf("   %s\n", plan.Summary)
+		for _, command := range plan.Commands {
+			fmt.Printf("   $ %s\n", command)
+		}
+		for _, note := range plan.Notes {
+			fmt.Printf("   Note: %s\n", note)
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("Wrote %d files to %s\n", len(files), *remediateDir)
+	return nil
+}
+
+// annotateRootCauses は --cloudtrail のログから drift の根本原因を特定する
+// CloudTrail を読めない場合も drift の検出結果は返すため、警告のみ表示
+func annotateRootCauses(ctx context.Context, events []*types.DriftEvent) {
+	if correlator == nil {
+		return
+	}
+	if err := correlator.Annotate(ctx, events); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// notifyDrifts は新しい drift を --notify の通知先に送る
+// 送れなかった通知は記録されるため、警告のみ表示して監視を続ける
+func notifyDrifts(ctx context.Context, created []*types.DriftEvent) {
+	if err := notifier.Notify(ctx, created); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
 func runWatch(ctx context.Context) error {
 	fmt.Println("Starting continuous drift monitoring...")
 	fmt.Printf("Interval: %s\n", *watchInterval)
 	fmt.Println()
 
-	adapter := tfdrift.NewTFDriftAdapter(*tfdriftPath, *configPath)
+	// 設定の誤りは監視を始める前に返す
+	targets, err := workspaces()
+	if err != nil {
+		return err
+	}
+
+	// Ctrl+C や SIGTERM で止めたときは送信待ちの通知を送ってから終了する
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(*watchInterval)
+	defer ticker.Stop()
 
-	// コールバック関数
-	callback := func(events []*types.DriftEvent) error {
-		fmt.Printf("[%s] Detected %d drift events\n", time.Now().Format(time.RFC3339), len(events))
+	// 同じ drift は検出のたびに同じ ID になるため、新しい drift と解消された drift だけを表示する
+	tracker := drift.NewTracker()
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("\nStopping drift monitoring...")
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := notifier.Flush(flushCtx); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+			return nil
+		case <-ticker.C:
+			// 毎回 state（と --graph）を読み直すため apply やスキャンの結果が反映される
+			started := time.Now()
+			events, checked, err := detectDrift(ctx)
+			if err != nil {
+				// エラーをログに記録して継続（ダイジェストの期間が過ぎた drift は通知する）
+				fmt.Fprintf(os.Stderr, "drift detection failed: %v\n", err)
+				notifyDrifts(ctx, nil)
+				continue
+			}
+
+			// 抑制した drift は毎回同じものが出るため表示しない
+			// すべてのワークスペースを検出できた場合、再び抑制しなかった drift は解消したものとして忘れる
+			suppressor.Drain()
+			if len(checked) == len(targets) {
+				suppressor.Prune(started)
+			}
+
+			changes := tracker.Observe(events, driftScope(checked, targets))
+			if changes.IsEmpty() {
+				// ダイジェストの期間が過ぎた drift は新しい drift がなくても通知する
+				notifyDrifts(ctx, nil)
+				continue
+			}
+			// 根本原因は drift を最初に検出したときだけ探す
+			annotateRootCauses(ctx, changes.Created)
+			notifyDrifts(ctx, changes.Created)
 
-		for _, event := range events {
-			fmt.Printf("  - [%s] %s (%s)\n", event.Type, event.ResourceID, event.ResourceType)
+			fmt.Printf("[%s] %d new, %d resolved, %d open drift events\n", time.Now().Format(time.RFC3339),
+				len(changes.Created), len(changes.Resolved), len(tracker.Open()))
+			for _, event := range changes.Created {
+				fmt.Printf("  + [%s] %s\n", event.Type, driftLabel(event))
+				if event.RootCause != nil {
+					fmt.Printf("    by %s (%s)\n", event.RootCause.UserIdentity, event.RootCause.EventName)
+				}
+			}
+			for _, event := range changes.Resolved {
+				fmt.Printf("  - [resolved] %s, open since %s\n", driftLabel(event), event.FirstSeen.Format(time.RFC3339))
+			}
+			fmt.Println()
 		}
-		fmt.Println()
+	}
+}
 
-		return nil
+// workspaces は --workspaces（指定がなければ --state）から対象のワークスペースを返す
+func workspaces() ([]backend.Workspace, error) {
+	if *workspaceFile != "" {
+		return backend.LoadWorkspaces(*workspaceFile)
+	}
+
+	ws, err := backend.ParseWorkspace(*stateFile)
+	if err != nil {
+		return nil, err
+	}
+	return []backend.Workspace{ws}, nil
+}
+
+// detectDrift は --engine に従って drift を検出
+// 検出できたワークスペースの名前も返す
+func detectDrift(ctx context.Context) ([]*types.DriftEvent, []string, error) {
+	if *engine != "native" {
+		return detectDriftWithGraph(ctx, nil)
+	}
+
+	if *graphFile == "" {
+		return nil, nil, fmt.Errorf("--graph is required for the native engine")
+	}
+	g, err := loadGraph(*graphFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load graph: %w", err)
+	}
+	return detectDriftWithGraph(ctx, g)
+}
+
+// detectDriftWithGraph は全ワークスペースの drift を検出（tfdrift エンジンではグラフを使わない）
+// 複数のワークスペースがある場合、1つの失敗で他のワークスペースの検出を止めない
+func detectDriftWithGraph(ctx context.Context, g *graph.Graph) ([]*types.DriftEvent, []string, error) {
+	targets, err := workspaces()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events := make([]*types.DriftEvent, 0)
+	checked := make([]string, 0, len(targets))
+	states := make([]*terraform.State, 0, len(targets))
+	for _, ws := range targets {
+		wsEvents, state, err := detectWorkspaceDrift(ctx, ws, g)
+		if err != nil {
+			if len(targets) == 1 {
+				return nil, nil, err
+			}
+			fmt.Fprintf(os.Stderr, "workspace %s: %v\n", ws.Name, err)
+			continue
+		}
+
+		for _, event := range wsEvents {
+			event.Workspace = ws.Name
+		}
+		events = append(events, wsEvents...)
+		checked = append(checked, ws.Name)
+		if state != nil {
+			states = append(states, state)
+		}
 	}
 
-	// 継続的監視を開始
-	return adapter.WatchDrift(ctx, *stateFile, *watchInterval, callback)
+	// 管理外のリソースは全ワークスペースの state を読めた場合だけ検出する
+	if *engine == "native" && len(states) == len(targets) {
+		events = append(events, newComparator().DetectUnmanaged(states, g)...)
+	}
+
+	return events, checked, nil
+}
+
+// newComparator は抑制ポリシーと深刻度ルールを設定した Comparator を作成
+func newComparator() *drift.Comparator {
+	comparator := drift.NewComparator()
+	comparator.SetSuppressor(suppressor)
+	comparator.SetSeverityEngine(severityRules)
+	return comparator
+}
+
+// driftScope は drift を解消扱いにできるワークスペース
+// 全ワークスペースを検出できた場合は管理外のリソースの drift も含める
+func driftScope(checked []string, targets []backend.Workspace) []string {
+	if len(checked) != len(targets) {
+		return checked
+	}
+	return append(append([]string{}, checked...), drift.UnmanagedWorkspace)
+}
+
+// detectWorkspaceDrift は1つのワークスペースの drift を検出
+// native エンジンでは読み込んだ state も返す
+func detectWorkspaceDrift(ctx context.Context, ws backend.Workspace, g *graph.Graph) ([]*types.DriftEvent, *terraform.State, error) {
+	source, err := backend.NewSource(ws)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch *engine {
+	case "tfdrift":
+		// TFDrift はファイルしか読めないため、リモートの state は一時ファイルに保存して渡す
+		path, cleanup, err := localStatePath(ctx, source)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer cleanup()
+
+		adapter := tfdrift.NewTFDriftAdapter(*tfdriftPath, *configPath)
+		adapter.SetSuppressor(suppressor)
+		adapter.SetSeverityEngine(severityRules)
+		events, err := adapter.DetectDrift(ctx, path)
+		return events, nil, err
+
+	case "native":
+		state, err := backend.Load(ctx, source)
+		if err != nil {
+			return nil, nil, err
+		}
+		return newComparator().Detect(state, g), state, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown engine: %s (available: tfdrift, native)", *engine)
+	}
+}
+
+// localStatePath は state のローカルファイルのパスを返す
+func localStatePath(ctx context.Context, source backend.Source) (string, func(), error) {
+	if local, ok := source.(*backend.LocalSource); ok {
+		return local.Path(), func() {}, nil
+	}
+
+	data, err := source.Read(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	file, err := os.CreateTemp("", "deepdrift-*.tfstate")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.Remove(file.Name()) }
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		cleanup()
+		return "", nil, err
+	}
+	if err := file.Close(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return file.Name(), cleanup, nil
 }
 
 func loadGraph(filename string) (*graph.Graph, error) {
@@ -235,26 +849,24 @@ func saveJSON(data interface{}, filename string) error {
 func runServer(ctx context.Context) error {
 	fmt.Println("Starting API server...")
 	fmt.Printf("Server: %s:%d\n", *serverHost, *serverPort)
-	fmt.Printf("ClickHouse: %s:%d/%s\n", *clickhouseHost, *clickhousePort, *clickhouseDB)
 	fmt.Println()
 
-	// Connect to ClickHouse
-	chConfig := &clickhouse.Config{
-		Host:     *clickhouseHost,
-		Port:     *clickhousePort,
-		Database: *clickhouseDB,
-		Username: "default",
-		Password: "",
-		Debug:    false,
+	store, err := openStorage()
+	if err != nil {
+		return err
 	}
+	defer store.Close()
 
-	chClient, err := clickhouse.NewClient(chConfig)
+	targets, err := workspaces()
 	if err != nil {
-		return fmt.Errorf("failed to connect to ClickHouse: %w", err)
+		return err
 	}
-	defer chClient.Close()
 
-	fmt.Println("✅ Connected to ClickHouse")
+	authenticator, err := loadAuth()
+	if err != nil {
+		return err
+	}
+	defer authenticator.Close()
 
 	// Create API server
 	apiConfig := &api.Config{
@@ -262,11 +874,21 @@ func runServer(ctx context.Context) error {
 		Port:           *serverPort,
 		ClickHouseAddr: fmt.Sprintf("%s:%d", *clickhouseHost, *clickhousePort),
 		ClickHouseDB:   *clickhouseDB,
-		EnableCORS:     true,
-		AllowedOrigins: []string{"*"},
+		SkyGraphURL:    *skyGraphURL,
+		EnableCORS:     *corsOrigins != "",
+		AllowedOrigins: strings.Split(*corsOrigins, ","),
+		Workspaces:     targets,
+		DetectInterval: *detectInterval,
+		Suppressions:   policy,
+		SeverityRules:  severityRules,
+		CloudTrail:     correlator,
+		Notifier:       notifier,
+		Auth:           authenticator,
+		TLSCert:        *tlsCert,
+		TLSKey:         *tlsKey,
 	}
 
-	server := api.NewServer(apiConfig, chClient)
+	server := api.NewServer(apiConfig, store)
 
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -281,17 +903,31 @@ func runServer(ctx context.Context) error {
 		}
 	}()
 
-	fmt.Printf("✅ Server started on http://%s:%d\n", *serverHost, *serverPort)
+	scheme := "http"
+	if *tlsCert != "" {
+		scheme = "https"
+	}
+	fmt.Printf("✅ Server started on %s://%s:%d\n", scheme, *serverHost, *serverPort)
 	fmt.Println()
 	fmt.Println("API Endpoints:")
 	fmt.Println("  GET  /health                    - Health check")
 	fmt.Println("  GET  /api/v1/drifts             - List drift events")
+	if authenticator != nil {
+		fmt.Println("  POST /api/v1/drifts             - Create a drift event")
+	}
 	fmt.Println("  GET  /api/v1/drifts/{id}        - Get drift event by ID")
+	fmt.Println("  POST /api/v1/drifts/{id}/acknowledge|resolve|reopen - Change drift status")
+	fmt.Println("  POST /api/v1/drifts/detect      - Run drift detection now")
 	fmt.Println("  GET  /api/v1/drifts/stats       - Get drift statistics")
 	fmt.Println("  GET  /api/v1/impact             - List impact analysis")
+	if authenticator != nil {
+		fmt.Println("  POST /api/v1/impact             - Create an impact analysis")
+	}
 	fmt.Println("  GET  /api/v1/impact/{id}        - Get impact by drift ID")
 	fmt.Println("  GET  /api/v1/impact/stats       - Get impact statistics")
 	fmt.Println("  GET  /api/v1/impact/high        - Get high impact drifts")
+	fmt.Println("  POST /api/v1/impact/simulate    - Simulate a Terraform plan (terraform show -json)")
+	fmt.Println("  GET  /api/v1/stream             - Stream drift events and impact analysis (SSE, WebSocket)")
 	fmt.Println()
 	fmt.Println("Press Ctrl+C to stop the server...")
 
@@ -306,3 +942,217 @@ func runServer(ctx context.Context) error {
 		return fmt.Errorf("server error: %w", err)
 	}
 }
+
+// openStorage は --storage で選んだストレージに接続する
+func openStorage() (storage.Backend, error) {
+	switch *storageBackend {
+	case "clickhouse":
+		fmt.Printf("ClickHouse: %s:%d/%s\n", *clickhouseHost, *clickhousePort, *clickhouseDB)
+
+		chConfig := clickhouseConfig()
+		if *autoMigrate {
+			if err := clickhouse.CreateDatabase(chConfig); err != nil {
+				return nil, fmt.Errorf("failed to create the ClickHouse database: %w", err)
+			}
+		}
+
+		chClient, err := clickhouse.NewClient(chConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to ClickHouse: %w", err)
+		}
+
+		fmt.Println("✅ Connected to ClickHouse")
+
+		if err := checkMigrations(chClient); err != nil {
+			chClient.Close()
+			return nil, err
+		}
+		return chClient, nil
+
+	case "embedded":
+		db, err := embedded.Open(*storagePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open embedded storage: %w", err)
+		}
+
+		fmt.Printf("✅ Opened embedded storage: %s\n", *storagePath)
+		return db, nil
+
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s (available: clickhouse, embedded)", *storageBackend)
+	}
+}
+
+// clickhouseConfig は --clickhouse-* の ClickHouse の接続設定を返す
+func clickhouseConfig() *clickhouse.Config {
+	return &clickhouse.Config{
+		Host:     *clickhouseHost,
+		Port:     *clickhousePort,
+		Database: *clickhouseDB,
+		Username: "default",
+		Password: "",
+		Debug:    false,
+	}
+}
+
+// checkMigrations は --auto-migrate の場合は未適用のマイグレーションを適用し、
+// そうでない場合は未適用のマイグレーションがあれば警告する
+func checkMigrations(client *clickhouse.Client) error {
+	ctx := context.Background()
+	migrator, err := clickhouse.NewMigrator(client)
+	if err != nil {
+		return err
+	}
+
+	if !*autoMigrate {
+		if steps, err := migrator.PlanUp(ctx, 0); err != nil {
+			fmt.Printf("⚠️  Could not check the schema migrations: %v\n", err)
+		} else if len(steps) > 0 {
+			fmt.Printf("⚠️  %d schema migrations are pending: run --command migrate, or start the server with --auto-migrate\n", len(steps))
+		}
+		return nil
+	}
+
+	steps, err := migrator.Up(ctx, 0)
+	if err != nil {
+		return fmt.Errorf("failed to migrate the ClickHouse schema: %w", err)
+	}
+	for _, step := range steps {
+		fmt.Printf("✅ Applied migration %s\n", step.Migration)
+	}
+	return nil
+}
+
+// runMigrate は ClickHouse のスキーマのマイグレーションを適用・ロールバックする
+//
+//	deepdrift --command migrate [up|down|status]
+//
+// up は --migrate-to のバージョンまで（既定は最新まで）適用し、down は
+// --migrate-to のバージョンまで（既定は1つだけ）ロールバックする。
+// --dry-run の場合は実行する SQL を表示するだけでデータベースを変更しない。
+// フラグはアクションより前に指定する（後ろのフラグは解析されないのでエラーにする）。
+func runMigrate(ctx context.Context) error {
+	if flag.NArg() > 1 {
+		return fmt.Errorf("unexpected arguments after the migrate action: %s (flags go before the action)", strings.Join(flag.Args()[1:], " "))
+	}
+	action := flag.Arg(0)
+	if action == "" {
+		action = "up"
+	}
+	if action != "up" && action != "down" && action != "status" {
+		return fmt.Errorf("unknown migrate action: %s (available: up, down, status)", action)
+	}
+
+	chConfig := clickhouseConfig()
+	fmt.Printf("ClickHouse: %s:%d/%s\n", chConfig.Host, chConfig.Port, chConfig.Database)
+	if action == "up" && !*dryRun {
+		if err := clickhouse.CreateDatabase(chConfig); err != nil {
+			return fmt.Errorf("failed to create the ClickHouse database: %w", err)
+		}
+	}
+
+	client, err := clickhouse.NewClient(chConfig)
+	if err != nil {
+		return fmt.Errorf("failed to connect to ClickHouse: %w", err)
+	}
+	defer client.Close()
+
+	migrator, err := clickhouse.NewMigrator(client)
+	if err != nil {
+		return err
+	}
+
+	if action == "status" {
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+		for _, status := range statuses {
+			state, appliedAt := "pending", ""
+			if status.Applied {
+				state, appliedAt = "applied", status.AppliedAt.Local().Format(time.RFC3339)
+			}
+			switch {
+			case status.Unknown:
+				state += " (unknown to this version)"
+			case status.Changed:
+				state += " (changed since)"
+			}
+			fmt.Printf("  %-32s %-34s %s\n", status.Migration, state, appliedAt)
+		}
+		return nil
+	}
+
+	var steps []chmigrate.Step
+	if action == "up" {
+		steps, err = migrator.PlanUp(ctx, *migrateTo)
+	} else {
+		steps, err = migrator.PlanDown(ctx, max(*migrateTo, 0))
+		if *migrateTo < 0 && len(steps) > 1 {
+			steps = steps[:1]
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		fmt.Println("✅ Schema is up to date")
+		return nil
+	}
+
+	fmt.Println()
+	for _, step := range steps {
+		if *dryRun {
+			fmt.Printf("-- %s\n", step)
+			for _, statement := range step.Statements() {
+				fmt.Printf("%s;\n\n", statement)
+			}
+			continue
+		}
+
+		fmt.Printf("🔄 %s\n", step)
+		if err := migrator.Apply(ctx, []chmigrate.Step{step}); err != nil {
+			return err
+		}
+	}
+
+	if *dryRun {
+		fmt.Printf("Dry run: %d migrations not applied\n", len(steps))
+	} else {
+		fmt.Printf("✅ %d migrations done\n", len(steps))
+	}
+	return nil
+}
+
+// loadAuth は --auth の認証の設定を読み込む（未指定の場合は nil で、認証しない）
+func loadAuth() (*auth.Authenticator, error) {
+	if *authFile == "" {
+		fmt.Println("⚠️  Authentication is disabled (--auth): anyone who can reach the API can read drifts and change their status. Creating drift events and impact analysis is turned off")
+		return nil, nil
+	}
+
+	config, err := auth.LoadConfig(*authFile)
+	if err != nil {
+		return nil, err
+	}
+	authenticator, err := auth.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up authentication: %w", err)
+	}
+
+	fmt.Printf("✅ Authentication enabled: %d API tokens, OIDC %t, mTLS %t\n", len(config.Tokens), config.OIDC != nil, config.MTLS != nil)
+	return authenticator, nil
+}
+
+// firedRules は深刻度の計算で一致したルール名を " (rule1, rule2)" の形式で返す
+func firedRules(rules []types.SeverityRule) string {
+	if len(rules) == 0 {
+		return ""
+	}
+	names := make([]string, len(rules))
+	for i, rule := range rules {
+		names[i] = rule.Name
+	}
+	return " (" + strings.Join(names, ", ") + ")"
+}
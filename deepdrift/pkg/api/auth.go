@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/auth"
+)
+
+// requiredRole returns the role a request needs when authentication is enabled:
+//
+//   - health checks, the UI assets and CORS preflights are open
+//   - reads (and plan simulations, which store nothing) need viewer
+//   - creating drift events and impact analysis needs admin
+//   - every other change (drift status, detection) needs operator
+func requiredRole(r *http.Request) auth.Role {
+	path := r.URL.Path
+	switch {
+	case path == "/health" || path == "/api/v1/health" || strings.HasPrefix(path, "/ui/"):
+		return ""
+	case r.Method == http.MethodOptions:
+		return ""
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return auth.RoleViewer
+	case path == "/api/v1/impact/simulate":
+		return auth.RoleViewer
+	case path == "/api/v1/drifts" || path == "/api/v1/impact":
+		return auth.RoleAdmin
+	default:
+		return auth.RoleOperator
+	}
+}
+
+// queryToken accepts the token of the UI in the access_token query parameter
+// of the requests a browser makes without the UI's headers: the event stream
+// (EventSource can't set headers) and the overlay download link. The token is
+// moved to the Authorization header and removed from the URL, so it isn't
+// logged. Other requests must send the header.
+func queryToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		token := query.Get("access_token")
+		if token == "" || r.Method != http.MethodGet || r.Header.Get("Authorization") != "" ||
+			(r.URL.Path != "/api/v1/stream" && r.URL.Path != "/api/v1/graph/overlay") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		query.Del("access_token")
+		r = r.Clone(r.Context())
+		r.URL.RawQuery = query.Encode()
+		r.RequestURI = r.URL.RequestURI()
+		r.Header.Set("Authorization", "Bearer "+token)
+		next.ServeHTTP(w, r)
+	})
+}
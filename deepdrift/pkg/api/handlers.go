@@ -3,19 +3,22 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/higakikeita/airdig/deepdrift/pkg/auth"
 	"github.com/higakikeita/airdig/deepdrift/pkg/drift"
-	"github.com/higakikeita/airdig/deepdrift/pkg/storage/clickhouse"
+	"github.com/higakikeita/airdig/deepdrift/pkg/impact"
+	"github.com/higakikeita/airdig/deepdrift/pkg/remediation"
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage"
 	"github.com/higakikeita/airdig/deepdrift/pkg/terraform"
 	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
 )
 
 // handleHealth handles health check requests
@@ -31,11 +34,11 @@ func (s *Server) handleHealth() http.HandlerFunc {
 			"timestamp": time.Now().Unix(),
 		}
 
-		if s.chClient != nil {
+		if s.storage != nil {
 			ctx := r.Context()
-			status, err := s.chClient.HealthCheck(ctx)
+			status, err := s.storage.Health(ctx)
 			if err == nil {
-				response["clickhouse"] = status
+				response[s.storage.Name()] = status
 			}
 		} else {
 			response["mode"] = "in-memory"
@@ -45,264 +48,53 @@ func (s *Server) handleHealth() http.HandlerFunc {
 	}
 }
 
-// detectDrifts performs real-time drift detection by comparing Terraform state with AWS resources
-func (s *Server) detectDrifts(ctx context.Context) ([]*types.DriftEvent, error) {
-	// 1. Load Terraform state
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
-	}
-
-	statePath := filepath.Join(homeDir, "tfdrift-falco", "examples", "terraform", "terraform.tfstate")
-
-	// Check if comprehensive test state exists
-	comprehensivePath := filepath.Join(homeDir, "tfdrift-falco", "examples", "comprehensive-test", "terraform.tfstate")
-	if _, err := os.Stat(comprehensivePath); err == nil {
-		statePath = comprehensivePath
-	}
-
-	tfReader := terraform.NewStateReader(statePath)
-	tfState, err := tfReader.Load()
-	if err != nil {
-		log.Printf("Warning: Failed to load Terraform state from %s: %v", statePath, err)
-		return []*types.DriftEvent{}, nil
+// detectDrifts performs real-time drift detection by comparing the Terraform state of every configured workspace with AWS resources.
+// It also returns the workspaces whose state was loaded, i.e. the scope in which missing drifts count as resolved.
+func (s *Server) detectDrifts(ctx context.Context) ([]*types.DriftEvent, []string, error) {
+	// 1. Load Terraform state of each workspace
+	states := s.loadStates(ctx, "")
+	if len(states) == 0 {
+		log.Printf("Warning: No Terraform state available for drift detection")
+		return []*types.DriftEvent{}, []string{}, nil
 	}
 
-	log.Printf("Loaded Terraform state with %d resources", len(tfState.Resources))
-
-	// 2. Get AWS EC2 instances from SkyGraph
-	awsInstances, err := s.getAWSEC2Instances(ctx)
+	// 2. Get the actual resources from SkyGraph
+	g, err := s.getSkyGraph(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get AWS resources: %w", err)
+		return nil, nil, fmt.Errorf("failed to get AWS resources: %w", err)
 	}
+	log.Printf("Retrieved %d resources from SkyGraph", len(g.Nodes))
 
-	log.Printf("Retrieved %d AWS EC2 instances from SkyGraph", len(awsInstances))
-
-	// 3. Compare Terraform resources with AWS resources
+	// 3. Compare Terraform resources with the actual resources
 	comparator := drift.NewComparator()
+	comparator.SetSuppressor(s.suppressor)
+	comparator.SetSeverityEngine(s.severity)
 	drifts := []*types.DriftEvent{}
+	checked := make([]string, 0, len(states))
+	loaded := make([]*terraform.State, 0, len(states))
 
-	tfEC2Instances := tfState.GetEC2Instances()
-	log.Printf("Found %d EC2 instances in Terraform state", len(tfEC2Instances))
-
-	for _, tfResource := range tfEC2Instances {
-		if len(tfResource.Instances) == 0 {
-			continue
-		}
-
-		tfID, ok := tfResource.Instances[0].Attributes["id"].(string)
-		if !ok {
-			continue
-		}
-
-		// Find matching AWS resource
-		for _, awsResource := range awsInstances {
-			awsID, _ := awsResource["instance_id"].(string)
-			if awsID == tfID {
-				if driftEvent := comparator.CompareEC2(tfResource, awsResource); driftEvent != nil {
-					log.Printf("Drift detected for %s: %s", tfID, driftEvent.Diff)
-					drifts = append(drifts, driftEvent)
-				}
-				break
-			}
-		}
-	}
-
-	// 4. Check Security Groups
-	awsSGs, err := s.getAWSSecurityGroups(ctx)
-	if err == nil {
-		tfSGs := tfState.GetSecurityGroups()
-		log.Printf("Found %d Security Groups in Terraform state", len(tfSGs))
-
-		for _, tfResource := range tfSGs {
-			if len(tfResource.Instances) == 0 {
-				continue
-			}
-
-			tfID, ok := tfResource.Instances[0].Attributes["id"].(string)
-			if !ok {
-				continue
-			}
+	for _, ws := range states {
+		checked = append(checked, ws.name)
+		loaded = append(loaded, ws.state)
+		log.Printf("[%s] Loaded Terraform state with %d resources", ws.name, len(ws.state.Resources))
 
-			for _, awsResource := range awsSGs {
-				awsID, _ := awsResource["security_group_id"].(string)
-				if awsID == tfID {
-					if driftEvent := comparator.CompareSecurityGroup(tfResource, awsResource); driftEvent != nil {
-						log.Printf("Security Group drift detected for %s", tfID)
-						drifts = append(drifts, driftEvent)
-					}
-					break
-				}
-			}
+		workspaceDrifts := comparator.Detect(ws.state, g)
+		for _, driftEvent := range workspaceDrifts {
+			driftEvent.Workspace = ws.name
+			log.Printf("[%s] %s drift detected for %s (%s)", ws.name, driftEvent.Type, driftEvent.ResourceID, driftEvent.TerraformAddress)
 		}
+		drifts = append(drifts, workspaceDrifts...)
 	}
 
-	// 5. Check S3 Buckets
-	awsS3Buckets, err := s.getAWSS3Buckets(ctx)
-	if err == nil {
-		tfS3Buckets := tfState.GetS3Buckets()
-		log.Printf("Found %d S3 buckets in Terraform state", len(tfS3Buckets))
-
-		for _, tfResource := range tfS3Buckets {
-			if len(tfResource.Instances) == 0 {
-				continue
-			}
-
-			tfBucket, ok := tfResource.Instances[0].Attributes["bucket"].(string)
-			if !ok {
-				continue
-			}
-
-			for _, awsResource := range awsS3Buckets {
-				awsBucket, _ := awsResource["bucket_name"].(string)
-				if awsBucket == tfBucket {
-					if driftEvent := comparator.CompareS3(tfResource, awsResource); driftEvent != nil {
-						log.Printf("S3 drift detected for %s", tfBucket)
-						drifts = append(drifts, driftEvent)
-					}
-					break
-				}
-			}
-		}
+	// 4. Resources outside every state are only reported when all workspaces were loaded
+	if len(states) == len(s.workspaces) {
+		unmanaged := comparator.DetectUnmanaged(loaded, g)
+		log.Printf("Found %d resources not managed by Terraform", len(unmanaged))
+		drifts = append(drifts, unmanaged...)
 	}
 
 	log.Printf("Detected %d drifts", len(drifts))
-	return drifts, nil
-}
-
-// getAWSEC2Instances retrieves EC2 instances from SkyGraph
-func (s *Server) getAWSEC2Instances(ctx context.Context) ([]map[string]interface{}, error) {
-	skyGraphURL := "http://localhost:8001/api/v1/graph"
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(skyGraphURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to SkyGraph: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("SkyGraph returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var graphResp struct {
-		Nodes []struct {
-			ID       string                 `json:"id"`
-			Type     string                 `json:"type"`
-			Metadata map[string]interface{} `json:"metadata"`
-			Tags     map[string]string      `json:"tags"`
-		} `json:"nodes"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&graphResp); err != nil {
-		return nil, fmt.Errorf("failed to decode SkyGraph response: %w", err)
-	}
-
-	// Extract EC2 instances
-	instances := []map[string]interface{}{}
-	for _, node := range graphResp.Nodes {
-		if node.Type == "ec2" {
-			instance := make(map[string]interface{})
-			for k, v := range node.Metadata {
-				instance[k] = v
-			}
-			instance["tags"] = node.Tags
-			instances = append(instances, instance)
-		}
-	}
-
-	return instances, nil
-}
-
-// getAWSSecurityGroups retrieves Security Groups from SkyGraph
-func (s *Server) getAWSSecurityGroups(ctx context.Context) ([]map[string]interface{}, error) {
-	skyGraphURL := "http://localhost:8001/api/v1/graph"
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(skyGraphURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to SkyGraph: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("SkyGraph returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var graphResp struct {
-		Nodes []struct {
-			ID       string                 `json:"id"`
-			Type     string                 `json:"type"`
-			Metadata map[string]interface{} `json:"metadata"`
-			Tags     map[string]string      `json:"tags"`
-		} `json:"nodes"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&graphResp); err != nil {
-		return nil, fmt.Errorf("failed to decode SkyGraph response: %w", err)
-	}
-
-	// Extract Security Groups
-	sgs := []map[string]interface{}{}
-	for _, node := range graphResp.Nodes {
-		if node.Type == "security_group" {
-			sg := make(map[string]interface{})
-			for k, v := range node.Metadata {
-				sg[k] = v
-			}
-			sg["tags"] = node.Tags
-			sgs = append(sgs, sg)
-		}
-	}
-
-	return sgs, nil
-}
-
-// getAWSS3Buckets retrieves S3 buckets from SkyGraph
-func (s *Server) getAWSS3Buckets(ctx context.Context) ([]map[string]interface{}, error) {
-	skyGraphURL := "http://localhost:8001/api/v1/graph"
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(skyGraphURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to SkyGraph: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("SkyGraph returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var graphResp struct {
-		Nodes []struct {
-			ID       string                 `json:"id"`
-			Type     string                 `json:"type"`
-			Metadata map[string]interface{} `json:"metadata"`
-			Tags     map[string]string      `json:"tags"`
-		} `json:"nodes"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&graphResp); err != nil {
-		return nil, fmt.Errorf("failed to decode SkyGraph response: %w", err)
-	}
-
-	// Extract S3 buckets
-	buckets := []map[string]interface{}{}
-	for _, node := range graphResp.Nodes {
-		if node.Type == "s3" {
-			bucket := make(map[string]interface{})
-			for k, v := range node.Metadata {
-				bucket[k] = v
-			}
-			bucket["tags"] = node.Tags
-			buckets = append(buckets, bucket)
-		}
-	}
-
-	return buckets, nil
+	return drifts, checked, nil
 }
 
 // handleDrifts handles drift events list requests
@@ -325,71 +117,240 @@ func (s *Server) handleDrifts() http.HandlerFunc {
 		startTime, _ := parseQueryTime(r, "start_time")
 		endTime, _ := parseQueryTime(r, "end_time")
 
-		// Build filter
-		filter := &clickhouse.DriftEventFilter{
+		list, err := parseListQuery(r, storage.DriftQueryFields)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Build filter; one more event than the limit tells whether there is a next page
+		filter := &storage.DriftEventFilter{
 			StartTime:    startTime,
 			EndTime:      endTime,
 			ResourceType: resourceType,
 			UserIdentity: userIdentity,
-			Limit:        limit,
+			Query:        list.query,
+			Sort:         list.sort,
+			After:        list.after,
+			Limit:        limit + 1,
 		}
 
-		if severity != "" {
+		if severity != "" && !strings.Contains(severity, ",") {
 			filter.Severity = types.Severity(severity)
 		}
 		if driftType != "" {
 			filter.DriftType = types.DriftType(driftType)
 		}
+		if status := parseQueryString(r, "status", ""); status != "" {
+			for _, st := range strings.Split(status, ",") {
+				filter.Statuses = append(filter.Statuses, types.DriftStatus(st))
+			}
+		}
+		if workspace := parseQueryString(r, "workspace", ""); workspace != "" {
+			filter.Workspaces = []string{workspace}
+		}
 
 		// Query drifts
 		var drifts []*types.DriftEvent
-		var err error
 
-		if s.chClient != nil {
+		if s.driftStore != nil {
 			drifts, err = s.driftStore.ListDriftEvents(ctx, filter)
 			if err != nil {
 				respondError(w, http.StatusInternalServerError, "Failed to query drifts: "+err.Error())
 				return
 			}
 		} else {
-			// Perform real-time drift detection when ClickHouse is disabled
-			drifts, err = s.detectDrifts(ctx)
-			if err != nil {
+			// Perform real-time drift detection when storage is disabled
+			if _, err = s.syncDrifts(ctx); err != nil {
 				respondError(w, http.StatusInternalServerError, "Failed to detect drifts: "+err.Error())
 				return
 			}
+			drifts = []*types.DriftEvent{}
+			for _, drift := range s.tracker.Open() {
+				if filter.Match(drift) {
+					drifts = append(drifts, drift)
+				}
+			}
+			drifts = storage.SortDrifts(drifts, filter)
 		}
 
+		drifts, next := nextCursor(drifts, limit, list.sort, storage.DriftSortKey)
+
 		respondJSON(w, http.StatusOK, map[string]interface{}{
-			"drifts": drifts,
-			"count":  len(drifts),
+			"drifts":      drifts,
+			"count":       len(drifts),
+			"next_cursor": next,
 		})
 	}
 }
 
-// handleDriftByID handles single drift event requests
+// handleDriftByID handles single drift event requests:
+//
+//	GET  /api/v1/drifts/{id}
+//	POST /api/v1/drifts/{id}/acknowledge
+//	POST /api/v1/drifts/{id}/resolve
+//	POST /api/v1/drifts/{id}/reopen
+//	GET  /api/v1/drifts/{id}/remediation?strategy=accept|revert
 func (s *Server) handleDriftByID() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract ID (and action) from path
+		path := strings.TrimPrefix(r.URL.Path, "/api/v1/drifts/")
+		id, action, _ := strings.Cut(path, "/")
+		if id == "" || strings.Contains(action, "/") {
+			respondError(w, http.StatusBadRequest, "Invalid drift ID")
+			return
+		}
+
+		if action == "remediation" {
+			s.handleDriftRemediation(w, r, id)
+			return
+		}
+		if action != "" {
+			s.handleDriftStatus(w, r, id, action)
+			return
+		}
+
 		if r.Method != http.MethodGet {
 			respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
 			return
 		}
 
-		// Extract ID from path
-		path := strings.TrimPrefix(r.URL.Path, "/api/v1/drifts/")
-		if path == "" || strings.Contains(path, "/") {
-			respondError(w, http.StatusBadRequest, "Invalid drift ID")
+		event, err := s.getDrift(r.Context(), id)
+		if err != nil {
+			respondDriftNotFound(w, err)
 			return
 		}
 
-		ctx := r.Context()
-		drift, err := s.driftStore.GetDriftEvent(ctx, path)
+		respondJSON(w, http.StatusOK, event)
+	}
+}
+
+// getDrift returns a drift event from the store, or from the in-memory tracker
+// when storage is not configured
+func (s *Server) getDrift(ctx context.Context, id string) (*types.DriftEvent, error) {
+	if s.driftStore == nil {
+		if event := s.tracker.Get(id); event != nil {
+			return event, nil
+		}
+		return nil, drift.ErrDriftNotFound
+	}
+	return s.driftStore.GetDriftEvent(ctx, id)
+}
+
+// respondDriftNotFound sends the error of a failed getDrift
+func respondDriftNotFound(w http.ResponseWriter, err error) {
+	if errors.Is(err, drift.ErrDriftNotFound) {
+		respondError(w, http.StatusNotFound, "Drift not found")
+		return
+	}
+	respondError(w, http.StatusNotFound, "Drift not found: "+err.Error())
+}
+
+// handleDriftRemediation returns the remediation plan of a drift event: a
+// Terraform patch that accepts the drift (strategy=accept, the default) or the
+// values to restore (strategy=revert)
+func (s *Server) handleDriftRemediation(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	strategy, err := remediation.ParseStrategy(r.URL.Query().Get("strategy"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	event, err := s.getDrift(r.Context(), id)
+	if err != nil {
+		respondDriftNotFound(w, err)
+		return
+	}
+
+	plan, err := remediation.Generate(event, strategy)
+	if err != nil {
+		respondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, plan)
+}
+
+// driftStatusActions maps the drift status endpoints to the status they set
+var driftStatusActions = map[string]types.DriftStatus{
+	"acknowledge": types.DriftAcknowledged,
+	"resolve":     types.DriftResolved,
+	"reopen":      types.DriftOpen,
+}
+
+// handleDriftStatus changes the lifecycle status of a drift event
+func (s *Server) handleDriftStatus(w http.ResponseWriter, r *http.Request, id, action string) {
+	status, ok := driftStatusActions[action]
+	if !ok {
+		respondError(w, http.StatusNotFound, "Unknown drift action: "+action)
+		return
+	}
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// The body is optional: {"user": "alice"}
+	var req struct {
+		User string `json:"user"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			respondError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+			return
+		}
+	}
+
+	// Authenticated users act as themselves
+	if identity := auth.FromContext(r.Context()); identity != nil {
+		req.User = identity.Subject
+	}
+
+	var event *types.DriftEvent
+	var err error
+	if s.driftStore != nil {
+		event, err = s.driftStore.UpdateDriftStatus(r.Context(), id, status, req.User)
+	} else {
+		event, err = s.tracker.SetStatus(id, status, req.User)
+	}
+	if err != nil {
+		if errors.Is(err, drift.ErrDriftNotFound) {
+			respondError(w, http.StatusNotFound, "Drift not found")
+			return
+		}
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	log.Printf("Drift %s marked as %s by %q", id, status, req.User)
+	s.publishDrifts(event)
+	respondJSON(w, http.StatusOK, event)
+}
+
+// handleDetectDrifts runs a drift detection cycle on demand (POST /api/v1/drifts/detect)
+func (s *Server) handleDetectDrifts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		changes, err := s.syncDrifts(r.Context())
 		if err != nil {
-			respondError(w, http.StatusNotFound, "Drift not found: "+err.Error())
+			respondError(w, http.StatusInternalServerError, "Failed to detect drifts: "+err.Error())
 			return
 		}
 
-		respondJSON(w, http.StatusOK, drift)
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"created":  changes.Created,
+			"updated":  len(changes.Updated),
+			"resolved": changes.Resolved,
+		})
 	}
 }
 
@@ -404,7 +365,7 @@ func (s *Server) handleDriftStats() http.HandlerFunc {
 		ctx := r.Context()
 		days := parseQueryInt(r, "days", 7)
 
-		// If ClickHouse is not configured, return empty stats
+		// If storage is not configured, return empty stats
 		if s.driftStore == nil {
 			respondJSON(w, http.StatusOK, map[string]interface{}{
 				"stats": map[string]interface{}{
@@ -416,6 +377,8 @@ func (s *Server) handleDriftStats() http.HandlerFunc {
 						"low":      0,
 					},
 					"by_type": map[string]int{},
+					// Suppressed drifts since the server started
+					"suppressed": s.suppressor.Stats(),
 				},
 				"days": days,
 			})
@@ -454,19 +417,38 @@ func (s *Server) handleImpactAnalysis() http.HandlerFunc {
 		startTime, _ := parseQueryTime(r, "start_time")
 		endTime, _ := parseQueryTime(r, "end_time")
 
-		// Build filter
-		filter := &clickhouse.ImpactAnalysisFilter{
+		list, err := parseListQuery(r, storage.ImpactQueryFields)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Build filter; one more result than the limit tells whether there is a next page
+		filter := &storage.ImpactAnalysisFilter{
 			StartTime:            startTime,
 			EndTime:              endTime,
 			MinBlastRadius:       minBlastRadius,
 			MinAffectedResources: minAffectedResources,
-			Limit:                limit,
+			Query:                list.query,
+			Sort:                 list.sort,
+			After:                list.after,
+			Limit:                limit + 1,
 		}
 
-		if severity != "" {
+		if severity != "" && !strings.Contains(severity, ",") {
 			filter.Severity = types.Severity(severity)
 		}
 
+		// If storage is not configured, there is no impact analysis
+		if s.impactStore == nil {
+			respondJSON(w, http.StatusOK, map[string]interface{}{
+				"results":     []*types.ImpactAnalysisResult{},
+				"count":       0,
+				"next_cursor": "",
+			})
+			return
+		}
+
 		// Query impact analysis
 		results, err := s.impactStore.ListImpactAnalysis(ctx, filter)
 		if err != nil {
@@ -474,9 +456,12 @@ func (s *Server) handleImpactAnalysis() http.HandlerFunc {
 			return
 		}
 
+		results, next := nextCursor(results, limit, list.sort, storage.ImpactSortKey)
+
 		respondJSON(w, http.StatusOK, map[string]interface{}{
-			"results": results,
-			"count":   len(results),
+			"results":     results,
+			"count":       len(results),
+			"next_cursor": next,
 		})
 	}
 }
@@ -496,8 +481,17 @@ func (s *Server) handleImpactByDriftID() http.HandlerFunc {
 			return
 		}
 
+		if s.impactStore == nil {
+			respondError(w, http.StatusNotFound, "Impact analysis not found")
+			return
+		}
+
 		ctx := r.Context()
 		result, err := s.impactStore.GetImpactAnalysis(ctx, path)
+		if errors.Is(err, storage.ErrImpactNotFound) {
+			respondError(w, http.StatusNotFound, "Impact analysis not found")
+			return
+		}
 		if err != nil {
 			respondError(w, http.StatusNotFound, "Impact analysis not found: "+err.Error())
 			return
@@ -518,7 +512,7 @@ func (s *Server) handleImpactStats() http.HandlerFunc {
 		ctx := r.Context()
 		days := parseQueryInt(r, "days", 7)
 
-		// If ClickHouse is not configured, return empty stats
+		// If storage is not configured, return empty stats
 		if s.impactStore == nil {
 			respondJSON(w, http.StatusOK, map[string]interface{}{
 				"stats": map[string]interface{}{
@@ -557,6 +551,16 @@ func (s *Server) handleHighImpactDrifts() http.HandlerFunc {
 		days := parseQueryInt(r, "days", 7)
 		limit := parseQueryInt(r, "limit", 50)
 
+		// If storage is not configured, there are no analyzed drifts
+		if s.impactStore == nil {
+			respondJSON(w, http.StatusOK, map[string]interface{}{
+				"drifts": []*storage.HighImpactDrift{},
+				"count":  0,
+				"days":   days,
+			})
+			return
+		}
+
 		drifts, err := s.impactStore.GetHighImpactDrifts(ctx, days, limit)
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, "Failed to get high impact drifts: "+err.Error())
@@ -571,6 +575,49 @@ func (s *Server) handleHighImpactDrifts() http.HandlerFunc {
 	}
 }
 
+// maxPlanSize limits the size of a plan posted for simulation
+const maxPlanSize = 32 << 20
+
+// handleImpactSimulate analyzes the impact of a Terraform plan before it is applied.
+// The body is the output of `terraform show -json <planfile>`; each planned change is
+// analyzed against the current SkyGraph graph as if it were drift.
+func (s *Server) handleImpactSimulate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		plan, err := terraform.ParsePlan(http.MaxBytesReader(w, r.Body, maxPlanSize))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid plan: "+err.Error())
+			return
+		}
+
+		g, err := s.getSkyGraph(r.Context())
+		if err != nil {
+			log.Printf("Failed to get graph from SkyGraph: %v", err)
+			respondError(w, http.StatusInternalServerError, "Failed to load resource graph: "+err.Error())
+			return
+		}
+
+		analyzer := impact.NewAnalyzer(g)
+		analyzer.SetSeverityEngine(s.severity)
+		analyzer.SetMaxDepth(parseQueryInt(r, "max_depth", impact.DefaultMaxDepth))
+
+		report, err := analyzer.Simulate(plan)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to simulate plan: "+err.Error())
+			return
+		}
+
+		log.Printf("Simulated %d planned changes: %s risk, %d affected resources",
+			report.ChangeCount, report.Severity, report.AffectedResourceCount)
+
+		respondJSON(w, http.StatusOK, report)
+	}
+}
+
 // handleCreateDrift handles drift event creation (for testing/manual input)
 func (s *Server) handleCreateDrift() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -579,21 +626,89 @@ func (s *Server) handleCreateDrift() http.HandlerFunc {
 			return
 		}
 
+		if s.driftStore == nil {
+			respondError(w, http.StatusServiceUnavailable, "Storage is not configured")
+			return
+		}
+
 		var event types.DriftEvent
 		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
 			respondError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
 			return
 		}
 
+		// Pushed events get the same content-derived ID as detected ones, so
+		// pushing the same drift again doesn't duplicate it
+		if event.ID == "" {
+			event.ID = types.DriftEventID(&event)
+		}
+
 		ctx := r.Context()
-		if err := s.driftStore.SaveDriftEvent(ctx, &event); err != nil {
+
+		// Pushed events go through the same suppression policy as detected ones
+		kept := s.suppressor.Apply(&event)
+		if kept == nil {
+			if err := s.driftStore.SaveSuppressions(ctx, s.suppressor.Drain()); err != nil {
+				respondError(w, http.StatusInternalServerError, "Failed to record suppressed drift event: "+err.Error())
+				return
+			}
+			respondJSON(w, http.StatusOK, map[string]interface{}{
+				"message": "Drift event suppressed by policy",
+				"id":      event.ID,
+			})
+			return
+		}
+
+		// Score events pushed without a severity with the same rules as detected ones
+		if kept.Severity == "" {
+			s.severity.Apply(kept)
+		}
+
+		// Give pushed events the same lifecycle as detected ones
+		seenAt := kept.Timestamp
+		if seenAt.IsZero() {
+			seenAt = time.Now()
+		}
+		if kept.Status == "" {
+			kept.Status = types.DriftOpen
+		}
+		if kept.FirstSeen.IsZero() {
+			kept.FirstSeen = seenAt
+		}
+		if kept.LastSeen.IsZero() {
+			kept.LastSeen = seenAt
+		}
+
+		existing, err := s.driftStore.GetDriftEvent(ctx, kept.ID)
+		if err != nil && !errors.Is(err, drift.ErrDriftNotFound) {
+			respondError(w, http.StatusInternalServerError, "Failed to load drift event: "+err.Error())
+			return
+		}
+
+		// An open drift only gets a new last_seen; a new or resolved one is (re)opened
+		var changes drift.Changes
+		if existing != nil && existing.Status != types.DriftResolved {
+			changes = drift.Reconcile([]*types.DriftEvent{existing}, []*types.DriftEvent{kept}, kept.LastSeen)
+		} else {
+			changes.Created = []*types.DriftEvent{kept}
+		}
+
+		if err := s.driftStore.RecordDriftChanges(ctx, changes); err != nil {
 			respondError(w, http.StatusInternalServerError, "Failed to save drift event: "+err.Error())
 			return
 		}
+		s.publishDrifts(changes.Created...)
 
+		if existing != nil {
+			respondJSON(w, http.StatusOK, map[string]interface{}{
+				"message": "Drift event updated",
+				"id":      existing.ID,
+			})
+			return
+		}
 		respondJSON(w, http.StatusCreated, map[string]interface{}{
 			"message": "Drift event created",
-			"id":      event.ID,
+			"id":      kept.ID,
 		})
 	}
 }
@@ -606,6 +721,11 @@ func (s *Server) handleCreateImpact() http.HandlerFunc {
 			return
 		}
 
+		if s.impactStore == nil {
+			respondError(w, http.StatusServiceUnavailable, "Storage is not configured")
+			return
+		}
+
 		var result types.ImpactAnalysisResult
 		if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
 			respondError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
@@ -617,6 +737,7 @@ func (s *Server) handleCreateImpact() http.HandlerFunc {
 			respondError(w, http.StatusInternalServerError, "Failed to save impact analysis: "+err.Error())
 			return
 		}
+		s.publishImpact(ctx, &result)
 
 		respondJSON(w, http.StatusCreated, map[string]interface{}{
 			"message":       "Impact analysis created",
@@ -672,12 +793,15 @@ func (s *Server) handleGraph() http.HandlerFunc {
 	}
 }
 
-// getGraphFromSkyGraph fetches the resource graph from SkyGraph service
-func (s *Server) getGraphFromSkyGraph(ctx context.Context) (*ResourceGraph, error) {
-	skyGraphURL := "http://localhost:8001/api/v1/graph"
+// getSkyGraph fetches the current resource graph from SkyGraph
+func (s *Server) getSkyGraph(ctx context.Context) (*graph.Graph, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.skyGraphURL+"/api/v1/graph", nil)
+	if err != nil {
+		return nil, err
+	}
 
 	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(skyGraphURL)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to SkyGraph: %w", err)
 	}
@@ -688,35 +812,27 @@ func (s *Server) getGraphFromSkyGraph(ctx context.Context) (*ResourceGraph, erro
 		return nil, fmt.Errorf("SkyGraph returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	var skyGraphResp struct {
-		Nodes []struct {
-			ID       string                 `json:"id"`
-			Type     string                 `json:"type"`
-			Provider string                 `json:"provider"`
-			Region   string                 `json:"region"`
-			Name     string                 `json:"name"`
-			Metadata map[string]interface{} `json:"metadata"`
-			Tags     map[string]string      `json:"tags"`
-		} `json:"nodes"`
-		Edges []struct {
-			From     string                 `json:"from"`
-			To       string                 `json:"to"`
-			Type     string                 `json:"type"`
-			Metadata map[string]interface{} `json:"metadata,omitempty"`
-		} `json:"edges"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&skyGraphResp); err != nil {
+	var g graph.Graph
+	if err := json.NewDecoder(resp.Body).Decode(&g); err != nil {
 		return nil, fmt.Errorf("failed to decode SkyGraph response: %w", err)
 	}
+	return &g, nil
+}
+
+// getGraphFromSkyGraph fetches the resource graph from SkyGraph service
+func (s *Server) getGraphFromSkyGraph(ctx context.Context) (*ResourceGraph, error) {
+	g, err := s.getSkyGraph(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	// Convert SkyGraph response to ResourceGraph format
 	graph := &ResourceGraph{
-		Nodes: make([]GraphNode, 0, len(skyGraphResp.Nodes)),
-		Edges: make([]GraphEdge, 0, len(skyGraphResp.Edges)),
+		Nodes: make([]GraphNode, 0, len(g.Nodes)),
+		Edges: make([]GraphEdge, 0, len(g.Edges)),
 	}
 
-	for _, node := range skyGraphResp.Nodes {
+	for _, node := range g.Nodes {
 		graph.Nodes = append(graph.Nodes, GraphNode{
 			ID:       node.ID,
 			Type:     node.Type,
@@ -728,7 +844,7 @@ func (s *Server) getGraphFromSkyGraph(ctx context.Context) (*ResourceGraph, erro
 		})
 	}
 
-	for _, edge := range skyGraphResp.Edges {
+	for _, edge := range g.Edges {
 		graph.Edges = append(graph.Edges, GraphEdge{
 			From:     edge.From,
 			To:       edge.To,
@@ -756,56 +872,15 @@ func (s *Server) handleResources() http.HandlerFunc {
 		limit := parseQueryInt(r, "limit", 100)
 
 		// Query SkyGraph for resources
-		skyGraphURL := "http://localhost:8001/api/v1/graph"
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		req, err := http.NewRequestWithContext(ctx, "GET", skyGraphURL, nil)
-		if err != nil {
-			respondError(w, http.StatusInternalServerError, "Failed to create request: "+err.Error())
-			return
-		}
-
-		client := &http.Client{Timeout: 10 * time.Second}
-		resp, err := client.Do(req)
+		g, err := s.getSkyGraph(r.Context())
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, "Failed to query SkyGraph: "+err.Error())
 			return
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			respondError(w, http.StatusInternalServerError, fmt.Sprintf("SkyGraph returned status %d: %s", resp.StatusCode, string(body)))
-			return
-		}
-
-		// Parse SkyGraph response
-		type SkyGraphNode struct {
-			ID        string                 `json:"id"`
-			Type      string                 `json:"type"`
-			Provider  string                 `json:"provider"`
-			Region    string                 `json:"region"`
-			Name      string                 `json:"name"`
-			Metadata  map[string]interface{} `json:"metadata"`
-			Tags      map[string]string      `json:"tags"`
-			CreatedAt string                 `json:"created_at"`
-			UpdatedAt string                 `json:"updated_at"`
-		}
-
-		type SkyGraphResponse struct {
-			Nodes []SkyGraphNode `json:"nodes"`
-		}
-
-		var skyGraphResp SkyGraphResponse
-		if err := json.NewDecoder(resp.Body).Decode(&skyGraphResp); err != nil {
-			respondError(w, http.StatusInternalServerError, "Failed to parse SkyGraph response: "+err.Error())
-			return
-		}
 
 		// Filter resources based on query parameters
-		filtered := make([]SkyGraphNode, 0)
-		for _, node := range skyGraphResp.Nodes {
+		filtered := make([]graph.ResourceNode, 0)
+		for _, node := range g.Nodes {
 			if resourceType != "" && node.Type != resourceType {
 				continue
 			}
@@ -824,7 +899,7 @@ func (s *Server) handleResources() http.HandlerFunc {
 		respondJSON(w, http.StatusOK, map[string]interface{}{
 			"resources": filtered,
 			"count":     len(filtered),
-			"total":     len(skyGraphResp.Nodes),
+			"total":     len(g.Nodes),
 		})
 	}
 }
@@ -837,34 +912,28 @@ func (s *Server) handleIntendedGraph() http.HandlerFunc {
 			return
 		}
 
-		// Load Terraform state
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			respondError(w, http.StatusInternalServerError, "Failed to get home directory")
+		if len(s.workspaces) == 0 {
+			respondError(w, http.StatusNotFound, "No Terraform workspaces configured")
 			return
 		}
 
-		statePath := filepath.Join(homeDir, "tfdrift-falco", "examples", "terraform", "terraform.tfstate")
-		
-		// Check if comprehensive test state exists
-		comprehensivePath := filepath.Join(homeDir, "tfdrift-falco", "examples", "comprehensive-test", "terraform.tfstate")
-		if _, err := os.Stat(comprehensivePath); err == nil {
-			statePath = comprehensivePath
-		}
-
-		tfReader := terraform.NewStateReader(statePath)
-		tfState, err := tfReader.Load()
-		if err != nil {
-			log.Printf("Failed to load Terraform state from %s: %v", statePath, err)
-			respondError(w, http.StatusInternalServerError, "Failed to load Terraform state: "+err.Error())
+		// Load Terraform state (all workspaces unless ?workspace= is given)
+		workspace := parseQueryString(r, "workspace", "")
+		states := s.loadStates(r.Context(), workspace)
+		if len(states) == 0 {
+			if workspace != "" && !s.hasWorkspace(workspace) {
+				respondError(w, http.StatusNotFound, "Unknown workspace: "+workspace)
+				return
+			}
+			respondError(w, http.StatusInternalServerError, "Failed to load Terraform state")
 			return
 		}
 
 		// Generate diagram
-		diagram := tfState.GenerateDiagram()
+		diagram := mergeDiagrams(states)
 
-		log.Printf("Generated intended diagram with %d nodes and %d edges from Terraform state", 
-			len(diagram.Nodes), len(diagram.Edges))
+		log.Printf("Generated intended diagram with %d nodes and %d edges from %d Terraform workspaces",
+			len(diagram.Nodes), len(diagram.Edges), len(states))
 
 		respondJSON(w, http.StatusOK, diagram)
 	}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/drift"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// syncDrifts runs one detection cycle and records the lifecycle changes:
+// new drifts are opened, persisting drifts get a new last_seen and drifts that
+// are no longer detected are resolved. Only drifts of the workspaces whose
+// state could be loaded are resolved, and drifts of resources outside every
+// workspace only when all of them could be loaded.
+func (s *Server) syncDrifts(ctx context.Context) (changes drift.Changes, err error) {
+	// Notify even when detection fails or checks nothing, so digests whose
+	// window has passed are still sent
+	defer func() { s.notify(ctx, changes.Created) }()
+
+	started := time.Now()
+	detected, checked, err := s.detectDrifts(ctx)
+	if err != nil {
+		return drift.Changes{}, err
+	}
+
+	// Suppressed drifts are kept for auditing, not tracked. Once every
+	// workspace was checked, drifts not suppressed again are gone and no
+	// longer counted, and drifts of unmanaged resources can be resolved too.
+	suppressed := s.suppressor.Drain()
+	if len(suppressed) > 0 {
+		log.Printf("Drift detection: %d drifts suppressed by policy", len(suppressed))
+	}
+	scope := checked
+	if len(checked) > 0 && len(checked) == len(s.workspaces) {
+		s.suppressor.Prune(started)
+		scope = append(append([]string{}, checked...), drift.UnmanagedWorkspace)
+	}
+
+	if s.driftStore == nil {
+		s.annotateRootCauses(ctx, detected, func(id string) bool { return s.tracker.Get(id) != nil })
+		changes = s.tracker.Observe(detected, scope)
+		s.publishChanges(changes)
+		return changes, nil
+	}
+
+	if len(checked) == 0 {
+		// Nothing was checked, so nothing can be resolved
+		return drift.Changes{}, nil
+	}
+
+	if err := s.driftStore.SaveSuppressions(ctx, suppressed); err != nil {
+		log.Printf("Warning: Failed to record suppressed drifts: %v", err)
+	}
+
+	known, err := s.driftStore.ListUnresolvedDriftEvents(ctx, scope)
+	if err != nil {
+		return drift.Changes{}, fmt.Errorf("failed to load unresolved drifts: %w", err)
+	}
+
+	knownIDs := make(map[string]bool, len(known))
+	for _, event := range known {
+		knownIDs[event.ID] = true
+	}
+	s.annotateRootCauses(ctx, detected, func(id string) bool { return knownIDs[id] })
+
+	changes = drift.Reconcile(known, detected, time.Now())
+	if err := s.driftStore.RecordDriftChanges(ctx, changes); err != nil {
+		return drift.Changes{}, fmt.Errorf("failed to record drifts: %w", err)
+	}
+
+	log.Printf("Drift detection: %d new, %d persisting, %d resolved",
+		len(changes.Created), len(changes.Updated), len(changes.Resolved))
+	s.publishChanges(changes)
+	return changes, nil
+}
+
+// publishChanges streams the drifts opened and resolved in a detection cycle.
+// Persisting drifts only get a new last_seen and are not streamed.
+func (s *Server) publishChanges(changes drift.Changes) {
+	s.publishDrifts(changes.Created...)
+	s.publishDrifts(changes.Resolved...)
+}
+
+// notify sends new drifts to the notifier in the background so detection
+// requests don't wait for retries. It runs after every cycle, even without new
+// drifts or checked workspaces, so digests whose window has passed are sent.
+func (s *Server) notify(ctx context.Context, created []*types.DriftEvent) {
+	if s.notifier == nil {
+		return
+	}
+
+	s.notifications.Add(1)
+	go func() {
+		defer s.notifications.Done()
+		if err := s.notifier.Notify(context.WithoutCancel(ctx), created); err != nil {
+			log.Printf("Warning: Failed to send drift notifications: %v", err)
+		}
+	}()
+}
+
+// annotateRootCauses correlates new drifts with CloudTrail. Known drifts keep
+// the root cause found when they were first detected, so CloudTrail is only
+// searched for the drifts opened in this cycle.
+func (s *Server) annotateRootCauses(ctx context.Context, detected []*types.DriftEvent, known func(id string) bool) {
+	if s.correlator == nil {
+		return
+	}
+
+	created := make([]*types.DriftEvent, 0, len(detected))
+	for _, event := range detected {
+		if !known(event.ID) {
+			created = append(created, event)
+		}
+	}
+	if err := s.correlator.Annotate(ctx, created); err != nil {
+		log.Printf("Warning: Failed to correlate drifts with CloudTrail: %v", err)
+	}
+}
+
+// runDetectionLoop runs syncDrifts every interval until ctx is cancelled
+func (s *Server) runDetectionLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.syncDrifts(ctx); err != nil {
+				log.Printf("Warning: Drift detection failed: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/overlay"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// overlayFormats maps the format parameter of the overlay endpoint to the
+// content type and file extension of the response
+var overlayFormats = map[string]struct {
+	contentType string
+	extension   string
+}{
+	"json":    {contentType: "application/json", extension: "json"},
+	"mermaid": {contentType: "text/plain; charset=utf-8", extension: "mmd"},
+}
+
+// handleGraphOverlay returns the intended architecture (Terraform state of all
+// workspaces) laid over the live SkyGraph, every node and edge marked in_sync,
+// drifted, unmanaged or missing and linked to its unresolved drift events:
+//
+//	GET /api/v1/graph/overlay?format=json|mermaid&download=true
+//
+// download=true returns the overlay as a file attachment.
+func (s *Server) handleGraphOverlay() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		format := parseQueryString(r, "format", "json")
+		output, ok := overlayFormats[format]
+		if !ok {
+			respondError(w, http.StatusBadRequest, "Unknown format: "+format+" (available: json, mermaid)")
+			return
+		}
+		download, _ := strconv.ParseBool(parseQueryString(r, "download", "false"))
+
+		if len(s.workspaces) == 0 {
+			respondError(w, http.StatusNotFound, "No Terraform workspaces configured")
+			return
+		}
+
+		ctx := r.Context()
+		states := s.loadStates(ctx, "")
+		if len(states) == 0 {
+			respondError(w, http.StatusInternalServerError, "Failed to load Terraform state")
+			return
+		}
+
+		actual, err := s.getSkyGraph(ctx)
+		if err != nil {
+			log.Printf("Failed to get graph from SkyGraph: %v", err)
+			respondError(w, http.StatusInternalServerError, "Failed to load resource graph: "+err.Error())
+			return
+		}
+
+		workspaces := workspaceNames(states)
+		events, err := s.unresolvedDrifts(ctx, workspaces)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to load drifts: "+err.Error())
+			return
+		}
+
+		result := overlay.Build(mergeDiagrams(states), actual, events)
+		result.Workspaces = workspaces
+
+		log.Printf("Generated graph overlay with %d nodes and %d edges from %d Terraform workspaces",
+			len(result.Nodes), len(result.Edges), len(states))
+
+		if download {
+			filename := fmt.Sprintf("deepdrift-overlay-%s.%s", result.GeneratedAt.UTC().Format("20060102T150405Z"), output.extension)
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		}
+
+		if format == "json" {
+			respondJSON(w, http.StatusOK, result)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := result.WriteMermaid(&buf); err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to render overlay: "+err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", output.contentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+	}
+}
+
+// unresolvedDrifts returns the open and acknowledged drifts of the workspaces.
+// Without storage a detection cycle is run first, as for the drift list.
+func (s *Server) unresolvedDrifts(ctx context.Context, workspaces []string) ([]*types.DriftEvent, error) {
+	if s.driftStore != nil {
+		return s.driftStore.ListUnresolvedDriftEvents(ctx, workspaces)
+	}
+
+	if _, err := s.syncDrifts(ctx); err != nil {
+		return nil, err
+	}
+	return s.tracker.Open(), nil
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage"
+)
+
+// listQuery holds the query language, sort and cursor parameters shared by
+// the drift and impact list endpoints:
+//
+//	q=severity>=high changed:ingress   query (see storage.Query)
+//	sort=newest|oldest|severity        sort order
+//	cursor=...                         next_cursor of the previous page
+type listQuery struct {
+	query *storage.Query
+	sort  storage.SortOrder
+	after *storage.Cursor
+}
+
+// parseListQuery parses the list parameters for the query fields. A severity
+// parameter with several comma-separated values is added to the query.
+func parseListQuery(r *http.Request, fields map[string]storage.QueryField) (*listQuery, error) {
+	q := r.URL.Query().Get("q")
+	if severity := r.URL.Query().Get("severity"); strings.Contains(severity, ",") {
+		q += " severity:" + severity
+	}
+
+	query, err := storage.ParseQuery(q, fields)
+	if err != nil {
+		return nil, err
+	}
+	order, err := storage.ParseSortOrder(r.URL.Query().Get("sort"))
+	if err != nil {
+		return nil, err
+	}
+
+	list := &listQuery{query: query, sort: order}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		if list.after, err = storage.ParseCursor(cursor, order); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// nextCursor trims a page fetched with limit+1 items to limit and returns the
+// cursor of the next page, or "" for the last page
+func nextCursor[T any](items []T, limit int, order storage.SortOrder, key func(T) storage.SortKey) ([]T, string) {
+	if len(items) <= limit {
+		return items, ""
+	}
+	items = items[:limit]
+	cursor := &storage.Cursor{Sort: order, SortKey: key(items[limit-1])}
+	return items, cursor.String()
+}
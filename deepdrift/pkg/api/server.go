@@ -2,23 +2,56 @@ package api
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/higakikeita/airdig/deepdrift/pkg/storage/clickhouse"
+	"github.com/higakikeita/airdig/deepdrift/pkg/auth"
+	"github.com/higakikeita/airdig/deepdrift/pkg/cloudtrail"
+	"github.com/higakikeita/airdig/deepdrift/pkg/drift"
+	"github.com/higakikeita/airdig/deepdrift/pkg/notify"
+	"github.com/higakikeita/airdig/deepdrift/pkg/severity"
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage"
+	"github.com/higakikeita/airdig/deepdrift/pkg/stream"
+	"github.com/higakikeita/airdig/deepdrift/pkg/terraform/backend"
 )
 
+// DefaultSkyGraphURL is the SkyGraph API the server reads the resource graph from
+const DefaultSkyGraphURL = "http://localhost:8001"
+
 // Server represents the REST API server
 type Server struct {
 	addr        string
-	driftStore  *clickhouse.DriftStore
-	impactStore *clickhouse.ImpactStore
-	chClient    *clickhouse.Client
+	driftStore  storage.DriftStore
+	impactStore storage.ImpactStore
+	storage     storage.Backend
 	mux         *http.ServeMux
 	server      *http.Server
+	workspaces  []stateWorkspace
+	tracker     *drift.Tracker
+	suppressor  *drift.Suppressor
+	severity    *severity.Engine
+	correlator  *cloudtrail.Correlator
+	notifier    *notify.Notifier
+	auth        *auth.Authenticator
+	tlsCert     string
+	tlsKey      string
+	skyGraphURL string
+
+	// broker pushes saved drift events and impact analysis to stream clients
+	broker         *stream.Broker
+	allowedOrigins []string
+
+	detectInterval time.Duration
+	stopDetection  context.CancelFunc
+
+	// notifications tracks deliveries still running in the background
+	notifications sync.WaitGroup
 }
 
 // Config holds server configuration
@@ -29,6 +62,37 @@ type Config struct {
 	ClickHouseDB    string
 	EnableCORS      bool
 	AllowedOrigins  []string
+
+	// SkyGraphURL is the base URL of the SkyGraph API (empty uses DefaultSkyGraphURL)
+	SkyGraphURL string
+
+	// Workspaces are the Terraform workspaces compared with SkyGraph
+	Workspaces []backend.Workspace
+
+	// DetectInterval runs drift detection periodically and records the drift
+	// lifecycle (0 disables it; detection can still be triggered via the API)
+	DetectInterval time.Duration
+
+	// Suppressions hides drifts matching the policy (nil suppresses nothing).
+	// Suppressed drifts are still recorded and counted in the drift stats.
+	Suppressions *drift.Policy
+
+	// SeverityRules scores detected drifts (nil uses the default rules).
+	SeverityRules *severity.Engine
+
+	// CloudTrail finds the root cause of new drifts that have none (nil disables it)
+	CloudTrail *cloudtrail.Correlator
+
+	// Notifier sends new drifts to Slack, webhooks and email (nil disables it)
+	Notifier *notify.Notifier
+
+	// Auth authenticates requests and checks their role. Without it the API
+	// is open, except that drift events and impact analysis can't be created.
+	Auth *auth.Authenticator
+
+	// TLSCert and TLSKey serve the API over HTTPS (required for mTLS)
+	TLSCert string
+	TLSKey  string
 }
 
 // DefaultConfig returns default server configuration
@@ -40,29 +104,51 @@ func DefaultConfig() *Config {
 		ClickHouseDB:   "deepdrift",
 		EnableCORS:     true,
 		AllowedOrigins: []string{"*"},
+		SkyGraphURL:    DefaultSkyGraphURL,
 	}
 }
 
-// NewServer creates a new API server
-func NewServer(config *Config, chClient *clickhouse.Client) *Server {
+// NewServer creates a new API server.
+// Without a storage backend drifts are only tracked in memory and the
+// endpoints that need stored data return empty results.
+func NewServer(config *Config, backend storage.Backend) *Server {
 	if config == nil {
 		config = DefaultConfig()
 	}
 
-	// Only create stores if ClickHouse client is provided
-	var driftStore *clickhouse.DriftStore
-	var impactStore *clickhouse.ImpactStore
-	if chClient != nil {
-		driftStore = clickhouse.NewDriftStore(chClient)
-		impactStore = clickhouse.NewImpactStore(chClient)
+	// Only create stores if a storage backend is provided
+	var driftStore storage.DriftStore
+	var impactStore storage.ImpactStore
+	if backend != nil {
+		driftStore = backend.DriftStore()
+		impactStore = backend.ImpactStore()
 	}
 
 	s := &Server{
 		addr:        fmt.Sprintf("%s:%d", config.Host, config.Port),
 		driftStore:  driftStore,
 		impactStore: impactStore,
-		chClient:    chClient,
+		storage:     backend,
 		mux:         http.NewServeMux(),
+		workspaces:  newStateWorkspaces(config.Workspaces),
+		tracker:     drift.NewTracker(),
+		suppressor:  drift.NewSuppressor(config.Suppressions),
+		severity:    config.SeverityRules,
+		correlator:  config.CloudTrail,
+		notifier:    config.Notifier,
+		auth:        config.Auth,
+		tlsCert:     config.TLSCert,
+		tlsKey:      config.TLSKey,
+		skyGraphURL: strings.TrimSuffix(config.SkyGraphURL, "/"),
+
+		broker:         stream.NewBroker(stream.DefaultHistory, stream.DefaultBuffer),
+		allowedOrigins: config.AllowedOrigins,
+
+		detectInterval: config.DetectInterval,
+	}
+
+	if s.skyGraphURL == "" {
+		s.skyGraphURL = DefaultSkyGraphURL
 	}
 
 	// Register routes
@@ -89,16 +175,22 @@ func (s *Server) registerRoutes(config *Config) {
 	s.mux.HandleFunc("/api/v1/drifts", s.handleDrifts())
 	s.mux.HandleFunc("/api/v1/drifts/", s.handleDriftByID())
 	s.mux.HandleFunc("/api/v1/drifts/stats", s.handleDriftStats())
+	s.mux.HandleFunc("/api/v1/drifts/detect", s.handleDetectDrifts())
 
 	// Impact analysis
 	s.mux.HandleFunc("/api/v1/impact", s.handleImpactAnalysis())
 	s.mux.HandleFunc("/api/v1/impact/", s.handleImpactByDriftID())
 	s.mux.HandleFunc("/api/v1/impact/stats", s.handleImpactStats())
 	s.mux.HandleFunc("/api/v1/impact/high", s.handleHighImpactDrifts())
+	s.mux.HandleFunc("/api/v1/impact/simulate", s.handleImpactSimulate())
+
+	// Real-time events (Server-Sent Events or WebSocket)
+	s.mux.HandleFunc("/api/v1/stream", s.handleStream())
 
 	// Resource graph
 	s.mux.HandleFunc("/api/v1/graph", s.handleGraph())
 	s.mux.HandleFunc("/api/v1/graph/intended", s.handleIntendedGraph())
+	s.mux.HandleFunc("/api/v1/graph/overlay", s.handleGraphOverlay())
 
 	// Resources
 	s.mux.HandleFunc("/api/v1/resources", s.handleResources())
@@ -107,6 +199,19 @@ func (s *Server) registerRoutes(config *Config) {
 	fs := http.FileServer(http.Dir("./ui/dist"))
 	s.mux.Handle("/ui/", http.StripPrefix("/ui/", fs))
 
+	// Create drift events and impact analysis (for testing/manual input).
+	// They store whatever they are sent, so they are only served to admins
+	// and not registered at all without authentication.
+	if s.auth != nil {
+		s.mux.HandleFunc("POST /api/v1/drifts", s.handleCreateDrift())
+		s.mux.HandleFunc("POST /api/v1/impact", s.handleCreateImpact())
+	}
+
+	// Authenticate requests (CORS preflights are answered before this)
+	protected := queryToken(s.auth.Middleware(requiredRole, s.mux))
+	s.mux = http.NewServeMux()
+	s.mux.Handle("/", protected)
+
 	// Apply CORS middleware if enabled
 	if config.EnableCORS {
 		s.applyCORSMiddleware(config.AllowedOrigins)
@@ -115,13 +220,65 @@ func (s *Server) registerRoutes(config *Config) {
 
 // Start starts the HTTP server
 func (s *Server) Start() error {
+	if s.tlsCert == "" && s.auth.ClientCAs() != nil {
+		return fmt.Errorf("mTLS authentication requires a TLS certificate")
+	}
+
 	log.Printf("Starting DeepDrift API server on %s", s.addr)
-	return s.server.ListenAndServe()
+
+	if s.detectInterval > 0 && len(s.workspaces) > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stopDetection = cancel
+		log.Printf("Running drift detection every %s", s.detectInterval)
+		go s.runDetectionLoop(ctx, s.detectInterval)
+	}
+
+	if s.tlsCert == "" {
+		return s.server.ListenAndServe()
+	}
+
+	s.server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	if pool := s.auth.ClientCAs(); pool != nil {
+		// Clients without a certificate can still use a bearer token
+		s.server.TLSConfig.ClientCAs = pool
+		s.server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return s.server.ListenAndServeTLS(s.tlsCert, s.tlsKey)
+}
+
+// Broker returns the event broker of the stream endpoint. Drift events saved
+// outside the server can be published to it, e.g. by passing
+// Broker().PublishDrifts as the callback of TFDriftAdapter.WatchDrift.
+func (s *Server) Broker() *stream.Broker {
+	return s.broker
 }
 
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
 	log.Println("Shutting down DeepDrift API server...")
+	if s.stopDetection != nil {
+		s.stopDetection()
+	}
+
+	// End the event streams, which would otherwise keep the server running
+	s.broker.Close()
+
+	// Send the drifts still waiting for their digest window, unless the
+	// running deliveries don't finish before ctx is done
+	delivered := make(chan struct{})
+	go func() {
+		s.notifications.Wait()
+		close(delivered)
+	}()
+	select {
+	case <-delivered:
+		if err := s.notifier.Flush(ctx); err != nil {
+			log.Printf("Warning: Failed to send drift notifications: %v", err)
+		}
+	case <-ctx.Done():
+		log.Printf("Warning: Drift notifications still pending at shutdown: %v", ctx.Err())
+	}
+
 	return s.server.Shutdown(ctx)
 }
 
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/severity"
+	"github.com/higakikeita/airdig/deepdrift/pkg/stream"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+const (
+	// streamHeartbeat keeps idle streams open through proxies and detects
+	// clients that went away
+	streamHeartbeat = 15 * time.Second
+
+	// streamRetry is how long SSE clients wait before reconnecting
+	streamRetry = 3 * time.Second
+)
+
+// handleStream pushes drift events and impact analysis as they are saved
+// (GET /api/v1/stream). It serves Server-Sent Events, or a WebSocket when the
+// request asks for an upgrade.
+//
+// Query parameters filter the events: type, severity and resource_type take
+// comma-separated values, resource_id_prefix a prefix of the resource ID.
+// Clients resume after the Last-Event-ID header (sent by EventSource when it
+// reconnects) or the last_event_id parameter.
+func (s *Server) handleStream() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		filter, err := parseStreamFilter(r.URL.Query())
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		lastEventID, resume, err := parseLastEventID(r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if stream.IsWebSocketUpgrade(r) {
+			s.streamWebSocket(w, r, filter, lastEventID, resume)
+			return
+		}
+		s.streamSSE(w, r, filter, lastEventID, resume)
+	}
+}
+
+// streamSSE writes the events as Server-Sent Events
+func (s *Server) streamSSE(w http.ResponseWriter, r *http.Request, filter stream.Filter, lastEventID uint64, resume bool) {
+	rc := http.NewResponseController(w)
+
+	// The server's write timeout would end the stream
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		respondError(w, http.StatusInternalServerError, "Failed to start stream: "+err.Error())
+		return
+	}
+
+	sub := s.broker.Subscribe(filter, lastEventID, resume)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	if err := rc.Flush(); err != nil {
+		log.Printf("Warning: Streaming is not supported by the connection: %v", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Slow clients reconnect and resume from the last event they received
+				if err := sub.Err(); errors.Is(err, stream.ErrSlowConsumer) {
+					log.Printf("Warning: Closed event stream of %s: %v", r.RemoteAddr, err)
+				}
+				return
+			}
+			if err := writeSSE(w, event); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeSSE writes one event in the text/event-stream format
+func writeSSE(w http.ResponseWriter, event stream.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if event.ID != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", event.ID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// streamWebSocket sends the events as JSON messages over a WebSocket
+func (s *Server) streamWebSocket(w http.ResponseWriter, r *http.Request, filter stream.Filter, lastEventID uint64, resume bool) {
+	// Browsers don't apply CORS to WebSockets, so the origin is checked here
+	if !s.allowsOrigin(r) {
+		respondError(w, http.StatusForbidden, "Origin not allowed")
+		return
+	}
+
+	ws, err := stream.UpgradeWebSocket(w, r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid WebSocket request: "+err.Error())
+		return
+	}
+
+	sub := s.broker.Subscribe(filter, lastEventID, resume)
+	defer sub.Close()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ws.Done():
+			ws.Close(stream.CloseNormal, "")
+			return
+		case <-heartbeat.C:
+			if err := ws.Ping(); err != nil {
+				ws.Close(stream.CloseGoingAway, "")
+				return
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				if err := sub.Err(); errors.Is(err, stream.ErrSlowConsumer) {
+					log.Printf("Warning: Closed event stream of %s: %v", r.RemoteAddr, err)
+					ws.Close(stream.CloseTryAgainLater, "resume from the last event id")
+					return
+				}
+				ws.Close(stream.CloseGoingAway, "server shutting down")
+				return
+			}
+			if err := ws.WriteJSON(event); err != nil {
+				ws.Close(stream.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+// allowsOrigin checks the Origin of a browser request against the allowed
+// CORS origins (or the server's own host when CORS is disabled)
+func (s *Server) allowsOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range s.allowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// parseStreamFilter parses the event filter of a stream request
+func parseStreamFilter(query url.Values) (stream.Filter, error) {
+	var filter stream.Filter
+	for _, t := range splitQueryList(query.Get("type")) {
+		eventType := stream.EventType(t)
+		if eventType != stream.EventDrift && eventType != stream.EventImpact {
+			return filter, fmt.Errorf("Invalid event type: %s", t)
+		}
+		filter.Types = append(filter.Types, eventType)
+	}
+	for _, sev := range splitQueryList(query.Get("severity")) {
+		if severity.Rank(types.Severity(sev)) < 0 {
+			return filter, fmt.Errorf("Invalid severity: %s", sev)
+		}
+		filter.Severities = append(filter.Severities, types.Severity(sev))
+	}
+	filter.ResourceTypes = splitQueryList(query.Get("resource_type"))
+	filter.ResourceIDPrefix = query.Get("resource_id_prefix")
+	return filter, nil
+}
+
+// parseLastEventID returns the event to resume after, if the client sent one
+func parseLastEventID(r *http.Request) (uint64, bool, error) {
+	val := r.Header.Get("Last-Event-ID")
+	if val == "" {
+		val = r.URL.Query().Get("last_event_id")
+	}
+	if val == "" {
+		return 0, false, nil
+	}
+
+	id, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("Invalid last event ID: %s", val)
+	}
+	return id, true, nil
+}
+
+// splitQueryList splits a comma-separated query parameter
+func splitQueryList(val string) []string {
+	var values []string
+	for _, v := range strings.Split(val, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// publishDrifts sends saved drift events to the stream subscribers
+func (s *Server) publishDrifts(events ...*types.DriftEvent) {
+	s.broker.PublishDrifts(events)
+}
+
+// publishImpact sends saved impact analysis to the stream subscribers. The
+// analysed drift event is looked up so resource filters apply to it.
+func (s *Server) publishImpact(ctx context.Context, result *types.ImpactAnalysisResult) {
+	var event *types.DriftEvent
+	if s.driftStore != nil {
+		var err error
+		if event, err = s.driftStore.GetDriftEvent(ctx, result.DriftEventID); err != nil {
+			event = nil
+		}
+	}
+	s.broker.Publish(stream.ImpactEvent(result, event))
+}
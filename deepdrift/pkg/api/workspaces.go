@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/overlay"
+	"github.com/higakikeita/airdig/deepdrift/pkg/terraform"
+	"github.com/higakikeita/airdig/deepdrift/pkg/terraform/backend"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+// stateWorkspace is a configured Terraform workspace and where its state is read from
+type stateWorkspace struct {
+	name   string
+	source backend.Source
+}
+
+// workspaceState is the state loaded from a workspace
+type workspaceState struct {
+	name  string
+	state *terraform.State
+}
+
+// newStateWorkspaces creates the state sources of the configured workspaces.
+// Workspaces with an invalid backend configuration are logged and skipped.
+func newStateWorkspaces(workspaces []backend.Workspace) []stateWorkspace {
+	result := make([]stateWorkspace, 0, len(workspaces))
+	for _, ws := range workspaces {
+		source, err := backend.NewSource(ws)
+		if err != nil {
+			log.Printf("Warning: Skipping Terraform workspace %s: %v", ws.Name, err)
+			continue
+		}
+		result = append(result, stateWorkspace{name: ws.Name, source: source})
+	}
+	return result
+}
+
+// hasWorkspace reports whether a workspace with the given name is configured
+func (s *Server) hasWorkspace(name string) bool {
+	for _, ws := range s.workspaces {
+		if ws.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// loadStates loads the Terraform state of every workspace (or only the named one).
+// Workspaces whose state cannot be read are logged and skipped so that one
+// unreachable backend does not hide drift in the others.
+func (s *Server) loadStates(ctx context.Context, name string) []workspaceState {
+	states := make([]workspaceState, 0, len(s.workspaces))
+	for _, ws := range s.workspaces {
+		if name != "" && ws.name != name {
+			continue
+		}
+
+		state, err := backend.Load(ctx, ws.source)
+		if err != nil {
+			if errors.Is(err, backend.ErrNoState) {
+				log.Printf("Workspace %s has no Terraform state yet (%s)", ws.name, ws.source)
+			} else {
+				log.Printf("Warning: Failed to load Terraform state of workspace %s: %v", ws.name, err)
+			}
+			continue
+		}
+		states = append(states, workspaceState{name: ws.name, state: state})
+	}
+	return states
+}
+
+// mergeDiagrams merges the intended diagrams of several workspaces into one graph.
+// Each node records the workspace that manages it in metadata["terraform_workspace"].
+func mergeDiagrams(states []workspaceState) *graph.Graph {
+	workspaces := make([]overlay.Workspace, 0, len(states))
+	for _, ws := range states {
+		workspaces = append(workspaces, overlay.Workspace{Name: ws.name, State: ws.state})
+	}
+	return overlay.IntendedGraph(workspaces)
+}
+
+// workspaceNames returns the names of the loaded workspaces
+func workspaceNames(states []workspaceState) []string {
+	names := make([]string, 0, len(states))
+	for _, ws := range states {
+		names = append(names, ws.name)
+	}
+	return names
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditEntry は監査ログの1件（状態を変更した要求）
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Subject string    `json:"subject"`
+	Role    Role      `json:"role"`
+	Auth    Method    `json:"auth"`
+
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Query      string `json:"query,omitempty"`
+	Status     int    `json:"status"`
+	RemoteAddr string `json:"remote_addr"`
+
+	// DurationMS は要求の処理時間（ミリ秒）
+	DurationMS int64 `json:"duration_ms"`
+}
+
+func newAuditEntry(r *http.Request, identity *Identity, status int, start time.Time) AuditEntry {
+	return AuditEntry{
+		Time:       start.UTC(),
+		Subject:    identity.Subject,
+		Role:       identity.Role,
+		Auth:       identity.Method,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Query:      r.URL.RawQuery,
+		Status:     status,
+		RemoteAddr: r.RemoteAddr,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+}
+
+// AuditLog は監査ログを JSON Lines でファイルに追記する
+// ファイルを指定しない場合はサーバーのログに出力する
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewAuditLog は監査ログのファイルを開く（path が空の場合はサーバーのログに出力する）
+func NewAuditLog(path string) (*AuditLog, error) {
+	if path == "" {
+		return &AuditLog{}, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &AuditLog{file: f}, nil
+}
+
+// Record は監査ログに1件追記する
+func (l *AuditLog) Record(entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		log.Printf("Audit: %s", data)
+		return nil
+	}
+	_, err = l.file.Write(append(data, '\n'))
+	return err
+}
+
+// Close は監査ログのファイルを閉じる
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
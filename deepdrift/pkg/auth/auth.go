@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Method は認証方式
+type Method string
+
+const (
+	MethodToken Method = "token"
+	MethodOIDC  Method = "oidc"
+	MethodMTLS  Method = "mtls"
+)
+
+// Identity は認証したユーザー（トークン・クライアント証明書）
+type Identity struct {
+	// Subject はトークンの名前、JWT のユーザー名、または証明書の名前
+	Subject string `json:"subject"`
+	Role    Role   `json:"role"`
+	Method  Method `json:"method"`
+}
+
+// ErrUnauthenticated は資格情報のない要求のエラー
+var ErrUnauthenticated = errors.New("authentication required")
+
+// Authenticator は要求を設定した方式で認証する
+// nil の Authenticator はすべての要求を認証せずに通す（認証なし）
+type Authenticator struct {
+	tokens    []TokenConfig
+	oidc      *oidcVerifier
+	mtls      *MTLSConfig
+	clientCAs *x509.CertPool
+	audit     *AuditLog
+}
+
+// New は設定から Authenticator を作成する（JWKS・クライアント CA・監査ログを読み込む）
+func New(config *Config) (*Authenticator, error) {
+	a := &Authenticator{
+		tokens: config.Tokens,
+		mtls:   config.MTLS,
+	}
+
+	if config.OIDC != nil {
+		keys, err := newKeySet(config.OIDC, time.Now)
+		if err != nil {
+			return nil, err
+		}
+		a.oidc = &oidcVerifier{config: config.OIDC, keys: keys, now: time.Now}
+	}
+
+	if config.MTLS != nil {
+		data, err := os.ReadFile(config.MTLS.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		a.clientCAs = x509.NewCertPool()
+		if !a.clientCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%s: no PEM certificates found", config.MTLS.ClientCA)
+		}
+	}
+
+	audit, err := NewAuditLog(config.AuditLog)
+	if err != nil {
+		return nil, err
+	}
+	a.audit = audit
+
+	return a, nil
+}
+
+// ClientCAs はクライアント証明書を検証する CA（mTLS を設定していない場合は nil）
+func (a *Authenticator) ClientCAs() *x509.CertPool {
+	if a == nil {
+		return nil
+	}
+	return a.clientCAs
+}
+
+// Close は監査ログを閉じる
+func (a *Authenticator) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.audit.Close()
+}
+
+// Authenticate は要求の資格情報を検証する
+// Authorization: Bearer のトークン（静的トークン、JWT の順に照合）を優先し、
+// ない場合は検証済みのクライアント証明書を使う。資格情報がない場合は ErrUnauthenticated
+func (a *Authenticator) Authenticate(r *http.Request) (*Identity, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, _ := strings.Cut(header, " ")
+		token = strings.TrimSpace(token)
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return nil, fmt.Errorf("unsupported authorization scheme")
+		}
+		return a.authenticateToken(r.Context(), token)
+	}
+
+	if a.mtls != nil && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return a.authenticateCertificate(r.TLS.VerifiedChains[0][0])
+	}
+
+	return nil, ErrUnauthenticated
+}
+
+func (a *Authenticator) authenticateToken(ctx context.Context, token string) (*Identity, error) {
+	hash := hashToken(token)
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare(hash, t.hash) == 1 {
+			return &Identity{Subject: t.Name, Role: t.Role, Method: MethodToken}, nil
+		}
+	}
+
+	if a.oidc != nil && strings.Count(token, ".") == 2 {
+		return a.oidc.verify(ctx, token)
+	}
+	return nil, fmt.Errorf("invalid token")
+}
+
+// authenticateCertificate は証明書の CommonName・DNS 名・メールアドレスの順にロールを探す
+func (a *Authenticator) authenticateCertificate(cert *x509.Certificate) (*Identity, error) {
+	names := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, name := range names {
+		if role, ok := a.mtls.Subjects[name]; ok && name != "" {
+			return &Identity{Subject: name, Role: role, Method: MethodMTLS}, nil
+		}
+	}
+
+	if a.mtls.DefaultRole == "" {
+		return nil, fmt.Errorf("certificate %q has no role", cert.Subject.CommonName)
+	}
+	return &Identity{Subject: cert.Subject.CommonName, Role: a.mtls.DefaultRole, Method: MethodMTLS}, nil
+}
+
+func hashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+type identityKey struct{}
+
+// WithIdentity は認証したユーザーを context に設定する
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext は認証したユーザーを返す（認証していない場合は nil）
+func FromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+	return identity
+}
@@ -0,0 +1,480 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	t.Setenv("TEST_CI_TOKEN", "ci-secret")
+
+	config, err := ParseConfig([]byte(`
+tokens:
+  - name: ci
+    token: ${TEST_CI_TOKEN}
+    role: operator
+oidc:
+  issuer: https://login.example.com
+  audience: deepdrift
+  jwks_file: jwks.json
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if config.Tokens[0].Token != "ci-secret" {
+		t.Errorf("token = %q, want it expanded from the environment", config.Tokens[0].Token)
+	}
+	if config.OIDC.UsernameClaim != "sub" || config.OIDC.Leeway != DefaultLeeway {
+		t.Errorf("oidc defaults = %+v", config.OIDC)
+	}
+
+	invalid := map[string]string{
+		"no method":     `audit_log: audit.jsonl`,
+		"unset env":     "tokens: [{name: ci, token: ${TEST_UNSET_TOKEN}, role: viewer}]",
+		"bad role":      `tokens: [{name: ci, token: x, role: root}]`,
+		"bad hash":      `tokens: [{name: ci, sha256: abc, role: viewer}]`,
+		"duplicate":     `tokens: [{name: ci, token: x, role: viewer}, {name: ci, token: y, role: viewer}]`,
+		"no issuer":     `oidc: {audience: deepdrift, jwks_file: jwks.json}`,
+		"no jwks":       `oidc: {issuer: https://login.example.com, audience: deepdrift}`,
+		"oidc bad role": `oidc: {issuer: https://login.example.com, audience: deepdrift, jwks_file: jwks.json, roles: {sre: root}}`,
+		"no client ca":  `mtls: {subjects: {bot: operator}}`,
+	}
+	for name, yaml := range invalid {
+		if _, err := ParseConfig([]byte(yaml)); err == nil {
+			t.Errorf("%s: ParseConfig succeeded", name)
+		}
+	}
+}
+
+func TestRole(t *testing.T) {
+	if !RoleAdmin.Allows(RoleOperator) || !RoleOperator.Allows(RoleViewer) || !RoleViewer.Allows(RoleViewer) {
+		t.Error("stronger roles should allow weaker ones")
+	}
+	if RoleViewer.Allows(RoleOperator) || RoleOperator.Allows(RoleAdmin) || Role("").Allows(RoleViewer) {
+		t.Error("weaker roles should not allow stronger ones")
+	}
+}
+
+func newTestAuthenticator(t *testing.T, yaml string) *Authenticator {
+	t.Helper()
+	config, err := ParseConfig([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	a, err := New(config)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { a.Close() })
+	return a
+}
+
+func request(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/drifts", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestStaticTokens(t *testing.T) {
+	hash := sha256.Sum256([]byte("admin-secret"))
+	a := newTestAuthenticator(t, `
+tokens:
+  - name: ci
+    token: ci-secret
+    role: operator
+  - name: admin
+    sha256: `+hex.EncodeToString(hash[:])+`
+    role: admin
+`)
+
+	identity, err := a.Authenticate(request("ci-secret"))
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if *identity != (Identity{Subject: "ci", Role: RoleOperator, Method: MethodToken}) {
+		t.Errorf("identity = %+v", identity)
+	}
+
+	identity, err = a.Authenticate(request("admin-secret"))
+	if err != nil || identity.Subject != "admin" || identity.Role != RoleAdmin {
+		t.Errorf("Authenticate(admin) = %+v, %v", identity, err)
+	}
+
+	if _, err := a.Authenticate(request("wrong")); err == nil {
+		t.Error("Authenticate accepted an unknown token")
+	}
+	if _, err := a.Authenticate(request("")); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Authenticate without credentials error = %v, want ErrUnauthenticated", err)
+	}
+
+	basic := request("")
+	basic.SetBasicAuth("ci", "ci-secret")
+	if _, err := a.Authenticate(basic); err == nil {
+		t.Error("Authenticate accepted basic auth")
+	}
+}
+
+// testIssuer signs JWTs and serves their JWKS
+type testIssuer struct {
+	kid     string
+	rsaKey  *rsa.PrivateKey
+	ecKey   *ecdsa.PrivateKey
+	fetches atomic.Int32
+	server  *httptest.Server
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &testIssuer{kid: "rsa-1", rsaKey: rsaKey, ecKey: ecKey}
+	issuer.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issuer.fetches.Add(1)
+		json.NewEncoder(w).Encode(issuer.jwks())
+	}))
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (i *testIssuer) jwks() map[string]interface{} {
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	return map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{"kty": "RSA", "kid": i.kid, "use": "sig", "n": encode(i.rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(i.rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": encode(i.ecKey.X.FillBytes(make([]byte, 32))), "y": encode(i.ecKey.Y.FillBytes(make([]byte, 32)))},
+			{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		},
+	}
+}
+
+func (i *testIssuer) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		sig, err := rsa.SignPKCS1v15(rand.Reader, i.rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = sig
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, i.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func claims(overrides map[string]interface{}) map[string]interface{} {
+	c := map[string]interface{}{
+		"iss":    "https://login.example.com",
+		"aud":    []string{"deepdrift", "other"},
+		"sub":    "user-1",
+		"email":  "alice@example.com",
+		"groups": []string{"developers", "sre"},
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+	for key, value := range overrides {
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+	}
+	return c
+}
+
+func TestOIDC(t *testing.T) {
+	issuer := newTestIssuer(t)
+	a := newTestAuthenticator(t, `
+oidc:
+  issuer: https://login.example.com
+  audience: deepdrift
+  jwks_url: `+issuer.server.URL+`
+  username_claim: email
+  roles_claim: groups
+  roles: {sre: operator, deepdrift-admins: admin}
+`)
+
+	for _, alg := range []string{"RS256", "ES256"} {
+		kid := map[string]string{"RS256": "rsa-1", "ES256": "ec-1"}[alg]
+		identity, err := a.Authenticate(request(issuer.sign(t, alg, kid, claims(nil))))
+		if err != nil {
+			t.Fatalf("%s: Authenticate: %v", alg, err)
+		}
+		if *identity != (Identity{Subject: "alice@example.com", Role: RoleOperator, Method: MethodOIDC}) {
+			t.Errorf("%s: identity = %+v", alg, identity)
+		}
+	}
+
+	// The strongest mapped role wins
+	identity, err := a.Authenticate(request(issuer.sign(t, "RS256", "rsa-1", claims(map[string]interface{}{"groups": []string{"sre", "deepdrift-admins"}}))))
+	if err != nil || identity.Role != RoleAdmin {
+		t.Errorf("Authenticate(admin group) = %+v, %v", identity, err)
+	}
+
+	tampered := issuer.sign(t, "RS256", "rsa-1", claims(nil))
+	forged, _ := json.Marshal(claims(map[string]interface{}{"groups": "deepdrift-admins"}))
+	parts := strings.Split(tampered, ".")
+	tampered = parts[0] + "." + base64.RawURLEncoding.EncodeToString(forged) + "." + parts[2]
+
+	invalid := map[string]string{
+		"expired":        issuer.sign(t, "RS256", "rsa-1", claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"not yet valid":  issuer.sign(t, "RS256", "rsa-1", claims(map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()})),
+		"no exp":         issuer.sign(t, "RS256", "rsa-1", claims(map[string]interface{}{"exp": nil})),
+		"wrong issuer":   issuer.sign(t, "RS256", "rsa-1", claims(map[string]interface{}{"iss": "https://evil.example.com"})),
+		"wrong audience": issuer.sign(t, "RS256", "rsa-1", claims(map[string]interface{}{"aud": "other"})),
+		"no role":        issuer.sign(t, "RS256", "rsa-1", claims(map[string]interface{}{"groups": []string{"developers"}})),
+		"no username":    issuer.sign(t, "RS256", "rsa-1", claims(map[string]interface{}{"email": nil})),
+		"alg none":       issuer.sign(t, "none", "rsa-1", claims(nil)),
+		"wrong key type": issuer.sign(t, "ES256", "rsa-1", claims(nil)),
+		"unknown kid":    issuer.sign(t, "RS256", "rsa-2", claims(nil)),
+		"tampered":       tampered,
+	}
+	for name, token := range invalid {
+		if identity, err := a.Authenticate(request(token)); err == nil {
+			t.Errorf("%s: Authenticate = %+v, want error", name, identity)
+		}
+	}
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	issuer := newTestIssuer(t)
+	a := newTestAuthenticator(t, `
+oidc:
+  issuer: https://login.example.com
+  audience: deepdrift
+  jwks_url: `+issuer.server.URL+`
+  default_role: viewer
+`)
+	now := time.Now()
+	a.oidc.keys.now = func() time.Time { return now }
+
+	// The issuer rotates its key: an unknown kid refetches the JWKS, but
+	// not more often than jwksMinInterval
+	issuer.kid = "rsa-2"
+	token := issuer.sign(t, "RS256", "rsa-2", claims(nil))
+	if _, err := a.Authenticate(request(token)); err == nil {
+		t.Fatal("Authenticate succeeded right after the initial fetch")
+	}
+
+	now = now.Add(jwksMinInterval)
+	identity, err := a.Authenticate(request(token))
+	if err != nil {
+		t.Fatalf("Authenticate after rotation: %v", err)
+	}
+	if identity.Subject != "user-1" || identity.Role != RoleViewer {
+		t.Errorf("identity = %+v", identity)
+	}
+	if fetches := issuer.fetches.Load(); fetches != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", fetches)
+	}
+}
+
+func TestOIDCFile(t *testing.T) {
+	issuer := newTestIssuer(t)
+	data, _ := json.Marshal(issuer.jwks())
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAuthenticator(t, `
+oidc:
+  issuer: https://login.example.com
+  audience: deepdrift
+  jwks_file: `+path+`
+  roles_claim: role
+`)
+	identity, err := a.Authenticate(request(issuer.sign(t, "ES256", "ec-1", claims(map[string]interface{}{"role": "admin"}))))
+	if err != nil || identity.Role != RoleAdmin {
+		t.Errorf("Authenticate = %+v, %v", identity, err)
+	}
+}
+
+func TestMTLS(t *testing.T) {
+	dir := t.TempDir()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "DeepDrift Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pem := "-----BEGIN CERTIFICATE-----\n" + base64.StdEncoding.EncodeToString(caDER) + "\n-----END CERTIFICATE-----\n"
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, []byte(pem), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAuthenticator(t, `
+mtls:
+  client_ca: `+caFile+`
+  subjects: {deploy-bot: operator, ops@example.com: admin}
+`)
+	if a.ClientCAs() == nil {
+		t.Fatal("ClientCAs is nil")
+	}
+
+	withCert := func(cert *x509.Certificate) *http.Request {
+		r := request("")
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		return r
+	}
+
+	identity, err := a.Authenticate(withCert(&x509.Certificate{Subject: pkix.Name{CommonName: "deploy-bot"}}))
+	if err != nil || *identity != (Identity{Subject: "deploy-bot", Role: RoleOperator, Method: MethodMTLS}) {
+		t.Errorf("Authenticate(deploy-bot) = %+v, %v", identity, err)
+	}
+	identity, err = a.Authenticate(withCert(&x509.Certificate{Subject: pkix.Name{CommonName: "ops"}, EmailAddresses: []string{"ops@example.com"}}))
+	if err != nil || identity.Subject != "ops@example.com" || identity.Role != RoleAdmin {
+		t.Errorf("Authenticate(email) = %+v, %v", identity, err)
+	}
+	if _, err := a.Authenticate(withCert(&x509.Certificate{Subject: pkix.Name{CommonName: "stranger"}})); err == nil {
+		t.Error("Authenticate accepted a certificate without a role")
+	}
+
+	// Certificates that were not verified are ignored
+	unverified := request("")
+	unverified.TLS = &tls.ConnectionState{}
+	if _, err := a.Authenticate(unverified); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Authenticate(unverified) error = %v, want ErrUnauthenticated", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	auditFile := filepath.Join(t.TempDir(), "audit.jsonl")
+	a := newTestAuthenticator(t, `
+tokens:
+  - {name: viewer, token: viewer-secret, role: viewer}
+  - {name: operator, token: operator-secret, role: operator}
+audit_log: `+auditFile+`
+`)
+
+	policy := func(r *http.Request) Role {
+		switch {
+		case r.URL.Path == "/health":
+			return ""
+		case r.Method == http.MethodGet:
+			return RoleViewer
+		default:
+			return RoleOperator
+		}
+	}
+	var seen *Identity
+	handler := a.Middleware(policy, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = FromContext(r.Context())
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+
+	serve := func(method, path, token string) int {
+		r := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	tests := []struct {
+		method, path, token string
+		want                int
+	}{
+		{http.MethodGet, "/health", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/drifts", "", http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/drifts", "wrong", http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/drifts", "viewer-secret", http.StatusOK},
+		{http.MethodPost, "/api/v1/drifts/d1/resolve", "viewer-secret", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/drifts/d1/resolve?force=1", "operator-secret", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		if got := serve(tt.method, tt.path, tt.token); got != tt.want {
+			t.Errorf("%s %s with %q = %d, want %d", tt.method, tt.path, tt.token, got, tt.want)
+		}
+	}
+	if seen == nil || seen.Subject != "operator" {
+		t.Errorf("identity in the handler = %+v", seen)
+	}
+
+	// Only the authenticated mutations are audited, including the forbidden one
+	data, err := os.ReadFile(auditFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("audit log has %d entries, want 2:\n%s", len(lines), data)
+	}
+	var denied, allowed AuditEntry
+	json.Unmarshal([]byte(lines[0]), &denied)
+	json.Unmarshal([]byte(lines[1]), &allowed)
+	if denied.Subject != "viewer" || denied.Status != http.StatusForbidden || denied.Method != http.MethodPost {
+		t.Errorf("denied entry = %+v", denied)
+	}
+	if allowed.Subject != "operator" || allowed.Role != RoleOperator || allowed.Auth != MethodToken ||
+		allowed.Status != http.StatusCreated || allowed.Path != "/api/v1/drifts/d1/resolve" || allowed.Query != "force=1" {
+		t.Errorf("allowed entry = %+v", allowed)
+	}
+}
+
+func TestNilAuthenticator(t *testing.T) {
+	var a *Authenticator
+	called := false
+	handler := a.Middleware(func(*http.Request) Role { return RoleAdmin }, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/drifts", nil))
+	if !called {
+		t.Error("a nil Authenticator should not block requests")
+	}
+	if a.ClientCAs() != nil || a.Close() != nil || FromContext(context.Background()) != nil {
+		t.Error("nil Authenticator methods should be no-ops")
+	}
+}
@@ -0,0 +1,270 @@
+// Package auth は API の認証（静的トークン・OIDC の JWT・mTLS）、ロールによる認可と監査ログを扱う
+package auth
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Role は API の操作権限（上位のロールは下位のロールの操作をすべて行える）
+type Role string
+
+const (
+	// RoleViewer は drift・影響分析・グラフの参照
+	RoleViewer Role = "viewer"
+
+	// RoleOperator は参照に加えて drift の acknowledge・resolve・reopen と検出の実行
+	RoleOperator Role = "operator"
+
+	// RoleAdmin はすべての操作（drift・影響分析の登録を含む）
+	RoleAdmin Role = "admin"
+)
+
+// rank はロールの強さ（不明なロールは 0）
+func (r Role) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleOperator:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// IsValid はロールが定義済みかを判定
+func (r Role) IsValid() bool {
+	return r.rank() > 0
+}
+
+// Allows は required のロールが必要な操作をこのロールで行えるかを判定
+func (r Role) Allows(required Role) bool {
+	return r.IsValid() && r.rank() >= required.rank()
+}
+
+// Config は認証・認可の設定（YAML）
+// 設定した方式のどれかで認証できればよい
+//
+//	tokens:
+//	  - name: ci
+//	    token: ${DEEPDRIFT_CI_TOKEN}
+//	    role: operator
+//	  - name: admin
+//	    sha256: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	    role: admin
+//	oidc:
+//	  issuer: https://login.example.com
+//	  audience: deepdrift
+//	  jwks_url: https://login.example.com/.well-known/jwks.json
+//	  username_claim: email
+//	  roles_claim: groups
+//	  roles: {deepdrift-admins: admin, sre: operator}
+//	  default_role: viewer
+//	mtls:
+//	  client_ca: /etc/deepdrift/client-ca.pem
+//	  subjects: {deploy-bot: operator}
+//	audit_log: /var/log/deepdrift/audit.jsonl
+type Config struct {
+	// Tokens は静的な API トークン
+	Tokens []TokenConfig `yaml:"tokens"`
+
+	// OIDC は Bearer の JWT を検証する OpenID Connect プロバイダー
+	OIDC *OIDCConfig `yaml:"oidc"`
+
+	// MTLS はクライアント証明書による認証（API サーバーを TLS で起動する必要がある）
+	MTLS *MTLSConfig `yaml:"mtls"`
+
+	// AuditLog は状態を変更した要求を記録するファイル（JSON Lines、空の場合はサーバーのログに出力）
+	AuditLog string `yaml:"audit_log"`
+}
+
+// TokenConfig は静的な API トークン（Authorization: Bearer <token>）
+// 設定ファイルに平文を書かないように、Token には ${VAR} で環境変数を、
+// または SHA256 にトークンの SHA-256 (hex) を指定する
+type TokenConfig struct {
+	// Name は監査ログに記録するトークンの名前
+	Name   string `yaml:"name"`
+	Token  string `yaml:"token"`
+	SHA256 string `yaml:"sha256"`
+	Role   Role   `yaml:"role"`
+
+	hash []byte
+}
+
+// OIDCConfig は JWT を検証する OpenID Connect プロバイダーの設定
+type OIDCConfig struct {
+	// Issuer は iss クレームと一致する必要がある
+	Issuer string `yaml:"issuer"`
+
+	// Audience は aud クレームに含まれる必要がある
+	Audience string `yaml:"audience"`
+
+	// JWKSURL・JWKSFile は署名の検証鍵 (JWK Set) の取得先（どちらか一方）
+	// URL の鍵は JWKSRefresh ごと、または未知の kid の JWT を受け取ったときに取得し直す
+	JWKSURL     string        `yaml:"jwks_url"`
+	JWKSFile    string        `yaml:"jwks_file"`
+	JWKSRefresh time.Duration `yaml:"jwks_refresh"`
+
+	// UsernameClaim はユーザー名のクレーム（空の場合は sub）
+	UsernameClaim string `yaml:"username_claim"`
+
+	// RolesClaim はグループ・ロールのクレーム（文字列または文字列の配列）
+	RolesClaim string `yaml:"roles_claim"`
+
+	// Roles は RolesClaim の値からロールへの対応（複数一致した場合は最も強いロール）
+	// 空の場合は RolesClaim の値をそのままロール名として扱う
+	Roles map[string]Role `yaml:"roles"`
+
+	// DefaultRole はロールが決まらないユーザーのロール（空の場合は拒否する）
+	DefaultRole Role `yaml:"default_role"`
+
+	// Leeway は exp・nbf の判定で許容する時計のずれ（0 の場合は DefaultLeeway）
+	Leeway time.Duration `yaml:"leeway"`
+}
+
+// MTLSConfig はクライアント証明書による認証の設定
+type MTLSConfig struct {
+	// ClientCA はクライアント証明書を検証する CA 証明書 (PEM)
+	ClientCA string `yaml:"client_ca"`
+
+	// Subjects は証明書の名前（CommonName・DNS 名・メールアドレス）からロールへの対応
+	Subjects map[string]Role `yaml:"subjects"`
+
+	// DefaultRole は Subjects にない証明書のロール（空の場合は拒否する）
+	DefaultRole Role `yaml:"default_role"`
+}
+
+const (
+	// DefaultLeeway は JWT の時刻の判定で許容する時計のずれの既定値
+	DefaultLeeway = time.Minute
+
+	// DefaultJWKSRefresh は JWKS を取得し直す間隔の既定値
+	DefaultJWKSRefresh = time.Hour
+)
+
+// LoadConfig は認証の設定ファイル (YAML) を読み込む
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth config: %w", err)
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig は認証の設定 (YAML) をパース
+func ParseConfig(data []byte) (*Config, error) {
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse auth config: %w", err)
+	}
+
+	if len(config.Tokens) == 0 && config.OIDC == nil && config.MTLS == nil {
+		return nil, fmt.Errorf("no authentication method is configured (tokens, oidc, mtls)")
+	}
+
+	seen := make(map[string]bool, len(config.Tokens))
+	for i := range config.Tokens {
+		token := &config.Tokens[i]
+		if token.Name == "" {
+			token.Name = fmt.Sprintf("token-%d", i)
+		}
+		if seen[token.Name] {
+			return nil, fmt.Errorf("token %s: duplicate name", token.Name)
+		}
+		seen[token.Name] = true
+
+		token.Token = os.ExpandEnv(token.Token)
+		if err := token.validate(); err != nil {
+			return nil, fmt.Errorf("token %s: %w", token.Name, err)
+		}
+	}
+
+	if config.OIDC != nil {
+		config.OIDC.JWKSURL = os.ExpandEnv(config.OIDC.JWKSURL)
+		if err := config.OIDC.validate(); err != nil {
+			return nil, fmt.Errorf("oidc: %w", err)
+		}
+	}
+
+	if config.MTLS != nil {
+		if err := config.MTLS.validate(); err != nil {
+			return nil, fmt.Errorf("mtls: %w", err)
+		}
+	}
+
+	return &config, nil
+}
+
+func (t *TokenConfig) validate() error {
+	switch {
+	case t.Token != "" && t.SHA256 != "":
+		return fmt.Errorf("token and sha256 are exclusive")
+	case t.Token != "":
+		t.hash = hashToken(t.Token)
+	case t.SHA256 != "":
+		hash, err := hex.DecodeString(t.SHA256)
+		if err != nil || len(hash) != 32 {
+			return fmt.Errorf("sha256 must be a hex encoded SHA-256")
+		}
+		t.hash = hash
+	default:
+		return fmt.Errorf("token or sha256 is required (is the environment variable set?)")
+	}
+
+	if !t.Role.IsValid() {
+		return fmt.Errorf("unknown role %q (available: viewer, operator, admin)", t.Role)
+	}
+	return nil
+}
+
+func (o *OIDCConfig) validate() error {
+	if o.Issuer == "" {
+		return fmt.Errorf("issuer is required")
+	}
+	if o.Audience == "" {
+		return fmt.Errorf("audience is required")
+	}
+	if (o.JWKSURL == "") == (o.JWKSFile == "") {
+		return fmt.Errorf("either jwks_url or jwks_file is required")
+	}
+
+	for value, role := range o.Roles {
+		if !role.IsValid() {
+			return fmt.Errorf("roles.%s: unknown role %q", value, role)
+		}
+	}
+	if o.DefaultRole != "" && !o.DefaultRole.IsValid() {
+		return fmt.Errorf("unknown default_role %q", o.DefaultRole)
+	}
+
+	if o.UsernameClaim == "" {
+		o.UsernameClaim = "sub"
+	}
+	if o.Leeway <= 0 {
+		o.Leeway = DefaultLeeway
+	}
+	if o.JWKSRefresh <= 0 {
+		o.JWKSRefresh = DefaultJWKSRefresh
+	}
+	return nil
+}
+
+func (m *MTLSConfig) validate() error {
+	if m.ClientCA == "" {
+		return fmt.Errorf("client_ca is required")
+	}
+	for subject, role := range m.Subjects {
+		if !role.IsValid() {
+			return fmt.Errorf("subjects.%s: unknown role %q", subject, role)
+		}
+	}
+	if m.DefaultRole != "" && !m.DefaultRole.IsValid() {
+		return fmt.Errorf("unknown default_role %q", m.DefaultRole)
+	}
+	return nil
+}
@@ -0,0 +1,416 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksFetchTimeout は JWKS の取得のタイムアウト
+const jwksFetchTimeout = 10 * time.Second
+
+// jwksMinInterval は未知の kid で JWKS を取得し直す最短の間隔（不正な JWT で取得を繰り返さないため）
+const jwksMinInterval = time.Minute
+
+// keySet は JWT の署名の検証鍵 (kid → 公開鍵)
+type keySet struct {
+	mu      sync.Mutex
+	url     string
+	refresh time.Duration
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+	client  *http.Client
+	now     func() time.Time
+}
+
+// newKeySet は JWKS のファイルを読み込む、または URL から取得する
+// URL の取得に失敗した場合は最初の JWT の検証時に取得し直す
+func newKeySet(config *OIDCConfig, now func() time.Time) (*keySet, error) {
+	ks := &keySet{
+		url:     config.JWKSURL,
+		refresh: config.JWKSRefresh,
+		client:  &http.Client{Timeout: jwksFetchTimeout},
+		now:     now,
+	}
+
+	if config.JWKSFile != "" {
+		data, err := os.ReadFile(config.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS: %w", err)
+		}
+		keys, err := parseJWKS(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", config.JWKSFile, err)
+		}
+		ks.keys = keys
+		return ks, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if err := ks.fetch(ctx); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return ks, nil
+}
+
+// key は kid の公開鍵を返す（kid が空の場合は鍵が1つだけならその鍵）
+func (ks *keySet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	key, ok := ks.lookup(kid)
+	if ks.url != "" {
+		elapsed := ks.now().Sub(ks.fetched)
+		if (ok && elapsed >= ks.refresh) || (!ok && elapsed >= jwksMinInterval) {
+			if err := ks.fetch(ctx); err != nil && !ok {
+				return nil, err
+			}
+			key, ok = ks.lookup(kid)
+		}
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (ks *keySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, true
+		}
+	}
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// fetch は JWKS を URL から取得する（取得に失敗した場合は今の鍵を使い続ける）
+// (the caller must hold ks.mu)
+func (ks *keySet) fetch(ctx context.Context) error {
+	ks.fetched = ks.now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ks.url, err)
+	}
+	ks.keys = keys
+	return nil
+}
+
+// jwk は JWK Set の鍵（署名の検証に使う RSA・EC の公開鍵だけを扱う）
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS は JWK Set をパース（暗号化用の鍵と未対応の鍵は無視する）
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+		}
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS has no signing keys")
+	}
+	return keys, nil
+}
+
+// publicKey は JWK の公開鍵を返す（未対応の kty・曲線は nil）
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid e")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// signingMethod は JWT の alg の検証方法
+type signingMethod struct {
+	hash crypto.Hash
+	pss  bool
+
+	// ecSize は ECDSA の署名の r・s のバイト数（RSA の場合は 0）
+	ecSize int
+}
+
+var signingMethods = map[string]signingMethod{
+	"RS256": {hash: crypto.SHA256},
+	"RS384": {hash: crypto.SHA384},
+	"RS512": {hash: crypto.SHA512},
+	"PS256": {hash: crypto.SHA256, pss: true},
+	"PS384": {hash: crypto.SHA384, pss: true},
+	"PS512": {hash: crypto.SHA512, pss: true},
+	"ES256": {hash: crypto.SHA256, ecSize: 32},
+	"ES384": {hash: crypto.SHA384, ecSize: 48},
+	"ES512": {hash: crypto.SHA512, ecSize: 66},
+}
+
+// verify は署名を検証する
+func (m signingMethod) verify(key crypto.PublicKey, signed, signature []byte) error {
+	h := m.hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if m.ecSize != 0 {
+			return fmt.Errorf("key type does not match the algorithm")
+		}
+		if m.pss {
+			return rsa.VerifyPSS(key, m.hash, digest, signature, nil)
+		}
+		return rsa.VerifyPKCS1v15(key, m.hash, digest, signature)
+
+	case *ecdsa.PublicKey:
+		if m.ecSize == 0 || (key.Curve.Params().BitSize+7)/8 != m.ecSize {
+			return fmt.Errorf("key type does not match the algorithm")
+		}
+		if len(signature) != 2*m.ecSize {
+			return fmt.Errorf("invalid signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:m.ecSize])
+		s := new(big.Int).SetBytes(signature[m.ecSize:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported key type %T", key)
+}
+
+// oidcVerifier は OpenID Connect プロバイダーが発行した JWT を検証する
+type oidcVerifier struct {
+	config *OIDCConfig
+	keys   *keySet
+	now    func() time.Time
+}
+
+// verify は JWT の署名とクレームを検証してユーザーを返す
+func (v *oidcVerifier) verify(ctx context.Context, token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	method, ok := signingMethods[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	key, err := v.keys.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+	if err := method.verify(key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	if err := v.validateClaims(claims); err != nil {
+		return nil, err
+	}
+
+	username, _ := claims[v.config.UsernameClaim].(string)
+	if username == "" {
+		return nil, fmt.Errorf("token has no %s claim", v.config.UsernameClaim)
+	}
+	role := v.role(claims[v.config.RolesClaim])
+	if role == "" {
+		return nil, fmt.Errorf("user %s has no role", username)
+	}
+
+	return &Identity{Subject: username, Role: role, Method: MethodOIDC}, nil
+}
+
+// validateClaims は iss・aud・exp・nbf を検証する
+func (v *oidcVerifier) validateClaims(claims map[string]interface{}) error {
+	if iss, _ := claims["iss"].(string); iss != v.config.Issuer {
+		return fmt.Errorf("token issuer %q is not %s", iss, v.config.Issuer)
+	}
+
+	audiences := stringList(claims["aud"])
+	if !contains(audiences, v.config.Audience) {
+		return fmt.Errorf("token audience %v does not include %s", audiences, v.config.Audience)
+	}
+
+	now := v.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("token has no exp claim")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(v.config.Leeway)) {
+		return fmt.Errorf("token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.config.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("token is not valid yet")
+	}
+	return nil
+}
+
+// role は RolesClaim の値から最も強いロールを返す（なければ DefaultRole）
+func (v *oidcVerifier) role(claim interface{}) Role {
+	best := v.config.DefaultRole
+	for _, value := range stringList(claim) {
+		role := Role(value)
+		if len(v.config.Roles) > 0 {
+			role = v.config.Roles[value]
+		}
+		if role.IsValid() && role.rank() > best.rank() {
+			best = role
+		}
+	}
+	return best
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// stringList は文字列または文字列の配列のクレームを返す
+func stringList(claim interface{}) []string {
+	switch claim := claim.(type) {
+	case string:
+		return []string{claim}
+	case []interface{}:
+		list := make([]string, 0, len(claim))
+		for _, item := range claim {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
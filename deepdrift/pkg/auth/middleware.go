@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Policy は要求に必要なロールを返す（"" の場合は認証しない）
+type Policy func(r *http.Request) Role
+
+// Middleware は policy に従って要求を認証・認可する
+// 認証した要求のうち状態を変更するもの（GET・HEAD・OPTIONS 以外）は、
+// ロールが足りずに拒否したものを含めて監査ログに記録する
+func (a *Authenticator) Middleware(policy Policy, next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required := policy(r)
+		if required == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		identity, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="deepdrift"`)
+			respondError(w, http.StatusUnauthorized, "Unauthorized: "+err.Error())
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		if identity.Role.Allows(required) {
+			next.ServeHTTP(rec, r.WithContext(WithIdentity(r.Context(), identity)))
+		} else {
+			respondError(rec, http.StatusForbidden, fmt.Sprintf("Forbidden: requires the %s role", required))
+		}
+
+		if isMutation(r) {
+			entry := newAuditEntry(r, identity, rec.status, start)
+			if err := a.audit.Record(entry); err != nil {
+				log.Printf("Warning: Failed to write audit log: %v", err)
+			}
+		}
+	})
+}
+
+// isMutation は状態を変更する可能性のある要求かを判定
+func isMutation(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// statusRecorder は監査ログのために応答のステータスを記録する
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(data []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush はストリーミングの応答のために元の ResponseWriter の Flush を呼ぶ
+func (w *statusRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap は http.ResponseController のために元の ResponseWriter を返す
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// respondError は API と同じ形式のエラーを返す
+func respondError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":     message,
+		"status":    status,
+		"timestamp": time.Now().Unix(),
+	})
+}
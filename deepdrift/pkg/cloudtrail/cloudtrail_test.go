@@ -0,0 +1,306 @@
+package cloudtrail
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// detectedAt は testdata のイベントの後に drift を検出した時刻
+var detectedAt = time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+
+func newTestSource(t *testing.T, path string) Source {
+	t.Helper()
+	src, err := NewSource(filepath.Join("testdata", path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestFileSource_S3Logs(t *testing.T) {
+	src := newTestSource(t, "AWSLogs")
+
+	// ダイジェストファイルと期間外の日付のログは読まない
+	events, err := src.Events(context.Background(), detectedAt.Add(-DefaultWindow), detectedAt)
+	if err != nil {
+		t.Fatalf("Events() error = %v", err)
+	}
+	if len(events) != 6 {
+		t.Fatalf("Expected 6 events, got %d", len(events))
+	}
+
+	e := events[2]
+	if e.EventName != "ModifyInstanceAttribute" || e.UserType != "AssumedRole" || e.UserName != "bob@example.com" {
+		t.Errorf("Unexpected event: %+v", e)
+	}
+	if !e.EventTime.Equal(time.Date(2025, 1, 15, 8, 41, 17, 0, time.UTC)) || e.SourceIP != "198.51.100.42" {
+		t.Errorf("Unexpected event: %+v", e)
+	}
+	if !events[1].ReadOnly || events[4].ErrorCode == "" {
+		t.Errorf("Expected read-only and failed events to be parsed as such: %+v", events)
+	}
+}
+
+// fakeS3 は testdata のディレクトリをバケットとして一覧・取得する S3 API のフェイク
+type fakeS3 struct {
+	keys []string
+
+	// listed は一覧した prefix（区切り文字を使った一覧は末尾に "|/" を付ける）
+	listed []string
+}
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	t.Helper()
+	f := &fakeS3{}
+	err := filepath.WalkDir("testdata", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			f.keys = append(f.keys, strings.TrimPrefix(filepath.ToSlash(path), "testdata/"))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *fakeS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	prefix, delimiter := aws.ToString(params.Prefix), aws.ToString(params.Delimiter)
+	if delimiter != "" {
+		f.listed = append(f.listed, prefix+"|"+delimiter)
+	} else {
+		f.listed = append(f.listed, prefix)
+	}
+
+	out := &s3.ListObjectsV2Output{}
+	seen := make(map[string]bool)
+	for _, key := range f.keys {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			if dir := prefix + rest[:i+1]; !seen[dir] {
+				seen[dir] = true
+				out.CommonPrefixes = append(out.CommonPrefixes, s3types.CommonPrefix{Prefix: aws.String(dir)})
+			}
+			continue
+		}
+		out.Contents = append(out.Contents, s3types.Object{Key: aws.String(key)})
+	}
+	return out, nil
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	file, err := os.Open(filepath.Join("testdata", aws.ToString(params.Key)))
+	if err != nil {
+		return nil, err
+	}
+	return &s3.GetObjectOutput{Body: file}, nil
+}
+
+func TestS3Source_Events(t *testing.T) {
+	for _, prefix := range []string{"AWSLogs/", "AWSLogs/111122223333/CloudTrail/us-east-1/"} {
+		t.Run(prefix, func(t *testing.T) {
+			client := newFakeS3(t)
+			src := NewS3Source("trail-bucket", prefix, "", "")
+			src.client = client
+
+			events, err := src.Events(context.Background(), detectedAt.Add(-DefaultWindow), detectedAt)
+			if err != nil {
+				t.Fatalf("Events() error = %v", err)
+			}
+			if len(events) != 6 {
+				t.Fatalf("Expected 6 events, got %d", len(events))
+			}
+
+			// リージョンのディレクトリでは期間（1/14〜1/15）の前後1日の日付のディレクトリだけを一覧し、
+			// ダイジェストのディレクトリはたどらない
+			root := "AWSLogs/111122223333/CloudTrail/us-east-1/"
+			days := map[string]bool{root + "2025/01/13/": true, root + "2025/01/14/": true, root + "2025/01/15/": true, root + "2025/01/16/": true}
+			for _, listed := range client.listed {
+				if (strings.HasPrefix(listed, root) && !days[listed]) || strings.Contains(listed, "CloudTrail-Digest/") {
+					t.Errorf("Unexpected listing of %s", listed)
+				}
+			}
+		})
+	}
+}
+
+func TestParseEvents_Gzip(t *testing.T) {
+	data, err := os.ReadFile("testdata/AWSLogs/111122223333/CloudTrail/us-east-1/2025/01/15/111122223333_CloudTrail_us-east-1_20250115T0915Z_Xb7Qe2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+
+	events, err := ParseEvents(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ParseEvents() error = %v", err)
+	}
+	if len(events) != 6 || events[0].UserName != "alice" {
+		t.Errorf("Unexpected events: %+v", events)
+	}
+}
+
+func TestFileSource_LakeExports(t *testing.T) {
+	src := newTestSource(t, "lake")
+
+	events, err := src.Events(context.Background(), detectedAt.Add(-DefaultWindow), detectedAt)
+	if err != nil {
+		t.Fatalf("Events() error = %v", err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 events, got %d", len(events))
+	}
+
+	byID := make(map[string]Event, len(events))
+	for _, e := range events {
+		byID[e.EventID] = e
+	}
+
+	// GetQueryResults の出力（userIdentity は Lake の {key=value} 表記）
+	versioning := byID["5d4c3b2a-1908-4f7e-8d6c-5b4a39281706"]
+	if versioning.UserName != "dave" || versioning.UserARN != "arn:aws:sts::111122223333:assumed-role/ops/dave" {
+		t.Errorf("Unexpected user: %+v", versioning)
+	}
+	if len(versioning.Resources) != 1 || versioning.Resources[0] != "arn:aws:s3:::app-logs" {
+		t.Errorf("Unexpected resources: %v", versioning.Resources)
+	}
+	if !versioning.EventTime.Equal(time.Date(2025, 1, 15, 10, 2, 11, 0, time.UTC)) {
+		t.Errorf("Unexpected event time: %s", versioning.EventTime)
+	}
+
+	// クエリ結果の CSV（userIdentity.arn を選択した列）
+	policy := byID["3c2b1a09-f8e7-4d6c-b5a4-938271605f4e"]
+	if policy.EventName != "PutRolePolicy" || policy.UserName != "frank" || policy.ReadOnly {
+		t.Errorf("Unexpected event: %+v", policy)
+	}
+	if !byID["4d3c2b1a-0f9e-4d7c-a6b5-049382716a5f"].ReadOnly {
+		t.Error("Expected GetRole to be read-only")
+	}
+}
+
+func TestCorrelator_Correlate(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		event      *types.DriftEvent
+		candidates []string // 確度の高い順の CloudTrail のイベント名
+		user       string
+	}{
+		{
+			name:   "security group rule added",
+			source: "AWSLogs",
+			event: &types.DriftEvent{
+				ResourceID:   "aws:111122223333:us-east-1:security_group:sg-0a1b2c3d",
+				ResourceType: "security_group",
+				Type:         types.DriftModified,
+				Diff:         map[string]interface{}{"ingress[2]": map[string]interface{}{"type": "added", "after": "tcp:22-22:0.0.0.0/0"}},
+			},
+			// DescribeSecurityGroups（読み取り）と失敗した RevokeSecurityGroupIngress は候補にしない
+			candidates: []string{"AuthorizeSecurityGroupIngress"},
+			user:       "alice",
+		},
+		{
+			name:   "instance type changed",
+			source: "AWSLogs",
+			event: &types.DriftEvent{
+				ResourceID:   "aws:ec2:i-0abc1234",
+				ResourceType: "ec2",
+				Type:         types.DriftModified,
+				Diff:         map[string]interface{}{"instance_type": map[string]interface{}{"type": "modified", "before": "t3.micro", "after": "t3.large"}},
+			},
+			// Terraform による変更は確度を下げ、i-0abc12345678 の TerminateInstances は一致しない
+			candidates: []string{"ModifyInstanceAttribute", "ModifyInstanceAttribute"},
+			user:       "bob@example.com",
+		},
+		{
+			name:   "bucket versioning suspended",
+			source: "lake",
+			event: &types.DriftEvent{
+				ResourceID:   "aws:s3:app-logs",
+				ResourceType: "s3",
+				Type:         types.DriftModified,
+				Diff:         map[string]interface{}{"versioning": map[string]interface{}{"type": "modified", "before": true, "after": false}},
+			},
+			candidates: []string{"PutBucketVersioning", "PutBucketTagging"},
+			user:       "dave",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.event.FirstSeen = detectedAt
+			correlator := NewCorrelator(newTestSource(t, tt.source), 0)
+
+			candidates, err := correlator.Correlate(context.Background(), tt.event)
+			if err != nil {
+				t.Fatalf("Correlate() error = %v", err)
+			}
+			if len(candidates) != len(tt.candidates) {
+				t.Fatalf("Expected candidates %v, got %+v", tt.candidates, candidates)
+			}
+			for i, name := range tt.candidates {
+				if candidates[i].EventName != name {
+					t.Errorf("candidates[%d] = %s; want %s", i, candidates[i].EventName, name)
+				}
+				if i > 0 && candidates[i].Confidence > candidates[i-1].Confidence {
+					t.Errorf("Candidates are not ranked by confidence: %+v", candidates)
+				}
+			}
+			if candidates[0].UserIdentity != tt.user {
+				t.Errorf("Expected user %s, got %s", tt.user, candidates[0].UserIdentity)
+			}
+			if len(candidates[0].Reasons) == 0 {
+				t.Error("Expected the reasons for the confidence to be recorded")
+			}
+		})
+	}
+}
+
+func TestCorrelator_Annotate(t *testing.T) {
+	correlator := NewCorrelator(newTestSource(t, "AWSLogs"), time.Hour)
+
+	existing := &types.RootCause{CloudTrailEventID: "from-tfdrift"}
+	events := []*types.DriftEvent{
+		{ResourceID: "aws:sg:sg-0a1b2c3d", Type: types.DriftModified, FirstSeen: detectedAt.Add(-time.Hour)},
+		{ResourceID: "aws:ec2:i-0abc1234", Type: types.DriftModified, FirstSeen: detectedAt, RootCause: existing},
+		{ResourceID: "aws:ec2:i-unknown", Type: types.DriftModified, FirstSeen: detectedAt},
+		// 変更から1時間以上後に検出した drift は対象外
+		{ResourceID: "aws:ec2:i-0abc12345678", Type: types.DriftDeleted, FirstSeen: detectedAt},
+	}
+
+	if err := correlator.Annotate(context.Background(), events); err != nil {
+		t.Fatalf("Annotate() error = %v", err)
+	}
+
+	if rc := events[0].RootCause; rc == nil || rc.EventName != "AuthorizeSecurityGroupIngress" || rc.UserARN != "arn:aws:iam::111122223333:user/alice" || rc.SourceIP != "203.0.113.5" {
+		t.Errorf("Unexpected root cause: %+v", rc)
+	}
+	if len(events[0].RootCauseCandidates) != 1 {
+		t.Errorf("Expected 1 candidate, got %+v", events[0].RootCauseCandidates)
+	}
+	if events[1].RootCause != existing || events[1].RootCauseCandidates != nil {
+		t.Error("Expected an existing root cause to be kept")
+	}
+	if events[2].RootCause != nil || events[3].RootCause != nil {
+		t.Errorf("Expected no root cause, got %+v, %+v", events[2].RootCause, events[3].RootCause)
+	}
+}
@@ -0,0 +1,317 @@
+package cloudtrail
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// DefaultWindow は drift の検出前に CloudTrail のイベントを探す期間の既定値
+const DefaultWindow = 24 * time.Hour
+
+// maxCandidates は drift ごとに記録する原因の候補の最大数
+const maxCandidates = 5
+
+// Correlator は drift と CloudTrail のイベントを照合して根本原因を特定する
+//
+// 検出前の期間内に drift のリソースを変更した管理イベント（書き込みで成功したもの）を候補とし、
+// リソースの記録場所・drift の種類と操作の対応・変化した属性と操作の対応・検出時刻への近さで確度を付ける。
+type Correlator struct {
+	source Source
+	window time.Duration
+}
+
+// NewCorrelator は新しい Correlator を作成
+// window は drift の最初の検出時刻から遡って探す期間（0 の場合は DefaultWindow）
+func NewCorrelator(source Source, window time.Duration) *Correlator {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &Correlator{source: source, window: window}
+}
+
+// Correlate は drift の原因の候補を確度の高い順に返す
+func (c *Correlator) Correlate(ctx context.Context, event *types.DriftEvent) ([]types.RootCause, error) {
+	start, end := c.period(event)
+	trail, err := c.source.Events(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return rank(event, trail, start, end), nil
+}
+
+// Annotate は RootCause がない drift に原因の候補を設定する（取得元は1回だけ読む）
+// 候補がない drift はそのまま残す
+func (c *Correlator) Annotate(ctx context.Context, events []*types.DriftEvent) error {
+	var targets []*types.DriftEvent
+	var start, end time.Time
+	for _, event := range events {
+		if event.RootCause != nil {
+			continue
+		}
+		s, e := c.period(event)
+		if len(targets) == 0 || s.Before(start) {
+			start = s
+		}
+		if len(targets) == 0 || e.After(end) {
+			end = e
+		}
+		targets = append(targets, event)
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	trail, err := c.source.Events(ctx, start, end)
+	if err != nil {
+		return fmt.Errorf("failed to read cloudtrail events from %s: %w", c.source, err)
+	}
+
+	for _, event := range targets {
+		s, e := c.period(event)
+		candidates := rank(event, trail, s, e)
+		if len(candidates) == 0 {
+			continue
+		}
+		rootCause := candidates[0]
+		event.RootCause = &rootCause
+		event.RootCauseCandidates = candidates
+	}
+	return nil
+}
+
+// period は drift の原因を探す期間を返す（最初の検出時刻から window だけ遡る）
+func (c *Correlator) period(event *types.DriftEvent) (time.Time, time.Time) {
+	end := event.FirstSeen
+	if end.IsZero() {
+		end = event.Timestamp
+	}
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return end.Add(-c.window), end
+}
+
+// rank は期間内のイベントから drift の原因の候補を選び、確度の高い順に返す
+func rank(event *types.DriftEvent, trail []Event, start, end time.Time) []types.RootCause {
+	ids := identifiers(event)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	candidates := []types.RootCause{}
+	for _, e := range trail {
+		if e.EventTime.Before(start) || e.EventTime.After(end) || !isWrite(e) {
+			continue
+		}
+
+		confidence, reasons := score(event, e, ids, start, end)
+		if confidence == 0 {
+			continue
+		}
+		candidates = append(candidates, types.RootCause{
+			CloudTrailEventID: e.EventID,
+			EventName:         e.EventName,
+			UserIdentity:      e.UserName,
+			UserARN:           e.UserARN,
+			SourceIP:          e.SourceIP,
+			Timestamp:         e.EventTime,
+			Confidence:        confidence,
+			Reasons:           reasons,
+		})
+	}
+
+	// 確度が同じ場合は新しいイベント（現在の状態を作った可能性が高い）を優先
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Confidence != candidates[j].Confidence {
+			return candidates[i].Confidence > candidates[j].Confidence
+		}
+		return candidates[i].Timestamp.After(candidates[j].Timestamp)
+	})
+	if len(candidates) > maxCandidates {
+		candidates = candidates[:maxCandidates]
+	}
+	return candidates
+}
+
+// score は CloudTrail のイベントが drift の原因である確度 (0〜1) と根拠を返す
+// リソースを参照していないイベントは 0
+func score(event *types.DriftEvent, e Event, ids []string, start, end time.Time) (float64, []string) {
+	var confidence float64
+	var reasons []string
+
+	switch id := ""; {
+	case matchResources(e.Resources, ids, &id):
+		confidence = 0.5
+		reasons = append(reasons, fmt.Sprintf("%s is listed in the event resources", id))
+	case matchText(e.RequestParameters, ids, &id):
+		confidence = 0.4
+		reasons = append(reasons, fmt.Sprintf("%s is in the request parameters", id))
+	case matchText(e.ResponseElements, ids, &id):
+		confidence = 0.25
+		reasons = append(reasons, fmt.Sprintf("%s is in the response elements", id))
+	default:
+		return 0, nil
+	}
+
+	if hasPrefix(e.EventName, actionPrefixes[event.Type]) {
+		confidence += 0.2
+		reasons = append(reasons, fmt.Sprintf("%s matches a %s drift", e.EventName, event.Type))
+	}
+
+	if attribute := changedBy(event, e.EventName); attribute != "" {
+		confidence += 0.2
+		reasons = append(reasons, fmt.Sprintf("%s changes %s", e.EventName, attribute))
+	}
+
+	// 検出時刻に近いほど現在の状態を作った可能性が高い
+	if window := end.Sub(start); window > 0 {
+		confidence += 0.1 * (1 - float64(end.Sub(e.EventTime))/float64(window))
+	}
+
+	// Terraform による変更は apply の結果であり、drift の原因である可能性は低い
+	if strings.Contains(strings.ToLower(e.UserAgent), "terraform") {
+		confidence *= 0.5
+		reasons = append(reasons, "made by Terraform")
+	}
+
+	return math.Round(math.Min(confidence, 1)*100) / 100, reasons
+}
+
+// actionPrefixes は drift の種類に対応する操作名の接頭辞
+var actionPrefixes = map[types.DriftType][]string{
+	types.DriftCreated: {"Create", "Run", "Put", "Allocate", "Import", "Register"},
+	types.DriftDeleted: {"Delete", "Terminate", "Remove", "Deregister", "Release"},
+	types.DriftModified: {
+		"Modify", "Update", "Put", "Authorize", "Revoke", "Attach", "Detach",
+		"Associate", "Disassociate", "Replace", "Enable", "Disable", "Set", "Add",
+		"Remove", "Tag", "Untag", "CreateTags", "DeleteTags", "Start", "Stop",
+		"Monitor", "Unmonitor",
+	},
+}
+
+// attributeActions は属性を変更する操作名
+var attributeActions = map[string][]string{
+	"ingress": {"AuthorizeSecurityGroupIngress", "RevokeSecurityGroupIngress", "ModifySecurityGroupRules", "UpdateSecurityGroupRuleDescriptionsIngress"},
+	"egress":  {"AuthorizeSecurityGroupEgress", "RevokeSecurityGroupEgress", "ModifySecurityGroupRules", "UpdateSecurityGroupRuleDescriptionsEgress"},
+	"tags": {
+		"CreateTags", "DeleteTags", "TagResource", "UntagResource", "PutBucketTagging",
+		"DeleteBucketTagging", "AddTagsToResource", "RemoveTagsFromResource", "TagRole", "UntagRole",
+	},
+	"instance_type":                        {"ModifyInstanceAttribute"},
+	"user_data":                            {"ModifyInstanceAttribute"},
+	"vpc_security_group_ids":               {"ModifyInstanceAttribute", "ModifyNetworkInterfaceAttribute", "ModifyDBInstance"},
+	"security_groups":                      {"ModifyInstanceAttribute", "ModifyNetworkInterfaceAttribute"},
+	"monitoring":                           {"MonitorInstances", "UnmonitorInstances"},
+	"iam_instance_profile":                 {"AssociateIamInstanceProfile", "DisassociateIamInstanceProfile", "ReplaceIamInstanceProfileAssociation"},
+	"versioning":                           {"PutBucketVersioning"},
+	"server_side_encryption_configuration": {"PutBucketEncryption", "DeleteBucketEncryption"},
+	"policy":                               {"PutBucketPolicy", "DeleteBucketPolicy", "CreatePolicyVersion", "SetDefaultPolicyVersion", "PutRolePolicy"},
+}
+
+// changedBy は操作が変更する属性のうち、drift で変化したものを返す
+func changedBy(event *types.DriftEvent, eventName string) string {
+	paths := make([]string, 0, len(event.Diff))
+	for path := range event.Diff {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		attribute := path
+		if i := strings.IndexAny(path, ".["); i >= 0 {
+			attribute = path[:i]
+		}
+		if attribute == "tags_all" {
+			attribute = "tags"
+		}
+		for _, action := range attributeActions[attribute] {
+			if action == eventName {
+				return attribute
+			}
+		}
+	}
+	return ""
+}
+
+// identifiers は CloudTrail のイベントで drift のリソースを指す識別子を返す
+// ノード ID の末尾（"aws:ec2:i-123" の "i-123"）と、state の id・arn・バケット名など
+func identifiers(event *types.DriftEvent) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	add := func(v string) {
+		if len(v) >= 3 && !seen[v] {
+			seen[v] = true
+			ids = append(ids, v)
+		}
+	}
+
+	add(event.ResourceID[strings.LastIndex(event.ResourceID, ":")+1:])
+	for _, state := range []map[string]interface{}{event.Before, event.After} {
+		for _, key := range []string{"id", "arn", "bucket", "bucket_name", "instance_id", "group_id", "security_group_id"} {
+			if v, ok := state[key].(string); ok {
+				add(v)
+			}
+		}
+	}
+	return ids
+}
+
+// isWrite は成功した書き込みの操作かを判定
+func isWrite(e Event) bool {
+	if e.ReadOnly || e.ErrorCode != "" {
+		return false
+	}
+	return !hasPrefix(e.EventName, []string{"Describe", "Get", "List", "Head", "Lookup"})
+}
+
+func hasPrefix(name string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchResources は resources の ARN がいずれかの識別子を指すかを判定
+func matchResources(resources []string, ids []string, matched *string) bool {
+	for _, arn := range resources {
+		for _, id := range ids {
+			if arn == id || strings.HasSuffix(arn, "/"+id) || strings.HasSuffix(arn, ":"+id) {
+				*matched = id
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchText はテキストにいずれかの識別子が単語として含まれるかを判定
+// "i-123" は "i-1234" に一致しない
+func matchText(text string, ids []string, matched *string) bool {
+	for _, id := range ids {
+		for offset := 0; ; {
+			i := strings.Index(text[offset:], id)
+			if i < 0 {
+				break
+			}
+			begin, end := offset+i, offset+i+len(id)
+			if (begin == 0 || !isIDChar(text[begin-1])) && (end == len(text) || !isIDChar(text[end])) {
+				*matched = id
+				return true
+			}
+			offset = begin + 1
+		}
+	}
+	return false
+}
+
+func isIDChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.'
+}
@@ -0,0 +1,411 @@
+// Package cloudtrail は CloudTrail の管理イベントから drift の根本原因を特定する
+package cloudtrail
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event は CloudTrail の管理イベント
+// S3 に配信されるログファイルと CloudTrail Lake のエクスポートを同じ形に揃える
+type Event struct {
+	EventID     string    `json:"event_id"`
+	EventName   string    `json:"event_name"`
+	EventSource string    `json:"event_source"`
+	EventTime   time.Time `json:"event_time"`
+	AWSRegion   string    `json:"aws_region,omitempty"`
+	AccountID   string    `json:"account_id,omitempty"`
+
+	// UserType は userIdentity.type (IAMUser, AssumedRole, Root など)
+	UserType string `json:"user_type,omitempty"`
+	UserARN  string `json:"user_arn,omitempty"`
+	// UserName は利用者の名前（AssumedRole の場合はセッション名）
+	UserName string `json:"user_name,omitempty"`
+
+	SourceIP  string `json:"source_ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	ReadOnly  bool   `json:"read_only"`
+	ErrorCode string `json:"error_code,omitempty"`
+
+	// Resources は resources に記録されたリソースの ARN
+	Resources []string `json:"resources,omitempty"`
+
+	// RequestParameters と ResponseElements は照合用のテキスト（JSON または Lake の表記）
+	RequestParameters string `json:"request_parameters,omitempty"`
+	ResponseElements  string `json:"response_elements,omitempty"`
+}
+
+// record は CloudTrail のイベントレコード（S3 のログファイルの形式）
+type record struct {
+	EventID            string          `json:"eventID"`
+	EventName          string          `json:"eventName"`
+	EventSource        string          `json:"eventSource"`
+	EventTime          string          `json:"eventTime"`
+	AWSRegion          string          `json:"awsRegion"`
+	RecipientAccountID string          `json:"recipientAccountId"`
+	SourceIPAddress    string          `json:"sourceIPAddress"`
+	UserAgent          string          `json:"userAgent"`
+	ErrorCode          string          `json:"errorCode"`
+	ReadOnly           interface{}     `json:"readOnly"`
+	UserIdentity       userIdentity    `json:"userIdentity"`
+	RequestParameters  json.RawMessage `json:"requestParameters"`
+	ResponseElements   json.RawMessage `json:"responseElements"`
+	Resources          []struct {
+		ARN string `json:"ARN"`
+	} `json:"resources"`
+}
+
+type userIdentity struct {
+	Type        string `json:"type"`
+	PrincipalID string `json:"principalId"`
+	ARN         string `json:"arn"`
+	AccountID   string `json:"accountId"`
+	UserName    string `json:"userName"`
+	InvokedBy   string `json:"invokedBy"`
+}
+
+// ParseEvents は CloudTrail のイベントを読み込む（gzip 圧縮にも対応）
+//
+// 対応する形式:
+//   - S3 に配信されるログファイル ({"Records": [...]})
+//   - CloudTrail Lake の GetQueryResults の出力 ({"QueryResultRows": [[{"eventID": "..."}, ...]]})
+//   - CloudTrail Lake のクエリ結果の CSV
+//   - イベントレコードの配列または JSON Lines
+func ParseEvents(data []byte) ([]Event, error) {
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress cloudtrail events: %w", err)
+		}
+		defer r.Close()
+		if data, err = io.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("failed to decompress cloudtrail events: %w", err)
+		}
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if data[0] != '{' && data[0] != '[' {
+		return parseCSV(data)
+	}
+
+	var events []Event
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse cloudtrail events: %w", err)
+		}
+
+		parsed, err := parseJSON(raw)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, parsed...)
+	}
+	return events, nil
+}
+
+// parseJSON はログファイル・Lake のクエリ結果・レコード・レコードの配列のいずれかをパース
+func parseJSON(raw json.RawMessage) ([]Event, error) {
+	if raw[0] == '[' {
+		var records []record
+		if err := json.Unmarshal(raw, &records); err != nil {
+			return nil, fmt.Errorf("failed to parse cloudtrail records: %w", err)
+		}
+		return fromRecords(records)
+	}
+
+	var envelope struct {
+		Records         []record              `json:"Records"`
+		QueryResultRows [][]map[string]string `json:"QueryResultRows"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse cloudtrail events: %w", err)
+	}
+
+	switch {
+	case envelope.Records != nil:
+		return fromRecords(envelope.Records)
+	case envelope.QueryResultRows != nil:
+		events := make([]Event, 0, len(envelope.QueryResultRows))
+		for _, row := range envelope.QueryResultRows {
+			columns := make(map[string]string, len(row))
+			for _, cell := range row {
+				for k, v := range cell {
+					columns[k] = v
+				}
+			}
+			event, err := fromColumns(columns)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+		}
+		return events, nil
+	}
+
+	var r record
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse cloudtrail record: %w", err)
+	}
+	return fromRecords([]record{r})
+}
+
+// parseCSV は CloudTrail Lake のクエリ結果の CSV をパース
+func parseCSV(data []byte) ([]Event, error) {
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cloudtrail lake results: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	header := rows[0]
+	events := make([]Event, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		columns := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(row) {
+				columns[name] = row[i]
+			}
+		}
+		event, err := fromColumns(columns)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func fromRecords(records []record) ([]Event, error) {
+	events := make([]Event, 0, len(records))
+	for _, r := range records {
+		eventTime, err := parseTime(r.EventTime)
+		if err != nil {
+			return nil, fmt.Errorf("event %s: %w", r.EventID, err)
+		}
+
+		event := Event{
+			EventID:           r.EventID,
+			EventName:         r.EventName,
+			EventSource:       r.EventSource,
+			EventTime:         eventTime,
+			AWSRegion:         r.AWSRegion,
+			AccountID:         r.RecipientAccountID,
+			UserType:          r.UserIdentity.Type,
+			UserARN:           r.UserIdentity.ARN,
+			UserName:          userName(r.UserIdentity),
+			SourceIP:          r.SourceIPAddress,
+			UserAgent:         r.UserAgent,
+			ReadOnly:          parseBool(r.ReadOnly),
+			ErrorCode:         r.ErrorCode,
+			RequestParameters: rawText(r.RequestParameters),
+			ResponseElements:  rawText(r.ResponseElements),
+		}
+		for _, resource := range r.Resources {
+			if resource.ARN != "" {
+				event.Resources = append(event.Resources, resource.ARN)
+			}
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// fromColumns は CloudTrail Lake のクエリ結果の1行を Event に変換
+// 列名は大文字小文字を区別しない。userIdentity は JSON と Lake の {key=value, ...} の表記に対応し、
+// userIdentity.arn のように選択した列も扱う
+func fromColumns(columns map[string]string) (Event, error) {
+	lower := make(map[string]string, len(columns))
+	for k, v := range columns {
+		lower[strings.ToLower(k)] = v
+	}
+	col := func(names ...string) string {
+		for _, name := range names {
+			if v := lower[name]; v != "" && v != "null" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	var identity userIdentity
+	if raw := col("useridentity"); raw != "" {
+		identity = parseIdentity(raw)
+	}
+	if v := col("useridentity.type"); v != "" {
+		identity.Type = v
+	}
+	if v := col("useridentity.arn", "arn"); v != "" {
+		identity.ARN = v
+	}
+	if v := col("useridentity.username", "username"); v != "" {
+		identity.UserName = v
+	}
+	if v := col("useridentity.principalid", "principalid"); v != "" {
+		identity.PrincipalID = v
+	}
+
+	eventTime, err := parseTime(col("eventtime"))
+	if err != nil {
+		return Event{}, fmt.Errorf("event %s: %w", col("eventid"), err)
+	}
+
+	event := Event{
+		EventID:           col("eventid"),
+		EventName:         col("eventname"),
+		EventSource:       col("eventsource"),
+		EventTime:         eventTime,
+		AWSRegion:         col("awsregion"),
+		AccountID:         col("recipientaccountid"),
+		UserType:          identity.Type,
+		UserARN:           identity.ARN,
+		UserName:          userName(identity),
+		SourceIP:          col("sourceipaddress"),
+		UserAgent:         col("useragent"),
+		ReadOnly:          parseBool(col("readonly")),
+		ErrorCode:         col("errorcode"),
+		RequestParameters: col("requestparameters"),
+		ResponseElements:  col("responseelements"),
+	}
+	// Lake の resources は ARN を含む表記（JSON または {accountId=..., type=..., arn=...}）
+	if resources := col("resources"); resources != "" {
+		event.Resources = findARNs(resources)
+	}
+	return event, nil
+}
+
+// parseIdentity は userIdentity の列をパース
+func parseIdentity(raw string) userIdentity {
+	var identity userIdentity
+	if err := json.Unmarshal([]byte(raw), &identity); err == nil {
+		return identity
+	}
+
+	// {type=IAMUser, principalid=AIDA..., arn=arn:aws:iam::...:user/alice, username=alice, sessioncontext={...}}
+	fields := make(map[string]string)
+	depth := 0
+	var key, value strings.Builder
+	inValue := false
+	flush := func() {
+		if k := strings.ToLower(strings.TrimSpace(key.String())); k != "" {
+			if v := strings.TrimSpace(value.String()); v != "null" {
+				fields[k] = v
+			}
+		}
+		key.Reset()
+		value.Reset()
+		inValue = false
+	}
+	for _, r := range strings.TrimSpace(raw) {
+		switch {
+		case r == '{':
+			depth++
+			if depth > 1 {
+				value.WriteRune(r)
+			}
+		case r == '}':
+			depth--
+			if depth > 0 {
+				value.WriteRune(r)
+			}
+		case depth == 1 && r == ',':
+			flush()
+		case depth == 1 && r == '=' && !inValue:
+			inValue = true
+		case inValue:
+			value.WriteRune(r)
+		default:
+			key.WriteRune(r)
+		}
+	}
+	flush()
+
+	identity.Type = fields["type"]
+	identity.PrincipalID = fields["principalid"]
+	identity.ARN = fields["arn"]
+	identity.AccountID = fields["accountid"]
+	identity.UserName = fields["username"]
+	identity.InvokedBy = fields["invokedby"]
+	return identity
+}
+
+// userName は利用者の名前を返す
+// AssumedRole はセッション名（SSO のユーザー名や CI のセッション名）を使う
+func userName(identity userIdentity) string {
+	switch {
+	case identity.UserName != "":
+		return identity.UserName
+	case identity.Type == "Root":
+		return "root"
+	case identity.InvokedBy != "":
+		return identity.InvokedBy
+	case identity.Type == "AssumedRole" && identity.ARN != "":
+		return identity.ARN[strings.LastIndex(identity.ARN, "/")+1:]
+	case identity.PrincipalID != "":
+		return identity.PrincipalID[strings.LastIndex(identity.PrincipalID, ":")+1:]
+	}
+	return identity.ARN
+}
+
+// findARNs はテキストに含まれる ARN を返す
+func findARNs(text string) []string {
+	var arns []string
+	for {
+		i := strings.Index(text, "arn:")
+		if i < 0 {
+			return arns
+		}
+		text = text[i:]
+		end := strings.IndexAny(text, `",}] `)
+		if end < 0 {
+			end = len(text)
+		}
+		arns = append(arns, text[:end])
+		text = text[end:]
+	}
+}
+
+// rawText は requestParameters などの JSON をそのままテキストとして返す
+func rawText(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
+
+// parseTime は eventTime をパース（S3 は RFC 3339、Lake は "2006-01-02 15:04:05.000"）
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid eventTime %q", s)
+}
+
+func parseBool(v interface{}) bool {
+	switch val := v.(type) {
+	case bool:
+		return val
+	case string:
+		b, _ := strconv.ParseBool(val)
+		return b
+	}
+	return false
+}
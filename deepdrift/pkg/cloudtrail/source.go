@@ -0,0 +1,294 @@
+package cloudtrail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Source は CloudTrail のイベントの取得元
+type Source interface {
+	// String は取得元の説明（ログ用）
+	String() string
+
+	// Events は start から end までに発生したイベントを返す
+	Events(ctx context.Context, start, end time.Time) ([]Event, error)
+}
+
+// NewSource は場所から Source を作成
+// "s3://bucket/prefix" は S3 に配信されたログ（または Lake のエクスポート）、それ以外はローカルのファイルかディレクトリ
+func NewSource(location string) (Source, error) {
+	if rest, ok := strings.CutPrefix(location, "s3://"); ok {
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("cloudtrail: invalid S3 location %q", location)
+		}
+		return NewS3Source(bucket, prefix, "", ""), nil
+	}
+	return NewFileSource(location)
+}
+
+// FileSource はローカルの CloudTrail のログファイルまたは CloudTrail Lake のエクスポート
+// ディレクトリの場合は再帰的に読み込む（S3 からダウンロードした AWSLogs/... をそのまま指定できる）
+type FileSource struct {
+	path string
+}
+
+// NewFileSource は新しい FileSource を作成
+func NewFileSource(path string) (*FileSource, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("cloudtrail: %w", err)
+	}
+	return &FileSource{path: path}, nil
+}
+
+// String は取得元の説明を返す
+func (s *FileSource) String() string {
+	return s.path
+}
+
+// Events はファイルを読み込み、期間内のイベントを返す
+func (s *FileSource) Events(ctx context.Context, start, end time.Time) ([]Event, error) {
+	var events []Event
+	err := filepath.WalkDir(s.path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || !isLogFile(filepath.ToSlash(path), start, end) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		parsed, err := ParseEvents(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		events = append(events, inRange(parsed, start, end)...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cloudtrail events: %w", err)
+	}
+	return events, nil
+}
+
+// s3API は S3Source が使う API
+type s3API interface {
+	s3.ListObjectsV2APIClient
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// S3Source は S3 に配信された CloudTrail のログファイル（または Lake のエクスポート）
+type S3Source struct {
+	bucket  string
+	prefix  string
+	region  string
+	profile string
+
+	once      sync.Once
+	client    s3API
+	clientErr error
+}
+
+// NewS3Source は新しい S3Source を作成
+// prefix は AWSLogs/<account>/CloudTrail/<region>/ のようにログの場所を絞り込む（空の場合はバケット全体）
+func NewS3Source(bucket, prefix, region, profile string) *S3Source {
+	return &S3Source{bucket: bucket, prefix: prefix, region: region, profile: profile}
+}
+
+// String は取得元の説明を返す
+func (s *S3Source) String() string {
+	return "s3://" + s.bucket + "/" + s.prefix
+}
+
+// Events は期間内の日付のログファイルを取得し、期間内のイベントを返す
+func (s *S3Source) Events(ctx context.Context, start, end time.Time) ([]Event, error) {
+	if _, err := s.s3Client(ctx); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.logKeys(ctx, s.prefix, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for _, key := range keys {
+		data, err := s.get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := ParseEvents(data)
+		if err != nil {
+			return nil, fmt.Errorf("s3://%s/%s: %w", s.bucket, key, err)
+		}
+		events = append(events, inRange(parsed, start, end)...)
+	}
+	return events, nil
+}
+
+// logKeys は prefix の下にある期間内のログファイルのキーを返す
+// リージョンのディレクトリ (.../CloudTrail/<region>/) では期間内の日付のディレクトリだけを一覧し、
+// それより上のディレクトリは1階層ずつたどる。ログが溜まってもポーリングのたびにバケット全体を一覧しない
+func (s *S3Source) logKeys(ctx context.Context, prefix string, start, end time.Time) ([]string, error) {
+	if regionDir.MatchString(prefix) {
+		var keys []string
+		first, last := logDays(start, end)
+		for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+			dayKeys, _, err := s.list(ctx, prefix+day.Format("2006/01/02/"), "", start, end)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, dayKeys...)
+		}
+		return keys, nil
+	}
+
+	keys, dirs, err := s.list(ctx, prefix, "/", start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if strings.HasSuffix(dir, "CloudTrail-Digest/") {
+			continue
+		}
+		dirKeys, err := s.logKeys(ctx, dir, start, end)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, dirKeys...)
+	}
+	return keys, nil
+}
+
+// list は prefix の下のログファイルのキーと、delimiter で区切ったサブディレクトリを返す
+func (s *S3Source) list(ctx context.Context, prefix, delimiter string, start, end time.Time) ([]string, []string, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}
+	if delimiter != "" {
+		input.Delimiter = aws.String(delimiter)
+	}
+
+	var keys, dirs []string
+	pages := s3.NewListObjectsV2Paginator(s.client, input)
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list s3://%s/%s: %w", s.bucket, prefix, err)
+		}
+		for _, object := range page.Contents {
+			if key := aws.ToString(object.Key); isLogFile(key, start, end) {
+				keys = append(keys, key)
+			}
+		}
+		for _, common := range page.CommonPrefixes {
+			dirs = append(dirs, aws.ToString(common.Prefix))
+		}
+	}
+	return keys, dirs, nil
+}
+
+func (s *S3Source) get(ctx context.Context, key string) ([]byte, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get s3://%s/%s: %w", s.bucket, key, err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read s3://%s/%s: %w", s.bucket, key, err)
+	}
+	return data, nil
+}
+
+// s3Client は S3 クライアントを返す（初回のみ AWS 設定をロード）
+func (s *S3Source) s3Client(ctx context.Context) (s3API, error) {
+	s.once.Do(func() {
+		if s.client != nil {
+			return
+		}
+
+		opts := []func(*config.LoadOptions) error{config.WithSharedConfigProfile(s.profile)}
+		if s.region != "" {
+			opts = append(opts, config.WithRegion(s.region))
+		}
+		cfg, err := config.LoadDefaultConfig(ctx, opts...)
+		if err != nil {
+			s.clientErr = fmt.Errorf("failed to load AWS config: %w", err)
+			return
+		}
+		s.client = s3.NewFromConfig(cfg)
+	})
+	return s.client, s.clientErr
+}
+
+// logDate は S3 に配信されるログのパス (.../CloudTrail/<region>/YYYY/MM/DD/...) の日付
+var logDate = regexp.MustCompile(`(?:^|/)(\d{4})/(\d{2})/(\d{2})/`)
+
+// regionDir は S3 に配信されるログのリージョンのディレクトリ (.../CloudTrail/<region>/)
+var regionDir = regexp.MustCompile(`(?:^|/)CloudTrail/[^/]+/$`)
+
+// logDays は期間のイベントを含みうるログのディレクトリの最初と最後の日付を返す
+// ログファイルは配信が遅れることがあるため、前後1日を含める
+func logDays(start, end time.Time) (time.Time, time.Time) {
+	return start.UTC().Truncate(24*time.Hour).AddDate(0, 0, -1), end.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+}
+
+// isLogFile はイベントを読み込むファイルかを判定
+// ダイジェストと Lake の署名ファイルは除き、パスの日付が期間外のログファイルは読まない
+func isLogFile(path string, start, end time.Time) bool {
+	name := path[strings.LastIndex(path, "/")+1:]
+	if strings.Contains(path, "CloudTrail-Digest/") || strings.HasSuffix(name, "_sign.json") || strings.HasPrefix(name, ".") {
+		return false
+	}
+	if !strings.HasSuffix(name, ".json") && !strings.HasSuffix(name, ".json.gz") &&
+		!strings.HasSuffix(name, ".csv") && !strings.HasSuffix(name, ".csv.gz") &&
+		!strings.HasSuffix(name, ".jsonl") && !strings.HasSuffix(name, ".jsonl.gz") {
+		return false
+	}
+
+	m := logDate.FindStringSubmatch(path)
+	if m == nil {
+		return true
+	}
+	day, err := time.Parse("2006/01/02", m[1]+"/"+m[2]+"/"+m[3])
+	if err != nil {
+		return true
+	}
+	first, last := logDays(start, end)
+	return !day.Before(first) && !day.After(last)
+}
+
+// inRange は期間内のイベントを返す
+func inRange(events []Event, start, end time.Time) []Event {
+	kept := events[:0]
+	for _, event := range events {
+		if !event.EventTime.Before(start) && !event.EventTime.After(end) {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
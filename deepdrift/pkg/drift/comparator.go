@@ -0,0 +1,357 @@
+package drift
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/severity"
+	"github.com/higakikeita/airdig/deepdrift/pkg/terraform"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+// fieldKind は属性の比較方法
+type fieldKind int
+
+const (
+	// scalarField は文字列・数値・真偽値として比較
+	scalarField fieldKind = iota
+
+	// setField は順序を無視した文字列の集合として比較
+	setField
+
+	// tagsField はタグとして比較（AWS が付与する "aws:" で始まるタグは除く）
+	tagsField
+
+	// rulesField は Security Group のルールとして比較（送信元ごとに展開し、まとめ方の違いを無視する）
+	rulesField
+
+	// versioningField は S3 のバージョニング設定として比較
+	versioningField
+)
+
+// field は Terraform の属性と実際のリソース（SkyGraph のメタデータ）のキーの対応
+type field struct {
+	// name は Diff のキー（Terraform の属性名）
+	name string
+
+	// actual は SkyGraph のメタデータのキー
+	actual string
+
+	kind fieldKind
+}
+
+var (
+	ec2Fields = []field{
+		{name: "instance_type", actual: "instance_type"},
+		{name: "ami", actual: "ami_id"},
+		{name: "subnet_id", actual: "subnet_id"},
+		{name: "private_ip", actual: "private_ip"},
+		{name: "instance_state", actual: "state"},
+		{name: "vpc_security_group_ids", actual: "security_groups", kind: setField},
+		{name: "tags", actual: "tags", kind: tagsField},
+	}
+
+	securityGroupFields = []field{
+		{name: "name", actual: "group_name"},
+		{name: "description", actual: "description"},
+		{name: "vpc_id", actual: "vpc_id"},
+		{name: "ingress", actual: "ingress_rules", kind: rulesField},
+		{name: "egress", actual: "egress_rules", kind: rulesField},
+		{name: "tags", actual: "tags", kind: tagsField},
+	}
+
+	s3Fields = []field{
+		{name: "region", actual: "region"},
+		{name: "versioning", actual: "versioning", kind: versioningField},
+		{name: "tags", actual: "tags", kind: tagsField},
+	}
+)
+
+// Comparator は Terraform state と実際のリソースを比較して drift を検出する
+type Comparator struct {
+	now        func() time.Time
+	suppressor *Suppressor
+	severity   *severity.Engine
+}
+
+// NewComparator は新しい Comparator を作成
+func NewComparator() *Comparator {
+	return &Comparator{now: time.Now}
+}
+
+// SetSuppressor は検出した drift に抑制ポリシーを適用するよう設定
+func (c *Comparator) SetSuppressor(suppressor *Suppressor) {
+	c.suppressor = suppressor
+}
+
+// SetSeverityEngine は drift の深刻度を計算するルールを設定（未設定の場合は既定のルール）
+func (c *Comparator) SetSeverityEngine(engine *severity.Engine) {
+	c.severity = engine
+}
+
+// CompareEC2 は aws_instance と SkyGraph の EC2 メタデータを比較
+// 実際のリソースに対応するインスタンス（instance_id が一致）がない場合や drift がない場合は nil を返す
+func (c *Comparator) CompareEC2(tfResource terraform.Resource, actual map[string]interface{}) *types.DriftEvent {
+	return c.compare(tfResource, actual, "id", stringValue(actual["instance_id"]), ec2Fields)
+}
+
+// CompareSecurityGroup は aws_security_group と SkyGraph の Security Group メタデータを比較
+func (c *Comparator) CompareSecurityGroup(tfResource terraform.Resource, actual map[string]interface{}) *types.DriftEvent {
+	id := stringValue(actual["group_id"])
+	if id == "" {
+		id = stringValue(actual["security_group_id"])
+	}
+	return c.compare(tfResource, actual, "id", id, securityGroupFields)
+}
+
+// CompareS3 は aws_s3_bucket と S3 バケットのメタデータを比較
+func (c *Comparator) CompareS3(tfResource terraform.Resource, actual map[string]interface{}) *types.DriftEvent {
+	return c.compare(tfResource, actual, "bucket", stringValue(actual["bucket_name"]), s3Fields)
+}
+
+// compare は ID が一致するインスタンスの属性を比較して DriftEvent を作成
+// 実際のリソース側にないキーは SkyGraph が収集していない属性として比較しない
+func (c *Comparator) compare(tfResource terraform.Resource, actual map[string]interface{}, idAttribute, id string, fields []field) *types.DriftEvent {
+	if id == "" {
+		return nil
+	}
+	inst, ok := tfResource.FindInstance(idAttribute, id)
+	if !ok {
+		return nil
+	}
+
+	before := make(map[string]interface{})
+	after := make(map[string]interface{})
+
+	for _, f := range fields {
+		actualValue, ok := actual[f.actual]
+		if !ok {
+			continue
+		}
+		desiredValue, ok := desired(inst, f)
+		if !ok {
+			continue
+		}
+
+		before[f.name] = normalize(f.kind, desiredValue, id)
+		after[f.name] = normalize(f.kind, actualValue, id)
+	}
+
+	// 変化した値だけをパスごとに記録する（例: "tags.Env", "ingress[3]"）
+	changes := Diff(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return c.newEvent(&types.DriftEvent{
+		ResourceID:       tfResource.NodeID(inst),
+		ResourceType:     tfResource.NodeType(),
+		TerraformAddress: tfResource.InstanceAddress(inst),
+		Type:             types.DriftModified,
+		Before:           before,
+		After:            after,
+		Diff:             DiffMap(changes),
+	})
+}
+
+// deleted は state にあるが実際には存在しないインスタンスの DriftEvent を作成
+// Before は state の属性で、After はない
+func (c *Comparator) deleted(tfResource terraform.Resource, inst terraform.Instance, fields []field) *types.DriftEvent {
+	id := tfResource.CloudID(inst)
+	before := make(map[string]interface{})
+	for _, f := range fields {
+		if value, ok := desired(inst, f); ok {
+			before[f.name] = normalize(f.kind, value, id)
+		}
+	}
+
+	return c.newEvent(&types.DriftEvent{
+		ResourceID:       tfResource.NodeID(inst),
+		ResourceType:     tfResource.NodeType(),
+		TerraformAddress: tfResource.InstanceAddress(inst),
+		Type:             types.DriftDeleted,
+		Before:           before,
+	})
+}
+
+// created は Terraform で管理されていないリソースの DriftEvent を作成
+// After は実際のリソースの属性（Terraform の属性名）で、Before はない
+func (c *Comparator) created(node graph.ResourceNode, actual map[string]interface{}, id string, fields []field) *types.DriftEvent {
+	after := make(map[string]interface{})
+	for _, f := range fields {
+		if value, ok := actual[f.actual]; ok && value != nil {
+			after[f.name] = normalize(f.kind, value, id)
+		}
+	}
+
+	return c.newEvent(&types.DriftEvent{
+		ResourceID:   node.ID,
+		ResourceType: node.Type,
+		Workspace:    UnmanagedWorkspace,
+		Type:         types.DriftCreated,
+		After:        after,
+	})
+}
+
+// newEvent は検出時刻と ID を設定し、抑制ポリシーと深刻度を適用する
+func (c *Comparator) newEvent(event *types.DriftEvent) *types.DriftEvent {
+	now := c.now()
+	event.Timestamp = now
+	event.Status = types.DriftOpen
+	event.FirstSeen = now
+	event.LastSeen = now
+	event.ID = types.DriftEventID(event)
+
+	// 抑制後に残った差分から深刻度を計算する
+	return c.severity.Apply(c.suppressor.Apply(event))
+}
+
+// desired は state のインスタンスの属性値を返す（値がない場合は false）
+func desired(inst terraform.Instance, f field) (interface{}, bool) {
+	value, ok := inst.Attributes[f.name]
+	if f.kind == tagsField {
+		// provider の default_tags を含む tags_all を優先
+		if all, exists := inst.Attributes["tags_all"]; exists && all != nil {
+			value, ok = all, true
+		}
+	}
+	return value, ok && value != nil
+}
+
+// normalize は比較できる形に値を揃える
+func normalize(kind fieldKind, v interface{}, selfID string) interface{} {
+	switch kind {
+	case setField:
+		values := terraform.StringSlice(v)
+		sorted := append([]string{}, values...)
+		sort.Strings(sorted)
+		return sorted
+
+	case tagsField:
+		tags := terraform.StringMap(v)
+		for key := range tags {
+			if strings.HasPrefix(key, "aws:") {
+				delete(tags, key)
+			}
+		}
+		return tags
+
+	case rulesField:
+		// Terraform の ingress / egress は SkyGraph の形式に変換してから展開する
+		if items, ok := v.([]interface{}); ok && isTerraformRules(items) {
+			v = terraform.SecurityGroupRules(items, selfID)
+		}
+		return expandRules(v)
+
+	case versioningField:
+		return versioningEnabled(v)
+
+	default:
+		return scalar(v)
+	}
+}
+
+// isTerraformRules は Terraform の ingress / egress 属性（self キーを持つ）かを判定
+func isTerraformRules(items []interface{}) bool {
+	for _, item := range items {
+		if rule, ok := item.(map[string]interface{}); ok {
+			_, hasSelf := rule["self"]
+			return hasSelf
+		}
+	}
+	return false
+}
+
+// expandRules は Security Group のルールを "protocol:ports:peer" の集合に展開
+// AWS は同じプロトコル・ポートのルールをまとめて返すため、相手（CIDR・Security Group）ごとに比較する
+func expandRules(v interface{}) []string {
+	var items []map[string]interface{}
+	switch val := v.(type) {
+	case []map[string]interface{}:
+		items = val
+	case []interface{}:
+		for _, item := range val {
+			if rule, ok := item.(map[string]interface{}); ok {
+				items = append(items, rule)
+			}
+		}
+	}
+
+	rules := make([]string, 0)
+	for _, rule := range items {
+		protocol := normalizeProtocol(stringValue(rule["protocol"]))
+		ports := "*"
+		if protocol != "all" {
+			ports = fmt.Sprintf("%v-%v", scalar(rule["from_port"]), scalar(rule["to_port"]))
+		}
+
+		prefix := protocol + ":" + ports + ":"
+		for _, key := range []string{"cidr_blocks", "ipv6_cidr_blocks", "prefix_list_ids", "security_groups"} {
+			for _, peer := range terraform.StringSlice(rule[key]) {
+				rules = append(rules, prefix+peer)
+			}
+		}
+	}
+	sort.Strings(rules)
+	return rules
+}
+
+// normalizeProtocol はプロトコル番号を名前に揃える（"-1" は全プロトコル）
+func normalizeProtocol(protocol string) string {
+	switch strings.ToLower(protocol) {
+	case "-1", "all", "":
+		return "all"
+	case "6":
+		return "tcp"
+	case "17":
+		return "udp"
+	case "1":
+		return "icmp"
+	default:
+		return strings.ToLower(protocol)
+	}
+}
+
+// versioningEnabled は S3 のバージョニング設定を真偽値に変換
+// Terraform: versioning = [{enabled = true}]、SkyGraph: "Enabled" / "Suspended" / bool
+func versioningEnabled(v interface{}) bool {
+	switch val := v.(type) {
+	case bool:
+		return val
+	case string:
+		return strings.EqualFold(val, "Enabled")
+	case []interface{}:
+		for _, item := range val {
+			if block, ok := item.(map[string]interface{}); ok {
+				enabled, _ := block["enabled"].(bool)
+				return enabled
+			}
+		}
+	}
+	return false
+}
+
+// scalar は数値を float64 に揃え、nil を空文字として扱う
+func scalar(v interface{}) interface{} {
+	switch n := v.(type) {
+	case nil:
+		return ""
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	default:
+		return v
+	}
+}
+
+// stringValue は文字列の値を返す（文字列でない場合は空文字）
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
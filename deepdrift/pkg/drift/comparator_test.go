@@ -0,0 +1,298 @@
+package drift
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/terraform"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+func parseResource(t *testing.T, data string) terraform.Resource {
+	t.Helper()
+	state, err := terraform.ParseState(strings.NewReader(`{"version": 4, "resources": [` + data + `]}`))
+	if err != nil {
+		t.Fatalf("ParseState() error = %v", err)
+	}
+	return state.Resources[0]
+}
+
+// fromJSON は SkyGraph API から取得したメタデータと同じ形（数値は float64）に変換
+func fromJSON(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+const ec2Resource = `{
+  "module": "module.app",
+  "mode": "managed",
+  "type": "aws_instance",
+  "name": "web",
+  "instances": [
+    {"index_key": 0, "attributes": {
+      "id": "i-0", "arn": "arn:aws:ec2:us-east-1:111111111111:instance/i-0",
+      "instance_type": "t3.micro", "ami": "ami-1", "subnet_id": "subnet-1", "private_ip": "10.0.1.10",
+      "instance_state": "running", "vpc_security_group_ids": ["sg-2", "sg-1"],
+      "tags": {"Name": "web-0"}, "tags_all": {"Name": "web-0", "Team": "platform"}
+    }},
+    {"index_key": 1, "attributes": {
+      "id": "i-1", "arn": "arn:aws:ec2:us-east-1:111111111111:instance/i-1",
+      "instance_type": "t3.micro", "ami": "ami-1", "vpc_security_group_ids": ["sg-1"]
+    }}
+  ]
+}`
+
+func TestCompareEC2_NoDrift(t *testing.T) {
+	resource := parseResource(t, ec2Resource)
+	actual := fromJSON(t, `{
+		"instance_id": "i-0", "instance_type": "t3.micro", "ami_id": "ami-1", "subnet_id": "subnet-1",
+		"private_ip": "10.0.1.10", "state": "running", "security_groups": ["sg-1", "sg-2"],
+		"tags": {"Name": "web-0", "Team": "platform", "aws:cloudformation:stack-name": "x"},
+		"availability_zone": "us-east-1a"
+	}`)
+
+	if event := NewComparator().CompareEC2(resource, actual); event != nil {
+		t.Errorf("Expected no drift, got %+v", event.Diff)
+	}
+}
+
+func TestCompareEC2_Drift(t *testing.T) {
+	resource := parseResource(t, ec2Resource)
+	actual := map[string]interface{}{
+		"instance_id":     "i-1",
+		"instance_type":   "t3.large",
+		"ami_id":          "ami-1",
+		"security_groups": []string{"sg-1", "sg-9"},
+		"tags":            map[string]string{"Name": "web-1"},
+	}
+
+	comparator := NewComparator()
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	comparator.now = func() time.Time { return now }
+
+	event := comparator.CompareEC2(resource, actual)
+	if event == nil {
+		t.Fatal("Expected drift")
+	}
+
+	if event.ResourceID != "aws:111111111111:us-east-1:ec2:i-1" || event.ResourceType != "ec2" {
+		t.Errorf("Unexpected resource: %s (%s)", event.ResourceID, event.ResourceType)
+	}
+	if event.TerraformAddress != "module.app.aws_instance.web[1]" {
+		t.Errorf("Unexpected address: %s", event.TerraformAddress)
+	}
+	if event.Type != types.DriftModified || event.Severity != types.SeverityMedium || !event.Timestamp.Equal(now) {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	// 2 番目のインスタンスにはタグがないため tags は比較しない
+	if len(event.Diff) != 2 {
+		t.Fatalf("Expected 2 changed fields, got %v", event.Diff)
+	}
+	change := event.Diff["instance_type"].(map[string]interface{})
+	if change["before"] != "t3.micro" || change["after"] != "t3.large" {
+		t.Errorf("Unexpected instance_type change: %v", change)
+	}
+	// Security Group は集合として比較し、追加された sg-9 だけを記録する
+	change = event.Diff["vpc_security_group_ids[1]"].(map[string]interface{})
+	if change["type"] != "added" || change["after"] != "sg-9" {
+		t.Errorf("Unexpected security group change: %v", change)
+	}
+}
+
+func TestCompareEC2_UnknownInstance(t *testing.T) {
+	resource := parseResource(t, ec2Resource)
+	if event := NewComparator().CompareEC2(resource, map[string]interface{}{"instance_id": "i-x", "instance_type": "t3.large"}); event != nil {
+		t.Errorf("Expected nil for unmatched instance, got %+v", event)
+	}
+}
+
+const sgResource = `{
+  "mode": "managed",
+  "type": "aws_security_group",
+  "name": "db",
+  "instances": [{"attributes": {
+    "id": "sg-db", "arn": "arn:aws:ec2:us-east-1:111111111111:security-group/sg-db",
+    "name": "db", "description": "database", "vpc_id": "vpc-1",
+    "ingress": [
+      {"protocol": "tcp", "from_port": 5432, "to_port": 5432, "cidr_blocks": ["10.0.1.0/24"],
+       "ipv6_cidr_blocks": [], "prefix_list_ids": [], "security_groups": ["sg-app"], "self": false},
+      {"protocol": "tcp", "from_port": 5432, "to_port": 5432, "cidr_blocks": [],
+       "ipv6_cidr_blocks": [], "prefix_list_ids": [], "security_groups": [], "self": true}
+    ],
+    "egress": [
+      {"protocol": "-1", "from_port": 0, "to_port": 0, "cidr_blocks": ["0.0.0.0/0"],
+       "ipv6_cidr_blocks": [], "prefix_list_ids": [], "security_groups": [], "self": false}
+    ]
+  }}]
+}`
+
+func TestCompareSecurityGroup(t *testing.T) {
+	resource := parseResource(t, sgResource)
+
+	// AWS は同じポートのルールを 1 つにまとめて返す
+	actual := fromJSON(t, `{
+		"group_id": "sg-db", "group_name": "db", "description": "database", "vpc_id": "vpc-1",
+		"ingress_rules": [
+			{"protocol": "tcp", "from_port": 5432, "to_port": 5432,
+			 "cidr_blocks": ["10.0.1.0/24"], "security_groups": ["sg-app", "sg-db"]}
+		],
+		"egress_rules": [{"protocol": "-1", "cidr_blocks": ["0.0.0.0/0"]}]
+	}`)
+	if event := NewComparator().CompareSecurityGroup(resource, actual); event != nil {
+		t.Fatalf("Expected no drift, got %v", event.Diff)
+	}
+
+	// 手動で 22 番ポートを開けた
+	actual["ingress_rules"] = append(actual["ingress_rules"].([]interface{}), map[string]interface{}{
+		"protocol": "tcp", "from_port": float64(22), "to_port": float64(22), "cidr_blocks": []interface{}{"0.0.0.0/0"},
+	})
+	event := NewComparator().CompareSecurityGroup(resource, actual)
+	if event == nil {
+		t.Fatal("Expected drift")
+	}
+	if event.Severity != types.SeverityHigh || event.ResourceType != "security_group" ||
+		event.ResourceID != "aws:111111111111:us-east-1:sg:sg-db" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	// 追加されたルールだけが差分になる（index は After["ingress"] の位置）
+	change, ok := event.Diff["ingress[0]"].(map[string]interface{})
+	if !ok || len(event.Diff) != 1 {
+		t.Fatalf("Expected only ingress change, got %v", event.Diff)
+	}
+	if change["type"] != "added" || change["after"] != "tcp:22-22:0.0.0.0/0" {
+		t.Errorf("Unexpected ingress change: %v", change)
+	}
+	if after := event.After["ingress"].([]string); len(after) != 4 || after[0] != "tcp:22-22:0.0.0.0/0" {
+		t.Errorf("Unexpected ingress after: %v", after)
+	}
+}
+
+func TestCompareS3(t *testing.T) {
+	resource := parseResource(t, `{
+	  "mode": "managed", "type": "aws_s3_bucket", "name": "logs",
+	  "instances": [{"attributes": {
+	    "id": "logs", "bucket": "logs", "region": "us-east-1",
+	    "versioning": [{"enabled": true, "mfa_delete": false}], "tags": {"Env": "prod"}
+	  }}]
+	}`)
+
+	actual := map[string]interface{}{
+		"bucket_name": "logs", "region": "us-east-1", "versioning": "Suspended",
+		"tags": map[string]string{"Env": "prod"},
+	}
+	event := NewComparator().CompareS3(resource, actual)
+	if event == nil {
+		t.Fatal("Expected drift")
+	}
+	change := event.Diff["versioning"].(map[string]interface{})
+	if change["before"] != true || change["after"] != false || len(event.Diff) != 1 {
+		t.Errorf("Unexpected diff: %v", event.Diff)
+	}
+	if event.ResourceID != "aws:s3:logs" || event.TerraformAddress != "aws_s3_bucket.logs" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	actual["versioning"] = "Enabled"
+	if event := NewComparator().CompareS3(resource, actual); event != nil {
+		t.Errorf("Expected no drift, got %v", event.Diff)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	state, err := terraform.ParseState(strings.NewReader(`{"version": 4, "resources": [` + ec2Resource + `,` + sgResource + `]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := graph.NewGraph()
+	g.AddNode(graph.ResourceNode{
+		ID: "aws:111111111111:us-east-1:ec2:i-0", Type: "ec2",
+		Metadata: map[string]interface{}{"instance_id": "i-0", "instance_type": "t3.large"},
+		Tags:     map[string]string{"Name": "web-0", "Team": "platform"},
+	})
+	g.AddNode(graph.ResourceNode{
+		ID: "aws:111111111111:us-east-1:sg:sg-db", Type: "security_group",
+		Metadata: map[string]interface{}{"group_id": "sg-db", "description": "database"},
+	})
+
+	// i-1 はグラフにないため比較しない
+	events := NewComparator().Detect(state, g)
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 drift, got %d", len(events))
+	}
+	if events[0].ResourceID != "aws:111111111111:us-east-1:ec2:i-0" || len(events[0].Diff) != 1 {
+		t.Errorf("Unexpected drift: %s %v", events[0].ResourceID, events[0].Diff)
+	}
+}
+
+func TestDetect_Deleted(t *testing.T) {
+	state, err := terraform.ParseState(strings.NewReader(`{"version": 4, "resources": [` + ec2Resource + `,` + sgResource + `]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// us-east-1 の EC2 はスキャンされているが Security Group はグラフに1つもない
+	g := graph.NewGraph()
+	g.AddNode(graph.ResourceNode{
+		ID: "aws:ec2:i-0", Type: "ec2", Region: "us-east-1",
+		Metadata: map[string]interface{}{"instance_id": "i-0", "instance_type": "t3.micro"},
+		Tags:     map[string]string{"Name": "web-0", "Team": "platform"},
+	})
+
+	// i-1 は削除されたものとして検出し、sg-db はスキャン範囲外なので検出しない
+	events := NewComparator().Detect(state, g)
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 drift, got %d", len(events))
+	}
+	event := events[0]
+	if event.Type != types.DriftDeleted || event.ResourceID != "aws:111111111111:us-east-1:ec2:i-1" {
+		t.Errorf("Unexpected drift: %s %s", event.Type, event.ResourceID)
+	}
+	if event.TerraformAddress != "module.app.aws_instance.web[1]" || event.Before["instance_type"] != "t3.micro" || event.After != nil {
+		t.Errorf("Unexpected deleted drift: %+v", event)
+	}
+}
+
+func TestDetectUnmanaged(t *testing.T) {
+	state, err := terraform.ParseState(strings.NewReader(`{"version": 4, "resources": [` + ec2Resource + `,` + sgResource + `]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := graph.NewGraph()
+	for _, node := range []graph.ResourceNode{
+		{ID: "aws:ec2:i-0", Type: "ec2", Region: "us-east-1", Metadata: map[string]interface{}{"instance_id": "i-0"}},
+		{ID: "aws:ec2:i-9", Type: "ec2", Region: "us-east-1",
+			Metadata: map[string]interface{}{"instance_id": "i-9", "instance_type": "t3.nano"},
+			Tags:     map[string]string{"Name": "manual"}},
+		// state がリソースを管理していないリージョン
+		{ID: "aws:ec2:i-8", Type: "ec2", Region: "eu-west-1", Metadata: map[string]interface{}{"instance_id": "i-8"}},
+		// AWS が自動で作るリソース
+		{ID: "aws:ec2:i-7", Type: "ec2", Region: "us-east-1", Metadata: map[string]interface{}{"instance_id": "i-7"},
+			Tags: map[string]string{"aws:autoscaling:groupName": "workers"}},
+		{ID: "aws:sg:sg-default", Type: "security_group", Region: "us-east-1",
+			Metadata: map[string]interface{}{"group_id": "sg-default", "group_name": "default"}},
+	} {
+		g.AddNode(node)
+	}
+
+	events := NewComparator().DetectUnmanaged([]*terraform.State{state}, g)
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 unmanaged resource, got %d", len(events))
+	}
+	event := events[0]
+	if event.Type != types.DriftCreated || event.ResourceID != "aws:ec2:i-9" || event.ResourceType != "ec2" {
+		t.Errorf("Unexpected drift: %s %s (%s)", event.Type, event.ResourceID, event.ResourceType)
+	}
+	if event.Workspace != UnmanagedWorkspace || event.Before != nil || event.After["instance_type"] != "t3.nano" {
+		t.Errorf("Unexpected created drift: %+v", event)
+	}
+}
@@ -0,0 +1,189 @@
+package drift
+
+import (
+	"github.com/higakikeita/airdig/deepdrift/pkg/terraform"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+// UnmanagedWorkspace は Terraform で管理されていないリソースの drift（DriftCreated）のワークスペース名
+// どのワークスペースにも属さないため空で、全ワークスペースの state を読めた場合だけ検出・解消する
+const UnmanagedWorkspace = ""
+
+// target は比較対象のリソースタイプ
+type target struct {
+	// resources は state から対象のリソースを取り出す
+	resources func(*terraform.State) []terraform.Resource
+
+	// nodeType は SkyGraph のノードタイプ
+	nodeType string
+
+	// idKey は SkyGraph のメタデータでクラウド側の ID を持つキー
+	idKey string
+
+	compare func(*Comparator, terraform.Resource, map[string]interface{}) *types.DriftEvent
+
+	fields []field
+}
+
+var targets = []target{
+	{(*terraform.State).GetEC2Instances, "ec2", "instance_id", (*Comparator).CompareEC2, ec2Fields},
+	{(*terraform.State).GetSecurityGroups, "security_group", "group_id", (*Comparator).CompareSecurityGroup, securityGroupFields},
+	{(*terraform.State).GetS3Buckets, "s3", "bucket_name", (*Comparator).CompareS3, s3Fields},
+}
+
+// location はリソースのアカウントとリージョン
+type location struct {
+	account string
+	region  string
+}
+
+// matches は同じアカウント・リージョンかを判定（どちらかのアカウントが不明の場合はリージョンだけで判定）
+func (l location) matches(other location) bool {
+	return l.region != "" && l.region == other.region &&
+		(l.account == "" || other.account == "" || l.account == other.account)
+}
+
+// inScope は locations のいずれかと同じアカウント・リージョンかを判定
+func inScope(locations []location, l location) bool {
+	for _, scanned := range locations {
+		if scanned.matches(l) {
+			return true
+		}
+	}
+	return false
+}
+
+// instanceLocation は state のインスタンスのアカウントとリージョンを返す
+func instanceLocation(inst terraform.Instance) location {
+	account, region := terraform.Location(inst)
+	return location{account: account, region: region}
+}
+
+// actualResources は SkyGraph のノードのうち t のタイプのものの属性を ID ごとに返す
+// ノードが見つかったアカウント・リージョンも返す
+func (t target) actualResources(g *graph.Graph) (map[string]map[string]interface{}, []location) {
+	actualByID := make(map[string]map[string]interface{})
+	var scanned []location
+	for _, node := range g.Nodes {
+		if node.Type != t.nodeType {
+			continue
+		}
+		id, _ := node.Metadata[t.idKey].(string)
+		if id == "" {
+			continue
+		}
+		actualByID[id] = attributes(node)
+
+		l := location{account: node.Account, region: node.Region}
+		if !inScope(scanned, l) {
+			scanned = append(scanned, l)
+		}
+	}
+	return actualByID, scanned
+}
+
+// Detect は state と SkyGraph のグラフを比較して drift を検出
+//
+// 両方にあるリソースは属性を比較して DriftModified を、state にあってグラフにないリソースは
+// DriftDeleted を返す。グラフに同じタイプのリソースが1つもないアカウント・リージョンは
+// スキャン範囲外の可能性があるため、削除されたとはみなさない。
+func (c *Comparator) Detect(state *terraform.State, g *graph.Graph) []*types.DriftEvent {
+	events := make([]*types.DriftEvent, 0)
+
+	for _, t := range targets {
+		actualByID, scanned := t.actualResources(g)
+
+		for _, resource := range t.resources(state) {
+			for _, inst := range resource.Instances {
+				id := resource.CloudID(inst)
+				actual, ok := actualByID[id]
+				if !ok {
+					if id != "" && inScope(scanned, instanceLocation(inst)) {
+						if event := c.deleted(resource, inst, t.fields); event != nil {
+							events = append(events, event)
+						}
+					}
+					continue
+				}
+				if event := t.compare(c, resource, actual); event != nil {
+					events = append(events, event)
+				}
+			}
+		}
+	}
+
+	return events
+}
+
+// DetectUnmanaged はどの state にもない SkyGraph のリソースを DriftCreated として検出
+//
+// states はすべてのワークスペースの state で、1つでも欠けるとそのリソースが管理外に見えるため、
+// 全ワークスペースの state を読めた場合だけ呼ぶ。state がリソースを管理していない
+// アカウント・リージョンと、AWS が自動で作るリソース（default Security Group、
+// Auto Scaling のインスタンス）は対象外。
+func (c *Comparator) DetectUnmanaged(states []*terraform.State, g *graph.Graph) []*types.DriftEvent {
+	events := make([]*types.DriftEvent, 0)
+
+	managed := make(map[string]map[string]bool, len(targets))
+	var scope []location
+	for _, t := range targets {
+		managed[t.nodeType] = make(map[string]bool)
+		for _, state := range states {
+			for _, resource := range t.resources(state) {
+				for _, inst := range resource.Instances {
+					managed[t.nodeType][resource.CloudID(inst)] = true
+					if l := instanceLocation(inst); !inScope(scope, l) {
+						scope = append(scope, l)
+					}
+				}
+			}
+		}
+	}
+
+	for _, t := range targets {
+		for _, node := range g.Nodes {
+			if node.Type != t.nodeType || createdByAWS(node) {
+				continue
+			}
+			id, _ := node.Metadata[t.idKey].(string)
+			if id == "" || managed[t.nodeType][id] {
+				continue
+			}
+			if !inScope(scope, location{account: node.Account, region: node.Region}) {
+				continue
+			}
+
+			if event := c.created(node, attributes(node), id, t.fields); event != nil {
+				events = append(events, event)
+			}
+		}
+	}
+
+	return events
+}
+
+// attributes はノードのメタデータにタグを "tags" として加えた属性を返す
+func attributes(node graph.ResourceNode) map[string]interface{} {
+	actual := make(map[string]interface{}, len(node.Metadata)+1)
+	for k, v := range node.Metadata {
+		actual[k] = v
+	}
+	if node.Tags != nil {
+		actual["tags"] = node.Tags
+	}
+	return actual
+}
+
+// createdByAWS は AWS が自動で作成・管理するリソースかを判定
+func createdByAWS(node graph.ResourceNode) bool {
+	switch node.Type {
+	case "security_group":
+		name, _ := node.Metadata["group_name"].(string)
+		return name == "default"
+	case "ec2":
+		_, ok := node.Tags["aws:autoscaling:groupName"]
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,471 @@
+package drift
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ChangeType は差分の種類
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeDeleted  ChangeType = "deleted"
+	ChangeModified ChangeType = "modified"
+)
+
+// Change は1つのパスの差分
+type Change struct {
+	// Path は変化した値の位置（例: "ingress[2].cidr_blocks[0]", "tags.Env"）
+	// リストの index は before 側の位置（added の場合は after 側の位置）
+	Path string `json:"path"`
+
+	Type   ChangeType  `json:"type"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// ListKeyFunc はリストの要素を対応付けるキーを返す
+// 詳細なキーから順に返し、キーを決められない場合は空文字を返す
+type ListKeyFunc func(item map[string]interface{}) []string
+
+// Differ は属性を再帰的に比較してパスごとの差分を返す
+//
+// 値は意味で比較する（数値と数値文字列、真偽値と "true" / "false"、nil と空の値は等しい）。
+// 順序に意味のないリスト（集合）とキーで要素を対応付けるリストは属性名で登録する。
+// それ以外のリストは位置で比較する。
+type Differ struct {
+	sets   map[string]bool
+	keys   map[string]ListKeyFunc
+	values map[string]func(interface{}) interface{}
+}
+
+// NewDiffer は AWS リソースの既定のルールを持つ Differ を作成
+func NewDiffer() *Differ {
+	d := &Differ{
+		sets:   make(map[string]bool),
+		keys:   make(map[string]ListKeyFunc),
+		values: make(map[string]func(interface{}) interface{}),
+	}
+
+	d.SetUnordered(
+		"vpc_security_group_ids", "security_groups", "security_group_ids",
+		"cidr_blocks", "ipv6_cidr_blocks", "prefix_list_ids",
+		"subnet_ids", "availability_zones",
+	)
+
+	for _, name := range []string{"ingress", "egress", "ingress_rules", "egress_rules"} {
+		d.SetListKey(name, securityGroupRuleKey)
+	}
+	d.SetListKey("ebs_block_device", fieldKey("device_name"))
+	d.SetListKey("ephemeral_block_device", fieldKey("device_name"))
+	d.SetListKey("network_interface", fieldKey("device_index"))
+	d.SetListKey("lifecycle_rule", fieldKey("id"))
+	d.SetListKey("tag", fieldKey("key"))
+
+	d.values["protocol"] = func(v interface{}) interface{} {
+		return normalizeProtocol(fmt.Sprint(numberString(v)))
+	}
+
+	return d
+}
+
+// SetUnordered は属性を順序のないリストとして比較するよう登録
+func (d *Differ) SetUnordered(names ...string) {
+	for _, name := range names {
+		d.sets[name] = true
+	}
+}
+
+// SetListKey は属性のリストの要素をキーで対応付けるよう登録
+// キーで対応付けた要素は順序を無視して比較する
+func (d *Differ) SetListKey(name string, fn ListKeyFunc) {
+	d.keys[name] = fn
+}
+
+var defaultDiffer = NewDiffer()
+
+// Diff は既定の Differ で before と after を比較
+func Diff(before, after map[string]interface{}) []Change {
+	return defaultDiffer.Diff(before, after)
+}
+
+// Equal は既定の Differ で2つの値が意味的に等しいかを判定
+func Equal(a, b interface{}) bool {
+	return defaultDiffer.Equal(a, b)
+}
+
+// Diff は before と after を比較してパス順に並べた差分を返す
+func (d *Differ) Diff(before, after map[string]interface{}) []Change {
+	var changes []Change
+	d.diffMap("", toInterfaceMap(before), toInterfaceMap(after), &changes)
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// Equal は2つの値が意味的に等しいかを判定
+func (d *Differ) Equal(a, b interface{}) bool {
+	return d.equal("", a, b)
+}
+
+// DiffMap は差分を DriftEvent.Diff の形（パス → {type, before, after}）に変換
+func DiffMap(changes []Change) map[string]interface{} {
+	diff := make(map[string]interface{}, len(changes))
+	for _, change := range changes {
+		entry := map[string]interface{}{"type": string(change.Type)}
+		if change.Type != ChangeAdded {
+			entry["before"] = change.Before
+		}
+		if change.Type != ChangeDeleted {
+			entry["after"] = change.After
+		}
+		diff[change.Path] = entry
+	}
+	return diff
+}
+
+// equal は name の属性のルールで2つの値を比較
+func (d *Differ) equal(name string, a, b interface{}) bool {
+	var changes []Change
+	d.diffValue("", name, a, b, &changes)
+	return len(changes) == 0
+}
+
+// diffValue は path の値を比較して差分を追加
+func (d *Differ) diffValue(path, name string, a, b interface{}, changes *[]Change) {
+	a, b = d.normalizeValue(name, a), d.normalizeValue(name, b)
+
+	switch {
+	case isEmpty(a) && isEmpty(b):
+		return
+	case isEmpty(a):
+		*changes = append(*changes, Change{Path: path, Type: ChangeAdded, After: b})
+		return
+	case isEmpty(b):
+		*changes = append(*changes, Change{Path: path, Type: ChangeDeleted, Before: a})
+		return
+	}
+
+	aMap, aIsMap := a.(map[string]interface{})
+	bMap, bIsMap := b.(map[string]interface{})
+	if aIsMap && bIsMap {
+		d.diffMap(path, aMap, bMap, changes)
+		return
+	}
+
+	aList, aIsList := a.([]interface{})
+	bList, bIsList := b.([]interface{})
+	if aIsList && bIsList {
+		d.diffList(path, name, aList, bList, changes)
+		return
+	}
+
+	if aIsMap || bIsMap || aIsList || bIsList || !scalarEqual(a, b) {
+		*changes = append(*changes, Change{Path: path, Type: ChangeModified, Before: a, After: b})
+	}
+}
+
+// diffMap はキーごとに比較（片方にしかないキーは added / deleted）
+func (d *Differ) diffMap(path string, a, b map[string]interface{}, changes *[]Change) {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		d.diffValue(joinKey(path, key), key, a[key], b[key], changes)
+	}
+}
+
+// diffList はリストを比較
+// 集合やキーを登録した属性は要素を対応付けてから比較し、それ以外は位置で比較する
+func (d *Differ) diffList(path, name string, a, b []interface{}, changes *[]Change) {
+	keyFn, keyed := d.keys[name]
+	if !keyed && !d.sets[name] {
+		for i := 0; i < len(a) || i < len(b); i++ {
+			var av, bv interface{}
+			if i < len(a) {
+				av = a[i]
+			}
+			if i < len(b) {
+				bv = b[i]
+			}
+			d.diffValue(joinIndex(path, i), name, av, bv, changes)
+		}
+		return
+	}
+
+	matchedA := make([]bool, len(a))
+	matchedB := make([]bool, len(b))
+
+	// 1. 等しい要素を対応付ける（順序の違いは差分にしない）
+	for i := range a {
+		for j := range b {
+			if !matchedB[j] && d.equal(name, a[i], b[j]) {
+				matchedA[i], matchedB[j] = true, true
+				break
+			}
+		}
+	}
+
+	// 2. 残りの要素をキーで対応付けて中身を比較する（どちらかで重複するキーは使わない）
+	if keyed {
+		for tier := 0; ; tier++ {
+			aKeys, aMore := listKeys(keyFn, a, matchedA, tier)
+			bKeys, bMore := listKeys(keyFn, b, matchedB, tier)
+			if !aMore && !bMore {
+				break
+			}
+			for key, i := range aKeys {
+				j, ok := bKeys[key]
+				if !ok || i < 0 || j < 0 {
+					continue
+				}
+				matchedA[i], matchedB[j] = true, true
+				d.diffValue(joinIndex(path, i), name, a[i], b[j], changes)
+			}
+		}
+	}
+
+	// 3. 対応する要素がないものは削除・追加（同じ位置で入れ替わったものは変更）
+	for i := range a {
+		if matchedA[i] {
+			continue
+		}
+		if i < len(b) && !matchedB[i] {
+			matchedB[i] = true
+			*changes = append(*changes, Change{Path: joinIndex(path, i), Type: ChangeModified, Before: a[i], After: b[i]})
+			continue
+		}
+		*changes = append(*changes, Change{Path: joinIndex(path, i), Type: ChangeDeleted, Before: a[i]})
+	}
+	for j := range b {
+		if !matchedB[j] {
+			*changes = append(*changes, Change{Path: joinIndex(path, j), Type: ChangeAdded, After: b[j]})
+		}
+	}
+}
+
+// listKeys は対応付いていない要素の tier 番目のキーと index を返す（重複するキーは -1）
+// more はその tier のキーを持つ要素があったかどうか
+func listKeys(keyFn ListKeyFunc, items []interface{}, matched []bool, tier int) (map[string]int, bool) {
+	keys := make(map[string]int)
+	more := false
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		candidates := keyFn(m)
+		if tier >= len(candidates) {
+			continue
+		}
+		more = true
+		if matched[i] || candidates[tier] == "" {
+			continue
+		}
+		if _, dup := keys[candidates[tier]]; dup {
+			keys[candidates[tier]] = -1
+			continue
+		}
+		keys[candidates[tier]] = i
+	}
+	return keys, more
+}
+
+// securityGroupRuleKey は Security Group のルールのキー
+// プロトコル・ポート・相手が同じルール、次にプロトコル・ポートが同じルールを対応付ける
+func securityGroupRuleKey(rule map[string]interface{}) []string {
+	protocol := normalizeProtocol(fmt.Sprint(numberString(rule["protocol"])))
+	ports := fmt.Sprintf("%v-%v", numberString(rule["from_port"]), numberString(rule["to_port"]))
+	if protocol == "all" {
+		ports = "*"
+	}
+	base := protocol + ":" + ports
+
+	var peers []string
+	for _, key := range []string{"cidr_blocks", "ipv6_cidr_blocks", "prefix_list_ids", "security_groups"} {
+		for _, peer := range toInterfaceList(rule[key]) {
+			peers = append(peers, fmt.Sprint(peer))
+		}
+	}
+	if self, _ := rule["self"].(bool); self {
+		peers = append(peers, "self")
+	}
+	sort.Strings(peers)
+
+	return []string{base + ":" + strings.Join(peers, ","), base}
+}
+
+// fieldKey は1つのフィールドの値をキーにする
+func fieldKey(field string) ListKeyFunc {
+	return func(item map[string]interface{}) []string {
+		v, ok := item[field]
+		if !ok || v == nil {
+			return []string{""}
+		}
+		return []string{fmt.Sprint(numberString(v))}
+	}
+}
+
+// normalizeValue は型の違い（[]string と []interface{} など）を揃え、属性ごとの正規化を適用
+func (d *Differ) normalizeValue(name string, v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]string:
+		return toInterfaceMap(val)
+	case []string, []map[string]interface{}:
+		return toInterfaceList(val)
+	}
+	if fn, ok := d.values[name]; ok && !isEmpty(v) {
+		return fn(v)
+	}
+	return v
+}
+
+// isEmpty は nil・空文字・空のリスト・空のマップを判定
+func isEmpty(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return val == ""
+	case []interface{}:
+		return len(val) == 0
+	case map[string]interface{}:
+		return len(val) == 0
+	}
+	return false
+}
+
+// scalarEqual はスカラー値を比較
+// 数値と数値文字列、真偽値と "true" / "false" は等しいとみなす（文字列どうしはそのまま比較）
+func scalarEqual(a, b interface{}) bool {
+	as, aIsString := a.(string)
+	bs, bIsString := b.(string)
+	if aIsString && bIsString {
+		return as == bs
+	}
+
+	if af, ok := toFloat(a); ok {
+		bf, ok := toFloat(b)
+		return ok && af == bf
+	}
+
+	if ab, ok := toBool(a); ok {
+		bb, ok := toBool(b)
+		return ok && ab == bb
+	}
+
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// toFloat は数値または数値文字列を float64 に変換
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toBool は真偽値または "true" / "false" を bool に変換
+func toBool(v interface{}) (bool, bool) {
+	switch b := v.(type) {
+	case bool:
+		return b, true
+	case string:
+		parsed, err := strconv.ParseBool(b)
+		return parsed, err == nil
+	}
+	return false, false
+}
+
+// numberString は整数の float64 を小数点なしで表示できるよう揃える
+func numberString(v interface{}) interface{} {
+	if f, ok := toFloat(v); ok {
+		if _, isString := v.(string); !isString {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+	}
+	return v
+}
+
+// toInterfaceMap はマップを map[string]interface{} に揃える
+func toInterfaceMap(v interface{}) map[string]interface{} {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m
+	case map[string]string:
+		result := make(map[string]interface{}, len(m))
+		for key, value := range m {
+			result[key] = value
+		}
+		return result
+	}
+	return nil
+}
+
+// toInterfaceList はリストを []interface{} に揃える
+func toInterfaceList(v interface{}) []interface{} {
+	switch list := v.(type) {
+	case []interface{}:
+		return list
+	case []string:
+		result := make([]interface{}, len(list))
+		for i, item := range list {
+			result[i] = item
+		}
+		return result
+	case []map[string]interface{}:
+		result := make([]interface{}, len(list))
+		for i, item := range list {
+			result[i] = item
+		}
+		return result
+	}
+	return nil
+}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_\-]*$`)
+
+// joinKey はマップのキーをパスに追加（識別子でないキーは ["key"] で表す）
+func joinKey(path, key string) string {
+	if !identifierPattern.MatchString(key) {
+		return path + "[" + strconv.Quote(key) + "]"
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// joinIndex はリストの index をパスに追加
+func joinIndex(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i)
+}
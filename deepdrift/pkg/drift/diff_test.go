@@ -0,0 +1,177 @@
+package drift
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        interface{}
+		b        interface{}
+		expected bool
+	}{
+		{name: "equal strings", a: "hello", b: "hello", expected: true},
+		{name: "different strings", a: "hello", b: "world", expected: false},
+		{name: "equal numbers", a: 42, b: float64(42), expected: true},
+		{name: "different numbers", a: 42, b: 43, expected: false},
+		{name: "number and numeric string", a: float64(443), b: "443", expected: true},
+		{name: "numeric strings are compared as strings", a: "007", b: "7", expected: false},
+		{name: "bool and string", a: true, b: "true", expected: true},
+		{name: "nil and empty string", a: nil, b: "", expected: true},
+		{name: "nil and empty list", a: nil, b: []interface{}{}, expected: true},
+		{
+			name:     "map key order",
+			a:        map[string]interface{}{"a": "1", "b": "2"},
+			b:        map[string]string{"b": "2", "a": "1"},
+			expected: true,
+		},
+		{
+			name:     "different maps",
+			a:        map[string]interface{}{"key": "value1"},
+			b:        map[string]interface{}{"key": "value2"},
+			expected: false,
+		},
+		{
+			name:     "ordered list",
+			a:        []interface{}{"a", "b"},
+			b:        []interface{}{"b", "a"},
+			expected: false,
+		},
+		{
+			name:     "set order is ignored",
+			a:        map[string]interface{}{"cidr_blocks": []interface{}{"10.0.0.0/8", "0.0.0.0/0"}},
+			b:        map[string]interface{}{"cidr_blocks": []string{"0.0.0.0/0", "10.0.0.0/8"}},
+			expected: true,
+		},
+		{
+			name:     "protocol number and name",
+			a:        map[string]interface{}{"protocol": "-1"},
+			b:        map[string]interface{}{"protocol": "all"},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := Equal(tt.a, tt.b); result != tt.expected {
+				aJSON, _ := json.Marshal(tt.a)
+				bJSON, _ := json.Marshal(tt.b)
+				t.Errorf("Equal(%s, %s) = %v; want %v", aJSON, bJSON, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDiff_NestedPaths(t *testing.T) {
+	before := map[string]interface{}{
+		"instance_type": "t3.micro",
+		"tags":          map[string]interface{}{"Name": "web", "aws:backup:plan": "daily"},
+		"root_block_device": []interface{}{
+			map[string]interface{}{"volume_size": float64(8), "encrypted": false},
+		},
+		"user_data": "x",
+	}
+	after := map[string]interface{}{
+		"instance_type": "t3.micro",
+		"tags":          map[string]interface{}{"Name": "web", "aws:backup:plan": "weekly", "Env": "prod"},
+		"root_block_device": []interface{}{
+			map[string]interface{}{"volume_size": "8", "encrypted": true},
+		},
+		"monitoring": true,
+	}
+
+	changes := Diff(before, after)
+
+	expected := []Change{
+		{Path: "monitoring", Type: ChangeAdded, After: true},
+		{Path: "root_block_device[0].encrypted", Type: ChangeModified, Before: false, After: true},
+		{Path: "tags.Env", Type: ChangeAdded, After: "prod"},
+		{Path: `tags["aws:backup:plan"]`, Type: ChangeModified, Before: "daily", After: "weekly"},
+		{Path: "user_data", Type: ChangeDeleted, Before: "x"},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %+v", len(expected), changes)
+	}
+	for i, want := range expected {
+		if changes[i] != want {
+			t.Errorf("changes[%d] = %+v; want %+v", i, changes[i], want)
+		}
+	}
+}
+
+func TestDiff_SecurityGroupRules(t *testing.T) {
+	rule := func(protocol string, port float64, description string, cidrs ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"protocol": protocol, "from_port": port, "to_port": port,
+			"cidr_blocks": cidrs, "description": description, "self": false,
+		}
+	}
+
+	before := map[string]interface{}{
+		"ingress": []interface{}{
+			rule("tcp", 443, "https", "0.0.0.0/0"),
+			rule("tcp", 5432, "db", "10.0.1.0/24", "10.0.2.0/24"),
+			rule("tcp", 80, "http", "10.0.0.0/8"),
+			rule("tcp", 80, "http from vpn", "172.16.0.0/12"),
+		},
+	}
+	after := map[string]interface{}{
+		"ingress": []interface{}{
+			// 説明だけ変更（プロトコル・ポート・相手で対応付ける）
+			rule("tcp", 80, "web", "10.0.0.0/8"),
+			rule("tcp", 80, "http from vpn", "172.16.0.0/12"),
+			// CIDR を1つ追加（プロトコル・ポートで対応付ける）
+			rule("tcp", 5432, "db", "10.0.2.0/24", "10.0.1.0/24", "10.0.3.0/24"),
+			rule("6", 443, "https", "0.0.0.0/0"),
+			// 新しいルール
+			rule("tcp", 22, "ssh", "0.0.0.0/0"),
+		},
+	}
+
+	diff := DiffMap(Diff(before, after))
+
+	if len(diff) != 3 {
+		t.Fatalf("Expected 3 changes, got %v", diff)
+	}
+	assertChange(t, diff, "ingress[2].description", "modified", "http", "web")
+	assertChange(t, diff, "ingress[1].cidr_blocks[2]", "added", nil, "10.0.3.0/24")
+	if change, ok := diff["ingress[4]"].(map[string]interface{}); !ok || change["type"] != "added" {
+		t.Errorf("Expected the ssh rule to be added, got %v", diff)
+	}
+}
+
+func TestDiff_KeyedList(t *testing.T) {
+	before := map[string]interface{}{
+		"ebs_block_device": []interface{}{
+			map[string]interface{}{"device_name": "/dev/sdb", "volume_size": float64(10)},
+			map[string]interface{}{"device_name": "/dev/sdc", "volume_size": float64(20)},
+		},
+	}
+	after := map[string]interface{}{
+		"ebs_block_device": []interface{}{
+			map[string]interface{}{"device_name": "/dev/sdc", "volume_size": float64(50)},
+			map[string]interface{}{"device_name": "/dev/sdb", "volume_size": float64(10)},
+		},
+	}
+
+	diff := DiffMap(Diff(before, after))
+	if len(diff) != 1 {
+		t.Fatalf("Expected 1 change, got %v", diff)
+	}
+	// device_name で対応付けるため、順序の入れ替えは差分にならない
+	assertChange(t, diff, "ebs_block_device[1].volume_size", "modified", float64(20), float64(50))
+}
+
+func assertChange(t *testing.T, diff map[string]interface{}, path, changeType string, before, after interface{}) {
+	t.Helper()
+	change, ok := diff[path].(map[string]interface{})
+	if !ok {
+		t.Errorf("Expected change at %s, got %v", path, diff)
+		return
+	}
+	if change["type"] != changeType || change["before"] != before || change["after"] != after {
+		t.Errorf("Unexpected change at %s: %v", path, change)
+	}
+}
@@ -0,0 +1,239 @@
+package drift
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// ErrDriftNotFound は指定した ID の drift イベントがないことを表す
+var ErrDriftNotFound = errors.New("drift event not found")
+
+// Changes は1回の検出サイクルで変化した drift イベント
+type Changes struct {
+	// Created は新しく検出された drift（解決済みから再発したものを含む）
+	Created []*types.DriftEvent
+
+	// Updated は前回から続いている drift（LastSeen を更新したもの）
+	Updated []*types.DriftEvent
+
+	// Resolved は今回検出されなかった（解消された）drift
+	Resolved []*types.DriftEvent
+}
+
+// All は変化したすべてのイベントを返す
+func (c Changes) All() []*types.DriftEvent {
+	all := make([]*types.DriftEvent, 0, len(c.Created)+len(c.Updated)+len(c.Resolved))
+	all = append(all, c.Created...)
+	all = append(all, c.Updated...)
+	return append(all, c.Resolved...)
+}
+
+// IsEmpty は新しい drift も解消された drift もないかを判定
+func (c Changes) IsEmpty() bool {
+	return len(c.Created) == 0 && len(c.Resolved) == 0
+}
+
+// Reconcile は未解決の drift（open / acknowledged）と今回の検出結果を突き合わせる
+//
+// ID は drift の内容から決まるため、同じ ID のイベントは同じ drift が続いていることを表す。
+// 続いている drift は FirstSeen・Status（確認済みかどうか）を引き継いで LastSeen を更新し、
+// 検出されなかった drift は resolved にする。
+// known には今回の検出対象（ワークスペース）に含まれるイベントだけを渡すこと。
+func Reconcile(known, detected []*types.DriftEvent, now time.Time) Changes {
+	var changes Changes
+
+	knownByID := make(map[string]*types.DriftEvent, len(known))
+	for _, event := range known {
+		if event.Status != types.DriftResolved {
+			knownByID[event.ID] = event
+		}
+	}
+
+	seen := make(map[string]bool, len(detected))
+	for _, event := range detected {
+		if seen[event.ID] {
+			continue
+		}
+		seen[event.ID] = true
+
+		previous, ok := knownByID[event.ID]
+		if !ok {
+			created := *event
+			created.Status = types.DriftOpen
+			created.FirstSeen = now
+			created.LastSeen = now
+			created.ResolvedAt = nil
+			changes.Created = append(changes.Created, &created)
+			continue
+		}
+
+		updated := *event
+		updated.Timestamp = previous.Timestamp
+		updated.Status = previous.Status
+		updated.FirstSeen = previous.FirstSeen
+		updated.LastSeen = now
+		updated.AcknowledgedBy = previous.AcknowledgedBy
+		updated.AcknowledgedAt = previous.AcknowledgedAt
+		if updated.RootCause == nil {
+			updated.RootCause = previous.RootCause
+			updated.RootCauseCandidates = previous.RootCauseCandidates
+		}
+		changes.Updated = append(changes.Updated, &updated)
+	}
+
+	for _, event := range known {
+		if _, open := knownByID[event.ID]; !open || seen[event.ID] {
+			continue
+		}
+		resolved := *event
+		resolvedAt := now
+		resolved.Status = types.DriftResolved
+		resolved.ResolvedAt = &resolvedAt
+		changes.Resolved = append(changes.Resolved, &resolved)
+	}
+
+	return changes
+}
+
+// SetStatus は drift イベントの status を手動で変更する
+// acknowledged は未解決の drift にだけ設定でき、open に戻すと確認済み・解決済みの記録を消す
+func SetStatus(event *types.DriftEvent, status types.DriftStatus, user string, now time.Time) error {
+	if !status.IsValid() {
+		return fmt.Errorf("invalid status: %s (available: open, acknowledged, resolved)", status)
+	}
+
+	switch status {
+	case types.DriftAcknowledged:
+		if event.Status == types.DriftResolved {
+			return fmt.Errorf("drift %s is already resolved", event.ID)
+		}
+		event.AcknowledgedBy = user
+		event.AcknowledgedAt = &now
+
+	case types.DriftResolved:
+		if event.Status != types.DriftResolved {
+			event.ResolvedAt = &now
+		}
+
+	case types.DriftOpen:
+		event.AcknowledgedBy = ""
+		event.AcknowledgedAt = nil
+		event.ResolvedAt = nil
+	}
+
+	event.Status = status
+	return nil
+}
+
+// Tracker は検出サイクルをまたいで未解決の drift をメモリ上で追跡する（watch モード用）
+type Tracker struct {
+	mu   sync.Mutex
+	open map[string]*types.DriftEvent
+	now  func() time.Time
+}
+
+// NewTracker は新しい Tracker を作成
+func NewTracker() *Tracker {
+	return &Tracker{
+		open: make(map[string]*types.DriftEvent),
+		now:  time.Now,
+	}
+}
+
+// Observe は検出結果を反映して変化を返す
+// workspaces は今回検出できたワークスペース（nil の場合はすべて）。
+// 検出に失敗したワークスペースの drift は解消扱いにしない。
+func (t *Tracker) Observe(detected []*types.DriftEvent, workspaces []string) Changes {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var inScope map[string]bool
+	if workspaces != nil {
+		inScope = make(map[string]bool, len(workspaces))
+		for _, ws := range workspaces {
+			inScope[ws] = true
+		}
+	}
+
+	known := make([]*types.DriftEvent, 0, len(t.open))
+	for id, event := range t.open {
+		// 手動で解決済みにした drift は次のサイクルで忘れる（まだ残っていれば再検出される）
+		if event.Status == types.DriftResolved {
+			delete(t.open, id)
+			continue
+		}
+		if inScope == nil || inScope[event.Workspace] {
+			known = append(known, event)
+		}
+	}
+
+	changes := Reconcile(known, detected, t.now())
+	for _, event := range changes.Created {
+		t.open[event.ID] = event
+	}
+	for _, event := range changes.Updated {
+		t.open[event.ID] = event
+	}
+	for _, event := range changes.Resolved {
+		delete(t.open, event.ID)
+	}
+	return changes
+}
+
+// Get は ID の drift を返す（追跡していない場合は nil）
+func (t *Tracker) Get(id string) *types.DriftEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	event, ok := t.open[id]
+	if !ok {
+		return nil
+	}
+	copied := *event
+	return &copied
+}
+
+// SetStatus は追跡中の drift の status を手動で変更する
+func (t *Tracker) SetStatus(id string, status types.DriftStatus, user string) (*types.DriftEvent, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	event, ok := t.open[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDriftNotFound, id)
+	}
+
+	updated := *event
+	if err := SetStatus(&updated, status, user, t.now()); err != nil {
+		return nil, err
+	}
+	t.open[id] = &updated
+
+	copied := updated
+	return &copied, nil
+}
+
+// Open は未解決の drift を返す
+func (t *Tracker) Open() []*types.DriftEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	events := make([]*types.DriftEvent, 0, len(t.open))
+	for _, event := range t.open {
+		if event.Status != types.DriftResolved {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].FirstSeen.Equal(events[j].FirstSeen) {
+			return events[i].FirstSeen.Before(events[j].FirstSeen)
+		}
+		return events[i].ID < events[j].ID
+	})
+	return events
+}
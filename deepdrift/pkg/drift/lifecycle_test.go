@@ -0,0 +1,130 @@
+package drift
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+func newEvent(resourceID, workspace, after string) *types.DriftEvent {
+	event := &types.DriftEvent{
+		ResourceID:   resourceID,
+		ResourceType: "ec2",
+		Workspace:    workspace,
+		Type:         types.DriftModified,
+		Diff: map[string]interface{}{
+			"instance_type": map[string]interface{}{"type": "modified", "before": "t3.micro", "after": after},
+		},
+		Severity: types.SeverityMedium,
+	}
+	event.ID = types.DriftEventID(event)
+	return event
+}
+
+func TestReconcile(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(5 * time.Minute)
+
+	changes := Reconcile(nil, []*types.DriftEvent{newEvent("i-1", "app", "t3.large"), newEvent("i-2", "app", "t3.large")}, t0)
+	if len(changes.Created) != 2 || len(changes.Updated) != 0 || len(changes.Resolved) != 0 {
+		t.Fatalf("Unexpected changes: %+v", changes)
+	}
+	known := changes.Created
+	if known[0].Status != types.DriftOpen || !known[0].FirstSeen.Equal(t0) {
+		t.Errorf("Unexpected created event: %+v", known[0])
+	}
+
+	// i-1 は確認済み、i-2 は解消、i-3 は新規
+	known[0].Status = types.DriftAcknowledged
+	known[0].AcknowledgedBy = "alice"
+	changes = Reconcile(known, []*types.DriftEvent{newEvent("i-1", "app", "t3.large"), newEvent("i-3", "app", "t3.large")}, t1)
+
+	if len(changes.Updated) != 1 || len(changes.Created) != 1 || len(changes.Resolved) != 1 {
+		t.Fatalf("Unexpected changes: created=%d updated=%d resolved=%d",
+			len(changes.Created), len(changes.Updated), len(changes.Resolved))
+	}
+	updated := changes.Updated[0]
+	if updated.Status != types.DriftAcknowledged || updated.AcknowledgedBy != "alice" ||
+		!updated.FirstSeen.Equal(t0) || !updated.LastSeen.Equal(t1) {
+		t.Errorf("Expected persisting drift to keep its lifecycle, got %+v", updated)
+	}
+	resolved := changes.Resolved[0]
+	if resolved.ResourceID != "i-2" || resolved.Status != types.DriftResolved || resolved.ResolvedAt == nil || !resolved.ResolvedAt.Equal(t1) {
+		t.Errorf("Unexpected resolved drift: %+v", resolved)
+	}
+	if changes.Created[0].ResourceID != "i-3" {
+		t.Errorf("Unexpected created drift: %+v", changes.Created[0])
+	}
+}
+
+func TestReconcile_ChangedValueIsNewDrift(t *testing.T) {
+	now := time.Now()
+	known := Reconcile(nil, []*types.DriftEvent{newEvent("i-1", "app", "t3.large")}, now).Created
+
+	changes := Reconcile(known, []*types.DriftEvent{newEvent("i-1", "app", "t3.xlarge")}, now.Add(time.Minute))
+	if len(changes.Created) != 1 || len(changes.Resolved) != 1 {
+		t.Errorf("Expected the old drift to be resolved and a new one created, got %+v", changes)
+	}
+}
+
+func TestTracker_Observe(t *testing.T) {
+	tracker := NewTracker()
+
+	changes := tracker.Observe([]*types.DriftEvent{newEvent("i-1", "app", "t3.large"), newEvent("vpc-1", "network", "x")}, nil)
+	if len(changes.Created) != 2 || changes.IsEmpty() {
+		t.Fatalf("Expected 2 new drifts, got %+v", changes)
+	}
+
+	// 同じ drift は再通知しない
+	changes = tracker.Observe([]*types.DriftEvent{newEvent("i-1", "app", "t3.large"), newEvent("vpc-1", "network", "x")}, nil)
+	if !changes.IsEmpty() || len(changes.Updated) != 2 {
+		t.Errorf("Expected no new drifts, got %+v", changes)
+	}
+
+	// network の state を読めなかった場合、その drift は解消扱いにしない
+	changes = tracker.Observe([]*types.DriftEvent{}, []string{"app"})
+	if len(changes.Resolved) != 1 || changes.Resolved[0].Workspace != "app" {
+		t.Errorf("Expected only the app drift to be resolved, got %+v", changes.Resolved)
+	}
+	if open := tracker.Open(); len(open) != 1 || open[0].Workspace != "network" {
+		t.Errorf("Unexpected open drifts: %+v", open)
+	}
+}
+
+func TestTracker_SetStatus(t *testing.T) {
+	tracker := NewTracker()
+	event := tracker.Observe([]*types.DriftEvent{newEvent("i-1", "app", "t3.large")}, nil).Created[0]
+
+	acked, err := tracker.SetStatus(event.ID, types.DriftAcknowledged, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acked.Status != types.DriftAcknowledged || acked.AcknowledgedBy != "alice" || acked.AcknowledgedAt == nil {
+		t.Errorf("Unexpected acknowledged drift: %+v", acked)
+	}
+
+	// 確認済みの状態は次のサイクルでも引き継がれる
+	tracker.Observe([]*types.DriftEvent{newEvent("i-1", "app", "t3.large")}, nil)
+	if got := tracker.Get(event.ID); got.Status != types.DriftAcknowledged {
+		t.Errorf("Expected acknowledged status to persist, got %s", got.Status)
+	}
+
+	if _, err := tracker.SetStatus(event.ID, types.DriftResolved, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tracker.SetStatus(event.ID, types.DriftAcknowledged, "bob"); err == nil {
+		t.Error("Expected error when acknowledging a resolved drift")
+	}
+	if len(tracker.Open()) != 0 {
+		t.Error("Resolved drift should not be open")
+	}
+
+	if _, err := tracker.SetStatus("drift-unknown", types.DriftResolved, ""); !errors.Is(err, ErrDriftNotFound) {
+		t.Errorf("Expected ErrDriftNotFound, got %v", err)
+	}
+	if _, err := tracker.SetStatus(event.ID, types.DriftStatus("closed"), ""); err == nil {
+		t.Error("Expected error for invalid status")
+	}
+}
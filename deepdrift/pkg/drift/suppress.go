@@ -0,0 +1,435 @@
+package drift
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/glob"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// SuppressionReason は drift を抑制した理由
+type SuppressionReason string
+
+const (
+	// SuppressedResource はリソース ID が ignore_resources に一致した
+	SuppressedResource SuppressionReason = "resource"
+
+	// SuppressedTag はリソースのタグが exempt_tags に一致した
+	SuppressedTag SuppressionReason = "tag"
+
+	// SuppressedAttributes は変化した属性がすべて ignore_attributes に一致した
+	SuppressedAttributes SuppressionReason = "attributes"
+
+	// SuppressedSilence は期限付きの silence に一致した
+	SuppressedSilence SuppressionReason = "silence"
+)
+
+// Policy は drift の抑制ポリシー（YAML）
+//
+//	ignore_attributes:
+//	  autoscaling_group: [desired_capacity]
+//	  "*": ['tags["aws:backup:*"]', last_modified]
+//	ignore_resources:
+//	  - "aws:*:ec2:i-sandbox*"
+//	exempt_tags:
+//	  DriftIgnore: "true"
+//	silences:
+//	  - resources: ["aws:*:sg:sg-legacy*"]
+//	    owner: alice@example.com
+//	    reason: migrating to the new security group
+//	    expires: 2026-01-31T00:00:00Z
+type Policy struct {
+	// IgnoreAttributes はリソースタイプ（"*" はすべて）ごとに無視する属性のパス
+	// パスは Diff のキーと同じ形で、"*" を使える。パスに一致すると、その下の値もすべて無視する
+	IgnoreAttributes map[string][]string `yaml:"ignore_attributes"`
+
+	// IgnoreResources は drift を無視するリソース ID の glob
+	IgnoreResources []string `yaml:"ignore_resources"`
+
+	// ExemptTags はこのタグを持つリソースの drift を無視する（値が "*" の場合はキーだけで判定）
+	ExemptTags map[string]string `yaml:"exempt_tags"`
+
+	// Silences は期限付きの抑制
+	Silences []Silence `yaml:"silences"`
+
+	ignoreAttributes map[string][]*regexp.Regexp
+	ignoreResources  []*regexp.Regexp
+}
+
+// Silence は担当者と期限を持つ一時的な抑制
+// 条件を省略した項目はすべてに一致し、Attributes を省略した場合はイベント全体を抑制する
+type Silence struct {
+	Resources     []string  `yaml:"resources"`
+	ResourceTypes []string  `yaml:"resource_types"`
+	Attributes    []string  `yaml:"attributes"`
+	Owner         string    `yaml:"owner"`
+	Reason        string    `yaml:"reason"`
+	Expires       time.Time `yaml:"expires"`
+
+	resources  []*regexp.Regexp
+	attributes []*regexp.Regexp
+}
+
+// LoadPolicy は抑制ポリシーのファイル (YAML) を読み込む
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suppression policy: %w", err)
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy は抑制ポリシー (YAML) をパース
+// silence には担当者 (owner) と期限 (expires) が必須
+func ParsePolicy(data []byte) (*Policy, error) {
+	var policy Policy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse suppression policy: %w", err)
+	}
+
+	policy.ignoreAttributes = make(map[string][]*regexp.Regexp, len(policy.IgnoreAttributes))
+	for resourceType, paths := range policy.IgnoreAttributes {
+		policy.ignoreAttributes[resourceType] = compilePaths(paths)
+	}
+	policy.ignoreResources = compileGlobs(policy.IgnoreResources)
+
+	for i := range policy.Silences {
+		silence := &policy.Silences[i]
+		if silence.Owner == "" {
+			return nil, fmt.Errorf("silence %d: owner is required", i)
+		}
+		if silence.Expires.IsZero() {
+			return nil, fmt.Errorf("silence %d: expires is required", i)
+		}
+		silence.resources = compileGlobs(silence.Resources)
+		silence.attributes = compilePaths(silence.Attributes)
+	}
+
+	return &policy, nil
+}
+
+// Suppression は抑制した drift の記録（監査用）
+type Suppression struct {
+	EventID          string            `json:"event_id"`
+	ResourceID       string            `json:"resource_id"`
+	ResourceType     string            `json:"resource_type"`
+	TerraformAddress string            `json:"terraform_address,omitempty"`
+	Reason           SuppressionReason `json:"reason"`
+
+	// Rule は一致したルール（glob、タグ、silence の理由など）
+	Rule string `json:"rule"`
+
+	// Owner・Expires は silence の担当者と期限
+	Owner   string     `json:"owner,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+
+	// Paths は無視した Diff のパス
+	Paths []string `json:"paths,omitempty"`
+
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// SuppressionStats は抑制した drift の件数（同じ drift は1件として数える）
+type SuppressionStats struct {
+	Total    int                       `json:"total"`
+	ByReason map[SuppressionReason]int `json:"by_reason"`
+}
+
+// Suppressor は検出した drift にポリシーを適用し、抑制した drift を記録する
+// ポリシーが nil の場合は何も抑制しない
+type Suppressor struct {
+	policy *Policy
+	now    func() time.Time
+
+	mu      sync.Mutex
+	pending []Suppression
+
+	// seen は理由ごとに抑制した drift と最後に抑制した時刻（Prune で古いものを除く）
+	seen map[SuppressionReason]map[string]time.Time
+}
+
+// NewSuppressor は新しい Suppressor を作成
+func NewSuppressor(policy *Policy) *Suppressor {
+	return &Suppressor{
+		policy: policy,
+		now:    time.Now,
+		seen:   make(map[SuppressionReason]map[string]time.Time),
+	}
+}
+
+// Filter は抑制されなかった drift を返す
+func (s *Suppressor) Filter(events []*types.DriftEvent) []*types.DriftEvent {
+	kept := make([]*types.DriftEvent, 0, len(events))
+	for _, event := range events {
+		if event = s.Apply(event); event != nil {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
+
+// Apply は1つの drift にポリシーを適用する
+// drift 全体を抑制した場合は nil を返す。一部の属性だけを無視した場合は、
+// 残りの差分で ID を計算し直したイベントを返す
+func (s *Suppressor) Apply(event *types.DriftEvent) *types.DriftEvent {
+	if s == nil || s.policy == nil || event == nil {
+		return event
+	}
+
+	now := s.now()
+	p := s.policy
+
+	if glob := matchAny(p.ignoreResources, p.IgnoreResources, event.ResourceID); glob != "" {
+		s.record(event, SuppressedResource, glob, nil, nil, now)
+		return nil
+	}
+
+	if tag := p.exemptTag(event); tag != "" {
+		s.record(event, SuppressedTag, tag, nil, nil, now)
+		return nil
+	}
+
+	// 期限切れの silence は使わない
+	var silences []*Silence
+	for i := range p.Silences {
+		silence := &p.Silences[i]
+		if !now.Before(silence.Expires) || !silence.matches(event) {
+			continue
+		}
+		if len(silence.attributes) == 0 {
+			s.record(event, SuppressedSilence, silence.rule(), nil, silence, now)
+			return nil
+		}
+		silences = append(silences, silence)
+	}
+
+	// 属性の無視は変更 (modified) だけに適用し、作成・削除は常に報告する
+	if event.Type != types.DriftModified || len(event.Diff) == 0 {
+		return event
+	}
+
+	patterns := append(append([]*regexp.Regexp{}, p.ignoreAttributes["*"]...), p.ignoreAttributes[event.ResourceType]...)
+	diff := make(map[string]interface{}, len(event.Diff))
+	var ignored []string
+	var silencedBy *Silence
+	for path, change := range event.Diff {
+		if matchPath(patterns, path) {
+			ignored = append(ignored, path)
+			continue
+		}
+		if silence := silenceFor(silences, path); silence != nil {
+			ignored = append(ignored, path)
+			silencedBy = silence
+			continue
+		}
+		diff[path] = change
+	}
+
+	if len(ignored) == 0 {
+		return event
+	}
+	sort.Strings(ignored)
+
+	if len(diff) == 0 {
+		if silencedBy != nil {
+			s.record(event, SuppressedSilence, silencedBy.rule(), ignored, silencedBy, now)
+		} else {
+			s.record(event, SuppressedAttributes, strings.Join(ignored, ","), ignored, nil, now)
+		}
+		return nil
+	}
+
+	trimmed := *event
+	trimmed.Diff = diff
+	trimmed.ID = types.DriftEventID(&trimmed)
+	return &trimmed
+}
+
+// Drain は前回の Drain 以降に抑制した drift を返す（保存用）
+func (s *Suppressor) Drain() []Suppression {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := s.pending
+	s.pending = nil
+	return pending
+}
+
+// Stats は抑制した drift の件数を返す
+func (s *Suppressor) Stats() SuppressionStats {
+	stats := SuppressionStats{ByReason: make(map[SuppressionReason]int)}
+	if s == nil {
+		return stats
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make(map[string]bool)
+	for reason, seen := range s.seen {
+		stats.ByReason[reason] = len(seen)
+		for id := range seen {
+			ids[id] = true
+		}
+	}
+	stats.Total = len(ids)
+	return stats
+}
+
+// Prune は before より後に抑制していない drift を Stats から除く
+// すべてのワークスペースを検出したサイクルの開始時刻で呼ぶと、解消した drift や
+// 検出されなくなった drift がいつまでも数えられ（メモリに残り）続けない
+func (s *Suppressor) Prune(before time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for reason, seen := range s.seen {
+		for id, last := range seen {
+			if last.Before(before) {
+				delete(seen, id)
+			}
+		}
+		if len(seen) == 0 {
+			delete(s.seen, reason)
+		}
+	}
+}
+
+func (s *Suppressor) record(event *types.DriftEvent, reason SuppressionReason, rule string, paths []string, silence *Silence, now time.Time) {
+	suppression := Suppression{
+		EventID:          event.ID,
+		ResourceID:       event.ResourceID,
+		ResourceType:     event.ResourceType,
+		TerraformAddress: event.TerraformAddress,
+		Reason:           reason,
+		Rule:             rule,
+		Paths:            paths,
+		Timestamp:        now,
+	}
+	if silence != nil {
+		expires := silence.Expires
+		suppression.Owner = silence.Owner
+		suppression.Expires = &expires
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append(s.pending, suppression)
+	if s.seen[reason] == nil {
+		s.seen[reason] = make(map[string]time.Time)
+	}
+	s.seen[reason][event.ID] = now
+}
+
+// exemptTag は drift のリソースが持つ除外タグを "key=value" で返す
+func (p *Policy) exemptTag(event *types.DriftEvent) string {
+	if len(p.ExemptTags) == 0 {
+		return ""
+	}
+
+	tags := event.ResourceTags()
+	keys := make([]string, 0, len(p.ExemptTags))
+	for key := range p.ExemptTags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		want := p.ExemptTags[key]
+		value, ok := tags[key]
+		if ok && (want == "*" || value == want) {
+			return key + "=" + value
+		}
+	}
+	return ""
+}
+
+// matches は silence の条件（リソース ID、リソースタイプ）に一致するかを判定
+func (s *Silence) matches(event *types.DriftEvent) bool {
+	if len(s.resources) > 0 && matchAny(s.resources, s.Resources, event.ResourceID) == "" {
+		return false
+	}
+	if len(s.ResourceTypes) > 0 {
+		for _, resourceType := range s.ResourceTypes {
+			if resourceType == event.ResourceType {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// rule は記録用の silence の説明
+func (s *Silence) rule() string {
+	if s.Reason != "" {
+		return s.Reason
+	}
+	return "silenced by " + s.Owner
+}
+
+// silenceFor は path を抑制する silence を返す
+func silenceFor(silences []*Silence, path string) *Silence {
+	for _, silence := range silences {
+		if matchPath(silence.attributes, path) {
+			return silence
+		}
+	}
+	return nil
+}
+
+// matchAny は value に一致する glob を返す（一致しない場合は空文字）
+func matchAny(patterns []*regexp.Regexp, globs []string, value string) string {
+	for i, pattern := range patterns {
+		if pattern.MatchString(value) {
+			return globs[i]
+		}
+	}
+	return ""
+}
+
+// matchPath は path がいずれかのパスのパターン、またはその下の値に一致するかを判定
+func matchPath(patterns []*regexp.Regexp, path string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGlobs は glob（"*" は任意の文字列、"?" は任意の1文字）を正規表現に変換
+func compileGlobs(globs []string) []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(globs))
+	for i, pattern := range globs {
+		patterns[i] = glob.Compile(pattern)
+	}
+	return patterns
+}
+
+// compilePaths はパスの glob を、その下の値（".key" や "[0]" が続くパス）にも一致する正規表現に変換
+func compilePaths(paths []string) []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(paths))
+	for i, path := range paths {
+		patterns[i] = glob.CompilePath(path)
+	}
+	return patterns
+}
@@ -0,0 +1,231 @@
+package drift
+
+import (
+	"testing"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+const testPolicy = `
+ignore_attributes:
+  autoscaling_group: [desired_capacity]
+  "*": ['tags["aws:backup:*"]', last_modified]
+ignore_resources:
+  - "aws:*:ec2:i-sandbox*"
+exempt_tags:
+  DriftIgnore: "*"
+  Environment: sandbox
+silences:
+  - resources: ["aws:*:sg:sg-legacy*"]
+    owner: alice@example.com
+    reason: migrating to the new security group
+    expires: 2025-02-01T00:00:00Z
+  - resource_types: [ec2]
+    attributes: [instance_type]
+    owner: bob@example.com
+    expires: 2025-02-01T00:00:00Z
+  - resource_types: [s3]
+    owner: carol@example.com
+    expires: 2024-12-01T00:00:00Z
+`
+
+func newSuppressor(t *testing.T, now time.Time) *Suppressor {
+	t.Helper()
+	policy, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSuppressor(policy)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func modifiedEvent(resourceID, resourceType string, after map[string]interface{}, paths ...string) *types.DriftEvent {
+	diff := make(map[string]interface{}, len(paths))
+	for _, path := range paths {
+		diff[path] = map[string]interface{}{"type": "modified", "before": "a", "after": "b"}
+	}
+	event := &types.DriftEvent{
+		ResourceID:   resourceID,
+		ResourceType: resourceType,
+		Type:         types.DriftModified,
+		After:        after,
+		Diff:         diff,
+	}
+	event.ID = types.DriftEventID(event)
+	return event
+}
+
+func TestSuppressor_Apply(t *testing.T) {
+	now := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		event  *types.DriftEvent
+		reason SuppressionReason
+		paths  []string // 残る差分（抑制されない場合）
+	}{
+		{
+			name:   "resource glob",
+			event:  modifiedEvent("aws:111111111111:ec2:i-sandbox-1", "ec2", nil, "ami"),
+			reason: SuppressedResource,
+		},
+		{
+			name:   "exempt tag with any value",
+			event:  modifiedEvent("aws:ec2:i-1", "ec2", map[string]interface{}{"tags": map[string]interface{}{"DriftIgnore": "yes"}}, "ami"),
+			reason: SuppressedTag,
+		},
+		{
+			name:  "exempt tag with another value",
+			event: modifiedEvent("aws:ec2:i-1", "ec2", map[string]interface{}{"tags": map[string]string{"Environment": "prod"}}, "ami"),
+			paths: []string{"ami"},
+		},
+		{
+			name:   "all changed attributes ignored",
+			event:  modifiedEvent("aws:asg:web", "autoscaling_group", nil, "desired_capacity", `tags["aws:backup:plan"]`),
+			reason: SuppressedAttributes,
+		},
+		{
+			name:  "ignored attributes are removed from the diff",
+			event: modifiedEvent("aws:asg:web", "autoscaling_group", nil, "desired_capacity", "max_size", "last_modified.time"),
+			paths: []string{"max_size"},
+		},
+		{
+			name:  "attribute ignore is per resource type",
+			event: modifiedEvent("aws:asg:web", "ecs_service", nil, "desired_capacity"),
+			paths: []string{"desired_capacity"},
+		},
+		{
+			name:   "silence",
+			event:  modifiedEvent("aws:111111111111:sg:sg-legacy-1", "security_group", nil, "ingress[0]"),
+			reason: SuppressedSilence,
+		},
+		{
+			name:   "attribute silence",
+			event:  modifiedEvent("aws:ec2:i-1", "ec2", nil, "instance_type"),
+			reason: SuppressedSilence,
+		},
+		{
+			name:  "attribute silence keeps other attributes",
+			event: modifiedEvent("aws:ec2:i-1", "ec2", nil, "instance_type", "ami"),
+			paths: []string{"ami"},
+		},
+		{
+			name:  "expired silence",
+			event: modifiedEvent("aws:s3:logs", "s3", nil, "versioning"),
+			paths: []string{"versioning"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSuppressor(t, now)
+			kept := s.Apply(tt.event)
+			suppressed := s.Drain()
+
+			if tt.reason != "" {
+				if kept != nil {
+					t.Fatalf("Expected drift to be suppressed, got %v", kept.Diff)
+				}
+				if len(suppressed) != 1 || suppressed[0].Reason != tt.reason || suppressed[0].EventID != tt.event.ID {
+					t.Errorf("Unexpected suppression: %+v", suppressed)
+				}
+				return
+			}
+
+			if kept == nil {
+				t.Fatalf("Expected drift to be kept, got %+v", suppressed)
+			}
+			if len(suppressed) != 0 {
+				t.Errorf("Unexpected suppression: %+v", suppressed)
+			}
+			if len(kept.Diff) != len(tt.paths) {
+				t.Fatalf("Expected diff %v, got %v", tt.paths, kept.Diff)
+			}
+			for _, path := range tt.paths {
+				if _, ok := kept.Diff[path]; !ok {
+					t.Errorf("Expected %s in diff, got %v", path, kept.Diff)
+				}
+			}
+			if len(kept.Diff) != len(tt.event.Diff) && kept.ID == tt.event.ID {
+				t.Error("Expected the ID to be recalculated from the remaining diff")
+			}
+		})
+	}
+}
+
+func TestSuppressor_DeletedIsNotIgnoredByAttributes(t *testing.T) {
+	s := newSuppressor(t, time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC))
+
+	event := modifiedEvent("aws:asg:web", "autoscaling_group", nil, "desired_capacity")
+	event.Type = types.DriftDeleted
+	if s.Apply(event) == nil {
+		t.Error("Deleted resources should not be suppressed by attribute ignores")
+	}
+}
+
+func TestSuppressor_Stats(t *testing.T) {
+	s := newSuppressor(t, time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC))
+
+	events := []*types.DriftEvent{
+		modifiedEvent("aws:111111111111:ec2:i-sandbox-1", "ec2", nil, "ami"),
+		modifiedEvent("aws:ec2:i-1", "ec2", nil, "instance_type"),
+		modifiedEvent("aws:ec2:i-2", "ec2", nil, "ami"),
+	}
+
+	// 同じ drift は何度抑制しても1件として数える
+	for i := 0; i < 3; i++ {
+		if kept := s.Filter(events); len(kept) != 1 || kept[0].ResourceID != "aws:ec2:i-2" {
+			t.Fatalf("Unexpected kept drifts: %+v", kept)
+		}
+	}
+
+	if pending := s.Drain(); len(pending) != 6 {
+		t.Errorf("Expected 6 recorded suppressions, got %d", len(pending))
+	}
+	if pending := s.Drain(); len(pending) != 0 {
+		t.Errorf("Expected Drain to clear suppressions, got %d", len(pending))
+	}
+
+	stats := s.Stats()
+	if stats.Total != 2 || stats.ByReason[SuppressedResource] != 1 || stats.ByReason[SuppressedSilence] != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// 次のサイクルで抑制されなかった drift（解消した drift）は Prune で除く
+	next := time.Date(2025, 1, 15, 0, 5, 0, 0, time.UTC)
+	s.now = func() time.Time { return next }
+	s.Filter(events[:1])
+	s.Prune(next)
+	if stats := s.Stats(); stats.Total != 1 || stats.ByReason[SuppressedResource] != 1 || len(stats.ByReason) != 1 {
+		t.Errorf("Unexpected stats after Prune: %+v", stats)
+	}
+}
+
+func TestParsePolicy_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"missing owner":   "silences:\n  - resources: ['*']\n    expires: 2025-01-01T00:00:00Z\n",
+		"missing expires": "silences:\n  - resources: ['*']\n    owner: alice\n",
+		"invalid yaml":    "ignore_resources: {",
+		"unknown field":   "ignore_resource: ['*']\n",
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParsePolicy([]byte(data)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestComparator_Suppressor(t *testing.T) {
+	resource := parseResource(t, ec2Resource)
+	actual := map[string]interface{}{"instance_id": "i-1", "instance_type": "t3.large", "ami_id": "ami-1"}
+
+	comparator := NewComparator()
+	comparator.SetSuppressor(newSuppressor(t, time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)))
+	if event := comparator.CompareEC2(resource, actual); event != nil {
+		t.Errorf("Expected instance_type drift to be silenced, got %v", event.Diff)
+	}
+}
@@ -0,0 +1,25 @@
+// Package glob は設定ファイルのリソース名や属性パスの glob を正規表現に変換する
+// （"*" は任意の文字列、"?" は任意の1文字）
+package glob
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Compile は glob 全体に一致する正規表現を返す
+func Compile(glob string) *regexp.Regexp {
+	return regexp.MustCompile("^" + pattern(glob) + "$")
+}
+
+// CompilePath は属性パスの glob を、その下の値（".key" や "[0]" が続くパス）にも一致する正規表現に変換
+func CompilePath(path string) *regexp.Regexp {
+	return regexp.MustCompile("^" + pattern(path) + `($|[.\[])`)
+}
+
+// pattern は glob を正規表現の文字列に変換
+func pattern(glob string) string {
+	pattern := regexp.QuoteMeta(glob)
+	pattern = strings.ReplaceAll(pattern, `\*`, ".*")
+	return strings.ReplaceAll(pattern, `\?`, ".")
+}
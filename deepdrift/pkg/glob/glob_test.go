@@ -0,0 +1,41 @@
+package glob
+
+import "testing"
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		glob  string
+		value string
+		want  bool
+	}{
+		{"aws_instance.*", "aws_instance.web", true},
+		{"aws_instance.*", "module.aws_instance.web", false},
+		{"i-?", "i-1", true},
+		{"i-?", "i-12", false},
+		{"a.b", "axb", false},
+	}
+	for _, tt := range tests {
+		if got := Compile(tt.glob).MatchString(tt.value); got != tt.want {
+			t.Errorf("Compile(%q).MatchString(%q) = %v, want %v", tt.glob, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCompilePath(t *testing.T) {
+	tests := []struct {
+		path  string
+		value string
+		want  bool
+	}{
+		{"tags", "tags", true},
+		{"tags", "tags.Owner", true},
+		{"ingress", "ingress[0].cidr_blocks", true},
+		{"tags", "tags_all", false},
+		{"tags.*", "tags.Owner", true},
+	}
+	for _, tt := range tests {
+		if got := CompilePath(tt.path).MatchString(tt.value); got != tt.want {
+			t.Errorf("CompilePath(%q).MatchString(%q) = %v, want %v", tt.path, tt.value, got, tt.want)
+		}
+	}
+}
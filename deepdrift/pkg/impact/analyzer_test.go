@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+	"github.com/higakikeita/airdig/skygraph/pkg/builder"
 	"github.com/higakikeita/airdig/skygraph/pkg/graph"
 )
 
@@ -123,23 +124,23 @@ func createTestGraph() *graph.Graph {
 	// VPC -> Security Group (ownership)
 	g.AddEdge(graph.Edge{From: vpc.ID, To: sg.ID, Type: "ownership"})
 
-	// EC2 -> Subnet (network)
-	g.AddEdge(graph.Edge{From: ec2_1.ID, To: subnet1.ID, Type: "network"})
-	g.AddEdge(graph.Edge{From: ec2_2.ID, To: subnet1.ID, Type: "network"})
+	// Subnet -> EC2 (network)
+	g.AddEdge(graph.Edge{From: subnet1.ID, To: ec2_1.ID, Type: "network"})
+	g.AddEdge(graph.Edge{From: subnet1.ID, To: ec2_2.ID, Type: "network"})
 
-	// EC2 -> Security Group (network)
-	g.AddEdge(graph.Edge{From: ec2_1.ID, To: sg.ID, Type: "network"})
-	g.AddEdge(graph.Edge{From: ec2_2.ID, To: sg.ID, Type: "network"})
+	// Security Group -> EC2 (network)
+	g.AddEdge(graph.Edge{From: sg.ID, To: ec2_1.ID, Type: "network"})
+	g.AddEdge(graph.Edge{From: sg.ID, To: ec2_2.ID, Type: "network"})
 
 	// EC2 -> RDS (dependency)
 	g.AddEdge(graph.Edge{From: ec2_1.ID, To: rds.ID, Type: "dependency"})
 	g.AddEdge(graph.Edge{From: ec2_2.ID, To: rds.ID, Type: "dependency"})
 
-	// RDS -> Subnet (network)
-	g.AddEdge(graph.Edge{From: rds.ID, To: subnet2.ID, Type: "network"})
+	// Subnet -> RDS (network)
+	g.AddEdge(graph.Edge{From: subnet2.ID, To: rds.ID, Type: "network"})
 
-	// Lambda -> Subnet (network)
-	g.AddEdge(graph.Edge{From: lambda.ID, To: subnet2.ID, Type: "network"})
+	// Subnet -> Lambda (network)
+	g.AddEdge(graph.Edge{From: subnet2.ID, To: lambda.ID, Type: "network"})
 
 	// Lambda -> RDS (dependency)
 	g.AddEdge(graph.Edge{From: lambda.ID, To: rds.ID, Type: "dependency"})
@@ -165,9 +166,14 @@ func TestAnalyzer_AnalyzeImpact_DeletedSecurityGroup(t *testing.T) {
 		t.Fatalf("AnalyzeImpact failed: %v", err)
 	}
 
-	// セキュリティグループが削除された場合、VPC、EC2、Subnet が影響を受ける
-	if result.AffectedResourceCount < 3 {
-		t.Errorf("Expected at least 3 affected resources, got %d", result.AffectedResourceCount)
+	// セキュリティグループが削除された場合、接続している EC2 が影響を受ける（所有者の VPC には伝わらない）
+	if result.AffectedResourceCount != 2 {
+		t.Errorf("Expected 2 affected resources, got %+v", result.AffectedResources)
+	}
+	for _, resource := range result.AffectedResources {
+		if resource.ResourceType != "ec2" || resource.RelationType != "network" || resource.Distance != 1 {
+			t.Errorf("Unexpected affected resource: %+v", resource)
+		}
 	}
 
 	// セキュリティグループの削除は critical
@@ -212,9 +218,9 @@ func TestAnalyzer_AnalyzeImpact_ModifiedEC2(t *testing.T) {
 		t.Fatalf("AnalyzeImpact failed: %v", err)
 	}
 
-	// EC2 の変更は Subnet、SG、RDS に影響
-	if result.AffectedResourceCount < 3 {
-		t.Errorf("Expected at least 3 affected resources, got %d", result.AffectedResourceCount)
+	// インスタンスタイプの変更は依存先の RDS や接続先の Subnet、SG には伝わらない
+	if result.AffectedResourceCount != 0 {
+		t.Errorf("Expected no affected resources, got %+v", result.AffectedResources)
 	}
 
 	// EC2 の変更は medium または high (影響を受けるリソースに security_group が含まれる場合は high)
@@ -361,8 +367,8 @@ func TestAnalyzer_SeverityEscalation(t *testing.T) {
 
 		// Security Group に接続
 		g.AddEdge(graph.Edge{
-			From: ec2.ID,
-			To:   "aws:sg:sg-789",
+			From: "aws:sg:sg-789",
+			To:   ec2.ID,
 			Type: "network",
 		})
 	}
@@ -404,8 +410,8 @@ func TestAnalyzer_BlastRadiusCalculation(t *testing.T) {
 
 	event := &types.DriftEvent{
 		ID:           "drift-006",
-		ResourceID:   "aws:lambda:func-456",
-		ResourceType: "lambda",
+		ResourceID:   "aws:vpc:vpc-123",
+		ResourceType: "vpc",
 		Type:         types.DriftModified,
 		Timestamp:    time.Now(),
 		Severity:     types.SeverityLow,
@@ -416,16 +422,219 @@ func TestAnalyzer_BlastRadiusCalculation(t *testing.T) {
 		t.Fatalf("AnalyzeImpact failed: %v", err)
 	}
 
-	// Lambda -> Subnet (1 hop), RDS (1 hop), VPC (2 hops), etc.
-	if result.BlastRadius < 1 {
-		t.Errorf("Expected blast radius >= 1, got %d", result.BlastRadius)
+	// VPC -> Subnet, SG (1 hop) -> EC2, RDS, Lambda (2 hops)
+	if result.BlastRadius != 2 || result.AffectedResourceCount != 7 {
+		t.Errorf("Expected 7 resources within 2 hops, got %d within %d", result.AffectedResourceCount, result.BlastRadius)
 	}
 
-	// Blast radius は最大 3 まで
-	if result.BlastRadius > 3 {
-		t.Errorf("Expected blast radius <= 3, got %d", result.BlastRadius)
+	// 最大ホップ数を超えては探索しない
+	analyzer.SetMaxDepth(1)
+	result, err = analyzer.AnalyzeImpact(event)
+	if err != nil {
+		t.Fatalf("AnalyzeImpact failed: %v", err)
+	}
+	if result.BlastRadius != 1 || result.AffectedResourceCount != 3 {
+		t.Errorf("Expected 3 resources within 1 hop, got %d within %d", result.AffectedResourceCount, result.BlastRadius)
+	}
+
+	// 上限を超える値は MaxDepthLimit になる
+	analyzer.SetMaxDepth(1000)
+	if analyzer.maxDepth != MaxDepthLimit {
+		t.Errorf("Expected max depth %d, got %d", MaxDepthLimit, analyzer.maxDepth)
 	}
 
 	t.Logf("Blast radius: %d hops", result.BlastRadius)
 	t.Logf("Affected resources: %d", result.AffectedResourceCount)
 }
+
+func TestAnalyzer_DirectionalPropagation(t *testing.T) {
+	g := createTestGraph()
+	analyzer := NewAnalyzer(g)
+
+	// Subnet を削除しても VPC 経由で隣の Subnet には伝わらない
+	result, err := analyzer.AnalyzeImpact(&types.DriftEvent{
+		ID:           "drift-007",
+		ResourceID:   "aws:subnet:subnet-222",
+		ResourceType: "subnet",
+		Type:         types.DriftDeleted,
+	})
+	if err != nil {
+		t.Fatalf("AnalyzeImpact failed: %v", err)
+	}
+
+	byID := make(map[string]types.AffectedResource)
+	for _, resource := range result.AffectedResources {
+		byID[resource.ResourceID] = resource
+	}
+	if _, ok := byID["aws:subnet:subnet-111"]; ok {
+		t.Error("Expected the sibling subnet not to be affected")
+	}
+	if _, ok := byID["aws:vpc:vpc-123"]; ok {
+		t.Error("Expected the parent VPC not to be affected")
+	}
+
+	// Subnet -> RDS (network 0.7) -> EC2 (dependency 0.8)
+	ec2, ok := byID["aws:ec2:i-111"]
+	if !ok {
+		t.Fatalf("Expected EC2 depending on the RDS to be affected, got %+v", result.AffectedResources)
+	}
+	if ec2.Distance != 2 || ec2.ImpactScore != 0.56 || ec2.RelationType != "dependency" {
+		t.Errorf("Unexpected affected resource: %+v", ec2)
+	}
+	if len(ec2.PropagationPath) != 2 || ec2.PropagationPath[0].To != "aws:rds:db-123" || ec2.PropagationPath[1].Direction != "reverse" {
+		t.Errorf("Unexpected propagation path: %+v", ec2.PropagationPath)
+	}
+
+	// Lambda は Subnet に直接接続している
+	if lambda := byID["aws:lambda:func-456"]; lambda.Distance != 1 || lambda.ImpactScore != 0.7 {
+		t.Errorf("Unexpected affected resource: %+v", lambda)
+	}
+	if result.ImpactScore != 0.7+0.7+0.56+0.56 {
+		t.Errorf("Expected impact score 2.52, got %v", result.ImpactScore)
+	}
+}
+
+func TestAnalyzer_NetworkChange(t *testing.T) {
+	g := createTestGraph()
+
+	// エッジの重みは影響の強さに掛ける
+	g.AddEdge(graph.Edge{From: "aws:sg:sg-db", To: "aws:ec2:i-111", Type: "network", Weight: 0.5})
+	g.AddNode(graph.ResourceNode{ID: "aws:sg:sg-db", Type: "security_group"})
+
+	analyzer := NewAnalyzer(g)
+	analyzer.SetMaxDepth(1)
+
+	// Security Group の変更は接続しているリソースから接続先に伝わる
+	result, err := analyzer.AnalyzeImpact(&types.DriftEvent{
+		ID:           "drift-008",
+		ResourceID:   "aws:ec2:i-111",
+		ResourceType: "ec2",
+		Type:         types.DriftModified,
+		Diff:         map[string]interface{}{"vpc_security_group_ids[1]": map[string]interface{}{"type": "added"}},
+	})
+	if err != nil {
+		t.Fatalf("AnalyzeImpact failed: %v", err)
+	}
+
+	scores := make(map[string]float64)
+	for _, resource := range result.AffectedResources {
+		scores[resource.ResourceID] = resource.ImpactScore
+	}
+	if len(scores) != 3 || scores["aws:sg:sg-789"] != 0.5 || scores["aws:subnet:subnet-111"] != 0.5 || scores["aws:sg:sg-db"] != 0.25 {
+		t.Errorf("Unexpected affected resources: %+v", result.AffectedResources)
+	}
+
+	// 影響の強い順に並ぶ
+	if last := result.AffectedResources[len(result.AffectedResources)-1]; last.ResourceID != "aws:sg:sg-db" {
+		t.Errorf("Expected the lightest edge last, got %+v", result.AffectedResources)
+	}
+}
+
+func TestAnalyzer_AttributeRulesOnlyFromDriftedResource(t *testing.T) {
+	g := createTestGraph()
+	analyzer := NewAnalyzer(g)
+
+	// Security Group のルールの変更は接続している EC2 に伝わるが、
+	// EC2 のネットワーク設定は変わっていないため EC2 から Subnet には伝わらない
+	result, err := analyzer.AnalyzeImpact(&types.DriftEvent{
+		ID:           "drift-009",
+		ResourceID:   "aws:sg:sg-789",
+		ResourceType: "security_group",
+		Type:         types.DriftModified,
+		Diff:         map[string]interface{}{"ingress[0].cidr_blocks[0]": map[string]interface{}{"type": "modified"}},
+	})
+	if err != nil {
+		t.Fatalf("AnalyzeImpact failed: %v", err)
+	}
+
+	if result.AffectedResourceCount != 2 || result.BlastRadius != 1 {
+		t.Errorf("Expected only the attached EC2 instances, got %+v", result.AffectedResources)
+	}
+}
+
+// buildScannedGraph はスキャン結果と同じ形式のノードから SkyGraph の builder でグラフを構築する
+func buildScannedGraph(t *testing.T) *graph.Graph {
+	t.Helper()
+	b := builder.NewGraphBuilder()
+	b.AddNodes([]graph.ResourceNode{
+		{ID: "aws:vpc:vpc-1", Type: "vpc", Provider: "aws"},
+		{ID: "aws:subnet:subnet-1", Type: "subnet", Provider: "aws", Metadata: map[string]interface{}{
+			"vpc_id": "vpc-1", "cidr_block": "10.0.1.0/24"}},
+		{ID: "aws:sg:sg-web", Type: "security_group", Provider: "aws", Metadata: map[string]interface{}{
+			"vpc_id": "vpc-1", "group_id": "sg-web"}},
+		{ID: "aws:ec2:i-1", Type: "ec2", Provider: "aws", Metadata: map[string]interface{}{
+			"vpc_id": "vpc-1", "subnet_id": "subnet-1", "security_groups": []string{"sg-web"}}},
+		{ID: "aws:rds:db-1", Type: "rds", Provider: "aws", Metadata: map[string]interface{}{
+			"vpc_id": "vpc-1", "subnet_ids": []string{"subnet-1"}, "security_groups": []string{"sg-web"}}},
+	})
+	if err := b.InferEdges(); err != nil {
+		t.Fatalf("InferEdges failed: %v", err)
+	}
+	return b.Build()
+}
+
+func TestAnalyzer_BuilderGraph(t *testing.T) {
+	g := buildScannedGraph(t)
+	analyzer := NewAnalyzer(g)
+	analyzer.SetMaxDepth(1)
+
+	affected := func(event *types.DriftEvent) map[string]types.AffectedResource {
+		t.Helper()
+		result, err := analyzer.AnalyzeImpact(event)
+		if err != nil {
+			t.Fatalf("AnalyzeImpact failed: %v", err)
+		}
+		byID := make(map[string]types.AffectedResource)
+		for _, resource := range result.AffectedResources {
+			byID[resource.ResourceID] = resource
+		}
+		return byID
+	}
+
+	// Subnet・Security Group の削除は接続している EC2 と RDS に伝わり、VPC には伝わらない
+	for _, id := range []string{"aws:subnet:subnet-1", "aws:sg:sg-web"} {
+		byID := affected(&types.DriftEvent{ID: "drift-" + id, ResourceID: id, Type: types.DriftDeleted})
+		if len(byID) != 2 {
+			t.Errorf("%s: expected EC2 and RDS to be affected, got %+v", id, byID)
+		}
+		for _, target := range []string{"aws:ec2:i-1", "aws:rds:db-1"} {
+			resource, ok := byID[target]
+			if !ok || resource.PropagationPath[0].Direction != string(Forward) ||
+				resource.PropagationPath[0].Reason != "connected through the affected network resource" {
+				t.Errorf("%s: unexpected impact on %s: %+v", id, target, resource)
+			}
+		}
+		if _, ok := byID["aws:vpc:vpc-1"]; ok {
+			t.Errorf("%s: the VPC should not be affected", id)
+		}
+	}
+
+	// EC2 の Security Group の変更は Security Group に伝わる
+	byID := affected(&types.DriftEvent{
+		ID:         "drift-ec2",
+		ResourceID: "aws:ec2:i-1",
+		Type:       types.DriftModified,
+		Diff:       map[string]interface{}{"vpc_security_group_ids[0]": map[string]interface{}{"type": "modified"}},
+	})
+	sg, ok := byID["aws:sg:sg-web"]
+	if !ok || sg.PropagationPath[0].Direction != string(Reverse) ||
+		sg.PropagationPath[0].Reason != "network configuration of the connected resource changed" {
+		t.Errorf("Unexpected impact on the security group: %+v", byID)
+	}
+}
+
+func TestAnalyzer_AccountScopedResourceID(t *testing.T) {
+	// Terraform の ARN から作ったアカウント付きの ID でも、単一アカウントのスキャンのノードに一致する
+	analyzer := NewAnalyzer(buildScannedGraph(t))
+	result, err := analyzer.AnalyzeImpact(&types.DriftEvent{
+		ID:         "drift-subnet",
+		ResourceID: graph.AWSNodeID("111111111111", "us-east-1", "subnet", "subnet-1"),
+		Type:       types.DriftDeleted,
+	})
+	if err != nil {
+		t.Fatalf("AnalyzeImpact failed: %v", err)
+	}
+	if result.AffectedResourceCount == 0 {
+		t.Errorf("Expected the scanned subnet to be found, got %+v", result)
+	}
+}
@@ -0,0 +1,251 @@
+package impact
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+// DefaultMaxDepth は影響を探索する最大ホップ数の既定値
+const DefaultMaxDepth = 3
+
+// MaxDepthLimit は設定できる最大ホップ数の上限（API のクエリなどで探索が大きくなりすぎないようにする）
+const MaxDepthLimit = 10
+
+// Direction はエッジに沿って影響が伝わる向き
+type Direction string
+
+const (
+	// Forward はエッジの From から To に伝わる (e.g., ownership: 親 → 子)
+	Forward Direction = "forward"
+
+	// Reverse はエッジの To から From に伝わる (e.g., dependency: 依存先 → 依存元)
+	Reverse Direction = "reverse"
+)
+
+// Rule はエッジタイプごとの影響の伝わり方
+type Rule struct {
+	// EdgeType はエッジの関係タイプ
+	EdgeType string
+
+	// Direction は影響が伝わる向き
+	Direction Direction
+
+	// DriftTypes は影響が伝わる drift の種類（空の場合はすべて）
+	DriftTypes []types.DriftType
+
+	// Attributes は影響が伝わる条件となる変化した属性（Diff のパスの先頭の属性名、空の場合は条件なし）
+	// 条件は drift のリソース自体の変化なので、条件のあるルールは drift のリソースからの1ホップ目だけに使う
+	Attributes []string
+
+	// Weight は1ホップで伝わる影響の割合 (0〜1)
+	Weight float64
+
+	// Reason は伝わる理由（伝播経路に記録する）
+	Reason string
+}
+
+// DefaultRules は既定の伝播ルール
+//
+// ownership は親から子へ、dependency と call は依存先から依存元へ伝わる。
+// network のエッジは SkyGraph と同じく接続先（Subnet、Security Group など）から接続しているリソースへ向く。
+// 接続先の変更・削除は接続しているリソースに伝わり、接続しているリソース側の変更は
+// ネットワークの設定が変わった場合だけ接続先に伝わる。
+var DefaultRules = []Rule{
+	{EdgeType: "ownership", Direction: Forward, Weight: 1.0, Reason: "owned by the affected parent"},
+	{EdgeType: "dependency", Direction: Reverse, Weight: 0.8, Reason: "depends on the affected resource"},
+	{EdgeType: "call", Direction: Reverse, Weight: 0.8, Reason: "calls the affected service"},
+	{
+		EdgeType:   "network",
+		Direction:  Forward,
+		DriftTypes: []types.DriftType{types.DriftModified, types.DriftDeleted},
+		Weight:     0.7,
+		Reason:     "connected through the affected network resource",
+	},
+	{
+		EdgeType:   "network",
+		Direction:  Reverse,
+		DriftTypes: []types.DriftType{types.DriftModified},
+		Attributes: []string{"vpc_security_group_ids", "security_groups", "subnet_id", "subnet_ids", "private_ip", "ingress", "egress"},
+		Weight:     0.5,
+		Reason:     "network configuration of the connected resource changed",
+	},
+}
+
+// applies はルールが drift に当てはまるかを判定
+func (r *Rule) applies(event *types.DriftEvent, changed map[string]bool) bool {
+	if len(r.DriftTypes) > 0 {
+		found := false
+		for _, t := range r.DriftTypes {
+			if t == event.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.Attributes) == 0 {
+		return true
+	}
+	for _, name := range r.Attributes {
+		if changed[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// reach は探索で見つけたリソースまでの最も強い経路
+type reach struct {
+	node  *graph.ResourceNode
+	score float64
+	path  []types.PropagationStep
+}
+
+// propagate は drift のリソースから伝播ルールに沿って影響を受けるリソースを探索する
+//
+// リソースごとに maxDepth ホップ以内で影響が最も強く伝わる経路（ホップごとの重みの積が最大）を求める。
+// 重みが同じ経路は短い方を使う。
+func (a *Analyzer) propagate(start *graph.ResourceNode, event *types.DriftEvent) []types.AffectedResource {
+	changed := make(map[string]bool, len(event.Diff))
+	for path := range event.Diff {
+		if i := strings.IndexAny(path, ".["); i >= 0 {
+			path = path[:i]
+		}
+		changed[path] = true
+	}
+
+	rules := make([]Rule, 0, len(a.rules))
+	for _, rule := range a.rules {
+		if rule.applies(event, changed) {
+			rules = append(rules, rule)
+		}
+	}
+
+	best := map[string]*reach{start.ID: {node: start, score: 1}}
+	frontier := []string{start.ID}
+
+	// 深さごとに経路を伸ばす（ホップ数の上限があるため、より強い経路が見つかったリソースだけ次の深さで広げる）
+	for depth := 1; depth <= a.maxDepth && len(frontier) > 0; depth++ {
+		improved := make(map[string]bool)
+		for _, id := range frontier {
+			from := best[id]
+			for _, hop := range a.hops(from.node, rules, depth == 1) {
+				if hop.node.ID == start.ID {
+					continue
+				}
+				score := from.score * hop.step.Weight
+				if current, ok := best[hop.node.ID]; ok && current.score >= score {
+					continue
+				}
+				path := make([]types.PropagationStep, len(from.path), len(from.path)+1)
+				copy(path, from.path)
+				best[hop.node.ID] = &reach{node: hop.node, score: score, path: append(path, hop.step)}
+				improved[hop.node.ID] = true
+			}
+		}
+
+		frontier = frontier[:0]
+		for id := range improved {
+			frontier = append(frontier, id)
+		}
+		sort.Strings(frontier)
+	}
+
+	affected := make([]types.AffectedResource, 0, len(best)-1)
+	for id, r := range best {
+		if id == start.ID || r.score <= 0 {
+			continue
+		}
+		last := r.path[len(r.path)-1]
+		affected = append(affected, types.AffectedResource{
+			ResourceID:        r.node.ID,
+			ResourceType:      r.node.Type,
+			RelationType:      last.RelationType,
+			Distance:          len(r.path),
+			ImpactDescription: a.generateImpactDescription(r.node, event.Type, last.RelationType),
+			ImpactScore:       round(r.score),
+			PropagationPath:   r.path,
+		})
+	}
+
+	// 影響の強い順（同じ場合は近い順）
+	sort.Slice(affected, func(i, j int) bool {
+		if affected[i].ImpactScore != affected[j].ImpactScore {
+			return affected[i].ImpactScore > affected[j].ImpactScore
+		}
+		if affected[i].Distance != affected[j].Distance {
+			return affected[i].Distance < affected[j].Distance
+		}
+		return affected[i].ResourceID < affected[j].ResourceID
+	})
+	return affected
+}
+
+// hop はルールに沿って1ホップで影響が伝わる隣接リソース
+type hop struct {
+	node *graph.ResourceNode
+	step types.PropagationStep
+}
+
+// hops はノードから影響が伝わる隣接リソースを返す（同じ隣接リソースへは最も重いエッジだけ）
+// first は drift のリソースから広げる場合で、属性の条件があるルールはこの場合だけ使う
+func (a *Analyzer) hops(node *graph.ResourceNode, rules []Rule, first bool) []hop {
+	var hops []hop
+	index := make(map[string]int)
+
+	add := func(edge graph.Edge, next string, dir Direction) {
+		for _, rule := range rules {
+			if rule.EdgeType != edge.Type || rule.Direction != dir || (!first && len(rule.Attributes) > 0) {
+				continue
+			}
+			nextNode := a.graph.FindNode(next)
+			if nextNode == nil {
+				return
+			}
+
+			step := types.PropagationStep{
+				From:         node.ID,
+				To:           next,
+				RelationType: edge.Type,
+				Direction:    string(dir),
+				Reason:       rule.Reason,
+				Weight:       round(rule.Weight * edgeWeight(edge)),
+			}
+			if i, ok := index[next]; ok {
+				if hops[i].step.Weight < step.Weight {
+					hops[i].step = step
+				}
+				continue
+			}
+			index[next] = len(hops)
+			hops = append(hops, hop{node: nextNode, step: step})
+		}
+	}
+
+	for _, edge := range a.graph.OutEdges(node.ID) {
+		add(edge, edge.To, Forward)
+	}
+	for _, edge := range a.graph.InEdges(node.ID) {
+		add(edge, edge.From, Reverse)
+	}
+	return hops
+}
+
+// edgeWeight はエッジの重みを 0〜1 で返す（未設定の場合は 1、1 を超える場合は 1）
+func edgeWeight(edge graph.Edge) float64 {
+	if edge.Weight <= 0 {
+		return 1
+	}
+	return math.Min(edge.Weight, 1)
+}
+
+// round はスコアを小数点以下2桁に丸める
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
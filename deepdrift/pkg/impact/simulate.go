@@ -0,0 +1,225 @@
+package impact
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/drift"
+	"github.com/higakikeita/airdig/deepdrift/pkg/severity"
+	"github.com/higakikeita/airdig/deepdrift/pkg/terraform"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// SimulatedChange は plan の1つのリソースの変更とそのインパクト
+type SimulatedChange struct {
+	// Address は Terraform のインスタンスのアドレス
+	Address string `json:"address"`
+
+	// Action は変更の種類 (create, update, delete, replace)
+	Action terraform.PlanAction `json:"action"`
+
+	// ActionReason は Terraform が記録した変更の理由 (e.g., "replace_because_cannot_update")
+	ActionReason string `json:"action_reason,omitempty"`
+
+	// ReplacePaths は置き換えの原因になった属性
+	ReplacePaths []string `json:"replace_paths,omitempty"`
+
+	// InGraph は変更するリソースが現在のグラフにあるか（作成するリソースは通常ない）
+	InGraph bool `json:"in_graph"`
+
+	// Event は変更を drift として表した合成イベント
+	Event *types.DriftEvent `json:"event"`
+
+	// Impact はその変更のインパクト
+	Impact *types.ImpactAnalysisResult `json:"impact"`
+}
+
+// SimulationReport は plan を適用する前のリスク報告
+type SimulationReport struct {
+	// TerraformVersion は plan を作成した Terraform のバージョン
+	TerraformVersion string `json:"terraform_version,omitempty"`
+
+	// GeneratedAt は報告の作成時刻
+	GeneratedAt time.Time `json:"generated_at"`
+
+	// Severity は変更の中で最も高い深刻度（変更がない場合は空）
+	Severity types.Severity `json:"severity,omitempty"`
+
+	// ChangeCount は分析した変更の数
+	ChangeCount int `json:"change_count"`
+
+	// Actions は変更の種類ごとの数
+	Actions map[terraform.PlanAction]int `json:"actions"`
+
+	// BySeverity は深刻度ごとの変更の数
+	BySeverity map[types.Severity]int `json:"by_severity"`
+
+	// AffectedResourceCount は影響を受けるリソースの数（重複を除く）
+	AffectedResourceCount int `json:"affected_resource_count"`
+
+	// AffectedResources は影響を受けるリソース（同じリソースは最も強く影響する経路だけ、影響の強い順）
+	AffectedResources []types.AffectedResource `json:"affected_resources"`
+
+	// BlastRadius は変更の中で最大の影響範囲（ホップ数）
+	BlastRadius int `json:"blast_radius"`
+
+	// ImpactScore は変更ごとの ImpactScore の合計
+	ImpactScore float64 `json:"impact_score"`
+
+	// Changes は変更ごとの結果（深刻度の高い順、同じ場合はインパクトの大きい順）
+	Changes []SimulatedChange `json:"changes"`
+}
+
+// Simulate は plan の変更を適用した場合のインパクトを現在のグラフで分析する
+// 変更はそれぞれ drift と同じ形の合成イベントにして AnalyzeImpact に渡す
+func (a *Analyzer) Simulate(plan *terraform.Plan) (*SimulationReport, error) {
+	report := &SimulationReport{
+		TerraformVersion:  plan.TerraformVersion,
+		GeneratedAt:       time.Now(),
+		Actions:           make(map[terraform.PlanAction]int),
+		BySeverity:        make(map[types.Severity]int),
+		AffectedResources: []types.AffectedResource{},
+		Changes:           []SimulatedChange{},
+	}
+	affected := make(map[string]int)
+
+	for _, rc := range plan.ManagedChanges() {
+		event := a.severity.Apply(plannedEvent(rc))
+
+		result, err := a.AnalyzeImpact(event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze %s: %w", rc.Address, err)
+		}
+
+		change := SimulatedChange{
+			Address:      rc.Address,
+			Action:       rc.Change.Action(),
+			ActionReason: rc.ActionReason,
+			ReplacePaths: rc.Change.ReplacePathNames(),
+			InGraph:      a.graph.LookupNode(event.ResourceID) != nil,
+			Event:        event,
+			Impact:       result,
+		}
+		result.Recommendations = planRecommendations(rc, change)
+		report.Changes = append(report.Changes, change)
+
+		report.ChangeCount++
+		report.Actions[change.Action]++
+		report.BySeverity[result.Severity]++
+		if severity.Rank(result.Severity) > severity.Rank(report.Severity) {
+			report.Severity = result.Severity
+		}
+		report.BlastRadius = max(report.BlastRadius, result.BlastRadius)
+		report.ImpactScore += result.ImpactScore
+
+		for _, resource := range result.AffectedResources {
+			if i, ok := affected[resource.ResourceID]; ok {
+				if report.AffectedResources[i].ImpactScore < resource.ImpactScore {
+					report.AffectedResources[i] = resource
+				}
+				continue
+			}
+			affected[resource.ResourceID] = len(report.AffectedResources)
+			report.AffectedResources = append(report.AffectedResources, resource)
+		}
+	}
+
+	report.ImpactScore = round(report.ImpactScore)
+	report.AffectedResourceCount = len(report.AffectedResources)
+	sort.SliceStable(report.AffectedResources, func(i, j int) bool {
+		return report.AffectedResources[i].ImpactScore > report.AffectedResources[j].ImpactScore
+	})
+	sort.SliceStable(report.Changes, func(i, j int) bool {
+		ri, rj := report.Changes[i].Impact, report.Changes[j].Impact
+		if severity.Rank(ri.Severity) != severity.Rank(rj.Severity) {
+			return severity.Rank(ri.Severity) > severity.Rank(rj.Severity)
+		}
+		return ri.ImpactScore > rj.ImpactScore
+	})
+
+	return report, nil
+}
+
+// plannedEvent は plan の変更を合成の drift イベントに変換
+// create は created、update は modified、delete と replace は deleted（置き換えでは元のリソースがなくなるため）
+func plannedEvent(rc terraform.ResourceChange) *types.DriftEvent {
+	r := rc.Resource()
+	inst := rc.Instance()
+	after := rc.Change.AfterValues()
+
+	// 作成するリソースの ID は apply まで決まらないことが多い
+	resourceID := rc.Address
+	if r.CloudID(inst) != "" {
+		resourceID = r.NodeID(inst)
+	}
+
+	now := time.Now()
+	event := &types.DriftEvent{
+		ResourceID:       resourceID,
+		ResourceType:     r.NodeType(),
+		TerraformAddress: rc.Address,
+		Timestamp:        now,
+		Before:           rc.Change.Before,
+		After:            after,
+		FirstSeen:        now,
+		LastSeen:         now,
+	}
+
+	switch rc.Change.Action() {
+	case terraform.ActionCreate:
+		event.Type = types.DriftCreated
+	case terraform.ActionDelete, terraform.ActionReplace:
+		event.Type = types.DriftDeleted
+	default:
+		event.Type = types.DriftModified
+	}
+	if rc.Change.Before != nil && after != nil {
+		event.Diff = drift.DiffMap(drift.Diff(rc.Change.Before, after))
+	}
+
+	event.ID = "plan-" + event.Fingerprint()[:20]
+	return event
+}
+
+// planRecommendations は変更を適用する前に確認することを返す
+func planRecommendations(rc terraform.ResourceChange, change SimulatedChange) []string {
+	recommendations := []string{}
+	affected := change.Impact.AffectedResourceCount
+
+	if !change.InGraph && change.Action != terraform.ActionCreate {
+		recommendations = append(recommendations, fmt.Sprintf("%s is not in the current graph; its impact could not be analyzed", change.Address))
+	}
+
+	switch change.Action {
+	case terraform.ActionDelete:
+		if affected > 0 {
+			recommendations = append(recommendations, fmt.Sprintf("Confirm that %d dependent resources no longer need %s", affected, change.Address))
+		}
+
+	case terraform.ActionReplace:
+		reason := "it"
+		if len(change.ReplacePaths) > 0 {
+			reason = strings.Join(change.ReplacePaths, ", ")
+		}
+		recommendations = append(recommendations, fmt.Sprintf("%s will be replaced because %s cannot be updated in place; its ID will change", change.Address, reason))
+		if affected > 0 {
+			recommendations = append(recommendations, fmt.Sprintf("Check that %d dependent resources pick up the new resource", affected))
+		}
+		if affected > 0 && rc.Change.Actions[0] == string(terraform.ActionDelete) {
+			// 削除してから作成する（create_before_destroy でない）場合
+			recommendations = append(recommendations, "Consider create_before_destroy to avoid downtime while it is replaced")
+		}
+
+	case terraform.ActionUpdate:
+		if change.Event.ResourceType == "security_group" {
+			recommendations = append(recommendations, "Review security group rule changes before applying")
+		}
+		if affected > 0 {
+			recommendations = append(recommendations, fmt.Sprintf("Verify that %d connected resources tolerate the change", affected))
+		}
+	}
+
+	return recommendations
+}
@@ -0,0 +1,132 @@
+package impact
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/terraform"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// simulatePlan は createTestGraph のリソースを変更する plan
+// Security Group のルールの更新、RDS の置き換え、EC2 の作成、S3 の no-op
+const simulatePlan = `{
+  "format_version": "1.2",
+  "terraform_version": "1.7.5",
+  "resource_changes": [
+    {
+      "address": "aws_security_group.web",
+      "mode": "managed", "type": "aws_security_group", "name": "web",
+      "change": {
+        "actions": ["update"],
+        "before": {"id": "sg-789", "name": "web", "vpc_id": "vpc-123",
+                   "ingress": [{"protocol": "tcp", "from_port": 443, "to_port": 443, "cidr_blocks": ["10.0.0.0/8"]}]},
+        "after": {"id": "sg-789", "name": "web", "vpc_id": "vpc-123",
+                  "ingress": [{"protocol": "tcp", "from_port": 443, "to_port": 443, "cidr_blocks": ["0.0.0.0/0"]}]},
+        "after_unknown": {}
+      }
+    },
+    {
+      "address": "aws_db_instance.main",
+      "mode": "managed", "type": "aws_db_instance", "name": "main",
+      "change": {
+        "actions": ["delete", "create"],
+        "before": {"id": "db-ABC", "identifier": "db-123", "engine": "mysql"},
+        "after": {"identifier": "db-123", "engine": "postgres"},
+        "after_unknown": {"id": true},
+        "replace_paths": [["engine"]]
+      },
+      "action_reason": "replace_because_cannot_update"
+    },
+    {
+      "address": "aws_instance.worker",
+      "mode": "managed", "type": "aws_instance", "name": "worker",
+      "change": {
+        "actions": ["create"],
+        "before": null,
+        "after": {"instance_type": "t3.micro", "subnet_id": "subnet-111"},
+        "after_unknown": {"id": true}
+      }
+    },
+    {
+      "address": "aws_s3_bucket.logs",
+      "mode": "managed", "type": "aws_s3_bucket", "name": "logs",
+      "change": {"actions": ["no-op"], "before": {"bucket": "logs"}, "after": {"bucket": "logs"}}
+    }
+  ]
+}`
+
+func TestAnalyzer_Simulate(t *testing.T) {
+	plan, err := terraform.ParsePlan(strings.NewReader(simulatePlan))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := NewAnalyzer(createTestGraph()).Simulate(plan)
+	if err != nil {
+		t.Fatalf("Simulate failed: %v", err)
+	}
+
+	if report.ChangeCount != 3 || report.Actions[terraform.ActionUpdate] != 1 || report.Actions[terraform.ActionReplace] != 1 || report.Actions[terraform.ActionCreate] != 1 {
+		t.Errorf("Unexpected change counts: %d %v", report.ChangeCount, report.Actions)
+	}
+	if report.Severity != types.SeverityHigh || report.BySeverity[types.SeverityHigh] != 2 {
+		t.Errorf("Unexpected severity: %s %v", report.Severity, report.BySeverity)
+	}
+
+	byAddress := make(map[string]SimulatedChange)
+	for _, change := range report.Changes {
+		byAddress[change.Address] = change
+	}
+
+	// ルールの変更は Security Group に接続している EC2 に伝わる
+	sg := byAddress["aws_security_group.web"]
+	if sg.Event.Type != types.DriftModified || sg.Event.ResourceID != "aws:sg:sg-789" || !sg.InGraph {
+		t.Errorf("Unexpected event: %+v", sg.Event)
+	}
+	if _, ok := sg.Event.Diff["ingress[0].cidr_blocks[0]"]; !ok {
+		t.Errorf("Expected the changed rule in the diff, got %v", sg.Event.Diff)
+	}
+	if sg.Impact.AffectedResourceCount != 2 || sg.Impact.ImpactScore != 1.4 {
+		t.Errorf("Unexpected impact: %+v", sg.Impact)
+	}
+
+	// 置き換えは削除として依存元に伝わる
+	db := byAddress["aws_db_instance.main"]
+	if db.Event.Type != types.DriftDeleted || db.Event.ResourceID != "aws:rds:db-123" || db.Impact.AffectedResourceCount != 3 {
+		t.Errorf("Unexpected replacement: %+v %+v", db.Event, db.Impact)
+	}
+	if len(db.ReplacePaths) != 1 || !containsString(db.Impact.Recommendations, "Consider create_before_destroy to avoid downtime while it is replaced") {
+		t.Errorf("Unexpected recommendations: %v", db.Impact.Recommendations)
+	}
+
+	// 作成するリソースは ID が決まらないためグラフにない
+	worker := byAddress["aws_instance.worker"]
+	if worker.Event.Type != types.DriftCreated || worker.Event.ResourceID != "aws_instance.worker" || worker.InGraph || worker.Impact.AffectedResourceCount != 0 {
+		t.Errorf("Unexpected creation: %+v", worker)
+	}
+
+	// 深刻度の高い順、同じ場合はインパクトの大きい順
+	if report.Changes[0].Address != "aws_db_instance.main" || report.Changes[2].Address != "aws_instance.worker" {
+		t.Errorf("Unexpected order: %s, %s, %s", report.Changes[0].Address, report.Changes[1].Address, report.Changes[2].Address)
+	}
+
+	// EC2 は両方の変更の影響を受けるが1回だけ数える（強い方の影響）
+	if report.AffectedResourceCount != 3 || report.BlastRadius != 1 || report.ImpactScore != 3.8 {
+		t.Errorf("Unexpected totals: %d resources, radius %d, score %v", report.AffectedResourceCount, report.BlastRadius, report.ImpactScore)
+	}
+	for _, resource := range report.AffectedResources {
+		if resource.ImpactScore != 0.8 || resource.RelationType != "dependency" {
+			t.Errorf("Expected the strongest impact to be kept, got %+v", resource)
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+// Package notify は新しい drift を Slack・Webhook・メールに通知する
+package notify
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/severity"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// Config は通知の設定（YAML）
+//
+//	sinks:
+//	  ops-slack:
+//	    type: slack
+//	    url: ${SLACK_WEBHOOK_URL}
+//	  audit:
+//	    type: webhook
+//	    url: https://audit.example.com/deepdrift
+//	    secret: ${WEBHOOK_SECRET}
+//	  oncall:
+//	    type: email
+//	    smtp: smtp.example.com:587
+//	    username: deepdrift
+//	    password: ${SMTP_PASSWORD}
+//	    from: deepdrift@example.com
+//	    to: [oncall@example.com]
+//	routes:
+//	  - name: prod-critical
+//	    sinks: [ops-slack, oncall]
+//	    min_severity: high
+//	    tags: {Env: prod}
+//	    digest_window: 5m
+//	    rate_limit: {messages: 1, per: 10m}
+//	  - name: audit
+//	    sinks: [audit]
+//	retry:
+//	  attempts: 5
+//	  initial_backoff: 1s
+//	  max_backoff: 1m
+//	failure_log: notify-failures.jsonl
+type Config struct {
+	// Sinks は名前ごとの通知先
+	Sinks map[string]SinkConfig `yaml:"sinks"`
+
+	// Routes は通知のルール（drift は一致したすべてのルールの通知先に送る）
+	Routes []Route `yaml:"routes"`
+
+	// Retry は送信に失敗した場合の再試行
+	Retry Retry `yaml:"retry"`
+
+	// FailureLog は再試行しても送れなかった通知を記録するファイル（JSON Lines、空の場合はメモリのみ）
+	FailureLog string `yaml:"failure_log"`
+}
+
+// SinkConfig は通知先の設定
+// 設定値の ${VAR} は環境変数で置き換える（Webhook の URL やパスワードをファイルに書かないため）
+type SinkConfig struct {
+	// Type は通知先の種類 (slack, webhook, email)
+	Type string `yaml:"type"`
+
+	// URL は Slack の Incoming Webhook または Webhook の送信先
+	URL string `yaml:"url"`
+
+	// Secret は Webhook の署名 (HMAC-SHA256) の鍵（空の場合は署名しない）
+	Secret string `yaml:"secret"`
+
+	// Headers は Webhook に付けるヘッダー
+	Headers map[string]string `yaml:"headers"`
+
+	// SMTP はメールサーバーのアドレス (host:port)
+	SMTP     string   `yaml:"smtp"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+
+	// Timeout は1回の送信のタイムアウト（0 の場合は DefaultTimeout）
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Route は drift を通知先に送る条件と送り方
+// 条件を省略した項目はすべてに一致する
+type Route struct {
+	Name  string   `yaml:"name"`
+	Sinks []string `yaml:"sinks"`
+
+	// MinSeverity はこの深刻度以上の drift に一致する
+	MinSeverity types.Severity `yaml:"min_severity"`
+
+	// ResourceTypes はリソースタイプ (e.g., "security_group")
+	ResourceTypes []string `yaml:"resource_types"`
+
+	// Tags はリソースのタグ（すべて一致する必要がある、値が "*" の場合はキーだけで判定）
+	Tags map[string]string `yaml:"tags"`
+
+	// DigestWindow は最初の drift からこの期間に検出した drift を1つの通知にまとめる（0 の場合は検出ごとにまとめる）
+	DigestWindow time.Duration `yaml:"digest_window"`
+
+	// RateLimit はルールごとの送信数の上限（超えた drift は次に送れる通知にまとめる）
+	RateLimit RateLimit `yaml:"rate_limit"`
+
+	resourceTypes map[string]bool
+}
+
+// RateLimit は Per の期間に送る通知の最大数（Messages が 0 の場合は制限なし）
+type RateLimit struct {
+	Messages int           `yaml:"messages"`
+	Per      time.Duration `yaml:"per"`
+}
+
+// Retry は送信の再試行（バックオフは InitialBackoff から倍ずつ、MaxBackoff まで）
+type Retry struct {
+	Attempts       int           `yaml:"attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+const (
+	// DefaultTimeout は1回の送信のタイムアウトの既定値
+	DefaultTimeout = 10 * time.Second
+
+	defaultAttempts       = 3
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+// LoadConfig は通知の設定ファイル (YAML) を読み込む
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification config: %w", err)
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig は通知の設定 (YAML) をパース
+func ParseConfig(data []byte) (*Config, error) {
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse notification config: %w", err)
+	}
+
+	for name, sink := range config.Sinks {
+		sink.URL = os.ExpandEnv(sink.URL)
+		sink.Secret = os.ExpandEnv(sink.Secret)
+		sink.SMTP = os.ExpandEnv(sink.SMTP)
+		sink.Username = os.ExpandEnv(sink.Username)
+		sink.Password = os.ExpandEnv(sink.Password)
+		for key, value := range sink.Headers {
+			sink.Headers[key] = os.ExpandEnv(value)
+		}
+		if err := sink.validate(); err != nil {
+			return nil, fmt.Errorf("sink %s: %w", name, err)
+		}
+		config.Sinks[name] = sink
+	}
+
+	seen := make(map[string]bool, len(config.Routes))
+	for i := range config.Routes {
+		route := &config.Routes[i]
+		if route.Name == "" {
+			route.Name = fmt.Sprintf("route-%d", i)
+		}
+		if seen[route.Name] {
+			return nil, fmt.Errorf("route %s: duplicate name", route.Name)
+		}
+		seen[route.Name] = true
+
+		if len(route.Sinks) == 0 {
+			return nil, fmt.Errorf("route %s: sinks are required", route.Name)
+		}
+		for _, name := range route.Sinks {
+			if _, ok := config.Sinks[name]; !ok {
+				return nil, fmt.Errorf("route %s: unknown sink %q", route.Name, name)
+			}
+		}
+		if route.MinSeverity != "" && severity.Rank(route.MinSeverity) < 0 {
+			return nil, fmt.Errorf("route %s: unknown severity %q", route.Name, route.MinSeverity)
+		}
+		if route.RateLimit.Messages > 0 && route.RateLimit.Per <= 0 {
+			return nil, fmt.Errorf("route %s: rate_limit.per is required", route.Name)
+		}
+
+		route.resourceTypes = make(map[string]bool, len(route.ResourceTypes))
+		for _, resourceType := range route.ResourceTypes {
+			route.resourceTypes[resourceType] = true
+		}
+	}
+
+	if config.Retry.Attempts <= 0 {
+		config.Retry.Attempts = defaultAttempts
+	}
+	if config.Retry.InitialBackoff <= 0 {
+		config.Retry.InitialBackoff = defaultInitialBackoff
+	}
+	if config.Retry.MaxBackoff <= 0 {
+		config.Retry.MaxBackoff = defaultMaxBackoff
+	}
+
+	return &config, nil
+}
+
+// emailAddress は宛先として受け付けるメールアドレス（ヘッダーの改行などを防ぐ）
+var emailAddress = regexp.MustCompile(`^[^\s<>,;:"]+@[^\s<>,;:"]+$`)
+
+// validate は通知先の種類ごとに必要な設定があるかを確認
+func (s SinkConfig) validate() error {
+	switch s.Type {
+	case "slack", "webhook":
+		if s.URL == "" {
+			return fmt.Errorf("url is required")
+		}
+	case "email":
+		if s.SMTP == "" || s.From == "" || len(s.To) == 0 {
+			return fmt.Errorf("smtp, from and to are required")
+		}
+		for _, addr := range append([]string{s.From}, s.To...) {
+			if !emailAddress.MatchString(addr) {
+				return fmt.Errorf("invalid email address %q", addr)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown sink type %q (expected slack, webhook or email)", s.Type)
+	}
+	return nil
+}
+
+// matches は drift がルールの条件に一致するかを判定
+func (r *Route) matches(event *types.DriftEvent) bool {
+	if r.MinSeverity != "" && severity.Rank(event.Severity) < severity.Rank(r.MinSeverity) {
+		return false
+	}
+	if len(r.resourceTypes) > 0 && !r.resourceTypes[event.ResourceType] {
+		return false
+	}
+	if len(r.Tags) > 0 {
+		tags := event.ResourceTags()
+		for key, want := range r.Tags {
+			value, ok := tags[key]
+			if !ok || (want != "*" && value != want) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// emailSink は SMTP でメールを送る
+type emailSink struct {
+	name    string
+	addr    string
+	host    string
+	auth    smtp.Auth
+	from    string
+	to      []string
+	timeout time.Duration
+}
+
+func newEmailSink(name string, config SinkConfig) *emailSink {
+	host, _, err := net.SplitHostPort(config.SMTP)
+	if err != nil {
+		host = config.SMTP
+	}
+
+	s := &emailSink{
+		name:    name,
+		addr:    config.SMTP,
+		host:    host,
+		from:    config.From,
+		to:      config.To,
+		timeout: timeout(config),
+	}
+	if config.Username != "" {
+		// PlainAuth は TLS でない接続では localhost 以外に認証情報を送らない
+		s.auth = smtp.PlainAuth("", config.Username, config.Password, host)
+	}
+	return s
+}
+
+func (s *emailSink) Name() string { return s.name }
+
+func (s *emailSink) Send(ctx context.Context, msg *Message) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return smtpError(err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return smtpError(err)
+		}
+	}
+	if s.auth != nil {
+		if err := c.Auth(s.auth); err != nil {
+			return smtpError(err)
+		}
+	}
+
+	if err := c.Mail(s.from); err != nil {
+		return smtpError(err)
+	}
+	for _, to := range s.to {
+		if err := c.Rcpt(to); err != nil {
+			return smtpError(err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return smtpError(err)
+	}
+	if _, err := w.Write(s.compose(msg)); err != nil {
+		return smtpError(err)
+	}
+	if err := w.Close(); err != nil {
+		return smtpError(err)
+	}
+	return c.Quit()
+}
+
+// compose はメールのヘッダーと本文を作成
+func (s *emailSink) compose(msg *Message) []byte {
+	var b bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", key, value)
+	}
+	header("From", s.from)
+	header("To", strings.Join(s.to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", "[DeepDrift] "+msg.Title()))
+	header("Date", msg.CreatedAt.Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@deepdrift>", msg.ID))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "8bit")
+	b.WriteString("\r\n")
+
+	// 本文の行末は CRLF、"." で始まる行は DATA の dot-stuffing で smtp パッケージが処理する
+	b.WriteString(strings.ReplaceAll(msg.Text(), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// smtpError は 5xx の応答を再試行しないエラーとして返す
+func smtpError(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+		return permanent(err)
+	}
+	return err
+}
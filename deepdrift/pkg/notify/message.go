@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/severity"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// maxListedEvents は1つの通知に一覧で載せる drift の最大数（残りは件数だけ）
+const maxListedEvents = 20
+
+// Message はルールごとにまとめた drift の通知
+type Message struct {
+	// ID は通知の識別子（再試行しても変わらないため、受信側で重複を除ける）
+	ID string `json:"id"`
+
+	// Route は通知を送ったルールの名前
+	Route string `json:"route"`
+
+	// Severity は drift の中で最も高い深刻度
+	Severity types.Severity `json:"severity"`
+
+	// Events は通知する drift（深刻度の高い順）
+	Events []*types.DriftEvent `json:"events"`
+
+	// CreatedAt は通知を作成した時刻
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// newMessage は drift をまとめた通知を作成
+func newMessage(route string, events []*types.DriftEvent, now time.Time) *Message {
+	sorted := make([]*types.DriftEvent, len(events))
+	copy(sorted, events)
+	sortBySeverity(sorted)
+
+	h := sha256.New()
+	h.Write([]byte(route))
+	msg := &Message{Route: route, Events: sorted, CreatedAt: now}
+	for _, event := range sorted {
+		h.Write([]byte{0})
+		h.Write([]byte(event.ID))
+		if severity.Rank(event.Severity) > severity.Rank(msg.Severity) {
+			msg.Severity = event.Severity
+		}
+	}
+	h.Write([]byte(now.UTC().Format(time.RFC3339Nano)))
+	msg.ID = hex.EncodeToString(h.Sum(nil))[:20]
+	return msg
+}
+
+// sortBySeverity は drift を深刻度の高い順に並べる（同じ深刻度は元の順）
+func sortBySeverity(events []*types.DriftEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return severity.Rank(events[i].Severity) > severity.Rank(events[j].Severity)
+	})
+}
+
+// Title は通知の件名 (e.g., "3 new drifts (1 critical, 2 high)")
+func (m *Message) Title() string {
+	if len(m.Events) == 1 {
+		e := m.Events[0]
+		return fmt.Sprintf("New %s drift: %s %s", e.Severity, e.ResourceID, e.Type)
+	}
+
+	counts := make(map[types.Severity]int)
+	for _, event := range m.Events {
+		counts[event.Severity]++
+	}
+	var parts []string
+	for _, s := range []types.Severity{types.SeverityCritical, types.SeverityHigh, types.SeverityMedium, types.SeverityLow} {
+		if counts[s] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[s], s))
+		}
+	}
+	return fmt.Sprintf("%d new drifts (%s)", len(m.Events), strings.Join(parts, ", "))
+}
+
+// Lines は drift ごとの1行の説明（maxListedEvents を超える分は件数だけ）
+func (m *Message) Lines() []string {
+	lines := make([]string, 0, min(len(m.Events), maxListedEvents)+1)
+	for i, event := range m.Events {
+		if i == maxListedEvents {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(m.Events)-maxListedEvents))
+			break
+		}
+		lines = append(lines, eventLine(event))
+	}
+	return lines
+}
+
+// Text は通知の本文（件名と drift の一覧）
+func (m *Message) Text() string {
+	return m.Title() + "\n" + strings.Join(m.Lines(), "\n")
+}
+
+// eventLine は drift の1行の説明
+// e.g., "[high] security_group aws:sg:sg-1 modified (aws_security_group.web) by alice: ingress[0].cidr_blocks[0]"
+func eventLine(event *types.DriftEvent) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s %s %s", event.Severity, event.ResourceType, event.ResourceID, event.Type)
+	if event.TerraformAddress != "" {
+		fmt.Fprintf(&b, " (%s)", event.TerraformAddress)
+	}
+	if event.RootCause != nil && event.RootCause.UserIdentity != "" {
+		fmt.Fprintf(&b, " by %s", event.RootCause.UserIdentity)
+	}
+	if len(event.Diff) > 0 {
+		keys := make([]string, 0, len(event.Diff))
+		for key := range event.Diff {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if len(keys) > 3 {
+			keys = append(keys[:3], fmt.Sprintf("+%d", len(event.Diff)-3))
+		}
+		fmt.Fprintf(&b, ": %s", strings.Join(keys, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,311 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// Sink は通知先
+type Sink interface {
+	// Name は設定での通知先の名前
+	Name() string
+
+	// Send は通知を1回送る（再試行は Notifier が行う）
+	Send(ctx context.Context, msg *Message) error
+}
+
+// permanentError は再試行しても成功しない送信のエラー（4xx の応答など）
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// permanent は再試行しないエラーとして返す
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// isPermanent は再試行しないエラーかを判定
+func isPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Failure は再試行しても送れなかった通知の記録
+type Failure struct {
+	Time      time.Time `json:"time"`
+	Route     string    `json:"route"`
+	Sink      string    `json:"sink"`
+	MessageID string    `json:"message_id"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error"`
+
+	// EventIDs は通知に含まれていた drift の ID
+	EventIDs []string `json:"event_ids"`
+}
+
+// maxFailures はメモリに残す Failure の最大数（古いものから捨てる）
+const maxFailures = 1000
+
+// Notifier は新しい drift をルールに従って通知先に送る
+// ルールごとに drift をためて、ダイジェストの期間とレート制限の範囲で1つの通知にまとめる
+type Notifier struct {
+	config *Config
+	sinks  map[string]Sink
+	routes []*routeState
+
+	mu       sync.Mutex
+	failures []Failure
+
+	// now と sleep はテストで時刻とバックオフの待ち時間を差し替える
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// routeState はルールごとの送信待ちの drift と送信の履歴
+type routeState struct {
+	route *Route
+
+	// pending は送信待ちの drift、since は最も古い送信待ちの drift を受け取った時刻
+	pending []*types.DriftEvent
+	since   time.Time
+
+	// sent はレート制限の期間内に送った通知の時刻
+	sent []time.Time
+}
+
+// New は設定から Notifier を作成
+func New(config *Config) (*Notifier, error) {
+	n := &Notifier{
+		config: config,
+		sinks:  make(map[string]Sink, len(config.Sinks)),
+		now:    time.Now,
+		sleep:  sleepContext,
+	}
+
+	for name, sinkConfig := range config.Sinks {
+		sink, err := newSink(name, sinkConfig)
+		if err != nil {
+			return nil, fmt.Errorf("sink %s: %w", name, err)
+		}
+		n.sinks[name] = sink
+	}
+	for i := range config.Routes {
+		n.routes = append(n.routes, &routeState{route: &config.Routes[i]})
+	}
+
+	return n, nil
+}
+
+// newSink は通知先の種類に合わせて Sink を作成
+func newSink(name string, config SinkConfig) (Sink, error) {
+	switch config.Type {
+	case "slack":
+		return newSlackSink(name, config), nil
+	case "webhook":
+		return newWebhookSink(name, config), nil
+	case "email":
+		return newEmailSink(name, config), nil
+	}
+	return nil, fmt.Errorf("unknown sink type %q", config.Type)
+}
+
+// Notify は新しい drift をルールの送信待ちに加え、送信できる通知を送る
+// ダイジェストの期間が過ぎていない drift やレート制限を超えた drift は、次に Notify を呼んだときにまとめて送る
+// （新しい drift がない場合も検出のたびに呼ぶ）
+// nil の Notifier は何もしない
+// 送れなかった通知のエラーをまとめて返す（Failures にも記録する）
+func (n *Notifier) Notify(ctx context.Context, events []*types.DriftEvent) error {
+	if n == nil {
+		return nil
+	}
+	return n.deliver(ctx, n.collect(events, false))
+}
+
+// Flush はダイジェストの期間とレート制限に関係なく送信待ちの drift をすべて送る（終了時に使う）
+func (n *Notifier) Flush(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+	return n.deliver(ctx, n.collect(nil, true))
+}
+
+// Failures は送れなかった通知の記録を返す（古い順）
+func (n *Notifier) Failures() []Failure {
+	if n == nil {
+		return nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	failures := make([]Failure, len(n.failures))
+	copy(failures, n.failures)
+	return failures
+}
+
+// delivery はルールの1つの通知
+type delivery struct {
+	route *Route
+	msg   *Message
+}
+
+// collect は drift を一致したルールの送信待ちに加え、送信する通知を取り出す
+func (n *Notifier) collect(events []*types.DriftEvent, force bool) []delivery {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := n.now()
+	var deliveries []delivery
+	for _, state := range n.routes {
+		for _, event := range events {
+			if !state.route.matches(event) {
+				continue
+			}
+			if len(state.pending) == 0 {
+				state.since = now
+			}
+			state.pending = append(state.pending, event)
+		}
+
+		if len(state.pending) == 0 || (!force && !state.due(now)) {
+			continue
+		}
+		deliveries = append(deliveries, delivery{route: state.route, msg: newMessage(state.route.Name, state.pending, now)})
+		state.pending = nil
+		state.sent = append(state.sent, now)
+	}
+	return deliveries
+}
+
+// due は送信待ちの drift を今送るかを判定
+func (s *routeState) due(now time.Time) bool {
+	if s.route.DigestWindow > 0 && now.Sub(s.since) < s.route.DigestWindow {
+		return false
+	}
+
+	limit := s.route.RateLimit
+	if limit.Messages <= 0 {
+		return true
+	}
+	recent := s.sent[:0]
+	for _, t := range s.sent {
+		if now.Sub(t) < limit.Per {
+			recent = append(recent, t)
+		}
+	}
+	s.sent = recent
+	return len(s.sent) < limit.Messages
+}
+
+// deliver は通知をルールのすべての通知先に送る
+func (n *Notifier) deliver(ctx context.Context, deliveries []delivery) error {
+	var errs []error
+	for _, d := range deliveries {
+		for _, name := range d.route.Sinks {
+			if err := n.send(ctx, d.route, n.sinks[name], d.msg); err != nil {
+				errs = append(errs, fmt.Errorf("route %s: sink %s: %w", d.route.Name, name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// send は通知を送り、失敗した場合はバックオフを倍にしながら再試行する
+// 再試行しても送れなかった場合は Failure として記録する
+func (n *Notifier) send(ctx context.Context, route *Route, sink Sink, msg *Message) error {
+	retry := n.config.Retry
+	backoff := retry.InitialBackoff
+
+	var err error
+	attempts := 0
+	for attempts < retry.Attempts {
+		attempts++
+		if err = sink.Send(ctx, msg); err == nil {
+			return nil
+		}
+		if isPermanent(err) || attempts == retry.Attempts {
+			break
+		}
+		if sleepErr := n.sleep(ctx, backoff); sleepErr != nil {
+			break
+		}
+		backoff = min(backoff*2, retry.MaxBackoff)
+	}
+
+	n.recordFailure(Failure{
+		Time:      n.now(),
+		Route:     route.Name,
+		Sink:      sink.Name(),
+		MessageID: msg.ID,
+		Attempts:  attempts,
+		Error:     err.Error(),
+		EventIDs:  eventIDs(msg.Events),
+	})
+	return err
+}
+
+// recordFailure は送れなかった通知をメモリと FailureLog に記録
+func (n *Notifier) recordFailure(failure Failure) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.failures = append(n.failures, failure)
+	if len(n.failures) > maxFailures {
+		n.failures = n.failures[len(n.failures)-maxFailures:]
+	}
+
+	if n.config.FailureLog == "" {
+		return
+	}
+	if err := appendJSONLine(n.config.FailureLog, failure); err != nil {
+		// 記録できなくても通知のエラーは呼び出し元に返る
+		fmt.Fprintf(os.Stderr, "Warning: Failed to record notification failure: %v\n", err)
+	}
+}
+
+// appendJSONLine は値を JSON Lines のファイルに追記
+func appendJSONLine(path string, v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func eventIDs(events []*types.DriftEvent) []string {
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+// sleepContext は d だけ待つ（ctx がキャンセルされた場合はエラー）
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
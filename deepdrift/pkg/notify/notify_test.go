@@ -0,0 +1,473 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// recorder は通知を受け取る HTTP のスタブ
+// statuses の順に応答し、使い切った後は 200 を返す
+type recorder struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	if len(r.statuses) > 0 {
+		status := r.statuses[0]
+		r.statuses = r.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.bodies)
+}
+
+// testNotifier は時刻を固定し、バックオフの待ち時間を記録する Notifier を作成
+func testNotifier(t *testing.T, config string) (*Notifier, *time.Time, *[]time.Duration) {
+	t.Helper()
+
+	c, err := ParseConfig([]byte(config))
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	n, err := New(c)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var sleeps []time.Duration
+	n.now = func() time.Time { return now }
+	n.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return n, &now, &sleeps
+}
+
+func testEvent(id, resourceType string, severity types.Severity, tags map[string]interface{}) *types.DriftEvent {
+	return &types.DriftEvent{
+		ID:           id,
+		ResourceID:   "aws:" + resourceType + ":" + id,
+		ResourceType: resourceType,
+		Type:         types.DriftModified,
+		Severity:     severity,
+		After:        map[string]interface{}{"tags": tags},
+		Diff:         map[string]interface{}{"instance_type": "t3.large"},
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	t.Setenv("TEST_SLACK_URL", "https://hooks.slack.example/T000/B000")
+
+	config, err := ParseConfig([]byte(`
+sinks:
+  slack:
+    type: slack
+    url: ${TEST_SLACK_URL}
+routes:
+  - sinks: [slack]
+    min_severity: high
+    digest_window: 5m
+    rate_limit: {messages: 2, per: 1h}
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	if config.Sinks["slack"].URL != "https://hooks.slack.example/T000/B000" {
+		t.Errorf("Expected the URL to be expanded, got %s", config.Sinks["slack"].URL)
+	}
+	route := config.Routes[0]
+	if route.Name != "route-0" || route.DigestWindow != 5*time.Minute || route.RateLimit.Per != time.Hour {
+		t.Errorf("Unexpected route: %+v", route)
+	}
+	if config.Retry.Attempts != defaultAttempts || config.Retry.InitialBackoff != defaultInitialBackoff {
+		t.Errorf("Expected retry defaults, got %+v", config.Retry)
+	}
+
+	invalid := map[string]string{
+		"unknown sink":     "sinks: {s: {type: slack, url: x}}\nroutes: [{sinks: [t]}]",
+		"unknown type":     "sinks: {s: {type: pager, url: x}}",
+		"missing url":      "sinks: {s: {type: webhook}}",
+		"unknown severity": "sinks: {s: {type: slack, url: x}}\nroutes: [{sinks: [s], min_severity: urgent}]",
+		"missing per":      "sinks: {s: {type: slack, url: x}}\nroutes: [{sinks: [s], rate_limit: {messages: 1}}]",
+		"bad address":      "sinks: {s: {type: email, smtp: 'localhost:25', from: a@example.com, to: [\"b@example.com\\r\\nBcc: c@example.com\"]}}",
+	}
+	for name, data := range invalid {
+		if _, err := ParseConfig([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestNotifier_Routing(t *testing.T) {
+	critical := &recorder{}
+	prod := &recorder{}
+	criticalServer := httptest.NewServer(critical)
+	defer criticalServer.Close()
+	prodServer := httptest.NewServer(prod)
+	defer prodServer.Close()
+
+	n, _, _ := testNotifier(t, fmt.Sprintf(`
+sinks:
+  critical: {type: slack, url: %s}
+  prod: {type: webhook, url: %s}
+routes:
+  - name: critical-network
+    sinks: [critical]
+    min_severity: critical
+    resource_types: [security_group, vpc]
+  - name: prod
+    sinks: [prod]
+    tags: {Env: prod, Owner: "*"}
+`, criticalServer.URL, prodServer.URL))
+
+	events := []*types.DriftEvent{
+		testEvent("sg-1", "security_group", types.SeverityCritical, map[string]interface{}{"Env": "dev"}),
+		testEvent("i-1", "ec2", types.SeverityCritical, map[string]interface{}{"Env": "prod", "Owner": "web"}),
+		testEvent("i-2", "ec2", types.SeverityLow, map[string]interface{}{"Env": "prod"}),
+		testEvent("vpc-1", "vpc", types.SeverityHigh, nil),
+	}
+	if err := n.Notify(context.Background(), events); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	var slack slackPayload
+	if critical.count() != 1 || json.Unmarshal(critical.bodies[0], &slack) != nil {
+		t.Fatalf("Expected one slack message, got %d", critical.count())
+	}
+	if slack.Text != "DeepDrift: New critical drift: aws:security_group:sg-1 modified" || len(slack.Blocks) != 2 {
+		t.Errorf("Unexpected slack payload: %+v", slack)
+	}
+
+	var webhook struct {
+		Route  string              `json:"route"`
+		Count  int                 `json:"count"`
+		Events []*types.DriftEvent `json:"events"`
+	}
+	if prod.count() != 1 || json.Unmarshal(prod.bodies[0], &webhook) != nil {
+		t.Fatalf("Expected one webhook message, got %d", prod.count())
+	}
+	if webhook.Route != "prod" || webhook.Count != 1 || webhook.Events[0].ID != "i-1" {
+		t.Errorf("Unexpected webhook payload: %+v", webhook)
+	}
+}
+
+func TestNotifier_DigestAndRateLimit(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	n, now, _ := testNotifier(t, fmt.Sprintf(`
+sinks:
+  hook: {type: webhook, url: %s}
+routes:
+  - name: all
+    sinks: [hook]
+    digest_window: 5m
+    rate_limit: {messages: 1, per: 30m}
+`, server.URL))
+	ctx := context.Background()
+
+	burst := make([]*types.DriftEvent, 200)
+	for i := range burst {
+		burst[i] = testEvent(fmt.Sprintf("i-%03d", i), "ec2", types.SeverityMedium, nil)
+	}
+	burst[150].Severity = types.SeverityCritical
+
+	// ダイジェストの期間中は送らない
+	n.Notify(ctx, burst[:120])
+	*now = now.Add(2 * time.Minute)
+	n.Notify(ctx, burst[120:])
+	if rec.count() != 0 {
+		t.Fatalf("Expected no message within the digest window, got %d", rec.count())
+	}
+
+	// 期間が過ぎたら1つの通知にまとめて送る
+	*now = now.Add(3 * time.Minute)
+	if err := n.Notify(ctx, nil); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if rec.count() != 1 {
+		t.Fatalf("Expected one digest, got %d", rec.count())
+	}
+	var digest webhookPayload
+	if err := json.Unmarshal(rec.bodies[0], &digest); err != nil {
+		t.Fatal(err)
+	}
+	if digest.Count != 200 || digest.Severity != types.SeverityCritical || digest.Events[0].ID != "i-150" {
+		t.Errorf("Unexpected digest: count=%d severity=%s first=%s", digest.Count, digest.Severity, digest.Events[0].ID)
+	}
+	if digest.Title != "200 new drifts (1 critical, 199 medium)" {
+		t.Errorf("Unexpected title: %s", digest.Title)
+	}
+
+	// レート制限を超えた drift は制限が解けるまでためる
+	n.Notify(ctx, []*types.DriftEvent{testEvent("i-900", "ec2", types.SeverityLow, nil)})
+	*now = now.Add(10 * time.Minute)
+	n.Notify(ctx, []*types.DriftEvent{testEvent("i-901", "ec2", types.SeverityLow, nil)})
+	if rec.count() != 1 {
+		t.Fatalf("Expected the rate limit to hold messages, got %d", rec.count())
+	}
+	*now = now.Add(20 * time.Minute)
+	n.Notify(ctx, nil)
+	if rec.count() != 2 {
+		t.Fatalf("Expected the held drifts after the rate limit, got %d", rec.count())
+	}
+	if err := json.Unmarshal(rec.bodies[1], &digest); err != nil || digest.Count != 2 {
+		t.Errorf("Expected the held drifts in one message, got %d", digest.Count)
+	}
+
+	// Flush は期間と制限に関係なく送る
+	n.Notify(ctx, []*types.DriftEvent{testEvent("i-902", "ec2", types.SeverityLow, nil)})
+	if err := n.Flush(ctx); err != nil || rec.count() != 3 {
+		t.Errorf("Expected Flush to send pending drifts, got %d messages (%v)", rec.count(), err)
+	}
+}
+
+func TestNotifier_WebhookSignatureAndRetry(t *testing.T) {
+	rec := &recorder{statuses: []int{http.StatusBadGateway, http.StatusTooManyRequests}}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	n, _, sleeps := testNotifier(t, fmt.Sprintf(`
+sinks:
+  hook:
+    type: webhook
+    url: %s
+    secret: s3cret
+    headers: {Authorization: Bearer token}
+routes:
+  - sinks: [hook]
+retry: {attempts: 4, initial_backoff: 1s, max_backoff: 3s}
+`, server.URL))
+
+	if err := n.Notify(context.Background(), []*types.DriftEvent{testEvent("sg-1", "security_group", types.SeverityHigh, nil)}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if rec.count() != 3 {
+		t.Fatalf("Expected 2 retries, got %d requests", rec.count())
+	}
+	if len(*sleeps) != 2 || (*sleeps)[0] != time.Second || (*sleeps)[1] != 2*time.Second {
+		t.Errorf("Unexpected backoff: %v", *sleeps)
+	}
+
+	req := rec.requests[2]
+	if got, want := req.Header.Get(SignatureHeader), Sign("s3cret", req.Header.Get(TimestampHeader), rec.bodies[2]); got != want {
+		t.Errorf("Signature = %s, want %s", got, want)
+	}
+	if req.Header.Get(DeliveryHeader) == "" || req.Header.Get(DeliveryHeader) != rec.requests[0].Header.Get(DeliveryHeader) {
+		t.Errorf("Expected the same delivery ID for retries")
+	}
+	if req.Header.Get("Authorization") != "Bearer token" {
+		t.Errorf("Expected custom headers to be sent")
+	}
+}
+
+func TestNotifier_RecordsFailures(t *testing.T) {
+	rec := &recorder{statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusForbidden}}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	failureLog := filepath.Join(t.TempDir(), "failures.jsonl")
+	n, _, sleeps := testNotifier(t, fmt.Sprintf(`
+sinks:
+  hook: {type: webhook, url: %s}
+routes:
+  - sinks: [hook]
+retry: {attempts: 3, initial_backoff: 1s, max_backoff: 1s}
+failure_log: %s
+`, server.URL, failureLog))
+	ctx := context.Background()
+
+	// 再試行しても失敗した場合
+	if err := n.Notify(ctx, []*types.DriftEvent{testEvent("i-1", "ec2", types.SeverityHigh, nil)}); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if rec.count() != 3 || len(*sleeps) != 2 {
+		t.Errorf("Expected 3 attempts, got %d requests and %d sleeps", rec.count(), len(*sleeps))
+	}
+
+	// 4xx は再試行しない
+	if err := n.Notify(ctx, []*types.DriftEvent{testEvent("i-2", "ec2", types.SeverityHigh, nil)}); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if rec.count() != 4 {
+		t.Errorf("Expected no retry for 403, got %d requests", rec.count())
+	}
+
+	failures := n.Failures()
+	if len(failures) != 2 || failures[0].Attempts != 3 || failures[1].Attempts != 1 || failures[1].EventIDs[0] != "i-2" {
+		t.Fatalf("Unexpected failures: %+v", failures)
+	}
+	if !strings.Contains(failures[1].Error, "unexpected status 403") {
+		t.Errorf("Unexpected error: %s", failures[1].Error)
+	}
+
+	data, err := os.ReadFile(failureLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var logged Failure
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[0]), &logged) != nil || logged.Sink != "hook" || logged.MessageID != failures[0].MessageID {
+		t.Errorf("Unexpected failure log: %s", data)
+	}
+}
+
+// smtpStub は受け取ったメールを記録する最小限の SMTP サーバー
+type smtpStub struct {
+	listener net.Listener
+	mu       sync.Mutex
+	rcpts    []string
+	data     []string
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStub{listener: l}
+	go s.serve()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *smtpStub) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStub) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 stub ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 stub")
+		case strings.HasPrefix(cmd, "MAIL FROM"):
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO"):
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, strings.TrimSpace(line[len("RCPT TO:"):]))
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			s.mu.Lock()
+			s.data = append(s.data, b.String())
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestNotifier_Email(t *testing.T) {
+	stub := newSMTPStub(t)
+
+	n, _, _ := testNotifier(t, fmt.Sprintf(`
+sinks:
+  oncall:
+    type: email
+    smtp: %s
+    from: deepdrift@example.com
+    to: [oncall@example.com, sre@example.com]
+routes:
+  - sinks: [oncall]
+`, stub.listener.Addr()))
+
+	events := []*types.DriftEvent{
+		testEvent("i-1", "ec2", types.SeverityMedium, nil),
+		testEvent("sg-1", "security_group", types.SeverityHigh, nil),
+	}
+	events[1].RootCause = &types.RootCause{UserIdentity: "alice"}
+	if err := n.Notify(context.Background(), events); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.data) != 1 || len(stub.rcpts) != 2 || stub.rcpts[1] != "<sre@example.com>" {
+		t.Fatalf("Unexpected mail: %d messages, recipients %v", len(stub.data), stub.rcpts)
+	}
+	mail := stub.data[0]
+	for _, want := range []string{
+		"Subject: [DeepDrift] 2 new drifts (1 high, 1 medium)\r\n",
+		"To: oncall@example.com, sre@example.com\r\n",
+		"[high] security_group aws:security_group:sg-1 modified by alice: instance_type\r\n",
+	} {
+		if !strings.Contains(mail, want) {
+			t.Errorf("Expected %q in mail:\n%s", want, mail)
+		}
+	}
+}
+
+func TestNotifier_Nil(t *testing.T) {
+	var n *Notifier
+	if err := n.Notify(context.Background(), []*types.DriftEvent{testEvent("i-1", "ec2", types.SeverityHigh, nil)}); err != nil {
+		t.Errorf("Expected a nil notifier to do nothing, got %v", err)
+	}
+	if err := n.Flush(context.Background()); err != nil || n.Failures() != nil {
+		t.Errorf("Expected a nil notifier to do nothing")
+	}
+}
@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// slackSink は Slack の Incoming Webhook に通知する
+type slackSink struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+func newSlackSink(name string, config SinkConfig) *slackSink {
+	return &slackSink{
+		name:   name,
+		url:    config.URL,
+		client: &http.Client{Timeout: timeout(config)},
+	}
+}
+
+func (s *slackSink) Name() string { return s.name }
+
+// slackText は Slack の text オブジェクト
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackBlock は Slack の Block Kit のブロック（header と section だけ使う）
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text"`
+}
+
+// slackPayload は Incoming Webhook の本文（text は通知とブロックを表示できない場合に使われる）
+type slackPayload struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+// maxSlackSectionText は section の text の最大文字数
+const maxSlackSectionText = 3000
+
+func (s *slackSink) Send(ctx context.Context, msg *Message) error {
+	title := fmt.Sprintf("DeepDrift: %s", msg.Title())
+	lines := make([]string, 0, len(msg.Lines()))
+	for _, line := range msg.Lines() {
+		lines = append(lines, "• "+escapeSlack(line))
+	}
+	section := truncate(strings.Join(lines, "\n"), maxSlackSectionText)
+
+	body, err := json.Marshal(slackPayload{
+		Text: title,
+		Blocks: []slackBlock{
+			{Type: "header", Text: &slackText{Type: "plain_text", Text: truncate(title, 150)}},
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: section}},
+		},
+	})
+	if err != nil {
+		return permanent(fmt.Errorf("failed to encode slack payload: %w", err))
+	}
+
+	return postJSON(ctx, s.client, s.url, body, nil)
+}
+
+// escapeSlack は Slack の mrkdwn で制御文字として扱われる文字をエスケープ
+func escapeSlack(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// truncate は文字列を n 文字（rune）までに切り詰める
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// TimestampHeader は Webhook を送った時刻（Unix 秒）
+	TimestampHeader = "X-DeepDrift-Timestamp"
+
+	// SignatureHeader は Webhook の署名 ("sha256=" + HMAC-SHA256(secret, timestamp + "." + body) の hex)
+	SignatureHeader = "X-DeepDrift-Signature"
+
+	// DeliveryHeader は通知の ID（再試行しても変わらない）
+	DeliveryHeader = "X-DeepDrift-Delivery"
+)
+
+// webhookSink は通知を署名付きの JSON として POST する
+type webhookSink struct {
+	name    string
+	url     string
+	secret  string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newWebhookSink(name string, config SinkConfig) *webhookSink {
+	return &webhookSink{
+		name:    name,
+		url:     config.URL,
+		secret:  config.Secret,
+		headers: config.Headers,
+		client:  &http.Client{Timeout: timeout(config)},
+	}
+}
+
+func (s *webhookSink) Name() string { return s.name }
+
+// webhookPayload は Webhook の本文
+type webhookPayload struct {
+	*Message
+	Title string `json:"title"`
+	Count int    `json:"count"`
+}
+
+func (s *webhookSink) Send(ctx context.Context, msg *Message) error {
+	body, err := json.Marshal(webhookPayload{Message: msg, Title: msg.Title(), Count: len(msg.Events)})
+	if err != nil {
+		return permanent(fmt.Errorf("failed to encode webhook payload: %w", err))
+	}
+
+	headers := map[string]string{DeliveryHeader: msg.ID}
+	for key, value := range s.headers {
+		headers[key] = value
+	}
+	if s.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		headers[TimestampHeader] = timestamp
+		headers[SignatureHeader] = Sign(s.secret, timestamp, body)
+	}
+
+	return postJSON(ctx, s.client, s.url, body, headers)
+}
+
+// Sign は Webhook の署名を計算する（受信側の検証にも使う）
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// postJSON は JSON を POST する
+// 408・429・5xx の応答と接続エラーは再試行し、それ以外の 4xx は再試行しない
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return permanent(fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "deepdrift-notify")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+	if detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512)); len(bytes.TrimSpace(detail)) > 0 {
+		err = fmt.Errorf("%w: %s", err, bytes.TrimSpace(detail))
+	}
+	if resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return permanent(err)
+	}
+	return err
+}
+
+// timeout は1回の送信のタイムアウト
+func timeout(config SinkConfig) time.Duration {
+	if config.Timeout > 0 {
+		return config.Timeout
+	}
+	return DefaultTimeout
+}
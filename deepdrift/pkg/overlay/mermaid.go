@@ -0,0 +1,79 @@
+package overlay
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// mermaidStyles は Status ごとのノード（classDef）とエッジ（linkStyle）のスタイル
+var mermaidStyles = map[Status]struct {
+	node string
+	edge string
+}{
+	StatusInSync:    {node: "fill:#dcfce7,stroke:#16a34a", edge: "stroke:#16a34a"},
+	StatusDrifted:   {node: "fill:#fef3c7,stroke:#d97706,stroke-width:2px", edge: "stroke:#d97706,stroke-width:2px"},
+	StatusUnmanaged: {node: "fill:#e0e7ff,stroke:#4f46e5,stroke-dasharray:5 5", edge: "stroke:#4f46e5,stroke-dasharray:5 5"},
+	StatusMissing:   {node: "fill:#fee2e2,stroke:#dc2626,stroke-dasharray:5 5", edge: "stroke:#dc2626,stroke-dasharray:5 5"},
+}
+
+// WriteMermaid はグラフを Mermaid の flowchart として書き出す
+// ノードとエッジは Status ごとに色分けし、in_sync 以外のエッジのラベルには Status を付ける
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintf(b, "%%%% DeepDrift intended vs actual overlay (generated at %s)\n", g.GeneratedAt.UTC().Format("2006-01-02T15:04:05Z"))
+	fmt.Fprintln(b, "flowchart LR")
+
+	ids := make(map[string]string, len(g.Nodes))
+	for i, node := range g.Nodes {
+		id := "n" + strconv.Itoa(i)
+		ids[node.ID] = id
+
+		label := node.Name
+		if label == "" {
+			label = node.ID
+		}
+		label = fmt.Sprintf("%s<br/>%s", mermaidText(label), mermaidText(node.Type))
+		if node.Status != StatusInSync {
+			label += "<br/>" + string(node.Status)
+		}
+		fmt.Fprintf(b, "    %s[\"%s\"]:::%s\n", id, label, node.Status)
+	}
+
+	links := make(map[Status][]string)
+	count := 0
+	for _, edge := range g.Edges {
+		from, to := ids[edge.From], ids[edge.To]
+		if from == "" || to == "" {
+			continue
+		}
+
+		label := mermaidText(edge.Type)
+		if edge.Status != StatusInSync {
+			label += " (" + string(edge.Status) + ")"
+		}
+		fmt.Fprintf(b, "    %s -->|\"%s\"| %s\n", from, label, to)
+
+		links[edge.Status] = append(links[edge.Status], strconv.Itoa(count))
+		count++
+	}
+
+	for _, status := range Statuses {
+		fmt.Fprintf(b, "    classDef %s %s\n", status, mermaidStyles[status].node)
+	}
+	for _, status := range Statuses {
+		if indexes := links[status]; len(indexes) > 0 {
+			fmt.Fprintf(b, "    linkStyle %s %s\n", strings.Join(indexes, ","), mermaidStyles[status].edge)
+		}
+	}
+
+	return b.Flush()
+}
+
+// mermaidText はラベルで使えない文字をエスケープする
+func mermaidText(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;", "|", "#124;").Replace(s)
+}
@@ -0,0 +1,425 @@
+// Package overlay は意図した構成（Terraform state）と実際の構成（SkyGraph）を
+// 1つのグラフに重ね、ノードとエッジごとに比較結果と関連する drift を付ける
+package overlay
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/severity"
+	"github.com/higakikeita/airdig/deepdrift/pkg/terraform"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+// Status はノード・エッジの意図した構成と実際の構成の比較結果
+type Status string
+
+const (
+	// StatusInSync は state で管理され、実際の構成と一致している
+	StatusInSync Status = "in_sync"
+
+	// StatusDrifted は state で管理されているが、実際の構成と差分がある
+	StatusDrifted Status = "drifted"
+
+	// StatusUnmanaged は実際にはあるが state にない
+	StatusUnmanaged Status = "unmanaged"
+
+	// StatusMissing は state にあるが実際にはない
+	StatusMissing Status = "missing"
+)
+
+// Statuses は全ての Status
+var Statuses = []Status{StatusInSync, StatusDrifted, StatusUnmanaged, StatusMissing}
+
+// Node は比較結果を付けたノード
+// 実際にあるノードは SkyGraph の値、missing のノードは state の値を持つ
+type Node struct {
+	graph.ResourceNode
+
+	Status Status `json:"status"`
+
+	// TerraformAddress はノードを管理する Terraform のインスタンスのアドレス
+	TerraformAddress string `json:"terraform_address,omitempty"`
+
+	// Workspace はノードを管理するワークスペース
+	Workspace string `json:"workspace,omitempty"`
+
+	// DriftEventIDs はノードの解決されていない drift
+	DriftEventIDs []string `json:"drift_event_ids,omitempty"`
+
+	// Severity は DriftEventIDs の drift の最も高い深刻度
+	Severity types.Severity `json:"severity,omitempty"`
+}
+
+// Edge は比較結果を付けたエッジ
+type Edge struct {
+	graph.Edge
+
+	Status Status `json:"status"`
+
+	// DriftEventIDs はエッジを変えた drift（参照属性が変化した drift）
+	DriftEventIDs []string `json:"drift_event_ids,omitempty"`
+
+	// Severity は DriftEventIDs の drift の最も高い深刻度
+	Severity types.Severity `json:"severity,omitempty"`
+}
+
+// Summary は Status ごとのノード数とエッジ数
+type Summary struct {
+	Nodes map[Status]int `json:"nodes"`
+	Edges map[Status]int `json:"edges"`
+}
+
+// Graph は意図した構成と実際の構成を重ねたグラフ
+type Graph struct {
+	Nodes   []Node  `json:"nodes"`
+	Edges   []Edge  `json:"edges"`
+	Summary Summary `json:"summary"`
+
+	// Workspaces は意図した構成を読み込んだワークスペース
+	Workspaces []string `json:"workspaces,omitempty"`
+
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// Workspace は state を読み込んだワークスペース
+type Workspace struct {
+	Name  string
+	State *terraform.State
+}
+
+// IntendedGraph は複数のワークスペースの意図した構成を1つのグラフにまとめる
+// 各ノードは管理するワークスペースを metadata["terraform_workspace"] に持つ
+// 複数のワークスペースにあるノードは最初のワークスペースのものを使う
+// SkyGraph がスキャンしないリソース（DB サブネットグループなど）は実際のグラフと比べられないため除く
+func IntendedGraph(workspaces []Workspace) *graph.Graph {
+	merged := graph.NewGraph()
+	for _, ws := range workspaces {
+		diagram := ws.State.GenerateDiagram()
+		for _, node := range diagram.Nodes {
+			if merged.HasNode(node.ID) || !terraform.IsScannedType(node.Type) {
+				continue
+			}
+			node.Metadata["terraform_workspace"] = ws.Name
+			merged.AddNode(node)
+		}
+		for _, edge := range diagram.Edges {
+			if merged.HasNode(edge.From) && merged.HasNode(edge.To) && !hasEdge(merged, edge) {
+				merged.AddEdge(edge)
+			}
+		}
+	}
+	return merged
+}
+
+// hasEdge は g に同じノード間・同じタイプのエッジがあるかを判定
+func hasEdge(g *graph.Graph, edge graph.Edge) bool {
+	for _, e := range g.OutEdges(edge.From) {
+		if e.To == edge.To && e.Type == edge.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Build は意図した構成（IntendedGraph）と実際の構成（SkyGraph）を重ねる
+//
+// ノードは ID で対応付け、drift はリソース ID（またはワークスペースと Terraform のアドレス）で
+// ノードに紐づける。解決済みの drift は無視する。
+// エッジは (From, To, Type) で対応付ける。片方のグラフにしかないエッジは、両端が管理されて
+// 実際にあり、To 側のノードの参照属性の drift が紐づく場合だけ drifted とし、それ以外は
+// unmanaged / missing とする。SkyGraph が推論したエッジ（metadata.inferred）は Terraform の
+// 参照属性に対応しないため重ねない。
+func Build(intended, actual *graph.Graph, events []*types.DriftEvent) *Graph {
+	if intended == nil {
+		intended = graph.NewGraph()
+	}
+	if actual == nil {
+		actual = graph.NewGraph()
+	}
+	intended = alignIDs(intended, actual)
+
+	index := newEventIndex(events)
+	overlay := &Graph{
+		Nodes:       make([]Node, 0, len(actual.Nodes)),
+		Edges:       make([]Edge, 0, len(actual.Edges)),
+		GeneratedAt: time.Now(),
+	}
+
+	// ノード
+	nodes := make(map[string]*Node)
+	nodeEvents := make(map[string][]*types.DriftEvent)
+	add := func(node Node, linked []*types.DriftEvent) {
+		node.DriftEventIDs, node.Severity = link(linked)
+		overlay.Nodes = append(overlay.Nodes, node)
+		nodeEvents[node.ID] = linked
+	}
+
+	for i := range intended.Nodes {
+		node := &intended.Nodes[i]
+		if intended.FindNode(node.ID) != node {
+			// ID の重複は最初のノードだけを使う
+			continue
+		}
+
+		address, _ := node.Metadata["terraform_address"].(string)
+		workspace, _ := node.Metadata["terraform_workspace"].(string)
+		linked := index.lookup(node.ID, workspace, address)
+
+		result := Node{
+			ResourceNode:     *node,
+			Status:           StatusMissing,
+			TerraformAddress: address,
+			Workspace:        workspace,
+		}
+		if actualNode := actual.FindNode(node.ID); actualNode != nil {
+			result.ResourceNode = *actualNode
+			result.Status = StatusInSync
+			if len(linked) > 0 {
+				result.Status = StatusDrifted
+			}
+		}
+		add(result, linked)
+	}
+
+	for i := range actual.Nodes {
+		node := &actual.Nodes[i]
+		if actual.FindNode(node.ID) != node || intended.HasNode(node.ID) {
+			continue
+		}
+		add(Node{ResourceNode: *node, Status: StatusUnmanaged}, index.lookup(node.ID, "", ""))
+	}
+
+	sort.Slice(overlay.Nodes, func(i, j int) bool { return overlay.Nodes[i].ID < overlay.Nodes[j].ID })
+	for i := range overlay.Nodes {
+		nodes[overlay.Nodes[i].ID] = &overlay.Nodes[i]
+	}
+
+	// 両端のノードが state で管理され、実際にもあるか
+	managed := func(edge graph.Edge) bool {
+		from, to := nodes[edge.From], nodes[edge.To]
+		return from != nil && to != nil &&
+			(from.Status == StatusInSync || from.Status == StatusDrifted) &&
+			(to.Status == StatusInSync || to.Status == StatusDrifted)
+	}
+
+	// エッジを変えた drift（To 側のノードの参照属性の drift）
+	referenceEvents := func(edge graph.Edge) []*types.DriftEvent {
+		attributes := terraform.ReferenceAttributes(nodes[edge.To].Type, edge.Type)
+		linked := make([]*types.DriftEvent, 0)
+		for _, event := range nodeEvents[edge.To] {
+			if changesAny(event, attributes) {
+				linked = append(linked, event)
+			}
+		}
+		return linked
+	}
+
+	// エッジ
+	intendedEdges := edgeSet(intended)
+	actualEdges := edgeSet(actual)
+
+	for key, edge := range intendedEdges {
+		result := Edge{Edge: edge, Status: StatusMissing}
+		var linked []*types.DriftEvent
+		if actualEdge, ok := actualEdges[key]; ok {
+			result.Edge = actualEdge
+			result.Status = StatusInSync
+		} else if managed(edge) {
+			if linked = referenceEvents(edge); len(linked) > 0 {
+				result.Status = StatusDrifted
+			}
+		}
+		result.DriftEventIDs, result.Severity = link(linked)
+		overlay.Edges = append(overlay.Edges, result)
+	}
+
+	for key, edge := range actualEdges {
+		if _, ok := intendedEdges[key]; ok {
+			continue
+		}
+		result := Edge{Edge: edge, Status: StatusUnmanaged}
+		var linked []*types.DriftEvent
+		if managed(edge) {
+			if linked = referenceEvents(edge); len(linked) > 0 {
+				result.Status = StatusDrifted
+			}
+		}
+		result.DriftEventIDs, result.Severity = link(linked)
+		overlay.Edges = append(overlay.Edges, result)
+	}
+
+	sort.Slice(overlay.Edges, func(i, j int) bool {
+		return edgeKey(overlay.Edges[i].Edge) < edgeKey(overlay.Edges[j].Edge)
+	})
+
+	overlay.Summary = summarize(overlay)
+	return overlay
+}
+
+// alignIDs は意図した構成のノード ID を実際のグラフの形式に揃えたグラフを返す
+// state の ID はアカウント付きだが、単一アカウント・単一リージョンの SkyGraph のスキャンは短い ID を使う
+func alignIDs(intended, actual *graph.Graph) *graph.Graph {
+	ids := make(map[string]string)
+	for _, node := range intended.Nodes {
+		if actual.HasNode(node.ID) {
+			continue
+		}
+		if found := actual.LookupNode(node.ID); found != nil {
+			ids[node.ID] = found.ID
+		}
+	}
+	if len(ids) == 0 {
+		return intended
+	}
+
+	aligned := graph.NewGraph()
+	for _, node := range intended.Nodes {
+		if id, ok := ids[node.ID]; ok {
+			node.ID = id
+		}
+		aligned.AddNode(node)
+	}
+	for _, edge := range intended.Edges {
+		if id, ok := ids[edge.From]; ok {
+			edge.From = id
+		}
+		if id, ok := ids[edge.To]; ok {
+			edge.To = id
+		}
+		aligned.AddEdge(edge)
+	}
+	return aligned
+}
+
+// IsInSync は全てのノードとエッジが in_sync かを判定
+func (g *Graph) IsInSync() bool {
+	return g.Summary.Nodes[StatusInSync] == len(g.Nodes) && g.Summary.Edges[StatusInSync] == len(g.Edges)
+}
+
+// summarize は Status ごとにノードとエッジを数える
+func summarize(g *Graph) Summary {
+	summary := Summary{
+		Nodes: make(map[Status]int, len(Statuses)),
+		Edges: make(map[Status]int, len(Statuses)),
+	}
+	for _, status := range Statuses {
+		summary.Nodes[status] = 0
+		summary.Edges[status] = 0
+	}
+	for _, node := range g.Nodes {
+		summary.Nodes[node.Status]++
+	}
+	for _, edge := range g.Edges {
+		summary.Edges[edge.Status]++
+	}
+	return summary
+}
+
+// eventIndex は解決されていない drift をリソース ID とアドレスで引く
+type eventIndex struct {
+	byResource map[string][]*types.DriftEvent
+	byAddress  map[string][]*types.DriftEvent
+}
+
+func newEventIndex(events []*types.DriftEvent) *eventIndex {
+	index := &eventIndex{
+		byResource: make(map[string][]*types.DriftEvent),
+		byAddress:  make(map[string][]*types.DriftEvent),
+	}
+	for _, event := range events {
+		if event == nil || event.Status == types.DriftResolved {
+			continue
+		}
+		if event.ResourceID != "" {
+			index.byResource[event.ResourceID] = append(index.byResource[event.ResourceID], event)
+			// 短い ID に揃えたノードからも引けるようにする
+			if short := graph.ShortAWSNodeID(event.ResourceID); short != event.ResourceID {
+				index.byResource[short] = append(index.byResource[short], event)
+			}
+		}
+		if event.TerraformAddress != "" {
+			key := addressKey(event.Workspace, event.TerraformAddress)
+			index.byAddress[key] = append(index.byAddress[key], event)
+		}
+	}
+	return index
+}
+
+// lookup はリソース ID、またはワークスペースとアドレスが一致する drift を返す
+// （TFDrift エンジンの drift はリソース ID がノード ID と異なる場合がある）
+func (ix *eventIndex) lookup(resourceID, workspace, address string) []*types.DriftEvent {
+	linked := make([]*types.DriftEvent, 0)
+	seen := make(map[string]bool)
+	candidates := ix.byResource[resourceID]
+	if address != "" {
+		candidates = append(candidates[:len(candidates):len(candidates)], ix.byAddress[addressKey(workspace, address)]...)
+	}
+	for _, event := range candidates {
+		if seen[event.ID] {
+			continue
+		}
+		seen[event.ID] = true
+		linked = append(linked, event)
+	}
+	return linked
+}
+
+func addressKey(workspace, address string) string {
+	return workspace + "\x00" + address
+}
+
+// link は drift の ID（ID 順）と最も高い深刻度を返す
+func link(events []*types.DriftEvent) ([]string, types.Severity) {
+	if len(events) == 0 {
+		return nil, ""
+	}
+	ids := make([]string, 0, len(events))
+	var highest types.Severity
+	for _, event := range events {
+		ids = append(ids, event.ID)
+		if highest == "" || severity.Rank(event.Severity) > severity.Rank(highest) {
+			highest = event.Severity
+		}
+	}
+	sort.Strings(ids)
+	return ids, highest
+}
+
+// changesAny は drift がいずれかの属性（またはその下の値）を変えたかを判定
+func changesAny(event *types.DriftEvent, attributes []string) bool {
+	for path := range event.Diff {
+		root := path
+		if i := strings.IndexAny(path, ".["); i >= 0 {
+			root = path[:i]
+		}
+		for _, attribute := range attributes {
+			if root == attribute {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// edgeKey はエッジを (From, To, Type) で識別するキー
+func edgeKey(edge graph.Edge) string {
+	return edge.From + "\x00" + edge.To + "\x00" + edge.Type
+}
+
+// edgeSet はエッジをキーで索引する（重複は最初のエッジを使い、推論したエッジは除く）
+func edgeSet(g *graph.Graph) map[string]graph.Edge {
+	edges := make(map[string]graph.Edge, len(g.Edges))
+	for _, edge := range g.Edges {
+		if inferred, _ := edge.Metadata["inferred"].(bool); inferred {
+			continue
+		}
+		key := edgeKey(edge)
+		if _, exists := edges[key]; !exists {
+			edges[key] = edge
+		}
+	}
+	return edges
+}
@@ -0,0 +1,289 @@
+package overlay
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/terraform"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+// testState は VPC・Subnet・Security Group x2・EC2 x2・RDS の state
+const testState = `{
+  "version": 4,
+  "resources": [
+    {"mode": "managed", "type": "aws_vpc", "name": "main", "instances": [{"attributes": {
+      "id": "vpc-1", "arn": "arn:aws:ec2:us-east-1:111111111111:vpc/vpc-1"}}]},
+    {"mode": "managed", "type": "aws_subnet", "name": "a", "instances": [{"attributes": {
+      "id": "subnet-a", "arn": "arn:aws:ec2:us-east-1:111111111111:subnet/subnet-a", "vpc_id": "vpc-1"}}]},
+    {"mode": "managed", "type": "aws_security_group", "name": "web", "instances": [{"attributes": {
+      "id": "sg-1", "arn": "arn:aws:ec2:us-east-1:111111111111:security-group/sg-1", "vpc_id": "vpc-1"}}]},
+    {"mode": "managed", "type": "aws_security_group", "name": "admin", "instances": [{"attributes": {
+      "id": "sg-2", "arn": "arn:aws:ec2:us-east-1:111111111111:security-group/sg-2", "vpc_id": "vpc-1"}}]},
+    {"mode": "managed", "type": "aws_instance", "name": "web", "instances": [{"attributes": {
+      "id": "i-1", "arn": "arn:aws:ec2:us-east-1:111111111111:instance/i-1",
+      "subnet_id": "subnet-a", "vpc_security_group_ids": ["sg-1"], "tags": {"Name": "web"}}}]},
+    {"mode": "managed", "type": "aws_instance", "name": "old", "instances": [{"attributes": {
+      "id": "i-gone", "arn": "arn:aws:ec2:us-east-1:111111111111:instance/i-gone", "subnet_id": "subnet-a"}}]},
+    {"mode": "managed", "type": "aws_db_subnet_group", "name": "main", "instances": [{"attributes": {
+      "id": "main", "name": "main", "arn": "arn:aws:rds:us-east-1:111111111111:subgrp:main", "subnet_ids": ["subnet-a"]}}]},
+    {"mode": "managed", "type": "aws_db_instance", "name": "main", "instances": [{"attributes": {
+      "id": "db-ABC", "identifier": "db-1", "arn": "arn:aws:rds:us-east-1:111111111111:db:db-1",
+      "db_subnet_group_name": "main", "vpc_security_group_ids": ["sg-1"]}}]}
+  ]
+}`
+
+func nodeID(idType, id string) string {
+	return graph.AWSNodeID("111111111111", "us-east-1", idType, id)
+}
+
+func intendedGraph(t *testing.T) *graph.Graph {
+	t.Helper()
+	state, err := terraform.ParseState(strings.NewReader(testState))
+	if err != nil {
+		t.Fatalf("ParseState: %v", err)
+	}
+	return IntendedGraph([]Workspace{{Name: "prod", State: state}})
+}
+
+// actualGraph は i-gone が削除され、i-rogue が作成され、i-1 の Security Group が sg-2 に変わった構成
+func actualGraph() *graph.Graph {
+	g := graph.NewGraph()
+	for _, node := range []struct{ idType, id, nodeType string }{
+		{"vpc", "vpc-1", "vpc"},
+		{"subnet", "subnet-a", "subnet"},
+		{"sg", "sg-1", "security_group"},
+		{"sg", "sg-2", "security_group"},
+		{"ec2", "i-1", "ec2"},
+		{"ec2", "i-rogue", "ec2"},
+		{"rds", "db-1", "rds"},
+	} {
+		g.AddNode(graph.ResourceNode{ID: nodeID(node.idType, node.id), Type: node.nodeType, Provider: "aws", Name: node.id})
+	}
+	for _, edge := range []struct{ from, to, edgeType string }{
+		{nodeID("vpc", "vpc-1"), nodeID("subnet", "subnet-a"), "ownership"},
+		{nodeID("vpc", "vpc-1"), nodeID("sg", "sg-1"), "ownership"},
+		{nodeID("vpc", "vpc-1"), nodeID("sg", "sg-2"), "ownership"},
+		{nodeID("subnet", "subnet-a"), nodeID("ec2", "i-1"), "network"},
+		{nodeID("sg", "sg-2"), nodeID("ec2", "i-1"), "network"},
+		{nodeID("subnet", "subnet-a"), nodeID("ec2", "i-rogue"), "network"},
+		{nodeID("subnet", "subnet-a"), nodeID("rds", "db-1"), "network"},
+		{nodeID("sg", "sg-1"), nodeID("rds", "db-1"), "network"},
+	} {
+		g.AddEdge(graph.Edge{From: edge.from, To: edge.to, Type: edge.edgeType})
+	}
+	addReachability(g, nodeID("ec2", "i-1"), nodeID("rds", "db-1"))
+	return g
+}
+
+// addReachability は SkyGraph が Security Group のルールから推論する EC2 → RDS のエッジを追加する
+func addReachability(g *graph.Graph, from, to string) {
+	g.AddEdge(graph.Edge{From: from, To: to, Type: "network",
+		Metadata: map[string]interface{}{"inferred": true, "reason": "security group", "port": 5432}})
+}
+
+func testEvents() []*types.DriftEvent {
+	return []*types.DriftEvent{
+		{ID: "d1", ResourceID: nodeID("ec2", "i-1"), Type: types.DriftModified, Severity: types.SeverityHigh, Status: types.DriftOpen,
+			Diff: map[string]interface{}{"vpc_security_group_ids": map[string]interface{}{"type": "modified"}}},
+		{ID: "d2", ResourceID: nodeID("sg", "sg-2"), Type: types.DriftModified, Severity: types.SeverityLow, Status: types.DriftAcknowledged,
+			Diff: map[string]interface{}{"tags.Env": map[string]interface{}{"type": "added"}}},
+		{ID: "d3", ResourceID: nodeID("vpc", "vpc-1"), Type: types.DriftModified, Severity: types.SeverityMedium, Status: types.DriftResolved},
+		// TFDrift のようにリソース ID がノード ID と異なる drift はアドレスで紐づける
+		{ID: "d4", ResourceID: "i-gone", TerraformAddress: "aws_instance.old", Workspace: "prod", Type: types.DriftDeleted, Severity: types.SeverityCritical, Status: types.DriftOpen},
+	}
+}
+
+func TestBuildNodes(t *testing.T) {
+	overlay := Build(intendedGraph(t), actualGraph(), testEvents())
+
+	nodes := make(map[string]Node)
+	for _, node := range overlay.Nodes {
+		nodes[node.ID] = node
+	}
+	// DB サブネットグループは SkyGraph がスキャンしないため含まない
+	if len(nodes) != 8 {
+		t.Fatalf("Expected 8 nodes, got %d", len(overlay.Nodes))
+	}
+
+	tests := []struct {
+		id       string
+		status   Status
+		drifts   string
+		severity types.Severity
+	}{
+		{nodeID("vpc", "vpc-1"), StatusInSync, "[]", ""},
+		{nodeID("subnet", "subnet-a"), StatusInSync, "[]", ""},
+		{nodeID("sg", "sg-1"), StatusInSync, "[]", ""},
+		{nodeID("sg", "sg-2"), StatusDrifted, "[d2]", types.SeverityLow},
+		{nodeID("ec2", "i-1"), StatusDrifted, "[d1]", types.SeverityHigh},
+		{nodeID("ec2", "i-gone"), StatusMissing, "[d4]", types.SeverityCritical},
+		{nodeID("ec2", "i-rogue"), StatusUnmanaged, "[]", ""},
+		{nodeID("rds", "db-1"), StatusInSync, "[]", ""},
+	}
+	for _, tt := range tests {
+		node, ok := nodes[tt.id]
+		if !ok {
+			t.Errorf("%s: not in the overlay", tt.id)
+			continue
+		}
+		if node.Status != tt.status || fmt.Sprint(node.DriftEventIDs) != tt.drifts || node.Severity != tt.severity {
+			t.Errorf("%s: status %s, drifts %v, severity %q; want %s, %s, %q",
+				tt.id, node.Status, node.DriftEventIDs, node.Severity, tt.status, tt.drifts, tt.severity)
+		}
+	}
+
+	web := nodes[nodeID("ec2", "i-1")]
+	if web.TerraformAddress != "aws_instance.web" || web.Workspace != "prod" || web.Name != "i-1" {
+		t.Errorf("Drifted node should have the actual values and the Terraform address: %+v", web)
+	}
+	if gone := nodes[nodeID("ec2", "i-gone")]; gone.Metadata["terraform_address"] != "aws_instance.old" {
+		t.Errorf("Missing node should have the state values: %+v", gone)
+	}
+
+	want := map[Status]int{StatusInSync: 4, StatusDrifted: 2, StatusUnmanaged: 1, StatusMissing: 1}
+	if fmt.Sprint(overlay.Summary.Nodes) != fmt.Sprint(want) {
+		t.Errorf("Node summary = %v, want %v", overlay.Summary.Nodes, want)
+	}
+}
+
+func TestBuildEdges(t *testing.T) {
+	overlay := Build(intendedGraph(t), actualGraph(), testEvents())
+
+	edges := make(map[string]Edge)
+	for _, edge := range overlay.Edges {
+		edges[edge.From+" "+edge.To] = edge
+	}
+
+	tests := []struct {
+		from, to string
+		status   Status
+		drifts   string
+	}{
+		{nodeID("vpc", "vpc-1"), nodeID("subnet", "subnet-a"), StatusInSync, "[]"},
+		{nodeID("subnet", "subnet-a"), nodeID("ec2", "i-1"), StatusInSync, "[]"},
+		// i-1 の vpc_security_group_ids の drift で sg-1 から sg-2 に変わった
+		{nodeID("sg", "sg-1"), nodeID("ec2", "i-1"), StatusDrifted, "[d1]"},
+		{nodeID("sg", "sg-2"), nodeID("ec2", "i-1"), StatusDrifted, "[d1]"},
+		{nodeID("subnet", "subnet-a"), nodeID("ec2", "i-gone"), StatusMissing, "[]"},
+		{nodeID("subnet", "subnet-a"), nodeID("ec2", "i-rogue"), StatusUnmanaged, "[]"},
+		// RDS の Subnet は DB サブネットグループ経由で参照する
+		{nodeID("subnet", "subnet-a"), nodeID("rds", "db-1"), StatusInSync, "[]"},
+		{nodeID("sg", "sg-1"), nodeID("rds", "db-1"), StatusInSync, "[]"},
+	}
+	for _, tt := range tests {
+		edge, ok := edges[tt.from+" "+tt.to]
+		if !ok {
+			t.Errorf("%s -> %s: not in the overlay", tt.from, tt.to)
+			continue
+		}
+		if edge.Status != tt.status || fmt.Sprint(edge.DriftEventIDs) != tt.drifts {
+			t.Errorf("%s -> %s: status %s, drifts %v; want %s, %s", tt.from, tt.to, edge.Status, edge.DriftEventIDs, tt.status, tt.drifts)
+		}
+	}
+
+	// 推論した i-1 → db-1 のエッジは Terraform の参照属性に対応しないため含まない
+	if _, ok := edges[nodeID("ec2", "i-1")+" "+nodeID("rds", "db-1")]; ok {
+		t.Error("Inferred reachability edge should not be in the overlay")
+	}
+
+	want := map[Status]int{StatusInSync: 6, StatusDrifted: 2, StatusUnmanaged: 1, StatusMissing: 1}
+	if fmt.Sprint(overlay.Summary.Edges) != fmt.Sprint(want) {
+		t.Errorf("Edge summary = %v, want %v", overlay.Summary.Edges, want)
+	}
+	if overlay.IsInSync() {
+		t.Error("Overlay with drift should not be in sync")
+	}
+}
+
+func TestBuildEdgesWithoutDrift(t *testing.T) {
+	// drift が紐づかなければ、両端が管理されていても drifted にしない
+	overlay := Build(intendedGraph(t), actualGraph(), nil)
+
+	for _, edge := range overlay.Edges {
+		if edge.Status == StatusDrifted {
+			t.Errorf("%s -> %s: should not be drifted without a linked drift event", edge.From, edge.To)
+		}
+	}
+	want := map[Status]int{StatusInSync: 6, StatusDrifted: 0, StatusUnmanaged: 2, StatusMissing: 2}
+	if fmt.Sprint(overlay.Summary.Edges) != fmt.Sprint(want) {
+		t.Errorf("Edge summary = %v, want %v", overlay.Summary.Edges, want)
+	}
+}
+
+func TestBuildInSync(t *testing.T) {
+	intended := intendedGraph(t)
+
+	// state どおりの構成に、SkyGraph が EC2 → RDS の到達性を推論したエッジを加えた実際のグラフ
+	actual := graph.NewGraph()
+	for _, node := range intended.Nodes {
+		actual.AddNode(node)
+	}
+	for _, edge := range intended.Edges {
+		actual.AddEdge(edge)
+	}
+	addReachability(actual, nodeID("ec2", "i-1"), nodeID("rds", "db-1"))
+
+	overlay := Build(intended, actual, nil)
+	if !overlay.IsInSync() {
+		t.Errorf("Overlay of a clean account should be in sync: %+v", overlay.Summary)
+	}
+}
+
+func TestBuildShortIDs(t *testing.T) {
+	// 単一アカウント・単一リージョンのスキャンは短い ID を使う
+	short := graph.NewGraph()
+	for _, node := range actualGraph().Nodes {
+		node.ID = graph.ShortAWSNodeID(node.ID)
+		short.AddNode(node)
+	}
+	for _, edge := range actualGraph().Edges {
+		edge.From, edge.To = graph.ShortAWSNodeID(edge.From), graph.ShortAWSNodeID(edge.To)
+		short.AddEdge(edge)
+	}
+
+	overlay := Build(intendedGraph(t), short, testEvents())
+
+	nodes := make(map[string]Node)
+	for _, node := range overlay.Nodes {
+		nodes[node.ID] = node
+	}
+	if web := nodes["aws:ec2:i-1"]; web.Status != StatusDrifted || fmt.Sprint(web.DriftEventIDs) != "[d1]" || web.TerraformAddress != "aws_instance.web" {
+		t.Errorf("Drifted node should be matched by its short ID: %+v", web)
+	}
+
+	nodeSummary := map[Status]int{StatusInSync: 4, StatusDrifted: 2, StatusUnmanaged: 1, StatusMissing: 1}
+	edgeSummary := map[Status]int{StatusInSync: 6, StatusDrifted: 2, StatusUnmanaged: 1, StatusMissing: 1}
+	if fmt.Sprint(overlay.Summary.Nodes) != fmt.Sprint(nodeSummary) || fmt.Sprint(overlay.Summary.Edges) != fmt.Sprint(edgeSummary) {
+		t.Errorf("Summary = %+v, want nodes %v and edges %v", overlay.Summary, nodeSummary, edgeSummary)
+	}
+}
+
+func TestWriteMermaid(t *testing.T) {
+	overlay := Build(intendedGraph(t), actualGraph(), testEvents())
+	overlay.Nodes[0].Name = `say "hi"`
+
+	var b strings.Builder
+	if err := overlay.WriteMermaid(&b); err != nil {
+		t.Fatalf("WriteMermaid: %v", err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		"flowchart LR\n",
+		`["say #quot;hi#quot;<br/>`,
+		":::missing\n",
+		`-->|"network (unmanaged)"|`,
+		"classDef drifted ",
+		"linkStyle ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Mermaid output should contain %q:\n%s", want, out)
+		}
+	}
+	if got := strings.Count(out, "-->"); got != len(overlay.Edges) {
+		t.Errorf("Expected %d links, got %d", len(overlay.Edges), got)
+	}
+}
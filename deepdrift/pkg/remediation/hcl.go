@@ -0,0 +1,275 @@
+package remediation
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/terraform"
+)
+
+// writeResource は resource ブロックを書き出す
+func writeResource(b *strings.Builder, r target, attrs map[string]interface{}) {
+	fmt.Fprintf(b, "resource %s %s {\n", hclString(r.resourceType), hclString(r.name))
+	writeBody(b, attrs, 1, false)
+	b.WriteString("}\n")
+}
+
+// writeBody はブロックの本体を書き出す（terraform fmt と同じく属性を先に、ネストしたブロックを後に書く）
+// skipEmpty の場合は空の値の属性を省く
+func writeBody(b *strings.Builder, attrs map[string]interface{}, depth int, skipEmpty bool) {
+	indent := strings.Repeat("  ", depth)
+
+	var names, blocks []string
+	for _, name := range sortedKeys(attrs) {
+		v := attrs[name]
+		switch {
+		case isBlock(v):
+			blocks = append(blocks, name)
+		case skipEmpty && isEmpty(v):
+		default:
+			names = append(names, name)
+		}
+	}
+
+	writeAttributes(b, indent, names, func(name string) string { return hclValue(attrs[name], depth) })
+	for i, name := range blocks {
+		for j, item := range blockItems(attrs[name]) {
+			if len(names) > 0 || i > 0 || j > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(b, "%s%s {\n", indent, name)
+			writeBody(b, item, depth+1, true)
+			fmt.Fprintf(b, "%s}\n", indent)
+		}
+	}
+}
+
+// hclValue は値を HCL の式で返す（depth はその属性のネストの深さ）
+func hclValue(v interface{}, depth int) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return hclString(val)
+	case bool:
+		return strconv.FormatBool(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case int, int32, int64:
+		return fmt.Sprintf("%d", val)
+	case json.Number:
+		return val.String()
+	case []string:
+		items := make([]interface{}, len(val))
+		for i, s := range val {
+			items[i] = s
+		}
+		return hclValue(items, depth)
+	case map[string]string:
+		m := make(map[string]interface{}, len(val))
+		for k, s := range val {
+			m[k] = s
+		}
+		return hclValue(m, depth)
+	case []interface{}:
+		return hclList(val, depth)
+	case map[string]interface{}:
+		return hclObject(val, depth)
+	default:
+		return hclString(fmt.Sprint(val))
+	}
+}
+
+// hclList はリストを返す（オブジェクトを含む場合は要素ごとに改行する）
+func hclList(items []interface{}, depth int) string {
+	multiline := false
+	values := make([]string, len(items))
+	for i, item := range items {
+		values[i] = hclValue(item, depth+1)
+		if strings.Contains(values[i], "\n") {
+			multiline = true
+		}
+	}
+	if !multiline {
+		return "[" + strings.Join(values, ", ") + "]"
+	}
+
+	indent := strings.Repeat("  ", depth)
+	var b strings.Builder
+	b.WriteString("[\n")
+	for _, value := range values {
+		fmt.Fprintf(&b, "%s  %s,\n", indent, value)
+	}
+	b.WriteString(indent + "]")
+	return b.String()
+}
+
+// hclObject はオブジェクト（タグなど）を複数行で返す
+func hclObject(m map[string]interface{}, depth int) string {
+	if len(m) == 0 {
+		return "{}"
+	}
+
+	indent := strings.Repeat("  ", depth)
+	var b strings.Builder
+	b.WriteString("{\n")
+	writeAttributes(&b, indent+"  ", sortedKeys(m), func(k string) string { return hclValue(m[k], depth+1) })
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+// writeAttributes は "name = value" を書き出す
+// terraform fmt と同じく、続けて並ぶ1行の属性は "=" の位置を揃える（複数行の値はそこで揃えるのをやめる）
+func writeAttributes(b *strings.Builder, indent string, names []string, value func(string) string) {
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = value(name)
+	}
+
+	for start := 0; start < len(names); {
+		end, width := start, 0
+		for end < len(names) && !strings.Contains(values[end], "\n") {
+			width = max(width, len(hclKey(names[end])))
+			end++
+		}
+		for i := start; i < end; i++ {
+			fmt.Fprintf(b, "%s%-*s = %s\n", indent, width, hclKey(names[i]), values[i])
+		}
+		if end < len(names) {
+			fmt.Fprintf(b, "%s%s = %s\n", indent, hclKey(names[end]), values[end])
+			end++
+		}
+		start = end
+	}
+}
+
+// hclString は文字列リテラルを返す（"${" と "%{" はテンプレートにならないようエスケープ）
+func hclString(s string) string {
+	quoted := strconv.Quote(s)
+	quoted = strings.ReplaceAll(quoted, "${", "$${")
+	return strings.ReplaceAll(quoted, "%{", "%%{")
+}
+
+// identifier は引用符なしで書ける HCL の識別子
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// hclKey は属性名・オブジェクトのキーを返す（識別子でない場合は引用符で囲む）
+func hclKey(k string) string {
+	if identifier.MatchString(k) {
+		return k
+	}
+	return hclString(k)
+}
+
+// isBlock はネストしたブロック（オブジェクトのリスト）として書く値かを判定
+// Terraform の state ではブロック (ingress, versioning など) はオブジェクトのリストになる
+func isBlock(v interface{}) bool {
+	return len(blockItems(v)) > 0
+}
+
+// blockItems はオブジェクトのリストを返す（それ以外の値は nil）
+func blockItems(v interface{}) []map[string]interface{} {
+	switch val := v.(type) {
+	case []map[string]interface{}:
+		return val
+	case []interface{}:
+		items := make([]map[string]interface{}, 0, len(val))
+		for _, item := range val {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			items = append(items, m)
+		}
+		return items
+	}
+	return nil
+}
+
+// isEmpty は空の値（null、空文字、空のリスト・マップ）かを判定
+func isEmpty(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return val == ""
+	case []interface{}:
+		return len(val) == 0
+	case []string:
+		return len(val) == 0
+	case map[string]interface{}:
+		return len(val) == 0
+	case map[string]string:
+		return len(val) == 0
+	}
+	return false
+}
+
+// configValue は drift の値を Terraform のコードに書く形に戻す
+// ネイティブエンジンは比較のために値を正規化している（ルールは "tcp:22-22:0.0.0.0/0"、バージョニングは真偽値）
+func configValue(name string, v interface{}) interface{} {
+	switch name {
+	case "ingress", "egress":
+		if rules := terraform.StringSlice(v); len(rules) > 0 && blockItems(v) == nil {
+			return ruleBlocks(rules)
+		}
+	case "versioning":
+		if enabled, ok := v.(bool); ok {
+			return []interface{}{map[string]interface{}{"enabled": enabled}}
+		}
+	}
+	return v
+}
+
+// rulePorts はルールのポート範囲 ("22-22", "-1--1")
+var rulePorts = regexp.MustCompile(`^(-?\d+)-(-?\d+)$`)
+
+// ruleBlocks は "protocol:ports:peer" のルールを、プロトコル・ポートごとの ingress / egress ブロックにまとめる
+func ruleBlocks(rules []string) []interface{} {
+	var blocks []interface{}
+	byKey := make(map[string]map[string]interface{})
+
+	for _, rule := range rules {
+		parts := strings.SplitN(rule, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		protocol, ports, peer := parts[0], parts[1], parts[2]
+
+		key := protocol + ":" + ports
+		block, ok := byKey[key]
+		if !ok {
+			block = map[string]interface{}{"protocol": protocol, "from_port": float64(0), "to_port": float64(0)}
+			if protocol == "all" {
+				block["protocol"] = "-1"
+			} else if m := rulePorts.FindStringSubmatch(ports); m != nil {
+				from, _ := strconv.ParseFloat(m[1], 64)
+				to, _ := strconv.ParseFloat(m[2], 64)
+				block["from_port"], block["to_port"] = from, to
+			}
+			byKey[key] = block
+			blocks = append(blocks, block)
+		}
+
+		peers, _ := block[peerAttribute(peer)].([]interface{})
+		block[peerAttribute(peer)] = append(peers, peer)
+	}
+	return blocks
+}
+
+// peerAttribute はルールの相手を書く属性を返す
+func peerAttribute(peer string) string {
+	switch {
+	case strings.HasPrefix(peer, "pl-"):
+		return "prefix_list_ids"
+	case strings.HasPrefix(peer, "sg-"):
+		return "security_groups"
+	case strings.Contains(peer, ":"):
+		return "ipv6_cidr_blocks"
+	default:
+		return "cidr_blocks"
+	}
+}
@@ -0,0 +1,487 @@
+package remediation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/drift"
+	"github.com/higakikeita/airdig/deepdrift/pkg/terraform"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// Strategy は drift への対応方法
+type Strategy string
+
+const (
+	// Accept は drift を Terraform のコードに取り込む
+	Accept Strategy = "accept"
+
+	// Revert は実際のリソースを Terraform の定義に戻す
+	Revert Strategy = "revert"
+)
+
+// ParseStrategy は文字列を Strategy に変換（空文字は Accept）
+func ParseStrategy(s string) (Strategy, error) {
+	switch Strategy(s) {
+	case "", Accept:
+		return Accept, nil
+	case Revert:
+		return Revert, nil
+	default:
+		return "", fmt.Errorf("unknown remediation strategy %q (accept or revert)", s)
+	}
+}
+
+// Plan は drift イベントの修正計画
+type Plan struct {
+	// DriftEventID は対象の drift イベント ID
+	DriftEventID string `json:"drift_event_id"`
+
+	// ResourceID はリソースの識別子
+	ResourceID string `json:"resource_id"`
+
+	// ResourceType はリソースタイプ
+	ResourceType string `json:"resource_type"`
+
+	// TerraformAddress は修正する Terraform のリソースアドレス
+	TerraformAddress string `json:"terraform_address"`
+
+	// DriftType は drift のタイプ
+	DriftType types.DriftType `json:"drift_type"`
+
+	// Strategy は対応方法 (accept, revert)
+	Strategy Strategy `json:"strategy"`
+
+	// Summary は修正内容の要約
+	Summary string `json:"summary"`
+
+	// Patch は drift をコードに取り込む HCL（resource ブロック、import ブロックまたは removed ブロック）
+	Patch string `json:"patch,omitempty"`
+
+	// Changes は修正で変わる属性と値
+	Changes []Change `json:"changes,omitempty"`
+
+	// Commands は修正のために実行するコマンド
+	Commands []string `json:"commands,omitempty"`
+
+	// Notes は自動では修正できない属性などの注意点
+	Notes []string `json:"notes,omitempty"`
+}
+
+// Change は修正で変わる属性を表す
+// Accept ではコードの値が From から To に、Revert では実際のリソースの値が From から To に変わる
+type Change struct {
+	// Path は属性のパス (e.g., "instance_type", "ingress[2]", "tags.Env")
+	Path string `json:"path"`
+
+	// Action は変更の種類 (set, add, remove)
+	Action string `json:"action"`
+
+	// From は修正前の値
+	From interface{} `json:"from,omitempty"`
+
+	// To は修正後の値
+	To interface{} `json:"to,omitempty"`
+}
+
+// computedAttributes は AWS が決める属性（コードに書けないため、取り込む値から除く）
+var computedAttributes = map[string]bool{
+	"id":                           true,
+	"arn":                          true,
+	"owner_id":                     true,
+	"tags_all":                     true,
+	"instance_state":               true,
+	"region":                       true,
+	"public_ip":                    true,
+	"public_dns":                   true,
+	"private_dns":                  true,
+	"primary_network_interface_id": true,
+	"bucket_domain_name":           true,
+	"bucket_regional_domain_name":  true,
+	"hosted_zone_id":               true,
+}
+
+// Generate は drift イベントの修正計画を作成
+func Generate(event *types.DriftEvent, strategy Strategy) (*Plan, error) {
+	r := newTarget(event)
+	plan := &Plan{
+		DriftEventID:     event.ID,
+		ResourceID:       event.ResourceID,
+		ResourceType:     event.ResourceType,
+		TerraformAddress: r.address,
+		DriftType:        event.Type,
+		Strategy:         strategy,
+	}
+	if !r.known {
+		plan.Notes = append(plan.Notes, fmt.Sprintf(
+			"The Terraform address of %s is unknown; replace %s with the address of the resource in your code", r.cloudID, r.address))
+	}
+
+	switch {
+	case strategy == Accept && event.Type == types.DriftModified:
+		acceptModified(plan, event, r)
+	case strategy == Accept && event.Type == types.DriftCreated:
+		acceptCreated(plan, event, r)
+	case strategy == Accept && event.Type == types.DriftDeleted:
+		acceptDeleted(plan, event, r)
+	case strategy == Revert && event.Type == types.DriftModified:
+		revertModified(plan, event, r)
+	case strategy == Revert && event.Type == types.DriftCreated:
+		revertCreated(plan, event, r)
+	case strategy == Revert && event.Type == types.DriftDeleted:
+		revertDeleted(plan, event, r)
+	case strategy != Accept && strategy != Revert:
+		return nil, fmt.Errorf("unknown remediation strategy %q", strategy)
+	default:
+		return nil, fmt.Errorf("unknown drift type %q", event.Type)
+	}
+	return plan, nil
+}
+
+// acceptModified は変化した属性を実際の値にする resource ブロックを作成
+func acceptModified(plan *Plan, event *types.DriftEvent, r target) {
+	attrs := make(map[string]interface{})
+	for _, name := range changedAttributes(event) {
+		if computedAttributes[name] {
+			plan.Notes = append(plan.Notes, fmt.Sprintf("%s is set by AWS and cannot be changed in Terraform code", name))
+			continue
+		}
+		attrs[name] = configValue(name, event.After[name])
+		plan.Changes = append(plan.Changes, Change{Path: name, Action: "set", From: event.Before[name], To: event.After[name]})
+	}
+
+	names := attributeList(plan.Changes)
+	plan.Summary = fmt.Sprintf("Set %s of %s to the current values to accept the drift", names, r.address)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Accept the drift of %s (%s)\n", r.address, event.ID)
+	fmt.Fprintf(&b, "# Update %s in the resource block:\n", names)
+	writeResource(&b, r, attrs)
+	plan.Patch = b.String()
+
+	plan.Commands = []string{"terraform plan -target=" + shellQuote(r.address)}
+	plan.Notes = append(plan.Notes, "terraform plan should show no changes for the resource after the code is updated")
+}
+
+// acceptCreated は Terraform の管理外で作成されたリソースを import ブロックで取り込む
+func acceptCreated(plan *Plan, event *types.DriftEvent, r target) {
+	attrs := configAttributes(event.After)
+	plan.Summary = fmt.Sprintf("Import %s into Terraform as %s", r.cloudID, r.address)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s was created outside Terraform (%s)\n", r.cloudID, event.ID)
+	fmt.Fprintf(&b, "import {\n  to = %s\n  id = %s\n}\n\n", r.address, hclString(r.cloudID))
+	writeResource(&b, r, attrs)
+	plan.Patch = b.String()
+
+	for _, name := range sortedKeys(attrs) {
+		plan.Changes = append(plan.Changes, Change{Path: name, Action: "set", To: event.After[name]})
+	}
+	plan.Commands = []string{"terraform plan", "terraform apply"}
+	plan.Notes = append(plan.Notes,
+		"Review the resource block; terraform plan should only show the import",
+		"Alternatively, drop the resource block and run terraform plan -generate-config-out=generated.tf")
+}
+
+// acceptDeleted は Terraform の外で削除されたリソースを removed ブロックで state から外す
+func acceptDeleted(plan *Plan, event *types.DriftEvent, r target) {
+	plan.Summary = fmt.Sprintf("Remove %s from Terraform code and state", r.address)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s was deleted outside Terraform (%s)\n", r.address, event.ID)
+	if r.indexed() {
+		// removed ブロックはインスタンス単位では指定できない
+		fmt.Fprintf(&b, "# Remove its key from count or for_each of %s; terraform apply then forgets the deleted instance\n", r.resourceAddress())
+	} else {
+		fmt.Fprintf(&b, "# Delete its resource block and add this block to forget it without destroying anything:\n")
+		fmt.Fprintf(&b, "removed {\n  from = %s\n\n  lifecycle {\n    destroy = false\n  }\n}\n", r.address)
+		plan.Notes = append(plan.Notes, "On Terraform before 1.7, run terraform state rm "+shellQuote(r.address)+" instead of adding the removed block")
+	}
+	plan.Patch = b.String()
+
+	plan.Commands = []string{"terraform plan", "terraform apply"}
+}
+
+// revertModified は変化した属性を Terraform の値に戻す
+func revertModified(plan *Plan, event *types.DriftEvent, r target) {
+	paths := make([]string, 0, len(event.Diff))
+	for path := range event.Diff {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		change, _ := event.Diff[path].(map[string]interface{})
+		plan.Changes = append(plan.Changes, Change{
+			Path:   path,
+			Action: revertActions[fmt.Sprint(change["type"])],
+			From:   change["after"],
+			To:     change["before"],
+		})
+
+		if name := attributeName(path); computedAttributes[name] {
+			plan.Notes = append(plan.Notes, fmt.Sprintf("%s is set by AWS; terraform apply does not restore it", path))
+		}
+	}
+
+	plan.Summary = fmt.Sprintf("Restore %s of %s with terraform apply", attributeList(plan.Changes), r.address)
+	plan.Commands = []string{
+		"terraform plan -target=" + shellQuote(r.address),
+		"terraform apply -target=" + shellQuote(r.address),
+	}
+}
+
+// revertActions は drift の差分の種類ごとに、元に戻す変更の種類
+// drift で追加された値は削除し、削除された値は追加する
+var revertActions = map[string]string{
+	string(drift.ChangeAdded):    "remove",
+	string(drift.ChangeDeleted):  "add",
+	string(drift.ChangeModified): "set",
+}
+
+// revertCreated は Terraform の管理外で作成されたリソースを削除する
+func revertCreated(plan *Plan, event *types.DriftEvent, r target) {
+	plan.Summary = fmt.Sprintf("Delete %s, which is not managed by Terraform", r.cloudID)
+	if command := deleteCommand(event, r.cloudID); command != "" {
+		plan.Commands = []string{command}
+	} else {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("Delete %s (%s) in the AWS console or CLI", r.cloudID, event.ResourceType))
+	}
+	plan.Notes = append(plan.Notes, "Check that nothing depends on the resource before deleting it")
+}
+
+// revertDeleted は Terraform の外で削除されたリソースを state の値で作り直す
+func revertDeleted(plan *Plan, event *types.DriftEvent, r target) {
+	for _, name := range sortedKeys(configAttributes(event.Before)) {
+		plan.Changes = append(plan.Changes, Change{Path: name, Action: "set", To: event.Before[name]})
+	}
+
+	plan.Summary = fmt.Sprintf("Recreate %s with terraform apply", r.address)
+	plan.Commands = []string{
+		"terraform plan -target=" + shellQuote(r.address),
+		"terraform apply -target=" + shellQuote(r.address),
+	}
+	plan.Notes = append(plan.Notes, "The recreated resource gets a new ID; resources referring to the old ID must be updated too")
+}
+
+// target は修正する Terraform のリソース
+type target struct {
+	// resourceType は Terraform のリソースタイプ (e.g., "aws_instance")
+	resourceType string
+
+	// name は resource ブロックの名前
+	name string
+
+	// address はインスタンスのアドレス (e.g., "module.app.aws_instance.web[0]")
+	address string
+
+	// cloudID はクラウド側の ID (e.g., "i-0abc1234")
+	cloudID string
+
+	// known は address が drift イベントに記録されていたか（false の場合は ID から作った仮のアドレス）
+	known bool
+}
+
+// newTarget は drift イベントから修正する Terraform のリソースを求める
+func newTarget(event *types.DriftEvent) target {
+	r := target{cloudID: cloudID(event)}
+
+	if event.TerraformAddress != "" {
+		// module.app.aws_instance.web[0] → aws_instance, web
+		base := event.TerraformAddress
+		if strings.HasSuffix(base, "]") {
+			if i := strings.LastIndex(base, "["); i > 0 {
+				base = base[:i]
+			}
+		}
+		parts := strings.Split(base, ".")
+		if len(parts) >= 2 {
+			r.resourceType, r.name = parts[len(parts)-2], parts[len(parts)-1]
+			r.address = event.TerraformAddress
+			r.known = true
+			return r
+		}
+	}
+
+	r.resourceType = event.ResourceType
+	if !strings.HasPrefix(r.resourceType, "aws_") {
+		r.resourceType = terraform.ResourceType(event.ResourceType)
+	}
+	r.name = resourceName(r.cloudID)
+	r.address = r.resourceType + "." + r.name
+	// 管理外で作成されたリソースは import 先のアドレスを新しく決める
+	r.known = event.Type == types.DriftCreated
+	return r
+}
+
+// resourceAddress はインデックスを除いたリソースのアドレスを返す
+func (r target) resourceAddress() string {
+	if r.indexed() {
+		return r.address[:strings.LastIndex(r.address, "[")]
+	}
+	return r.address
+}
+
+// indexed は count または for_each のインスタンスかを判定
+func (r target) indexed() bool {
+	return strings.HasSuffix(r.address, "]")
+}
+
+// cloudID はクラウド側の ID を返す（state の id・bucket、なければノード ID の末尾）
+func cloudID(event *types.DriftEvent) string {
+	for _, state := range []map[string]interface{}{event.After, event.Before} {
+		for _, key := range []string{"id", "bucket"} {
+			if v, ok := state[key].(string); ok && v != "" {
+				return v
+			}
+		}
+	}
+	return event.ResourceID[strings.LastIndex(event.ResourceID, ":")+1:]
+}
+
+// resourceName は ID から resource ブロックの名前を作る (e.g., "i-0abc1234" → "i_0abc1234")
+func resourceName(id string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(id) {
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' {
+			b.WriteRune(c)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	name := b.String()
+	if name == "" || name[0] >= '0' && name[0] <= '9' || name[0] == '_' {
+		name = "r_" + name
+	}
+	return name
+}
+
+// changedAttributes は Diff のパスから変化した属性名を重複なく返す
+func changedAttributes(event *types.DriftEvent) []string {
+	seen := make(map[string]bool)
+	for path := range event.Diff {
+		seen[attributeName(path)] = true
+	}
+	return sortedKeys(seen)
+}
+
+// attributeName はパスの先頭の属性名を返す (e.g., "ingress[2].cidr_blocks[0]" → "ingress")
+func attributeName(path string) string {
+	if i := strings.IndexAny(path, ".["); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// configAttributes はコードに書ける属性を返す（AWS が決める属性と空の値を除く）
+func configAttributes(state map[string]interface{}) map[string]interface{} {
+	attrs := make(map[string]interface{}, len(state))
+	for name, v := range state {
+		if computedAttributes[name] || isEmpty(v) {
+			continue
+		}
+		attrs[name] = configValue(name, v)
+	}
+	return attrs
+}
+
+// attributeList は変更する属性を列挙した文字列を返す
+func attributeList(changes []Change) string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, c := range changes {
+		name := attributeName(c.Path)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "no attributes"
+	}
+	return strings.Join(names, ", ")
+}
+
+// deleteCommands はリソースタイプごとの削除コマンド（%s はクラウド側の ID）
+var deleteCommands = map[string]string{
+	"ec2":            "aws ec2 terminate-instances --instance-ids %s",
+	"security_group": "aws ec2 delete-security-group --group-id %s",
+	"s3":             "aws s3 rb s3://%s",
+	"vpc":            "aws ec2 delete-vpc --vpc-id %s",
+	"subnet":         "aws ec2 delete-subnet --subnet-id %s",
+	"rds":            "aws rds delete-db-instance --db-instance-identifier %s",
+}
+
+// deleteCommand は管理外のリソースを削除する AWS CLI のコマンドを返す（未対応のタイプは空文字）
+func deleteCommand(event *types.DriftEvent, id string) string {
+	format, ok := deleteCommands[event.ResourceType]
+	if !ok {
+		return ""
+	}
+	command := fmt.Sprintf(format, shellQuote(id))
+
+	// ノード ID は "aws:<account>:<region>:<type>:<id>" の形式の場合がある
+	if parts := strings.Split(event.ResourceID, ":"); len(parts) == 5 && parts[2] != "" && event.ResourceType != "s3" {
+		command += " --region " + parts[2]
+	}
+	return command
+}
+
+// shellQuote はシェルの引数として必要な場合だけ単一引用符で囲む
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_./:", c))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteFiles は修正計画をディレクトリに書き出し、作成したファイルのパスを返す
+// 計画ごとに <drift イベント ID>.json を作り、HCL のパッチがある場合は <drift イベント ID>.tf も作る
+func WriteFiles(dir string, plans []*Plan) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	var files []string
+	for _, plan := range plans {
+		name := plan.DriftEventID
+		if name == "" {
+			name = resourceName(plan.ResourceID)
+		}
+
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return files, fmt.Errorf("failed to marshal remediation plan %s: %w", name, err)
+		}
+		path := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+			return files, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		files = append(files, path)
+
+		if plan.Patch == "" {
+			continue
+		}
+		path = filepath.Join(dir, name+".tf")
+		if err := os.WriteFile(path, []byte(plan.Patch), 0o644); err != nil {
+			return files, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
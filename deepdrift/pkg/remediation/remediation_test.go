@@ -0,0 +1,261 @@
+package remediation
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// securityGroupDrift はネイティブエンジンが検出した Security Group の drift（ルールは正規化済み）
+func securityGroupDrift() *types.DriftEvent {
+	return &types.DriftEvent{
+		ID:               "drift-sg",
+		ResourceID:       "aws:111122223333:us-east-1:sg:sg-0a1b2c3d",
+		ResourceType:     "security_group",
+		TerraformAddress: "module.net.aws_security_group.web",
+		Type:             types.DriftModified,
+		Before: map[string]interface{}{
+			"ingress": []string{"tcp:443-443:0.0.0.0/0"},
+			"tags":    map[string]string{"Env": "prod"},
+		},
+		After: map[string]interface{}{
+			"ingress": []string{"all:*:10.0.0.0/8", "tcp:22-22:0.0.0.0/0", "tcp:22-22:::/0", "tcp:443-443:0.0.0.0/0"},
+			"tags":    map[string]string{"Env": "staging", "Owner ID": "${team}"},
+		},
+		Diff: map[string]interface{}{
+			"ingress[0]":       map[string]interface{}{"type": "added", "after": "all:*:10.0.0.0/8"},
+			"ingress[1]":       map[string]interface{}{"type": "added", "after": "tcp:22-22:0.0.0.0/0"},
+			"ingress[2]":       map[string]interface{}{"type": "added", "after": "tcp:22-22:::/0"},
+			"tags.Env":         map[string]interface{}{"type": "modified", "before": "prod", "after": "staging"},
+			`tags["Owner ID"]`: map[string]interface{}{"type": "added", "after": "${team}"},
+		},
+	}
+}
+
+func TestGenerate_AcceptModified(t *testing.T) {
+	plan, err := Generate(securityGroupDrift(), Accept)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	expected := `# Accept the drift of module.net.aws_security_group.web (drift-sg)
+# Update ingress, tags in the resource block:
+resource "aws_security_group" "web" {
+  tags = {
+    Env        = "staging"
+    "Owner ID" = "$${team}"
+  }
+
+  ingress {
+    cidr_blocks = ["10.0.0.0/8"]
+    from_port   = 0
+    protocol    = "-1"
+    to_port     = 0
+  }
+
+  ingress {
+    cidr_blocks      = ["0.0.0.0/0"]
+    from_port        = 22
+    ipv6_cidr_blocks = ["::/0"]
+    protocol         = "tcp"
+    to_port          = 22
+  }
+
+  ingress {
+    cidr_blocks = ["0.0.0.0/0"]
+    from_port   = 443
+    protocol    = "tcp"
+    to_port     = 443
+  }
+}
+`
+	if plan.Patch != expected {
+		t.Errorf("Unexpected patch:\n%s", plan.Patch)
+	}
+	if len(plan.Changes) != 2 || plan.Changes[0].Path != "ingress" || plan.Changes[1].Path != "tags" {
+		t.Errorf("Unexpected changes: %+v", plan.Changes)
+	}
+	if plan.TerraformAddress != "module.net.aws_security_group.web" || len(plan.Commands) != 1 {
+		t.Errorf("Unexpected plan: %+v", plan)
+	}
+}
+
+func TestGenerate_AcceptCreated(t *testing.T) {
+	event := &types.DriftEvent{
+		ID:           "drift-new",
+		ResourceID:   "aws:ec2:i-0abc1234",
+		ResourceType: "ec2",
+		Type:         types.DriftCreated,
+		After: map[string]interface{}{
+			"id":            "i-0abc1234",
+			"arn":           "arn:aws:ec2:us-east-1:111122223333:instance/i-0abc1234",
+			"ami":           "ami-0123456789",
+			"instance_type": "t3.micro",
+			"key_name":      "",
+			"root_block_device": []interface{}{
+				map[string]interface{}{"volume_size": float64(8), "encrypted": true, "kms_key_id": ""},
+			},
+		},
+	}
+
+	plan, err := Generate(event, Accept)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	// AWS が決める属性 (id, arn) と空の値はコードに書かない
+	expected := `# i-0abc1234 was created outside Terraform (drift-new)
+import {
+  to = aws_instance.i_0abc1234
+  id = "i-0abc1234"
+}
+
+resource "aws_instance" "i_0abc1234" {
+  ami           = "ami-0123456789"
+  instance_type = "t3.micro"
+
+  root_block_device {
+    encrypted   = true
+    volume_size = 8
+  }
+}
+`
+	if plan.Patch != expected {
+		t.Errorf("Unexpected patch:\n%s", plan.Patch)
+	}
+	if plan.TerraformAddress != "aws_instance.i_0abc1234" || len(plan.Changes) != 3 {
+		t.Errorf("Unexpected plan: %+v", plan)
+	}
+}
+
+func TestGenerate_AcceptDeleted(t *testing.T) {
+	event := &types.DriftEvent{
+		ID:               "drift-del",
+		ResourceID:       "aws:s3:app-logs",
+		ResourceType:     "s3",
+		TerraformAddress: "aws_s3_bucket.logs",
+		Type:             types.DriftDeleted,
+		Before:           map[string]interface{}{"bucket": "app-logs"},
+	}
+
+	plan, err := Generate(event, Accept)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if !strings.Contains(plan.Patch, "removed {\n  from = aws_s3_bucket.logs\n\n  lifecycle {\n    destroy = false\n  }\n}\n") {
+		t.Errorf("Expected a removed block, got:\n%s", plan.Patch)
+	}
+
+	// count / for_each のインスタンスは removed ブロックで指定できない
+	event.TerraformAddress = `aws_s3_bucket.logs["app"]`
+	plan, err = Generate(event, Accept)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if strings.Contains(plan.Patch, "removed {") || !strings.Contains(plan.Patch, "for_each of aws_s3_bucket.logs") {
+		t.Errorf("Unexpected patch:\n%s", plan.Patch)
+	}
+}
+
+func TestGenerate_RevertModified(t *testing.T) {
+	plan, err := Generate(securityGroupDrift(), Revert)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	if plan.Patch != "" {
+		t.Errorf("Expected no patch, got:\n%s", plan.Patch)
+	}
+	if len(plan.Changes) != 5 {
+		t.Fatalf("Expected 5 changes, got %+v", plan.Changes)
+	}
+
+	// drift で追加されたルールは削除し、変わったタグは元の値に戻す
+	if c := plan.Changes[0]; c.Path != "ingress[0]" || c.Action != "remove" || c.From != "all:*:10.0.0.0/8" {
+		t.Errorf("Unexpected change: %+v", c)
+	}
+	if c := plan.Changes[3]; c.Path != "tags.Env" || c.Action != "set" || c.From != "staging" || c.To != "prod" {
+		t.Errorf("Unexpected change: %+v", c)
+	}
+	if plan.Commands[1] != "terraform apply -target=module.net.aws_security_group.web" {
+		t.Errorf("Unexpected commands: %v", plan.Commands)
+	}
+}
+
+func TestGenerate_Revert(t *testing.T) {
+	// 管理外で作成されたリソースは削除する
+	created := &types.DriftEvent{
+		ID:           "drift-new",
+		ResourceID:   "aws:111122223333:eu-west-1:sg:sg-0ffe",
+		ResourceType: "security_group",
+		Type:         types.DriftCreated,
+	}
+	plan, err := Generate(created, Revert)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(plan.Commands) != 1 || plan.Commands[0] != "aws ec2 delete-security-group --group-id sg-0ffe --region eu-west-1" {
+		t.Errorf("Unexpected commands: %v", plan.Commands)
+	}
+
+	// 削除されたリソースは state の値で作り直す（アドレスが分からない場合は注意点に書く）
+	deleted := &types.DriftEvent{
+		ID:           "drift-del",
+		ResourceID:   "aws:ec2:i-0abc1234",
+		ResourceType: "ec2",
+		Type:         types.DriftDeleted,
+		Before:       map[string]interface{}{"id": "i-0abc1234", "instance_type": "t3.micro"},
+	}
+	plan, err = Generate(deleted, Revert)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(plan.Changes) != 1 || plan.Changes[0].Path != "instance_type" || plan.Changes[0].To != "t3.micro" {
+		t.Errorf("Unexpected changes: %+v", plan.Changes)
+	}
+	if plan.TerraformAddress != "aws_instance.i_0abc1234" || !strings.Contains(plan.Notes[0], "address of i-0abc1234 is unknown") {
+		t.Errorf("Unexpected plan: %+v", plan)
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	accept, err := Generate(securityGroupDrift(), Accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revert, err := Generate(securityGroupDrift(), Revert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revert.DriftEventID = "drift-sg-revert"
+
+	dir := filepath.Join(t.TempDir(), "remediation")
+	files, err := WriteFiles(dir, []*Plan{accept, revert})
+	if err != nil {
+		t.Fatalf("WriteFiles() error = %v", err)
+	}
+
+	// パッチがない計画は JSON だけ書き出す
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 files, got %v", files)
+	}
+	patch, err := os.ReadFile(filepath.Join(dir, "drift-sg.tf"))
+	if err != nil || string(patch) != accept.Patch {
+		t.Errorf("Unexpected patch file: %s (%v)", patch, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "drift-sg-revert.json")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	if s, err := ParseStrategy(""); err != nil || s != Accept {
+		t.Errorf("ParseStrategy(\"\") = %s, %v", s, err)
+	}
+	if _, err := ParseStrategy("ignore"); err == nil {
+		t.Error("Expected error")
+	}
+}
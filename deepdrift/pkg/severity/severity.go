@@ -0,0 +1,470 @@
+// Package severity は設定ファイルのルールで drift とインパクトの深刻度を計算する
+package severity
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/glob"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+// DefaultRules は既定のルール
+// drift の種類とセキュリティ関連リソースで drift の深刻度を決め、
+// 影響を受けるリソースが多い場合やセキュリティ関連リソースに影響する場合に引き上げる
+const DefaultRules = `
+drift:
+  - name: modified
+    description: Resource was modified outside Terraform
+    when:
+      drift_types: [modified]
+    severity: medium
+
+  - name: deleted
+    description: Resource was deleted outside Terraform
+    when:
+      drift_types: [deleted]
+    severity: high
+
+  - name: security-resource
+    description: Security-related resource drifted
+    when:
+      resource_types: &security [security_group, iam_role, iam_policy, kms_key]
+    severity: high
+
+  - name: security-resource-deleted
+    description: Security-related resource was deleted
+    when:
+      drift_types: [deleted]
+      resource_types: *security
+    severity: critical
+
+impact:
+  - name: large-blast-radius
+    description: More than 10 resources are affected
+    when:
+      min_affected: 11
+    escalate: 1
+
+  - name: security-resource-affected
+    description: A security-related resource is affected
+    when:
+      affected_resource_types: *security
+    escalate: 1
+    max: high
+`
+
+// Condition はルールの条件（指定した項目をすべて満たすと一致する）
+type Condition struct {
+	// DriftTypes は drift の種類のいずれか
+	DriftTypes []types.DriftType `yaml:"drift_types"`
+
+	// ResourceTypes はリソースタイプのいずれか
+	ResourceTypes []string `yaml:"resource_types"`
+
+	// Attributes は変化した属性のパス（Diff のキー）の glob のいずれか
+	// パスに一致すると、その下の値（"ingress" に対する "ingress[0].cidr_blocks[0]" など）にも一致する
+	Attributes []string `yaml:"attributes"`
+
+	// Tags はリソースのタグ（すべて一致。値が "*" の場合はキーだけで判定）
+	Tags map[string]string `yaml:"tags"`
+
+	// InternetExposed はリソースがインターネットに公開されているか
+	InternetExposed *bool `yaml:"internet_exposed"`
+
+	// MinAffected は影響を受けるリソース数の下限（impact のみ）
+	MinAffected int `yaml:"min_affected"`
+
+	// MinBlastRadius は影響範囲（ホップ数）の下限（impact のみ）
+	MinBlastRadius int `yaml:"min_blast_radius"`
+
+	// AffectedResourceTypes は影響を受けるリソースのタイプのいずれか（impact のみ）
+	AffectedResourceTypes []string `yaml:"affected_resource_types"`
+
+	attributes []*regexp.Regexp
+}
+
+// Rule は深刻度のルール
+// Severity は深刻度の下限を設定し、Escalate は深刻度を段階数だけ引き上げる（Max を超えない）
+type Rule struct {
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description"`
+	When        Condition      `yaml:"when"`
+	Severity    types.Severity `yaml:"severity"`
+	Escalate    int            `yaml:"escalate"`
+	Max         types.Severity `yaml:"max"`
+}
+
+// Engine はルールで深刻度を計算する
+//
+// drift のルールは検出時に low から順に適用し、impact のルールはインパクト分析で
+// drift の深刻度から順に適用する。一致したルールは結果に記録する。
+type Engine struct {
+	Drift  []Rule `yaml:"drift"`
+	Impact []Rule `yaml:"impact"`
+}
+
+var defaultEngine = mustParse(DefaultRules)
+
+// Default は既定のルールの Engine を返す
+func Default() *Engine {
+	return defaultEngine
+}
+
+// Load はルールファイル (YAML) を読み込む
+// ファイルのルールは既定のルールを置き換える
+func Load(path string) (*Engine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read severity rules: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse はルール (YAML) をパース
+func Parse(data []byte) (*Engine, error) {
+	var engine Engine
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&engine); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse severity rules: %w", err)
+	}
+
+	if err := validate(engine.Drift, "drift", false); err != nil {
+		return nil, err
+	}
+	if err := validate(engine.Impact, "impact", true); err != nil {
+		return nil, err
+	}
+	return &engine, nil
+}
+
+func mustParse(data string) *Engine {
+	engine, err := Parse([]byte(data))
+	if err != nil {
+		panic(err)
+	}
+	return engine
+}
+
+// validate はルールを検証し、属性のパターンをコンパイルする
+func validate(rules []Rule, stage string, impact bool) error {
+	seen := make(map[string]bool, len(rules))
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("%s rule %d: name is required", stage, i)
+		}
+		if seen[rule.Name] {
+			return fmt.Errorf("%s rule %s: duplicate name", stage, rule.Name)
+		}
+		seen[rule.Name] = true
+
+		if (rule.Severity == "") == (rule.Escalate == 0) {
+			return fmt.Errorf("%s rule %s: exactly one of severity or escalate is required", stage, rule.Name)
+		}
+		for _, severity := range []types.Severity{rule.Severity, rule.Max} {
+			if severity != "" && Rank(severity) < 0 {
+				return fmt.Errorf("%s rule %s: invalid severity %q (available: low, medium, high, critical)", stage, rule.Name, severity)
+			}
+		}
+
+		when := &rule.When
+		if !impact && (when.MinAffected > 0 || when.MinBlastRadius > 0 || len(when.AffectedResourceTypes) > 0) {
+			return fmt.Errorf("%s rule %s: affected resource conditions are only available in impact rules", stage, rule.Name)
+		}
+		when.attributes = make([]*regexp.Regexp, len(when.Attributes))
+		for j, path := range when.Attributes {
+			when.attributes[j] = glob.CompilePath(path)
+		}
+	}
+	return nil
+}
+
+// Result は深刻度の計算結果
+type Result struct {
+	Severity types.Severity
+	Rules    []types.SeverityRule
+}
+
+// Facts はルールの判定に使う drift とインパクトの情報
+type Facts struct {
+	DriftType       types.DriftType
+	ResourceType    string
+	ChangedPaths    []string
+	Tags            map[string]string
+	InternetExposed bool
+
+	AffectedCount         int
+	BlastRadius           int
+	AffectedResourceTypes []string
+}
+
+// DriftFacts は drift イベントから Facts を作成
+func DriftFacts(event *types.DriftEvent) Facts {
+	paths := make([]string, 0, len(event.Diff))
+	for path := range event.Diff {
+		paths = append(paths, path)
+	}
+	return Facts{
+		DriftType:       event.Type,
+		ResourceType:    event.ResourceType,
+		ChangedPaths:    paths,
+		Tags:            event.ResourceTags(),
+		InternetExposed: InternetExposed(event.After) || InternetExposed(event.Before),
+	}
+}
+
+// ScoreDrift は drift の深刻度を計算
+func (e *Engine) ScoreDrift(event *types.DriftEvent) Result {
+	return apply(e.rules().Drift, types.SeverityLow, DriftFacts(event))
+}
+
+// Apply は drift の深刻度を計算し、event の Severity と SeverityRules に設定する
+// Engine が nil の場合は既定のルールを使う
+func (e *Engine) Apply(event *types.DriftEvent) *types.DriftEvent {
+	if event == nil {
+		return nil
+	}
+	result := e.ScoreDrift(event)
+	event.Severity = result.Severity
+	event.SeverityRules = result.Rules
+	return event
+}
+
+// rules は Engine が nil の場合に既定のルールを返す
+func (e *Engine) rules() *Engine {
+	if e == nil {
+		return defaultEngine
+	}
+	return e
+}
+
+// ScoreImpact はインパクト分析の深刻度を drift の深刻度から計算
+// node はグラフ上のリソース（ない場合は nil）で、タグと公開状態の判定に使う
+func (e *Engine) ScoreImpact(event *types.DriftEvent, node *graph.ResourceNode, affected []types.AffectedResource) Result {
+	facts := DriftFacts(event)
+	if node != nil {
+		for k, v := range node.Tags {
+			if _, ok := facts.Tags[k]; !ok {
+				facts.Tags[k] = v
+			}
+		}
+		facts.InternetExposed = facts.InternetExposed || InternetExposed(node.Metadata)
+	}
+
+	facts.AffectedCount = len(affected)
+	for _, resource := range affected {
+		if resource.Distance > facts.BlastRadius {
+			facts.BlastRadius = resource.Distance
+		}
+		facts.AffectedResourceTypes = append(facts.AffectedResourceTypes, resource.ResourceType)
+	}
+
+	base := event.Severity
+	if Rank(base) < 0 {
+		base = types.SeverityLow
+	}
+	result := apply(e.rules().Impact, base, facts)
+	result.Rules = append(append([]types.SeverityRule{}, event.SeverityRules...), result.Rules...)
+	return result
+}
+
+// apply はルールを順に適用する
+func apply(rules []Rule, base types.Severity, facts Facts) Result {
+	result := Result{Severity: base}
+	for _, rule := range rules {
+		if !rule.When.matches(facts) {
+			continue
+		}
+
+		next := result.Severity
+		if rule.Severity != "" {
+			next = maxSeverity(next, rule.Severity)
+		} else {
+			next = escalate(next, rule.Escalate, rule.Max)
+		}
+
+		result.Severity = next
+		result.Rules = append(result.Rules, types.SeverityRule{
+			Name:        rule.Name,
+			Description: rule.Description,
+			Severity:    next,
+		})
+	}
+	return result
+}
+
+// matches は Facts が条件をすべて満たすかを判定
+func (c *Condition) matches(f Facts) bool {
+	if len(c.DriftTypes) > 0 && !containsDriftType(c.DriftTypes, f.DriftType) {
+		return false
+	}
+	if len(c.ResourceTypes) > 0 && !containsAny(c.ResourceTypes, f.ResourceType) {
+		return false
+	}
+	if len(c.attributes) > 0 && !matchesAnyPath(c.attributes, f.ChangedPaths) {
+		return false
+	}
+	for key, want := range c.Tags {
+		value, ok := f.Tags[key]
+		if !ok || (want != "*" && value != want) {
+			return false
+		}
+	}
+	if c.InternetExposed != nil && *c.InternetExposed != f.InternetExposed {
+		return false
+	}
+	if f.AffectedCount < c.MinAffected || f.BlastRadius < c.MinBlastRadius {
+		return false
+	}
+	if len(c.AffectedResourceTypes) > 0 && !containsAny(c.AffectedResourceTypes, f.AffectedResourceTypes...) {
+		return false
+	}
+	return true
+}
+
+// InternetExposed はリソースの属性からインターネットに公開されているかを判定
+// パブリック IP・公開設定・internet-facing のロードバランサー、
+// または 0.0.0.0/0 か ::/0 からの受信を許可する Security Group のルールがある場合に公開とみなす
+func InternetExposed(attributes map[string]interface{}) bool {
+	for key, value := range attributes {
+		switch key {
+		case "public_ip", "public_ip_address", "public_dns":
+			if s, ok := value.(string); ok && s != "" {
+				return true
+			}
+		case "associate_public_ip_address", "publicly_accessible", "map_public_ip_on_launch":
+			if b, ok := value.(bool); ok && b {
+				return true
+			}
+		case "scheme":
+			if value == "internet-facing" {
+				return true
+			}
+		case "ingress", "ingress_rules":
+			if openToInternet(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// openToInternet は受信ルールに 0.0.0.0/0 または ::/0 が含まれるかを判定
+// ルールは Terraform・SkyGraph の形式と、展開した "protocol:ports:peer" の文字列を扱う
+func openToInternet(v interface{}) bool {
+	switch val := v.(type) {
+	case string:
+		return val == "0.0.0.0/0" || val == "::/0" ||
+			strings.HasSuffix(val, ":0.0.0.0/0") || strings.HasSuffix(val, ":::/0")
+	case []string:
+		for _, item := range val {
+			if openToInternet(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range val {
+			if openToInternet(item) {
+				return true
+			}
+		}
+	case []map[string]interface{}:
+		for _, item := range val {
+			if openToInternet(item) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range []string{"cidr_blocks", "ipv6_cidr_blocks"} {
+			if openToInternet(val[key]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Rank は深刻度の順位を返す（low が 0、critical が 3、不明な深刻度は -1）
+func Rank(s types.Severity) int {
+	switch s {
+	case types.SeverityLow:
+		return 0
+	case types.SeverityMedium:
+		return 1
+	case types.SeverityHigh:
+		return 2
+	case types.SeverityCritical:
+		return 3
+	}
+	return -1
+}
+
+var levels = []types.Severity{types.SeverityLow, types.SeverityMedium, types.SeverityHigh, types.SeverityCritical}
+
+// maxSeverity は高い方の深刻度を返す
+func maxSeverity(a, b types.Severity) types.Severity {
+	if Rank(b) > Rank(a) {
+		return b
+	}
+	return a
+}
+
+// escalate は深刻度を steps 段階引き上げる（max を超えない。すでに max 以上の場合は変えない）
+func escalate(s types.Severity, steps int, max types.Severity) types.Severity {
+	limit := len(levels) - 1
+	if max != "" {
+		limit = Rank(max)
+	}
+	if Rank(s) >= limit {
+		return s
+	}
+	next := Rank(s) + steps
+	if next > limit {
+		next = limit
+	}
+	if next < 0 {
+		next = 0
+	}
+	return levels[next]
+}
+
+func containsDriftType(list []types.DriftType, v types.DriftType) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAny は values のいずれかが list に含まれるかを判定
+func containsAny(list []string, values ...string) bool {
+	for _, item := range list {
+		for _, v := range values {
+			if item == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchesAnyPath(patterns []*regexp.Regexp, paths []string) bool {
+	for _, pattern := range patterns {
+		for _, path := range paths {
+			if pattern.MatchString(path) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package severity
+
+import (
+	"testing"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+func TestDefault_ScoreDrift(t *testing.T) {
+	tests := []struct {
+		name         string
+		driftType    types.DriftType
+		resourceType string
+		expected     types.Severity
+	}{
+		{name: "deleted security group", driftType: types.DriftDeleted, resourceType: "security_group", expected: types.SeverityCritical},
+		{name: "modified security group", driftType: types.DriftModified, resourceType: "security_group", expected: types.SeverityHigh},
+		{name: "deleted IAM role", driftType: types.DriftDeleted, resourceType: "iam_role", expected: types.SeverityCritical},
+		{name: "modified IAM policy", driftType: types.DriftModified, resourceType: "iam_policy", expected: types.SeverityHigh},
+		{name: "deleted EC2", driftType: types.DriftDeleted, resourceType: "ec2", expected: types.SeverityHigh},
+		{name: "modified EC2", driftType: types.DriftModified, resourceType: "ec2", expected: types.SeverityMedium},
+		{name: "created S3 bucket", driftType: types.DriftCreated, resourceType: "s3", expected: types.SeverityLow},
+		{name: "created VPC", driftType: types.DriftCreated, resourceType: "vpc", expected: types.SeverityLow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := &types.DriftEvent{Type: tt.driftType, ResourceType: tt.resourceType}
+			if result := Default().ScoreDrift(event); result.Severity != tt.expected {
+				t.Errorf("ScoreDrift(%s, %s) = %s; want %s",
+					tt.driftType, tt.resourceType, result.Severity, tt.expected)
+			}
+		})
+	}
+}
+
+const testRules = `
+drift:
+  - name: modified
+    when:
+      drift_types: [modified]
+    severity: medium
+  - name: production
+    description: Production resources
+    when:
+      tags: {Environment: production}
+    escalate: 1
+  - name: open-ingress
+    when:
+      resource_types: [security_group]
+      attributes: [ingress]
+      internet_exposed: true
+    severity: critical
+  - name: instance-role
+    when:
+      attributes: [iam_instance_profile]
+    severity: high
+
+impact:
+  - name: wide
+    when:
+      min_affected: 3
+      min_blast_radius: 2
+    escalate: 1
+  - name: database
+    when:
+      affected_resource_types: [rds]
+    escalate: 2
+    max: high
+`
+
+func TestEngine_ScoreDrift(t *testing.T) {
+	engine, err := Parse([]byte(testRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		event    *types.DriftEvent
+		expected types.Severity
+		rules    []string
+	}{
+		{
+			name: "no rule matches",
+			event: &types.DriftEvent{
+				Type: types.DriftCreated, ResourceType: "ec2",
+			},
+			expected: types.SeverityLow,
+		},
+		{
+			name: "production tag escalates",
+			event: &types.DriftEvent{
+				Type: types.DriftModified, ResourceType: "ec2",
+				After: map[string]interface{}{"tags": map[string]interface{}{"Environment": "production"}},
+				Diff:  map[string]interface{}{"instance_type": map[string]interface{}{}},
+			},
+			expected: types.SeverityHigh,
+			rules:    []string{"modified", "production"},
+		},
+		{
+			name: "ingress opened to the internet",
+			event: &types.DriftEvent{
+				Type: types.DriftModified, ResourceType: "security_group",
+				After: map[string]interface{}{"ingress": []interface{}{"tcp:22-22:0.0.0.0/0"}},
+				Diff:  map[string]interface{}{"ingress[0]": map[string]interface{}{}},
+			},
+			expected: types.SeverityCritical,
+			rules:    []string{"modified", "open-ingress"},
+		},
+		{
+			name: "ingress inside the VPC",
+			event: &types.DriftEvent{
+				Type: types.DriftModified, ResourceType: "security_group",
+				After: map[string]interface{}{"ingress": []interface{}{"tcp:22-22:10.0.0.0/8"}},
+				Diff:  map[string]interface{}{"ingress[0]": map[string]interface{}{}},
+			},
+			expected: types.SeverityMedium,
+			rules:    []string{"modified"},
+		},
+		{
+			name: "changed attribute",
+			event: &types.DriftEvent{
+				Type: types.DriftModified, ResourceType: "ec2",
+				Diff: map[string]interface{}{"iam_instance_profile": map[string]interface{}{}},
+			},
+			expected: types.SeverityHigh,
+			rules:    []string{"modified", "instance-role"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := engine.Apply(tt.event)
+			if event.Severity != tt.expected {
+				t.Errorf("Expected severity %s, got %s", tt.expected, event.Severity)
+			}
+			if len(event.SeverityRules) != len(tt.rules) {
+				t.Fatalf("Expected rules %v, got %+v", tt.rules, event.SeverityRules)
+			}
+			for i, name := range tt.rules {
+				if event.SeverityRules[i].Name != name {
+					t.Errorf("SeverityRules[%d] = %s; want %s", i, event.SeverityRules[i].Name, name)
+				}
+			}
+		})
+	}
+}
+
+func TestEngine_ScoreImpact(t *testing.T) {
+	engine, err := Parse([]byte(testRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := &types.DriftEvent{
+		Type:          types.DriftModified,
+		ResourceType:  "ec2",
+		Severity:      types.SeverityLow,
+		SeverityRules: []types.SeverityRule{{Name: "modified", Severity: types.SeverityLow}},
+	}
+	affected := []types.AffectedResource{
+		{ResourceType: "subnet", Distance: 1},
+		{ResourceType: "vpc", Distance: 2},
+		{ResourceType: "rds", Distance: 1},
+	}
+
+	result := engine.ScoreImpact(event, &graph.ResourceNode{Tags: map[string]string{"Environment": "production"}}, affected)
+
+	// low → medium (wide) → high (database: 2段階だが max は high)
+	if result.Severity != types.SeverityHigh {
+		t.Errorf("Expected severity high, got %s", result.Severity)
+	}
+	if len(result.Rules) != 3 || result.Rules[0].Name != "modified" || result.Rules[2].Name != "database" {
+		t.Errorf("Expected drift and impact rules to be recorded, got %+v", result.Rules)
+	}
+
+	// max を超えている場合は引き下げない
+	event.Severity = types.SeverityCritical
+	if result := engine.ScoreImpact(event, nil, affected[2:]); result.Severity != types.SeverityCritical {
+		t.Errorf("Expected severity critical, got %s", result.Severity)
+	}
+}
+
+func TestInternetExposed(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes map[string]interface{}
+		expected   bool
+	}{
+		{name: "public ip", attributes: map[string]interface{}{"public_ip": "203.0.113.5"}, expected: true},
+		{name: "empty public ip", attributes: map[string]interface{}{"public_ip": ""}, expected: false},
+		{name: "publicly accessible", attributes: map[string]interface{}{"publicly_accessible": true}, expected: true},
+		{name: "internet-facing load balancer", attributes: map[string]interface{}{"scheme": "internet-facing"}, expected: true},
+		{
+			name: "terraform ingress rule",
+			attributes: map[string]interface{}{"ingress": []interface{}{
+				map[string]interface{}{"from_port": float64(443), "ipv6_cidr_blocks": []interface{}{"::/0"}},
+			}},
+			expected: true,
+		},
+		{
+			name:       "egress is not exposure",
+			attributes: map[string]interface{}{"egress": []interface{}{"-1:0-0:0.0.0.0/0"}},
+			expected:   false,
+		},
+		{name: "private", attributes: map[string]interface{}{"private_ip": "10.0.0.5"}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := InternetExposed(tt.attributes); result != tt.expected {
+				t.Errorf("InternetExposed() = %v; want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"missing name":          "drift:\n  - severity: high\n",
+		"duplicate name":        "drift:\n  - name: a\n    severity: high\n  - name: a\n    severity: low\n",
+		"severity and escalate": "drift:\n  - name: a\n    severity: high\n    escalate: 1\n",
+		"no effect":             "drift:\n  - name: a\n",
+		"invalid severity":      "drift:\n  - name: a\n    severity: urgent\n",
+		"impact condition":      "drift:\n  - name: a\n    when: {min_affected: 3}\n    severity: high\n",
+		"invalid yaml":          "drift: {",
+		"unknown field":         "drift:\n  - name: a\n    when: {drift_type: [modified]}\n    severity: high\n",
+		"misspelled stage":      "drifts:\n  - name: a\n    severity: high\n",
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse([]byte(data)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
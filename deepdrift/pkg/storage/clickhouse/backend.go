@@ -0,0 +1,29 @@
+package clickhouse
+
+import (
+	"context"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage"
+)
+
+var _ storage.Backend = (*Client)(nil)
+
+// Name identifies the ClickHouse backend
+func (c *Client) Name() string {
+	return "clickhouse"
+}
+
+// DriftStore returns a drift store on this connection
+func (c *Client) DriftStore() storage.DriftStore {
+	return NewDriftStore(c)
+}
+
+// ImpactStore returns an impact store on this connection
+func (c *Client) ImpactStore() storage.ImpactStore {
+	return NewImpactStore(c)
+}
+
+// Health returns the HealthCheck status
+func (c *Client) Health(ctx context.Context) (interface{}, error) {
+	return c.HealthCheck(ctx)
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
@@ -146,20 +147,29 @@ func (c *Client) Stats() driver.Stats {
 	return c.conn.Stats()
 }
 
-// InitSchema initializes the database schema
-func (c *Client) InitSchema(ctx context.Context, schemaSQL string) error {
-	// Split schema into individual statements and execute
-	// This is a simplified version - in production you might want to use a migration tool
+// CreateDatabase creates the database of the config if it does not exist.
+// NewClient cannot connect to a database that does not exist yet, so it
+// connects to the default database first.
+func CreateDatabase(config *Config) error {
+	if config == nil {
+		config = DefaultConfig()
+	}
 
-	// For now, just execute the entire schema
-	// Note: ClickHouse doesn't support multi-statement execution in a single Exec call
-	// so you'd need to split by semicolons and execute separately
+	bootstrap := *config
+	bootstrap.Database = "default"
+	client, err := NewClient(&bootstrap)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
-	// Create database
-	if err := c.Exec(ctx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", c.config.Database)); err != nil {
+	database := "`" + strings.ReplaceAll(config.Database, "`", "\\`") + "`"
+	if err := client.Exec(ctx, "CREATE DATABASE IF NOT EXISTS "+database); err != nil {
 		return fmt.Errorf("failed to create database: %w", err)
 	}
-
 	return nil
 }
 
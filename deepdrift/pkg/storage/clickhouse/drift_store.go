@@ -2,10 +2,14 @@ package clickhouse
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/higakikeita/airdig/deepdrift/pkg/drift"
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage"
 	"github.com/higakikeita/airdig/deepdrift/pkg/types"
 )
 
@@ -14,6 +18,8 @@ type DriftStore struct {
 	client *Client
 }
 
+var _ storage.DriftStore = (*DriftStore)(nil)
+
 // NewDriftStore creates a new drift store
 func NewDriftStore(client *Client) *DriftStore {
 	return &DriftStore{
@@ -26,14 +32,14 @@ func (s *DriftStore) SaveDriftEvent(ctx context.Context, event *types.DriftEvent
 	query := `
 		INSERT INTO drift_events (
 			id, resource_id, resource_type, drift_type, severity,
-			timestamp, state_before, state_after, diff,
+			timestamp, state_before, state_after, diff, severity_rules,
 			cloudtrail_event_id, event_name, user_identity, user_arn,
-			source_ip, root_cause_timestamp, date
+			source_ip, root_cause_timestamp, root_cause_candidates, tags, date
 		) VALUES (
+			?, ?, ?, ?, ?,
 			?, ?, ?, ?, ?,
 			?, ?, ?, ?,
-			?, ?, ?, ?,
-			?, ?, ?
+			?, ?, ?, ?, ?
 		)
 	`
 
@@ -41,6 +47,8 @@ func (s *DriftStore) SaveDriftEvent(ctx context.Context, event *types.DriftEvent
 	stateBefore, _ := json.Marshal(event.Before)
 	stateAfter, _ := json.Marshal(event.After)
 	diff, _ := json.Marshal(event.Diff)
+	severityRules, _ := json.Marshal(severityRulesOrEmpty(event.SeverityRules))
+	candidates, _ := json.Marshal(rootCausesOrEmpty(event.RootCauseCandidates))
 
 	// Extract root cause fields
 	var cloudtrailEventID, eventName, userIdentity, userARN, sourceIP string
@@ -64,12 +72,15 @@ func (s *DriftStore) SaveDriftEvent(ctx context.Context, event *types.DriftEvent
 		string(stateBefore),
 		string(stateAfter),
 		string(diff),
+		string(severityRules),
 		cloudtrailEventID,
 		eventName,
 		userIdentity,
 		userARN,
 		sourceIP,
 		rootCauseTimestamp,
+		string(candidates),
+		event.ResourceTags(),
 		event.Timestamp.Truncate(24*time.Hour), // date
 	)
 }
@@ -83,9 +94,9 @@ func (s *DriftStore) SaveDriftEvents(ctx context.Context, events []*types.DriftE
 	batch, err := s.client.PrepareBatch(ctx, `
 		INSERT INTO drift_events (
 			id, resource_id, resource_type, drift_type, severity,
-			timestamp, state_before, state_after, diff,
+			timestamp, state_before, state_after, diff, severity_rules,
 			cloudtrail_event_id, event_name, user_identity, user_arn,
-			source_ip, root_cause_timestamp, date
+			source_ip, root_cause_timestamp, root_cause_candidates, tags, date
 		)
 	`)
 	if err != nil {
@@ -96,6 +107,8 @@ func (s *DriftStore) SaveDriftEvents(ctx context.Context, events []*types.DriftE
 		stateBefore, _ := json.Marshal(event.Before)
 		stateAfter, _ := json.Marshal(event.After)
 		diff, _ := json.Marshal(event.Diff)
+		severityRules, _ := json.Marshal(severityRulesOrEmpty(event.SeverityRules))
+		candidates, _ := json.Marshal(rootCausesOrEmpty(event.RootCauseCandidates))
 
 		var cloudtrailEventID, eventName, userIdentity, userARN, sourceIP string
 		var rootCauseTimestamp time.Time
@@ -118,12 +131,15 @@ func (s *DriftStore) SaveDriftEvents(ctx context.Context, events []*types.DriftE
 			string(stateBefore),
 			string(stateAfter),
 			string(diff),
+			string(severityRules),
 			cloudtrailEventID,
 			eventName,
 			userIdentity,
 			userARN,
 			sourceIP,
 			rootCauseTimestamp,
+			string(candidates),
+			event.ResourceTags(),
 			event.Timestamp.Truncate(24*time.Hour),
 		); err != nil {
 			return fmt.Errorf("failed to append to batch: %w", err)
@@ -137,27 +153,38 @@ func (s *DriftStore) SaveDriftEvents(ctx context.Context, events []*types.DriftE
 	return nil
 }
 
-// GetDriftEvent retrieves a single drift event by ID
-func (s *DriftStore) GetDriftEvent(ctx context.Context, id string) (*types.DriftEvent, error) {
-	query := `
-		SELECT
-			id, resource_id, resource_type, drift_type, severity,
-			timestamp, state_before, state_after, diff,
-			cloudtrail_event_id, event_name, user_identity, user_arn,
-			source_ip, root_cause_timestamp
-		FROM drift_events
-		WHERE id = ?
-		LIMIT 1
-	`
+// driftEventQuery selects drift events joined with their latest lifecycle status
+const driftEventQuery = `
+	SELECT
+		e.id, e.resource_id, e.resource_type, e.drift_type, e.severity,
+		e.timestamp, e.state_before, e.state_after, e.diff, e.severity_rules,
+		e.cloudtrail_event_id, e.event_name, e.user_identity, e.user_arn,
+		e.source_ip, e.root_cause_timestamp, e.root_cause_candidates,
+		s.workspace AS workspace,
+		if(s.id = '', 'open', toString(s.status)) AS status,
+		if(s.id = '', e.timestamp, s.first_seen) AS first_seen,
+		if(s.id = '', e.timestamp, s.last_seen) AS last_seen,
+		s.resolved_at, s.acknowledged_by, s.acknowledged_at
+	FROM drift_events AS e
+	LEFT JOIN (SELECT * FROM drift_event_status FINAL) AS s ON s.id = e.id
+	WHERE 1=1
+`
+
+// rowScanner is implemented by both driver.Row and driver.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
+// scanDriftEvent scans a row selected by driftEventQuery
+func scanDriftEvent(row rowScanner) (*types.DriftEvent, error) {
 	var event types.DriftEvent
-	var driftType, severity string
-	var stateBefore, stateAfter, diff string
+	var driftType, severity, status string
+	var stateBefore, stateAfter, diff, severityRules string
 	var cloudtrailEventID, eventName, userIdentity, userARN, sourceIP string
 	var rootCauseTimestamp time.Time
+	var candidates string
 
-	row := s.client.QueryRow(ctx, query, id)
-	err := row.Scan(
+	if err := row.Scan(
 		&event.ID,
 		&event.ResourceID,
 		&event.ResourceType,
@@ -167,27 +194,42 @@ func (s *DriftStore) GetDriftEvent(ctx context.Context, id string) (*types.Drift
 		&stateBefore,
 		&stateAfter,
 		&diff,
+		&severityRules,
 		&cloudtrailEventID,
 		&eventName,
 		&userIdentity,
 		&userARN,
 		&sourceIP,
 		&rootCauseTimestamp,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get drift event: %w", err)
+		&candidates,
+		&event.Workspace,
+		&status,
+		&event.FirstSeen,
+		&event.LastSeen,
+		&event.ResolvedAt,
+		&event.AcknowledgedBy,
+		&event.AcknowledgedAt,
+	); err != nil {
+		return nil, err
 	}
 
 	event.Type = types.DriftType(driftType)
 	event.Severity = types.Severity(severity)
+	event.Status = types.DriftStatus(status)
 
 	// Parse JSON strings
 	json.Unmarshal([]byte(stateBefore), &event.Before)
 	json.Unmarshal([]byte(stateAfter), &event.After)
 	json.Unmarshal([]byte(diff), &event.Diff)
-
-	// Set root cause if present
-	if cloudtrailEventID != "" {
+	json.Unmarshal([]byte(severityRules), &event.SeverityRules)
+	json.Unmarshal([]byte(candidates), &event.RootCauseCandidates)
+
+	// Set root cause if present. The top CloudTrail candidate is the root cause
+	// and also carries the confidence.
+	if len(event.RootCauseCandidates) > 0 && event.RootCauseCandidates[0].CloudTrailEventID == cloudtrailEventID {
+		rootCause := event.RootCauseCandidates[0]
+		event.RootCause = &rootCause
+	} else if cloudtrailEventID != "" {
 		event.RootCause = &types.RootCause{
 			CloudTrailEventID: cloudtrailEventID,
 			EventName:         eventName,
@@ -201,48 +243,79 @@ func (s *DriftStore) GetDriftEvent(ctx context.Context, id string) (*types.Drift
 	return &event, nil
 }
 
+// GetDriftEvent retrieves a single drift event by ID
+func (s *DriftStore) GetDriftEvent(ctx context.Context, id string) (*types.DriftEvent, error) {
+	query := driftEventQuery + " AND e.id = ? LIMIT 1"
+
+	event, err := scanDriftEvent(s.client.QueryRow(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", drift.ErrDriftNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get drift event: %w", err)
+	}
+
+	return event, nil
+}
+
 // ListDriftEvents lists drift events with filters
-func (s *DriftStore) ListDriftEvents(ctx context.Context, filter *DriftEventFilter) ([]*types.DriftEvent, error) {
-	query := `
-		SELECT
-			id, resource_id, resource_type, drift_type, severity,
-			timestamp, state_before, state_after, diff,
-			cloudtrail_event_id, event_name, user_identity, user_arn,
-			source_ip, root_cause_timestamp
-		FROM drift_events
-		WHERE 1=1
-	`
+func (s *DriftStore) ListDriftEvents(ctx context.Context, filter *storage.DriftEventFilter) ([]*types.DriftEvent, error) {
+	query := driftEventQuery
 
 	args := []interface{}{}
 
 	if filter != nil {
 		if !filter.StartTime.IsZero() {
-			query += " AND timestamp >= ?"
+			query += " AND e.timestamp >= ?"
 			args = append(args, filter.StartTime)
 		}
 		if !filter.EndTime.IsZero() {
-			query += " AND timestamp <= ?"
+			query += " AND e.timestamp <= ?"
 			args = append(args, filter.EndTime)
 		}
 		if filter.ResourceType != "" {
-			query += " AND resource_type = ?"
+			query += " AND e.resource_type = ?"
 			args = append(args, filter.ResourceType)
 		}
 		if filter.DriftType != "" {
-			query += " AND drift_type = ?"
+			query += " AND e.drift_type = ?"
 			args = append(args, string(filter.DriftType))
 		}
 		if filter.Severity != "" {
-			query += " AND severity = ?"
+			query += " AND e.severity = ?"
 			args = append(args, string(filter.Severity))
 		}
 		if filter.UserIdentity != "" {
-			query += " AND user_identity = ?"
+			query += " AND e.user_identity = ?"
 			args = append(args, filter.UserIdentity)
 		}
+		if len(filter.Statuses) > 0 {
+			statuses := make([]string, len(filter.Statuses))
+			for i, status := range filter.Statuses {
+				statuses[i] = string(status)
+			}
+			query += " AND status IN ?"
+			args = append(args, statuses)
+		}
+		if len(filter.Workspaces) > 0 {
+			query += " AND workspace IN ?"
+			args = append(args, filter.Workspaces)
+		}
+
+		conds, condArgs := queryConditions(filter.Query, driftQueryColumns, "e.id")
+		query += conds
+		args = append(args, condArgs...)
+
+		after, afterArgs := driftSortColumns.after(filter.After)
+		query += after
+		args = append(args, afterArgs...)
 	}
 
-	query += " ORDER BY timestamp DESC"
+	var order storage.SortOrder
+	if filter != nil {
+		order = filter.Sort
+	}
+	query += " ORDER BY " + driftSortColumns.orderBy(order) + " LIMIT 1 BY e.id"
 
 	if filter != nil && filter.Limit > 0 {
 		query += " LIMIT ?"
@@ -257,59 +330,181 @@ func (s *DriftStore) ListDriftEvents(ctx context.Context, filter *DriftEventFilt
 
 	events := []*types.DriftEvent{}
 	for rows.Next() {
-		var event types.DriftEvent
-		var driftType, severity string
-		var stateBefore, stateAfter, diff string
-		var cloudtrailEventID, eventName, userIdentity, userARN, sourceIP string
-		var rootCauseTimestamp time.Time
+		event, err := scanDriftEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// ListUnresolvedDriftEvents lists open and acknowledged drift events.
+// When workspaces is not empty only events of those workspaces are returned.
+func (s *DriftStore) ListUnresolvedDriftEvents(ctx context.Context, workspaces []string) ([]*types.DriftEvent, error) {
+	return s.ListDriftEvents(ctx, &storage.DriftEventFilter{
+		Statuses:   []types.DriftStatus{types.DriftOpen, types.DriftAcknowledged},
+		Workspaces: workspaces,
+	})
+}
 
-		if err := rows.Scan(
-			&event.ID,
-			&event.ResourceID,
-			&event.ResourceType,
-			&driftType,
-			&severity,
-			&event.Timestamp,
-			&stateBefore,
-			&stateAfter,
-			&diff,
-			&cloudtrailEventID,
-			&eventName,
-			&userIdentity,
-			&userARN,
-			&sourceIP,
-			&rootCauseTimestamp,
+// SaveDriftStatuses records the current lifecycle status of drift events
+func (s *DriftStore) SaveDriftStatuses(ctx context.Context, events []*types.DriftEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	batch, err := s.client.PrepareBatch(ctx, `
+		INSERT INTO drift_event_status (
+			id, workspace, status, first_seen, last_seen,
+			resolved_at, acknowledged_by, acknowledged_at, updated_at
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	now := time.Now()
+	for _, event := range events {
+		status := event.Status
+		if status == "" {
+			status = types.DriftOpen
+		}
+		if err := batch.Append(
+			event.ID,
+			event.Workspace,
+			string(status),
+			event.FirstSeen,
+			event.LastSeen,
+			event.ResolvedAt,
+			event.AcknowledgedBy,
+			event.AcknowledgedAt,
+			now,
 		); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+			return fmt.Errorf("failed to append to batch: %w", err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send batch: %w", err)
+	}
+
+	return nil
+}
+
+// SaveSuppressions records drift events hidden by the suppression policy
+func (s *DriftStore) SaveSuppressions(ctx context.Context, suppressions []drift.Suppression) error {
+	if len(suppressions) == 0 {
+		return nil
+	}
+
+	batch, err := s.client.PrepareBatch(ctx, `
+		INSERT INTO suppressed_drift_events (
+			event_id, resource_id, resource_type, terraform_address,
+			reason, rule, owner, expires, paths, timestamp
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, suppression := range suppressions {
+		paths := suppression.Paths
+		if paths == nil {
+			paths = []string{}
+		}
+		if err := batch.Append(
+			suppression.EventID,
+			suppression.ResourceID,
+			suppression.ResourceType,
+			suppression.TerraformAddress,
+			string(suppression.Reason),
+			suppression.Rule,
+			suppression.Owner,
+			suppression.Expires,
+			paths,
+			suppression.Timestamp,
+		); err != nil {
+			return fmt.Errorf("failed to append to batch: %w", err)
 		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send batch: %w", err)
+	}
 
-		event.Type = types.DriftType(driftType)
-		event.Severity = types.Severity(severity)
-
-		json.Unmarshal([]byte(stateBefore), &event.Before)
-		json.Unmarshal([]byte(stateAfter), &event.After)
-		json.Unmarshal([]byte(diff), &event.Diff)
-
-		if cloudtrailEventID != "" {
-			event.RootCause = &types.RootCause{
-				CloudTrailEventID: cloudtrailEventID,
-				EventName:         eventName,
-				UserIdentity:      userIdentity,
-				UserARN:           userARN,
-				SourceIP:          sourceIP,
-				Timestamp:         rootCauseTimestamp,
+	return nil
+}
+
+// RecordDriftChanges persists the result of a detection cycle.
+// New drifts are inserted into drift_events once (a drift that was resolved and
+// reappears keeps its original row); every change gets a new status version.
+func (s *DriftStore) RecordDriftChanges(ctx context.Context, changes drift.Changes) error {
+	if len(changes.Created) > 0 {
+		ids := make([]string, len(changes.Created))
+		for i, event := range changes.Created {
+			ids[i] = event.ID
+		}
+
+		existing, err := s.existingDriftEventIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+
+		created := make([]*types.DriftEvent, 0, len(changes.Created))
+		for _, event := range changes.Created {
+			if !existing[event.ID] {
+				created = append(created, event)
 			}
 		}
+		if err := s.SaveDriftEvents(ctx, created); err != nil {
+			return err
+		}
+	}
+
+	return s.SaveDriftStatuses(ctx, changes.All())
+}
+
+// existingDriftEventIDs returns the subset of ids that are already stored
+func (s *DriftStore) existingDriftEventIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	rows, err := s.client.Query(ctx, "SELECT DISTINCT id FROM drift_events WHERE id IN ?", ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query drift event IDs: %w", err)
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool, len(ids))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		existing[id] = true
+	}
+	return existing, nil
+}
 
-		events = append(events, &event)
+// UpdateDriftStatus changes the lifecycle status of a drift event (e.g. acknowledge, resolve)
+func (s *DriftStore) UpdateDriftStatus(ctx context.Context, id string, status types.DriftStatus, user string) (*types.DriftEvent, error) {
+	event, err := s.GetDriftEvent(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
-	return events, nil
+	if err := drift.SetStatus(event, status, user, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.SaveDriftStatuses(ctx, []*types.DriftEvent{event}); err != nil {
+		return nil, err
+	}
+
+	return event, nil
 }
 
 // GetDriftStats returns drift statistics
-func (s *DriftStore) GetDriftStats(ctx context.Context, days int) (*DriftStats, error) {
-	stats := &DriftStats{}
+func (s *DriftStore) GetDriftStats(ctx context.Context, days int) (*storage.DriftStats, error) {
+	stats := &storage.DriftStats{}
 
 	// Total count
 	query := `
@@ -392,26 +587,63 @@ func (s *DriftStore) GetDriftStats(ctx context.Context, days int) (*DriftStats,
 		stats.ByResourceType[resourceType] = count
 	}
 
-	return stats, nil
-}
+	// Count by lifecycle status (events without a status row are open)
+	query = `
+		SELECT if(s.id = '', 'open', toString(s.status)) AS status, count()
+		FROM drift_events AS e
+		LEFT JOIN (SELECT id, status FROM drift_event_status FINAL) AS s ON s.id = e.id
+		WHERE e.date >= today() - INTERVAL ? DAY
+		GROUP BY status
+	`
+	rows, err = s.client.Query(ctx, query, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-// DriftEventFilter defines filters for querying drift events
-type DriftEventFilter struct {
-	StartTime    time.Time
-	EndTime      time.Time
-	ResourceType string
-	DriftType    types.DriftType
-	Severity     types.Severity
-	UserIdentity string
-	Limit        int
-}
+	stats.ByStatus = make(map[string]uint64)
+	for rows.Next() {
+		var status string
+		var count uint64
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		stats.ByStatus[status] = count
+	}
 
-// DriftStats contains drift statistics
-type DriftStats struct {
-	TotalCount       uint64            `json:"total_count"`
-	BySeverity       map[string]uint64 `json:"by_severity"`
-	ByType           map[string]uint64 `json:"by_type"`
-	ByResourceType   map[string]uint64 `json:"by_resource_type"`
+	// Suppressed drifts (a drift suppressed in every cycle counts once)
+	query = `
+		SELECT toString(reason) AS reason, uniqExact(event_id)
+		FROM suppressed_drift_events
+		WHERE date >= today() - INTERVAL ? DAY
+		GROUP BY reason
+	`
+	rows, err = s.client.Query(ctx, query, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats.SuppressedByReason = make(map[string]uint64)
+	for rows.Next() {
+		var reason string
+		var count uint64
+		if err := rows.Scan(&reason, &count); err != nil {
+			return nil, err
+		}
+		stats.SuppressedByReason[reason] = count
+	}
+
+	query = `
+		SELECT uniqExact(event_id)
+		FROM suppressed_drift_events
+		WHERE date >= today() - INTERVAL ? DAY
+	`
+	if err := s.client.QueryRow(ctx, query, days).Scan(&stats.SuppressedCount); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
 }
 
 // DeleteOldDriftEvents deletes drift events older than specified days
@@ -439,3 +671,19 @@ func (s *DriftStore) DeleteOldDriftEvents(ctx context.Context, days int) (uint64
 
 	return count, nil
 }
+
+// severityRulesOrEmpty stores missing severity rules as an empty JSON array
+func severityRulesOrEmpty(rules []types.SeverityRule) []types.SeverityRule {
+	if rules == nil {
+		return []types.SeverityRule{}
+	}
+	return rules
+}
+
+// rootCausesOrEmpty stores missing root cause candidates as an empty JSON array
+func rootCausesOrEmpty(candidates []types.RootCause) []types.RootCause {
+	if candidates == nil {
+		return []types.RootCause{}
+	}
+	return candidates
+}
@@ -2,10 +2,13 @@ package clickhouse
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage"
 	"github.com/higakikeita/airdig/deepdrift/pkg/types"
 )
 
@@ -14,6 +17,8 @@ type ImpactStore struct {
 	client *Client
 }
 
+var _ storage.ImpactStore = (*ImpactStore)(nil)
+
 // NewImpactStore creates a new impact store
 func NewImpactStore(client *Client) *ImpactStore {
 	return &ImpactStore{
@@ -26,13 +31,14 @@ func (s *ImpactStore) SaveImpactAnalysis(ctx context.Context, result *types.Impa
 	// Save to impact_analysis table
 	affectedResourcesJSON, _ := json.Marshal(result.AffectedResources)
 	recommendationsJSON, _ := json.Marshal(result.Recommendations)
+	severityRulesJSON, _ := json.Marshal(severityRulesOrEmpty(result.SeverityRules))
 
 	query := `
 		INSERT INTO impact_analysis (
-			drift_event_id, affected_resource_count, blast_radius, severity,
-			affected_resources, recommendations, analyzed_at, date
+			drift_event_id, affected_resource_count, blast_radius, impact_score, severity,
+			affected_resources, recommendations, severity_rules, analyzed_at, date
 		) VALUES (
-			?, ?, ?, ?, ?, ?, ?, ?
+			?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 		)
 	`
 
@@ -41,9 +47,11 @@ func (s *ImpactStore) SaveImpactAnalysis(ctx context.Context, result *types.Impa
 		result.DriftEventID,
 		result.AffectedResourceCount,
 		result.BlastRadius,
+		result.ImpactScore,
 		string(result.Severity),
 		string(affectedResourcesJSON),
 		string(recommendationsJSON),
+		string(severityRulesJSON),
 		analyzedAt,
 		analyzedAt.Truncate(24*time.Hour),
 	); err != nil {
@@ -68,8 +76,8 @@ func (s *ImpactStore) SaveImpactAnalysisBatch(ctx context.Context, results []*ty
 
 	batch, err := s.client.PrepareBatch(ctx, `
 		INSERT INTO impact_analysis (
-			drift_event_id, affected_resource_count, blast_radius, severity,
-			affected_resources, recommendations, analyzed_at, date
+			drift_event_id, affected_resource_count, blast_radius, impact_score, severity,
+			affected_resources, recommendations, severity_rules, analyzed_at, date
 		)
 	`)
 	if err != nil {
@@ -80,14 +88,17 @@ func (s *ImpactStore) SaveImpactAnalysisBatch(ctx context.Context, results []*ty
 	for _, result := range results {
 		affectedResourcesJSON, _ := json.Marshal(result.AffectedResources)
 		recommendationsJSON, _ := json.Marshal(result.Recommendations)
+		severityRulesJSON, _ := json.Marshal(severityRulesOrEmpty(result.SeverityRules))
 
 		if err := batch.Append(
 			result.DriftEventID,
 			result.AffectedResourceCount,
 			result.BlastRadius,
+			result.ImpactScore,
 			string(result.Severity),
 			string(affectedResourcesJSON),
 			string(recommendationsJSON),
+			string(severityRulesJSON),
 			analyzedAt,
 			analyzedAt.Truncate(24*time.Hour),
 		); err != nil {
@@ -120,7 +131,7 @@ func (s *ImpactStore) saveAffectedResources(ctx context.Context, driftEventID st
 	batch, err := s.client.PrepareBatch(ctx, `
 		INSERT INTO affected_resources (
 			drift_event_id, resource_id, resource_type, relation_type,
-			distance, impact_description, date
+			distance, impact_description, impact_score, propagation_path, date
 		)
 	`)
 	if err != nil {
@@ -129,6 +140,8 @@ func (s *ImpactStore) saveAffectedResources(ctx context.Context, driftEventID st
 
 	date := time.Now().Truncate(24 * time.Hour)
 	for _, resource := range resources {
+		propagationPathJSON, _ := json.Marshal(propagationPathOrEmpty(resource.PropagationPath))
+
 		if err := batch.Append(
 			driftEventID,
 			resource.ResourceID,
@@ -136,6 +149,8 @@ func (s *ImpactStore) saveAffectedResources(ctx context.Context, driftEventID st
 			resource.RelationType,
 			resource.Distance,
 			resource.ImpactDescription,
+			resource.ImpactScore,
+			string(propagationPathJSON),
 			date,
 		); err != nil {
 			return err
@@ -149,8 +164,8 @@ func (s *ImpactStore) saveAffectedResources(ctx context.Context, driftEventID st
 func (s *ImpactStore) GetImpactAnalysis(ctx context.Context, driftEventID string) (*types.ImpactAnalysisResult, error) {
 	query := `
 		SELECT
-			drift_event_id, affected_resource_count, blast_radius, severity,
-			affected_resources, recommendations, analyzed_at
+			drift_event_id, affected_resource_count, blast_radius, impact_score, severity,
+			affected_resources, recommendations, severity_rules, analyzed_at
 		FROM impact_analysis
 		WHERE drift_event_id = ?
 		ORDER BY analyzed_at DESC
@@ -159,37 +174,45 @@ func (s *ImpactStore) GetImpactAnalysis(ctx context.Context, driftEventID string
 
 	var result types.ImpactAnalysisResult
 	var severity string
-	var affectedResourcesJSON, recommendationsJSON string
+	var affectedResourcesJSON, recommendationsJSON, severityRulesJSON string
 	var analyzedAt time.Time
 
 	row := s.client.QueryRow(ctx, query, driftEventID)
-	if err := row.Scan(
+	err := row.Scan(
 		&result.DriftEventID,
 		&result.AffectedResourceCount,
 		&result.BlastRadius,
+		&result.ImpactScore,
 		&severity,
 		&affectedResourcesJSON,
 		&recommendationsJSON,
+		&severityRulesJSON,
 		&analyzedAt,
-	); err != nil {
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", storage.ErrImpactNotFound, driftEventID)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to get impact analysis: %w", err)
 	}
 
 	result.Severity = types.Severity(severity)
+	result.AnalyzedAt = analyzedAt
 
 	// Parse JSON
 	json.Unmarshal([]byte(affectedResourcesJSON), &result.AffectedResources)
 	json.Unmarshal([]byte(recommendationsJSON), &result.Recommendations)
+	json.Unmarshal([]byte(severityRulesJSON), &result.SeverityRules)
 
 	return &result, nil
 }
 
 // ListImpactAnalysis lists impact analysis results with filters
-func (s *ImpactStore) ListImpactAnalysis(ctx context.Context, filter *ImpactAnalysisFilter) ([]*types.ImpactAnalysisResult, error) {
+func (s *ImpactStore) ListImpactAnalysis(ctx context.Context, filter *storage.ImpactAnalysisFilter) ([]*types.ImpactAnalysisResult, error) {
 	query := `
 		SELECT
-			drift_event_id, affected_resource_count, blast_radius, severity,
-			affected_resources, recommendations, analyzed_at
+			drift_event_id, affected_resource_count, blast_radius, impact_score, severity,
+			affected_resources, recommendations, severity_rules, analyzed_at
 		FROM impact_analysis
 		WHERE 1=1
 	`
@@ -217,9 +240,21 @@ func (s *ImpactStore) ListImpactAnalysis(ctx context.Context, filter *ImpactAnal
 			query += " AND affected_resource_count >= ?"
 			args = append(args, filter.MinAffectedResources)
 		}
+
+		conds, condArgs := queryConditions(filter.Query, impactQueryColumns, "drift_event_id")
+		query += conds
+		args = append(args, condArgs...)
+
+		after, afterArgs := impactSortColumns.after(filter.After)
+		query += after
+		args = append(args, afterArgs...)
 	}
 
-	query += " ORDER BY analyzed_at DESC"
+	var order storage.SortOrder
+	if filter != nil {
+		order = filter.Sort
+	}
+	query += " ORDER BY " + impactSortColumns.orderBy(order)
 
 	if filter != nil && filter.Limit > 0 {
 		query += " LIMIT ?"
@@ -236,25 +271,29 @@ func (s *ImpactStore) ListImpactAnalysis(ctx context.Context, filter *ImpactAnal
 	for rows.Next() {
 		var result types.ImpactAnalysisResult
 		var severity string
-		var affectedResourcesJSON, recommendationsJSON string
+		var affectedResourcesJSON, recommendationsJSON, severityRulesJSON string
 		var analyzedAt time.Time
 
 		if err := rows.Scan(
 			&result.DriftEventID,
 			&result.AffectedResourceCount,
 			&result.BlastRadius,
+			&result.ImpactScore,
 			&severity,
 			&affectedResourcesJSON,
 			&recommendationsJSON,
+			&severityRulesJSON,
 			&analyzedAt,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
 		result.Severity = types.Severity(severity)
+		result.AnalyzedAt = analyzedAt
 
 		json.Unmarshal([]byte(affectedResourcesJSON), &result.AffectedResources)
 		json.Unmarshal([]byte(recommendationsJSON), &result.Recommendations)
+		json.Unmarshal([]byte(severityRulesJSON), &result.SeverityRules)
 
 		results = append(results, &result)
 	}
@@ -266,10 +305,11 @@ func (s *ImpactStore) ListImpactAnalysis(ctx context.Context, filter *ImpactAnal
 func (s *ImpactStore) GetAffectedResources(ctx context.Context, driftEventID string) ([]types.AffectedResource, error) {
 	query := `
 		SELECT
-			resource_id, resource_type, relation_type, distance, impact_description
+			resource_id, resource_type, relation_type, distance, impact_description,
+			impact_score, propagation_path
 		FROM affected_resources
 		WHERE drift_event_id = ?
-		ORDER BY distance, resource_type
+		ORDER BY impact_score DESC, distance, resource_type
 	`
 
 	rows, err := s.client.Query(ctx, query, driftEventID)
@@ -281,15 +321,19 @@ func (s *ImpactStore) GetAffectedResources(ctx context.Context, driftEventID str
 	resources := []types.AffectedResource{}
 	for rows.Next() {
 		var resource types.AffectedResource
+		var propagationPathJSON string
 		if err := rows.Scan(
 			&resource.ResourceID,
 			&resource.ResourceType,
 			&resource.RelationType,
 			&resource.Distance,
 			&resource.ImpactDescription,
+			&resource.ImpactScore,
+			&propagationPathJSON,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
+		json.Unmarshal([]byte(propagationPathJSON), &resource.PropagationPath)
 		resources = append(resources, resource)
 	}
 
@@ -297,8 +341,8 @@ func (s *ImpactStore) GetAffectedResources(ctx context.Context, driftEventID str
 }
 
 // GetImpactStats returns impact analysis statistics
-func (s *ImpactStore) GetImpactStats(ctx context.Context, days int) (*ImpactStats, error) {
-	stats := &ImpactStats{}
+func (s *ImpactStore) GetImpactStats(ctx context.Context, days int) (*storage.ImpactStats, error) {
+	stats := &storage.ImpactStats{}
 
 	// Total analyzed
 	query := `
@@ -312,7 +356,7 @@ func (s *ImpactStore) GetImpactStats(ctx context.Context, days int) (*ImpactStat
 
 	// Average blast radius
 	query = `
-		SELECT avg(blast_radius)
+		SELECT avgOrDefault(blast_radius)
 		FROM impact_analysis
 		WHERE date >= today() - INTERVAL ? DAY
 	`
@@ -322,7 +366,7 @@ func (s *ImpactStore) GetImpactStats(ctx context.Context, days int) (*ImpactStat
 
 	// Average affected resources
 	query = `
-		SELECT avg(affected_resource_count)
+		SELECT avgOrDefault(affected_resource_count)
 		FROM impact_analysis
 		WHERE date >= today() - INTERVAL ? DAY
 	`
@@ -359,7 +403,7 @@ func (s *ImpactStore) GetImpactStats(ctx context.Context, days int) (*ImpactStat
 }
 
 // GetHighImpactDrifts returns drifts with high impact
-func (s *ImpactStore) GetHighImpactDrifts(ctx context.Context, days int, limit int) ([]*HighImpactDrift, error) {
+func (s *ImpactStore) GetHighImpactDrifts(ctx context.Context, days int, limit int) ([]*storage.HighImpactDrift, error) {
 	query := `
 		SELECT
 			de.id,
@@ -369,11 +413,12 @@ func (s *ImpactStore) GetHighImpactDrifts(ctx context.Context, days int, limit i
 			de.timestamp,
 			ia.affected_resource_count,
 			ia.blast_radius,
+			ia.impact_score,
 			ia.severity
 		FROM drift_events de
 		JOIN impact_analysis ia ON de.id = ia.drift_event_id
 		WHERE de.date >= today() - INTERVAL ? DAY
-		ORDER BY ia.blast_radius DESC, ia.affected_resource_count DESC
+		ORDER BY ia.impact_score DESC, ia.blast_radius DESC, ia.affected_resource_count DESC
 		LIMIT ?
 	`
 
@@ -383,9 +428,9 @@ func (s *ImpactStore) GetHighImpactDrifts(ctx context.Context, days int, limit i
 	}
 	defer rows.Close()
 
-	drifts := []*HighImpactDrift{}
+	drifts := []*storage.HighImpactDrift{}
 	for rows.Next() {
-		var drift HighImpactDrift
+		var drift storage.HighImpactDrift
 		var driftType, severity string
 		if err := rows.Scan(
 			&drift.ID,
@@ -395,6 +440,7 @@ func (s *ImpactStore) GetHighImpactDrifts(ctx context.Context, days int, limit i
 			&drift.Timestamp,
 			&drift.AffectedResourceCount,
 			&drift.BlastRadius,
+			&drift.ImpactScore,
 			&severity,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
@@ -407,32 +453,10 @@ func (s *ImpactStore) GetHighImpactDrifts(ctx context.Context, days int, limit i
 	return drifts, nil
 }
 
-// ImpactAnalysisFilter defines filters for querying impact analysis
-type ImpactAnalysisFilter struct {
-	StartTime            time.Time
-	EndTime              time.Time
-	Severity             types.Severity
-	MinBlastRadius       int
-	MinAffectedResources int
-	Limit                int
-}
-
-// ImpactStats contains impact analysis statistics
-type ImpactStats struct {
-	TotalAnalyzed            uint64            `json:"total_analyzed"`
-	AvgBlastRadius           float64           `json:"avg_blast_radius"`
-	AvgAffectedResources     float64           `json:"avg_affected_resources"`
-	TopAffectedResourceTypes map[string]uint64 `json:"top_affected_resource_types"`
-}
-
-// HighImpactDrift represents a drift with high impact
-type HighImpactDrift struct {
-	ID                   string           `json:"id"`
-	ResourceID           string           `json:"resource_id"`
-	ResourceType         string           `json:"resource_type"`
-	DriftType            types.DriftType  `json:"drift_type"`
-	Timestamp            time.Time        `json:"timestamp"`
-	AffectedResourceCount int             `json:"affected_resource_count"`
-	BlastRadius          int              `json:"blast_radius"`
-	Severity             types.Severity   `json:"severity"`
+// propagationPathOrEmpty stores a missing propagation path as an empty JSON array
+func propagationPathOrEmpty(path []types.PropagationStep) []types.PropagationStep {
+	if path == nil {
+		return []types.PropagationStep{}
+	}
+	return path
 }
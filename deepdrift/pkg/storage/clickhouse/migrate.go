@@ -0,0 +1,22 @@
+package clickhouse
+
+import (
+	"embed"
+
+	"github.com/higakikeita/airdig/chmigrate"
+)
+
+// migrationFiles are the schema migrations, applied in version order:
+//
+//	migrations/0002_drift_event_status.up.sql    applies version 2
+//	migrations/0002_drift_event_status.down.sql  rolls it back
+//
+// How they are applied is described in the chmigrate package.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// NewMigrator returns a migrator for the migrations embedded in this package
+func NewMigrator(client *Client) (*chmigrate.Migrator, error) {
+	return chmigrate.NewMigrator(client, migrationFiles, "migrations")
+}
@@ -0,0 +1,28 @@
+package clickhouse
+
+import (
+	"testing"
+
+	"github.com/higakikeita/airdig/chmigrate"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := chmigrate.LoadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations")
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("migration %s: version %d, want %d", migration, migration.Version, i+1)
+		}
+		up := chmigrate.Step{Migration: migration}
+		down := chmigrate.Step{Migration: migration, Rollback: true}
+		if len(up.Statements()) == 0 || len(down.Statements()) == 0 {
+			t.Errorf("migration %s: up or down step is empty", migration)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+DROP VIEW IF EXISTS resource_impact_summary;
+DROP VIEW IF EXISTS user_activity_summary;
+DROP VIEW IF EXISTS drift_summary_hourly;
+DROP VIEW IF EXISTS drift_summary_daily;
+
+DROP TABLE IF EXISTS affected_resources;
+DROP TABLE IF EXISTS impact_analysis;
+DROP TABLE IF EXISTS drift_events;
@@ -1,9 +1,6 @@
 -- DeepDrift ClickHouse Schema
--- Database for storing drift events and impact analysis results
-
-CREATE DATABASE IF NOT EXISTS deepdrift;
-
-USE deepdrift;
+-- Drift events and impact analysis results. Migrations run in the database
+-- the client is connected to (--clickhouse-db).
 
 -- Drift Events Table
 -- Stores all drift events detected by TFDrift-Falco
@@ -152,62 +149,3 @@ AS SELECT
 FROM drift_events de
 LEFT JOIN impact_analysis ia ON de.id = ia.drift_event_id
 GROUP BY date, de.resource_type;
-
--- Indexes for faster queries
-
--- Full-text search index on resource_id
--- ALTER TABLE drift_events ADD INDEX resource_id_idx resource_id TYPE tokenbf_v1(32768, 3, 0) GRANULARITY 4;
-
--- Index on user_identity for quick user lookup
--- ALTER TABLE drift_events ADD INDEX user_identity_idx user_identity TYPE bloom_filter() GRANULARITY 4;
-
--- Index on severity for filtering
--- ALTER TABLE drift_events ADD INDEX severity_idx severity TYPE set(10) GRANULARITY 4;
-
--- Common Queries Examples
-
--- Recent critical drifts
--- SELECT
---     timestamp,
---     resource_id,
---     drift_type,
---     user_identity
--- FROM drift_events
--- WHERE severity = 'critical'
---   AND timestamp > now() - INTERVAL 24 HOUR
--- ORDER BY timestamp DESC
--- LIMIT 100;
-
--- Drift trends over last 7 days
--- SELECT
---     date,
---     drift_type,
---     count() as count
--- FROM drift_summary_daily
--- WHERE date >= today() - INTERVAL 7 DAY
--- GROUP BY date, drift_type
--- ORDER BY date, drift_type;
-
--- Top users causing drifts
--- SELECT
---     user_identity,
---     sum(drift_count) as total_drifts,
---     sum(unique_resources) as unique_resources
--- FROM user_activity_summary
--- WHERE date >= today() - INTERVAL 30 DAY
--- GROUP BY user_identity
--- ORDER BY total_drifts DESC
--- LIMIT 20;
-
--- Resources with highest blast radius
--- SELECT
---     de.resource_id,
---     de.resource_type,
---     ia.affected_resource_count,
---     ia.blast_radius,
---     de.timestamp
--- FROM drift_events de
--- JOIN impact_analysis ia ON de.id = ia.drift_event_id
--- WHERE de.timestamp > now() - INTERVAL 7 DAY
--- ORDER BY ia.blast_radius DESC, ia.affected_resource_count DESC
--- LIMIT 50;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS drift_event_status;
@@ -0,0 +1,21 @@
+-- Drift Event Status Table
+-- Lifecycle of each drift event (open -> acknowledged -> resolved).
+-- Drift event IDs are derived from the drift content, so a drift that persists
+-- across detection cycles keeps its ID: drift_events holds one row per drift and
+-- every status change / last_seen update inserts a new version here.
+CREATE TABLE IF NOT EXISTS drift_event_status (
+    id String,
+    workspace String,
+    status Enum8('open' = 1, 'acknowledged' = 2, 'resolved' = 3),
+
+    first_seen DateTime64(3),
+    last_seen DateTime64(3),
+    resolved_at Nullable(DateTime64(3)),
+    acknowledged_by String,
+    acknowledged_at Nullable(DateTime64(3)),
+
+    updated_at DateTime64(3) DEFAULT now64()
+) ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY id
+TTL toDate(last_seen) + INTERVAL 90 DAY
+SETTINGS index_granularity = 8192;
@@ -0,0 +1,22 @@
+-- Suppressed Drift Events Table
+-- Drift events hidden by the suppression policy (ignore rules, tag exemptions,
+-- silences). Kept for auditing what is being suppressed; one row per detection.
+CREATE TABLE IF NOT EXISTS suppressed_drift_events (
+    event_id String,
+    resource_id String,
+    resource_type String,
+    terraform_address String,
+
+    reason Enum8('resource' = 1, 'tag' = 2, 'attributes' = 3, 'silence' = 4),
+    rule String,
+    owner String,
+    expires Nullable(DateTime64(3)),
+    paths Array(String),
+
+    timestamp DateTime64(3),
+    date Date DEFAULT toDate(timestamp)
+) ENGINE = MergeTree()
+PARTITION BY toYYYYMM(date)
+ORDER BY (date, reason, resource_type, timestamp)
+TTL date + INTERVAL 90 DAY
+SETTINGS index_granularity = 8192;
@@ -0,0 +1,2 @@
+ALTER TABLE impact_analysis DROP COLUMN IF EXISTS severity_rules;
+ALTER TABLE drift_events DROP COLUMN IF EXISTS severity_rules;
@@ -0,0 +1,3 @@
+-- Severity rules that fired (JSON array)
+ALTER TABLE drift_events ADD COLUMN IF NOT EXISTS severity_rules String DEFAULT '[]' AFTER diff;
+ALTER TABLE impact_analysis ADD COLUMN IF NOT EXISTS severity_rules String DEFAULT '[]' AFTER recommendations;
@@ -0,0 +1 @@
+ALTER TABLE drift_events DROP COLUMN IF EXISTS root_cause_candidates;
@@ -0,0 +1,2 @@
+-- Ranked CloudTrail root cause candidates (JSON array)
+ALTER TABLE drift_events ADD COLUMN IF NOT EXISTS root_cause_candidates String DEFAULT '[]' AFTER root_cause_timestamp;
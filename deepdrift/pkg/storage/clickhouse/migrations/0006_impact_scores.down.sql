@@ -0,0 +1,3 @@
+ALTER TABLE affected_resources DROP COLUMN IF EXISTS propagation_path;
+ALTER TABLE affected_resources DROP COLUMN IF EXISTS impact_score;
+ALTER TABLE impact_analysis DROP COLUMN IF EXISTS impact_score;
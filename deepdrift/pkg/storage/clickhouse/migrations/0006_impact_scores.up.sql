@@ -0,0 +1,6 @@
+-- Weighted impact scores and how the impact propagated from the drifted resource
+ALTER TABLE impact_analysis ADD COLUMN IF NOT EXISTS impact_score Float64 DEFAULT 0 AFTER blast_radius;
+ALTER TABLE affected_resources ADD COLUMN IF NOT EXISTS impact_score Float64 DEFAULT 0 AFTER impact_description;
+
+-- JSON array of the propagation steps
+ALTER TABLE affected_resources ADD COLUMN IF NOT EXISTS propagation_path String DEFAULT '[]' AFTER impact_score;
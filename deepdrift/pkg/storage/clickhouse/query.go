@@ -0,0 +1,191 @@
+package clickhouse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/severity"
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// driftQueryColumns maps the query fields of drift events to the columns of
+// driftEventQuery
+var driftQueryColumns = map[string]string{
+	"id":            "e.id",
+	"resource_id":   "e.resource_id",
+	"resource_type": "e.resource_type",
+	"type":          "toString(e.drift_type)",
+	"severity":      "e.severity",
+	"status":        "status",
+	"workspace":     "workspace",
+	"user":          "e.user_identity",
+	"changed":       "JSONExtractKeys(e.diff)",
+	"tag":           "e.tags",
+	"timestamp":     "e.timestamp",
+}
+
+// impactQueryColumns maps the query fields of impact analysis to the columns
+// of impact_analysis. The other fields match the analysed drift event.
+var impactQueryColumns = map[string]string{
+	"drift_event_id":     "drift_event_id",
+	"severity":           "severity",
+	"blast_radius":       "blast_radius",
+	"affected_resources": "affected_resource_count",
+	"impact_score":       "impact_score",
+	"analyzed_at":        "analyzed_at",
+}
+
+// queryConditions translates a query into " AND ..." conditions with
+// parameters. Fields missing from columns are matched against the drift event
+// the row refers to through its driftIDColumn.
+func queryConditions(query *storage.Query, columns map[string]string, driftIDColumn string) (string, []interface{}) {
+	if query == nil {
+		return "", nil
+	}
+
+	var sql strings.Builder
+	var args []interface{}
+	for _, term := range query.Terms {
+		var cond string
+		var termArgs []interface{}
+		if column, ok := columns[term.Field]; ok {
+			cond, termArgs = termCondition(term, column)
+		} else {
+			cond, termArgs = termCondition(term, driftQueryColumns[term.Field])
+			cond = fmt.Sprintf("%s IN (SELECT e.id FROM drift_events AS e WHERE %s)", driftIDColumn, cond)
+		}
+
+		if term.Negate {
+			cond = "NOT (" + cond + ")"
+		}
+		sql.WriteString(" AND " + cond)
+		args = append(args, termArgs...)
+	}
+	return sql.String(), args
+}
+
+// termCondition translates one term (ignoring Negate) for a column
+func termCondition(term storage.Term, column string) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	switch term.Kind {
+	case storage.KindString, storage.KindEnum:
+		for _, value := range term.Values {
+			if storage.HasWildcard(value) {
+				conds = append(conds, column+" LIKE ?")
+				args = append(args, likePattern(value))
+			} else {
+				conds = append(conds, column+" = ?")
+				args = append(args, value)
+			}
+		}
+
+	case storage.KindSeverity:
+		if term.Op == storage.OpMatch {
+			return column + " IN ?", []interface{}{term.Values}
+		}
+		// The Enum8 values of severity follow the severity order
+		return fmt.Sprintf("CAST(%s, 'Int8') %s ?", column, term.Op), []interface{}{severityValue(types.Severity(term.Values[0]))}
+
+	case storage.KindNumber:
+		if term.Op == storage.OpMatch {
+			return column + " IN ?", []interface{}{term.Numbers}
+		}
+		return fmt.Sprintf("%s %s ?", column, term.Op), []interface{}{term.Numbers[0]}
+
+	case storage.KindTime:
+		return fmt.Sprintf("%s %s ?", column, term.Op), []interface{}{term.Times[0]}
+
+	case storage.KindPath:
+		for _, value := range term.Values {
+			if storage.HasWildcard(value) {
+				conds = append(conds, fmt.Sprintf("arrayExists(k -> k LIKE ?, %s)", column))
+				args = append(args, likePattern(value))
+			} else {
+				conds = append(conds, fmt.Sprintf("arrayExists(k -> k = ? OR startsWith(k, ?) OR startsWith(k, ?), %s)", column))
+				args = append(args, value, value+".", value+"[")
+			}
+		}
+
+	case storage.KindTag:
+		for _, value := range term.Values {
+			key, pattern, hasValue := strings.Cut(value, "=")
+			switch {
+			case !hasValue:
+				conds = append(conds, fmt.Sprintf("mapContains(%s, ?)", column))
+				args = append(args, key)
+			case storage.HasWildcard(pattern):
+				conds = append(conds, fmt.Sprintf("(mapContains(%[1]s, ?) AND %[1]s[?] LIKE ?)", column))
+				args = append(args, key, key, likePattern(pattern))
+			default:
+				conds = append(conds, fmt.Sprintf("(mapContains(%[1]s, ?) AND %[1]s[?] = ?)", column))
+				args = append(args, key, key, pattern)
+			}
+		}
+	}
+
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+// likePattern translates the * and ? wildcards into a LIKE pattern
+func likePattern(pattern string) string {
+	var b strings.Builder
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteByte('%')
+		case '?':
+			b.WriteByte('_')
+		case '%', '_', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// severityValue returns the Enum8 value of a severity in the schema
+func severityValue(s types.Severity) int8 {
+	return int8(severity.Rank(s) + 1)
+}
+
+// sortColumns are the columns of the sort key of a table
+type sortColumns struct {
+	severity, time, id string
+}
+
+var (
+	driftSortColumns  = sortColumns{severity: "e.severity", time: "e.timestamp", id: "e.id"}
+	impactSortColumns = sortColumns{severity: "severity", time: "analyzed_at", id: "drift_event_id"}
+)
+
+// orderBy returns the ORDER BY expressions of a sort order
+func (c sortColumns) orderBy(order storage.SortOrder) string {
+	switch order {
+	case storage.SortOldest:
+		return fmt.Sprintf("%s ASC, %s ASC", c.time, c.id)
+	case storage.SortSeverity:
+		return fmt.Sprintf("%s DESC, %s DESC, %s DESC", c.severity, c.time, c.id)
+	}
+	return fmt.Sprintf("%s DESC, %s DESC", c.time, c.id)
+}
+
+// after returns the " AND ..." condition that skips the rows up to a cursor
+func (c sortColumns) after(cursor *storage.Cursor) (string, []interface{}) {
+	if cursor == nil {
+		return "", nil
+	}
+
+	switch cursor.Sort {
+	case storage.SortOldest:
+		return fmt.Sprintf(" AND (%s, %s) > (?, ?)", c.time, c.id), []interface{}{cursor.Time, cursor.ID}
+	case storage.SortSeverity:
+		return fmt.Sprintf(" AND (CAST(%s, 'Int8'), %s, %s) < (?, ?, ?)", c.severity, c.time, c.id),
+			[]interface{}{severityValue(cursor.Severity), cursor.Time, cursor.ID}
+	}
+	return fmt.Sprintf(" AND (%s, %s) < (?, ?)", c.time, c.id), []interface{}{cursor.Time, cursor.ID}
+}
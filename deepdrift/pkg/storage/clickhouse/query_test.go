@@ -0,0 +1,103 @@
+package clickhouse
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+func TestQueryConditions(t *testing.T) {
+	tests := []struct {
+		query    string
+		fields   map[string]storage.QueryField
+		wantSQL  string
+		wantArgs string
+	}{
+		{
+			"resource_id:i-*,sg-1 -type:deleted",
+			storage.DriftQueryFields,
+			" AND (e.resource_id LIKE ? OR e.resource_id = ?) AND NOT ((toString(e.drift_type) = ?))",
+			"[i-% sg-1 deleted]",
+		},
+		{
+			"resource_id:a_b%?",
+			storage.DriftQueryFields,
+			" AND (e.resource_id LIKE ?)",
+			`[a\_b\%_]`,
+		},
+		{
+			"severity>=high severity:low,medium",
+			storage.DriftQueryFields,
+			" AND CAST(e.severity, 'Int8') >= ? AND e.severity IN ?",
+			"[3 [low medium]]",
+		},
+		{
+			"changed:ingress",
+			storage.DriftQueryFields,
+			" AND (arrayExists(k -> k = ? OR startsWith(k, ?) OR startsWith(k, ?), JSONExtractKeys(e.diff)))",
+			"[ingress ingress. ingress[]",
+		},
+		{
+			"tag:env=prod,team",
+			storage.DriftQueryFields,
+			" AND ((mapContains(e.tags, ?) AND e.tags[?] = ?) OR mapContains(e.tags, ?))",
+			"[env env prod team]",
+		},
+		{
+			"blast_radius>2 resource_type:aws_instance",
+			storage.ImpactQueryFields,
+			" AND blast_radius > ? AND drift_event_id IN (SELECT e.id FROM drift_events AS e WHERE (e.resource_type = ?))",
+			"[2 aws_instance]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := storage.ParseQuery(tt.query, tt.fields)
+			if err != nil {
+				t.Fatalf("ParseQuery: %v", err)
+			}
+			columns, driftID := driftQueryColumns, "e.id"
+			if _, ok := tt.fields["blast_radius"]; ok {
+				columns, driftID = impactQueryColumns, "drift_event_id"
+			}
+
+			sql, args := queryConditions(query, columns, driftID)
+			if sql != tt.wantSQL {
+				t.Errorf("sql = %q\nwant  %q", sql, tt.wantSQL)
+			}
+			if got := fmt.Sprint(args); got != tt.wantArgs {
+				t.Errorf("args = %s, want %s", got, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestSortColumns(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if got := driftSortColumns.orderBy(storage.SortSeverity); got != "e.severity DESC, e.timestamp DESC, e.id DESC" {
+		t.Errorf("orderBy(severity) = %q", got)
+	}
+	if got := impactSortColumns.orderBy(storage.SortOldest); got != "analyzed_at ASC, drift_event_id ASC" {
+		t.Errorf("orderBy(oldest) = %q", got)
+	}
+
+	sql, args := driftSortColumns.after(&storage.Cursor{Sort: storage.SortSeverity, SortKey: storage.SortKey{
+		Severity: types.SeverityCritical, Time: ts, ID: "drift-1",
+	}})
+	if sql != " AND (CAST(e.severity, 'Int8'), e.timestamp, e.id) < (?, ?, ?)" || len(args) != 3 || args[0] != int8(4) {
+		t.Errorf("after(severity) = %q, %v", sql, args)
+	}
+
+	sql, _ = driftSortColumns.after(&storage.Cursor{Sort: storage.SortOldest, SortKey: storage.SortKey{Time: ts, ID: "drift-1"}})
+	if sql != " AND (e.timestamp, e.id) > (?, ?)" {
+		t.Errorf("after(oldest) = %q", sql)
+	}
+	if sql, args := driftSortColumns.after(nil); sql != "" || args != nil {
+		t.Errorf("after(nil) = %q, %v", sql, args)
+	}
+}
@@ -0,0 +1,80 @@
+package clickhouse
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage"
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage/storagetest"
+)
+
+// testTables are truncated before every conformance test
+var testTables = []string{
+	"drift_events",
+	"drift_event_status",
+	"suppressed_drift_events",
+	"impact_analysis",
+	"affected_resources",
+}
+
+// TestConformance runs the storage conformance suite against the ClickHouse
+// server in DEEPDRIFT_TEST_CLICKHOUSE (host:port/database). The database is
+// created and migrated if needed, and its tables are truncated, so don't
+// point it at real data.
+func TestConformance(t *testing.T) {
+	addr := os.Getenv("DEEPDRIFT_TEST_CLICKHOUSE")
+	if addr == "" {
+		t.Skip("DEEPDRIFT_TEST_CLICKHOUSE is not set")
+	}
+
+	hostPort, database, ok := strings.Cut(addr, "/")
+	if !ok || database == "" {
+		t.Fatalf("DEEPDRIFT_TEST_CLICKHOUSE = %q, want host:port/database", addr)
+	}
+	host, portStr, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		t.Fatalf("DEEPDRIFT_TEST_CLICKHOUSE: %v", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatalf("DEEPDRIFT_TEST_CLICKHOUSE: invalid port %q", portStr)
+	}
+
+	config := DefaultConfig()
+	config.Host, config.Port, config.Database = host, port, database
+
+	if err := CreateDatabase(config); err != nil {
+		t.Fatalf("CreateDatabase: %v", err)
+	}
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	migrator, err := NewMigrator(client)
+	if err == nil {
+		_, err = migrator.Up(context.Background(), 0)
+	}
+	client.Close()
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.Backend {
+		client, err := NewClient(config)
+		if err != nil {
+			t.Fatalf("NewClient: %v", err)
+		}
+		t.Cleanup(func() { client.Close() })
+
+		for _, table := range testTables {
+			if err := client.Exec(context.Background(), "TRUNCATE TABLE "+table); err != nil {
+				t.Fatalf("failed to truncate %s: %v", table, err)
+			}
+		}
+		return client
+	})
+}
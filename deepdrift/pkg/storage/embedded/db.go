@@ -0,0 +1,528 @@
+// Package embedded implements the DeepDrift stores without an external
+// database server. The rows are stored in a BoltDB file, one bucket per
+// ClickHouse table, and cached in memory for the queries. Like the ClickHouse
+// TTLs, rows are kept for 90 days. It suits single-binary deployments and
+// tests; use ClickHouse for large histories.
+package embedded
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/drift"
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// ErrClosed is returned by writes after the database is closed
+var ErrClosed = errors.New("embedded: database is closed")
+
+// retentionDays is how long rows are kept, as the TTL of the ClickHouse tables
+const retentionDays = 90
+
+// The buckets of the database file, named after the ClickHouse tables
+var (
+	eventsBucket       = []byte("drift_events")
+	statusesBucket     = []byte("drift_event_status")
+	suppressionsBucket = []byte("suppressed_drift_events")
+	impactsBucket      = []byte("impact_analysis")
+)
+
+// DB is an embedded storage backend
+type DB struct {
+	mu     sync.RWMutex
+	path   string
+	file   *bolt.DB
+	closed bool
+
+	// The tables mirror the ClickHouse schema: drift_events, drift_event_status,
+	// suppressed_drift_events and impact_analysis (affected_resources is
+	// derived from the saved analyses)
+	events       map[string]*types.DriftEvent
+	statuses     map[string]statusRow
+	suppressions []drift.Suppression
+	impacts      []impactRow
+
+	// expireAt is when the rows past the retention are next deleted
+	expireAt time.Time
+
+	now func() time.Time
+}
+
+var _ storage.Backend = (*DB)(nil)
+
+// statusRow is the latest lifecycle status version of a drift event
+type statusRow struct {
+	ID             string            `json:"id"`
+	Workspace      string            `json:"workspace,omitempty"`
+	Status         types.DriftStatus `json:"status"`
+	FirstSeen      time.Time         `json:"first_seen"`
+	LastSeen       time.Time         `json:"last_seen"`
+	ResolvedAt     *time.Time        `json:"resolved_at,omitempty"`
+	AcknowledgedBy string            `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt *time.Time        `json:"acknowledged_at,omitempty"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// impactRow is a saved impact analysis
+type impactRow struct {
+	Result     *types.ImpactAnalysisResult `json:"result"`
+	AnalyzedAt time.Time                   `json:"analyzed_at"`
+}
+
+// analysis returns a copy of the saved result with the time it was saved
+func (row impactRow) analysis() (*types.ImpactAnalysisResult, error) {
+	result, err := clone(row.Result)
+	if err != nil {
+		return nil, err
+	}
+	result.AnalyzedAt = row.AnalyzedAt
+	return result, nil
+}
+
+// record is one change of the tables; exactly one field is set
+type record struct {
+	Event       *types.DriftEvent
+	Status      *statusRow
+	Suppression *drift.Suppression
+	Impact      *impactRow
+
+	// DeleteBefore deletes the drift events last seen before the date with
+	// their statuses, and the suppressions and analyses of before the date
+	DeleteBefore *time.Time
+}
+
+// Open opens the database at path, creating it if it does not exist.
+// An empty path opens a database that is only kept in memory.
+func Open(path string) (*DB, error) {
+	db := &DB{
+		path:     path,
+		events:   make(map[string]*types.DriftEvent),
+		statuses: make(map[string]statusRow),
+		now:      time.Now,
+	}
+	if path == "" {
+		return db, nil
+	}
+
+	// Another process holding the file would block Open forever
+	file, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	db.file = file
+
+	if err := db.load(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// Name identifies the embedded backend
+func (db *DB) Name() string {
+	return "embedded"
+}
+
+// DriftStore returns the drift store of the database
+func (db *DB) DriftStore() storage.DriftStore {
+	return NewDriftStore(db)
+}
+
+// ImpactStore returns the impact store of the database
+func (db *DB) ImpactStore() storage.ImpactStore {
+	return NewImpactStore(db)
+}
+
+// HealthStatus represents the health status of the embedded database
+type HealthStatus struct {
+	Path           string `json:"path,omitempty"`
+	DriftEvents    int    `json:"drift_events"`
+	ImpactAnalyses int    `json:"impact_analyses"`
+}
+
+// Health returns the number of stored rows
+func (db *DB) Health(ctx context.Context) (interface{}, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, ErrClosed
+	}
+	return &HealthStatus{Path: db.path, DriftEvents: len(db.events), ImpactAnalyses: len(db.impacts)}, nil
+}
+
+// Close closes the database file
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return nil
+	}
+	db.closed = true
+	if db.file != nil {
+		return db.file.Close()
+	}
+	return nil
+}
+
+// commit writes records to the database file in one transaction and applies
+// them to the tables once it is committed, so a failed write changes neither.
+// The rows past the retention are deleted in the same transaction once a day.
+// Records are copied through JSON first, so the stored values never alias the
+// caller's and look exactly as they will after a restart.
+// The caller must hold db.mu.
+func (db *DB) commit(records ...record) error {
+	if db.closed {
+		return ErrClosed
+	}
+
+	expire := false
+	if now := db.now(); !now.Before(db.expireAt) {
+		before := day(now).AddDate(0, 0, -retentionDays)
+		records = append(records[:len(records):len(records)], record{DeleteBefore: &before})
+		expire = true
+	}
+
+	copies := make([]record, len(records))
+	for i, r := range records {
+		c, err := copyRecord(r)
+		if err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+		copies[i] = c
+	}
+
+	if db.file != nil {
+		err := db.file.Update(func(tx *bolt.Tx) error {
+			for _, r := range copies {
+				if err := write(tx, r); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", db.path, err)
+		}
+	}
+
+	for _, r := range copies {
+		db.apply(r)
+	}
+	if expire {
+		db.expireAt = day(db.now()).AddDate(0, 0, 1)
+	}
+	return nil
+}
+
+// copyRecord returns a deep copy of the record. The lifecycle columns of a
+// drift event are dropped, drift_events does not hold them.
+func copyRecord(r record) (record, error) {
+	var c record
+	var err error
+	switch {
+	case r.Event != nil:
+		if c.Event, err = clone(r.Event); err != nil {
+			return c, err
+		}
+		c.Event.Workspace = ""
+		c.Event.Status = ""
+		c.Event.FirstSeen, c.Event.LastSeen = time.Time{}, time.Time{}
+		c.Event.ResolvedAt, c.Event.AcknowledgedAt = nil, nil
+		c.Event.AcknowledgedBy = ""
+	case r.Status != nil:
+		c.Status, err = clone(r.Status)
+	case r.Suppression != nil:
+		c.Suppression, err = clone(r.Suppression)
+	case r.Impact != nil:
+		c.Impact, err = clone(r.Impact)
+	case r.DeleteBefore != nil:
+		before := *r.DeleteBefore
+		c.DeleteBefore = &before
+	}
+	return c, err
+}
+
+// write stores a record in the buckets of the database file
+func write(tx *bolt.Tx, r record) error {
+	switch {
+	case r.Event != nil:
+		// Like LIMIT 1 BY id, the newest row of an event wins
+		bucket := tx.Bucket(eventsBucket)
+		var existing types.DriftEvent
+		if found, err := get(bucket, []byte(r.Event.ID), &existing); err != nil {
+			return err
+		} else if found && existing.Timestamp.After(r.Event.Timestamp) {
+			return nil
+		}
+		return put(bucket, []byte(r.Event.ID), r.Event)
+
+	case r.Status != nil:
+		return put(tx.Bucket(statusesBucket), []byte(r.Status.ID), r.Status)
+
+	case r.Suppression != nil:
+		return appendRow(tx.Bucket(suppressionsBucket), r.Suppression)
+
+	case r.Impact != nil:
+		return appendRow(tx.Bucket(impactsBucket), r.Impact)
+
+	case r.DeleteBefore != nil:
+		return deleteBefore(tx, *r.DeleteBefore)
+	}
+	return nil
+}
+
+// deleteBefore deletes the rows of before the date from the buckets
+func deleteBefore(tx *bolt.Tx, before time.Time) error {
+	statuses := tx.Bucket(statusesBucket)
+	expired := func(key []byte, v []byte) (bool, error) {
+		var event types.DriftEvent
+		if err := json.Unmarshal(v, &event); err != nil {
+			return false, err
+		}
+		var status statusRow
+		if _, err := get(statuses, key, &status); err != nil {
+			return false, err
+		}
+		return lastSeen(&event, status).Before(before), nil
+	}
+	deleted, err := deleteWhere(tx.Bucket(eventsBucket), expired)
+	if err != nil {
+		return err
+	}
+	for _, key := range deleted {
+		if err := statuses.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	if _, err := deleteWhere(tx.Bucket(suppressionsBucket), func(_, v []byte) (bool, error) {
+		var suppression drift.Suppression
+		err := json.Unmarshal(v, &suppression)
+		return day(suppression.Timestamp).Before(before), err
+	}); err != nil {
+		return err
+	}
+
+	_, err = deleteWhere(tx.Bucket(impactsBucket), func(_, v []byte) (bool, error) {
+		var row impactRow
+		err := json.Unmarshal(v, &row)
+		return day(row.AnalyzedAt).Before(before), err
+	})
+	return err
+}
+
+// deleteWhere deletes the keys of a bucket the function matches and returns them
+func deleteWhere(bucket *bolt.Bucket, match func(k, v []byte) (bool, error)) ([][]byte, error) {
+	var keys [][]byte
+	err := bucket.ForEach(func(k, v []byte) error {
+		ok, err := match(k, v)
+		if ok {
+			// Keys are only valid during the iteration
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if err := bucket.Delete(key); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// lastSeen returns the date a drift event was last seen (its timestamp
+// without a status)
+func lastSeen(event *types.DriftEvent, status statusRow) time.Time {
+	if status.LastSeen.After(event.Timestamp) {
+		return day(status.LastSeen)
+	}
+	return day(event.Timestamp)
+}
+
+// get decodes the value of key into v and reports whether it exists
+func get(bucket *bolt.Bucket, key []byte, v interface{}) (bool, error) {
+	data := bucket.Get(key)
+	if data == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// put stores v as JSON under key
+func put(bucket *bolt.Bucket, key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, data)
+}
+
+// appendRow stores v under the next sequence number, so the rows iterate in the
+// order they were saved
+func appendRow(bucket *bolt.Bucket, v interface{}) error {
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return put(bucket, key, v)
+}
+
+// apply changes the tables by a committed record
+func (db *DB) apply(r record) {
+	switch {
+	case r.Event != nil:
+		// Like LIMIT 1 BY id, the newest row of an event wins
+		if existing, ok := db.events[r.Event.ID]; ok && existing.Timestamp.After(r.Event.Timestamp) {
+			return
+		}
+		db.events[r.Event.ID] = r.Event
+
+	case r.Status != nil:
+		db.statuses[r.Status.ID] = *r.Status
+
+	case r.Suppression != nil:
+		db.suppressions = append(db.suppressions, *r.Suppression)
+
+	case r.Impact != nil:
+		db.impacts = append(db.impacts, *r.Impact)
+
+	case r.DeleteBefore != nil:
+		before := *r.DeleteBefore
+		for id, event := range db.events {
+			if lastSeen(event, db.statuses[id]).Before(before) {
+				delete(db.events, id)
+				delete(db.statuses, id)
+			}
+		}
+
+		suppressions := db.suppressions[:0]
+		for _, suppression := range db.suppressions {
+			if !day(suppression.Timestamp).Before(before) {
+				suppressions = append(suppressions, suppression)
+			}
+		}
+		db.suppressions = suppressions
+
+		impacts := db.impacts[:0]
+		for _, row := range db.impacts {
+			if !day(row.AnalyzedAt).Before(before) {
+				impacts = append(impacts, row)
+			}
+		}
+		db.impacts = impacts
+	}
+}
+
+// load creates the buckets and reads them into the tables
+func (db *DB) load() error {
+	return db.file.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{eventsBucket, statusesBucket, suppressionsBucket, impactsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Bucket(eventsBucket).ForEach(func(k, v []byte) error {
+			var event types.DriftEvent
+			if err := json.Unmarshal(v, &event); err != nil {
+				return fmt.Errorf("drift event %s: %w", k, err)
+			}
+			db.events[event.ID] = &event
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		if err := tx.Bucket(statusesBucket).ForEach(func(k, v []byte) error {
+			var status statusRow
+			if err := json.Unmarshal(v, &status); err != nil {
+				return fmt.Errorf("drift status %s: %w", k, err)
+			}
+			db.statuses[status.ID] = status
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		if err := tx.Bucket(suppressionsBucket).ForEach(func(k, v []byte) error {
+			var suppression drift.Suppression
+			if err := json.Unmarshal(v, &suppression); err != nil {
+				return fmt.Errorf("suppression %x: %w", k, err)
+			}
+			db.suppressions = append(db.suppressions, suppression)
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		return tx.Bucket(impactsBucket).ForEach(func(k, v []byte) error {
+			var row impactRow
+			if err := json.Unmarshal(v, &row); err != nil {
+				return fmt.Errorf("impact analysis %x: %w", k, err)
+			}
+			db.impacts = append(db.impacts, row)
+			return nil
+		})
+	})
+}
+
+// day returns the date of t, as the date columns of the ClickHouse schema
+func day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// since returns the first date included in stats of the last days
+// (`date >= today() - INTERVAL days DAY`)
+func (db *DB) since(days int) time.Time {
+	return day(db.now()).AddDate(0, 0, -days)
+}
+
+// clone returns a deep copy of v, so callers can't change the stored rows
+func clone[T any](v *T) (*T, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("embedded: failed to copy row: %w", err)
+	}
+	var c T
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("embedded: failed to copy row: %w", err)
+	}
+	return &c, nil
+}
+
+// topCounts keeps the n largest counts (ties by key), as `ORDER BY count() DESC LIMIT n`
+func topCounts(counts map[string]uint64, n int) map[string]uint64 {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	top := make(map[string]uint64, min(n, len(keys)))
+	for _, key := range keys[:min(n, len(keys))] {
+		top[key] = counts[key]
+	}
+	return top
+}
@@ -0,0 +1,216 @@
+package embedded
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/drift"
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage"
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage/storagetest"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+func openTestDB(t *testing.T, path string) *DB {
+	t.Helper()
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestConformance(t *testing.T) {
+	t.Run("Memory", func(t *testing.T) {
+		storagetest.Run(t, func(t *testing.T) storage.Backend {
+			return openTestDB(t, "")
+		})
+	})
+	t.Run("File", func(t *testing.T) {
+		storagetest.Run(t, func(t *testing.T) storage.Backend {
+			return openTestDB(t, filepath.Join(t.TempDir(), "deepdrift.db"))
+		})
+	})
+}
+
+func TestPersistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "deepdrift.db")
+	ts := time.Now().UTC().Truncate(time.Millisecond)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	event := &types.DriftEvent{ID: "drift-1", ResourceID: "i-1", ResourceType: "aws_instance", Type: types.DriftModified, Severity: types.SeverityHigh, Timestamp: ts}
+	if err := db.DriftStore().SaveDriftEvent(ctx, event); err != nil {
+		t.Fatalf("SaveDriftEvent: %v", err)
+	}
+	if _, err := db.DriftStore().UpdateDriftStatus(ctx, "drift-1", types.DriftAcknowledged, "alice"); err != nil {
+		t.Fatalf("UpdateDriftStatus: %v", err)
+	}
+	if _, err := db.DriftStore().UpdateDriftStatus(ctx, "drift-1", types.DriftResolved, "alice"); err != nil {
+		t.Fatalf("UpdateDriftStatus: %v", err)
+	}
+	if err := db.ImpactStore().SaveImpactAnalysis(ctx, &types.ImpactAnalysisResult{DriftEventID: "drift-1", BlastRadius: 2, Severity: types.SeverityHigh}); err != nil {
+		t.Fatalf("SaveImpactAnalysis: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := db.DriftStore().SaveDriftEvent(ctx, event); err != ErrClosed {
+		t.Errorf("SaveDriftEvent after Close error = %v, want ErrClosed", err)
+	}
+
+	db = openTestDB(t, path)
+	got, err := db.DriftStore().GetDriftEvent(ctx, "drift-1")
+	if err != nil {
+		t.Fatalf("GetDriftEvent: %v", err)
+	}
+	if got.Status != types.DriftResolved || got.AcknowledgedBy != "alice" || got.ResolvedAt == nil || !got.Timestamp.Equal(ts) {
+		t.Errorf("event after reopen = %+v", got)
+	}
+	if impact, err := db.ImpactStore().GetImpactAnalysis(ctx, "drift-1"); err != nil || impact.BlastRadius != 2 {
+		t.Errorf("GetImpactAnalysis after reopen = %+v, %v", impact, err)
+	}
+
+	// A status is stored once per drift event, however often it changes
+	if n := bucketLen(t, db, statusesBucket); n != 1 {
+		t.Errorf("drift_event_status has %d rows, want 1", n)
+	}
+}
+
+func TestFailedWrite(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, filepath.Join(t.TempDir(), "deepdrift.db"))
+	if err := db.DriftStore().SaveDriftEvent(ctx, &types.DriftEvent{ID: "drift-1", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("SaveDriftEvent: %v", err)
+	}
+
+	// A write that fails to commit doesn't change the tables
+	db.file.Close()
+	if err := db.DriftStore().SaveDriftEvent(ctx, &types.DriftEvent{ID: "drift-2", Timestamp: time.Now()}); err == nil {
+		t.Fatal("SaveDriftEvent to a closed file should fail")
+	}
+	events, err := db.DriftStore().ListDriftEvents(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListDriftEvents: %v", err)
+	}
+	if len(events) != 1 || events[0].ID != "drift-1" {
+		t.Errorf("events = %v, want only drift-1", events)
+	}
+}
+
+func TestOpenCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deepdrift.db")
+	if err := os.WriteFile(path, []byte("{\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("Open(corrupt) should fail")
+	}
+}
+
+func TestDeleteOldDriftEvents(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, "")
+	store := db.DriftStore()
+	now := time.Now()
+
+	events := []*types.DriftEvent{
+		{ID: "old", Timestamp: now.AddDate(0, 0, -40), Status: types.DriftOpen},
+		{ID: "new", Timestamp: now, Status: types.DriftOpen},
+	}
+	if err := store.RecordDriftChanges(ctx, drift.Changes{Created: events}); err != nil {
+		t.Fatalf("RecordDriftChanges: %v", err)
+	}
+	suppressions := []drift.Suppression{
+		{EventID: "old", Reason: drift.SuppressedTag, Timestamp: now.AddDate(0, 0, -40)},
+		{EventID: "new", Reason: drift.SuppressedTag, Timestamp: now},
+	}
+	if err := store.SaveSuppressions(ctx, suppressions); err != nil {
+		t.Fatalf("SaveSuppressions: %v", err)
+	}
+
+	deleted, err := store.DeleteOldDriftEvents(ctx, 30)
+	if err != nil {
+		t.Fatalf("DeleteOldDriftEvents: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted = %d, want 1", deleted)
+	}
+	if _, err := store.GetDriftEvent(ctx, "old"); err == nil {
+		t.Error("old drift event was not deleted")
+	}
+	if unresolved, _ := store.ListUnresolvedDriftEvents(ctx, nil); len(unresolved) != 1 || unresolved[0].ID != "new" {
+		t.Errorf("unresolved = %v, want only new", unresolved)
+	}
+	if len(db.suppressions) != 1 || db.suppressions[0].EventID != "new" {
+		t.Errorf("suppressions = %v, want only new", db.suppressions)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "deepdrift.db")
+	db := openTestDB(t, path)
+	now := time.Now()
+	old := now.AddDate(0, 0, -(retentionDays + 10))
+
+	// Rows saved 100 days ago
+	db.now = func() time.Time { return old }
+	events := []*types.DriftEvent{
+		{ID: "gone", Timestamp: old, Status: types.DriftResolved, LastSeen: old},
+		// Still detected, the status was updated recently
+		{ID: "ongoing", Timestamp: old, Status: types.DriftOpen, LastSeen: old},
+	}
+	if err := db.DriftStore().RecordDriftChanges(ctx, drift.Changes{Created: events}); err != nil {
+		t.Fatalf("RecordDriftChanges: %v", err)
+	}
+	if err := db.DriftStore().SaveSuppressions(ctx, []drift.Suppression{{EventID: "gone", Reason: drift.SuppressedTag, Timestamp: old}}); err != nil {
+		t.Fatalf("SaveSuppressions: %v", err)
+	}
+	if err := db.ImpactStore().SaveImpactAnalysis(ctx, &types.ImpactAnalysisResult{DriftEventID: "gone"}); err != nil {
+		t.Fatalf("SaveImpactAnalysis: %v", err)
+	}
+
+	// The first write of a day deletes the rows past the retention
+	db.now = func() time.Time { return now }
+	events[1].LastSeen = now
+	if err := db.DriftStore().RecordDriftChanges(ctx, drift.Changes{Updated: events[1:]}); err != nil {
+		t.Fatalf("RecordDriftChanges: %v", err)
+	}
+
+	check := func(db *DB) {
+		t.Helper()
+		if len(db.events) != 1 || db.events["ongoing"] == nil {
+			t.Errorf("events = %v, want only ongoing", db.events)
+		}
+		if len(db.statuses) != 1 || len(db.suppressions) != 0 || len(db.impacts) != 0 {
+			t.Errorf("statuses = %d, suppressions = %d, impacts = %d; want 1, 0, 0", len(db.statuses), len(db.suppressions), len(db.impacts))
+		}
+	}
+	check(db)
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	check(openTestDB(t, path))
+}
+
+// bucketLen returns the number of rows in a bucket of the database file
+func bucketLen(t *testing.T, db *DB, name []byte) int {
+	t.Helper()
+	var n int
+	if err := db.file.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(name).Stats().KeyN
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -0,0 +1,271 @@
+package embedded
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/drift"
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// DriftStore handles drift event storage operations
+type DriftStore struct {
+	db *DB
+}
+
+var _ storage.DriftStore = (*DriftStore)(nil)
+
+// NewDriftStore creates a new drift store
+func NewDriftStore(db *DB) *DriftStore {
+	return &DriftStore{db: db}
+}
+
+// SaveDriftEvent saves a single drift event
+func (s *DriftStore) SaveDriftEvent(ctx context.Context, event *types.DriftEvent) error {
+	return s.SaveDriftEvents(ctx, []*types.DriftEvent{event})
+}
+
+// SaveDriftEvents saves multiple drift events
+func (s *DriftStore) SaveDriftEvents(ctx context.Context, events []*types.DriftEvent) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.commit(eventRecords(events)...)
+}
+
+func eventRecords(events []*types.DriftEvent) []record {
+	records := make([]record, len(events))
+	for i, event := range events {
+		records[i] = record{Event: event}
+	}
+	return records
+}
+
+// withStatus returns a copy of a stored event joined with its latest status
+// (the caller must hold db.mu)
+func (db *DB) withStatus(stored *types.DriftEvent) (*types.DriftEvent, error) {
+	event, err := clone(stored)
+	if err != nil {
+		return nil, err
+	}
+	status, ok := db.statuses[event.ID]
+	if !ok {
+		event.Status = types.DriftOpen
+		event.FirstSeen = event.Timestamp
+		event.LastSeen = event.Timestamp
+		return event, nil
+	}
+
+	event.Workspace = status.Workspace
+	event.Status = status.Status
+	event.FirstSeen = status.FirstSeen
+	event.LastSeen = status.LastSeen
+	event.ResolvedAt = status.ResolvedAt
+	event.AcknowledgedBy = status.AcknowledgedBy
+	event.AcknowledgedAt = status.AcknowledgedAt
+	return event, nil
+}
+
+// GetDriftEvent retrieves a single drift event by ID
+func (s *DriftStore) GetDriftEvent(ctx context.Context, id string) (*types.DriftEvent, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	stored, ok := s.db.events[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", drift.ErrDriftNotFound, id)
+	}
+	return s.db.withStatus(stored)
+}
+
+// ListDriftEvents lists drift events with filters
+func (s *DriftStore) ListDriftEvents(ctx context.Context, filter *storage.DriftEventFilter) ([]*types.DriftEvent, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	events := []*types.DriftEvent{}
+	for _, stored := range s.db.events {
+		event, err := s.db.withStatus(stored)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Match(event) {
+			events = append(events, event)
+		}
+	}
+
+	return storage.SortDrifts(events, filter), nil
+}
+
+// ListUnresolvedDriftEvents lists open and acknowledged drift events.
+// When workspaces is not empty only events of those workspaces are returned.
+func (s *DriftStore) ListUnresolvedDriftEvents(ctx context.Context, workspaces []string) ([]*types.DriftEvent, error) {
+	return s.ListDriftEvents(ctx, &storage.DriftEventFilter{
+		Statuses:   []types.DriftStatus{types.DriftOpen, types.DriftAcknowledged},
+		Workspaces: workspaces,
+	})
+}
+
+// SaveDriftStatuses records the current lifecycle status of drift events
+func (s *DriftStore) SaveDriftStatuses(ctx context.Context, events []*types.DriftEvent) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.commit(s.db.statusRecords(events)...)
+}
+
+// statusRecords returns a status version for every event
+func (db *DB) statusRecords(events []*types.DriftEvent) []record {
+	now := db.now()
+	records := make([]record, len(events))
+	for i, event := range events {
+		status := event.Status
+		if status == "" {
+			status = types.DriftOpen
+		}
+		records[i] = record{Status: &statusRow{
+			ID:             event.ID,
+			Workspace:      event.Workspace,
+			Status:         status,
+			FirstSeen:      event.FirstSeen,
+			LastSeen:       event.LastSeen,
+			ResolvedAt:     event.ResolvedAt,
+			AcknowledgedBy: event.AcknowledgedBy,
+			AcknowledgedAt: event.AcknowledgedAt,
+			UpdatedAt:      now,
+		}}
+	}
+	return records
+}
+
+// SaveSuppressions records drift events hidden by the suppression policy
+func (s *DriftStore) SaveSuppressions(ctx context.Context, suppressions []drift.Suppression) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	records := make([]record, len(suppressions))
+	for i := range suppressions {
+		records[i] = record{Suppression: &suppressions[i]}
+	}
+	return s.db.commit(records...)
+}
+
+// RecordDriftChanges persists the result of a detection cycle.
+// New drifts are saved once (a drift that was resolved and reappears keeps its
+// original event); every change replaces the status of its drift.
+func (s *DriftStore) RecordDriftChanges(ctx context.Context, changes drift.Changes) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	created := make([]*types.DriftEvent, 0, len(changes.Created))
+	for _, event := range changes.Created {
+		if _, ok := s.db.events[event.ID]; !ok {
+			created = append(created, event)
+		}
+	}
+
+	// The events and their statuses are written together, so a crash can't
+	// leave a new drift without its status
+	records := append(eventRecords(created), s.db.statusRecords(changes.All())...)
+	return s.db.commit(records...)
+}
+
+// UpdateDriftStatus changes the lifecycle status of a drift event (e.g. acknowledge, resolve)
+func (s *DriftStore) UpdateDriftStatus(ctx context.Context, id string, status types.DriftStatus, user string) (*types.DriftEvent, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	stored, ok := s.db.events[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", drift.ErrDriftNotFound, id)
+	}
+
+	event, err := s.db.withStatus(stored)
+	if err != nil {
+		return nil, err
+	}
+	if err := drift.SetStatus(event, status, user, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.db.commit(s.db.statusRecords([]*types.DriftEvent{event})...); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// GetDriftStats returns drift statistics
+func (s *DriftStore) GetDriftStats(ctx context.Context, days int) (*storage.DriftStats, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	since := s.db.since(days)
+	stats := &storage.DriftStats{
+		BySeverity:         make(map[string]uint64),
+		ByType:             make(map[string]uint64),
+		ByStatus:           make(map[string]uint64),
+		SuppressedByReason: make(map[string]uint64),
+	}
+
+	byResourceType := make(map[string]uint64)
+	for id, event := range s.db.events {
+		if day(event.Timestamp).Before(since) {
+			continue
+		}
+		stats.TotalCount++
+		stats.BySeverity[string(event.Severity)]++
+		stats.ByType[string(event.Type)]++
+		byResourceType[event.ResourceType]++
+
+		status := types.DriftOpen
+		if row, ok := s.db.statuses[id]; ok {
+			status = row.Status
+		}
+		stats.ByStatus[string(status)]++
+	}
+	stats.ByResourceType = topCounts(byResourceType, topResourceTypes)
+
+	// Suppressed drifts (a drift suppressed in every cycle counts once)
+	suppressed := make(map[string]bool)
+	byReason := make(map[drift.SuppressionReason]map[string]bool)
+	for _, suppression := range s.db.suppressions {
+		if day(suppression.Timestamp).Before(since) {
+			continue
+		}
+		suppressed[suppression.EventID] = true
+		if byReason[suppression.Reason] == nil {
+			byReason[suppression.Reason] = make(map[string]bool)
+		}
+		byReason[suppression.Reason][suppression.EventID] = true
+	}
+	stats.SuppressedCount = uint64(len(suppressed))
+	for reason, ids := range byReason {
+		stats.SuppressedByReason[string(reason)] = uint64(len(ids))
+	}
+
+	return stats, nil
+}
+
+// topResourceTypes is the number of resource types the stats break down by
+const topResourceTypes = 10
+
+// DeleteOldDriftEvents deletes drift events not seen for the specified days,
+// with the suppressions and impact analyses older than that
+func (s *DriftStore) DeleteOldDriftEvents(ctx context.Context, days int) (uint64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	before := s.db.since(days)
+	var count uint64
+	for id, event := range s.db.events {
+		if lastSeen(event, s.db.statuses[id]).Before(before) {
+			count++
+		}
+	}
+	if err := s.db.commit(record{DeleteBefore: &before}); err != nil {
+		return 0, fmt.Errorf("failed to delete old drift events: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,200 @@
+package embedded
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// ImpactStore handles impact analysis storage operations
+type ImpactStore struct {
+	db *DB
+}
+
+var _ storage.ImpactStore = (*ImpactStore)(nil)
+
+// NewImpactStore creates a new impact store
+func NewImpactStore(db *DB) *ImpactStore {
+	return &ImpactStore{db: db}
+}
+
+// SaveImpactAnalysis saves an impact analysis result
+func (s *ImpactStore) SaveImpactAnalysis(ctx context.Context, result *types.ImpactAnalysisResult) error {
+	return s.SaveImpactAnalysisBatch(ctx, []*types.ImpactAnalysisResult{result})
+}
+
+// SaveImpactAnalysisBatch saves multiple impact analysis results
+func (s *ImpactStore) SaveImpactAnalysisBatch(ctx context.Context, results []*types.ImpactAnalysisResult) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	analyzedAt := s.db.now()
+	records := make([]record, len(results))
+	for i, result := range results {
+		records[i] = record{Impact: &impactRow{Result: result, AnalyzedAt: analyzedAt}}
+	}
+	if err := s.db.commit(records...); err != nil {
+		return fmt.Errorf("failed to save impact analysis: %w", err)
+	}
+	return nil
+}
+
+// newestImpacts returns the saved analyses newest first; analyses saved at
+// the same time are ordered by when they were saved
+// (the caller must hold db.mu)
+func (db *DB) newestImpacts() []impactRow {
+	rows := make([]impactRow, len(db.impacts))
+	for i, row := range db.impacts {
+		rows[len(rows)-1-i] = row
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].AnalyzedAt.After(rows[j].AnalyzedAt)
+	})
+	return rows
+}
+
+// GetImpactAnalysis retrieves impact analysis for a drift event
+func (s *ImpactStore) GetImpactAnalysis(ctx context.Context, driftEventID string) (*types.ImpactAnalysisResult, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, row := range s.db.newestImpacts() {
+		if row.Result.DriftEventID == driftEventID {
+			return row.analysis()
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", storage.ErrImpactNotFound, driftEventID)
+}
+
+// ListImpactAnalysis lists impact analysis results with filters
+func (s *ImpactStore) ListImpactAnalysis(ctx context.Context, filter *storage.ImpactAnalysisFilter) ([]*types.ImpactAnalysisResult, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	results := []*types.ImpactAnalysisResult{}
+	for _, row := range s.db.newestImpacts() {
+		result, err := row.analysis()
+		if err != nil {
+			return nil, err
+		}
+		var event *types.DriftEvent
+		if stored, ok := s.db.events[result.DriftEventID]; ok {
+			if event, err = s.db.withStatus(stored); err != nil {
+				return nil, err
+			}
+		}
+		if filter.Match(result, event) {
+			results = append(results, result)
+		}
+	}
+
+	return storage.SortImpacts(results, filter), nil
+}
+
+// GetAffectedResources retrieves affected resources for a drift event.
+// As with the affected_resources table, every saved analysis of the drift
+// event adds its resources.
+func (s *ImpactStore) GetAffectedResources(ctx context.Context, driftEventID string) ([]types.AffectedResource, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	resources := []types.AffectedResource{}
+	for _, row := range s.db.impacts {
+		if row.Result.DriftEventID == driftEventID {
+			result, err := clone(row.Result)
+			if err != nil {
+				return nil, err
+			}
+			resources = append(resources, result.AffectedResources...)
+		}
+	}
+
+	sort.SliceStable(resources, func(i, j int) bool {
+		a, b := resources[i], resources[j]
+		if a.ImpactScore != b.ImpactScore {
+			return a.ImpactScore > b.ImpactScore
+		}
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		return a.ResourceType < b.ResourceType
+	})
+
+	return resources, nil
+}
+
+// GetImpactStats returns impact analysis statistics
+func (s *ImpactStore) GetImpactStats(ctx context.Context, days int) (*storage.ImpactStats, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	since := s.db.since(days)
+	stats := &storage.ImpactStats{}
+
+	var blastRadius, affected int
+	byResourceType := make(map[string]uint64)
+	for _, row := range s.db.impacts {
+		if day(row.AnalyzedAt).Before(since) {
+			continue
+		}
+		stats.TotalAnalyzed++
+		blastRadius += row.Result.BlastRadius
+		affected += row.Result.AffectedResourceCount
+		for _, resource := range row.Result.AffectedResources {
+			byResourceType[resource.ResourceType]++
+		}
+	}
+
+	if stats.TotalAnalyzed > 0 {
+		stats.AvgBlastRadius = float64(blastRadius) / float64(stats.TotalAnalyzed)
+		stats.AvgAffectedResources = float64(affected) / float64(stats.TotalAnalyzed)
+	}
+	stats.TopAffectedResourceTypes = topCounts(byResourceType, topResourceTypes)
+
+	return stats, nil
+}
+
+// GetHighImpactDrifts returns drifts with high impact
+func (s *ImpactStore) GetHighImpactDrifts(ctx context.Context, days int, limit int) ([]*storage.HighImpactDrift, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	since := s.db.since(days)
+	drifts := []*storage.HighImpactDrift{}
+	for _, row := range s.db.impacts {
+		event, ok := s.db.events[row.Result.DriftEventID]
+		if !ok || day(event.Timestamp).Before(since) {
+			continue
+		}
+		drifts = append(drifts, &storage.HighImpactDrift{
+			ID:                    event.ID,
+			ResourceID:            event.ResourceID,
+			ResourceType:          event.ResourceType,
+			DriftType:             event.Type,
+			Timestamp:             event.Timestamp,
+			AffectedResourceCount: row.Result.AffectedResourceCount,
+			BlastRadius:           row.Result.BlastRadius,
+			ImpactScore:           row.Result.ImpactScore,
+			Severity:              row.Result.Severity,
+		})
+	}
+
+	sort.SliceStable(drifts, func(i, j int) bool {
+		a, b := drifts[i], drifts[j]
+		if a.ImpactScore != b.ImpactScore {
+			return a.ImpactScore > b.ImpactScore
+		}
+		if a.BlastRadius != b.BlastRadius {
+			return a.BlastRadius > b.BlastRadius
+		}
+		return a.AffectedResourceCount > b.AffectedResourceCount
+	})
+	if limit >= 0 && len(drifts) > limit {
+		drifts = drifts[:limit]
+	}
+
+	return drifts, nil
+}
@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/severity"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// ErrInvalidCursor is returned for cursors that were not issued for the
+// requested sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SortOrder orders list results. Every order ends with the item ID, so
+// items with the same time keep their order across pages.
+type SortOrder string
+
+const (
+	// SortNewest lists the newest first (the default)
+	SortNewest SortOrder = "newest"
+
+	// SortOldest lists the oldest first
+	SortOldest SortOrder = "oldest"
+
+	// SortSeverity lists the most severe first, then the newest
+	SortSeverity SortOrder = "severity"
+)
+
+// ParseSortOrder parses a sort order ("" is SortNewest)
+func ParseSortOrder(s string) (SortOrder, error) {
+	switch order := SortOrder(s); order {
+	case "":
+		return SortNewest, nil
+	case SortNewest, SortOldest, SortSeverity:
+		return order, nil
+	}
+	return "", fmt.Errorf("unknown sort order %q (newest, oldest, severity)", s)
+}
+
+// SortKey is the position of an item in a sort order. For drift events Time
+// is the timestamp and ID the event ID; for impact analysis Time is when it
+// was analysed and ID the drift event ID.
+type SortKey struct {
+	Severity types.Severity `json:"v,omitempty"`
+	Time     time.Time      `json:"t"`
+	ID       string         `json:"i"`
+}
+
+// DriftSortKey returns the sort key of a drift event
+func DriftSortKey(event *types.DriftEvent) SortKey {
+	return SortKey{Severity: event.Severity, Time: event.Timestamp, ID: event.ID}
+}
+
+// ImpactSortKey returns the sort key of an impact analysis
+func ImpactSortKey(result *types.ImpactAnalysisResult) SortKey {
+	return SortKey{Severity: result.Severity, Time: result.AnalyzedAt, ID: result.DriftEventID}
+}
+
+// Less reports whether a sorts before b
+func (o SortOrder) Less(a, b SortKey) bool {
+	if o == SortSeverity {
+		if ra, rb := severity.Rank(a.Severity), severity.Rank(b.Severity); ra != rb {
+			return ra > rb
+		}
+	}
+	if !a.Time.Equal(b.Time) {
+		if o == SortOldest {
+			return a.Time.Before(b.Time)
+		}
+		return a.Time.After(b.Time)
+	}
+	if o == SortOldest {
+		return a.ID < b.ID
+	}
+	return a.ID > b.ID
+}
+
+// Cursor is the position after the last item of a page. Clients get it as an
+// opaque string and send it back for the next page.
+type Cursor struct {
+	Sort SortOrder `json:"s"`
+	SortKey
+}
+
+// String encodes the cursor for clients
+func (c *Cursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes a cursor issued for the sort order
+func ParseCursor(s string, order SortOrder) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != order {
+		return nil, fmt.Errorf("%w: it was issued for sort=%s", ErrInvalidCursor, cursor.Sort)
+	}
+	return &cursor, nil
+}
+
+// Match reports whether a drift event, joined with its status, passes the filter
+func (f *DriftEventFilter) Match(event *types.DriftEvent) bool {
+	if f == nil {
+		return true
+	}
+	if !f.StartTime.IsZero() && event.Timestamp.Before(f.StartTime) {
+		return false
+	}
+	if !f.EndTime.IsZero() && event.Timestamp.After(f.EndTime) {
+		return false
+	}
+	if f.ResourceType != "" && event.ResourceType != f.ResourceType {
+		return false
+	}
+	if f.DriftType != "" && event.Type != f.DriftType {
+		return false
+	}
+	if f.Severity != "" && event.Severity != f.Severity {
+		return false
+	}
+	if f.UserIdentity != "" && (event.RootCause == nil || event.RootCause.UserIdentity != f.UserIdentity) {
+		return false
+	}
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, event.Status) {
+		return false
+	}
+	if len(f.Workspaces) > 0 && !slices.Contains(f.Workspaces, event.Workspace) {
+		return false
+	}
+	if f.After != nil && !f.Sort.Less(f.After.SortKey, DriftSortKey(event)) {
+		return false
+	}
+	return f.Query.Match(func(field string) interface{} { return driftField(event, field) })
+}
+
+// driftField returns the value of a query field of a drift event
+func driftField(event *types.DriftEvent, field string) interface{} {
+	switch field {
+	case "id":
+		return event.ID
+	case "resource_id":
+		return event.ResourceID
+	case "resource_type":
+		return event.ResourceType
+	case "type":
+		return string(event.Type)
+	case "severity":
+		return string(event.Severity)
+	case "status":
+		return string(event.Status)
+	case "workspace":
+		return event.Workspace
+	case "user":
+		if event.RootCause == nil {
+			return ""
+		}
+		return event.RootCause.UserIdentity
+	case "changed":
+		paths := make([]string, 0, len(event.Diff))
+		for path := range event.Diff {
+			paths = append(paths, path)
+		}
+		return paths
+	case "tag":
+		return event.ResourceTags()
+	case "timestamp":
+		return event.Timestamp
+	}
+	return nil
+}
+
+// SortDrifts sorts drift events in the filter's order and applies its limit.
+// Together with DriftEventFilter.Match it lists drift events in memory the
+// way the ClickHouse backend does.
+func SortDrifts(events []*types.DriftEvent, filter *DriftEventFilter) []*types.DriftEvent {
+	var order SortOrder
+	if filter != nil {
+		order = filter.Sort
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return order.Less(DriftSortKey(events[i]), DriftSortKey(events[j]))
+	})
+	if filter != nil && filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events
+}
+
+// Match reports whether an impact analysis passes the filter. drift is the
+// analysed drift event, which resource queries match (nil if unknown).
+func (f *ImpactAnalysisFilter) Match(result *types.ImpactAnalysisResult, drift *types.DriftEvent) bool {
+	if f == nil {
+		return true
+	}
+	if !f.StartTime.IsZero() && result.AnalyzedAt.Before(f.StartTime) {
+		return false
+	}
+	if !f.EndTime.IsZero() && result.AnalyzedAt.After(f.EndTime) {
+		return false
+	}
+	if f.Severity != "" && result.Severity != f.Severity {
+		return false
+	}
+	if result.BlastRadius < f.MinBlastRadius || result.AffectedResourceCount < f.MinAffectedResources {
+		return false
+	}
+	if f.After != nil && !f.Sort.Less(f.After.SortKey, ImpactSortKey(result)) {
+		return false
+	}
+	return f.Query.Match(func(field string) interface{} {
+		switch field {
+		case "drift_event_id":
+			return result.DriftEventID
+		case "severity":
+			return string(result.Severity)
+		case "blast_radius":
+			return float64(result.BlastRadius)
+		case "affected_resources":
+			return float64(result.AffectedResourceCount)
+		case "impact_score":
+			return result.ImpactScore
+		case "analyzed_at":
+			return result.AnalyzedAt
+		}
+		if drift == nil {
+			return nil
+		}
+		return driftField(drift, field)
+	})
+}
+
+// SortImpacts sorts impact analysis in the filter's order and applies its limit
+func SortImpacts(results []*types.ImpactAnalysisResult, filter *ImpactAnalysisFilter) []*types.ImpactAnalysisResult {
+	var order SortOrder
+	if filter != nil {
+		order = filter.Sort
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return order.Less(ImpactSortKey(results[i]), ImpactSortKey(results[j]))
+	})
+	if filter != nil && filter.Limit > 0 && len(results) > filter.Limit {
+		results = results[:filter.Limit]
+	}
+	return results
+}
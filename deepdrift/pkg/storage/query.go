@@ -0,0 +1,440 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/severity"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// Query is a parsed search expression for drift events or impact analysis,
+// e.g.
+//
+//	severity>=high status:open,acknowledged resource_id:aws:ec2:* changed:ingress tag:env=prod
+//
+// A query is a list of terms separated by spaces, all of which must match.
+// A term is a field, an operator and a value:
+//
+//   - field:a,b matches any of the values; strings may use the * and ? wildcards
+//   - field>=v, field>v, field<=v and field<v compare severities, numbers and times
+//   - a leading - negates a term: -status:resolved
+//
+// Values containing spaces or commas are quoted: user:"Jane Doe".
+type Query struct {
+	Terms []Term
+}
+
+// Operator compares a field with the values of a term
+type Operator string
+
+const (
+	OpMatch        Operator = ":"
+	OpGreaterEqual Operator = ">="
+	OpGreater      Operator = ">"
+	OpLessEqual    Operator = "<="
+	OpLess         Operator = "<"
+)
+
+// operators are tried in order, so the two-character ones come first
+var operators = []Operator{OpGreaterEqual, OpLessEqual, OpMatch, OpGreater, OpLess}
+
+// FieldKind is the type of a query field. It decides the operators a field
+// supports and how its values are parsed.
+type FieldKind int
+
+const (
+	// KindString fields match values with the * and ? wildcards
+	KindString FieldKind = iota
+
+	// KindEnum fields match one of a fixed set of values
+	KindEnum
+
+	// KindSeverity fields match or compare severities (low < medium < high < critical)
+	KindSeverity
+
+	// KindNumber fields match or compare numbers
+	KindNumber
+
+	// KindTime fields compare RFC 3339 times or dates (2006-01-02)
+	KindTime
+
+	// KindPath fields match a changed attribute path and the paths below it
+	// ("ingress" matches "ingress[0].cidr_blocks[1]"); with wildcards the whole
+	// path must match
+	KindPath
+
+	// KindTag fields match key=value (the value may use wildcards) or a tag key
+	KindTag
+)
+
+// QueryField describes a field queries may use
+type QueryField struct {
+	Kind FieldKind
+
+	// Values are the allowed values of an enum field
+	Values []string
+}
+
+// DriftQueryFields are the fields of drift event queries
+var DriftQueryFields = map[string]QueryField{
+	"id":            {Kind: KindString},
+	"resource_id":   {Kind: KindString},
+	"resource_type": {Kind: KindString},
+	"type": {Kind: KindEnum, Values: []string{
+		string(types.DriftCreated), string(types.DriftModified), string(types.DriftDeleted),
+	}},
+	"severity": {Kind: KindSeverity},
+	"status": {Kind: KindEnum, Values: []string{
+		string(types.DriftOpen), string(types.DriftAcknowledged), string(types.DriftResolved),
+	}},
+	"workspace": {Kind: KindString},
+	"user":      {Kind: KindString},
+	"changed":   {Kind: KindPath},
+	"tag":       {Kind: KindTag},
+	"timestamp": {Kind: KindTime},
+}
+
+// ImpactQueryFields are the fields of impact analysis queries. resource_id,
+// resource_type and tag match the analysed drift event.
+var ImpactQueryFields = map[string]QueryField{
+	"drift_event_id":     {Kind: KindString},
+	"resource_id":        {Kind: KindString},
+	"resource_type":      {Kind: KindString},
+	"tag":                {Kind: KindTag},
+	"severity":           {Kind: KindSeverity},
+	"blast_radius":       {Kind: KindNumber},
+	"affected_resources": {Kind: KindNumber},
+	"impact_score":       {Kind: KindNumber},
+	"analyzed_at":        {Kind: KindTime},
+}
+
+// Term is one condition of a query
+type Term struct {
+	Field  string
+	Kind   FieldKind
+	Op     Operator
+	Negate bool
+
+	// Values holds the values as written. Comparisons have a single value.
+	Values []string
+
+	// Numbers and Times hold the parsed values of number and time fields
+	Numbers []float64
+	Times   []time.Time
+}
+
+// ParseQuery parses a query for the given fields (DriftQueryFields or
+// ImpactQueryFields). An empty query returns nil, which matches everything.
+func ParseQuery(input string, fields map[string]QueryField) (*Query, error) {
+	tokens, err := splitQuery(input, unicode.IsSpace, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	query := &Query{}
+	for _, token := range tokens {
+		term, err := parseTerm(token, fields)
+		if err != nil {
+			return nil, err
+		}
+		query.Terms = append(query.Terms, term)
+	}
+	return query, nil
+}
+
+func parseTerm(token string, fields map[string]QueryField) (Term, error) {
+	var term Term
+	if strings.HasPrefix(token, "-") {
+		term.Negate = true
+		token = token[1:]
+	}
+
+	end := strings.IndexFunc(token, func(r rune) bool { return r != '_' && !unicode.IsLetter(r) })
+	if end <= 0 {
+		return term, fmt.Errorf("invalid query term %q: expected field:value", token)
+	}
+	term.Field = token[:end]
+	field, ok := fields[term.Field]
+	if !ok {
+		return term, fmt.Errorf("unknown query field %q", term.Field)
+	}
+	term.Kind = field.Kind
+
+	rest := token[end:]
+	for _, op := range operators {
+		if strings.HasPrefix(rest, string(op)) {
+			term.Op = op
+			rest = rest[len(op):]
+			break
+		}
+	}
+	if term.Op == "" {
+		return term, fmt.Errorf("invalid query term %q: expected an operator after %s", token, term.Field)
+	}
+
+	values, err := splitQuery(rest, func(r rune) bool { return r == ',' }, false)
+	if err != nil {
+		return term, err
+	}
+	if len(values) == 0 {
+		return term, fmt.Errorf("query term %q has no value", token)
+	}
+	term.Values = values
+
+	if err := term.parseValues(field); err != nil {
+		return term, fmt.Errorf("invalid query term %q: %w", token, err)
+	}
+	return term, nil
+}
+
+// parseValues checks the operator and values of a term against its field
+func (t *Term) parseValues(field QueryField) error {
+	comparable := field.Kind == KindSeverity || field.Kind == KindNumber || field.Kind == KindTime
+	if t.Op != OpMatch {
+		if !comparable {
+			return fmt.Errorf("%s only supports %s", t.Field, OpMatch)
+		}
+		if len(t.Values) > 1 {
+			return fmt.Errorf("%s takes a single value", t.Op)
+		}
+	}
+
+	for _, value := range t.Values {
+		switch field.Kind {
+		case KindEnum:
+			if !slices.Contains(field.Values, value) {
+				return fmt.Errorf("%s must be one of %s", t.Field, strings.Join(field.Values, ", "))
+			}
+		case KindSeverity:
+			if severity.Rank(types.Severity(value)) < 0 {
+				return fmt.Errorf("unknown severity %q", value)
+			}
+		case KindNumber:
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("%s is not a number", value)
+			}
+			t.Numbers = append(t.Numbers, n)
+		case KindTime:
+			if t.Op == OpMatch {
+				return fmt.Errorf("compare %s with >=, >, <= or <", t.Field)
+			}
+			ts, err := parseQueryTime(value)
+			if err != nil {
+				return err
+			}
+			t.Times = append(t.Times, ts)
+		case KindTag:
+			if key, _, _ := strings.Cut(value, "="); key == "" {
+				return fmt.Errorf("tag must be key=value or key")
+			}
+		}
+	}
+	return nil
+}
+
+// parseQueryTime parses an RFC 3339 time or a date (midnight UTC)
+func parseQueryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%s is not an RFC 3339 time or a date", value)
+}
+
+// splitQuery splits s at the separators outside double quotes. The quotes are
+// kept when the parts are split again.
+func splitQuery(s string, separator func(rune) bool, keepQuotes bool) ([]string, error) {
+	var parts []string
+	var current strings.Builder
+	quoted, pending := false, false
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			pending = true
+			if keepQuotes {
+				current.WriteRune(r)
+			}
+		case !quoted && separator(r):
+			if pending {
+				parts = append(parts, current.String())
+				current.Reset()
+				pending = false
+			}
+		default:
+			current.WriteRune(r)
+			pending = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in query %q", s)
+	}
+	if pending {
+		parts = append(parts, current.String())
+	}
+	return parts, nil
+}
+
+// Match reports whether a record matches every term. value returns the value
+// of a field: a string for string, enum and severity fields, a float64, a
+// time.Time, the changed paths as []string or the tags as map[string]string.
+// Backends without a query engine use it to evaluate queries in memory.
+func (q *Query) Match(value func(field string) interface{}) bool {
+	if q == nil {
+		return true
+	}
+	for _, term := range q.Terms {
+		if term.match(value(term.Field)) == term.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+// match reports whether a value matches the term, ignoring Negate
+func (t *Term) match(value interface{}) bool {
+	switch t.Kind {
+	case KindString, KindEnum:
+		s, _ := value.(string)
+		for _, pattern := range t.Values {
+			if matchGlob(pattern, s) {
+				return true
+			}
+		}
+		return false
+
+	case KindSeverity:
+		s, _ := value.(string)
+		rank := severity.Rank(types.Severity(s))
+		if t.Op == OpMatch {
+			for _, v := range t.Values {
+				if rank >= 0 && rank == severity.Rank(types.Severity(v)) {
+					return true
+				}
+			}
+			return false
+		}
+		return rank >= 0 && t.Op.compare(rank-severity.Rank(types.Severity(t.Values[0])))
+
+	case KindNumber:
+		n, _ := value.(float64)
+		if t.Op == OpMatch {
+			for _, v := range t.Numbers {
+				if n == v {
+					return true
+				}
+			}
+			return false
+		}
+		switch {
+		case n < t.Numbers[0]:
+			return t.Op.compare(-1)
+		case n > t.Numbers[0]:
+			return t.Op.compare(1)
+		}
+		return t.Op.compare(0)
+
+	case KindTime:
+		ts, _ := value.(time.Time)
+		return t.Op.compare(ts.Compare(t.Times[0]))
+
+	case KindPath:
+		paths, _ := value.([]string)
+		for _, path := range paths {
+			for _, pattern := range t.Values {
+				if MatchPath(pattern, path) {
+					return true
+				}
+			}
+		}
+		return false
+
+	case KindTag:
+		tags, _ := value.(map[string]string)
+		for _, v := range t.Values {
+			key, pattern, hasValue := strings.Cut(v, "=")
+			tag, ok := tags[key]
+			if ok && (!hasValue || matchGlob(pattern, tag)) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// compare applies a comparison operator to the result of comparing the field
+// with the term's value (-1, 0 or +1)
+func (op Operator) compare(cmp int) bool {
+	switch op {
+	case OpGreaterEqual:
+		return cmp >= 0
+	case OpGreater:
+		return cmp > 0
+	case OpLessEqual:
+		return cmp <= 0
+	case OpLess:
+		return cmp < 0
+	}
+	return cmp == 0
+}
+
+// HasWildcard reports whether a query value uses the * or ? wildcards
+func HasWildcard(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?")
+}
+
+// MatchPath reports whether a changed attribute path matches a path pattern:
+// the path itself or a path below it, or with wildcards the whole path
+func MatchPath(pattern, path string) bool {
+	if HasWildcard(pattern) {
+		return matchGlob(pattern, path)
+	}
+	if !strings.HasPrefix(path, pattern) {
+		return false
+	}
+	rest := path[len(pattern):]
+	return rest == "" || rest[0] == '.' || rest[0] == '['
+}
+
+// matchGlob matches s against a pattern where * matches any characters and
+// ? a single character. Unlike path.Match, [ and / are ordinary characters so
+// attribute paths like "ingress[0]" can be matched.
+func matchGlob(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	pi, si := 0, 0
+	star, mark := -1, 0
+
+	for si < len(str) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == str[si]):
+			pi++
+			si++
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, si
+			pi++
+		case star >= 0:
+			pi = star + 1
+			mark++
+			si = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
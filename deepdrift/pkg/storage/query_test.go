@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+func TestParseQuery(t *testing.T) {
+	query, err := ParseQuery(`severity>=high -status:resolved user:"Jane Doe" tag:env=prod,team`, DriftQueryFields)
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	if len(query.Terms) != 4 {
+		t.Fatalf("terms = %+v", query.Terms)
+	}
+
+	if term := query.Terms[0]; term.Field != "severity" || term.Op != OpGreaterEqual || term.Values[0] != "high" {
+		t.Errorf("severity term = %+v", term)
+	}
+	if term := query.Terms[1]; !term.Negate || term.Op != OpMatch || term.Values[0] != "resolved" {
+		t.Errorf("status term = %+v", term)
+	}
+	if term := query.Terms[2]; term.Values[0] != "Jane Doe" {
+		t.Errorf("user term = %+v", term)
+	}
+	if term := query.Terms[3]; len(term.Values) != 2 || term.Values[0] != "env=prod" || term.Values[1] != "team" {
+		t.Errorf("tag term = %+v", term)
+	}
+
+	if query, err := ParseQuery("  ", DriftQueryFields); err != nil || query != nil {
+		t.Errorf("ParseQuery(blank) = %+v, %v", query, err)
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, input := range []string{
+		"owner:alice",
+		"severity:urgent",
+		"status:closed",
+		"status>open",
+		"severity>=high,critical",
+		"timestamp:2024-01-01",
+		"timestamp>yesterday",
+		"blast_radius>=2",
+		"tag:=prod",
+		"resource_id",
+		`user:"Jane`,
+	} {
+		if _, err := ParseQuery(input, DriftQueryFields); err == nil {
+			t.Errorf("ParseQuery(%q) succeeded", input)
+		}
+	}
+
+	if _, err := ParseQuery("blast_radius>=two", ImpactQueryFields); err == nil {
+		t.Error("ParseQuery(blast_radius>=two) succeeded")
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"ingress", "ingress", true},
+		{"ingress", "ingress[0].cidr_blocks", true},
+		{"tags", "tags.Owner", true},
+		{"tag", "tags.Owner", false},
+		{"ingress[0]", "ingress[0].from_port", true},
+		{"ingress[0]", "ingress[1].from_port", false},
+		{"*.cidr_blocks*", "ingress[0].cidr_blocks[1]", true},
+		{"tags.*", "tags_all.Owner", false},
+		{"ingress?0?.from_port", "ingress[0].from_port", true},
+	}
+	for _, tt := range tests {
+		if got := MatchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSortOrderLess(t *testing.T) {
+	ts := time.Now()
+	older := SortKey{Severity: types.SeverityCritical, Time: ts.Add(-time.Hour), ID: "b"}
+	newer := SortKey{Severity: types.SeverityLow, Time: ts, ID: "a"}
+	tie := SortKey{Severity: types.SeverityLow, Time: ts, ID: "c"}
+
+	if !SortNewest.Less(newer, older) || SortNewest.Less(older, newer) {
+		t.Error("newest does not put the newer key first")
+	}
+	if !SortOldest.Less(older, newer) {
+		t.Error("oldest does not put the older key first")
+	}
+	if !SortSeverity.Less(older, newer) {
+		t.Error("severity does not put the critical key first")
+	}
+	if !SortNewest.Less(tie, newer) || !SortOldest.Less(newer, tie) {
+		t.Error("keys with the same time are not ordered by ID")
+	}
+}
+
+func TestCursor(t *testing.T) {
+	cursor := &Cursor{Sort: SortSeverity, SortKey: SortKey{
+		Severity: types.SeverityHigh,
+		Time:     time.Date(2024, 1, 2, 3, 4, 5, 6000000, time.UTC),
+		ID:       "drift-1",
+	}}
+
+	got, err := ParseCursor(cursor.String(), SortSeverity)
+	if err != nil {
+		t.Fatalf("ParseCursor: %v", err)
+	}
+	if got.Severity != cursor.Severity || !got.Time.Equal(cursor.Time) || got.ID != cursor.ID {
+		t.Errorf("cursor = %+v, want %+v", got, cursor)
+	}
+
+	if _, err := ParseCursor(cursor.String(), SortNewest); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("ParseCursor(other sort) error = %v, want ErrInvalidCursor", err)
+	}
+	for _, s := range []string{"not a cursor!", "bm90IGpzb24", "e30"} {
+		if _, err := ParseCursor(s, SortSeverity); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("ParseCursor(%q) error = %v, want ErrInvalidCursor", s, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Package storage defines the stores DeepDrift persists drift events and impact
+// analysis in. The clickhouse package implements them on ClickHouse, the
+// embedded package in a local file for single-binary deployments and tests.
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/drift"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// ErrImpactNotFound is returned when a drift event has no impact analysis
+var ErrImpactNotFound = errors.New("impact analysis not found")
+
+// Backend is a storage backend providing both stores
+type Backend interface {
+	// Name identifies the backend (e.g. "clickhouse", "embedded")
+	Name() string
+
+	DriftStore() DriftStore
+	ImpactStore() ImpactStore
+
+	// Health reports the backend status shown by the health endpoint
+	Health(ctx context.Context) (interface{}, error)
+
+	Close() error
+}
+
+// DriftStore persists drift events and their lifecycle status.
+//
+// A drift event is stored once; its status (open, acknowledged, resolved),
+// workspace and first/last seen times are versioned separately, and the latest
+// version is returned with the event. Events without a status version are open
+// and were first and last seen at their timestamp.
+type DriftStore interface {
+	// SaveDriftEvent saves a single drift event
+	SaveDriftEvent(ctx context.Context, event *types.DriftEvent) error
+
+	// SaveDriftEvents saves multiple drift events
+	SaveDriftEvents(ctx context.Context, events []*types.DriftEvent) error
+
+	// GetDriftEvent returns a drift event, or an error wrapping
+	// drift.ErrDriftNotFound
+	GetDriftEvent(ctx context.Context, id string) (*types.DriftEvent, error)
+
+	// ListDriftEvents lists drift events matching the filter (nil matches
+	// all), newest first
+	ListDriftEvents(ctx context.Context, filter *DriftEventFilter) ([]*types.DriftEvent, error)
+
+	// ListUnresolvedDriftEvents lists open and acknowledged drift events.
+	// When workspaces is not empty only events of those workspaces are returned.
+	ListUnresolvedDriftEvents(ctx context.Context, workspaces []string) ([]*types.DriftEvent, error)
+
+	// SaveDriftStatuses records the current lifecycle status of drift events
+	SaveDriftStatuses(ctx context.Context, events []*types.DriftEvent) error
+
+	// SaveSuppressions records drift events hidden by the suppression policy
+	SaveSuppressions(ctx context.Context, suppressions []drift.Suppression) error
+
+	// RecordDriftChanges persists the result of a detection cycle. New drifts
+	// are saved once (a drift that was resolved and reappears keeps its
+	// original event); every change gets a new status version.
+	RecordDriftChanges(ctx context.Context, changes drift.Changes) error
+
+	// UpdateDriftStatus changes the lifecycle status of a drift event (e.g.
+	// acknowledge, resolve) and returns the updated event
+	UpdateDriftStatus(ctx context.Context, id string, status types.DriftStatus, user string) (*types.DriftEvent, error)
+
+	// GetDriftStats returns statistics of the drift events of the last days
+	GetDriftStats(ctx context.Context, days int) (*DriftStats, error)
+
+	// DeleteOldDriftEvents deletes drift events older than the given days
+	DeleteOldDriftEvents(ctx context.Context, days int) (uint64, error)
+}
+
+// ImpactStore persists impact analysis results
+type ImpactStore interface {
+	// SaveImpactAnalysis saves an impact analysis result and its affected resources
+	SaveImpactAnalysis(ctx context.Context, result *types.ImpactAnalysisResult) error
+
+	// SaveImpactAnalysisBatch saves multiple impact analysis results
+	SaveImpactAnalysisBatch(ctx context.Context, results []*types.ImpactAnalysisResult) error
+
+	// GetImpactAnalysis returns the latest impact analysis of a drift event, or
+	// an error wrapping ErrImpactNotFound
+	GetImpactAnalysis(ctx context.Context, driftEventID string) (*types.ImpactAnalysisResult, error)
+
+	// ListImpactAnalysis lists impact analysis results matching the filter
+	// (nil matches all), newest first
+	ListImpactAnalysis(ctx context.Context, filter *ImpactAnalysisFilter) ([]*types.ImpactAnalysisResult, error)
+
+	// GetAffectedResources returns the resources affected by a drift event,
+	// strongest impact first
+	GetAffectedResources(ctx context.Context, driftEventID string) ([]types.AffectedResource, error)
+
+	// GetImpactStats returns statistics of the impact analysis of the last days
+	GetImpactStats(ctx context.Context, days int) (*ImpactStats, error)
+
+	// GetHighImpactDrifts returns the drift events of the last days with the
+	// highest impact score
+	GetHighImpactDrifts(ctx context.Context, days int, limit int) ([]*HighImpactDrift, error)
+}
+
+// DriftEventFilter defines filters for querying drift events
+type DriftEventFilter struct {
+	StartTime    time.Time
+	EndTime      time.Time
+	ResourceType string
+	DriftType    types.DriftType
+	Severity     types.Severity
+	UserIdentity string
+	Statuses     []types.DriftStatus
+	Workspaces   []string
+
+	// Query adds conditions in the query language (nil matches everything)
+	Query *Query
+
+	// Sort orders the events ("" is SortNewest)
+	Sort SortOrder
+
+	// After skips the events up to the cursor (the end of the previous page)
+	After *Cursor
+
+	Limit int
+}
+
+// DriftStats contains drift statistics
+type DriftStats struct {
+	TotalCount     uint64            `json:"total_count"`
+	BySeverity     map[string]uint64 `json:"by_severity"`
+	ByType         map[string]uint64 `json:"by_type"`
+	ByResourceType map[string]uint64 `json:"by_resource_type"`
+	ByStatus       map[string]uint64 `json:"by_status"`
+
+	// SuppressedCount is the number of distinct drifts hidden by the suppression policy
+	SuppressedCount    uint64            `json:"suppressed_count"`
+	SuppressedByReason map[string]uint64 `json:"suppressed_by_reason"`
+}
+
+// ImpactAnalysisFilter defines filters for querying impact analysis
+type ImpactAnalysisFilter struct {
+	StartTime            time.Time
+	EndTime              time.Time
+	Severity             types.Severity
+	MinBlastRadius       int
+	MinAffectedResources int
+
+	// Query adds conditions in the query language (nil matches everything)
+	Query *Query
+
+	// Sort orders the results ("" is SortNewest)
+	Sort SortOrder
+
+	// After skips the results up to the cursor (the end of the previous page)
+	After *Cursor
+
+	Limit int
+}
+
+// ImpactStats contains impact analysis statistics
+type ImpactStats struct {
+	TotalAnalyzed            uint64            `json:"total_analyzed"`
+	AvgBlastRadius           float64           `json:"avg_blast_radius"`
+	AvgAffectedResources     float64           `json:"avg_affected_resources"`
+	TopAffectedResourceTypes map[string]uint64 `json:"top_affected_resource_types"`
+}
+
+// HighImpactDrift represents a drift with high impact
+type HighImpactDrift struct {
+	ID                    string          `json:"id"`
+	ResourceID            string          `json:"resource_id"`
+	ResourceType          string          `json:"resource_type"`
+	DriftType             types.DriftType `json:"drift_type"`
+	Timestamp             time.Time       `json:"timestamp"`
+	AffectedResourceCount int             `json:"affected_resource_count"`
+	BlastRadius           int             `json:"blast_radius"`
+	ImpactScore           float64         `json:"impact_score"`
+	Severity              types.Severity  `json:"severity"`
+}
@@ -0,0 +1,730 @@
+// Package storagetest is a conformance test suite for storage backends.
+// Every backend runs the same suite from its own tests:
+//
+//	storagetest.Run(t, func(t *testing.T) storage.Backend { ... })
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/drift"
+	"github.com/higakikeita/airdig/deepdrift/pkg/storage"
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// Open returns an empty backend for a test; the backend is closed by the
+// caller (e.g. with t.Cleanup)
+type Open func(t *testing.T) storage.Backend
+
+// Run runs the conformance suite, opening a new backend for every test
+func Run(t *testing.T, open Open) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, b storage.Backend)
+	}{
+		{"DriftEventRoundTrip", testDriftEventRoundTrip},
+		{"ListDriftEvents", testListDriftEvents},
+		{"QueryDriftEvents", testQueryDriftEvents},
+		{"PaginateDriftEvents", testPaginateDriftEvents},
+		{"Lifecycle", testLifecycle},
+		{"DriftStats", testDriftStats},
+		{"ImpactAnalysis", testImpactAnalysis},
+		{"QueryImpactAnalysis", testQueryImpactAnalysis},
+		{"ImpactStats", testImpactStats},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+// now is the base time of the test data. Backends store timestamps in
+// milliseconds.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+func newEvent(id, resourceType string, driftType types.DriftType, severity types.Severity, timestamp time.Time) *types.DriftEvent {
+	return &types.DriftEvent{
+		ID:           id,
+		ResourceID:   "res-" + id,
+		ResourceType: resourceType,
+		Type:         driftType,
+		Timestamp:    timestamp,
+		Before:       map[string]interface{}{"instance_type": "t3.micro"},
+		After:        map[string]interface{}{"instance_type": "t3.large"},
+		Diff:         map[string]interface{}{"instance_type": map[string]interface{}{"before": "t3.micro", "after": "t3.large"}},
+		Severity:     severity,
+	}
+}
+
+func testDriftEventRoundTrip(t *testing.T, b storage.Backend) {
+	ctx := context.Background()
+	store := b.DriftStore()
+	ts := now()
+
+	event := newEvent("drift-1", "aws_instance", types.DriftModified, types.SeverityHigh, ts)
+	event.SeverityRules = []types.SeverityRule{{Name: "instance-type", Severity: types.SeverityHigh}}
+	event.RootCause = &types.RootCause{
+		CloudTrailEventID: "ct-1",
+		EventName:         "ModifyInstanceAttribute",
+		UserIdentity:      "alice",
+		UserARN:           "arn:aws:iam::123456789012:user/alice",
+		SourceIP:          "10.0.0.1",
+		Timestamp:         ts.Add(-time.Minute),
+	}
+	if err := store.SaveDriftEvent(ctx, event); err != nil {
+		t.Fatalf("SaveDriftEvent: %v", err)
+	}
+
+	got, err := store.GetDriftEvent(ctx, "drift-1")
+	if err != nil {
+		t.Fatalf("GetDriftEvent: %v", err)
+	}
+	if got.ResourceID != "res-drift-1" || got.ResourceType != "aws_instance" || got.Type != types.DriftModified || got.Severity != types.SeverityHigh {
+		t.Errorf("event = %+v", got)
+	}
+	if !got.Timestamp.Equal(ts) {
+		t.Errorf("timestamp = %v, want %v", got.Timestamp, ts)
+	}
+	if got.After["instance_type"] != "t3.large" || got.Before["instance_type"] != "t3.micro" || got.Diff["instance_type"] == nil {
+		t.Errorf("state = before %v, after %v, diff %v", got.Before, got.After, got.Diff)
+	}
+	if len(got.SeverityRules) != 1 || got.SeverityRules[0].Name != "instance-type" {
+		t.Errorf("severity rules = %+v", got.SeverityRules)
+	}
+	if got.RootCause == nil || got.RootCause.UserIdentity != "alice" || got.RootCause.EventName != "ModifyInstanceAttribute" {
+		t.Errorf("root cause = %+v", got.RootCause)
+	}
+
+	// Events without a status version are open since their timestamp
+	if got.Status != types.DriftOpen || !got.FirstSeen.Equal(ts) || !got.LastSeen.Equal(ts) {
+		t.Errorf("lifecycle = %s %v %v, want open at %v", got.Status, got.FirstSeen, got.LastSeen, ts)
+	}
+
+	// The stored event is not shared with the caller
+	got.Severity = types.SeverityLow
+	again, err := store.GetDriftEvent(ctx, "drift-1")
+	if err != nil {
+		t.Fatalf("GetDriftEvent: %v", err)
+	}
+	if again.Severity != types.SeverityHigh {
+		t.Errorf("severity = %s after changing a returned event", again.Severity)
+	}
+
+	if _, err := store.GetDriftEvent(ctx, "missing"); !errors.Is(err, drift.ErrDriftNotFound) {
+		t.Errorf("GetDriftEvent(missing) error = %v, want ErrDriftNotFound", err)
+	}
+}
+
+func testListDriftEvents(t *testing.T, b storage.Backend) {
+	ctx := context.Background()
+	store := b.DriftStore()
+	ts := now()
+
+	first := newEvent("drift-1", "aws_instance", types.DriftModified, types.SeverityHigh, ts.Add(-3*time.Hour))
+	first.RootCause = &types.RootCause{CloudTrailEventID: "ct-1", UserIdentity: "alice"}
+	second := newEvent("drift-2", "aws_security_group", types.DriftDeleted, types.SeverityCritical, ts.Add(-2*time.Hour))
+	third := newEvent("drift-3", "aws_instance", types.DriftCreated, types.SeverityLow, ts.Add(-time.Hour))
+	if err := store.SaveDriftEvents(ctx, []*types.DriftEvent{first, second, third}); err != nil {
+		t.Fatalf("SaveDriftEvents: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter *storage.DriftEventFilter
+		want   []string
+	}{
+		{"all", nil, []string{"drift-3", "drift-2", "drift-1"}},
+		{"limit", &storage.DriftEventFilter{Limit: 2}, []string{"drift-3", "drift-2"}},
+		{"resource type", &storage.DriftEventFilter{ResourceType: "aws_instance"}, []string{"drift-3", "drift-1"}},
+		{"drift type", &storage.DriftEventFilter{DriftType: types.DriftDeleted}, []string{"drift-2"}},
+		{"severity", &storage.DriftEventFilter{Severity: types.SeverityLow}, []string{"drift-3"}},
+		{"user", &storage.DriftEventFilter{UserIdentity: "alice"}, []string{"drift-1"}},
+		{"time range", &storage.DriftEventFilter{StartTime: ts.Add(-150 * time.Minute), EndTime: ts.Add(-30 * time.Minute)}, []string{"drift-3", "drift-2"}},
+		{"status", &storage.DriftEventFilter{Statuses: []types.DriftStatus{types.DriftResolved}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := store.ListDriftEvents(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("ListDriftEvents: %v", err)
+			}
+			assertIDs(t, eventIDs(events), tt.want)
+		})
+	}
+}
+
+func testQueryDriftEvents(t *testing.T, b storage.Backend) {
+	ctx := context.Background()
+	store := b.DriftStore()
+	ts := now()
+
+	web := newEvent("drift-1", "aws_instance", types.DriftModified, types.SeverityHigh, ts.Add(-3*time.Hour))
+	web.ResourceID = "i-web-1"
+	web.After["tags"] = map[string]interface{}{"env": "prod", "team": "web"}
+	web.RootCause = &types.RootCause{CloudTrailEventID: "ct-1", UserIdentity: "alice"}
+	db := newEvent("drift-2", "aws_security_group", types.DriftDeleted, types.SeverityCritical, ts.Add(-2*time.Hour))
+	db.ResourceID = "sg-db_1"
+	db.Before["tags"] = map[string]interface{}{"env": "staging"}
+	db.Diff = map[string]interface{}{"ingress[0].cidr_blocks[1]": map[string]interface{}{"before": "10.0.0.0/8", "after": nil}}
+	batch := newEvent("drift-3", "aws_instance", types.DriftCreated, types.SeverityLow, ts.Add(-time.Hour))
+	batch.ResourceID = "i-batch-1"
+	batch.Diff = map[string]interface{}{"tags.Owner": map[string]interface{}{"before": nil, "after": "batch"}}
+	if err := store.SaveDriftEvents(ctx, []*types.DriftEvent{web, db, batch}); err != nil {
+		t.Fatalf("SaveDriftEvents: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"resource_id:i-*", []string{"drift-3", "drift-1"}},
+		{"resource_id:i-web-?", []string{"drift-1"}},
+		// _ is not a wildcard
+		{"resource_id:sg-db_1", []string{"drift-2"}},
+		{"resource_id:sg-db_?", []string{"drift-2"}},
+		{"resource_id:sg-dbx1", nil},
+		{"resource_type:aws_security_group,aws_lb", []string{"drift-2"}},
+		{"-type:deleted", []string{"drift-3", "drift-1"}},
+		{"severity:high,critical", []string{"drift-2", "drift-1"}},
+		{"severity>=high", []string{"drift-2", "drift-1"}},
+		{"severity<medium", []string{"drift-3"}},
+		{"status:open resource_type:aws_instance", []string{"drift-3", "drift-1"}},
+		{"status:resolved", nil},
+		{"user:alice", []string{"drift-1"}},
+		{"changed:ingress", []string{"drift-2"}},
+		{"changed:ingress[0].cidr_blocks", []string{"drift-2"}},
+		{"changed:ingr", nil},
+		{"changed:*cidr*", []string{"drift-2"}},
+		{"changed:tags", []string{"drift-3"}},
+		{"tag:env", []string{"drift-2", "drift-1"}},
+		{"tag:env=prod", []string{"drift-1"}},
+		{"tag:env=stag*", []string{"drift-2"}},
+		{"-tag:env", []string{"drift-3"}},
+		{"timestamp>=" + ts.Add(-150*time.Minute).Format(time.RFC3339), []string{"drift-3", "drift-2"}},
+		{"id:drift-1,drift-3 severity<critical", []string{"drift-3", "drift-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := storage.ParseQuery(tt.query, storage.DriftQueryFields)
+			if err != nil {
+				t.Fatalf("ParseQuery: %v", err)
+			}
+			events, err := store.ListDriftEvents(ctx, &storage.DriftEventFilter{Query: query})
+			if err != nil {
+				t.Fatalf("ListDriftEvents: %v", err)
+			}
+			assertIDs(t, eventIDs(events), tt.want)
+		})
+	}
+}
+
+func testPaginateDriftEvents(t *testing.T, b storage.Backend) {
+	ctx := context.Background()
+	store := b.DriftStore()
+	ts := now()
+
+	// drift-2 and drift-3 have the same timestamp, so the ID decides their order
+	events := []*types.DriftEvent{
+		newEvent("drift-1", "aws_instance", types.DriftModified, types.SeverityHigh, ts.Add(-4*time.Hour)),
+		newEvent("drift-2", "aws_instance", types.DriftModified, types.SeverityLow, ts.Add(-3*time.Hour)),
+		newEvent("drift-3", "aws_instance", types.DriftModified, types.SeverityHigh, ts.Add(-3*time.Hour)),
+		newEvent("drift-4", "aws_instance", types.DriftModified, types.SeverityCritical, ts.Add(-2*time.Hour)),
+		newEvent("drift-5", "aws_instance", types.DriftModified, types.SeverityLow, ts.Add(-time.Hour)),
+	}
+	if err := store.SaveDriftEvents(ctx, events); err != nil {
+		t.Fatalf("SaveDriftEvents: %v", err)
+	}
+
+	tests := []struct {
+		sort storage.SortOrder
+		want []string
+	}{
+		{storage.SortNewest, []string{"drift-5", "drift-4", "drift-3", "drift-2", "drift-1"}},
+		{storage.SortOldest, []string{"drift-1", "drift-2", "drift-3", "drift-4", "drift-5"}},
+		{storage.SortSeverity, []string{"drift-4", "drift-3", "drift-1", "drift-5", "drift-2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.sort), func(t *testing.T) {
+			all, err := store.ListDriftEvents(ctx, &storage.DriftEventFilter{Sort: tt.sort})
+			if err != nil {
+				t.Fatalf("ListDriftEvents: %v", err)
+			}
+			assertIDs(t, eventIDs(all), tt.want)
+
+			var ids []string
+			filter := &storage.DriftEventFilter{Sort: tt.sort, Limit: 2}
+			for pages := 0; ; pages++ {
+				if pages > len(tt.want) {
+					t.Fatalf("pagination does not end: %v", ids)
+				}
+				page, err := store.ListDriftEvents(ctx, filter)
+				if err != nil {
+					t.Fatalf("ListDriftEvents: %v", err)
+				}
+				ids = append(ids, eventIDs(page)...)
+				if len(page) < filter.Limit {
+					break
+				}
+				filter.After = &storage.Cursor{Sort: tt.sort, SortKey: storage.DriftSortKey(page[len(page)-1])}
+			}
+			assertIDs(t, ids, tt.want)
+		})
+	}
+}
+
+func testLifecycle(t *testing.T, b storage.Backend) {
+	ctx := context.Background()
+	store := b.DriftStore()
+	ts := now()
+
+	prod := newEvent("drift-1", "aws_instance", types.DriftModified, types.SeverityHigh, ts.Add(-time.Hour))
+	prod.Workspace = "prod"
+	prod.Status = types.DriftOpen
+	prod.FirstSeen, prod.LastSeen = prod.Timestamp, prod.Timestamp
+	staging := newEvent("drift-2", "aws_instance", types.DriftModified, types.SeverityMedium, ts.Add(-time.Hour))
+	staging.Workspace = "staging"
+	staging.Status = types.DriftOpen
+	staging.FirstSeen, staging.LastSeen = staging.Timestamp, staging.Timestamp
+
+	if err := store.RecordDriftChanges(ctx, drift.Changes{Created: []*types.DriftEvent{prod, staging}}); err != nil {
+		t.Fatalf("RecordDriftChanges: %v", err)
+	}
+
+	unresolved, err := store.ListUnresolvedDriftEvents(ctx, []string{"prod"})
+	if err != nil {
+		t.Fatalf("ListUnresolvedDriftEvents: %v", err)
+	}
+	assertIDs(t, eventIDs(unresolved), []string{"drift-1"})
+	if unresolved[0].Workspace != "prod" || unresolved[0].Status != types.DriftOpen {
+		t.Errorf("unresolved = %s %s", unresolved[0].Workspace, unresolved[0].Status)
+	}
+
+	// The next cycle still sees the prod drift and no longer the staging one
+	prod.LastSeen = ts
+	staging.Status = types.DriftResolved
+	resolvedAt := ts
+	staging.ResolvedAt = &resolvedAt
+	if err := store.RecordDriftChanges(ctx, drift.Changes{Updated: []*types.DriftEvent{prod}, Resolved: []*types.DriftEvent{staging}}); err != nil {
+		t.Fatalf("RecordDriftChanges: %v", err)
+	}
+
+	got, err := store.GetDriftEvent(ctx, "drift-1")
+	if err != nil {
+		t.Fatalf("GetDriftEvent: %v", err)
+	}
+	if !got.LastSeen.Equal(ts) || !got.FirstSeen.Equal(prod.FirstSeen) {
+		t.Errorf("seen = %v - %v, want %v - %v", got.FirstSeen, got.LastSeen, prod.FirstSeen, ts)
+	}
+
+	unresolved, err = store.ListUnresolvedDriftEvents(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListUnresolvedDriftEvents: %v", err)
+	}
+	assertIDs(t, eventIDs(unresolved), []string{"drift-1"})
+
+	got, err = store.GetDriftEvent(ctx, "drift-2")
+	if err != nil {
+		t.Fatalf("GetDriftEvent: %v", err)
+	}
+	if got.Status != types.DriftResolved || got.ResolvedAt == nil || !got.ResolvedAt.Equal(ts) {
+		t.Errorf("drift-2 = %s resolved at %v", got.Status, got.ResolvedAt)
+	}
+
+	acknowledged, err := store.UpdateDriftStatus(ctx, "drift-1", types.DriftAcknowledged, "bob")
+	if err != nil {
+		t.Fatalf("UpdateDriftStatus: %v", err)
+	}
+	if acknowledged.Status != types.DriftAcknowledged || acknowledged.AcknowledgedBy != "bob" || acknowledged.AcknowledgedAt == nil {
+		t.Errorf("acknowledged = %+v", acknowledged)
+	}
+
+	got, err = store.GetDriftEvent(ctx, "drift-1")
+	if err != nil {
+		t.Fatalf("GetDriftEvent: %v", err)
+	}
+	if got.Status != types.DriftAcknowledged || got.AcknowledgedBy != "bob" || got.Workspace != "prod" {
+		t.Errorf("drift-1 = %s by %q in %q", got.Status, got.AcknowledgedBy, got.Workspace)
+	}
+
+	if _, err := store.UpdateDriftStatus(ctx, "drift-2", types.DriftAcknowledged, "bob"); err == nil {
+		t.Error("acknowledging a resolved drift succeeded")
+	}
+	if _, err := store.UpdateDriftStatus(ctx, "missing", types.DriftResolved, "bob"); !errors.Is(err, drift.ErrDriftNotFound) {
+		t.Errorf("UpdateDriftStatus(missing) error = %v, want ErrDriftNotFound", err)
+	}
+
+	// A resolved drift that reappears keeps its original event
+	reappeared := newEvent("drift-2", "aws_instance", types.DriftModified, types.SeverityCritical, ts)
+	reappeared.Workspace = "staging"
+	reappeared.Status = types.DriftOpen
+	reappeared.FirstSeen, reappeared.LastSeen = ts, ts
+	if err := store.RecordDriftChanges(ctx, drift.Changes{Created: []*types.DriftEvent{reappeared}}); err != nil {
+		t.Fatalf("RecordDriftChanges: %v", err)
+	}
+	got, err = store.GetDriftEvent(ctx, "drift-2")
+	if err != nil {
+		t.Fatalf("GetDriftEvent: %v", err)
+	}
+	if got.Status != types.DriftOpen || got.ResolvedAt != nil || got.Severity != types.SeverityMedium {
+		t.Errorf("reappeared drift = %s resolved at %v severity %s", got.Status, got.ResolvedAt, got.Severity)
+	}
+}
+
+func testDriftStats(t *testing.T, b storage.Backend) {
+	ctx := context.Background()
+	store := b.DriftStore()
+	ts := now()
+
+	events := []*types.DriftEvent{
+		newEvent("drift-1", "aws_instance", types.DriftModified, types.SeverityHigh, ts),
+		newEvent("drift-2", "aws_instance", types.DriftDeleted, types.SeverityHigh, ts),
+		newEvent("drift-3", "aws_s3_bucket", types.DriftModified, types.SeverityLow, ts),
+		// Outside of the window
+		newEvent("drift-4", "aws_iam_role", types.DriftModified, types.SeverityCritical, ts.AddDate(0, 0, -40)),
+	}
+	if err := store.SaveDriftEvents(ctx, events); err != nil {
+		t.Fatalf("SaveDriftEvents: %v", err)
+	}
+	if _, err := store.UpdateDriftStatus(ctx, "drift-3", types.DriftResolved, "alice"); err != nil {
+		t.Fatalf("UpdateDriftStatus: %v", err)
+	}
+
+	// drift-5 is suppressed in two cycles and counts once
+	suppressions := []drift.Suppression{
+		{EventID: "drift-5", ResourceID: "res-5", ResourceType: "aws_instance", Reason: drift.SuppressedTag, Rule: "tag:Team=platform", Timestamp: ts.Add(-time.Hour)},
+		{EventID: "drift-5", ResourceID: "res-5", ResourceType: "aws_instance", Reason: drift.SuppressedTag, Rule: "tag:Team=platform", Timestamp: ts},
+		{EventID: "drift-6", ResourceID: "res-6", ResourceType: "aws_instance", Reason: drift.SuppressedSilence, Rule: "maintenance", Owner: "bob", Timestamp: ts},
+	}
+	if err := store.SaveSuppressions(ctx, suppressions); err != nil {
+		t.Fatalf("SaveSuppressions: %v", err)
+	}
+
+	stats, err := store.GetDriftStats(ctx, 30)
+	if err != nil {
+		t.Fatalf("GetDriftStats: %v", err)
+	}
+	if stats.TotalCount != 3 {
+		t.Errorf("TotalCount = %d, want 3", stats.TotalCount)
+	}
+	assertCounts(t, "BySeverity", stats.BySeverity, map[string]uint64{"high": 2, "low": 1})
+	assertCounts(t, "ByType", stats.ByType, map[string]uint64{"modified": 2, "deleted": 1})
+	assertCounts(t, "ByResourceType", stats.ByResourceType, map[string]uint64{"aws_instance": 2, "aws_s3_bucket": 1})
+	assertCounts(t, "ByStatus", stats.ByStatus, map[string]uint64{"open": 2, "resolved": 1})
+	if stats.SuppressedCount != 2 {
+		t.Errorf("SuppressedCount = %d, want 2", stats.SuppressedCount)
+	}
+	assertCounts(t, "SuppressedByReason", stats.SuppressedByReason, map[string]uint64{"tag": 1, "silence": 1})
+}
+
+func newImpact(driftEventID string, blastRadius int, score float64, severity types.Severity, resources ...types.AffectedResource) *types.ImpactAnalysisResult {
+	return &types.ImpactAnalysisResult{
+		DriftEventID:          driftEventID,
+		AffectedResourceCount: len(resources),
+		AffectedResources:     resources,
+		BlastRadius:           blastRadius,
+		ImpactScore:           score,
+		Recommendations:       []string{"Review the change"},
+		Severity:              severity,
+	}
+}
+
+func affected(id, resourceType string, distance int, score float64) types.AffectedResource {
+	return types.AffectedResource{
+		ResourceID:        id,
+		ResourceType:      resourceType,
+		RelationType:      "network",
+		Distance:          distance,
+		ImpactDescription: "Connected to the drifted resource",
+		ImpactScore:       score,
+	}
+}
+
+func testImpactAnalysis(t *testing.T, b storage.Backend) {
+	ctx := context.Background()
+	store := b.ImpactStore()
+
+	first := newImpact("drift-1", 1, 0.5, types.SeverityMedium, affected("sg-1", "aws_security_group", 1, 0.5))
+	first.SeverityRules = []types.SeverityRule{{Name: "blast-radius", Severity: types.SeverityMedium}}
+	if err := store.SaveImpactAnalysis(ctx, first); err != nil {
+		t.Fatalf("SaveImpactAnalysis: %v", err)
+	}
+	// Analyses are ordered by when they were saved, in milliseconds
+	time.Sleep(5 * time.Millisecond)
+
+	second := newImpact("drift-1", 2, 1.3, types.SeverityHigh,
+		affected("lb-1", "aws_lb", 2, 0.3),
+		affected("i-1", "aws_instance", 1, 1.0),
+	)
+	other := newImpact("drift-2", 3, 2.0, types.SeverityCritical, affected("db-1", "aws_db_instance", 1, 2.0))
+	if err := store.SaveImpactAnalysisBatch(ctx, []*types.ImpactAnalysisResult{second, other}); err != nil {
+		t.Fatalf("SaveImpactAnalysisBatch: %v", err)
+	}
+
+	// The latest analysis of the drift is returned
+	got, err := store.GetImpactAnalysis(ctx, "drift-1")
+	if err != nil {
+		t.Fatalf("GetImpactAnalysis: %v", err)
+	}
+	if got.BlastRadius != 2 || got.ImpactScore != 1.3 || got.Severity != types.SeverityHigh || got.AffectedResourceCount != 2 {
+		t.Errorf("analysis = %+v", got)
+	}
+	if len(got.AffectedResources) != 2 || len(got.Recommendations) != 1 {
+		t.Errorf("affected = %+v, recommendations = %v", got.AffectedResources, got.Recommendations)
+	}
+
+	if _, err := store.GetImpactAnalysis(ctx, "missing"); !errors.Is(err, storage.ErrImpactNotFound) {
+		t.Errorf("GetImpactAnalysis(missing) error = %v, want ErrImpactNotFound", err)
+	}
+
+	listTests := []struct {
+		name   string
+		filter *storage.ImpactAnalysisFilter
+		want   []float64
+	}{
+		{"all", nil, []float64{0.5, 1.3, 2.0}},
+		{"severity", &storage.ImpactAnalysisFilter{Severity: types.SeverityMedium}, []float64{0.5}},
+		{"blast radius", &storage.ImpactAnalysisFilter{MinBlastRadius: 2}, []float64{1.3, 2.0}},
+		{"affected resources", &storage.ImpactAnalysisFilter{MinAffectedResources: 2}, []float64{1.3}},
+		// Newest first, so the first analysis is left out
+		{"limit", &storage.ImpactAnalysisFilter{Limit: 2}, []float64{1.3, 2.0}},
+		{"time range", &storage.ImpactAnalysisFilter{StartTime: time.Now().Add(time.Hour)}, nil},
+	}
+	for _, tt := range listTests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := store.ListImpactAnalysis(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("ListImpactAnalysis: %v", err)
+			}
+			scores := make([]float64, len(results))
+			for i, result := range results {
+				scores[i] = result.ImpactScore
+			}
+			sort.Float64s(scores)
+			if fmt.Sprint(scores) != fmt.Sprint(orEmpty(tt.want)) {
+				t.Errorf("scores = %v, want %v", scores, tt.want)
+			}
+		})
+	}
+
+	// Every analysis of the drift adds its resources, strongest impact first
+	resources, err := store.GetAffectedResources(ctx, "drift-1")
+	if err != nil {
+		t.Fatalf("GetAffectedResources: %v", err)
+	}
+	ids := make([]string, len(resources))
+	for i, resource := range resources {
+		ids[i] = resource.ResourceID
+	}
+	assertIDs(t, ids, []string{"i-1", "sg-1", "lb-1"})
+	if resources[0].ResourceType != "aws_instance" || resources[0].Distance != 1 || resources[0].RelationType != "network" {
+		t.Errorf("resource = %+v", resources[0])
+	}
+
+	if resources, err := store.GetAffectedResources(ctx, "missing"); err != nil || len(resources) != 0 {
+		t.Errorf("GetAffectedResources(missing) = %v, %v", resources, err)
+	}
+}
+
+func testQueryImpactAnalysis(t *testing.T, b storage.Backend) {
+	ctx := context.Background()
+	ts := now()
+
+	web := newEvent("drift-1", "aws_instance", types.DriftModified, types.SeverityHigh, ts.Add(-2*time.Hour))
+	web.After["tags"] = map[string]interface{}{"env": "prod"}
+	db := newEvent("drift-2", "aws_db_instance", types.DriftModified, types.SeverityCritical, ts.Add(-time.Hour))
+	if err := b.DriftStore().SaveDriftEvents(ctx, []*types.DriftEvent{web, db}); err != nil {
+		t.Fatalf("SaveDriftEvents: %v", err)
+	}
+
+	store := b.ImpactStore()
+	for _, result := range []*types.ImpactAnalysisResult{
+		newImpact("drift-1", 1, 0.5, types.SeverityMedium, affected("sg-1", "aws_security_group", 1, 0.5)),
+		newImpact("drift-2", 3, 2.0, types.SeverityCritical, affected("db-1", "aws_db_instance", 1, 2.0)),
+		newImpact("drift-3", 2, 1.0, types.SeverityHigh),
+	} {
+		if err := store.SaveImpactAnalysis(ctx, result); err != nil {
+			t.Fatalf("SaveImpactAnalysis: %v", err)
+		}
+		// Analyses are ordered by when they were saved, in milliseconds
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"drift_event_id:drift-*", []string{"drift-3", "drift-2", "drift-1"}},
+		{"severity>=high", []string{"drift-3", "drift-2"}},
+		{"blast_radius>=2 impact_score<2", []string{"drift-3"}},
+		{"affected_resources:1", []string{"drift-2", "drift-1"}},
+		// Resource fields match the analysed drift event
+		{"resource_type:aws_instance", []string{"drift-1"}},
+		{"tag:env=prod", []string{"drift-1"}},
+		{"-resource_type:aws_db_instance", []string{"drift-3", "drift-1"}},
+		{"analyzed_at>" + ts.Add(time.Hour).Format(time.RFC3339), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := storage.ParseQuery(tt.query, storage.ImpactQueryFields)
+			if err != nil {
+				t.Fatalf("ParseQuery: %v", err)
+			}
+			results, err := store.ListImpactAnalysis(ctx, &storage.ImpactAnalysisFilter{Query: query})
+			if err != nil {
+				t.Fatalf("ListImpactAnalysis: %v", err)
+			}
+			assertIDs(t, impactIDs(results), tt.want)
+		})
+	}
+
+	sortTests := []struct {
+		sort storage.SortOrder
+		want []string
+	}{
+		{storage.SortNewest, []string{"drift-3", "drift-2", "drift-1"}},
+		{storage.SortOldest, []string{"drift-1", "drift-2", "drift-3"}},
+		{storage.SortSeverity, []string{"drift-2", "drift-3", "drift-1"}},
+	}
+	for _, tt := range sortTests {
+		t.Run(string(tt.sort), func(t *testing.T) {
+			var ids []string
+			filter := &storage.ImpactAnalysisFilter{Sort: tt.sort, Limit: 1}
+			for range len(tt.want) + 1 {
+				page, err := store.ListImpactAnalysis(ctx, filter)
+				if err != nil {
+					t.Fatalf("ListImpactAnalysis: %v", err)
+				}
+				if len(page) == 0 {
+					break
+				}
+				if page[0].AnalyzedAt.IsZero() {
+					t.Fatalf("analysis of %s has no AnalyzedAt", page[0].DriftEventID)
+				}
+				ids = append(ids, impactIDs(page)...)
+				filter.After = &storage.Cursor{Sort: tt.sort, SortKey: storage.ImpactSortKey(page[0])}
+			}
+			assertIDs(t, ids, tt.want)
+		})
+	}
+}
+
+func testImpactStats(t *testing.T, b storage.Backend) {
+	ctx := context.Background()
+	ts := now()
+
+	events := []*types.DriftEvent{
+		newEvent("drift-1", "aws_instance", types.DriftModified, types.SeverityHigh, ts),
+		newEvent("drift-2", "aws_security_group", types.DriftModified, types.SeverityCritical, ts),
+		newEvent("drift-3", "aws_s3_bucket", types.DriftDeleted, types.SeverityLow, ts),
+		// Outside of the window
+		newEvent("drift-4", "aws_iam_role", types.DriftModified, types.SeverityCritical, ts.AddDate(0, 0, -40)),
+	}
+	if err := b.DriftStore().SaveDriftEvents(ctx, events); err != nil {
+		t.Fatalf("SaveDriftEvents: %v", err)
+	}
+
+	store := b.ImpactStore()
+	results := []*types.ImpactAnalysisResult{
+		newImpact("drift-1", 1, 1.0, types.SeverityHigh, affected("sg-1", "aws_security_group", 1, 1.0)),
+		newImpact("drift-2", 3, 2.5, types.SeverityCritical,
+			affected("i-1", "aws_instance", 1, 1.0),
+			affected("i-2", "aws_instance", 1, 1.0),
+			affected("lb-1", "aws_lb", 2, 0.5),
+		),
+		newImpact("drift-3", 2, 0.5, types.SeverityLow, affected("cf-1", "aws_cloudfront_distribution", 2, 0.5)),
+		newImpact("drift-4", 4, 9.0, types.SeverityCritical, affected("r-1", "aws_iam_policy", 1, 9.0)),
+	}
+	if err := store.SaveImpactAnalysisBatch(ctx, results); err != nil {
+		t.Fatalf("SaveImpactAnalysisBatch: %v", err)
+	}
+
+	stats, err := store.GetImpactStats(ctx, 30)
+	if err != nil {
+		t.Fatalf("GetImpactStats: %v", err)
+	}
+	// Analyses are dated when saved, so the one of the old drift counts too
+	if stats.TotalAnalyzed != 4 || stats.AvgBlastRadius != 2.5 || stats.AvgAffectedResources != 1.5 {
+		t.Errorf("stats = %+v", stats)
+	}
+	assertCounts(t, "TopAffectedResourceTypes", stats.TopAffectedResourceTypes, map[string]uint64{
+		"aws_instance": 2, "aws_security_group": 1, "aws_lb": 1, "aws_cloudfront_distribution": 1, "aws_iam_policy": 1,
+	})
+
+	// High impact drifts are the drifts of the window by impact score
+	drifts, err := store.GetHighImpactDrifts(ctx, 30, 2)
+	if err != nil {
+		t.Fatalf("GetHighImpactDrifts: %v", err)
+	}
+	ids := make([]string, len(drifts))
+	for i, d := range drifts {
+		ids[i] = d.ID
+	}
+	assertIDs(t, ids, []string{"drift-2", "drift-1"})
+	if d := drifts[0]; d.ResourceType != "aws_security_group" || d.BlastRadius != 3 || d.AffectedResourceCount != 3 || d.Severity != types.SeverityCritical || !d.Timestamp.Equal(ts) {
+		t.Errorf("high impact drift = %+v", d)
+	}
+
+	empty, err := b.ImpactStore().GetImpactStats(ctx, -1)
+	if err != nil {
+		t.Fatalf("GetImpactStats: %v", err)
+	}
+	if empty.TotalAnalyzed != 0 || empty.AvgBlastRadius != 0 {
+		t.Errorf("stats of no analyses = %+v", empty)
+	}
+}
+
+func eventIDs(events []*types.DriftEvent) []string {
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+func impactIDs(results []*types.ImpactAnalysisResult) []string {
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.DriftEventID
+	}
+	return ids
+}
+
+func assertIDs(t *testing.T, got, want []string) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(orEmpty(want)) {
+		t.Fatalf("ids = %v, want %v", got, want)
+	}
+}
+
+func assertCounts(t *testing.T, name string, got, want map[string]uint64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s = %v, want %v", name, got, want)
+		return
+	}
+	for key, count := range want {
+		if got[key] != count {
+			t.Errorf("%s = %v, want %v", name, got, want)
+			return
+		}
+	}
+}
+
+func orEmpty[T any](list []T) []T {
+	if list == nil {
+		return []T{}
+	}
+	return list
+}
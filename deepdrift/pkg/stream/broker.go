@@ -0,0 +1,221 @@
+package stream
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+const (
+	// DefaultHistory は再接続したクライアントに再送するために保持するイベント数
+	DefaultHistory = 1000
+
+	// DefaultBuffer は購読者ごとに溜められるイベント数
+	// 溢れた購読者は切断し、クライアントは最後のイベント ID から再開する
+	DefaultBuffer = 256
+)
+
+var (
+	// ErrSlowConsumer は購読者がイベントを受け取るのが遅く、切断したことを表す
+	ErrSlowConsumer = errors.New("subscriber fell behind the event stream")
+
+	// ErrClosed は Broker が閉じられたことを表す
+	ErrClosed = errors.New("event stream closed")
+)
+
+// Broker は保存されたイベントを購読者に配信するプロセス内のブローカー
+//
+// Publish は購読者を待たない。バッファが溢れた購読者は切断するため、
+// 遅いクライアントが drift の検出や保存を止めることはない。
+// 直近のイベントは保持しており、最後に受け取ったイベント ID から再開できる。
+type Broker struct {
+	mu          sync.Mutex
+	history     []Event // history[id % len(history)] に直近のイベントを保持する
+	count       int     // history に入っているイベント数
+	lastID      uint64
+	buffer      int
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// NewBroker は Broker を作成（0 以下の値は既定値を使う）
+func NewBroker(history, buffer int) *Broker {
+	if history <= 0 {
+		history = DefaultHistory
+	}
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+
+	return &Broker{
+		history: make([]Event, history),
+		// ID は起動時刻（マイクロ秒）から始めるため、再起動後の ID は以前のプロセスの ID より大きい
+		// 以前のプロセスの ID で再開したクライアントには取りこぼしがあることを通知する
+		lastID:      uint64(time.Now().UnixMicro()),
+		buffer:      buffer,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish はイベントに ID を付けて保持し、一致する購読者に配信する
+func (b *Broker) Publish(events ...Event) {
+	if b == nil || len(events) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, event := range events {
+		b.lastID++
+		event.ID = b.lastID
+		if event.Time.IsZero() {
+			event.Time = now
+		}
+
+		b.history[event.ID%uint64(len(b.history))] = event
+		if b.count < len(b.history) {
+			b.count++
+		}
+
+		for sub := range b.subscribers {
+			if !sub.filter.Match(event) {
+				continue
+			}
+			select {
+			case sub.events <- event:
+			default:
+				b.drop(sub, ErrSlowConsumer)
+			}
+		}
+	}
+}
+
+// PublishDrifts は drift イベントを配信する
+// TFDriftAdapter.WatchDrift の callback としてそのまま渡せる
+func (b *Broker) PublishDrifts(events []*types.DriftEvent) error {
+	published := make([]Event, 0, len(events))
+	for _, event := range events {
+		if event != nil {
+			published = append(published, DriftEvent(event))
+		}
+	}
+	b.Publish(published...)
+	return nil
+}
+
+// Subscribe は filter に一致するイベントを購読する
+//
+// resume が true の場合は lastEventID より後の保持しているイベントを先に配信する。
+// lastEventID のイベントがすでに保持されていない（または別のプロセスの ID の）場合は、
+// 取りこぼしがあることを EventReset で通知する。
+func (b *Broker) Subscribe(filter Filter, lastEventID uint64, resume bool) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	if resume {
+		replay = b.replay(filter, lastEventID)
+	}
+
+	sub := &Subscription{
+		broker: b,
+		filter: filter,
+		events: make(chan Event, max(b.buffer, len(replay))),
+	}
+	for _, event := range replay {
+		sub.events <- event
+	}
+
+	if b.closed {
+		sub.err = ErrClosed
+		close(sub.events)
+		return sub
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// replay は lastEventID より後の filter に一致するイベントを返す
+func (b *Broker) replay(filter Filter, lastEventID uint64) []Event {
+	oldest := b.lastID - uint64(b.count) + 1
+	if lastEventID > b.lastID || lastEventID+1 < oldest {
+		return []Event{{Type: EventReset, Time: time.Now().UTC()}}
+	}
+
+	var events []Event
+	for id := lastEventID + 1; id <= b.lastID; id++ {
+		event := b.history[id%uint64(len(b.history))]
+		if filter.Match(event) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Subscribers は現在の購読者数を返す
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// Close はすべての購読を終了する（以降の Publish は無視する）
+func (b *Broker) Close() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		b.drop(sub, ErrClosed)
+	}
+}
+
+// drop は購読を終了する（b.mu を保持して呼ぶ）
+func (b *Broker) drop(sub *Subscription, err error) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	sub.err = err
+	close(sub.events)
+}
+
+// Subscription は Broker の購読
+type Subscription struct {
+	broker *Broker
+	filter Filter
+	events chan Event
+	err    error // broker.mu で保護する
+}
+
+// Events はイベントを受け取るチャネルを返す
+// 購読が終了するとチャネルは閉じられ、Err で理由を確認できる
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Err は購読が終了した理由を返す（ErrSlowConsumer・ErrClosed、Close した場合は nil）
+func (s *Subscription) Err() error {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	return s.err
+}
+
+// Close は購読を終了する
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.drop(s, nil)
+}
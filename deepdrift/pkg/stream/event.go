@@ -0,0 +1,105 @@
+package stream
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+// EventType はイベントの種類
+type EventType string
+
+const (
+	// EventDrift は保存された drift イベント（新しい drift・状態の変更）
+	EventDrift EventType = "drift"
+
+	// EventImpact は保存されたインパクト分析の結果
+	EventImpact EventType = "impact"
+
+	// EventReset は再開するイベントがもう保持されていないことを表す
+	// クライアントは REST API で一覧を読み直す
+	EventReset EventType = "reset"
+)
+
+// Event は購読者に配信するイベント
+type Event struct {
+	// ID は Broker が付ける連番（再開に使う、EventReset は 0）
+	ID   uint64    `json:"id,omitempty"`
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+
+	// ResourceID・ResourceType・Severity はフィルタの対象
+	// インパクト分析の場合は対象の drift のリソース
+	ResourceID   string         `json:"resource_id,omitempty"`
+	ResourceType string         `json:"resource_type,omitempty"`
+	Severity     types.Severity `json:"severity,omitempty"`
+
+	Drift  *types.DriftEvent           `json:"drift,omitempty"`
+	Impact *types.ImpactAnalysisResult `json:"impact,omitempty"`
+}
+
+// DriftEvent は drift イベントの Event を作成
+// 配信中に元のイベントが更新されても影響しないようにコピーを持つ
+func DriftEvent(event *types.DriftEvent) Event {
+	copied := *event
+	return Event{
+		Type:         EventDrift,
+		ResourceID:   event.ResourceID,
+		ResourceType: event.ResourceType,
+		Severity:     event.Severity,
+		Drift:        &copied,
+	}
+}
+
+// ImpactEvent はインパクト分析の結果の Event を作成
+// drift は分析の対象の drift イベント（不明な場合は nil、リソースのフィルタには一致しない）
+func ImpactEvent(result *types.ImpactAnalysisResult, drift *types.DriftEvent) Event {
+	copied := *result
+	event := Event{
+		Type:     EventImpact,
+		Severity: result.Severity,
+		Impact:   &copied,
+	}
+	if drift != nil {
+		event.ResourceID = drift.ResourceID
+		event.ResourceType = drift.ResourceType
+	}
+	return event
+}
+
+// Filter は購読するイベントの条件（空の項目はすべてに一致する）
+type Filter struct {
+	// Types はイベントの種類（drift・impact）
+	Types []EventType
+
+	// Severities は深刻度（いずれかに一致）
+	Severities []types.Severity
+
+	// ResourceTypes はリソースタイプ（いずれかに一致）
+	ResourceTypes []string
+
+	// ResourceIDPrefix はリソース ID の接頭辞
+	ResourceIDPrefix string
+}
+
+// Match はイベントが条件に一致するかを判定（EventReset は常に一致する）
+func (f Filter) Match(event Event) bool {
+	if event.Type == EventReset {
+		return true
+	}
+	if len(f.Types) > 0 && !slices.Contains(f.Types, event.Type) {
+		return false
+	}
+	if len(f.Severities) > 0 && !slices.Contains(f.Severities, event.Severity) {
+		return false
+	}
+	if len(f.ResourceTypes) > 0 && !slices.Contains(f.ResourceTypes, event.ResourceType) {
+		return false
+	}
+	if f.ResourceIDPrefix != "" && !strings.HasPrefix(event.ResourceID, f.ResourceIDPrefix) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,460 @@
+package stream
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/types"
+)
+
+func driftEvent(id, resourceID, resourceType string, sev types.Severity) *types.DriftEvent {
+	return &types.DriftEvent{
+		ID:           id,
+		ResourceID:   resourceID,
+		ResourceType: resourceType,
+		Type:         types.DriftModified,
+		Severity:     sev,
+	}
+}
+
+// receive はイベントを1つ受け取る（届かない場合は失敗）
+func receive(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case event, ok := <-sub.Events():
+		if !ok {
+			t.Fatalf("subscription closed: %v", sub.Err())
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+	return Event{}
+}
+
+// assertNoEvent はイベントが届いていないことを確認する
+func assertNoEvent(t *testing.T, sub *Subscription) {
+	t.Helper()
+	select {
+	case event := <-sub.Events():
+		t.Fatalf("unexpected event: %+v", event)
+	default:
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	drift := DriftEvent(driftEvent("d1", "aws:ec2:i-123", "ec2", types.SeverityHigh))
+	impact := ImpactEvent(&types.ImpactAnalysisResult{DriftEventID: "d1", Severity: types.SeverityCritical}, nil)
+	reset := Event{Type: EventReset}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		event  Event
+		want   bool
+	}{
+		{"empty filter", Filter{}, drift, true},
+		{"type", Filter{Types: []EventType{EventImpact}}, drift, false},
+		{"severity", Filter{Severities: []types.Severity{types.SeverityHigh, types.SeverityCritical}}, drift, true},
+		{"other severity", Filter{Severities: []types.Severity{types.SeverityLow}}, drift, false},
+		{"resource type", Filter{ResourceTypes: []string{"ec2", "vpc"}}, drift, true},
+		{"other resource type", Filter{ResourceTypes: []string{"vpc"}}, drift, false},
+		{"resource id prefix", Filter{ResourceIDPrefix: "aws:ec2:"}, drift, true},
+		{"other resource id prefix", Filter{ResourceIDPrefix: "aws:s3:"}, drift, false},
+		{"impact without drift", Filter{ResourceIDPrefix: "aws:"}, impact, false},
+		{"impact severity", Filter{Severities: []types.Severity{types.SeverityCritical}}, impact, true},
+		{"reset always matches", Filter{Types: []EventType{EventImpact}, ResourceIDPrefix: "x"}, reset, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.event); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImpactEventUsesDriftResource(t *testing.T) {
+	event := ImpactEvent(&types.ImpactAnalysisResult{DriftEventID: "d1"}, driftEvent("d1", "aws:vpc:vpc-1", "vpc", types.SeverityLow))
+	if event.ResourceID != "aws:vpc:vpc-1" || event.ResourceType != "vpc" {
+		t.Errorf("resource = %q/%q, want the drift's resource", event.ResourceID, event.ResourceType)
+	}
+}
+
+func TestBrokerPublish(t *testing.T) {
+	b := NewBroker(10, 10)
+	defer b.Close()
+
+	all := b.Subscribe(Filter{}, 0, false)
+	critical := b.Subscribe(Filter{Severities: []types.Severity{types.SeverityCritical}}, 0, false)
+
+	b.PublishDrifts([]*types.DriftEvent{
+		driftEvent("d1", "aws:ec2:i-1", "ec2", types.SeverityLow),
+		driftEvent("d2", "aws:ec2:i-2", "ec2", types.SeverityCritical),
+	})
+
+	first, second := receive(t, all), receive(t, all)
+	if first.Drift.ID != "d1" || second.Drift.ID != "d2" {
+		t.Errorf("events = %s, %s, want d1, d2", first.Drift.ID, second.Drift.ID)
+	}
+	if second.ID != first.ID+1 {
+		t.Errorf("event IDs = %d, %d, want consecutive IDs", first.ID, second.ID)
+	}
+	if first.Time.IsZero() {
+		t.Error("event time not set")
+	}
+
+	if got := receive(t, critical); got.Drift.ID != "d2" {
+		t.Errorf("filtered event = %s, want d2", got.Drift.ID)
+	}
+	assertNoEvent(t, critical)
+}
+
+func TestBrokerCopiesEvents(t *testing.T) {
+	b := NewBroker(10, 10)
+	defer b.Close()
+	sub := b.Subscribe(Filter{}, 0, false)
+
+	event := driftEvent("d1", "aws:ec2:i-1", "ec2", types.SeverityLow)
+	b.PublishDrifts([]*types.DriftEvent{event})
+	event.Status = types.DriftResolved
+
+	if got := receive(t, sub); got.Drift.Status == types.DriftResolved {
+		t.Error("published event changed with the original")
+	}
+}
+
+func TestBrokerResume(t *testing.T) {
+	b := NewBroker(3, 10)
+	defer b.Close()
+
+	live := b.Subscribe(Filter{}, 0, false)
+	for _, id := range []string{"d1", "d2", "d3", "d4"} {
+		b.PublishDrifts([]*types.DriftEvent{driftEvent(id, "aws:ec2:"+id, "ec2", types.SeverityLow)})
+	}
+	var ids []uint64
+	for range 4 {
+		ids = append(ids, receive(t, live).ID)
+	}
+
+	t.Run("after a kept event", func(t *testing.T) {
+		sub := b.Subscribe(Filter{}, ids[1], true)
+		defer sub.Close()
+		if got := receive(t, sub); got.Drift.ID != "d3" {
+			t.Errorf("first replayed event = %s, want d3", got.Drift.ID)
+		}
+		if got := receive(t, sub); got.Drift.ID != "d4" {
+			t.Errorf("second replayed event = %s, want d4", got.Drift.ID)
+		}
+		assertNoEvent(t, sub)
+	})
+
+	t.Run("oldest kept event", func(t *testing.T) {
+		// d1 は保持していないが、その後のイベント（d2〜d4）はすべて保持している
+		sub := b.Subscribe(Filter{}, ids[0], true)
+		defer sub.Close()
+		if got := receive(t, sub); got.Type != EventDrift || got.Drift.ID != "d2" {
+			t.Errorf("first replayed event = %+v, want d2", got)
+		}
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		sub := b.Subscribe(Filter{ResourceIDPrefix: "aws:ec2:d4"}, ids[0], true)
+		defer sub.Close()
+		if got := receive(t, sub); got.Drift.ID != "d4" {
+			t.Errorf("replayed event = %s, want d4", got.Drift.ID)
+		}
+		assertNoEvent(t, sub)
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		sub := b.Subscribe(Filter{}, ids[3], true)
+		defer sub.Close()
+		assertNoEvent(t, sub)
+	})
+
+	t.Run("missed events", func(t *testing.T) {
+		sub := b.Subscribe(Filter{}, ids[0]-1, true)
+		defer sub.Close()
+		if got := receive(t, sub); got.Type != EventReset || got.ID != 0 {
+			t.Errorf("event = %+v, want a reset", got)
+		}
+		assertNoEvent(t, sub)
+	})
+
+	t.Run("other process", func(t *testing.T) {
+		sub := b.Subscribe(Filter{}, ids[3]+100, true)
+		defer sub.Close()
+		if got := receive(t, sub); got.Type != EventReset {
+			t.Errorf("event = %+v, want a reset", got)
+		}
+	})
+
+	t.Run("restart", func(t *testing.T) {
+		// 再起動したサーバーの ID は大きいため、以前のサーバーのクライアントには reset を送る
+		restarted := NewBroker(3, 10)
+		defer restarted.Close()
+		restarted.PublishDrifts([]*types.DriftEvent{driftEvent("d5", "aws:ec2:d5", "ec2", types.SeverityLow)})
+
+		sub := restarted.Subscribe(Filter{}, ids[3], true)
+		defer sub.Close()
+		if got := receive(t, sub); got.Type != EventReset {
+			t.Errorf("event = %+v, want a reset", got)
+		}
+	})
+}
+
+func TestBrokerSlowConsumer(t *testing.T) {
+	b := NewBroker(10, 2)
+	defer b.Close()
+
+	slow := b.Subscribe(Filter{}, 0, false)
+	fast := b.Subscribe(Filter{}, 0, false)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 5 {
+			b.PublishDrifts([]*types.DriftEvent{driftEvent(string(rune('a'+i)), "r", "ec2", types.SeverityLow)})
+			<-fast.Events()
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a slow subscriber")
+	}
+
+	// バッファに入っていたイベントはチャネルが閉じられる前に受け取れる
+	var received []Event
+	for event := range slow.Events() {
+		received = append(received, event)
+	}
+	if len(received) != 2 {
+		t.Errorf("received %d events, want the 2 buffered ones", len(received))
+	}
+	if !errors.Is(slow.Err(), ErrSlowConsumer) {
+		t.Errorf("Err() = %v, want ErrSlowConsumer", slow.Err())
+	}
+	if fast.Err() != nil {
+		t.Errorf("fast subscriber Err() = %v", fast.Err())
+	}
+	if got := b.Subscribers(); got != 1 {
+		t.Errorf("Subscribers() = %d, want 1", got)
+	}
+
+	// 遅いクライアントは最後に受け取ったイベントから再開する
+	resumed := b.Subscribe(Filter{}, received[1].ID, true)
+	defer resumed.Close()
+	for _, want := range []string{"c", "d", "e"} {
+		if got := receive(t, resumed); got.Drift.ID != want {
+			t.Errorf("resumed event = %s, want %s", got.Drift.ID, want)
+		}
+	}
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker(10, 10)
+	sub := b.Subscribe(Filter{}, 0, false)
+	closed := b.Subscribe(Filter{}, 0, false)
+	closed.Close()
+
+	b.Close()
+	if _, ok := <-sub.Events(); ok {
+		t.Fatal("subscription still open after Close")
+	}
+	if !errors.Is(sub.Err(), ErrClosed) {
+		t.Errorf("Err() = %v, want ErrClosed", sub.Err())
+	}
+	if closed.Err() != nil {
+		t.Errorf("Err() of a closed subscription = %v, want nil", closed.Err())
+	}
+
+	// Close した後の Publish と Subscribe はブロックしない
+	b.PublishDrifts([]*types.DriftEvent{driftEvent("d1", "r", "ec2", types.SeverityLow)})
+	late := b.Subscribe(Filter{}, 0, false)
+	if _, ok := <-late.Events(); ok || !errors.Is(late.Err(), ErrClosed) {
+		t.Errorf("subscription after Close: err = %v", late.Err())
+	}
+
+	var nilBroker *Broker
+	nilBroker.Publish(Event{Type: EventDrift})
+	nilBroker.Close()
+}
+
+// wsClient は WebSocket のテスト用クライアント
+type wsClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, url string) *wsClient {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	key := make([]byte, 16)
+	rand.Read(key)
+	encoded := base64.StdEncoding.EncodeToString(key)
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", encoded)
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != acceptKey(encoded) {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &wsClient{conn: conn, br: br}
+}
+
+// write はマスクしたフレームを送る
+func (c *wsClient) write(t *testing.T, opcode byte, payload []byte) {
+	t.Helper()
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// read はサーバーのフレームを1つ読む
+func (c *wsClient) read(t *testing.T) (byte, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	if head[1]&0x80 != 0 {
+		t.Fatal("server frame is masked")
+	}
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(c.br, ext[:])
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0f, payload
+}
+
+func TestWebSocket(t *testing.T) {
+	messages := make(chan interface{}, 1)
+	closeCode := make(chan int, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := UpgradeWebSocket(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for {
+			select {
+			case msg := <-messages:
+				if err := ws.WriteJSON(msg); err != nil {
+					t.Error(err)
+				}
+			case code := <-closeCode:
+				ws.Close(code, "bye")
+				return
+			case <-ws.Done():
+				ws.Close(CloseNormal, "")
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("messages", func(t *testing.T) {
+		c := dialWebSocket(t, srv.URL)
+
+		long := strings.Repeat("x", 300)
+		messages <- map[string]string{"value": long}
+		opcode, payload := c.read(t)
+		var got map[string]string
+		if err := json.Unmarshal(payload, &got); err != nil || opcode != opText || got["value"] != long {
+			t.Fatalf("message = %d %q", opcode, payload)
+		}
+
+		c.write(t, opPing, []byte("hi"))
+		if opcode, payload := c.read(t); opcode != opPong || string(payload) != "hi" {
+			t.Errorf("reply to ping = %d %q, want pong", opcode, payload)
+		}
+
+		closeCode <- CloseGoingAway
+		opcode, payload = c.read(t)
+		if opcode != opClose || binary.BigEndian.Uint16(payload) != CloseGoingAway || string(payload[2:]) != "bye" {
+			t.Errorf("close frame = %d %q", opcode, payload)
+		}
+	})
+
+	t.Run("client close", func(t *testing.T) {
+		c := dialWebSocket(t, srv.URL)
+		c.write(t, opClose, binary.BigEndian.AppendUint16(nil, CloseNormal))
+		opcode, payload := c.read(t)
+		if opcode != opClose || binary.BigEndian.Uint16(payload) != CloseNormal {
+			t.Errorf("reply to close = %d %q", opcode, payload)
+		}
+	})
+
+	t.Run("unmasked frame", func(t *testing.T) {
+		c := dialWebSocket(t, srv.URL)
+		c.conn.Write([]byte{0x80 | opText, 2, 'h', 'i'})
+		opcode, payload := c.read(t)
+		if opcode != opClose || binary.BigEndian.Uint16(payload) != CloseProtocolError {
+			t.Errorf("reply to unmasked frame = %d %q", opcode, payload)
+		}
+	})
+
+	t.Run("not an upgrade", func(t *testing.T) {
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,313 @@
+package stream
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket の close コード（RFC 6455 7.4.1）
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	CloseTooLarge      = 1009
+	CloseTryAgainLater = 1013
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// maxClientFrame はクライアントから受け取るフレームの最大サイズ
+	// クライアントからのメッセージは使わないため小さくしている
+	maxClientFrame = 64 << 10
+
+	// wsWriteTimeout は1フレームの書き込みを待つ時間
+	wsWriteTimeout = 10 * time.Second
+
+	// wsCloseTimeout は close フレームを送ってからクライアントの応答を待つ時間
+	wsCloseTimeout = time.Second
+)
+
+// IsWebSocketUpgrade は WebSocket へのアップグレード要求かを判定
+func IsWebSocketUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") && headerContains(r.Header, "Upgrade", "websocket")
+}
+
+// WebSocket はサーバーからイベントを送るための WebSocket 接続（RFC 6455）
+//
+// クライアントからのデータのメッセージは読み捨てる。ping には pong を返し、
+// close には close を返して接続を閉じる。
+type WebSocket struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	mu        sync.Mutex // 書き込みを直列化する
+	closeSent bool
+
+	done chan struct{} // 読み込みが終わると閉じる
+}
+
+// UpgradeWebSocket は要求を WebSocket にアップグレードする
+// 要求が不正な場合は応答を書かずにエラーを返す
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request) (*WebSocket, error) {
+	if r.Method != http.MethodGet {
+		return nil, fmt.Errorf("websocket upgrade requires GET")
+	}
+	if !IsWebSocketUpgrade(r) {
+		return nil, fmt.Errorf("missing websocket upgrade headers")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, fmt.Errorf("unsupported websocket version %q", r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, fmt.Errorf("invalid Sec-WebSocket-Key")
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to take over the connection: %w", err)
+	}
+
+	// サーバーのタイムアウトは接続を引き継いだ後も残るため解除する
+	conn.SetDeadline(time.Time{})
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := rw.WriteString(response); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ws := &WebSocket{
+		conn: conn,
+		br:   rw.Reader,
+		done: make(chan struct{}),
+	}
+	go ws.readLoop()
+	return ws, nil
+}
+
+// acceptKey は Sec-WebSocket-Accept の値を計算する
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Done はクライアントが切断したか close を送ると閉じるチャネルを返す
+func (ws *WebSocket) Done() <-chan struct{} {
+	return ws.done
+}
+
+// WriteJSON は v を JSON のテキストメッセージとして送る
+func (ws *WebSocket) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.writeFrame(opText, data)
+}
+
+// Ping は ping を送る（接続を維持し、切断を検出するため）
+func (ws *WebSocket) Ping() error {
+	return ws.writeFrame(opPing, nil)
+}
+
+// Close は close フレームを送り、クライアントの応答を少し待ってから接続を閉じる
+func (ws *WebSocket) Close(code int, reason string) error {
+	err := ws.writeClose(code, reason)
+	if errors.Is(err, net.ErrClosed) {
+		// クライアントの close に応答済み
+		err = nil
+	}
+
+	select {
+	case <-ws.done:
+	case <-time.After(wsCloseTimeout):
+	}
+
+	if closeErr := ws.conn.Close(); err == nil && !errors.Is(closeErr, net.ErrClosed) {
+		err = closeErr
+	}
+	return err
+}
+
+func (ws *WebSocket) writeClose(code int, reason string) error {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	payload := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	copy(payload[2:], reason)
+	return ws.writeFrame(opClose, payload)
+}
+
+// writeFrame は1フレームを送る（サーバーのフレームはマスクしない）
+func (ws *WebSocket) writeFrame(opcode byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.closeSent {
+		return net.ErrClosed
+	}
+	if opcode == opClose {
+		ws.closeSent = true
+	}
+
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	ws.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := ws.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readLoop はクライアントのフレームを読み、制御フレームに応答する
+func (ws *WebSocket) readLoop() {
+	defer close(ws.done)
+
+	for {
+		opcode, payload, err := ws.readFrame()
+		if err != nil {
+			var protoErr *protocolError
+			if errors.As(err, &protoErr) {
+				ws.writeClose(protoErr.code, protoErr.reason)
+			}
+			ws.conn.Close()
+			return
+		}
+
+		switch opcode {
+		case opPing:
+			ws.writeFrame(opPong, payload)
+		case opClose:
+			// クライアントの close にはそのコードを返して接続を閉じる
+			code := CloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			ws.writeClose(code, "")
+			ws.conn.Close()
+			return
+		}
+	}
+}
+
+// protocolError はクライアントが RFC 6455 に従わないフレームを送ったことを表す
+type protocolError struct {
+	code   int
+	reason string
+}
+
+func (e *protocolError) Error() string {
+	return fmt.Sprintf("websocket protocol error %d: %s", e.code, e.reason)
+}
+
+// readFrame はクライアントのフレームを1つ読む
+func (ws *WebSocket) readFrame() (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(ws.br, head[:]); err != nil {
+		return 0, nil, err
+	}
+
+	fin := head[0]&0x80 != 0
+	opcode := head[0] & 0x0f
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7f)
+
+	if head[0]&0x70 != 0 {
+		return 0, nil, &protocolError{CloseProtocolError, "reserved bits set"}
+	}
+	if !masked {
+		return 0, nil, &protocolError{CloseProtocolError, "client frames must be masked"}
+	}
+	switch opcode {
+	case opContinuation, opText, opBinary:
+	case opClose, opPing, opPong:
+		if !fin || length > 125 {
+			return 0, nil, &protocolError{CloseProtocolError, "invalid control frame"}
+		}
+	default:
+		return 0, nil, &protocolError{CloseProtocolError, "unknown opcode"}
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxClientFrame {
+		return 0, nil, &protocolError{CloseTooLarge, "frame too large"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.br, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.br, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// headerContains はカンマ区切りのヘッダーに token が含まれるかを判定（大文字小文字を区別しない）
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
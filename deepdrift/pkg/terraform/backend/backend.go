@@ -0,0 +1,173 @@
+// Package backend は Terraform state をローカルファイル・S3・HTTP・Terraform Cloud から取得する
+package backend
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/higakikeita/airdig/deepdrift/pkg/terraform"
+)
+
+// ErrNoState は取得元に state がまだ存在しないことを表す（apply 前のワークスペースなど）
+var ErrNoState = errors.New("terraform state not found")
+
+// Source は Terraform state の取得元
+type Source interface {
+	// String は取得元の説明（ログ用、認証情報は含めない）
+	String() string
+
+	// Read は最新の state を JSON のまま取得
+	Read(ctx context.Context) ([]byte, error)
+}
+
+// Load は取得元から state を読み込んでパース
+func Load(ctx context.Context, src Source) (*terraform.State, error) {
+	data, err := src.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := terraform.ParseState(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", src, err)
+	}
+	return state, nil
+}
+
+// Workspace は監視対象のワークスペース
+type Workspace struct {
+	// Name はワークスペースの名前（drift イベントに記録される）
+	Name string `yaml:"name" json:"name"`
+
+	// Backend は取得元の種類 (local, s3, http, remote)
+	Backend string `yaml:"backend" json:"backend"`
+
+	// Config は Terraform の backend ブロックと同じキーの設定
+	Config map[string]string `yaml:"config" json:"config,omitempty"`
+}
+
+// WorkspaceFile はワークスペース設定ファイルの形式
+//
+//	workspaces:
+//	  - name: network
+//	    backend: s3
+//	    config:
+//	      bucket: acme-tfstate
+//	      key: network/terraform.tfstate
+//	      region: us-east-1
+type WorkspaceFile struct {
+	Workspaces []Workspace `yaml:"workspaces"`
+}
+
+// LoadWorkspaces はワークスペース設定ファイル (YAML) を読み込む
+func LoadWorkspaces(path string) ([]Workspace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace file: %w", err)
+	}
+	return ParseWorkspaces(data)
+}
+
+// ParseWorkspaces はワークスペース設定 (YAML) をパース
+// 設定値の ${VAR} は環境変数で置き換える（トークンなどをファイルに書かないため）
+func ParseWorkspaces(data []byte) ([]Workspace, error) {
+	var file WorkspaceFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse workspace file: %w", err)
+	}
+
+	seen := make(map[string]bool, len(file.Workspaces))
+	for i, ws := range file.Workspaces {
+		if ws.Name == "" {
+			return nil, fmt.Errorf("workspace %d: name is required", i)
+		}
+		if seen[ws.Name] {
+			return nil, fmt.Errorf("workspace %s: duplicate name", ws.Name)
+		}
+		seen[ws.Name] = true
+
+		for key, value := range ws.Config {
+			ws.Config[key] = os.ExpandEnv(value)
+		}
+		if _, err := NewSource(ws); err != nil {
+			return nil, fmt.Errorf("workspace %s: %w", ws.Name, err)
+		}
+	}
+
+	return file.Workspaces, nil
+}
+
+// NewSource はワークスペースの設定から取得元を作成
+func NewSource(ws Workspace) (Source, error) {
+	switch ws.Backend {
+	case "", "local":
+		return NewLocalSource(ws.Config["path"])
+	case "s3":
+		return NewS3Source(ws.Config)
+	case "http":
+		return NewHTTPSource(ws.Config)
+	case "remote", "cloud":
+		return NewRemoteSource(ws.Config)
+	default:
+		return nil, fmt.Errorf("unknown backend: %s (available: local, s3, http, remote)", ws.Backend)
+	}
+}
+
+// ParseWorkspace はコマンドラインの --state の値からワークスペースを作成
+//
+//	terraform.tfstate                         ローカルファイル
+//	s3://bucket/path/terraform.tfstate        S3 (?region=...&workspace=...)
+//	https://example.com/state/network         HTTP backend
+//	remote://app.terraform.io/org/workspace   Terraform Cloud / Enterprise
+func ParseWorkspace(value string) (Workspace, error) {
+	scheme, _, found := strings.Cut(value, "://")
+	if !found {
+		return Workspace{Name: value, Backend: "local", Config: map[string]string{"path": value}}, nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return Workspace{}, fmt.Errorf("invalid state location %q: %w", value, err)
+	}
+
+	config := make(map[string]string)
+	for key := range u.Query() {
+		config[key] = u.Query().Get(key)
+	}
+
+	ws := Workspace{Name: value, Config: config}
+	switch scheme {
+	case "s3":
+		ws.Backend = "s3"
+		config["bucket"] = u.Host
+		config["key"] = strings.TrimPrefix(u.Path, "/")
+
+	case "http", "https":
+		// クエリも state のアドレスの一部として扱う
+		ws.Backend = "http"
+		ws.Config = map[string]string{"address": value}
+
+	case "remote", "cloud":
+		ws.Backend = "remote"
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) != 2 {
+			return Workspace{}, fmt.Errorf("invalid state location %q: expected remote://<hostname>/<organization>/<workspace>", value)
+		}
+		config["hostname"] = u.Host
+		config["organization"] = parts[0]
+		config["workspace"] = parts[1]
+		ws.Name = parts[1]
+
+	default:
+		return Workspace{}, fmt.Errorf("unsupported state location scheme: %s", scheme)
+	}
+
+	return ws, nil
+}
@@ -0,0 +1,292 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const testState = `{
+  "version": 4,
+  "serial": 3,
+  "lineage": "abc",
+  "resources": [
+    {"mode": "managed", "type": "aws_vpc", "name": "main",
+     "instances": [{"attributes": {"id": "vpc-1"}}]}
+  ]
+}`
+
+// fsS3 はバケットをディレクトリとして扱う S3 のフェイク
+type fsS3 struct {
+	root string
+}
+
+func (f *fsS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	file, err := os.Open(filepath.Join(f.root, *params.Bucket, filepath.FromSlash(*params.Key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, &s3types.NoSuchKey{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s3.GetObjectOutput{Body: file}, nil
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocalSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terraform.tfstate")
+	writeFile(t, path, testState)
+
+	src, err := NewSource(Workspace{Backend: "local", Config: map[string]string{"path": path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := Load(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if state.Serial != 3 || len(state.Resources) != 1 {
+		t.Errorf("Unexpected state: %+v", state)
+	}
+
+	missing, _ := NewLocalSource(filepath.Join(t.TempDir(), "missing.tfstate"))
+	if _, err := Load(context.Background(), missing); !errors.Is(err, ErrNoState) {
+		t.Errorf("Expected ErrNoState, got %v", err)
+	}
+}
+
+func TestS3Source(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "tfstate", "network", "terraform.tfstate"), testState)
+	writeFile(t, filepath.Join(root, "tfstate", "env:", "staging", "network", "terraform.tfstate"), strings.Replace(testState, `"serial": 3`, `"serial": 7`, 1))
+
+	tests := []struct {
+		name   string
+		config map[string]string
+		serial int64
+	}{
+		{"default workspace", map[string]string{"bucket": "tfstate", "key": "network/terraform.tfstate"}, 3},
+		{"named workspace", map[string]string{"bucket": "tfstate", "key": "network/terraform.tfstate", "workspace": "staging"}, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := NewS3Source(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src.client = &fsS3{root: root}
+
+			state, err := Load(context.Background(), src)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if state.Serial != tt.serial {
+				t.Errorf("Expected serial %d, got %d", tt.serial, state.Serial)
+			}
+		})
+	}
+
+	src, _ := NewS3Source(map[string]string{"bucket": "tfstate", "key": "missing.tfstate"})
+	src.client = &fsS3{root: root}
+	if _, err := src.Read(context.Background()); !errors.Is(err, ErrNoState) {
+		t.Errorf("Expected ErrNoState, got %v", err)
+	}
+
+	if _, err := NewS3Source(map[string]string{"bucket": "tfstate"}); err == nil {
+		t.Error("Expected error without key")
+	}
+}
+
+func TestHTTPSource(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "deepdrift" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/state/network":
+			io.WriteString(w, testState)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	config := map[string]string{"address": ts.URL + "/state/network", "username": "deepdrift", "password": "secret"}
+	src, err := NewHTTPSource(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := Load(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if state.Lineage != "abc" {
+		t.Errorf("Unexpected lineage: %s", state.Lineage)
+	}
+
+	config["address"] = ts.URL + "/state/unknown"
+	src, _ = NewHTTPSource(config)
+	if _, err := src.Read(context.Background()); !errors.Is(err, ErrNoState) {
+		t.Errorf("Expected ErrNoState, got %v", err)
+	}
+
+	config["address"] = ts.URL + "/state/network"
+	config["password"] = "wrong"
+	src, _ = NewHTTPSource(config)
+	if _, err := src.Read(context.Background()); err == nil || errors.Is(err, ErrNoState) {
+		t.Errorf("Expected authentication error, got %v", err)
+	}
+}
+
+func TestRemoteSource(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v2/organizations/acme/workspaces/network-prod":
+			io.WriteString(w, `{"data": {"id": "ws-123", "type": "workspaces"}}`)
+		case "/api/v2/organizations/acme/workspaces/empty":
+			io.WriteString(w, `{"data": {"id": "ws-456", "type": "workspaces"}}`)
+		case "/api/v2/workspaces/ws-123/current-state-version":
+			io.WriteString(w, `{"data": {"attributes": {"hosted-state-download-url": "`+ts.URL+`/download/sv-1"}}}`)
+		case "/download/sv-1":
+			io.WriteString(w, testState)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	t.Setenv("TF_TOKEN_tfe_example_com", "test-token")
+
+	ws, err := ParseWorkspace("remote://tfe.example.com/acme/network-prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.Config["address"] = ts.URL
+	src, err := NewSource(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := Load(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(state.Resources) != 1 {
+		t.Errorf("Unexpected resources: %+v", state.Resources)
+	}
+
+	// apply 前のワークスペースには current state version がない
+	src, _ = NewRemoteSource(map[string]string{"hostname": "tfe.example.com", "address": ts.URL, "organization": "acme", "workspace": "empty"})
+	if _, err := src.Read(context.Background()); !errors.Is(err, ErrNoState) {
+		t.Errorf("Expected ErrNoState, got %v", err)
+	}
+
+	src, _ = NewRemoteSource(map[string]string{"hostname": "tfe.example.com", "address": ts.URL, "organization": "acme", "workspace": "unknown"})
+	if _, err := src.Read(context.Background()); err == nil || errors.Is(err, ErrNoState) {
+		t.Errorf("Expected workspace error, got %v", err)
+	}
+}
+
+func TestParseWorkspace(t *testing.T) {
+	tests := []struct {
+		value   string
+		backend string
+		config  map[string]string
+	}{
+		{"terraform.tfstate", "local", map[string]string{"path": "terraform.tfstate"}},
+		{"s3://tfstate/network/terraform.tfstate?region=us-west-2", "s3",
+			map[string]string{"bucket": "tfstate", "key": "network/terraform.tfstate", "region": "us-west-2"}},
+		{"https://state.example.com/network?env=prod", "http", map[string]string{"address": "https://state.example.com/network?env=prod"}},
+		{"remote://app.terraform.io/acme/network", "remote",
+			map[string]string{"hostname": "app.terraform.io", "organization": "acme", "workspace": "network"}},
+	}
+	for _, tt := range tests {
+		ws, err := ParseWorkspace(tt.value)
+		if err != nil {
+			t.Errorf("ParseWorkspace(%s) error = %v", tt.value, err)
+			continue
+		}
+		if ws.Backend != tt.backend || len(ws.Config) != len(tt.config) {
+			t.Errorf("ParseWorkspace(%s) = %+v", tt.value, ws)
+			continue
+		}
+		for key, want := range tt.config {
+			if ws.Config[key] != want {
+				t.Errorf("ParseWorkspace(%s): %s = %s, want %s", tt.value, key, ws.Config[key], want)
+			}
+		}
+	}
+
+	for _, value := range []string{"remote://app.terraform.io/acme", "gcs://bucket/key"} {
+		if _, err := ParseWorkspace(value); err == nil {
+			t.Errorf("Expected error for %s", value)
+		}
+	}
+}
+
+func TestParseWorkspaces(t *testing.T) {
+	t.Setenv("TFC_TOKEN", "from-env")
+
+	workspaces, err := ParseWorkspaces([]byte(`
+workspaces:
+  - name: network
+    backend: s3
+    config:
+      bucket: tfstate
+      key: network/terraform.tfstate
+      region: us-east-1
+  - name: app
+    backend: remote
+    config:
+      organization: acme
+      workspace: app-prod
+      token: ${TFC_TOKEN}
+  - name: local
+    config:
+      path: terraform.tfstate
+`))
+	if err != nil {
+		t.Fatalf("ParseWorkspaces() error = %v", err)
+	}
+	if len(workspaces) != 3 {
+		t.Fatalf("Expected 3 workspaces, got %d", len(workspaces))
+	}
+	if workspaces[1].Config["token"] != "from-env" {
+		t.Errorf("Expected token from environment, got %q", workspaces[1].Config["token"])
+	}
+
+	for _, data := range []string{
+		"workspaces:\n  - backend: local\n    config: {path: a}\n",
+		"workspaces:\n  - name: a\n    backend: s3\n    config: {bucket: b}\n",
+		"workspaces:\n  - name: a\n    backend: gcs\n",
+		"workspaces:\n  - name: a\n    config: {path: a}\n  - name: a\n    config: {path: b}\n",
+	} {
+		if _, err := ParseWorkspaces([]byte(data)); err == nil {
+			t.Errorf("Expected error for %q", data)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// LocalSource はローカルファイルの state
+type LocalSource struct {
+	path string
+}
+
+// NewLocalSource は新しい LocalSource を作成
+func NewLocalSource(path string) (*LocalSource, error) {
+	if path == "" {
+		return nil, fmt.Errorf("local backend: path is required")
+	}
+	return &LocalSource{path: path}, nil
+}
+
+// String は取得元の説明を返す
+func (s *LocalSource) String() string {
+	return s.path
+}
+
+// Path は state ファイルのパスを返す
+func (s *LocalSource) Path() string {
+	return s.path
+}
+
+// Read は state ファイルを読み込む
+func (s *LocalSource) Read(ctx context.Context) ([]byte, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", s.path, ErrNoState)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	return data, nil
+}
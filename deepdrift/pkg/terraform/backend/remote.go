@@ -0,0 +1,163 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// defaultRemoteHostname は Terraform Cloud のホスト名
+const defaultRemoteHostname = "app.terraform.io"
+
+// RemoteSource は Terraform Cloud / Terraform Enterprise のワークスペースの state
+type RemoteSource struct {
+	baseURL      string
+	organization string
+	workspace    string
+	token        string
+	client       *http.Client
+
+	mu          sync.Mutex
+	workspaceID string
+}
+
+// NewRemoteSource は remote backend の設定 (hostname, organization, workspace, token) から RemoteSource を作成
+//
+// token が未設定の場合は Terraform CLI と同じく TF_TOKEN_<hostname> 環境変数、次に TFE_TOKEN を使う。
+// address を指定すると https://<hostname> の代わりにその URL に接続する。
+func NewRemoteSource(cfg map[string]string) (*RemoteSource, error) {
+	if cfg["organization"] == "" || cfg["workspace"] == "" {
+		return nil, fmt.Errorf("remote backend: organization and workspace are required")
+	}
+
+	hostname := cfg["hostname"]
+	if hostname == "" {
+		hostname = defaultRemoteHostname
+	}
+	baseURL := cfg["address"]
+	if baseURL == "" {
+		baseURL = "https://" + hostname
+	}
+
+	token := cfg["token"]
+	if token == "" {
+		token = os.Getenv(tokenEnvName(hostname))
+	}
+	if token == "" {
+		token = os.Getenv("TFE_TOKEN")
+	}
+
+	return &RemoteSource{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		organization: cfg["organization"],
+		workspace:    cfg["workspace"],
+		token:        token,
+		client:       &http.Client{Timeout: defaultHTTPTimeout},
+	}, nil
+}
+
+// tokenEnvName は Terraform CLI がホストごとのトークンに使う環境変数名を返す
+// (e.g., "app.terraform.io" -> "TF_TOKEN_app_terraform_io")
+func tokenEnvName(hostname string) string {
+	name := strings.ReplaceAll(hostname, "-", "__")
+	return "TF_TOKEN_" + strings.ReplaceAll(name, ".", "_")
+}
+
+// String は取得元の説明を返す
+func (s *RemoteSource) String() string {
+	return s.baseURL + "/" + s.organization + "/" + s.workspace
+}
+
+// Read はワークスペースの現在の state version をダウンロード
+func (s *RemoteSource) Read(ctx context.Context) ([]byte, error) {
+	workspaceID, err := s.resolveWorkspaceID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var version struct {
+		Data struct {
+			Attributes struct {
+				DownloadURL string `json:"hosted-state-download-url"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := s.getJSON(ctx, "/api/v2/workspaces/"+url.PathEscape(workspaceID)+"/current-state-version", &version); err != nil {
+		return nil, err
+	}
+	if version.Data.Attributes.DownloadURL == "" {
+		return nil, fmt.Errorf("%s: %w", s, ErrNoState)
+	}
+
+	req, err := s.newRequest(ctx, version.Data.Attributes.DownloadURL)
+	if err != nil {
+		return nil, err
+	}
+	return doGet(s.client, req, s.String())
+}
+
+// resolveWorkspaceID はワークスペース名から ID を取得（取得後はキャッシュ）
+func (s *RemoteSource) resolveWorkspaceID(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.workspaceID != "" {
+		return s.workspaceID, nil
+	}
+
+	var ws struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	path := "/api/v2/organizations/" + url.PathEscape(s.organization) + "/workspaces/" + url.PathEscape(s.workspace)
+	if err := s.getJSON(ctx, path, &ws); err != nil {
+		if errors.Is(err, ErrNoState) {
+			// 権限のないワークスペースも 404 になる
+			return "", fmt.Errorf("%s: workspace not found or not accessible with the token", s)
+		}
+		return "", err
+	}
+	if ws.Data.ID == "" {
+		return "", fmt.Errorf("%s: workspace not found", s)
+	}
+
+	s.workspaceID = ws.Data.ID
+	return s.workspaceID, nil
+}
+
+// getJSON は API を呼び出して JSON をデコード
+func (s *RemoteSource) getJSON(ctx context.Context, path string, v interface{}) error {
+	req, err := s.newRequest(ctx, s.baseURL+path)
+	if err != nil {
+		return err
+	}
+
+	data, err := doGet(s.client, req, s.String())
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", path, err)
+	}
+	return nil
+}
+
+// newRequest は認証ヘッダー付きの GET リクエストを作成
+func (s *RemoteSource) newRequest(ctx context.Context, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.api+json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return req, nil
+}
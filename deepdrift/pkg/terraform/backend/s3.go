@@ -0,0 +1,120 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// defaultWorkspaceKeyPrefix は Terraform の S3 backend の workspace_key_prefix の既定値
+const defaultWorkspaceKeyPrefix = "env:"
+
+// s3API は S3Source が使う API
+type s3API interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// S3Source は S3 backend の state
+//
+// 読み取りは DynamoDB のロックを取得しない（Terraform の plan / apply を妨げないため）。
+// apply 中に読んだ場合は直前の serial の state が返る。
+type S3Source struct {
+	bucket  string
+	key     string
+	region  string
+	profile string
+
+	once      sync.Once
+	client    s3API
+	clientErr error
+}
+
+// NewS3Source は S3 backend の設定 (bucket, key, region, profile, workspace, workspace_key_prefix) から S3Source を作成
+func NewS3Source(cfg map[string]string) (*S3Source, error) {
+	if cfg["bucket"] == "" || cfg["key"] == "" {
+		return nil, fmt.Errorf("s3 backend: bucket and key are required")
+	}
+
+	return &S3Source{
+		bucket:  cfg["bucket"],
+		key:     s3StateKey(cfg),
+		region:  cfg["region"],
+		profile: cfg["profile"],
+	}, nil
+}
+
+// s3StateKey は Terraform のワークスペースを考慮した state のキーを返す
+// default 以外のワークスペースは <workspace_key_prefix>/<workspace>/<key> に保存される
+func s3StateKey(cfg map[string]string) string {
+	workspace := cfg["workspace"]
+	if workspace == "" || workspace == "default" {
+		return cfg["key"]
+	}
+
+	prefix := cfg["workspace_key_prefix"]
+	if prefix == "" {
+		prefix = defaultWorkspaceKeyPrefix
+	}
+	return path.Join(prefix, workspace, cfg["key"])
+}
+
+// String は取得元の説明を返す
+func (s *S3Source) String() string {
+	return "s3://" + s.bucket + "/" + s.key
+}
+
+// Read は S3 から state を取得
+func (s *S3Source) Read(ctx context.Context) ([]byte, error) {
+	client, err := s.s3Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key),
+	})
+	if err != nil {
+		var noSuchKey *s3types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, fmt.Errorf("%s: %w", s, ErrNoState)
+		}
+		return nil, fmt.Errorf("failed to get %s: %w", s, err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s, err)
+	}
+	return data, nil
+}
+
+// s3Client は S3 クライアントを返す（初回のみ AWS 設定をロード）
+func (s *S3Source) s3Client(ctx context.Context) (s3API, error) {
+	s.once.Do(func() {
+		if s.client != nil {
+			return
+		}
+
+		opts := []func(*config.LoadOptions) error{config.WithSharedConfigProfile(s.profile)}
+		if s.region != "" {
+			opts = append(opts, config.WithRegion(s.region))
+		}
+		cfg, err := config.LoadDefaultConfig(ctx, opts...)
+		if err != nil {
+			s.clientErr = fmt.Errorf("failed to load AWS config: %w", err)
+			return
+		}
+		s.client = s3.NewFromConfig(cfg)
+	})
+	return s.client, s.clientErr
+}
@@ -0,0 +1,272 @@
+package terraform
+
+import (
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+// reference は他のリソースの ID を持つ属性から推論するエッジ（SkyGraph のデフォルトルールと同じ向き・タイプ）
+type reference struct {
+	// attribute は参照先の ID（または ID の配列）を持つ属性
+	attribute string
+
+	// idType は参照先のノード ID のタイプ
+	idType string
+
+	edgeType string
+
+	// ids は属性の値から参照先の ID を求める（nil の場合は属性の値そのもの）
+	ids func(s *State, value interface{}) []string
+}
+
+// references はリソースタイプごとの参照属性
+var references = map[string][]reference{
+	"aws_subnet": {
+		{attribute: "vpc_id", idType: "vpc", edgeType: "ownership"},
+	},
+	"aws_security_group": {
+		{attribute: "vpc_id", idType: "vpc", edgeType: "ownership"},
+	},
+	"aws_instance": {
+		{attribute: "subnet_id", idType: "subnet", edgeType: "network"},
+		{attribute: "vpc_security_group_ids", idType: "sg", edgeType: "network"},
+	},
+	"aws_db_instance": {
+		{attribute: "vpc_security_group_ids", idType: "sg", edgeType: "network"},
+		{attribute: "db_subnet_group_name", idType: "subnet", edgeType: "network", ids: (*State).dbSubnetGroupSubnets},
+	},
+}
+
+// GenerateDiagram は state から意図された構成（intended）のグラフを生成
+// ノード ID とメタデータは SkyGraph と同じ形式に揃え、実際のグラフと重ねて比較できるようにする
+func (s *State) GenerateDiagram() *graph.Graph {
+	g := graph.NewGraph()
+	for _, node := range s.Nodes() {
+		if !g.HasNode(node.ID) {
+			g.AddNode(node)
+		}
+	}
+
+	for _, r := range s.GetManagedResources() {
+		refs := references[r.Type]
+		if len(refs) == 0 {
+			continue
+		}
+		for _, inst := range r.Instances {
+			if inst.Deposed != "" {
+				continue
+			}
+			nodeID := r.NodeID(inst)
+			account, region := Location(inst)
+
+			for _, ref := range refs {
+				for _, id := range ref.resolve(s, inst.Attributes[ref.attribute]) {
+					// 両端のノードが state にある場合のみ
+					from := graph.AWSNodeID(account, region, ref.idType, id)
+					if id == "" || !g.HasNode(from) {
+						continue
+					}
+					g.AddEdge(graph.Edge{From: from, To: nodeID, Type: ref.edgeType})
+				}
+			}
+		}
+	}
+
+	return g
+}
+
+// Nodes は managed リソースの全インスタンスを SkyGraph のノードに変換
+func (s *State) Nodes() []graph.ResourceNode {
+	nodes := make([]graph.ResourceNode, 0)
+	for _, r := range s.GetManagedResources() {
+		for _, inst := range r.Instances {
+			// 置き換え待ちの古いオブジェクトは除く
+			if inst.Deposed != "" {
+				continue
+			}
+			nodes = append(nodes, r.Node(inst))
+		}
+	}
+	return nodes
+}
+
+// Node はインスタンスを SkyGraph のノードに変換
+func (r Resource) Node(inst Instance) graph.ResourceNode {
+	account, region := Location(inst)
+	if r.ProviderName() != "aws" {
+		account = ""
+	}
+
+	name := inst.StringAttribute("name")
+	if tags := StringMap(inst.Attributes["tags"]); tags["Name"] != "" {
+		name = tags["Name"]
+	}
+	if name == "" {
+		name = r.CloudID(inst)
+	}
+
+	return graph.ResourceNode{
+		ID:       r.NodeID(inst),
+		Type:     r.NodeType(),
+		Provider: r.ProviderName(),
+		Region:   region,
+		Account:  account,
+		Name:     name,
+		Metadata: r.metadata(inst),
+		Tags:     StringMap(inst.Attributes["tags"]),
+	}
+}
+
+// metadata は属性をコピーし、SkyGraph のスキャナーと同じキーを追加する
+func (r Resource) metadata(inst Instance) map[string]interface{} {
+	metadata := make(map[string]interface{}, len(inst.Attributes)+4)
+	for k, v := range inst.Attributes {
+		if k == "tags" || k == "tags_all" {
+			continue
+		}
+		metadata[k] = v
+	}
+
+	metadata["terraform_address"] = r.InstanceAddress(inst)
+	if inst.Status != "" {
+		metadata["terraform_status"] = inst.Status
+	}
+
+	switch r.Type {
+	case "aws_instance":
+		metadata["instance_id"] = inst.StringAttribute("id")
+		metadata["private_ip"] = inst.StringAttribute("private_ip")
+		metadata["security_groups"] = StringSlice(inst.Attributes["vpc_security_group_ids"])
+
+	case "aws_security_group":
+		metadata["group_id"] = inst.StringAttribute("id")
+		metadata["group_name"] = inst.StringAttribute("name")
+		metadata["ingress_rules"] = SecurityGroupRules(inst.Attributes["ingress"], inst.StringAttribute("id"))
+		metadata["egress_rules"] = SecurityGroupRules(inst.Attributes["egress"], inst.StringAttribute("id"))
+
+	case "aws_db_instance":
+		metadata["db_instance_id"] = inst.StringAttribute("identifier")
+		metadata["security_groups"] = StringSlice(inst.Attributes["vpc_security_group_ids"])
+	}
+
+	return metadata
+}
+
+// SecurityGroupRules は aws_security_group の ingress / egress を SkyGraph の形式に変換
+// self = true のルールは自身の Security Group への参照として扱う
+func SecurityGroupRules(v interface{}, selfID string) []map[string]interface{} {
+	items, _ := v.([]interface{})
+	rules := make([]map[string]interface{}, 0, len(items))
+
+	for _, item := range items {
+		attrs, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		rule := map[string]interface{}{
+			"protocol": attrs["protocol"],
+		}
+		if port, ok := attrs["from_port"]; ok {
+			rule["from_port"] = port
+		}
+		if port, ok := attrs["to_port"]; ok {
+			rule["to_port"] = port
+		}
+		if cidrs := StringSlice(attrs["cidr_blocks"]); len(cidrs) > 0 {
+			rule["cidr_blocks"] = cidrs
+		}
+		if cidrs := StringSlice(attrs["ipv6_cidr_blocks"]); len(cidrs) > 0 {
+			rule["ipv6_cidr_blocks"] = cidrs
+		}
+		if prefixLists := StringSlice(attrs["prefix_list_ids"]); len(prefixLists) > 0 {
+			rule["prefix_list_ids"] = prefixLists
+		}
+
+		groups := StringSlice(attrs["security_groups"])
+		if self, _ := attrs["self"].(bool); self && selfID != "" {
+			groups = append(groups, selfID)
+		}
+		if len(groups) > 0 {
+			rule["security_groups"] = groups
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// StringSlice は属性の配列（JSON では []interface{}）を []string に変換（文字列は 1 要素の配列）
+func StringSlice(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		if val == "" {
+			return []string{}
+		}
+		return []string{val}
+	case []string:
+		return val
+	case []interface{}:
+		result := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return []string{}
+	}
+}
+
+// StringMap は属性のマップ（JSON では map[string]interface{}）を map[string]string に変換
+func StringMap(v interface{}) map[string]string {
+	result := make(map[string]string)
+	switch val := v.(type) {
+	case map[string]string:
+		for k, s := range val {
+			result[k] = s
+		}
+	case map[string]interface{}:
+		for k, item := range val {
+			if s, ok := item.(string); ok {
+				result[k] = s
+			}
+		}
+	}
+	return result
+}
+
+// resolve は参照属性の値から参照先の ID を返す
+func (ref reference) resolve(s *State, value interface{}) []string {
+	if ref.ids != nil {
+		return ref.ids(s, value)
+	}
+	return StringSlice(value)
+}
+
+// dbSubnetGroupSubnets は DB サブネットグループ名から、state にあるそのグループの Subnet ID を返す
+// (RDS は Subnet をサブネットグループ経由で参照し、SkyGraph は RDS の subnet_ids からエッジを作る)
+func (s *State) dbSubnetGroupSubnets(value interface{}) []string {
+	name, _ := value.(string)
+	if name == "" {
+		return nil
+	}
+	for _, r := range s.GetResourcesByType("aws_db_subnet_group") {
+		if inst, ok := r.FindInstance("name", name); ok {
+			return StringSlice(inst.Attributes["subnet_ids"])
+		}
+	}
+	return nil
+}
+
+// ReferenceAttributes は nodeType のリソースが edgeType のエッジ（自身へのエッジ）を作る参照属性を返す
+// (e.g., "ec2", "network" → subnet_id, vpc_security_group_ids)。この属性の drift はエッジを変える
+func ReferenceAttributes(nodeType, edgeType string) []string {
+	attributes := make([]string, 0)
+	for _, ref := range references[ResourceType(nodeType)] {
+		if ref.edgeType == edgeType {
+			attributes = append(attributes, ref.attribute)
+		}
+	}
+	return attributes
+}
@@ -0,0 +1,117 @@
+package terraform
+
+import (
+	"strings"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+// resourceKind は Terraform のリソースタイプと SkyGraph のノードの対応
+type resourceKind struct {
+	// nodeType は SkyGraph のノードタイプ (e.g., "ec2")
+	nodeType string
+
+	// idType はノード ID に使うタイプ（Security Group は "sg"）
+	idType string
+
+	// idAttribute はクラウド側のリソース ID を持つ属性
+	idAttribute string
+}
+
+// resourceKinds は SkyGraph がスキャンするリソースタイプの対応表
+var resourceKinds = map[string]resourceKind{
+	"aws_instance":       {nodeType: "ec2", idType: "ec2", idAttribute: "id"},
+	"aws_vpc":            {nodeType: "vpc", idType: "vpc", idAttribute: "id"},
+	"aws_subnet":         {nodeType: "subnet", idType: "subnet", idAttribute: "id"},
+	"aws_security_group": {nodeType: "security_group", idType: "sg", idAttribute: "id"},
+	"aws_db_instance":    {nodeType: "rds", idType: "rds", idAttribute: "identifier"},
+	"aws_s3_bucket":      {nodeType: "s3", idType: "s3", idAttribute: "bucket"},
+}
+
+// kind はリソースタイプの対応を返す（対応表にない場合は "aws_" などの接頭辞を除いた名前）
+func (r Resource) kind() resourceKind {
+	if k, ok := resourceKinds[r.Type]; ok {
+		return k
+	}
+	_, name, found := strings.Cut(r.Type, "_")
+	if !found {
+		name = r.Type
+	}
+	return resourceKind{nodeType: name, idType: name, idAttribute: "id"}
+}
+
+// ResourceType は SkyGraph のノードタイプに対応する Terraform のリソースタイプを返す
+// (e.g., "ec2" → "aws_instance")。対応表にない場合は "aws_" を付けた名前
+func ResourceType(nodeType string) string {
+	for resourceType, k := range resourceKinds {
+		if k.nodeType == nodeType {
+			return resourceType
+		}
+	}
+	return "aws_" + nodeType
+}
+
+// IsScannedType は SkyGraph がスキャンするノードタイプかを判定
+func IsScannedType(nodeType string) bool {
+	for _, k := range resourceKinds {
+		if k.nodeType == nodeType {
+			return true
+		}
+	}
+	return false
+}
+
+// ProviderName はリソースタイプの接頭辞からプロバイダー名を返す (e.g., "aws_instance" → "aws")
+func (r Resource) ProviderName() string {
+	provider, _, _ := strings.Cut(r.Type, "_")
+	return provider
+}
+
+// NodeType は対応する SkyGraph のノードタイプを返す
+func (r Resource) NodeType() string {
+	return r.kind().nodeType
+}
+
+// CloudID はインスタンスのクラウド側の ID を返す（インスタンス ID、バケット名など）
+func (r Resource) CloudID(inst Instance) string {
+	if id := inst.StringAttribute(r.kind().idAttribute); id != "" {
+		return id
+	}
+	return inst.StringAttribute("id")
+}
+
+// NodeID は対応する SkyGraph のノード ID を返す
+// AWS リソースはアカウント・リージョンを ARN（なければ owner_id, region, availability_zone）から求める
+func (r Resource) NodeID(inst Instance) string {
+	k := r.kind()
+	id := r.CloudID(inst)
+
+	provider := r.ProviderName()
+	if provider != "aws" {
+		return provider + ":" + k.idType + ":" + id
+	}
+
+	account, region := Location(inst)
+	return graph.AWSNodeID(account, region, k.idType, id)
+}
+
+// Location は AWS リソースのアカウント ID とリージョンを返す
+func Location(inst Instance) (account, region string) {
+	// arn:partition:service:region:account:resource
+	if parts := strings.SplitN(inst.StringAttribute("arn"), ":", 6); len(parts) == 6 {
+		region, account = parts[3], parts[4]
+	}
+	if account == "" {
+		account = inst.StringAttribute("owner_id")
+	}
+	if region == "" {
+		region = inst.StringAttribute("region")
+	}
+	if region == "" {
+		// us-east-1a → us-east-1
+		if az := inst.StringAttribute("availability_zone"); len(az) > 1 {
+			region = az[:len(az)-1]
+		}
+	}
+	return account, region
+}
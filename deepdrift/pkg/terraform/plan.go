@@ -0,0 +1,202 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// UnknownValue は plan の時点では値が決まらない属性（"known after apply"）に入れる値
+const UnknownValue = "(known after apply)"
+
+// PlanAction は plan のリソースの変更の種類
+type PlanAction string
+
+const (
+	ActionCreate  PlanAction = "create"
+	ActionUpdate  PlanAction = "update"
+	ActionDelete  PlanAction = "delete"
+	ActionReplace PlanAction = "replace"
+	ActionRead    PlanAction = "read"
+	ActionNoOp    PlanAction = "no-op"
+)
+
+// Plan は `terraform show -json <planfile>` の出力（JSON output format v1）
+type Plan struct {
+	FormatVersion    string           `json:"format_version"`
+	TerraformVersion string           `json:"terraform_version"`
+	ResourceChanges  []ResourceChange `json:"resource_changes"`
+}
+
+// ResourceChange は plan のリソースインスタンスごとの変更
+type ResourceChange struct {
+	// Address はインスタンスのアドレス (e.g., `module.app.aws_instance.web[0]`)
+	Address string `json:"address"`
+
+	// ModuleAddress はモジュールのパス（ルートモジュールの場合は空）
+	ModuleAddress string `json:"module_address,omitempty"`
+
+	Mode string `json:"mode"`
+	Type string `json:"type"`
+	Name string `json:"name"`
+
+	// Index は count（数値）または for_each（文字列）のキー
+	Index interface{} `json:"index,omitempty"`
+
+	ProviderName string `json:"provider_name"`
+
+	// Deposed は置き換え待ちの古いオブジェクトの変更の場合のキー
+	Deposed string `json:"deposed,omitempty"`
+
+	Change Change `json:"change"`
+
+	// ActionReason は変更の理由 (e.g., "replace_because_cannot_update")
+	ActionReason string `json:"action_reason,omitempty"`
+}
+
+// Change は変更前後の値
+type Change struct {
+	Actions []string               `json:"actions"`
+	Before  map[string]interface{} `json:"before"`
+	After   map[string]interface{} `json:"after"`
+
+	// AfterUnknown は After のうち apply まで値が決まらない属性（After と同じ形で値が true）
+	AfterUnknown interface{} `json:"after_unknown,omitempty"`
+
+	// ReplacePaths は置き換えの原因になった属性のパス
+	ReplacePaths [][]interface{} `json:"replace_paths,omitempty"`
+}
+
+// Action は actions を1つの変更の種類にまとめる
+// ["delete", "create"] と ["create", "delete"]（create_before_destroy）は replace
+func (c Change) Action() PlanAction {
+	switch len(c.Actions) {
+	case 1:
+		return PlanAction(c.Actions[0])
+	case 2:
+		return ActionReplace
+	}
+	return ActionNoOp
+}
+
+// AfterValues は After に apply まで決まらない属性を UnknownValue として加えた値を返す
+func (c Change) AfterValues() map[string]interface{} {
+	if c.After == nil {
+		return nil
+	}
+	values, _ := withUnknown(c.After, c.AfterUnknown).(map[string]interface{})
+	return values
+}
+
+// withUnknown は after_unknown が true の位置に UnknownValue を入れた値を返す
+func withUnknown(value, unknown interface{}) interface{} {
+	switch u := unknown.(type) {
+	case bool:
+		if u {
+			return UnknownValue
+		}
+	case map[string]interface{}:
+		m, _ := value.(map[string]interface{})
+		merged := make(map[string]interface{}, len(m)+len(u))
+		for k, v := range m {
+			merged[k] = v
+		}
+		for k, uv := range u {
+			merged[k] = withUnknown(m[k], uv)
+		}
+		return merged
+	case []interface{}:
+		list, _ := value.([]interface{})
+		merged := make([]interface{}, max(len(list), len(u)))
+		copy(merged, list)
+		for i, uv := range u {
+			merged[i] = withUnknown(merged[i], uv)
+		}
+		return merged
+	}
+	return value
+}
+
+// ReplacePathNames は置き換えの原因になった属性のパスを "ingress[0].cidr_blocks" の形で返す
+func (c Change) ReplacePathNames() []string {
+	names := make([]string, 0, len(c.ReplacePaths))
+	for _, path := range c.ReplacePaths {
+		var b strings.Builder
+		for _, step := range path {
+			switch s := step.(type) {
+			case string:
+				if b.Len() > 0 {
+					b.WriteString(".")
+				}
+				b.WriteString(s)
+			default:
+				b.WriteString(indexSuffix(s))
+			}
+		}
+		names = append(names, b.String())
+	}
+	return names
+}
+
+// Resource は変更のリソースを state のリソースとして返す（ノード ID・ノードタイプの変換に使う）
+func (rc ResourceChange) Resource() Resource {
+	return Resource{Module: rc.ModuleAddress, Mode: rc.Mode, Type: rc.Type, Name: rc.Name}
+}
+
+// Instance は変更前（削除・更新）または変更後（作成）の値をインスタンスとして返す
+func (rc ResourceChange) Instance() Instance {
+	attributes := rc.Change.Before
+	if attributes == nil {
+		attributes = rc.Change.After
+	}
+	return Instance{IndexKey: rc.Index, Attributes: attributes, Deposed: rc.Deposed}
+}
+
+// LoadPlan は plan の JSON ファイルを読み込んでパース（"-" の場合は標準入力）
+func LoadPlan(path string) (*Plan, error) {
+	if path == "-" {
+		return ParsePlan(os.Stdin)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plan file: %w", err)
+	}
+	defer f.Close()
+
+	plan, err := ParsePlan(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plan, nil
+}
+
+// ParsePlan は `terraform show -json` の plan をパース
+func ParsePlan(r io.Reader) (*Plan, error) {
+	var plan Plan
+	if err := json.NewDecoder(r).Decode(&plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if major, _, _ := strings.Cut(plan.FormatVersion, "."); major != "1" {
+		return nil, fmt.Errorf("unsupported plan format version %q (use the output of terraform show -json <planfile>)", plan.FormatVersion)
+	}
+	return &plan, nil
+}
+
+// ManagedChanges は managed リソースの変更（no-op と read を除く）を返す
+func (p *Plan) ManagedChanges() []ResourceChange {
+	changes := make([]ResourceChange, 0, len(p.ResourceChanges))
+	for _, rc := range p.ResourceChanges {
+		if rc.Mode != "managed" {
+			continue
+		}
+		switch rc.Change.Action() {
+		case ActionNoOp, ActionRead:
+			continue
+		}
+		changes = append(changes, rc)
+	}
+	return changes
+}
@@ -0,0 +1,146 @@
+package terraform
+
+import (
+	"strings"
+	"testing"
+)
+
+// testPlan は `terraform show -json` の plan（更新・置き換え・作成・no-op・data source の読み込み）
+const testPlan = `{
+  "format_version": "1.2",
+  "terraform_version": "1.7.5",
+  "resource_changes": [
+    {
+      "address": "module.network.aws_subnet.private[\"a\"]",
+      "module_address": "module.network",
+      "mode": "managed",
+      "type": "aws_subnet",
+      "name": "private",
+      "index": "a",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {
+        "actions": ["update"],
+        "before": {"id": "subnet-a", "arn": "arn:aws:ec2:us-east-1:111111111111:subnet/subnet-a", "map_public_ip_on_launch": false, "tags": {"Name": "a"}},
+        "after": {"id": "subnet-a", "arn": "arn:aws:ec2:us-east-1:111111111111:subnet/subnet-a", "map_public_ip_on_launch": true, "tags": {"Name": "a"}},
+        "after_unknown": {"tags": {}}
+      }
+    },
+    {
+      "address": "aws_db_instance.main",
+      "mode": "managed",
+      "type": "aws_db_instance",
+      "name": "main",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {
+        "actions": ["create", "delete"],
+        "before": {"id": "db-ABC", "identifier": "main", "engine": "mysql", "vpc_security_group_ids": ["sg-1"]},
+        "after": {"identifier": "main", "engine": "postgres", "vpc_security_group_ids": ["sg-1"]},
+        "after_unknown": {"id": true, "endpoint": true, "vpc_security_group_ids": [false]},
+        "replace_paths": [["engine"], ["ingress", 0, "cidr_blocks"]]
+      },
+      "action_reason": "replace_because_cannot_update"
+    },
+    {
+      "address": "aws_instance.worker[0]",
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "worker",
+      "index": 0,
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {
+        "actions": ["create"],
+        "before": null,
+        "after": {"instance_type": "t3.micro", "subnet_id": "subnet-a", "ebs_block_device": [{"volume_size": 20}]},
+        "after_unknown": {"id": true, "private_ip": true, "ebs_block_device": [{"volume_id": true}]}
+      }
+    },
+    {
+      "address": "aws_s3_bucket.logs",
+      "mode": "managed",
+      "type": "aws_s3_bucket",
+      "name": "logs",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {"actions": ["no-op"], "before": {"bucket": "logs"}, "after": {"bucket": "logs"}}
+    },
+    {
+      "address": "data.aws_ami.ubuntu",
+      "mode": "data",
+      "type": "aws_ami",
+      "name": "ubuntu",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {"actions": ["read"], "before": null, "after": {}}
+    }
+  ]
+}`
+
+func TestParsePlan(t *testing.T) {
+	plan, err := ParsePlan(strings.NewReader(testPlan))
+	if err != nil {
+		t.Fatalf("ParsePlan() error = %v", err)
+	}
+	if plan.TerraformVersion != "1.7.5" || len(plan.ResourceChanges) != 5 {
+		t.Fatalf("Unexpected plan: %+v", plan)
+	}
+
+	// no-op と data source の読み込みは除く
+	changes := plan.ManagedChanges()
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 managed changes, got %d", len(changes))
+	}
+
+	want := []PlanAction{ActionUpdate, ActionReplace, ActionCreate}
+	for i, rc := range changes {
+		if got := rc.Change.Action(); got != want[i] {
+			t.Errorf("%s: Action() = %s, want %s", rc.Address, got, want[i])
+		}
+	}
+
+	// 更新・置き換えは変更前の値から SkyGraph のノードを求める
+	subnet := changes[0]
+	if got := subnet.Resource().NodeID(subnet.Instance()); got != "aws:111111111111:us-east-1:subnet:subnet-a" {
+		t.Errorf("NodeID() = %s", got)
+	}
+	if got := subnet.Resource().InstanceAddress(subnet.Instance()); got != subnet.Address {
+		t.Errorf("InstanceAddress() = %s, want %s", got, subnet.Address)
+	}
+
+	if got := changes[1].Change.ReplacePathNames(); len(got) != 2 || got[0] != "engine" || got[1] != "ingress[0].cidr_blocks" {
+		t.Errorf("ReplacePathNames() = %v", got)
+	}
+}
+
+func TestChange_AfterValues(t *testing.T) {
+	plan, err := ParsePlan(strings.NewReader(testPlan))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := plan.ManagedChanges()
+
+	db := changes[1].Change.AfterValues()
+	if db["id"] != UnknownValue || db["endpoint"] != UnknownValue || db["engine"] != "postgres" {
+		t.Errorf("Unexpected values: %v", db)
+	}
+	if sgs := StringSlice(db["vpc_security_group_ids"]); len(sgs) != 1 || sgs[0] != "sg-1" {
+		t.Errorf("Expected known list elements to be kept, got %v", db["vpc_security_group_ids"])
+	}
+
+	// ネストしたブロックの値も補う
+	worker := changes[2].Change.AfterValues()
+	block := worker["ebs_block_device"].([]interface{})[0].(map[string]interface{})
+	if block["volume_id"] != UnknownValue || block["volume_size"] != float64(20) {
+		t.Errorf("Unexpected block: %v", block)
+	}
+
+	// 作成するリソースの ID は決まっていない
+	if id := changes[2].Resource().CloudID(changes[2].Instance()); id != "" {
+		t.Errorf("Expected no cloud ID for a new resource, got %s", id)
+	}
+}
+
+func TestParsePlan_NotAPlan(t *testing.T) {
+	// state ファイルを渡した場合
+	_, err := ParsePlan(strings.NewReader(testState))
+	if err == nil || !strings.Contains(err.Error(), "unsupported plan format version") {
+		t.Errorf("Expected unsupported format error, got %v", err)
+	}
+}
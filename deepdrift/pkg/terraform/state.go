@@ -0,0 +1,187 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// SupportedStateVersion は読み込みに対応している state ファイルのフォーマットバージョン
+const SupportedStateVersion = 4
+
+// State は Terraform state（フォーマット v4）を表す
+type State struct {
+	Version          int               `json:"version"`
+	TerraformVersion string            `json:"terraform_version"`
+	Serial           int64             `json:"serial"`
+	Lineage          string            `json:"lineage"`
+	Outputs          map[string]Output `json:"outputs,omitempty"`
+	Resources        []Resource        `json:"resources"`
+}
+
+// Output は state の output 値
+type Output struct {
+	Value     interface{} `json:"value"`
+	Type      interface{} `json:"type,omitempty"`
+	Sensitive bool        `json:"sensitive,omitempty"`
+}
+
+// Resource は state 内のリソースブロック（count / for_each の場合は複数の Instance を持つ）
+type Resource struct {
+	// Module はモジュールのパス（ルートモジュールの場合は空、例: "module.vpc.module.subnets"）
+	Module string `json:"module,omitempty"`
+
+	// Mode は "managed" または "data"
+	Mode string `json:"mode"`
+
+	// Type はリソースタイプ (e.g., "aws_instance")
+	Type string `json:"type"`
+
+	// Name はリソース名
+	Name string `json:"name"`
+
+	// Provider はプロバイダー設定 (e.g., `provider["registry.terraform.io/hashicorp/aws"]`)
+	Provider string `json:"provider"`
+
+	// Instances はリソースのインスタンス
+	Instances []Instance `json:"instances"`
+}
+
+// Instance はリソースの 1 インスタンス
+type Instance struct {
+	// IndexKey は count（数値）または for_each（文字列）のキー、単一インスタンスの場合は nil
+	IndexKey interface{} `json:"index_key,omitempty"`
+
+	SchemaVersion int                    `json:"schema_version"`
+	Attributes    map[string]interface{} `json:"attributes"`
+
+	// Status は "tainted" など（通常は空）
+	Status string `json:"status,omitempty"`
+
+	// Deposed は create_before_destroy で置き換え待ちのオブジェクトのキー
+	Deposed string `json:"deposed,omitempty"`
+
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+// Address はリソースのアドレスを返す (e.g., "module.app.aws_instance.web")
+func (r Resource) Address() string {
+	address := r.Type + "." + r.Name
+	if r.Mode == "data" {
+		address = "data." + address
+	}
+	if r.Module != "" {
+		address = r.Module + "." + address
+	}
+	return address
+}
+
+// InstanceAddress はインスタンスのアドレスを返す (e.g., `aws_instance.web[0]`, `aws_instance.web["a"]`)
+func (r Resource) InstanceAddress(inst Instance) string {
+	return r.Address() + indexSuffix(inst.IndexKey)
+}
+
+// indexSuffix は index_key をアドレスの添字に変換
+func indexSuffix(key interface{}) string {
+	switch k := key.(type) {
+	case nil:
+		return ""
+	case float64:
+		return fmt.Sprintf("[%d]", int64(k))
+	case string:
+		return fmt.Sprintf("[%q]", k)
+	default:
+		return fmt.Sprintf("[%v]", k)
+	}
+}
+
+// StringAttribute は文字列属性を返す（存在しない場合は空文字）
+func (inst Instance) StringAttribute(key string) string {
+	s, _ := inst.Attributes[key].(string)
+	return s
+}
+
+// StateReader は Terraform state ファイルを読み込む
+type StateReader struct {
+	path string
+}
+
+// NewStateReader は新しい StateReader を作成
+func NewStateReader(path string) *StateReader {
+	return &StateReader{path: path}
+}
+
+// Load は state ファイルを読み込んでパース
+func (r *StateReader) Load() (*State, error) {
+	f, err := os.Open(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state file: %w", err)
+	}
+	defer f.Close()
+
+	state, err := ParseState(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", r.path, err)
+	}
+	return state, nil
+}
+
+// ParseState は state（JSON）をパース
+func ParseState(r io.Reader) (*State, error) {
+	var state State
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+	if state.Version != SupportedStateVersion {
+		return nil, fmt.Errorf("unsupported state version %d (only version %d is supported)", state.Version, SupportedStateVersion)
+	}
+	return &state, nil
+}
+
+// GetManagedResources は managed リソース（data source 以外）を返す
+func (s *State) GetManagedResources() []Resource {
+	resources := make([]Resource, 0, len(s.Resources))
+	for _, r := range s.Resources {
+		if r.Mode == "managed" {
+			resources = append(resources, r)
+		}
+	}
+	return resources
+}
+
+// GetResourcesByType は指定したタイプの managed リソースを返す（全モジュール）
+func (s *State) GetResourcesByType(resourceType string) []Resource {
+	resources := make([]Resource, 0)
+	for _, r := range s.Resources {
+		if r.Mode == "managed" && r.Type == resourceType {
+			resources = append(resources, r)
+		}
+	}
+	return resources
+}
+
+// GetEC2Instances は aws_instance リソースを返す
+func (s *State) GetEC2Instances() []Resource {
+	return s.GetResourcesByType("aws_instance")
+}
+
+// GetSecurityGroups は aws_security_group リソースを返す
+func (s *State) GetSecurityGroups() []Resource {
+	return s.GetResourcesByType("aws_security_group")
+}
+
+// GetS3Buckets は aws_s3_bucket リソースを返す
+func (s *State) GetS3Buckets() []Resource {
+	return s.GetResourcesByType("aws_s3_bucket")
+}
+
+// FindInstance は属性 key の値が value のインスタンスを探す（deposed は除く）
+func (r Resource) FindInstance(key, value string) (Instance, bool) {
+	for _, inst := range r.Instances {
+		if inst.Deposed == "" && inst.StringAttribute(key) == value {
+			return inst, true
+		}
+	}
+	return Instance{}, false
+}
@@ -0,0 +1,273 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testState は module・count・for_each・data source・deposed を含む state v4
+const testState = `{
+  "version": 4,
+  "terraform_version": "1.6.0",
+  "serial": 12,
+  "lineage": "3f1c2b7a",
+  "outputs": {"vpc_id": {"value": "vpc-1", "type": "string"}},
+  "resources": [
+    {
+      "mode": "data",
+      "type": "aws_ami",
+      "name": "ubuntu",
+      "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+      "instances": [{"schema_version": 0, "attributes": {"id": "ami-123"}}]
+    },
+    {
+      "module": "module.network",
+      "mode": "managed",
+      "type": "aws_vpc",
+      "name": "main",
+      "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+      "instances": [{"schema_version": 1, "attributes": {
+        "id": "vpc-1", "arn": "arn:aws:ec2:us-east-1:111111111111:vpc/vpc-1", "cidr_block": "10.0.0.0/16",
+        "tags": {"Name": "main"}
+      }}]
+    },
+    {
+      "module": "module.network",
+      "mode": "managed",
+      "type": "aws_subnet",
+      "name": "private",
+      "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+      "instances": [
+        {"index_key": "a", "schema_version": 1, "attributes": {
+          "id": "subnet-a", "arn": "arn:aws:ec2:us-east-1:111111111111:subnet/subnet-a", "vpc_id": "vpc-1"
+        }},
+        {"index_key": "b", "schema_version": 1, "attributes": {
+          "id": "subnet-b", "arn": "arn:aws:ec2:us-east-1:111111111111:subnet/subnet-b", "vpc_id": "vpc-1"
+        }}
+      ]
+    },
+    {
+      "mode": "managed",
+      "type": "aws_security_group",
+      "name": "web",
+      "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+      "instances": [{"schema_version": 1, "attributes": {
+        "id": "sg-1", "arn": "arn:aws:ec2:us-east-1:111111111111:security-group/sg-1",
+        "name": "web", "vpc_id": "vpc-1",
+        "ingress": [{"protocol": "tcp", "from_port": 443, "to_port": 443, "cidr_blocks": ["0.0.0.0/0"],
+                     "ipv6_cidr_blocks": [], "prefix_list_ids": [], "security_groups": [], "self": true}],
+        "egress": []
+      }}]
+    },
+    {
+      "module": "module.app[\"blue\"]",
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "web",
+      "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+      "instances": [
+        {"index_key": 0, "schema_version": 1, "attributes": {
+          "id": "i-0", "arn": "arn:aws:ec2:us-east-1:111111111111:instance/i-0",
+          "instance_type": "t3.micro", "subnet_id": "subnet-a", "vpc_security_group_ids": ["sg-1"],
+          "tags": {"Name": "web-0"}
+        }},
+        {"index_key": 1, "schema_version": 1, "status": "tainted", "attributes": {
+          "id": "i-1", "arn": "arn:aws:ec2:us-east-1:111111111111:instance/i-1",
+          "instance_type": "t3.micro", "subnet_id": "subnet-b", "vpc_security_group_ids": ["sg-1"]
+        }},
+        {"index_key": 1, "deposed": "00000001", "schema_version": 1, "attributes": {"id": "i-old"}}
+      ]
+    },
+    {
+      "mode": "managed",
+      "type": "aws_s3_bucket",
+      "name": "logs",
+      "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+      "instances": [{"schema_version": 0, "attributes": {
+        "id": "logs-bucket", "bucket": "logs-bucket", "arn": "arn:aws:s3:::logs-bucket", "region": "us-east-1"
+      }}]
+    }
+  ]
+}`
+
+func loadTestState(t *testing.T) *State {
+	t.Helper()
+	state, err := ParseState(strings.NewReader(testState))
+	if err != nil {
+		t.Fatalf("ParseState() error = %v", err)
+	}
+	return state
+}
+
+func TestParseState(t *testing.T) {
+	state := loadTestState(t)
+
+	if state.Serial != 12 || state.Lineage != "3f1c2b7a" || state.TerraformVersion != "1.6.0" {
+		t.Errorf("Unexpected header: %+v", state)
+	}
+	if len(state.Resources) != 6 {
+		t.Fatalf("Expected 6 resources, got %d", len(state.Resources))
+	}
+	if len(state.GetManagedResources()) != 5 {
+		t.Errorf("Expected data sources to be excluded, got %d managed", len(state.GetManagedResources()))
+	}
+	if got := state.Outputs["vpc_id"].Value; got != "vpc-1" {
+		t.Errorf("Unexpected output: %v", got)
+	}
+
+	ec2 := state.GetEC2Instances()
+	if len(ec2) != 1 || len(ec2[0].Instances) != 3 {
+		t.Fatalf("Unexpected EC2 resources: %+v", ec2)
+	}
+	if len(state.GetSecurityGroups()) != 1 || len(state.GetS3Buckets()) != 1 {
+		t.Error("Expected one security group and one bucket")
+	}
+}
+
+func TestParseState_UnsupportedVersion(t *testing.T) {
+	_, err := ParseState(strings.NewReader(`{"version": 3, "modules": []}`))
+	if err == nil || !strings.Contains(err.Error(), "unsupported state version 3") {
+		t.Errorf("Expected unsupported version error, got %v", err)
+	}
+}
+
+func TestStateReader_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terraform.tfstate")
+	if err := os.WriteFile(path, []byte(testState), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := NewStateReader(path).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(state.Resources) != 6 {
+		t.Errorf("Expected 6 resources, got %d", len(state.Resources))
+	}
+
+	if _, err := NewStateReader(filepath.Join(t.TempDir(), "missing")).Load(); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestResource_Addresses(t *testing.T) {
+	state := loadTestState(t)
+
+	tests := []struct {
+		resource Resource
+		instance int
+		want     string
+	}{
+		{state.GetEC2Instances()[0], 0, `module.app["blue"].aws_instance.web[0]`},
+		{state.GetEC2Instances()[0], 1, `module.app["blue"].aws_instance.web[1]`},
+		{state.GetResourcesByType("aws_subnet")[0], 1, `module.network.aws_subnet.private["b"]`},
+		{state.GetS3Buckets()[0], 0, `aws_s3_bucket.logs`},
+		{state.Resources[0], 0, `data.aws_ami.ubuntu`},
+	}
+	for _, tt := range tests {
+		if got := tt.resource.InstanceAddress(tt.resource.Instances[tt.instance]); got != tt.want {
+			t.Errorf("InstanceAddress() = %s, want %s", got, tt.want)
+		}
+	}
+}
+
+func TestResource_NodeID(t *testing.T) {
+	state := loadTestState(t)
+
+	ec2 := state.GetEC2Instances()[0]
+	if got := ec2.NodeID(ec2.Instances[0]); got != "aws:111111111111:us-east-1:ec2:i-0" {
+		t.Errorf("Unexpected EC2 node ID: %s", got)
+	}
+
+	sg := state.GetSecurityGroups()[0]
+	if got := sg.NodeID(sg.Instances[0]); got != "aws:111111111111:us-east-1:sg:sg-1" {
+		t.Errorf("Unexpected security group node ID: %s", got)
+	}
+
+	// S3 の ARN にはアカウントがないため短い形式
+	bucket := state.GetS3Buckets()[0]
+	if got := bucket.NodeID(bucket.Instances[0]); got != "aws:s3:logs-bucket" {
+		t.Errorf("Unexpected bucket node ID: %s", got)
+	}
+}
+
+func TestGenerateDiagram(t *testing.T) {
+	g := loadTestState(t).GenerateDiagram()
+
+	// vpc, subnet x2, sg, ec2 x2 (deposed を除く), s3
+	if g.NodeCount() != 7 {
+		t.Fatalf("Expected 7 nodes, got %d", g.NodeCount())
+	}
+	if g.HasNode("aws:ec2:i-old") || g.HasNode("aws:111111111111:us-east-1:ec2:i-old") {
+		t.Error("Deposed instance should not be in the diagram")
+	}
+
+	node := g.FindNode("aws:111111111111:us-east-1:ec2:i-0")
+	if node == nil {
+		t.Fatal("Expected EC2 node")
+	}
+	if node.Name != "web-0" || node.Type != "ec2" || node.Region != "us-east-1" || node.Account != "111111111111" {
+		t.Errorf("Unexpected node: %+v", node)
+	}
+	if node.Metadata["terraform_address"] != `module.app["blue"].aws_instance.web[0]` {
+		t.Errorf("Unexpected terraform_address: %v", node.Metadata["terraform_address"])
+	}
+	if tainted := g.FindNode("aws:111111111111:us-east-1:ec2:i-1"); tainted.Metadata["terraform_status"] != "tainted" {
+		t.Errorf("Expected tainted status, got %v", tainted.Metadata["terraform_status"])
+	}
+
+	hasEdge := func(from, to, edgeType string) bool {
+		for _, e := range g.OutEdges(from) {
+			if e.To == to && e.Type == edgeType {
+				return true
+			}
+		}
+		return false
+	}
+	id := func(idType, id string) string { return "aws:111111111111:us-east-1:" + idType + ":" + id }
+
+	for _, want := range [][3]string{
+		{id("vpc", "vpc-1"), id("subnet", "subnet-a"), "ownership"},
+		{id("vpc", "vpc-1"), id("subnet", "subnet-b"), "ownership"},
+		{id("vpc", "vpc-1"), id("sg", "sg-1"), "ownership"},
+		{id("subnet", "subnet-a"), id("ec2", "i-0"), "network"},
+		{id("sg", "sg-1"), id("ec2", "i-1"), "network"},
+	} {
+		if !hasEdge(want[0], want[1], want[2]) {
+			t.Errorf("Missing edge %v", want)
+		}
+	}
+	if g.EdgeCount() != 7 {
+		t.Errorf("Expected 7 edges, got %d: %+v", g.EdgeCount(), g.Edges)
+	}
+
+	// self = true は自身への参照として SkyGraph の形式に変換される
+	sg := g.FindNode(id("sg", "sg-1"))
+	rules := sg.Metadata["ingress_rules"].([]map[string]interface{})
+	if len(rules) != 1 {
+		t.Fatalf("Unexpected ingress rules: %v", rules)
+	}
+	if groups := rules[0]["security_groups"].([]string); len(groups) != 1 || groups[0] != "sg-1" {
+		t.Errorf("Expected self reference, got %v", rules[0])
+	}
+}
+
+func TestReferenceAttributes(t *testing.T) {
+	tests := []struct {
+		nodeType, edgeType string
+		want               string
+	}{
+		{"ec2", "network", "[subnet_id vpc_security_group_ids]"},
+		{"security_group", "ownership", "[vpc_id]"},
+		{"ec2", "ownership", "[]"},
+		{"lambda", "network", "[]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(ReferenceAttributes(tt.nodeType, tt.edgeType)); got != tt.want {
+			t.Errorf("ReferenceAttributes(%q, %q) = %s, want %s", tt.nodeType, tt.edgeType, got, tt.want)
+		}
+	}
+}
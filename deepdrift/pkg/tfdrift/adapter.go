@@ -9,6 +9,8 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/higakikeita/airdig/deepdrift/pkg/drift"
+	"github.com/higakikeita/airdig/deepdrift/pkg/severity"
 	"github.com/higakikeita/airdig/deepdrift/pkg/types"
 )
 
@@ -16,6 +18,8 @@ import (
 type TFDriftAdapter struct {
 	tfdriftPath string
 	configPath  string
+	suppressor  *drift.Suppressor
+	severity    *severity.Engine
 }
 
 // NewTFDriftAdapter は新しい TFDriftAdapter を作成
@@ -26,7 +30,18 @@ func NewTFDriftAdapter(tfdriftPath, configPath string) *TFDriftAdapter {
 	}
 }
 
+// SetSuppressor は検出した drift に抑制ポリシーを適用するよう設定
+func (a *TFDriftAdapter) SetSuppressor(suppressor *drift.Suppressor) {
+	a.suppressor = suppressor
+}
+
+// SetSeverityEngine は drift の深刻度を計算するルールを設定（未設定の場合は既定のルール）
+func (a *TFDriftAdapter) SetSeverityEngine(engine *severity.Engine) {
+	a.severity = engine
+}
+
 // DetectDrift は TFDrift を実行して drift イベントを検出
+// 抑制ポリシーに一致した drift は返さない
 func (a *TFDriftAdapter) DetectDrift(ctx context.Context, stateFile string) ([]*types.DriftEvent, error) {
 	// TFDrift を実行
 	output, err := a.runTFDrift(ctx, stateFile)
@@ -39,7 +54,6 @@ func (a *TFDriftAdapter) DetectDrift(ctx context.Context, stateFile string) ([]*
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse tfdrift output: %w", err)
 	}
-
 	return events, nil
 }
 
@@ -77,6 +91,7 @@ func (a *TFDriftAdapter) runTFDrift(ctx context.Context, stateFile string) ([]by
 }
 
 // parseTFDriftOutput は TFDrift の JSON 出力を DeepDrift の DriftEvent に変換
+// 抑制ポリシーに一致した drift を除き、残った差分から深刻度を計算する
 func (a *TFDriftAdapter) parseTFDriftOutput(output []byte) ([]*types.DriftEvent, error) {
 	// TFDrift の出力形式（仮定）
 	type TFDriftOutput struct {
@@ -103,19 +118,23 @@ func (a *TFDriftAdapter) parseTFDriftOutput(output []byte) ([]*types.DriftEvent,
 	}
 
 	// DeepDrift の DriftEvent に変換
+	now := time.Now()
 	events := make([]*types.DriftEvent, 0, len(tfdriftOutput.Drifts))
 	for _, drift := range tfdriftOutput.Drifts {
 		event := &types.DriftEvent{
-			ID:           fmt.Sprintf("drift-%d", time.Now().UnixNano()),
 			ResourceID:   drift.ResourceID,
 			ResourceType: drift.ResourceType,
 			Type:         types.DriftType(drift.Type),
-			Timestamp:    time.Now(),
+			Timestamp:    now,
 			Before:       drift.Before,
 			After:        drift.After,
 			Diff:         calculateDiff(drift.Before, drift.After),
-			Severity:     calculateSeverity(drift.Type, drift.ResourceType),
+			Status:       types.DriftOpen,
+			FirstSeen:    now,
+			LastSeen:     now,
 		}
+		// 同じ drift は検出のたびに同じ ID になる
+		event.ID = types.DriftEventID(event)
 
 		// CloudTrail 情報がある場合は RootCause を設定
 		if drift.CloudTrail != nil {
@@ -129,89 +148,28 @@ func (a *TFDriftAdapter) parseTFDriftOutput(output []byte) ([]*types.DriftEvent,
 			}
 		}
 
-		events = append(events, event)
+		if event = a.suppressor.Apply(event); event == nil {
+			continue
+		}
+		events = append(events, a.severity.Apply(event))
 	}
 
 	return events, nil
 }
 
-// calculateDiff は Before と After の差分を計算
+// calculateDiff は Before と After の差分をパスごとに計算
+// 例: Security Group のルールが1つ変わった場合は "ingress[2].cidr_blocks[0]" のように変化した値だけを返す
 func calculateDiff(before, after map[string]interface{}) map[string]interface{} {
-	diff := make(map[string]interface{})
-
-	// Before にあって After にないキー（削除）
-	for key, beforeVal := range before {
-		if afterVal, exists := after[key]; !exists {
-			diff[key] = map[string]interface{}{
-				"type":   "deleted",
-				"before": beforeVal,
-			}
-		} else if !equal(beforeVal, afterVal) {
-			diff[key] = map[string]interface{}{
-				"type":   "modified",
-				"before": beforeVal,
-				"after":  afterVal,
-			}
-		}
-	}
-
-	// After にあって Before にないキー（追加）
-	for key, afterVal := range after {
-		if _, exists := before[key]; !exists {
-			diff[key] = map[string]interface{}{
-				"type":  "added",
-				"after": afterVal,
-			}
-		}
-	}
-
-	return diff
-}
-
-// equal は2つの値が等しいかを判定（簡易版）
-func equal(a, b interface{}) bool {
-	// TODO: より詳細な比較ロジックを実装
-	aJSON, _ := json.Marshal(a)
-	bJSON, _ := json.Marshal(b)
-	return string(aJSON) == string(bJSON)
-}
-
-// calculateSeverity は drift の深刻度を計算
-func calculateSeverity(driftType, resourceType string) types.Severity {
-	// セキュリティ関連リソースの変更は高い深刻度
-	securityResources := map[string]bool{
-		"security_group": true,
-		"iam_role":       true,
-		"iam_policy":     true,
-		"kms_key":        true,
-	}
-
-	if securityResources[resourceType] {
-		if driftType == string(types.DriftDeleted) {
-			return types.SeverityCritical
-		}
-		return types.SeverityHigh
-	}
-
-	// 削除は一般的に高い深刻度
-	if driftType == string(types.DriftDeleted) {
-		return types.SeverityHigh
-	}
-
-	// 変更は中程度
-	if driftType == string(types.DriftModified) {
-		return types.SeverityMedium
-	}
-
-	// 作成は低い深刻度
-	return types.SeverityLow
+	return drift.DiffMap(drift.Diff(before, after))
 }
 
 // WatchDrift は継続的に drift を監視（デーモンモード）
+// 同じ drift は一度だけ通知し、callback には新しい drift と解消された drift（Status が resolved）を渡す
 func (a *TFDriftAdapter) WatchDrift(ctx context.Context, stateFile string, interval time.Duration, callback func([]*types.DriftEvent) error) error {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	tracker := drift.NewTracker()
 	for {
 		select {
 		case <-ctx.Done():
@@ -224,10 +182,14 @@ func (a *TFDriftAdapter) WatchDrift(ctx context.Context, stateFile string, inter
 				continue
 			}
 
-			if len(events) > 0 {
-				if err := callback(events); err != nil {
-					fmt.Fprintf(os.Stderr, "callback failed: %v\n", err)
-				}
+			changes := tracker.Observe(events, nil)
+			if changes.IsEmpty() {
+				continue
+			}
+
+			notify := append(changes.Created, changes.Resolved...)
+			if err := callback(notify); err != nil {
+				fmt.Fprintf(os.Stderr, "callback failed: %v\n", err)
 			}
 		}
 	}
@@ -1,7 +1,6 @@
 package tfdrift
 
 import (
-	"encoding/json"
 	"testing"
 	"time"
 
@@ -111,6 +110,9 @@ func TestParseTFDriftOutput(t *testing.T) {
 	if e2.Severity != types.SeverityCritical {
 		t.Errorf("Expected severity 'critical' for deleted security group, got '%s'", e2.Severity)
 	}
+	if n := len(e2.SeverityRules); n == 0 || e2.SeverityRules[n-1].Name != "security-resource-deleted" {
+		t.Errorf("Expected the fired severity rules to be recorded, got %+v", e2.SeverityRules)
+	}
 
 	// Test third event (created S3)
 	e3 := events[2]
@@ -130,74 +132,6 @@ func TestParseTFDriftOutput(t *testing.T) {
 	t.Logf("Successfully parsed %d drift events", len(events))
 }
 
-func TestCalculateSeverity(t *testing.T) {
-	tests := []struct {
-		name         string
-		driftType    string
-		resourceType string
-		expected     types.Severity
-	}{
-		{
-			name:         "deleted security group",
-			driftType:    string(types.DriftDeleted),
-			resourceType: "security_group",
-			expected:     types.SeverityCritical,
-		},
-		{
-			name:         "modified security group",
-			driftType:    string(types.DriftModified),
-			resourceType: "security_group",
-			expected:     types.SeverityHigh,
-		},
-		{
-			name:         "deleted IAM role",
-			driftType:    string(types.DriftDeleted),
-			resourceType: "iam_role",
-			expected:     types.SeverityCritical,
-		},
-		{
-			name:         "modified IAM policy",
-			driftType:    string(types.DriftModified),
-			resourceType: "iam_policy",
-			expected:     types.SeverityHigh,
-		},
-		{
-			name:         "deleted EC2",
-			driftType:    string(types.DriftDeleted),
-			resourceType: "ec2",
-			expected:     types.SeverityHigh,
-		},
-		{
-			name:         "modified EC2",
-			driftType:    string(types.DriftModified),
-			resourceType: "ec2",
-			expected:     types.SeverityMedium,
-		},
-		{
-			name:         "created S3 bucket",
-			driftType:    string(types.DriftCreated),
-			resourceType: "s3",
-			expected:     types.SeverityLow,
-		},
-		{
-			name:         "created VPC",
-			driftType:    string(types.DriftCreated),
-			resourceType: "vpc",
-			expected:     types.SeverityLow,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := calculateSeverity(tt.driftType, tt.resourceType)
-			if result != tt.expected {
-				t.Errorf("calculateSeverity(%s, %s) = %s; want %s",
-					tt.driftType, tt.resourceType, result, tt.expected)
-			}
-		})
-	}
-}
-
 func TestCalculateDiff(t *testing.T) {
 	before := map[string]interface{}{
 		"instance_type": "t3.micro",
@@ -235,9 +169,12 @@ func TestCalculateDiff(t *testing.T) {
 		t.Error("Expected 'monitoring' in diff")
 	}
 
-	// tags が変更されたことを確認
-	if _, exists := diff["tags"]; !exists {
-		t.Error("Expected 'tags' in diff")
+	// tags は変更されたキーだけが差分になる
+	if _, exists := diff["tags.Env"]; !exists {
+		t.Error("Expected 'tags.Env' in diff")
+	}
+	if _, exists := diff["tags.Name"]; exists {
+		t.Error("Unexpected 'tags.Name' in diff")
 	}
 
 	// backup が追加されたことを確認
@@ -253,75 +190,29 @@ func TestCalculateDiff(t *testing.T) {
 	t.Logf("Diff calculated: %d changes", len(diff))
 }
 
-func TestEqual(t *testing.T) {
-	tests := []struct {
-		name     string
-		a        interface{}
-		b        interface{}
-		expected bool
-	}{
-		{
-			name:     "equal strings",
-			a:        "hello",
-			b:        "hello",
-			expected: true,
-		},
-		{
-			name:     "different strings",
-			a:        "hello",
-			b:        "world",
-			expected: false,
-		},
-		{
-			name:     "equal numbers",
-			a:        42,
-			b:        42,
-			expected: true,
-		},
-		{
-			name:     "different numbers",
-			a:        42,
-			b:        43,
-			expected: false,
-		},
-		{
-			name:     "equal booleans",
-			a:        true,
-			b:        true,
-			expected: true,
-		},
-		{
-			name: "equal maps",
-			a: map[string]interface{}{
-				"key": "value",
-			},
-			b: map[string]interface{}{
-				"key": "value",
-			},
-			expected: true,
+func TestCalculateDiff_SecurityGroupRules(t *testing.T) {
+	before := map[string]interface{}{
+		"ingress": []interface{}{
+			map[string]interface{}{"protocol": "tcp", "from_port": float64(443), "to_port": float64(443), "cidr_blocks": []interface{}{"0.0.0.0/0"}},
+			map[string]interface{}{"protocol": "tcp", "from_port": float64(22), "to_port": float64(22), "cidr_blocks": []interface{}{"10.0.0.0/8"}},
 		},
-		{
-			name: "different maps",
-			a: map[string]interface{}{
-				"key": "value1",
-			},
-			b: map[string]interface{}{
-				"key": "value2",
-			},
-			expected: false,
+	}
+	after := map[string]interface{}{
+		"ingress": []interface{}{
+			map[string]interface{}{"protocol": "tcp", "from_port": float64(22), "to_port": float64(22), "cidr_blocks": []interface{}{"0.0.0.0/0"}},
+			map[string]interface{}{"protocol": "6", "from_port": "443", "to_port": "443", "cidr_blocks": []interface{}{"0.0.0.0/0"}},
 		},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := equal(tt.a, tt.b)
-			if result != tt.expected {
-				aJSON, _ := json.Marshal(tt.a)
-				bJSON, _ := json.Marshal(tt.b)
-				t.Errorf("equal(%s, %s) = %v; want %v",
-					string(aJSON), string(bJSON), result, tt.expected)
-			}
-		})
+	diff := calculateDiff(before, after)
+
+	// 443 のルールは順序・型が違うだけなので差分にならず、22 のルールの CIDR だけが変わる
+	if len(diff) != 1 {
+		t.Fatalf("Expected 1 change, got %v", diff)
+	}
+	change, ok := diff["ingress[1].cidr_blocks[0]"].(map[string]interface{})
+	if !ok || change["type"] != "modified" || change["before"] != "10.0.0.0/8" || change["after"] != "0.0.0.0/0" {
+		t.Errorf("Unexpected diff: %v", diff)
 	}
 }
 
@@ -405,3 +296,41 @@ func TestParseTFDriftOutput_CloudTrailTimestamp(t *testing.T) {
 			expectedTime, events[0].RootCause.Timestamp)
 	}
 }
+
+func TestParseTFDriftOutput_StableIDs(t *testing.T) {
+	adapter := NewTFDriftAdapter("", "")
+	output := func(instanceType string) []byte {
+		return []byte(`{"drifts": [{
+			"resource_id": "aws:ec2:i-123456", "resource_type": "ec2", "type": "modified",
+			"before": {"instance_type": "t3.micro", "tags": {"Name": "web", "Env": "prod"}},
+			"after": {"instance_type": "` + instanceType + `", "tags": {"Env": "prod", "Name": "web"}}
+		}]}`)
+	}
+
+	first, err := adapter.parseTFDriftOutput(output("t3.large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	second, err := adapter.parseTFDriftOutput(output("t3.large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 同じ drift は検出時刻が違っても同じ ID
+	if first[0].ID != second[0].ID {
+		t.Errorf("Expected the same ID for the same drift, got %s and %s", first[0].ID, second[0].ID)
+	}
+	if first[0].Status != types.DriftOpen {
+		t.Errorf("Expected open status, got %s", first[0].Status)
+	}
+
+	// 値がさらに変わると別の drift
+	changed, err := adapter.parseTFDriftOutput(output("t3.xlarge"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed[0].ID == first[0].ID {
+		t.Error("Expected a different ID when the drifted value changes")
+	}
+}
@@ -1,6 +1,11 @@
 package types
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
 
 // DriftType は drift の種類を表す
 type DriftType string
@@ -11,6 +16,25 @@ const (
 	DriftDeleted  DriftType = "deleted"
 )
 
+// DriftStatus は drift イベントの対応状況を表す
+type DriftStatus string
+
+const (
+	// DriftOpen は drift が続いていて未対応
+	DriftOpen DriftStatus = "open"
+
+	// DriftAcknowledged は drift が続いているが確認済み
+	DriftAcknowledged DriftStatus = "acknowledged"
+
+	// DriftResolved は drift が解消された（または手動で解決済みにされた）
+	DriftResolved DriftStatus = "resolved"
+)
+
+// IsValid は既知の status かを判定
+func (s DriftStatus) IsValid() bool {
+	return s == DriftOpen || s == DriftAcknowledged || s == DriftResolved
+}
+
 // DriftEvent は drift イベントを表す
 type DriftEvent struct {
 	// ID はイベントの一意識別子
@@ -22,6 +46,12 @@ type DriftEvent struct {
 	// ResourceType はリソースタイプ (e.g., "ec2", "vpc", "security_group")
 	ResourceType string `json:"resource_type"`
 
+	// TerraformAddress は Terraform のリソースアドレス (e.g., "module.app.aws_instance.web[0]")
+	TerraformAddress string `json:"terraform_address,omitempty"`
+
+	// Workspace は drift を検出した Terraform ワークスペースの名前
+	Workspace string `json:"workspace,omitempty"`
+
 	// Type は drift のタイプ (created, modified, deleted)
 	Type DriftType `json:"type"`
 
@@ -40,11 +70,77 @@ type DriftEvent struct {
 	// RootCause は原因 (CloudTrail event ID, IAM user, etc.)
 	RootCause *RootCause `json:"root_cause,omitempty"`
 
+	// RootCauseCandidates は CloudTrail から特定した原因の候補（確度の高い順、先頭が RootCause）
+	RootCauseCandidates []RootCause `json:"root_cause_candidates,omitempty"`
+
 	// ImpactedResources はこの drift により影響を受けるリソースのリスト
 	ImpactedResources []string `json:"impacted_resources,omitempty"`
 
 	// Severity は drift の深刻度
 	Severity Severity `json:"severity"`
+
+	// SeverityRules は Severity を決めたルール（一致した順）
+	SeverityRules []SeverityRule `json:"severity_rules,omitempty"`
+
+	// Status は対応状況 (open, acknowledged, resolved)
+	Status DriftStatus `json:"status,omitempty"`
+
+	// FirstSeen は drift を最初に検出した時刻
+	FirstSeen time.Time `json:"first_seen"`
+
+	// LastSeen は drift を最後に検出した時刻
+	LastSeen time.Time `json:"last_seen"`
+
+	// ResolvedAt は drift が解消された時刻
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+
+	// AcknowledgedBy は drift を確認済みにしたユーザー
+	AcknowledgedBy string `json:"acknowledged_by,omitempty"`
+
+	// AcknowledgedAt は drift を確認済みにした時刻
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+}
+
+// Fingerprint は drift の内容（リソース・drift の種類・変化した属性とその値）から決まるハッシュ
+// 同じ drift が続いている間は検出のたびに同じ値になり、値がさらに変わると別の値になる
+func (e *DriftEvent) Fingerprint() string {
+	// map のキーはソートされてエンコードされるため、同じ内容は同じ JSON になる
+	content, _ := json.Marshal(struct {
+		ResourceID string                 `json:"resource_id"`
+		Type       DriftType              `json:"type"`
+		Diff       map[string]interface{} `json:"diff"`
+	}{e.ResourceID, e.Type, e.Diff})
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// ResourceTags は drift のリソースのタグを返す
+// 変更前・変更後の tags_all と tags をまとめ、同じキーは変更後の値を使う
+func (e *DriftEvent) ResourceTags() map[string]string {
+	tags := make(map[string]string)
+	for _, state := range []map[string]interface{}{e.Before, e.After} {
+		for _, key := range []string{"tags_all", "tags"} {
+			switch m := state[key].(type) {
+			case map[string]string:
+				for k, v := range m {
+					tags[k] = v
+				}
+			case map[string]interface{}:
+				for k, v := range m {
+					if s, ok := v.(string); ok {
+						tags[k] = s
+					}
+				}
+			}
+		}
+	}
+	return tags
+}
+
+// DriftEventID は Fingerprint から決まる drift イベントの ID を返す
+func DriftEventID(e *DriftEvent) string {
+	return "drift-" + e.Fingerprint()[:20]
 }
 
 // RootCause は drift の根本原因を表す
@@ -66,6 +162,12 @@ type RootCause struct {
 
 	// Timestamp はイベント発生時刻
 	Timestamp time.Time `json:"timestamp,omitempty"`
+
+	// Confidence は CloudTrail から特定した場合の確度 (0〜1)
+	Confidence float64 `json:"confidence,omitempty"`
+
+	// Reasons は確度の根拠（リソースの一致、drift と操作の対応など）
+	Reasons []string `json:"reasons,omitempty"`
 }
 
 // Severity は drift の深刻度を表す
@@ -78,6 +180,18 @@ const (
 	SeverityCritical Severity = "critical"
 )
 
+// SeverityRule は深刻度の計算で一致したルールを表す
+type SeverityRule struct {
+	// Name はルール名
+	Name string `json:"name"`
+
+	// Description はルールの説明
+	Description string `json:"description,omitempty"`
+
+	// Severity はルールを適用した後の深刻度
+	Severity Severity `json:"severity"`
+}
+
 // ImpactAnalysisResult はインパクト分析の結果を表す
 type ImpactAnalysisResult struct {
 	// DriftEventID は対象の drift イベント ID
@@ -92,11 +206,20 @@ type ImpactAnalysisResult struct {
 	// BlastRadius は影響範囲 (グラフ内のホップ数)
 	BlastRadius int `json:"blast_radius"`
 
+	// ImpactScore は影響を受けるリソースの ImpactScore の合計（エッジの重みを反映した影響範囲）
+	ImpactScore float64 `json:"impact_score"`
+
 	// Recommendations は推奨アクション
 	Recommendations []string `json:"recommendations"`
 
 	// Severity は全体の深刻度
 	Severity Severity `json:"severity"`
+
+	// SeverityRules は Severity を決めたルール（drift 自体の深刻度を決めたルールを含む）
+	SeverityRules []SeverityRule `json:"severity_rules,omitempty"`
+
+	// AnalyzedAt は分析結果を保存した時刻（ストレージから読んだ場合のみで、それ以外はゼロ値）
+	AnalyzedAt time.Time `json:"analyzed_at"`
 }
 
 // AffectedResource は影響を受けるリソースを表す
@@ -115,4 +238,31 @@ type AffectedResource struct {
 
 	// ImpactDescription は影響の説明
 	ImpactDescription string `json:"impact_description"`
+
+	// ImpactScore は影響の強さ (0〜1)。経路上のエッジの重みの積で、drift のリソースから離れるほど小さくなる
+	ImpactScore float64 `json:"impact_score"`
+
+	// PropagationPath は drift のリソースからこのリソースまで影響が伝わった経路
+	PropagationPath []PropagationStep `json:"propagation_path,omitempty"`
+}
+
+// PropagationStep は影響が伝わった1ホップを表す
+type PropagationStep struct {
+	// From は影響元のリソース ID
+	From string `json:"from"`
+
+	// To は影響先のリソース ID
+	To string `json:"to"`
+
+	// RelationType はエッジの関係タイプ (e.g., "ownership", "dependency", "network")
+	RelationType string `json:"relation_type"`
+
+	// Direction は影響が伝わった向き (forward: エッジの From → To, reverse: To → From)
+	Direction string `json:"direction"`
+
+	// Reason は伝わった理由 (e.g., "owned by the affected parent")
+	Reason string `json:"reason"`
+
+	// Weight はこのホップで伝わった影響の割合 (0〜1)
+	Weight float64 `json:"weight"`
 }
@@ -1,5 +1,11 @@
 import axios from 'axios';
-import type { AxiosInstance } from 'axios';
+import type { AxiosError, AxiosInstance, InternalAxiosRequestConfig } from 'axios';
+
+// Local storage key of the API token (servers started with --auth)
+const TOKEN_KEY = 'deepdrift_token';
+
+// A request retried once with a new token after a 401
+type RetriedRequestConfig = InternalAxiosRequestConfig & { _retried?: boolean };
 
 export interface DriftEvent {
   id: string;
@@ -20,6 +26,18 @@ export interface ImpactAnalysisResult {
   blast_radius: number;
   severity: 'low' | 'medium' | 'high' | 'critical';
   recommendations: string[];
+  analyzed_at?: string;
+}
+
+export interface StreamEvent {
+  id?: number;
+  type: 'drift' | 'impact' | 'reset';
+  time: string;
+  resource_id?: string;
+  resource_type?: string;
+  severity?: string;
+  drift?: DriftEvent;
+  impact?: ImpactAnalysisResult;
 }
 
 export interface DriftStats {
@@ -59,8 +77,41 @@ export interface Resource {
   updated_at: string;
 }
 
+export type OverlayStatus = 'in_sync' | 'drifted' | 'unmanaged' | 'missing';
+
+export interface OverlayNode extends Resource {
+  status: OverlayStatus;
+  terraform_address?: string;
+  workspace?: string;
+  drift_event_ids?: string[];
+  severity?: DriftEvent['severity'];
+}
+
+export interface OverlayEdge {
+  from: string;
+  to: string;
+  type: string;
+  metadata?: Record<string, any>;
+  status: OverlayStatus;
+  drift_event_ids?: string[];
+  severity?: DriftEvent['severity'];
+}
+
+export interface GraphOverlay {
+  nodes: OverlayNode[];
+  edges: OverlayEdge[];
+  summary: {
+    nodes: Record<OverlayStatus, number>;
+    edges: Record<OverlayStatus, number>;
+  };
+  workspaces?: string[];
+  generated_at: string;
+}
+
 class APIClient {
   private client: AxiosInstance;
+  private token: string | null = localStorage.getItem(TOKEN_KEY);
+  private tokenPrompt: Promise<string | null> | null = null;
 
   constructor(baseURL: string = '') {
     // Use empty baseURL for relative paths (works with Vite proxy in dev mode)
@@ -72,6 +123,55 @@ class APIClient {
         'Content-Type': 'application/json',
       },
     });
+
+    this.client.interceptors.request.use((config) => {
+      if (this.token) {
+        config.headers.Authorization = `Bearer ${this.token}`;
+      }
+      return config;
+    });
+
+    // When the server requires authentication, ask for a token and retry once
+    this.client.interceptors.response.use(undefined, async (error: AxiosError) => {
+      const config = error.config as RetriedRequestConfig | undefined;
+      if (error.response?.status !== 401 || !config || config._retried) {
+        throw error;
+      }
+      const token = await this.promptToken();
+      if (!token) {
+        throw error;
+      }
+      config._retried = true;
+      return this.client.request(config);
+    });
+  }
+
+  // Set the API token sent with every request (null forgets it)
+  setToken(token: string | null) {
+    this.token = token;
+    if (token) {
+      localStorage.setItem(TOKEN_KEY, token);
+    } else {
+      localStorage.removeItem(TOKEN_KEY);
+    }
+  }
+
+  // Ask for a token once for all the requests rejected at the same time
+  private promptToken(): Promise<string | null> {
+    if (!this.tokenPrompt) {
+      this.tokenPrompt = Promise.resolve().then(() => {
+        const token = window.prompt('DeepDrift API token')?.trim() || null;
+        this.setToken(token);
+        this.tokenPrompt = null;
+        return token;
+      });
+    }
+    return this.tokenPrompt;
+  }
+
+  // Query parameter with the token, for URLs the browser opens without our headers
+  private tokenQuery(): string {
+    return this.token ? `&access_token=${encodeURIComponent(this.token)}` : '';
   }
 
   // Health check
@@ -89,7 +189,10 @@ class APIClient {
     user_identity?: string;
     start_time?: string;
     end_time?: string;
-  }): Promise<{ drifts: DriftEvent[]; count: number }> {
+    q?: string;
+    sort?: 'newest' | 'oldest' | 'severity';
+    cursor?: string;
+  }): Promise<{ drifts: DriftEvent[]; count: number; next_cursor: string }> {
     const response = await this.client.get('/api/v1/drifts', { params });
     return response.data;
   }
@@ -114,7 +217,10 @@ class APIClient {
     min_affected_resources?: number;
     start_time?: string;
     end_time?: string;
-  }): Promise<{ results: ImpactAnalysisResult[]; count: number }> {
+    q?: string;
+    sort?: 'newest' | 'oldest' | 'severity';
+    cursor?: string;
+  }): Promise<{ results: ImpactAnalysisResult[]; count: number; next_cursor: string }> {
     const response = await this.client.get('/api/v1/impact', { params });
     return response.data;
   }
@@ -163,6 +269,42 @@ class APIClient {
     const response = await this.client.get('/api/v1/graph/intended');
     return response.data;
   }
+
+  async getGraphOverlay(): Promise<GraphOverlay> {
+    const response = await this.client.get('/api/v1/graph/overlay');
+    return response.data;
+  }
+
+  // URL of the overlay as a downloadable file (Mermaid flowchart or JSON)
+  getGraphOverlayDownloadURL(format: 'json' | 'mermaid' = 'json'): string {
+    return `${this.client.defaults.baseURL || ''}/api/v1/graph/overlay?format=${format}&download=true${this.tokenQuery()}`;
+  }
+
+  // Real-time events (Server-Sent Events)
+  // EventSource reconnects on its own and resumes after the last event it received.
+  // A 'reset' event means events were missed and lists should be reloaded.
+  // EventSource can't send headers, so the token goes in the access_token parameter.
+  streamEvents(
+    params: {
+      type?: string;
+      severity?: string;
+      resource_type?: string;
+      resource_id_prefix?: string;
+    },
+    onEvent: (event: StreamEvent) => void,
+  ): () => void {
+    const query = new URLSearchParams();
+    Object.entries(params).forEach(([key, value]) => {
+      if (value) query.set(key, value);
+    });
+    if (this.token) query.set('access_token', this.token);
+
+    const baseURL = this.client.defaults.baseURL || '';
+    const source = new EventSource(`${baseURL}/api/v1/stream?${query}`);
+    const handle = (message: MessageEvent) => onEvent(JSON.parse(message.data));
+    ['drift', 'impact', 'reset'].forEach((type) => source.addEventListener(type, handle));
+    return () => source.close();
+  }
 }
 
 // Export singleton instance
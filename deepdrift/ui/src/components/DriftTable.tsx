@@ -29,10 +29,20 @@ const DriftTable: React.FC = () => {
 
   useEffect(() => {
     loadDrifts();
-    const interval = setInterval(() => {
-      loadDrifts();
-    }, 30000); // Update every 30 seconds
-    return () => clearInterval(interval);
+
+    // Receive drifts as they are saved instead of polling
+    const params: Record<string, string> = { type: 'drift' };
+    if (severityFilter) params.severity = severityFilter;
+    if (resourceTypeFilter) params.resource_type = resourceTypeFilter;
+    return apiClient.streamEvents(params, (event) => {
+      if (event.type === 'reset') {
+        loadDrifts();
+        return;
+      }
+      const drift = event.drift;
+      if (!drift || (typeFilter && drift.type !== typeFilter)) return;
+      setDrifts((prev) => [drift, ...prev.filter((d) => d.id !== drift.id)]);
+    });
   }, [severityFilter, typeFilter, resourceTypeFilter]);
 
   const loadDrifts = async () => {
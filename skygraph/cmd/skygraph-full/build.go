@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/builder"
+)
+
+// builderFlags はグラフ構築（エッジ推論ルール）のフラグ（scan と serve で共通）
+type builderFlags struct {
+	rulesFile    string
+	disableRules string
+}
+
+// registerBuilderFlags はグラフ構築関連のフラグを fs に登録
+func registerBuilderFlags(fs *flag.FlagSet) *builderFlags {
+	f := &builderFlags{}
+	fs.StringVar(&f.rulesFile, "rules", "", "YAML file with additional edge inference rules")
+	fs.StringVar(&f.disableRules, "disable-rules", "", "Edge inference rules to disable, comma-separated")
+	return f
+}
+
+// newBuilder はフラグに従ってルールを設定した GraphBuilder を作成
+func (f *builderFlags) newBuilder() (*builder.GraphBuilder, error) {
+	graphBuilder := builder.NewGraphBuilder()
+
+	if f.rulesFile != "" {
+		rules, err := builder.LoadRulesFile(f.rulesFile)
+		if err != nil {
+			return nil, err
+		}
+		graphBuilder.AddRule(rules...)
+	}
+
+	for _, name := range splitList(f.disableRules) {
+		if !graphBuilder.DisableRule(name) {
+			return nil, fmt.Errorf("unknown edge inference rule: %s (available: %v)", name, graphBuilder.Rules())
+		}
+	}
+
+	return graphBuilder, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+// runDiff は diff サブコマンドを実行
+// 2つのグラフスナップショットを比較して差分を出力する
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", "text", "Output format (text, json)")
+	outputFile := fs.String("output", "", "Output file path (default: stdout)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: skygraph diff [flags] <old.json> <new.json>\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("diff requires exactly two graph files")
+	}
+
+	// 出力ファイルを作る前に検証し、既存のファイルを空にしない
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown format: %s (available: text, json)", *format)
+	}
+
+	oldGraph, err := loadGraph(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", fs.Arg(0), err)
+	}
+
+	newGraph, err := loadGraph(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", fs.Arg(1), err)
+	}
+
+	diff := graph.Diff(oldGraph, newGraph)
+
+	if *outputFile == "" {
+		return writeDiff(os.Stdout, *format, fs.Arg(0), fs.Arg(1), diff)
+	}
+
+	f, err := os.Create(*outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	if err := writeDiff(f, *format, fs.Arg(0), fs.Arg(1), diff); err != nil {
+		f.Close()
+		return err
+	}
+	// 書き込みの失敗は Close で初めて分かることがある
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
+}
+
+// writeDiff は差分を format の形式で書き出す
+func writeDiff(w io.Writer, format, oldPath, newPath string, diff *graph.GraphDiff) error {
+	if format == "text" {
+		return diff.WriteText(w)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]interface{}{
+		"old":     oldPath,
+		"new":     newPath,
+		"summary": diff.Summary(),
+		"diff":    diff,
+	})
+}
@@ -8,19 +8,35 @@ import (
 	"os"
 	"time"
 
-	"github.com/yourusername/airdig/skygraph/pkg/aws"
-	"github.com/yourusername/airdig/skygraph/pkg/builder"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
 )
 
 var (
-	provider = flag.String("provider", "aws", "Cloud provider (aws, gcp, azure, kubernetes)")
-	region   = flag.String("region", "us-east-1", "AWS region")
-	profile  = flag.String("profile", "default", "AWS profile")
-	output   = flag.String("output", "graph.json", "Output file path")
-	verbose  = flag.Bool("verbose", false, "Verbose output")
+	providers = registerProviderFlags(flag.CommandLine)
+	builds    = registerBuilderFlags(flag.CommandLine)
+	output    = flag.String("output", "graph.json", "Output file path")
+	verbose   = flag.Bool("verbose", false, "Verbose output")
 )
 
 func main() {
+	// サブコマンド
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			if err := runServe(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "diff":
+			if err := runDiff(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	flag.Parse()
 
 	fmt.Println("==============================================")
@@ -29,39 +45,27 @@ func main() {
 	fmt.Println("==============================================")
 	fmt.Println()
 
-	if *provider != "aws" {
-		fmt.Fprintf(os.Stderr, "Error: Only 'aws' provider is supported in v0.1.0\n")
-		os.Exit(1)
-	}
-
 	// コンテキスト作成（タイムアウト 5分）
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	fmt.Printf("Provider: %s\n", *provider)
-	fmt.Printf("Region: %s\n", *region)
-	fmt.Printf("Profile: %s\n", *profile)
+	providers.print()
 	fmt.Println()
 
-	// AWS スキャナーを作成
-	fmt.Println("Initializing AWS scanner...")
-	scanner, err := aws.NewAWSScanner(ctx, *region, *profile)
+	// スキャナーを作成
+	fmt.Println("Initializing scanners...")
+	scanners, err := providers.newScanners(ctx)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: Failed to create AWS scanner: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
 	// スキャン実行
-	fmt.Println("Scanning AWS resources...")
-	fmt.Println("  - VPC")
-	fmt.Println("  - Subnet")
-	fmt.Println("  - Security Group")
-	fmt.Println("  - EC2 Instances")
-	fmt.Println("  - RDS Instances")
+	fmt.Println("Scanning resources...")
 	fmt.Println()
 
 	startTime := time.Now()
-	result, err := scanner.ScanAll(ctx)
+	result, err := scanProviders(ctx, scanners)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: Scan failed: %v\n", err)
 		os.Exit(1)
@@ -79,7 +83,11 @@ func main() {
 
 	// グラフ構築
 	fmt.Println("Building graph...")
-	graphBuilder := builder.NewGraphBuilder()
+	graphBuilder, err := builds.newBuilder()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 	graphBuilder.AddNodes(result.Nodes)
 
 	if err := graphBuilder.InferEdges(); err != nil {
@@ -123,6 +131,17 @@ func main() {
 			fmt.Printf("  - %s: %d\n", edgeType, count)
 		}
 		fmt.Println()
+
+		// スキャナー別の取得統計
+		fmt.Println("Pages by scanner:")
+		for name, stats := range result.Stats {
+			status := "complete"
+			if !stats.Complete {
+				status = "partial"
+			}
+			fmt.Printf("  - %s: %d items in %d pages (%s)\n", name, stats.Items, stats.Pages, status)
+		}
+		fmt.Println()
 	}
 
 	// JSON エクスポート
@@ -150,3 +169,18 @@ func exportJSON(g interface{}, filename string) error {
 
 	return nil
 }
+
+// loadGraph はグラフを JSON ファイルから読み込む
+func loadGraph(filename string) (*graph.Graph, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var g graph.Graph
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	return &g, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/aws"
+	"github.com/higakikeita/airdig/skygraph/pkg/k8s"
+	"github.com/higakikeita/airdig/skygraph/pkg/scanner"
+)
+
+// providerScanner は1プロバイダー分の全リソースをスキャンする
+type providerScanner interface {
+	ScanAll(ctx context.Context) (*scanner.Result, error)
+}
+
+// providerFlags はスキャン対象プロバイダーのフラグ（scan と serve で共通）
+type providerFlags struct {
+	providers string
+
+	// AWS
+	region      string
+	profile     string
+	roles       string
+	externalID  string
+	concurrency int
+
+	// Kubernetes
+	kubeconfig  string
+	kubeContext string
+	cluster     string
+	namespaces  string
+}
+
+// registerProviderFlags はプロバイダー関連のフラグを fs に登録
+func registerProviderFlags(fs *flag.FlagSet) *providerFlags {
+	f := &providerFlags{}
+	fs.StringVar(&f.providers, "provider", "aws", "Cloud provider(s), comma-separated (aws, kubernetes)")
+	fs.StringVar(&f.region, "region", "us-east-1", "AWS region(s), comma-separated")
+	fs.StringVar(&f.profile, "profile", "default", "AWS profile")
+	fs.StringVar(&f.roles, "assume-role", "", "IAM role ARN(s) to assume for multi-account scans, comma-separated")
+	fs.StringVar(&f.externalID, "external-id", "", "External ID for assume-role")
+	fs.IntVar(&f.concurrency, "concurrency", aws.DefaultConcurrency, "Maximum number of concurrent scanners")
+	fs.StringVar(&f.kubeconfig, "kubeconfig", "", "Path to kubeconfig (default: $KUBECONFIG or ~/.kube/config)")
+	fs.StringVar(&f.kubeContext, "context", "", "Kubeconfig context (default: current-context)")
+	fs.StringVar(&f.cluster, "cluster", "", "Cluster name used in Kubernetes node IDs (default: from kubeconfig)")
+	fs.StringVar(&f.namespaces, "namespace", "", "Kubernetes namespace(s), comma-separated (default: all)")
+	return f
+}
+
+// print は設定を表示
+func (f *providerFlags) print() {
+	fmt.Printf("Provider: %s\n", f.providers)
+	for _, p := range splitList(f.providers) {
+		switch p {
+		case "aws":
+			fmt.Printf("Region: %s\n", f.region)
+			fmt.Printf("Profile: %s\n", f.profile)
+			if f.roles != "" {
+				fmt.Printf("Assume roles: %s\n", f.roles)
+			}
+		case "kubernetes":
+			if f.kubeContext != "" {
+				fmt.Printf("Context: %s\n", f.kubeContext)
+			}
+			if f.namespaces != "" {
+				fmt.Printf("Namespaces: %s\n", f.namespaces)
+			}
+		}
+	}
+}
+
+// newScanners はフラグで指定されたプロバイダーのスキャナーを作成
+func (f *providerFlags) newScanners(ctx context.Context) ([]providerScanner, error) {
+	providers := splitList(f.providers)
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no provider specified")
+	}
+
+	scanners := make([]providerScanner, 0, len(providers))
+	for _, p := range providers {
+		switch p {
+		case "aws":
+			awsScanner, err := aws.NewAWSScannerWithOptions(ctx, aws.Options{
+				Profile:     f.profile,
+				Regions:     splitList(f.region),
+				AssumeRoles: splitList(f.roles),
+				ExternalID:  f.externalID,
+				Concurrency: f.concurrency,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to create AWS scanner: %w", err)
+			}
+			scanners = append(scanners, awsScanner)
+
+		case "kubernetes", "k8s":
+			k8sScanner, err := k8s.NewK8sScannerFromKubeconfig(f.kubeconfig, f.kubeContext, f.cluster, splitList(f.namespaces))
+			if err != nil {
+				return nil, fmt.Errorf("failed to create Kubernetes scanner: %w", err)
+			}
+			scanners = append(scanners, k8sScanner)
+
+		default:
+			return nil, fmt.Errorf("unsupported provider: %s (available: aws, kubernetes)", p)
+		}
+	}
+
+	return scanners, nil
+}
+
+// scanProviders は全プロバイダーをスキャンして結果を統合
+func scanProviders(ctx context.Context, scanners []providerScanner) (*scanner.Result, error) {
+	result := &scanner.Result{}
+	for _, sc := range scanners {
+		r, err := sc.ScanAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result.Merge(r)
+	}
+	return result, nil
+}
+
+// splitList はカンマ区切りの文字列を分割（空要素は除く）
+func splitList(s string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/scanner"
+	"github.com/higakikeita/airdig/skygraph/pkg/server"
+)
+
+// runServe は serve サブコマンドを実行
+// 定期的にクラウドをスキャンし、最新のグラフを HTTP で提供する
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	host := fs.String("host", "0.0.0.0", "API server host")
+	port := fs.Int("port", 8001, "API server port")
+	interval := fs.Duration("interval", 5*time.Minute, "Scan interval (0 disables periodic scans)")
+	scanTimeout := fs.Duration("scan-timeout", 5*time.Minute, "Timeout for a single scan")
+	providers := registerProviderFlags(fs)
+	builds := registerBuilderFlags(fs)
+	graphFile := fs.String("graph", "", "Serve a graph JSON file instead of scanning")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	fmt.Println("==============================================")
+	fmt.Println("  SkyGraph - API Server")
+	fmt.Println("==============================================")
+	fmt.Println()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	config := &server.Config{
+		Host:        *host,
+		Port:        *port,
+		Interval:    *interval,
+		ScanTimeout: *scanTimeout,
+	}
+
+	var srv *server.Server
+
+	if *graphFile != "" {
+		g, err := loadGraph(*graphFile)
+		if err != nil {
+			return fmt.Errorf("failed to load graph: %w", err)
+		}
+
+		srv = server.NewServer(config, nil)
+		srv.SetGraph(g)
+		fmt.Printf("Serving graph from %s (%d nodes, %d edges)\n", *graphFile, g.NodeCount(), g.EdgeCount())
+	} else {
+		providers.print()
+		fmt.Printf("Interval: %s\n", *interval)
+
+		scanners, err := providers.newScanners(ctx)
+		if err != nil {
+			return err
+		}
+
+		// ルール設定の誤りは起動時に検出する
+		if _, err := builds.newBuilder(); err != nil {
+			return err
+		}
+
+		srv = server.NewServer(config, func(ctx context.Context) (*graph.Graph, *scanner.Result, error) {
+			return scanAndBuild(ctx, scanners, builds)
+		})
+
+		go srv.Run(ctx)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- srv.Start()
+	}()
+
+	fmt.Printf("✅ Server started on http://%s\n", srv.Addr())
+	fmt.Println()
+	fmt.Println("API Endpoints:")
+	fmt.Println("  GET  /health                    - Health check")
+	fmt.Println("  GET  /api/v1/graph              - Latest graph (filters: type, provider, region, account, tag)")
+	fmt.Println("  GET  /api/v1/nodes              - List nodes (filters: type, provider, region, account, tag, limit)")
+	fmt.Println("  GET  /api/v1/nodes/{id}         - Get node and its edges")
+	fmt.Println("  GET  /api/v1/scan/status        - Scan status")
+	fmt.Println()
+
+	select {
+	case <-ctx.Done():
+		fmt.Println("\nReceived shutdown signal, gracefully shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	case err := <-errChan:
+		return fmt.Errorf("server error: %w", err)
+	}
+}
+
+// scanAndBuild は全プロバイダーをスキャンしてエッジ推論済みのグラフを構築
+func scanAndBuild(ctx context.Context, scanners []providerScanner, builds *builderFlags) (*graph.Graph, *scanner.Result, error) {
+	result, err := scanProviders(ctx, scanners)
+	if err != nil {
+		return nil, nil, fmt.Errorf("scan failed: %w", err)
+	}
+
+	graphBuilder, err := builds.newBuilder()
+	if err != nil {
+		return nil, result, err
+	}
+	graphBuilder.AddNodes(result.Nodes)
+
+	if err := graphBuilder.InferEdges(); err != nil {
+		return nil, result, fmt.Errorf("failed to infer edges: %w", err)
+	}
+
+	return graphBuilder.Build(), result, nil
+}
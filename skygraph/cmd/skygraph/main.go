@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/yourusername/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
 )
 
 func main() {
@@ -5,21 +5,28 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
-	"github.com/yourusername/airdig/skygraph/pkg/graph"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/scanner"
 )
 
 // EC2Scanner は EC2 インスタンスをスキャン
 type EC2Scanner struct {
-	client *ec2.Client
-	region string
+	statsRecorder
+
+	client  ec2.DescribeInstancesAPIClient
+	account string
+	region  string
 }
 
 // NewEC2Scanner は新しい EC2 スキャナーを作成
-func NewEC2Scanner(client *ec2.Client, region string) *EC2Scanner {
+func NewEC2Scanner(client ec2.DescribeInstancesAPIClient, account, region string) *EC2Scanner {
 	return &EC2Scanner{
-		client: client,
-		region: region,
+		client:  client,
+		account: account,
+		region:  region,
 	}
 }
 
@@ -28,16 +35,41 @@ func (s *EC2Scanner) Name() string {
 	return "ec2"
 }
 
-// Scan は EC2 インスタンスをスキャン
+// Scan は EC2 インスタンスを全ページ分スキャン
+// 途中でエラーになった場合は、それまでに取得したノードとエラーを返す
 func (s *EC2Scanner) Scan(ctx context.Context) ([]graph.ResourceNode, error) {
-	result, err := s.client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to describe instances: %w", err)
+	paginator := ec2.NewDescribeInstancesPaginator(s.client, &ec2.DescribeInstancesInput{
+		MaxResults: aws.Int32(ec2PageSize),
+	})
+
+	nodes := make([]graph.ResourceNode, 0)
+	stats := scanner.ScanStats{}
+	defer func() { s.record(stats) }()
+
+	for paginator.HasMorePages() {
+		if err := ctx.Err(); err != nil {
+			return nodes, fmt.Errorf("scan interrupted after %d pages: %w", stats.Pages, err)
+		}
+
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nodes, fmt.Errorf("failed to describe instances (page %d): %w", stats.Pages+1, err)
+		}
+		stats.Pages++
+
+		nodes = append(nodes, s.convertReservations(result.Reservations)...)
+		stats.Items = len(nodes)
 	}
 
+	stats.Complete = true
+	return nodes, nil
+}
+
+// convertReservations は 1 ページ分のインスタンスを ResourceNode に変換
+func (s *EC2Scanner) convertReservations(reservations []types.Reservation) []graph.ResourceNode {
 	nodes := make([]graph.ResourceNode, 0)
 
-	for _, reservation := range result.Reservations {
+	for _, reservation := range reservations {
 		for _, instance := range reservation.Instances {
 			// Security Group IDs を抽出
 			sgIDs := make([]string, 0, len(instance.SecurityGroups))
@@ -48,22 +80,23 @@ func (s *EC2Scanner) Scan(ctx context.Context) ([]graph.ResourceNode, error) {
 			}
 
 			node := graph.ResourceNode{
-				ID:       fmt.Sprintf("aws:ec2:%s", *instance.InstanceId),
+				ID:       graph.AWSNodeID(s.account, s.region, "ec2", *instance.InstanceId),
 				Type:     "ec2",
 				Provider: "aws",
 				Region:   s.region,
+				Account:  s.account,
 				Name:     getNameTag(instance.Tags),
 				Metadata: map[string]interface{}{
-					"instance_id":      *instance.InstanceId,
-					"instance_type":    string(instance.InstanceType),
-					"state":            string(instance.State.Name),
-					"vpc_id":           getStringPtr(instance.VpcId),
-					"subnet_id":        getStringPtr(instance.SubnetId),
-					"private_ip":       getStringPtr(instance.PrivateIpAddress),
-					"public_ip":        getStringPtr(instance.PublicIpAddress),
+					"instance_id":       *instance.InstanceId,
+					"instance_type":     string(instance.InstanceType),
+					"state":             string(instance.State.Name),
+					"vpc_id":            getStringPtr(instance.VpcId),
+					"subnet_id":         getStringPtr(instance.SubnetId),
+					"private_ip":        getStringPtr(instance.PrivateIpAddress),
+					"public_ip":         getStringPtr(instance.PublicIpAddress),
 					"availability_zone": getStringPtr(instance.Placement.AvailabilityZone),
-					"security_groups":  sgIDs,
-					"ami_id":           getStringPtr(instance.ImageId),
+					"security_groups":   sgIDs,
+					"ami_id":            getStringPtr(instance.ImageId),
 				},
 				Tags:      convertTags(instance.Tags),
 				CreatedAt: getTimePtr(instance.LaunchTime),
@@ -74,5 +107,5 @@ func (s *EC2Scanner) Scan(ctx context.Context) ([]graph.ResourceNode, error) {
 		}
 	}
 
-	return nodes, nil
+	return nodes
 }
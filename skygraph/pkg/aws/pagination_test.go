@@ -0,0 +1,182 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// fakeVPCClient は NextToken でページングする DescribeVpcs のフェイク
+type fakeVPCClient struct {
+	pages   [][]ec2types.Vpc
+	failAt  int // このページ番号（0始まり）でエラーを返す。-1 で無効
+	calls   int
+	onCall  func(page int)
+	lastMax int32
+}
+
+func (c *fakeVPCClient) DescribeVpcs(ctx context.Context, input *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	page := 0
+	if input.NextToken != nil {
+		page, _ = strconv.Atoi(*input.NextToken)
+	}
+	c.calls++
+	c.lastMax = aws.ToInt32(input.MaxResults)
+	if c.onCall != nil {
+		c.onCall(page)
+	}
+	if page == c.failAt {
+		return nil, errors.New("throttled")
+	}
+
+	output := &ec2.DescribeVpcsOutput{Vpcs: c.pages[page]}
+	if page+1 < len(c.pages) {
+		output.NextToken = aws.String(strconv.Itoa(page + 1))
+	}
+	return output, nil
+}
+
+func testVPCPages(pageCount, perPage int) [][]ec2types.Vpc {
+	pages := make([][]ec2types.Vpc, pageCount)
+	for p := range pages {
+		for i := 0; i < perPage; i++ {
+			pages[p] = append(pages[p], ec2types.Vpc{
+				VpcId:         aws.String(fmt.Sprintf("vpc-%d-%d", p, i)),
+				CidrBlock:     aws.String("10.0.0.0/16"),
+				IsDefault:     aws.Bool(false),
+				DhcpOptionsId: aws.String("dopt-1"),
+			})
+		}
+	}
+	return pages
+}
+
+func TestVPCScanner_AllPages(t *testing.T) {
+	client := &fakeVPCClient{pages: testVPCPages(3, 2), failAt: -1}
+	s := NewVPCScanner(client, "", "us-east-1")
+
+	nodes, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(nodes) != 6 {
+		t.Errorf("Expected 6 nodes, got %d", len(nodes))
+	}
+	if client.calls != 3 {
+		t.Errorf("Expected 3 API calls, got %d", client.calls)
+	}
+	if client.lastMax != ec2PageSize {
+		t.Errorf("Expected MaxResults %d, got %d", ec2PageSize, client.lastMax)
+	}
+
+	stats := s.LastStats()
+	if stats.Pages != 3 || stats.Items != 6 || !stats.Complete {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestVPCScanner_PartialOnError(t *testing.T) {
+	client := &fakeVPCClient{pages: testVPCPages(3, 2), failAt: 2}
+	s := NewVPCScanner(client, "", "us-east-1")
+
+	nodes, err := s.Scan(context.Background())
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if len(nodes) != 4 {
+		t.Errorf("Expected 4 partial nodes, got %d", len(nodes))
+	}
+
+	stats := s.LastStats()
+	if stats.Pages != 2 || stats.Items != 4 || stats.Complete {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestVPCScanner_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &fakeVPCClient{pages: testVPCPages(5, 1), failAt: -1}
+	client.onCall = func(page int) {
+		if page == 1 {
+			cancel()
+		}
+	}
+	s := NewVPCScanner(client, "", "us-east-1")
+
+	nodes, err := s.Scan(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if client.calls != 2 {
+		t.Errorf("Expected scan to stop after 2 calls, got %d", client.calls)
+	}
+	if len(nodes) != 2 {
+		t.Errorf("Expected 2 partial nodes, got %d", len(nodes))
+	}
+	if s.LastStats().Complete {
+		t.Error("Expected incomplete stats after cancel")
+	}
+}
+
+// fakeRDSClient は Marker でページングする DescribeDBInstances のフェイク
+type fakeRDSClient struct {
+	pages [][]rdstypes.DBInstance
+}
+
+func (c *fakeRDSClient) DescribeDBInstances(ctx context.Context, input *rds.DescribeDBInstancesInput, optFns ...func(*rds.Options)) (*rds.DescribeDBInstancesOutput, error) {
+	page := 0
+	if input.Marker != nil {
+		page, _ = strconv.Atoi(*input.Marker)
+	}
+
+	output := &rds.DescribeDBInstancesOutput{DBInstances: c.pages[page]}
+	if page+1 < len(c.pages) {
+		output.Marker = aws.String(strconv.Itoa(page + 1))
+	}
+	return output, nil
+}
+
+func testDBInstance(id string) rdstypes.DBInstance {
+	return rdstypes.DBInstance{
+		DBInstanceIdentifier: aws.String(id),
+		Engine:               aws.String("postgres"),
+		EngineVersion:        aws.String("15.4"),
+		DBInstanceClass:      aws.String("db.t3.micro"),
+		AllocatedStorage:     aws.Int32(20),
+		DBInstanceStatus:     aws.String("available"),
+		Endpoint:             &rdstypes.Endpoint{Address: aws.String(id + ".example.com"), Port: aws.Int32(5432)},
+		MultiAZ:              aws.Bool(false),
+		PubliclyAccessible:   aws.Bool(false),
+	}
+}
+
+func TestRDSScanner_AllPages(t *testing.T) {
+	client := &fakeRDSClient{pages: [][]rdstypes.DBInstance{
+		{testDBInstance("db-1"), testDBInstance("db-2")},
+		{testDBInstance("db-3")},
+	}}
+	s := NewRDSScanner(client, "", "us-east-1")
+
+	nodes, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(nodes) != 3 || nodes[2].ID != "aws:rds:db-3" {
+		t.Errorf("Expected 3 nodes ending with db-3, got %+v", nodes)
+	}
+
+	stats := s.LastStats()
+	if stats.Pages != 2 || stats.Items != 3 || !stats.Complete {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
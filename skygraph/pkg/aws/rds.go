@@ -5,21 +5,27 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
-	"github.com/yourusername/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/scanner"
 )
 
 // RDSScanner は RDS インスタンスをスキャン
 type RDSScanner struct {
-	client *rds.Client
-	region string
+	statsRecorder
+
+	client  rds.DescribeDBInstancesAPIClient
+	account string
+	region  string
 }
 
 // NewRDSScanner は新しい RDS スキャナーを作成
-func NewRDSScanner(client *rds.Client, region string) *RDSScanner {
+func NewRDSScanner(client rds.DescribeDBInstancesAPIClient, account, region string) *RDSScanner {
 	return &RDSScanner{
-		client: client,
-		region: region,
+		client:  client,
+		account: account,
+		region:  region,
 	}
 }
 
@@ -28,66 +34,84 @@ func (s *RDSScanner) Name() string {
 	return "rds"
 }
 
-// Scan は RDS インスタンスをスキャン
+// Scan は RDS インスタンスを全ページ分スキャン
+// 途中でエラーになった場合は、それまでに取得したノードとエラーを返す
 func (s *RDSScanner) Scan(ctx context.Context) ([]graph.ResourceNode, error) {
-	result, err := s.client.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to describe RDS instances: %w", err)
-	}
+	paginator := rds.NewDescribeDBInstancesPaginator(s.client, &rds.DescribeDBInstancesInput{
+		MaxRecords: aws.Int32(rdsPageSize),
+	})
+
+	nodes := make([]graph.ResourceNode, 0)
+	stats := scanner.ScanStats{}
+	defer func() { s.record(stats) }()
 
-	nodes := make([]graph.ResourceNode, 0, len(result.DBInstances))
+	for paginator.HasMorePages() {
+		if err := ctx.Err(); err != nil {
+			return nodes, fmt.Errorf("scan interrupted after %d pages: %w", stats.Pages, err)
+		}
+
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nodes, fmt.Errorf("failed to describe RDS instances (page %d): %w", stats.Pages+1, err)
+		}
+		stats.Pages++
 
-	for _, db := range result.DBInstances {
-		// Subnet Group から VPC ID と Subnet IDs を取得
-		var vpcID string
-		subnetIDs := make([]string, 0)
+		for _, db := range result.DBInstances {
+			// Subnet Group から VPC ID と Subnet IDs を取得
+			var vpcID string
+			subnetIDs := make([]string, 0)
 
-		if db.DBSubnetGroup != nil {
-			vpcID = getStringPtr(db.DBSubnetGroup.VpcId)
-			for _, subnet := range db.DBSubnetGroup.Subnets {
-				if subnet.SubnetIdentifier != nil {
-					subnetIDs = append(subnetIDs, *subnet.SubnetIdentifier)
+			if db.DBSubnetGroup != nil {
+				vpcID = getStringPtr(db.DBSubnetGroup.VpcId)
+				for _, subnet := range db.DBSubnetGroup.Subnets {
+					if subnet.SubnetIdentifier != nil {
+						subnetIDs = append(subnetIDs, *subnet.SubnetIdentifier)
+					}
 				}
 			}
-		}
 
-		// Security Groups
-		sgIDs := make([]string, 0, len(db.VpcSecurityGroups))
-		for _, sg := range db.VpcSecurityGroups {
-			if sg.VpcSecurityGroupId != nil {
-				sgIDs = append(sgIDs, *sg.VpcSecurityGroupId)
+			// Security Groups
+			sgIDs := make([]string, 0, len(db.VpcSecurityGroups))
+			for _, sg := range db.VpcSecurityGroups {
+				if sg.VpcSecurityGroupId != nil {
+					sgIDs = append(sgIDs, *sg.VpcSecurityGroupId)
+				}
+			}
+
+			node := graph.ResourceNode{
+				ID:       graph.AWSNodeID(s.account, s.region, "rds", *db.DBInstanceIdentifier),
+				Type:     "rds",
+				Provider: "aws",
+				Region:   s.region,
+				Account:  s.account,
+				Name:     *db.DBInstanceIdentifier,
+				Metadata: map[string]interface{}{
+					"db_instance_id":      *db.DBInstanceIdentifier,
+					"engine":              *db.Engine,
+					"engine_version":      *db.EngineVersion,
+					"instance_class":      *db.DBInstanceClass,
+					"storage":             *db.AllocatedStorage,
+					"storage_type":        getStringPtr(db.StorageType),
+					"status":              *db.DBInstanceStatus,
+					"endpoint":            getStringPtr(db.Endpoint.Address),
+					"port":                getInt32Ptr(db.Endpoint.Port),
+					"vpc_id":              vpcID,
+					"subnet_ids":          subnetIDs,
+					"security_groups":     sgIDs,
+					"multi_az":            *db.MultiAZ,
+					"publicly_accessible": *db.PubliclyAccessible,
+				},
+				Tags:      convertRDSTags(db.TagList),
+				CreatedAt: getTimePtr(db.InstanceCreateTime),
+				UpdatedAt: time.Now(),
 			}
-		}
 
-		node := graph.ResourceNode{
-			ID:       fmt.Sprintf("aws:rds:%s", *db.DBInstanceIdentifier),
-			Type:     "rds",
-			Provider: "aws",
-			Region:   s.region,
-			Name:     *db.DBInstanceIdentifier,
-			Metadata: map[string]interface{}{
-				"db_instance_id":   *db.DBInstanceIdentifier,
-				"engine":           *db.Engine,
-				"engine_version":   *db.EngineVersion,
-				"instance_class":   *db.DBInstanceClass,
-				"storage":          *db.AllocatedStorage,
-				"storage_type":     getStringPtr(db.StorageType),
-				"status":           *db.DBInstanceStatus,
-				"endpoint":         getStringPtr(db.Endpoint.Address),
-				"port":             getInt32Ptr(db.Endpoint.Port),
-				"vpc_id":           vpcID,
-				"subnet_ids":       subnetIDs,
-				"security_groups":  sgIDs,
-				"multi_az":         *db.MultiAZ,
-				"publicly_accessible": *db.PubliclyAccessible,
-			},
-			Tags:      convertRDSTags(db.TagList),
-			CreatedAt: getTimePtr(db.InstanceCreateTime),
-			UpdatedAt: time.Now(),
+			nodes = append(nodes, node)
 		}
 
-		nodes = append(nodes, node)
+		stats.Items = len(nodes)
 	}
 
+	stats.Complete = true
 	return nodes, nil
 }
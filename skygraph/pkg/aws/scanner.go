@@ -5,76 +5,274 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
-	"github.com/yourusername/airdig/skygraph/pkg/graph"
-	"github.com/yourusername/airdig/skygraph/pkg/scanner"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/scanner"
 )
 
+// DefaultConcurrency は同時に実行するスキャナー数のデフォルト値
+const DefaultConcurrency = 8
+
+// Options は AWSScanner の設定
+type Options struct {
+	// Profile は AWS プロファイル名（AssumeRole の元の認証情報にも使う）
+	Profile string
+
+	// Regions はスキャン対象リージョン（最低1つ必要）
+	Regions []string
+
+	// AssumeRoles はスキャン対象アカウントで AssumeRole するロール ARN
+	// 空の場合はプロファイルの認証情報のアカウントのみをスキャンする
+	AssumeRoles []string
+
+	// ExternalID は AssumeRole 時に渡す External ID（オプション）
+	ExternalID string
+
+	// Concurrency は同時に実行するスキャナー数の上限（0 以下は DefaultConcurrency）
+	Concurrency int
+}
+
+// ec2API は EC2 系スキャナーが使う API
+type ec2API interface {
+	ec2.DescribeInstancesAPIClient
+	ec2.DescribeVpcsAPIClient
+	ec2.DescribeSubnetsAPIClient
+	ec2.DescribeSecurityGroupsAPIClient
+}
+
+// callerIdentityAPI は認証情報のアカウント ID を解決する API
+type callerIdentityAPI interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// account はスキャン対象の1アカウント
+type account struct {
+	// id はアカウント ID（空の場合はスキャン時に identity で解決する）
+	id string
+
+	// label はエラー表示用の識別子（ロール ARN またはプロファイル名）
+	label string
+
+	identity callerIdentityAPI
+	targets  []*target
+}
+
+// target はスキャン対象の1アカウント×1リージョン
+type target struct {
+	region    string
+	ec2Client ec2API
+	rdsClient rds.DescribeDBInstancesAPIClient
+}
+
 // AWSScanner は AWS リソースをスキャンする
+// 複数リージョン・複数アカウント（AssumeRole）にまたがってスキャンできる
 type AWSScanner struct {
-	region  string
-	profile string
+	profile     string
+	concurrency int
 
-	ec2Client *ec2.Client
-	rdsClient *rds.Client
+	mu       sync.Mutex
+	accounts []*account
 }
 
-// NewAWSScanner は新しい AWS スキャナーを作成
+// NewAWSScanner は単一リージョン・単一アカウントの AWS スキャナーを作成
 func NewAWSScanner(ctx context.Context, region, profile string) (*AWSScanner, error) {
+	return NewAWSScannerWithOptions(ctx, Options{
+		Profile: profile,
+		Regions: []string{region},
+	})
+}
+
+// NewAWSScannerWithOptions はリージョン・アカウントを指定して AWS スキャナーを作成
+func NewAWSScannerWithOptions(ctx context.Context, opts Options) (*AWSScanner, error) {
+	if len(opts.Regions) == 0 {
+		return nil, fmt.Errorf("at least one region is required")
+	}
+
 	// AWS 設定をロード
-	cfg, err := config.LoadDefaultConfig(ctx,
-		config.WithRegion(region),
-		config.WithSharedConfigProfile(profile),
+	baseCfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(opts.Regions[0]),
+		config.WithSharedConfigProfile(opts.Profile),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
-	return &AWSScanner{
-		region:    region,
-		profile:   profile,
-		ec2Client: ec2.NewFromConfig(cfg),
-		rdsClient: rds.NewFromConfig(cfg),
-	}, nil
+	s := &AWSScanner{
+		profile:     opts.Profile,
+		concurrency: opts.Concurrency,
+	}
+	if s.concurrency <= 0 {
+		s.concurrency = DefaultConcurrency
+	}
+
+	if len(opts.AssumeRoles) == 0 {
+		s.accounts = append(s.accounts, newAccount("", "profile "+opts.Profile, baseCfg, opts.Regions))
+		return s, nil
+	}
+
+	stsClient := sts.NewFromConfig(baseCfg)
+	for _, roleARN := range opts.AssumeRoles {
+		parsed, err := arn.Parse(roleARN)
+		if err != nil {
+			return nil, fmt.Errorf("invalid role ARN %q: %w", roleARN, err)
+		}
+
+		cfg := baseCfg.Copy()
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsClient, roleARN,
+			func(o *stscreds.AssumeRoleOptions) {
+				o.RoleSessionName = "skygraph"
+				if opts.ExternalID != "" {
+					o.ExternalID = aws.String(opts.ExternalID)
+				}
+			},
+		))
+
+		s.accounts = append(s.accounts, newAccount(parsed.AccountID, roleARN, cfg, opts.Regions))
+	}
+
+	return s, nil
+}
+
+// newAccount はリージョンごとのクライアントを持つ account を作成
+func newAccount(id, label string, cfg aws.Config, regions []string) *account {
+	acc := &account{
+		id:       id,
+		label:    label,
+		identity: sts.NewFromConfig(cfg),
+	}
+	for _, region := range regions {
+		regionCfg := cfg.Copy()
+		regionCfg.Region = region
+		acc.targets = append(acc.targets, &target{
+			region:    region,
+			ec2Client: ec2.NewFromConfig(regionCfg),
+			rdsClient: rds.NewFromConfig(regionCfg),
+		})
+	}
+	return acc
 }
 
-// ScanAll は全ての AWS リソースをスキャン
+// resolveAccountID はアカウント ID を返す（未解決なら GetCallerIdentity で解決してキャッシュ）
+func (s *AWSScanner) resolveAccountID(ctx context.Context, acc *account) (string, error) {
+	s.mu.Lock()
+	id := acc.id
+	s.mu.Unlock()
+	if id != "" {
+		return id, nil
+	}
+
+	output, err := acc.identity.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve account ID for %s: %w", acc.label, err)
+	}
+	if output.Account == nil || *output.Account == "" {
+		return "", fmt.Errorf("failed to resolve account ID for %s: empty account", acc.label)
+	}
+
+	s.mu.Lock()
+	acc.id = *output.Account
+	s.mu.Unlock()
+	return *output.Account, nil
+}
+
+// scannersFor は1アカウント×1リージョンのリソーススキャナーを作成
+func scannersFor(accountID string, t *target) []scanner.Scanner {
+	return []scanner.Scanner{
+		NewVPCScanner(t.ec2Client, accountID, t.region),
+		NewSubnetScanner(t.ec2Client, accountID, t.region),
+		NewSecurityGroupScanner(t.ec2Client, accountID, t.region),
+		NewEC2Scanner(t.ec2Client, accountID, t.region),
+		NewRDSScanner(t.rdsClient, accountID, t.region),
+	}
+}
+
+// accountScoped はノード ID にアカウントとリージョンを含めるかを判定
+// 複数のアカウントまたはリージョンにまたがるスキャンだけが対象で、単一アカウント・単一リージョンの
+// スキャンは従来どおり短い ID ("aws:<type>:<id>") のままにして既存のスナップショットや利用側との互換を保つ
+func (s *AWSScanner) accountScoped() bool {
+	return len(s.accounts) > 1 || (len(s.accounts) == 1 && len(s.accounts[0].targets) > 1)
+}
+
+// ScanAll は全てのアカウント・リージョンの AWS リソースをスキャン
+//
+// 複数のアカウントまたはリージョンにまたがる場合、ノード ID はアカウント・リージョン付きになり、
+// Errors と Stats のキーは "<account>/<region>/<scanner>" 形式になる。
+// アカウント ID を解決できなかった場合は "<ロール ARN またはプロファイル>" をキーにエラーを記録し、
+// そのアカウントはスキャンしない。
+// 単一アカウント・単一リージョンの場合はアカウント ID を解決せず、ノード ID は短い形式、
+// キーはスキャナー名になる。
 func (s *AWSScanner) ScanAll(ctx context.Context) (*scanner.Result, error) {
 	result := &scanner.Result{
 		Nodes:  make([]graph.ResourceNode, 0),
 		Errors: make(map[string]error),
-	}
-
-	// 各リソーススキャナーを並列実行
-	scanners := []scanner.Scanner{
-		NewVPCScanner(s.ec2Client, s.region),
-		NewSubnetScanner(s.ec2Client, s.region),
-		NewSecurityGroupScanner(s.ec2Client, s.region),
-		NewEC2Scanner(s.ec2Client, s.region),
-		NewRDSScanner(s.rdsClient, s.region),
+		Stats:  make(map[string]scanner.ScanStats),
 	}
 
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
-	for _, sc := range scanners {
-		wg.Add(1)
-		go func(scanner scanner.Scanner) {
-			defer wg.Done()
+	// 同時実行数を制限するセマフォ
+	sem := make(chan struct{}, s.concurrency)
+
+	scoped := s.accountScoped()
+	for _, acc := range s.accounts {
+		accountID := ""
+		if scoped {
+			id, err := s.resolveAccountID(ctx, acc)
+			if err != nil {
+				mu.Lock()
+				result.Errors[acc.label] = err
+				mu.Unlock()
+				continue
+			}
+			accountID = id
+		}
+
+		for _, t := range acc.targets {
+			prefix := ""
+			if scoped {
+				prefix = fmt.Sprintf("%s/%s/", accountID, t.region)
+			}
 
-			nodes, err := scanner.Scan(ctx)
+			// 各リソーススキャナーを並列実行
+			for _, sc := range scannersFor(accountID, t) {
+				wg.Add(1)
+				go func(key string, sc scanner.Scanner) {
+					defer wg.Done()
 
-			mu.Lock()
-			defer mu.Unlock()
+					select {
+					case sem <- struct{}{}:
+						defer func() { <-sem }()
+					case <-ctx.Done():
+						mu.Lock()
+						result.Errors[key] = ctx.Err()
+						mu.Unlock()
+						return
+					}
 
-			if err != nil {
-				result.Errors[scanner.Name()] = err
-			} else {
-				result.Nodes = append(result.Nodes, nodes...)
+					nodes, err := sc.Scan(ctx)
+
+					mu.Lock()
+					defer mu.Unlock()
+
+					// エラー時もそれまでに取得したノードは結果に含める
+					if err != nil {
+						result.Errors[key] = err
+					}
+					result.Nodes = append(result.Nodes, nodes...)
+
+					if reporter, ok := sc.(scanner.StatsReporter); ok {
+						result.Stats[key] = reporter.LastStats()
+					}
+				}(prefix+sc.Name(), sc)
 			}
-		}(sc)
+		}
 	}
 
 	wg.Wait()
@@ -0,0 +1,195 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// fakeEC2Client は VPC 以外は空を返す EC2 API のフェイク
+type fakeEC2Client struct {
+	fakeVPCClient
+	err error
+}
+
+func (c *fakeEC2Client) DescribeInstances(ctx context.Context, input *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &ec2.DescribeInstancesOutput{}, nil
+}
+
+func (c *fakeEC2Client) DescribeSubnets(ctx context.Context, input *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error) {
+	return &ec2.DescribeSubnetsOutput{}, nil
+}
+
+func (c *fakeEC2Client) DescribeSecurityGroups(ctx context.Context, input *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error) {
+	return &ec2.DescribeSecurityGroupsOutput{}, nil
+}
+
+// fakeIdentity は GetCallerIdentity のフェイク
+type fakeIdentity struct {
+	account string
+	calls   int
+}
+
+func (f *fakeIdentity) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	f.calls++
+	if f.account == "" {
+		return nil, errors.New("expired token")
+	}
+	return &sts.GetCallerIdentityOutput{Account: aws.String(f.account)}, nil
+}
+
+func newFakeTarget(region string, ec2Err error) *target {
+	return &target{
+		region: region,
+		ec2Client: &fakeEC2Client{
+			fakeVPCClient: fakeVPCClient{pages: testVPCPages(1, 1), failAt: -1},
+			err:           ec2Err,
+		},
+		rdsClient: &fakeRDSClient{pages: [][]rdstypes.DBInstance{{testDBInstance("db-1")}}},
+	}
+}
+
+func TestAWSScanner_ScanAll_MultiAccountMultiRegion(t *testing.T) {
+	base := &fakeIdentity{account: "111111111111"}
+	s := &AWSScanner{
+		concurrency: 2,
+		accounts: []*account{
+			{
+				label:    "profile default",
+				identity: base,
+				targets: []*target{
+					newFakeTarget("us-east-1", nil),
+					newFakeTarget("ap-northeast-1", nil),
+				},
+			},
+			{
+				id:    "222222222222",
+				label: "arn:aws:iam::222222222222:role/ReadOnly",
+				targets: []*target{
+					newFakeTarget("us-east-1", errors.New("access denied")),
+				},
+			},
+			{
+				label:    "arn:aws:iam::333333333333:role/Broken",
+				identity: &fakeIdentity{},
+			},
+		},
+	}
+
+	result, err := s.ScanAll(context.Background())
+	if err != nil {
+		t.Fatalf("ScanAll failed: %v", err)
+	}
+
+	// 3 ターゲット × (VPC 1 + RDS 1)。同じ vpc-0-0 / db-1 でも ID は衝突しない
+	if len(result.Nodes) != 6 {
+		t.Fatalf("Expected 6 nodes, got %d", len(result.Nodes))
+	}
+	ids := make(map[string]bool)
+	for _, node := range result.Nodes {
+		if ids[node.ID] {
+			t.Errorf("Duplicate node ID %s", node.ID)
+		}
+		ids[node.ID] = true
+		if node.Account == "" {
+			t.Errorf("Expected account on %s", node.ID)
+		}
+	}
+	for _, want := range []string{
+		"aws:111111111111:us-east-1:vpc:vpc-0-0",
+		"aws:111111111111:ap-northeast-1:rds:db-1",
+		"aws:222222222222:us-east-1:vpc:vpc-0-0",
+	} {
+		if !ids[want] {
+			t.Errorf("Expected node %s", want)
+		}
+	}
+
+	if _, ok := result.Errors["222222222222/us-east-1/ec2"]; !ok {
+		t.Errorf("Expected ec2 error for account 222222222222, got %v", result.Errors)
+	}
+	if _, ok := result.Errors["arn:aws:iam::333333333333:role/Broken"]; !ok {
+		t.Errorf("Expected account resolution error, got %v", result.Errors)
+	}
+	if len(result.Errors) != 2 {
+		t.Errorf("Expected 2 errors, got %v", result.Errors)
+	}
+
+	if stats := result.Stats["111111111111/ap-northeast-1/vpc"]; stats.Items != 1 || !stats.Complete {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// アカウント ID は一度解決したらキャッシュされる
+	if _, err := s.ScanAll(context.Background()); err != nil {
+		t.Fatalf("ScanAll failed: %v", err)
+	}
+	if base.calls != 1 {
+		t.Errorf("Expected account ID to be resolved once, got %d calls", base.calls)
+	}
+}
+
+func TestAWSScanner_ScanAll_SingleAccount(t *testing.T) {
+	identity := &fakeIdentity{account: "111111111111"}
+	s := &AWSScanner{
+		concurrency: 2,
+		accounts: []*account{
+			{label: "profile default", identity: identity, targets: []*target{newFakeTarget("us-east-1", nil)}},
+		},
+	}
+
+	result, err := s.ScanAll(context.Background())
+	if err != nil {
+		t.Fatalf("ScanAll failed: %v", err)
+	}
+
+	// 単一アカウント・単一リージョンは従来どおり短い ID で、アカウント ID も解決しない
+	ids := make(map[string]bool)
+	for _, node := range result.Nodes {
+		ids[node.ID] = true
+		if node.Account != "" {
+			t.Errorf("Expected no account on %s, got %s", node.ID, node.Account)
+		}
+	}
+	for _, want := range []string{"aws:vpc:vpc-0-0", "aws:rds:db-1"} {
+		if !ids[want] {
+			t.Errorf("Expected node %s, got %v", want, ids)
+		}
+	}
+	if identity.calls != 0 {
+		t.Errorf("Expected no account ID lookup, got %d calls", identity.calls)
+	}
+	if stats := result.Stats["vpc"]; stats.Items != 1 || !stats.Complete {
+		t.Errorf("Unexpected stats: %+v", result.Stats)
+	}
+}
+
+func TestAWSScanner_ScanAll_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s := &AWSScanner{
+		concurrency: 1,
+		accounts: []*account{
+			{id: "111111111111", targets: []*target{newFakeTarget("us-east-1", nil)}},
+		},
+	}
+
+	result, err := s.ScanAll(ctx)
+	if err != nil {
+		t.Fatalf("ScanAll failed: %v", err)
+	}
+	if len(result.Nodes) != 0 {
+		t.Errorf("Expected no nodes after cancel, got %d", len(result.Nodes))
+	}
+	if len(result.Errors) != 5 {
+		t.Errorf("Expected every scanner to report an error, got %v", result.Errors)
+	}
+}
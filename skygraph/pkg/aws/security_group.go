@@ -5,21 +5,28 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
-	"github.com/yourusername/airdig/skygraph/pkg/graph"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/scanner"
 )
 
 // SecurityGroupScanner は Security Group をスキャン
 type SecurityGroupScanner struct {
-	client *ec2.Client
-	region string
+	statsRecorder
+
+	client  ec2.DescribeSecurityGroupsAPIClient
+	account string
+	region  string
 }
 
 // NewSecurityGroupScanner は新しい Security Group スキャナーを作成
-func NewSecurityGroupScanner(client *ec2.Client, region string) *SecurityGroupScanner {
+func NewSecurityGroupScanner(client ec2.DescribeSecurityGroupsAPIClient, account, region string) *SecurityGroupScanner {
 	return &SecurityGroupScanner{
-		client: client,
-		region: region,
+		client:  client,
+		account: account,
+		region:  region,
 	}
 }
 
@@ -28,64 +35,118 @@ func (s *SecurityGroupScanner) Name() string {
 	return "security_group"
 }
 
-// Scan は Security Group をスキャン
+// Scan は Security Group を全ページ分スキャン
+// 途中でエラーになった場合は、それまでに取得したノードとエラーを返す
 func (s *SecurityGroupScanner) Scan(ctx context.Context) ([]graph.ResourceNode, error) {
-	result, err := s.client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to describe security groups: %w", err)
-	}
+	paginator := ec2.NewDescribeSecurityGroupsPaginator(s.client, &ec2.DescribeSecurityGroupsInput{
+		MaxResults: aws.Int32(ec2PageSize),
+	})
 
-	nodes := make([]graph.ResourceNode, 0, len(result.SecurityGroups))
+	nodes := make([]graph.ResourceNode, 0)
+	stats := scanner.ScanStats{}
+	defer func() { s.record(stats) }()
 
-	for _, sg := range result.SecurityGroups {
-		// Ingress rules を整形
-		ingressRules := make([]map[string]interface{}, 0, len(sg.IpPermissions))
-		for _, perm := range sg.IpPermissions {
-			rule := map[string]interface{}{
-				"protocol": getStringPtr(perm.IpProtocol),
-			}
-			if perm.FromPort != nil {
-				rule["from_port"] = *perm.FromPort
-			}
-			if perm.ToPort != nil {
-				rule["to_port"] = *perm.ToPort
+	for paginator.HasMorePages() {
+		if err := ctx.Err(); err != nil {
+			return nodes, fmt.Errorf("scan interrupted after %d pages: %w", stats.Pages, err)
+		}
+
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nodes, fmt.Errorf("failed to describe security groups (page %d): %w", stats.Pages+1, err)
+		}
+		stats.Pages++
+
+		for _, sg := range result.SecurityGroups {
+			node := graph.ResourceNode{
+				ID:       graph.AWSNodeID(s.account, s.region, "sg", *sg.GroupId),
+				Type:     "security_group",
+				Provider: "aws",
+				Region:   s.region,
+				Account:  s.account,
+				Name:     getStringPtr(sg.GroupName),
+				Metadata: map[string]interface{}{
+					"group_id":      *sg.GroupId,
+					"group_name":    *sg.GroupName,
+					"description":   getStringPtr(sg.Description),
+					"vpc_id":        getStringPtr(sg.VpcId),
+					"ingress_rules": convertPermissions(sg.IpPermissions),
+					"egress_rules":  convertPermissions(sg.IpPermissionsEgress),
+				},
+				Tags:      convertTags(sg.Tags),
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
 			}
 
-			// CIDR blocks
-			cidrs := make([]string, 0, len(perm.IpRanges))
-			for _, ipRange := range perm.IpRanges {
-				if ipRange.CidrIp != nil {
-					cidrs = append(cidrs, *ipRange.CidrIp)
-				}
+			nodes = append(nodes, node)
+		}
+
+		stats.Items = len(nodes)
+	}
+
+	stats.Complete = true
+	return nodes, nil
+}
+
+// convertPermissions は Ingress / Egress ルールを Metadata 用に整形
+// 相手（Ingress では送信元、Egress では宛先）が Security Group の場合は
+// security_groups にグループ ID を持つ
+func convertPermissions(perms []types.IpPermission) []map[string]interface{} {
+	rules := make([]map[string]interface{}, 0, len(perms))
+	for _, perm := range perms {
+		rule := map[string]interface{}{
+			"protocol": getStringPtr(perm.IpProtocol),
+		}
+		if perm.FromPort != nil {
+			rule["from_port"] = *perm.FromPort
+		}
+		if perm.ToPort != nil {
+			rule["to_port"] = *perm.ToPort
+		}
+
+		// CIDR blocks
+		cidrs := make([]string, 0, len(perm.IpRanges))
+		for _, ipRange := range perm.IpRanges {
+			if ipRange.CidrIp != nil {
+				cidrs = append(cidrs, *ipRange.CidrIp)
 			}
-			if len(cidrs) > 0 {
-				rule["cidr_blocks"] = cidrs
+		}
+		if len(cidrs) > 0 {
+			rule["cidr_blocks"] = cidrs
+		}
+
+		ipv6Cidrs := make([]string, 0, len(perm.Ipv6Ranges))
+		for _, ipRange := range perm.Ipv6Ranges {
+			if ipRange.CidrIpv6 != nil {
+				ipv6Cidrs = append(ipv6Cidrs, *ipRange.CidrIpv6)
 			}
+		}
+		if len(ipv6Cidrs) > 0 {
+			rule["ipv6_cidr_blocks"] = ipv6Cidrs
+		}
 
-			ingressRules = append(ingressRules, rule)
+		prefixLists := make([]string, 0, len(perm.PrefixListIds))
+		for _, prefixList := range perm.PrefixListIds {
+			if prefixList.PrefixListId != nil {
+				prefixLists = append(prefixLists, *prefixList.PrefixListId)
+			}
+		}
+		if len(prefixLists) > 0 {
+			rule["prefix_list_ids"] = prefixLists
 		}
 
-		node := graph.ResourceNode{
-			ID:       fmt.Sprintf("aws:sg:%s", *sg.GroupId),
-			Type:     "security_group",
-			Provider: "aws",
-			Region:   s.region,
-			Name:     getStringPtr(sg.GroupName),
-			Metadata: map[string]interface{}{
-				"group_id":      *sg.GroupId,
-				"group_name":    *sg.GroupName,
-				"description":   getStringPtr(sg.Description),
-				"vpc_id":        getStringPtr(sg.VpcId),
-				"ingress_rules": ingressRules,
-				"egress_count":  len(sg.IpPermissionsEgress),
-			},
-			Tags:      convertTags(sg.Tags),
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+		// Security Group 参照（別アカウントの場合も GroupId で識別できる）
+		groups := make([]string, 0, len(perm.UserIdGroupPairs))
+		for _, pair := range perm.UserIdGroupPairs {
+			if pair.GroupId != nil {
+				groups = append(groups, *pair.GroupId)
+			}
+		}
+		if len(groups) > 0 {
+			rule["security_groups"] = groups
 		}
 
-		nodes = append(nodes, node)
+		rules = append(rules, rule)
 	}
-
-	return nodes, nil
+	return rules
 }
@@ -0,0 +1,75 @@
+package aws
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// fakeSecurityGroupClient は 1 ページ分の Security Group を返す DescribeSecurityGroups のフェイク
+type fakeSecurityGroupClient struct {
+	groups []ec2types.SecurityGroup
+}
+
+func (c *fakeSecurityGroupClient) DescribeSecurityGroups(ctx context.Context, input *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error) {
+	return &ec2.DescribeSecurityGroupsOutput{SecurityGroups: c.groups}, nil
+}
+
+func TestSecurityGroupScanner_Rules(t *testing.T) {
+	client := &fakeSecurityGroupClient{groups: []ec2types.SecurityGroup{{
+		GroupId:   aws.String("sg-db"),
+		GroupName: aws.String("db"),
+		VpcId:     aws.String("vpc-1"),
+		IpPermissions: []ec2types.IpPermission{{
+			IpProtocol: aws.String("tcp"),
+			FromPort:   aws.Int32(5432),
+			ToPort:     aws.Int32(5432),
+			UserIdGroupPairs: []ec2types.UserIdGroupPair{
+				{GroupId: aws.String("sg-app")},
+				{GroupId: aws.String("sg-peer"), UserId: aws.String("222222222222")},
+			},
+			IpRanges: []ec2types.IpRange{{CidrIp: aws.String("10.0.2.0/24")}},
+		}},
+		IpPermissionsEgress: []ec2types.IpPermission{{
+			IpProtocol:    aws.String("-1"),
+			IpRanges:      []ec2types.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+			Ipv6Ranges:    []ec2types.Ipv6Range{{CidrIpv6: aws.String("::/0")}},
+			PrefixListIds: []ec2types.PrefixListId{{PrefixListId: aws.String("pl-1")}},
+		}},
+	}}}
+
+	nodes, err := NewSecurityGroupScanner(client, "111111111111", "us-east-1").Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(nodes) != 1 {
+		t.Fatalf("Expected 1 node, got %d", len(nodes))
+	}
+
+	ingress := nodes[0].Metadata["ingress_rules"].([]map[string]interface{})
+	wantIngress := []map[string]interface{}{{
+		"protocol":        "tcp",
+		"from_port":       int32(5432),
+		"to_port":         int32(5432),
+		"cidr_blocks":     []string{"10.0.2.0/24"},
+		"security_groups": []string{"sg-app", "sg-peer"},
+	}}
+	if !reflect.DeepEqual(ingress, wantIngress) {
+		t.Errorf("ingress_rules = %v, want %v", ingress, wantIngress)
+	}
+
+	egress := nodes[0].Metadata["egress_rules"].([]map[string]interface{})
+	wantEgress := []map[string]interface{}{{
+		"protocol":         "-1",
+		"cidr_blocks":      []string{"0.0.0.0/0"},
+		"ipv6_cidr_blocks": []string{"::/0"},
+		"prefix_list_ids":  []string{"pl-1"},
+	}}
+	if !reflect.DeepEqual(egress, wantEgress) {
+		t.Errorf("egress_rules = %v, want %v", egress, wantEgress)
+	}
+}
@@ -0,0 +1,27 @@
+package aws
+
+import (
+	"sync"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/scanner"
+)
+
+// statsRecorder は直近のスキャンの取得統計を保持する（各スキャナーに埋め込む）
+type statsRecorder struct {
+	mu    sync.Mutex
+	stats scanner.ScanStats
+}
+
+// LastStats は直近の Scan の取得統計を返す
+func (r *statsRecorder) LastStats() scanner.ScanStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// record は取得統計を記録
+func (r *statsRecorder) record(stats scanner.ScanStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats = stats
+}
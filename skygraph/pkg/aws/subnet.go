@@ -5,21 +5,27 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
-	"github.com/yourusername/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/scanner"
 )
 
 // SubnetScanner は Subnet をスキャン
 type SubnetScanner struct {
-	client *ec2.Client
-	region string
+	statsRecorder
+
+	client  ec2.DescribeSubnetsAPIClient
+	account string
+	region  string
 }
 
 // NewSubnetScanner は新しい Subnet スキャナーを作成
-func NewSubnetScanner(client *ec2.Client, region string) *SubnetScanner {
+func NewSubnetScanner(client ec2.DescribeSubnetsAPIClient, account, region string) *SubnetScanner {
 	return &SubnetScanner{
-		client: client,
-		region: region,
+		client:  client,
+		account: account,
+		region:  region,
 	}
 }
 
@@ -28,37 +34,55 @@ func (s *SubnetScanner) Name() string {
 	return "subnet"
 }
 
-// Scan は Subnet をスキャン
+// Scan は Subnet を全ページ分スキャン
+// 途中でエラーになった場合は、それまでに取得したノードとエラーを返す
 func (s *SubnetScanner) Scan(ctx context.Context) ([]graph.ResourceNode, error) {
-	result, err := s.client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to describe subnets: %w", err)
-	}
+	paginator := ec2.NewDescribeSubnetsPaginator(s.client, &ec2.DescribeSubnetsInput{
+		MaxResults: aws.Int32(ec2PageSize),
+	})
+
+	nodes := make([]graph.ResourceNode, 0)
+	stats := scanner.ScanStats{}
+	defer func() { s.record(stats) }()
+
+	for paginator.HasMorePages() {
+		if err := ctx.Err(); err != nil {
+			return nodes, fmt.Errorf("scan interrupted after %d pages: %w", stats.Pages, err)
+		}
+
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nodes, fmt.Errorf("failed to describe subnets (page %d): %w", stats.Pages+1, err)
+		}
+		stats.Pages++
 
-	nodes := make([]graph.ResourceNode, 0, len(result.Subnets))
+		for _, subnet := range result.Subnets {
+			node := graph.ResourceNode{
+				ID:       graph.AWSNodeID(s.account, s.region, "subnet", *subnet.SubnetId),
+				Type:     "subnet",
+				Provider: "aws",
+				Region:   s.region,
+				Account:  s.account,
+				Name:     getNameTag(subnet.Tags),
+				Metadata: map[string]interface{}{
+					"subnet_id":         *subnet.SubnetId,
+					"vpc_id":            *subnet.VpcId,
+					"cidr_block":        *subnet.CidrBlock,
+					"availability_zone": *subnet.AvailabilityZone,
+					"state":             string(subnet.State),
+					"available_ips":     *subnet.AvailableIpAddressCount,
+				},
+				Tags:      convertTags(subnet.Tags),
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			}
 
-	for _, subnet := range result.Subnets {
-		node := graph.ResourceNode{
-			ID:       fmt.Sprintf("aws:subnet:%s", *subnet.SubnetId),
-			Type:     "subnet",
-			Provider: "aws",
-			Region:   s.region,
-			Name:     getNameTag(subnet.Tags),
-			Metadata: map[string]interface{}{
-				"subnet_id":         *subnet.SubnetId,
-				"vpc_id":            *subnet.VpcId,
-				"cidr_block":        *subnet.CidrBlock,
-				"availability_zone": *subnet.AvailabilityZone,
-				"state":             string(subnet.State),
-				"available_ips":     *subnet.AvailableIpAddressCount,
-			},
-			Tags:      convertTags(subnet.Tags),
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+			nodes = append(nodes, node)
 		}
 
-		nodes = append(nodes, node)
+		stats.Items = len(nodes)
 	}
 
+	stats.Complete = true
 	return nodes, nil
 }
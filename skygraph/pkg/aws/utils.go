@@ -62,3 +62,9 @@ func getTimePtr(t *time.Time) time.Time {
 	}
 	return *t
 }
+
+// ページあたりの最大取得件数（各 API の上限値）
+const (
+	ec2PageSize = 1000
+	rdsPageSize = 100
+)
@@ -5,21 +5,27 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
-	"github.com/yourusername/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/scanner"
 )
 
 // VPCScanner は VPC をスキャン
 type VPCScanner struct {
-	client *ec2.Client
-	region string
+	statsRecorder
+
+	client  ec2.DescribeVpcsAPIClient
+	account string
+	region  string
 }
 
 // NewVPCScanner は新しい VPC スキャナーを作成
-func NewVPCScanner(client *ec2.Client, region string) *VPCScanner {
+func NewVPCScanner(client ec2.DescribeVpcsAPIClient, account, region string) *VPCScanner {
 	return &VPCScanner{
-		client: client,
-		region: region,
+		client:  client,
+		account: account,
+		region:  region,
 	}
 }
 
@@ -28,36 +34,54 @@ func (s *VPCScanner) Name() string {
 	return "vpc"
 }
 
-// Scan は VPC をスキャン
+// Scan は VPC を全ページ分スキャン
+// 途中でエラーになった場合は、それまでに取得したノードとエラーを返す
 func (s *VPCScanner) Scan(ctx context.Context) ([]graph.ResourceNode, error) {
-	result, err := s.client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to describe VPCs: %w", err)
-	}
+	paginator := ec2.NewDescribeVpcsPaginator(s.client, &ec2.DescribeVpcsInput{
+		MaxResults: aws.Int32(ec2PageSize),
+	})
+
+	nodes := make([]graph.ResourceNode, 0)
+	stats := scanner.ScanStats{}
+	defer func() { s.record(stats) }()
+
+	for paginator.HasMorePages() {
+		if err := ctx.Err(); err != nil {
+			return nodes, fmt.Errorf("scan interrupted after %d pages: %w", stats.Pages, err)
+		}
+
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nodes, fmt.Errorf("failed to describe VPCs (page %d): %w", stats.Pages+1, err)
+		}
+		stats.Pages++
+
+		for _, vpc := range result.Vpcs {
+			node := graph.ResourceNode{
+				ID:       graph.AWSNodeID(s.account, s.region, "vpc", *vpc.VpcId),
+				Type:     "vpc",
+				Provider: "aws",
+				Region:   s.region,
+				Account:  s.account,
+				Name:     getNameTag(vpc.Tags),
+				Metadata: map[string]interface{}{
+					"vpc_id":       *vpc.VpcId,
+					"cidr_block":   *vpc.CidrBlock,
+					"state":        string(vpc.State),
+					"is_default":   *vpc.IsDefault,
+					"dhcp_options": *vpc.DhcpOptionsId,
+				},
+				Tags:      convertTags(vpc.Tags),
+				CreatedAt: time.Now(), // AWS doesn't provide creation time for VPC
+				UpdatedAt: time.Now(),
+			}
 
-	nodes := make([]graph.ResourceNode, 0, len(result.Vpcs))
-
-	for _, vpc := range result.Vpcs {
-		node := graph.ResourceNode{
-			ID:       fmt.Sprintf("aws:vpc:%s", *vpc.VpcId),
-			Type:     "vpc",
-			Provider: "aws",
-			Region:   s.region,
-			Name:     getNameTag(vpc.Tags),
-			Metadata: map[string]interface{}{
-				"vpc_id":       *vpc.VpcId,
-				"cidr_block":   *vpc.CidrBlock,
-				"state":        string(vpc.State),
-				"is_default":   *vpc.IsDefault,
-				"dhcp_options": *vpc.DhcpOptionsId,
-			},
-			Tags:      convertTags(vpc.Tags),
-			CreatedAt: time.Now(), // AWS doesn't provide creation time for VPC
-			UpdatedAt: time.Now(),
+			nodes = append(nodes, node)
 		}
 
-		nodes = append(nodes, node)
+		stats.Items = len(nodes)
 	}
 
+	stats.Complete = true
 	return nodes, nil
 }
@@ -0,0 +1,136 @@
+package builder
+
+import (
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/reachability"
+)
+
+// awsRules は AWS リソースのエッジ推論ルールを返す
+func awsRules() []Rule {
+	return []Rule{
+		// Subnet / Security Group → VPC (ownership)
+		&MetadataRule{RuleName: "subnet-vpc", Types: []string{"subnet"},
+			MetadataKey: "vpc_id", Target: "aws:vpc:%s", EdgeType: "ownership"},
+		&MetadataRule{RuleName: "security-group-vpc", Types: []string{"security_group"},
+			MetadataKey: "vpc_id", Target: "aws:vpc:%s", EdgeType: "ownership"},
+
+		// EC2 → Subnet / Security Groups (network)
+		&MetadataRule{RuleName: "ec2-subnet", Types: []string{"ec2"},
+			MetadataKey: "subnet_id", Target: "aws:subnet:%s", EdgeType: "network"},
+		&MetadataRule{RuleName: "ec2-security-group", Types: []string{"ec2"},
+			MetadataKey: "security_groups", Target: "aws:sg:%s", EdgeType: "network"},
+
+		// RDS → Subnet / Security Groups (network)
+		&MetadataRule{RuleName: "rds-subnet", Types: []string{"rds"},
+			MetadataKey: "subnet_ids", Target: "aws:subnet:%s", EdgeType: "network"},
+		&MetadataRule{RuleName: "rds-security-group", Types: []string{"rds"},
+			MetadataKey: "security_groups", Target: "aws:sg:%s", EdgeType: "network"},
+
+		// EC2 → RDS (network): Security Group で DB のポートへの通信が許可されている場合のみ
+		&reachabilityRule{},
+	}
+}
+
+// reachabilityRule は Security Group のルール上 EC2 から RDS のポートへ通信できる場合に network エッジを作る
+type reachabilityRule struct {
+	// engine は全 Security Group のルール（InferEdges 中のみ使用）
+	engine *reachability.Engine
+
+	// ec2ByLocation は "アカウント/リージョン" → EC2 ノード
+	// Security Group ID はリージョン内でのみ意味を持つため、同じ場所の EC2 のみを候補にする
+	ec2ByLocation map[string][]graph.ResourceNode
+
+	// subnetCIDRs は Subnet ノード ID → CIDR
+	subnetCIDRs map[string]string
+}
+
+func (r *reachabilityRule) Name() string        { return "ec2-rds-reachability" }
+func (r *reachabilityRule) NodeTypes() []string { return []string{"rds"} }
+
+// Prepare は Security Group のルールと EC2・Subnet を索引化
+func (r *reachabilityRule) Prepare(g *graph.Graph) {
+	groups := make([]reachability.SecurityGroup, 0)
+	r.ec2ByLocation = make(map[string][]graph.ResourceNode)
+	r.subnetCIDRs = make(map[string]string)
+
+	for _, node := range g.Nodes {
+		switch node.Type {
+		case "security_group":
+			if sg, ok := reachability.SecurityGroupFromNode(node); ok {
+				groups = append(groups, sg)
+			}
+		case "ec2":
+			key := node.Account + "/" + node.Region
+			r.ec2ByLocation[key] = append(r.ec2ByLocation[key], node)
+		case "subnet":
+			if cidr, ok := node.Metadata["cidr_block"].(string); ok {
+				r.subnetCIDRs[node.ID] = cidr
+			}
+		}
+	}
+
+	r.engine = reachability.NewEngine(groups)
+}
+
+// Finish は索引を破棄
+func (r *reachabilityRule) Finish() {
+	r.engine = nil
+	r.ec2ByLocation = nil
+	r.subnetCIDRs = nil
+}
+
+// Infer は RDS のポートへ通信できる EC2 → RDS のエッジを作成
+func (r *reachabilityRule) Infer(g *graph.Graph, node graph.ResourceNode) ([]graph.Edge, error) {
+	port, ok := reachability.ToInt(node.Metadata["port"])
+	if !ok || port == 0 {
+		return nil, nil
+	}
+
+	// RDS の IP はエンドポイントからは分からないため、所属 Subnet の CIDR を宛先とする
+	dst := reachability.Endpoint{
+		SecurityGroups: metadataStrings(node.Metadata["security_groups"]),
+	}
+	dst.VPCID, _ = node.Metadata["vpc_id"].(string)
+	for _, subnetID := range metadataStrings(node.Metadata["subnet_ids"]) {
+		cidr := r.subnetCIDRs[graph.AWSNodeID(node.Account, node.Region, "subnet", subnetID)]
+		if prefix, ok := reachability.ParseAddress(cidr); ok {
+			dst.Prefixes = append(dst.Prefixes, prefix)
+		}
+	}
+
+	edges := make([]graph.Edge, 0)
+	for _, ec2 := range r.ec2ByLocation[node.Account+"/"+node.Region] {
+		src := reachability.Endpoint{
+			SecurityGroups: metadataStrings(ec2.Metadata["security_groups"]),
+		}
+		src.VPCID, _ = ec2.Metadata["vpc_id"].(string)
+		if ip, ok := ec2.Metadata["private_ip"].(string); ok {
+			if prefix, ok := reachability.ParseAddress(ip); ok {
+				src.Prefixes = append(src.Prefixes, prefix)
+			}
+		}
+
+		path, ok := r.engine.Reachable(src, dst, "tcp", port)
+		if !ok {
+			continue
+		}
+
+		metadata := map[string]interface{}{
+			"inferred": true,
+			"reason":   "security group",
+			"protocol": path.Protocol,
+			"port":     path.Port,
+			"ingress":  path.Ingress,
+		}
+		if path.Egress != "" {
+			metadata["egress"] = path.Egress
+		}
+		edges = append(edges, graph.Edge{
+			From:     ec2.ID,
+			To:       node.ID,
+			Type:     "network",
+			Metadata: metadata,
+		})
+	}
+	return edges, nil
+}
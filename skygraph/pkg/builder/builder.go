@@ -3,144 +3,139 @@ package builder
 import (
 	"fmt"
 
-	"github.com/yourusername/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
 )
 
 // GraphBuilder はスキャン結果からグラフを構築
 type GraphBuilder struct {
 	graph *graph.Graph
+
+	// rules はエッジ推論ルール（登録順に実行）
+	rules []Rule
 }
 
-// NewGraphBuilder は新しい GraphBuilder を作成
+// NewGraphBuilder はデフォルトのルールを登録した GraphBuilder を作成
 func NewGraphBuilder() *GraphBuilder {
+	return NewGraphBuilderWithRules(DefaultRules()...)
+}
+
+// NewGraphBuilderWithRules は指定したルールのみを登録した GraphBuilder を作成
+func NewGraphBuilderWithRules(rules ...Rule) *GraphBuilder {
 	return &GraphBuilder{
 		graph: graph.NewGraph(),
+		rules: rules,
 	}
 }
 
+// DefaultRules は組み込みのエッジ推論ルールを返す（呼び出しごとに新しいインスタンス）
+func DefaultRules() []Rule {
+	rules := awsRules()
+	rules = append(rules, k8sRules()...)
+	return rules
+}
+
+// AddRule はルールを末尾に追加
+func (b *GraphBuilder) AddRule(rules ...Rule) {
+	b.rules = append(b.rules, rules...)
+}
+
+// DisableRule は指定した名前のルールを無効化し、無効化したかどうかを返す
+func (b *GraphBuilder) DisableRule(name string) bool {
+	for i, rule := range b.rules {
+		if rule.Name() == name {
+			b.rules = append(b.rules[:i:i], b.rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Rules は登録されているルール名を登録順に返す
+func (b *GraphBuilder) Rules() []string {
+	names := make([]string, 0, len(b.rules))
+	for _, rule := range b.rules {
+		names = append(names, rule.Name())
+	}
+	return names
+}
+
 // AddNodes はノードをグラフに追加（重複排除）
 func (b *GraphBuilder) AddNodes(nodes []graph.ResourceNode) {
 	for _, node := range nodes {
 		// 既存チェック
-		if b.graph.FindNode(node.ID) == nil {
+		if !b.graph.HasNode(node.ID) {
 			b.graph.AddNode(node)
 		}
 	}
 }
 
-// InferEdges は全ノードからエッジを推論
+// InferEdges は登録順にルールを実行して全ノードからエッジを推論
+// 両端のノードが存在しないエッジと、既に存在するエッジ（From, To, Type が同じ）は追加しない
 func (b *GraphBuilder) InferEdges() error {
-	for _, node := range b.graph.Nodes {
-		edges, err := b.inferEdgesForNode(node)
-		if err != nil {
-			return fmt.Errorf("failed to infer edges for %s: %w", node.ID, err)
-		}
+	// ノードタイプごとのインデックス（ルールの対象ノードのみを走査するため）
+	nodesByType := make(map[string][]int)
+	for i, node := range b.graph.Nodes {
+		nodesByType[node.Type] = append(nodesByType[node.Type], i)
+	}
 
-		for _, edge := range edges {
-			// エッジの to ノードが存在するか確認
-			if b.graph.FindNode(edge.To) != nil {
-				b.graph.AddEdge(edge)
-			}
+	seen := make(map[[3]string]bool, len(b.graph.Edges))
+	for _, edge := range b.graph.Edges {
+		seen[[3]string{edge.From, edge.To, edge.Type}] = true
+	}
+
+	for _, rule := range b.rules {
+		if err := b.applyRule(rule, nodesByType, seen); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-// inferEdgesForNode は1つのノードに対してエッジを推論
-func (b *GraphBuilder) inferEdgesForNode(node graph.ResourceNode) ([]graph.Edge, error) {
-	edges := make([]graph.Edge, 0)
-
-	switch node.Type {
-	case "vpc":
-		// VPC に対するエッジは他のノードから生成されるため、ここでは何もしない
-
-	case "subnet":
-		// Subnet → VPC (ownership)
-		if vpcID, ok := node.Metadata["vpc_id"].(string); ok && vpcID != "" {
-			edges = append(edges, graph.Edge{
-				From: fmt.Sprintf("aws:vpc:%s", vpcID),
-				To:   node.ID,
-				Type: "ownership",
-			})
-		}
+// applyRule は1つのルールを対象ノードに適用
+func (b *GraphBuilder) applyRule(rule Rule, nodesByType map[string][]int, seen map[[3]string]bool) error {
+	if p, ok := rule.(Preparer); ok {
+		p.Prepare(b.graph)
+		defer p.Finish()
+	}
 
-	case "security_group":
-		// Security Group → VPC (ownership)
-		if vpcID, ok := node.Metadata["vpc_id"].(string); ok && vpcID != "" {
-			edges = append(edges, graph.Edge{
-				From: fmt.Sprintf("aws:vpc:%s", vpcID),
-				To:   node.ID,
-				Type: "ownership",
-			})
+	var targets []int
+	if types := rule.NodeTypes(); len(types) == 0 {
+		targets = make([]int, len(b.graph.Nodes))
+		for i := range targets {
+			targets[i] = i
 		}
-
-	case "ec2":
-		// EC2 → Subnet (network)
-		if subnetID, ok := node.Metadata["subnet_id"].(string); ok && subnetID != "" {
-			edges = append(edges, graph.Edge{
-				From: fmt.Sprintf("aws:subnet:%s", subnetID),
-				To:   node.ID,
-				Type: "network",
-			})
+	} else {
+		for _, t := range types {
+			targets = append(targets, nodesByType[t]...)
 		}
+	}
 
-		// EC2 → Security Groups (network)
-		if sgIDs, ok := node.Metadata["security_groups"].([]string); ok {
-			for _, sgID := range sgIDs {
-				edges = append(edges, graph.Edge{
-					From: fmt.Sprintf("aws:sg:%s", sgID),
-					To:   node.ID,
-					Type: "network",
-				})
-			}
-		}
+	for _, i := range targets {
+		node := b.graph.Nodes[i]
 
-	case "rds":
-		// RDS → Subnet (network)
-		if subnetIDs, ok := node.Metadata["subnet_ids"].([]string); ok {
-			for _, subnetID := range subnetIDs {
-				edges = append(edges, graph.Edge{
-					From: fmt.Sprintf("aws:subnet:%s", subnetID),
-					To:   node.ID,
-					Type: "network",
-				})
-			}
+		edges, err := rule.Infer(b.graph, node)
+		if err != nil {
+			return fmt.Errorf("rule %s failed to infer edges for %s: %w", rule.Name(), node.ID, err)
 		}
 
-		// RDS → Security Groups (network)
-		if sgIDs, ok := node.Metadata["security_groups"].([]string); ok {
-			for _, sgID := range sgIDs {
-				edges = append(edges, graph.Edge{
-					From: fmt.Sprintf("aws:sg:%s", sgID),
-					To:   node.ID,
-					Type: "network",
-				})
+		for _, edge := range edges {
+			// エッジの両端のノードが存在するか確認
+			if !b.graph.HasNode(edge.From) || !b.graph.HasNode(edge.To) {
+				continue
 			}
-		}
 
-		// EC2 → RDS (dependency) の推論
-		// 同じ VPC 内の EC2 は RDS に依存している可能性がある
-		if vpcID, ok := node.Metadata["vpc_id"].(string); ok && vpcID != "" {
-			for _, n := range b.graph.Nodes {
-				if n.Type == "ec2" {
-					if ec2VpcID, ok := n.Metadata["vpc_id"].(string); ok && ec2VpcID == vpcID {
-						edges = append(edges, graph.Edge{
-							From: n.ID,
-							To:   node.ID,
-							Type: "dependency",
-							Metadata: map[string]interface{}{
-								"inferred": true,
-								"reason":   "same VPC",
-							},
-						})
-					}
-				}
+			key := [3]string{edge.From, edge.To, edge.Type}
+			if seen[key] {
+				continue
 			}
+			seen[key] = true
+
+			b.graph.AddEdge(edge)
 		}
 	}
 
-	return edges, nil
+	return nil
 }
 
 // Build はグラフを完成させて返す
@@ -0,0 +1,215 @@
+package builder
+
+import (
+	"strings"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+// k8sNamespacedTypes は Namespace に属する Kubernetes リソースのノードタイプ
+var k8sNamespacedTypes = []string{
+	"k8s_deployment", "k8s_replicaset", "k8s_pod", "k8s_service",
+	"k8s_ingress", "k8s_configmap", "k8s_secret",
+}
+
+// k8sRules は Kubernetes リソースのエッジ推論ルールを返す
+func k8sRules() []Rule {
+	return []Rule{
+		// Namespace → リソース (ownership)
+		&MetadataRule{RuleName: "k8s-namespace", Types: k8sNamespacedTypes,
+			MetadataKey: "namespace", Target: "k8s:namespace:%s", EdgeType: "ownership"},
+
+		// OwnerReferences: Deployment → ReplicaSet → Pod など (ownership)
+		NewRuleFunc("k8s-owner-references", nil, inferOwnerReferences),
+
+		// Service → Pod (network): selector に一致する同じ Namespace の Pod
+		&serviceSelectorRule{},
+
+		// Ingress → Service (network)
+		&MetadataRule{RuleName: "k8s-ingress-service", Types: []string{"k8s_ingress"},
+			MetadataKey: "backend_services", Target: "k8s:service:%s", EdgeType: "network", Direction: DirectionToTarget},
+
+		// Pod → ConfigMap / Secret (dependency)
+		&MetadataRule{RuleName: "k8s-pod-configmap", Types: []string{"k8s_pod"},
+			MetadataKey: "configmaps", Target: "k8s:configmap:%s", EdgeType: "dependency", Direction: DirectionToTarget},
+		&MetadataRule{RuleName: "k8s-pod-secret", Types: []string{"k8s_pod"},
+			MetadataKey: "secrets", Target: "k8s:secret:%s", EdgeType: "dependency", Direction: DirectionToTarget},
+
+		// Node → Pod (network)
+		&MetadataRule{RuleName: "k8s-node-pod", Types: []string{"k8s_pod"},
+			MetadataKey: "node_name", Target: "k8s:node:%s", EdgeType: "network"},
+
+		// EC2 → Node (ownership) と EC2 → Pod (network): Node の providerID で紐付け
+		&ec2NodeRule{},
+	}
+}
+
+// k8sLocation はノードのクラスタ名と Namespace を返す
+func k8sLocation(node graph.ResourceNode) (cluster, namespace string) {
+	cluster, _ = node.Metadata["cluster"].(string)
+	namespace, _ = node.Metadata["namespace"].(string)
+	return cluster, namespace
+}
+
+// inferOwnerReferences は owner_refs（"Kind/name" 形式）から ownership エッジを作成
+func inferOwnerReferences(g *graph.Graph, node graph.ResourceNode) ([]graph.Edge, error) {
+	if node.Provider != "kubernetes" {
+		return nil, nil
+	}
+
+	cluster, namespace := k8sLocation(node)
+	edges := make([]graph.Edge, 0)
+
+	for _, owner := range metadataStrings(node.Metadata["owner_refs"]) {
+		kind, name, found := strings.Cut(owner, "/")
+		if !found {
+			continue
+		}
+		edges = append(edges, graph.Edge{
+			From: graph.K8sNodeID(cluster, strings.ToLower(kind), namespace, name),
+			To:   node.ID,
+			Type: "ownership",
+		})
+	}
+
+	return edges, nil
+}
+
+// serviceSelectorRule は Service の selector に一致する Pod へ network エッジを作る
+type serviceSelectorRule struct {
+	// podsByNamespace は Namespace ノード ID → Pod ノード（InferEdges 中のみ使用）
+	podsByNamespace map[string][]graph.ResourceNode
+}
+
+func (r *serviceSelectorRule) Name() string        { return "k8s-service-selector" }
+func (r *serviceSelectorRule) NodeTypes() []string { return []string{"k8s_service"} }
+
+// Prepare は Namespace ごとの Pod を索引化
+func (r *serviceSelectorRule) Prepare(g *graph.Graph) {
+	r.podsByNamespace = make(map[string][]graph.ResourceNode)
+	for _, node := range g.Nodes {
+		if node.Type != "k8s_pod" {
+			continue
+		}
+		cluster, namespace := k8sLocation(node)
+		nsID := graph.K8sNodeID(cluster, "namespace", "", namespace)
+		r.podsByNamespace[nsID] = append(r.podsByNamespace[nsID], node)
+	}
+}
+
+// Finish は索引を破棄
+func (r *serviceSelectorRule) Finish() {
+	r.podsByNamespace = nil
+}
+
+// Infer は selector に一致する Pod へのエッジを作成
+func (r *serviceSelectorRule) Infer(g *graph.Graph, node graph.ResourceNode) ([]graph.Edge, error) {
+	selector, ok := node.Metadata["selector"].(map[string]string)
+	if !ok || len(selector) == 0 {
+		return nil, nil
+	}
+
+	cluster, namespace := k8sLocation(node)
+	nsID := graph.K8sNodeID(cluster, "namespace", "", namespace)
+
+	edges := make([]graph.Edge, 0)
+	for _, pod := range r.podsByNamespace[nsID] {
+		if matchSelector(selector, pod.Tags) {
+			edges = append(edges, graph.Edge{
+				From: node.ID,
+				To:   pod.ID,
+				Type: "network",
+				Metadata: map[string]interface{}{
+					"inferred": true,
+					"reason":   "service selector",
+				},
+			})
+		}
+	}
+	return edges, nil
+}
+
+// matchSelector は labels が selector の全ての条件を満たすかを判定
+func matchSelector(selector, labels map[string]string) bool {
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// ec2NodeRule は Kubernetes Node の providerID から EC2 インスタンスへの紐付けを推論する
+type ec2NodeRule struct {
+	// instanceByNode は Kubernetes Node のノード ID → EC2 インスタンス ID
+	instanceByNode map[string]string
+
+	// ec2ByInstance は EC2 インスタンス ID → EC2 ノード ID
+	ec2ByInstance map[string][]string
+}
+
+func (r *ec2NodeRule) Name() string        { return "k8s-ec2-node" }
+func (r *ec2NodeRule) NodeTypes() []string { return []string{"k8s_node", "k8s_pod"} }
+
+// Prepare は Node とインスタンス ID の対応を索引化
+func (r *ec2NodeRule) Prepare(g *graph.Graph) {
+	r.instanceByNode = make(map[string]string)
+	r.ec2ByInstance = make(map[string][]string)
+
+	for _, node := range g.Nodes {
+		instanceID, ok := node.Metadata["instance_id"].(string)
+		if !ok || instanceID == "" {
+			continue
+		}
+		switch node.Type {
+		case "k8s_node":
+			r.instanceByNode[node.ID] = instanceID
+		case "ec2":
+			r.ec2ByInstance[instanceID] = append(r.ec2ByInstance[instanceID], node.ID)
+		}
+	}
+}
+
+// Finish は索引を破棄
+func (r *ec2NodeRule) Finish() {
+	r.instanceByNode = nil
+	r.ec2ByInstance = nil
+}
+
+// Infer は EC2 → Node (ownership) と EC2 → Pod (network) のエッジを作成
+func (r *ec2NodeRule) Infer(g *graph.Graph, node graph.ResourceNode) ([]graph.Edge, error) {
+	edges := make([]graph.Edge, 0)
+
+	switch node.Type {
+	case "k8s_node":
+		for _, ec2ID := range r.ec2ByInstance[r.instanceByNode[node.ID]] {
+			edges = append(edges, graph.Edge{
+				From: ec2ID,
+				To:   node.ID,
+				Type: "ownership",
+			})
+		}
+
+	case "k8s_pod":
+		nodeName, ok := node.Metadata["node_name"].(string)
+		if !ok || nodeName == "" {
+			return nil, nil
+		}
+		cluster, _ := k8sLocation(node)
+		k8sNodeID := graph.K8sNodeID(cluster, "node", "", nodeName)
+
+		for _, ec2ID := range r.ec2ByInstance[r.instanceByNode[k8sNodeID]] {
+			edges = append(edges, graph.Edge{
+				From: ec2ID,
+				To:   node.ID,
+				Type: "network",
+				Metadata: map[string]interface{}{
+					"inferred": true,
+					"reason":   "node providerID",
+				},
+			})
+		}
+	}
+
+	return edges, nil
+}
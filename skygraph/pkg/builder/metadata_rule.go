@@ -0,0 +1,196 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	"gopkg.in/yaml.v3"
+)
+
+// エッジの向き
+const (
+	// DirectionFromTarget は参照先 → ノードのエッジを作る（例: Subnet → EC2）
+	DirectionFromTarget = "from_target"
+
+	// DirectionToTarget はノード → 参照先のエッジを作る（例: Pod → ConfigMap）
+	DirectionToTarget = "to_target"
+)
+
+// MetadataRule は Metadata の値が指す参照先ノードとの間にエッジを作る宣言的ルール
+//
+// YAML の例:
+//
+//	rules:
+//	  - name: ec2-subnet
+//	    node_types: [ec2]
+//	    metadata_key: subnet_id
+//	    target: "aws:subnet:%s"
+//	    edge_type: network
+type MetadataRule struct {
+	RuleName string   `yaml:"name"`
+	Types    []string `yaml:"node_types"`
+
+	// MetadataKey は参照先 ID を持つ Metadata のキー（値は文字列または文字列の配列）
+	MetadataKey string `yaml:"metadata_key"`
+
+	// Target は参照先ノード ID のテンプレート（%s が Metadata の値に置き換わる）
+	// "aws:<type>:%s" はノードと同じアカウント・リージョンの AWS ノード ID に、
+	// "k8s:<kind>:%s" はノードと同じクラスタ・Namespace の Kubernetes ノード ID に展開する
+	Target string `yaml:"target"`
+
+	// EdgeType はエッジタイプ（network, dependency, ownership など）
+	EdgeType string `yaml:"edge_type"`
+
+	// Direction は from_target（デフォルト）または to_target
+	Direction string `yaml:"direction,omitempty"`
+
+	// EdgeMetadata はエッジに付与するメタデータ
+	EdgeMetadata map[string]interface{} `yaml:"edge_metadata,omitempty"`
+}
+
+// Name はルール名を返す
+func (r *MetadataRule) Name() string {
+	return r.RuleName
+}
+
+// NodeTypes は対象ノードタイプを返す
+func (r *MetadataRule) NodeTypes() []string {
+	return r.Types
+}
+
+// Validate はルール定義を検証
+func (r *MetadataRule) Validate() error {
+	if r.RuleName == "" {
+		return fmt.Errorf("rule name is required")
+	}
+	if r.MetadataKey == "" {
+		return fmt.Errorf("rule %s: metadata_key is required", r.RuleName)
+	}
+	if !strings.Contains(r.Target, "%s") {
+		return fmt.Errorf("rule %s: target must contain %%s", r.RuleName)
+	}
+	if r.EdgeType == "" {
+		return fmt.Errorf("rule %s: edge_type is required", r.RuleName)
+	}
+	switch r.Direction {
+	case "", DirectionFromTarget, DirectionToTarget:
+	default:
+		return fmt.Errorf("rule %s: unknown direction %q (available: %s, %s)",
+			r.RuleName, r.Direction, DirectionFromTarget, DirectionToTarget)
+	}
+	return nil
+}
+
+// Infer は Metadata の値ごとにエッジを作成
+func (r *MetadataRule) Infer(g *graph.Graph, node graph.ResourceNode) ([]graph.Edge, error) {
+	values := metadataStrings(node.Metadata[r.MetadataKey])
+	edges := make([]graph.Edge, 0, len(values))
+
+	for _, value := range values {
+		target := r.targetID(node, value)
+
+		edge := graph.Edge{
+			From: target,
+			To:   node.ID,
+			Type: r.EdgeType,
+		}
+		if r.Direction == DirectionToTarget {
+			edge.From, edge.To = node.ID, target
+		}
+		if len(r.EdgeMetadata) > 0 {
+			edge.Metadata = make(map[string]interface{}, len(r.EdgeMetadata))
+			for k, v := range r.EdgeMetadata {
+				edge.Metadata[k] = v
+			}
+		}
+
+		edges = append(edges, edge)
+	}
+
+	return edges, nil
+}
+
+// targetID はテンプレートから参照先ノード ID を作成
+func (r *MetadataRule) targetID(node graph.ResourceNode, value string) string {
+	parts := strings.Split(r.Target, ":")
+	if len(parts) == 3 && parts[2] == "%s" {
+		switch parts[0] {
+		case "aws":
+			return graph.AWSNodeID(node.Account, node.Region, parts[1], value)
+		case "k8s":
+			cluster, namespace := k8sLocation(node)
+			if parts[1] == "namespace" || parts[1] == "node" {
+				// クラスタスコープのリソース
+				namespace = ""
+			}
+			return graph.K8sNodeID(cluster, parts[1], namespace, value)
+		}
+	}
+	return strings.ReplaceAll(r.Target, "%s", value)
+}
+
+// metadataStrings は Metadata の値を文字列のスライスに変換
+// JSON から読み込んだグラフの []interface{} にも対応する
+func metadataStrings(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		if val == "" {
+			return nil
+		}
+		return []string{val}
+	case []string:
+		return val
+	case []interface{}:
+		result := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok && s != "" {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// rulesFile は YAML ルールファイルの形式
+type rulesFile struct {
+	Rules []*MetadataRule `yaml:"rules"`
+}
+
+// LoadRules は YAML からルールを読み込む
+func LoadRules(r io.Reader) ([]Rule, error) {
+	var file rulesFile
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse rules: %w", err)
+	}
+
+	rules := make([]Rule, 0, len(file.Rules))
+	for _, rule := range file.Rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// LoadRulesFile は YAML ファイルからルールを読み込む
+func LoadRulesFile(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rules file: %w", err)
+	}
+	defer f.Close()
+
+	rules, err := LoadRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
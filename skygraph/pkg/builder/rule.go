@@ -0,0 +1,58 @@
+package builder
+
+import (
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+// Rule はエッジ推論ルール
+// GraphBuilder は登録順にルールを実行し、NodeTypes に一致するノードごとに Infer を呼ぶ
+type Rule interface {
+	// Name はルール名を返す（DisableRule で指定する識別子）
+	Name() string
+
+	// NodeTypes は対象ノードタイプを返す（空の場合は全ノードが対象）
+	NodeTypes() []string
+
+	// Infer は1つのノードからエッジを推論する
+	// 両端のノードがグラフに存在しないエッジは GraphBuilder が捨てる
+	Infer(g *graph.Graph, node graph.ResourceNode) ([]graph.Edge, error)
+}
+
+// Preparer は推論前にグラフ全体から索引を作るルール
+// InferEdges がこのルールを適用する直前に Prepare が、適用し終えた直後に Finish が呼ばれる
+// （ルールごとに呼ばれるので、Prepare のグラフには先に適用されたルールが推論したエッジも含まれる）
+type Preparer interface {
+	Prepare(g *graph.Graph)
+	Finish()
+}
+
+// RuleFunc は関数をルールとして登録するためのアダプタ
+type RuleFunc struct {
+	RuleName  string
+	Types     []string
+	InferFunc func(g *graph.Graph, node graph.ResourceNode) ([]graph.Edge, error)
+}
+
+// NewRuleFunc は関数からルールを作成
+func NewRuleFunc(name string, nodeTypes []string, infer func(g *graph.Graph, node graph.ResourceNode) ([]graph.Edge, error)) *RuleFunc {
+	return &RuleFunc{
+		RuleName:  name,
+		Types:     nodeTypes,
+		InferFunc: infer,
+	}
+}
+
+// Name はルール名を返す
+func (r *RuleFunc) Name() string {
+	return r.RuleName
+}
+
+// NodeTypes は対象ノードタイプを返す
+func (r *RuleFunc) NodeTypes() []string {
+	return r.Types
+}
+
+// Infer は関数を呼び出してエッジを推論
+func (r *RuleFunc) Infer(g *graph.Graph, node graph.ResourceNode) ([]graph.Edge, error) {
+	return r.InferFunc(g, node)
+}
@@ -0,0 +1,323 @@
+package builder
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+func hasEdge(g *graph.Graph, from, to, edgeType string) bool {
+	for _, edge := range g.Edges {
+		if edge.From == from && edge.To == to && edge.Type == edgeType {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMetadataRule_CustomTypes(t *testing.T) {
+	b := NewGraphBuilderWithRules(
+		&MetadataRule{RuleName: "fn-queue", Types: []string{"function"},
+			MetadataKey: "queues", Target: "custom:queue:%s", EdgeType: "dependency", Direction: DirectionToTarget,
+			EdgeMetadata: map[string]interface{}{"inferred": true}},
+		&MetadataRule{RuleName: "topic-fn", Types: []string{"function"},
+			MetadataKey: "topic", Target: "custom:topic:%s", EdgeType: "network"},
+	)
+	b.AddNodes([]graph.ResourceNode{
+		{ID: "custom:function:f1", Type: "function", Metadata: map[string]interface{}{
+			// JSON から読み込んだグラフと同じ形式
+			"queues": []interface{}{"q1", "q2", 3},
+			"topic":  "t1",
+		}},
+		{ID: "custom:queue:q1", Type: "queue"},
+		{ID: "custom:queue:q2", Type: "queue"},
+		{ID: "custom:topic:t1", Type: "topic"},
+	})
+
+	if err := b.InferEdges(); err != nil {
+		t.Fatalf("InferEdges() error = %v", err)
+	}
+
+	g := b.Build()
+	if g.EdgeCount() != 3 {
+		t.Fatalf("Expected 3 edges, got %d: %+v", g.EdgeCount(), g.Edges)
+	}
+	for _, want := range [][3]string{
+		{"custom:function:f1", "custom:queue:q1", "dependency"},
+		{"custom:function:f1", "custom:queue:q2", "dependency"},
+		{"custom:topic:t1", "custom:function:f1", "network"},
+	} {
+		if !hasEdge(g, want[0], want[1], want[2]) {
+			t.Errorf("Missing edge %v", want)
+		}
+	}
+	if g.Edges[0].Metadata["inferred"] != true {
+		t.Errorf("Expected edge metadata to be copied, got %v", g.Edges[0].Metadata)
+	}
+}
+
+func TestMetadataRule_TargetID(t *testing.T) {
+	rule := &MetadataRule{Target: "aws:subnet:%s"}
+
+	tests := []struct {
+		name string
+		node graph.ResourceNode
+		want string
+	}{
+		{"legacy", graph.ResourceNode{Region: "us-east-1"}, "aws:subnet:subnet-1"},
+		{"account", graph.ResourceNode{Account: "111111111111", Region: "us-east-1"},
+			"aws:111111111111:us-east-1:subnet:subnet-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rule.targetID(tt.node, "subnet-1"); got != tt.want {
+				t.Errorf("targetID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	node := graph.ResourceNode{Metadata: map[string]interface{}{"cluster": "prod", "namespace": "web"}}
+	if got := (&MetadataRule{Target: "k8s:configmap:%s"}).targetID(node, "cfg"); got != "k8s:prod:configmap:web/cfg" {
+		t.Errorf("Unexpected namespaced target: %s", got)
+	}
+	if got := (&MetadataRule{Target: "k8s:node:%s"}).targetID(node, "n1"); got != graph.K8sNodeID("prod", "node", "", "n1") {
+		t.Errorf("Unexpected cluster-scoped target: %s", got)
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	rules, err := LoadRules(strings.NewReader(`
+rules:
+  - name: lambda-subnet
+    node_types: [lambda]
+    metadata_key: subnet_ids
+    target: "aws:subnet:%s"
+    edge_type: network
+  - name: lambda-role
+    node_types: [lambda]
+    metadata_key: role
+    target: "aws:iam_role:%s"
+    edge_type: dependency
+    direction: to_target
+`))
+	if err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+	if len(rules) != 2 || rules[0].Name() != "lambda-subnet" || rules[1].Name() != "lambda-role" {
+		t.Fatalf("Unexpected rules: %+v", rules)
+	}
+
+	b := NewGraphBuilderWithRules(rules...)
+	b.AddNodes([]graph.ResourceNode{
+		{ID: "aws:lambda:fn", Type: "lambda", Region: "us-east-1", Metadata: map[string]interface{}{
+			"subnet_ids": []string{"subnet-1"},
+			"role":       "app",
+		}},
+		{ID: "aws:subnet:subnet-1", Type: "subnet", Region: "us-east-1"},
+		{ID: "aws:iam_role:app", Type: "iam_role"},
+	})
+	if err := b.InferEdges(); err != nil {
+		t.Fatalf("InferEdges() error = %v", err)
+	}
+	if !hasEdge(b.Build(), "aws:subnet:subnet-1", "aws:lambda:fn", "network") {
+		t.Error("Missing subnet → lambda edge")
+	}
+	if !hasEdge(b.Build(), "aws:lambda:fn", "aws:iam_role:app", "dependency") {
+		t.Error("Missing lambda → role edge")
+	}
+
+	// 空のファイルはルールなし
+	rules, err = LoadRules(strings.NewReader(""))
+	if err != nil || len(rules) != 0 {
+		t.Errorf("Expected no rules for empty input, got %v, %v", rules, err)
+	}
+}
+
+func TestLoadRules_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"missing target placeholder", `
+rules:
+  - name: bad
+    metadata_key: vpc_id
+    target: "aws:vpc"
+    edge_type: ownership
+`, "target must contain %s"},
+		{"unknown direction", `
+rules:
+  - name: bad
+    metadata_key: vpc_id
+    target: "aws:vpc:%s"
+    edge_type: ownership
+    direction: sideways
+`, "unknown direction"},
+		{"unknown field", `
+rules:
+  - name: bad
+    metadata: vpc_id
+`, "field metadata not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadRules(strings.NewReader(tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadRules() error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphBuilder_AddAndDisableRules(t *testing.T) {
+	b := NewGraphBuilder()
+
+	if !b.DisableRule("ec2-rds-reachability") {
+		t.Fatal("Expected ec2-rds-reachability to be disabled")
+	}
+	if b.DisableRule("ec2-rds-reachability") {
+		t.Error("Expected second DisableRule to report false")
+	}
+
+	b.AddRule(NewRuleFunc("custom", []string{"ec2"}, func(g *graph.Graph, node graph.ResourceNode) ([]graph.Edge, error) {
+		return nil, nil
+	}))
+
+	names := b.Rules()
+	if names[len(names)-1] != "custom" {
+		t.Errorf("Expected custom rule to run last, got %v", names)
+	}
+	for _, name := range names {
+		if name == "ec2-rds-reachability" {
+			t.Errorf("Disabled rule still registered: %v", names)
+		}
+	}
+
+	// 無効化は他の GraphBuilder のルールに影響しない
+	defaults := NewGraphBuilder().Rules()
+	if len(defaults) != len(names) || !containsString(defaults, "ec2-rds-reachability") || containsString(defaults, "custom") {
+		t.Errorf("Expected defaults to be unaffected, got %v", defaults)
+	}
+}
+
+func TestGraphBuilder_DeduplicatesEdges(t *testing.T) {
+	calls := 0
+	dup := func(g *graph.Graph, node graph.ResourceNode) ([]graph.Edge, error) {
+		calls++
+		return []graph.Edge{
+			{From: "a", To: "b", Type: "network"},
+			{From: "a", To: "b", Type: "network"},
+			{From: "a", To: "missing", Type: "network"},
+		}, nil
+	}
+
+	b := NewGraphBuilderWithRules(
+		NewRuleFunc("first", []string{"x"}, dup),
+		NewRuleFunc("second", []string{"x"}, dup),
+	)
+	b.AddNodes([]graph.ResourceNode{{ID: "a", Type: "x"}, {ID: "b", Type: "y"}})
+
+	if err := b.InferEdges(); err != nil {
+		t.Fatalf("InferEdges() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected rules to run only for type x, got %d calls", calls)
+	}
+	if b.Build().EdgeCount() != 1 {
+		t.Errorf("Expected 1 edge, got %+v", b.Build().Edges)
+	}
+}
+
+func TestGraphBuilder_DefaultAWSRules(t *testing.T) {
+	const account, region = "111111111111", "us-east-1"
+	id := func(resourceType, resourceID string) string {
+		return graph.AWSNodeID(account, region, resourceType, resourceID)
+	}
+	node := func(resourceType, resourceID string, metadata map[string]interface{}) graph.ResourceNode {
+		return graph.ResourceNode{ID: id(resourceType, resourceID), Type: resourceType, Provider: "aws",
+			Account: account, Region: region, Metadata: metadata}
+	}
+
+	b := NewGraphBuilder()
+	b.AddNodes([]graph.ResourceNode{
+		node("vpc", "vpc-1", nil),
+		node("subnet", "subnet-1", map[string]interface{}{"vpc_id": "vpc-1", "cidr_block": "10.0.1.0/24"}),
+		{ID: id("sg", "sg-1"), Type: "security_group", Provider: "aws", Account: account, Region: region,
+			Metadata: map[string]interface{}{
+				"group_id": "sg-1", "vpc_id": "vpc-1",
+				"egress_rules": []map[string]interface{}{{"protocol": "-1", "cidr_blocks": []string{"0.0.0.0/0"}}},
+			}},
+		{ID: id("sg", "sg-db"), Type: "security_group", Provider: "aws", Account: account, Region: region,
+			Metadata: map[string]interface{}{
+				"group_id": "sg-db", "vpc_id": "vpc-1",
+				"ingress_rules": []map[string]interface{}{
+					{"protocol": "tcp", "from_port": int32(5432), "to_port": int32(5432), "security_groups": []string{"sg-1"}},
+				},
+			}},
+		node("ec2", "i-1", map[string]interface{}{
+			"vpc_id": "vpc-1", "subnet_id": "subnet-1", "security_groups": []string{"sg-1"}, "private_ip": "10.0.1.10",
+		}),
+		// 同じ VPC でも DB の Security Group で許可されていない EC2
+		node("ec2", "i-2", map[string]interface{}{
+			"vpc_id": "vpc-1", "subnet_id": "subnet-1", "security_groups": []string{}, "private_ip": "10.0.1.11",
+		}),
+		node("rds", "db-1", map[string]interface{}{
+			"vpc_id": "vpc-1", "subnet_ids": []string{"subnet-1"}, "security_groups": []string{"sg-db"}, "port": 5432,
+		}),
+	})
+	if err := b.InferEdges(); err != nil {
+		t.Fatalf("InferEdges() error = %v", err)
+	}
+
+	g := b.Build()
+	for _, want := range [][3]string{
+		{id("vpc", "vpc-1"), id("subnet", "subnet-1"), "ownership"},
+		{id("vpc", "vpc-1"), id("sg", "sg-1"), "ownership"},
+		{id("subnet", "subnet-1"), id("ec2", "i-1"), "network"},
+		{id("sg", "sg-1"), id("ec2", "i-1"), "network"},
+		{id("subnet", "subnet-1"), id("rds", "db-1"), "network"},
+		{id("ec2", "i-1"), id("rds", "db-1"), "network"},
+	} {
+		if !hasEdge(g, want[0], want[1], want[2]) {
+			t.Errorf("Missing edge %v", want)
+		}
+	}
+
+	for _, edge := range g.Edges {
+		if edge.To != id("rds", "db-1") || edge.From == id("subnet", "subnet-1") || edge.From == id("sg", "sg-db") {
+			continue
+		}
+		if edge.From != id("ec2", "i-1") {
+			t.Errorf("Unexpected edge to RDS: %+v", edge)
+			continue
+		}
+		if edge.Metadata["port"] != 5432 || edge.Metadata["protocol"] != "tcp" || edge.Metadata["ingress"] != "sg-1" {
+			t.Errorf("Unexpected reachability metadata: %v", edge.Metadata)
+		}
+	}
+}
+
+func TestGraphBuilder_RuleError(t *testing.T) {
+	b := NewGraphBuilderWithRules(NewRuleFunc("broken", nil, func(g *graph.Graph, node graph.ResourceNode) ([]graph.Edge, error) {
+		return nil, errors.New("boom")
+	}))
+	b.AddNodes([]graph.ResourceNode{{ID: "a", Type: "x"}})
+
+	err := b.InferEdges()
+	if err == nil || !strings.Contains(err.Error(), "rule broken") {
+		t.Errorf("Expected wrapped rule error, got %v", err)
+	}
+}
@@ -0,0 +1,346 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ChangeType describes how a node, edge or field changed between two graphs
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeRemoved  ChangeType = "removed"
+	ChangeModified ChangeType = "modified"
+)
+
+// FieldChange is a single field-level change of a node
+type FieldChange struct {
+	// Key is the field name ("name", "region", ...) or the Metadata / Tags key
+	Key string `json:"key"`
+
+	// Change is added, removed or modified
+	Change ChangeType `json:"change"`
+
+	// Before is the old value (null for added)
+	Before interface{} `json:"before"`
+
+	// After is the new value (null for removed)
+	After interface{} `json:"after"`
+}
+
+// NodeChange describes a node that exists in both graphs but differs
+type NodeChange struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+
+	// Fields holds changes to Type, Provider, Region, Account and Name
+	Fields []FieldChange `json:"fields,omitempty"`
+
+	// Metadata holds per-key changes to Metadata
+	Metadata []FieldChange `json:"metadata,omitempty"`
+
+	// Tags holds per-key changes to Tags
+	Tags []FieldChange `json:"tags,omitempty"`
+}
+
+// GraphDiff is the result of comparing two graph snapshots.
+// CreatedAt and UpdatedAt are ignored since scanners set them to the scan time.
+type GraphDiff struct {
+	AddedNodes    []ResourceNode `json:"added_nodes"`
+	RemovedNodes  []ResourceNode `json:"removed_nodes"`
+	ModifiedNodes []NodeChange   `json:"modified_nodes"`
+	AddedEdges    []Edge         `json:"added_edges"`
+	RemovedEdges  []Edge         `json:"removed_edges"`
+}
+
+// DiffSummary holds the number of changes per category
+type DiffSummary struct {
+	AddedNodes    int `json:"added_nodes"`
+	RemovedNodes  int `json:"removed_nodes"`
+	ModifiedNodes int `json:"modified_nodes"`
+	AddedEdges    int `json:"added_edges"`
+	RemovedEdges  int `json:"removed_edges"`
+}
+
+// Diff compares two graphs and returns the changes from prev to next.
+// Edges are identified by (From, To, Type). Either graph may be nil.
+func Diff(prev, next *Graph) *GraphDiff {
+	if prev == nil {
+		prev = NewGraph()
+	}
+	if next == nil {
+		next = NewGraph()
+	}
+
+	diff := &GraphDiff{
+		AddedNodes:    make([]ResourceNode, 0),
+		RemovedNodes:  make([]ResourceNode, 0),
+		ModifiedNodes: make([]NodeChange, 0),
+		AddedEdges:    make([]Edge, 0),
+		RemovedEdges:  make([]Edge, 0),
+	}
+
+	// Nodes
+	for i := range next.Nodes {
+		node := &next.Nodes[i]
+		if next.FindNode(node.ID) != node {
+			// Duplicate ID: only the first occurrence is considered
+			continue
+		}
+
+		prevNode := prev.FindNode(node.ID)
+		if prevNode == nil {
+			diff.AddedNodes = append(diff.AddedNodes, *node)
+			continue
+		}
+
+		if change := diffNode(prevNode, node); change != nil {
+			diff.ModifiedNodes = append(diff.ModifiedNodes, *change)
+		}
+	}
+
+	for i := range prev.Nodes {
+		node := &prev.Nodes[i]
+		if prev.FindNode(node.ID) != node {
+			continue
+		}
+		if !next.HasNode(node.ID) {
+			diff.RemovedNodes = append(diff.RemovedNodes, *node)
+		}
+	}
+
+	// Edges
+	prevEdges := edgeSet(prev)
+	nextEdges := edgeSet(next)
+
+	for key, edge := range nextEdges {
+		if _, exists := prevEdges[key]; !exists {
+			diff.AddedEdges = append(diff.AddedEdges, edge)
+		}
+	}
+	for key, edge := range prevEdges {
+		if _, exists := nextEdges[key]; !exists {
+			diff.RemovedEdges = append(diff.RemovedEdges, edge)
+		}
+	}
+
+	sortNodes(diff.AddedNodes)
+	sortNodes(diff.RemovedNodes)
+	sort.Slice(diff.ModifiedNodes, func(i, j int) bool { return diff.ModifiedNodes[i].ID < diff.ModifiedNodes[j].ID })
+	sortEdges(diff.AddedEdges)
+	sortEdges(diff.RemovedEdges)
+
+	return diff
+}
+
+// diffNode returns the field-level changes between two versions of a node, or nil if equal
+func diffNode(prev, next *ResourceNode) *NodeChange {
+	change := &NodeChange{
+		ID:   next.ID,
+		Type: next.Type,
+	}
+
+	fields := []struct {
+		key        string
+		prev, next string
+	}{
+		{"type", prev.Type, next.Type},
+		{"provider", prev.Provider, next.Provider},
+		{"region", prev.Region, next.Region},
+		{"account", prev.Account, next.Account},
+		{"name", prev.Name, next.Name},
+	}
+	for _, f := range fields {
+		if f.prev != f.next {
+			change.Fields = append(change.Fields, FieldChange{
+				Key:    f.key,
+				Change: ChangeModified,
+				Before: f.prev,
+				After:  f.next,
+			})
+		}
+	}
+
+	change.Metadata = diffMaps(prev.Metadata, next.Metadata)
+
+	prevTags := make(map[string]interface{}, len(prev.Tags))
+	for k, v := range prev.Tags {
+		prevTags[k] = v
+	}
+	nextTags := make(map[string]interface{}, len(next.Tags))
+	for k, v := range next.Tags {
+		nextTags[k] = v
+	}
+	change.Tags = diffMaps(prevTags, nextTags)
+
+	if len(change.Fields) == 0 && len(change.Metadata) == 0 && len(change.Tags) == 0 {
+		return nil
+	}
+	return change
+}
+
+// diffMaps compares two maps key by key, returning changes sorted by key
+func diffMaps(prev, next map[string]interface{}) []FieldChange {
+	var changes []FieldChange
+
+	for key, nextVal := range next {
+		prevVal, exists := prev[key]
+		if !exists {
+			changes = append(changes, FieldChange{Key: key, Change: ChangeAdded, After: nextVal})
+		} else if !valuesEqual(prevVal, nextVal) {
+			changes = append(changes, FieldChange{Key: key, Change: ChangeModified, Before: prevVal, After: nextVal})
+		}
+	}
+	for key, prevVal := range prev {
+		if _, exists := next[key]; !exists {
+			changes = append(changes, FieldChange{Key: key, Change: ChangeRemoved, Before: prevVal})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// valuesEqual compares two values by their JSON encoding so that a freshly
+// scanned int32 and a float64 decoded from a snapshot compare equal
+func valuesEqual(a, b interface{}) bool {
+	aJSON, errA := json.Marshal(a)
+	bJSON, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return string(aJSON) == string(bJSON)
+}
+
+// edgeKey identifies an edge for diffing
+func edgeKey(edge Edge) string {
+	return edge.From + "\x00" + edge.To + "\x00" + edge.Type
+}
+
+// edgeSet returns the edges of a graph keyed by edgeKey
+func edgeSet(g *Graph) map[string]Edge {
+	set := make(map[string]Edge, len(g.Edges))
+	for _, edge := range g.Edges {
+		key := edgeKey(edge)
+		if _, exists := set[key]; !exists {
+			set[key] = edge
+		}
+	}
+	return set
+}
+
+func sortNodes(nodes []ResourceNode) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+}
+
+func sortEdges(edges []Edge) {
+	sort.Slice(edges, func(i, j int) bool { return edgeKey(edges[i]) < edgeKey(edges[j]) })
+}
+
+// IsEmpty reports whether the two graphs were identical
+func (d *GraphDiff) IsEmpty() bool {
+	return len(d.AddedNodes) == 0 && len(d.RemovedNodes) == 0 && len(d.ModifiedNodes) == 0 &&
+		len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0
+}
+
+// Summary returns the number of changes per category
+func (d *GraphDiff) Summary() DiffSummary {
+	return DiffSummary{
+		AddedNodes:    len(d.AddedNodes),
+		RemovedNodes:  len(d.RemovedNodes),
+		ModifiedNodes: len(d.ModifiedNodes),
+		AddedEdges:    len(d.AddedEdges),
+		RemovedEdges:  len(d.RemovedEdges),
+	}
+}
+
+// WriteText writes a human-readable report of the diff
+func (d *GraphDiff) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	s := d.Summary()
+	fmt.Fprintf(&b, "Nodes: +%d -%d ~%d\n", s.AddedNodes, s.RemovedNodes, s.ModifiedNodes)
+	fmt.Fprintf(&b, "Edges: +%d -%d\n", s.AddedEdges, s.RemovedEdges)
+
+	if d.IsEmpty() {
+		b.WriteString("\nNo changes\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	if len(d.AddedNodes) > 0 {
+		b.WriteString("\nAdded nodes:\n")
+		for _, node := range d.AddedNodes {
+			fmt.Fprintf(&b, "  + %s (%s)%s\n", node.ID, node.Type, formatName(node.Name))
+		}
+	}
+
+	if len(d.RemovedNodes) > 0 {
+		b.WriteString("\nRemoved nodes:\n")
+		for _, node := range d.RemovedNodes {
+			fmt.Fprintf(&b, "  - %s (%s)%s\n", node.ID, node.Type, formatName(node.Name))
+		}
+	}
+
+	if len(d.ModifiedNodes) > 0 {
+		b.WriteString("\nModified nodes:\n")
+		for _, change := range d.ModifiedNodes {
+			fmt.Fprintf(&b, "  ~ %s (%s)\n", change.ID, change.Type)
+			writeFieldChanges(&b, "", change.Fields)
+			writeFieldChanges(&b, "metadata.", change.Metadata)
+			writeFieldChanges(&b, "tags.", change.Tags)
+		}
+	}
+
+	if len(d.AddedEdges) > 0 {
+		b.WriteString("\nAdded edges:\n")
+		for _, edge := range d.AddedEdges {
+			fmt.Fprintf(&b, "  + %s -> %s [%s]\n", edge.From, edge.To, edge.Type)
+		}
+	}
+
+	if len(d.RemovedEdges) > 0 {
+		b.WriteString("\nRemoved edges:\n")
+		for _, edge := range d.RemovedEdges {
+			fmt.Fprintf(&b, "  - %s -> %s [%s]\n", edge.From, edge.To, edge.Type)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeFieldChanges(b *strings.Builder, prefix string, changes []FieldChange) {
+	for _, c := range changes {
+		switch c.Change {
+		case ChangeAdded:
+			fmt.Fprintf(b, "      + %s%s: %s\n", prefix, c.Key, formatValue(c.After))
+		case ChangeRemoved:
+			fmt.Fprintf(b, "      - %s%s: %s\n", prefix, c.Key, formatValue(c.Before))
+		default:
+			fmt.Fprintf(b, "      ~ %s%s: %s -> %s\n", prefix, c.Key, formatValue(c.Before), formatValue(c.After))
+		}
+	}
+}
+
+func formatName(name string) string {
+	if name == "" {
+		return ""
+	}
+	return " " + name
+}
+
+func formatValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
@@ -0,0 +1,168 @@
+package graph
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createSnapshot() *Graph {
+	g := NewGraph()
+
+	g.AddNode(ResourceNode{
+		ID: "aws:vpc:vpc-1", Type: "vpc", Provider: "aws", Region: "us-east-1", Name: "main",
+		Metadata: map[string]interface{}{"cidr_block": "10.0.0.0/16"},
+	})
+	g.AddNode(ResourceNode{
+		ID: "aws:ec2:i-1", Type: "ec2", Provider: "aws", Region: "us-east-1", Name: "web-1",
+		Metadata: map[string]interface{}{
+			"instance_type":   "t3.micro",
+			"port":            5432,
+			"security_groups": []string{"sg-1"},
+		},
+		Tags:      map[string]string{"Environment": "production", "Owner": "alice"},
+		UpdatedAt: time.Now(),
+	})
+	g.AddNode(ResourceNode{ID: "aws:ec2:i-2", Type: "ec2", Provider: "aws", Region: "us-east-1"})
+
+	g.AddEdge(Edge{From: "aws:vpc:vpc-1", To: "aws:ec2:i-1", Type: "network"})
+	g.AddEdge(Edge{From: "aws:vpc:vpc-1", To: "aws:ec2:i-2", Type: "network"})
+
+	return g
+}
+
+func TestDiff_Identical(t *testing.T) {
+	diff := Diff(createSnapshot(), createSnapshot())
+
+	if !diff.IsEmpty() {
+		t.Errorf("Expected empty diff, got %+v", diff.Summary())
+	}
+}
+
+func TestDiff_Changes(t *testing.T) {
+	old := createSnapshot()
+	new := createSnapshot()
+
+	// i-1: instance_type 変更, Owner タグ削除, Team タグ追加, name 変更
+	node := new.FindNode("aws:ec2:i-1")
+	node.Name = "web-1-renamed"
+	node.Metadata["instance_type"] = "t3.large"
+	node.Metadata["ebs_optimized"] = true
+	delete(node.Tags, "Owner")
+	node.Tags["Team"] = "platform"
+	node.UpdatedAt = time.Now().Add(time.Hour)
+
+	// i-2 を削除、i-3 を追加
+	new.RemoveNode("aws:ec2:i-2")
+	new.AddNode(ResourceNode{ID: "aws:ec2:i-3", Type: "ec2", Provider: "aws"})
+	new.AddEdge(Edge{From: "aws:vpc:vpc-1", To: "aws:ec2:i-3", Type: "network"})
+
+	diff := Diff(old, new)
+
+	summary := diff.Summary()
+	expected := DiffSummary{AddedNodes: 1, RemovedNodes: 1, ModifiedNodes: 1, AddedEdges: 1, RemovedEdges: 1}
+	if summary != expected {
+		t.Fatalf("Expected summary %+v, got %+v", expected, summary)
+	}
+
+	if diff.AddedNodes[0].ID != "aws:ec2:i-3" {
+		t.Errorf("Expected i-3 to be added, got %s", diff.AddedNodes[0].ID)
+	}
+	if diff.RemovedNodes[0].ID != "aws:ec2:i-2" {
+		t.Errorf("Expected i-2 to be removed, got %s", diff.RemovedNodes[0].ID)
+	}
+	if diff.RemovedEdges[0].To != "aws:ec2:i-2" || diff.AddedEdges[0].To != "aws:ec2:i-3" {
+		t.Errorf("Unexpected edge changes: +%+v -%+v", diff.AddedEdges, diff.RemovedEdges)
+	}
+
+	change := diff.ModifiedNodes[0]
+	if len(change.Fields) != 1 || change.Fields[0].Key != "name" {
+		t.Errorf("Expected name field change, got %+v", change.Fields)
+	}
+
+	if len(change.Metadata) != 2 {
+		t.Fatalf("Expected 2 metadata changes, got %+v", change.Metadata)
+	}
+	if change.Metadata[0].Key != "ebs_optimized" || change.Metadata[0].Change != ChangeAdded {
+		t.Errorf("Expected ebs_optimized added, got %+v", change.Metadata[0])
+	}
+	if change.Metadata[1].Key != "instance_type" || change.Metadata[1].Change != ChangeModified ||
+		change.Metadata[1].Before != "t3.micro" || change.Metadata[1].After != "t3.large" {
+		t.Errorf("Expected instance_type modified, got %+v", change.Metadata[1])
+	}
+
+	// 値のない側も null として出力する（false や "" への変化と区別できるように）
+	data, err := json.Marshal(change.Metadata[0])
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"before":null`) || !strings.Contains(string(data), `"after":`) {
+		t.Errorf("Expected before and after in JSON, got %s", data)
+	}
+
+	if len(change.Tags) != 2 {
+		t.Fatalf("Expected 2 tag changes, got %+v", change.Tags)
+	}
+	if change.Tags[0].Key != "Owner" || change.Tags[0].Change != ChangeRemoved {
+		t.Errorf("Expected Owner removed, got %+v", change.Tags[0])
+	}
+	if change.Tags[1].Key != "Team" || change.Tags[1].Change != ChangeAdded {
+		t.Errorf("Expected Team added, got %+v", change.Tags[1])
+	}
+}
+
+func TestDiff_JSONSnapshotVsLiveValues(t *testing.T) {
+	// JSON から読み込んだスナップショット（数値は float64、配列は []interface{}）と
+	// スキャン直後の値（int, []string）は同じ値として扱う
+	data, err := json.Marshal(createSnapshot())
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var decoded Graph
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if diff := Diff(&decoded, createSnapshot()); !diff.IsEmpty() {
+		t.Errorf("Expected empty diff, got %+v", diff.ModifiedNodes)
+	}
+}
+
+func TestDiff_NilGraphs(t *testing.T) {
+	diff := Diff(nil, createSnapshot())
+	if len(diff.AddedNodes) != 3 || len(diff.AddedEdges) != 2 {
+		t.Errorf("Expected everything to be added, got %+v", diff.Summary())
+	}
+
+	diff = Diff(createSnapshot(), nil)
+	if len(diff.RemovedNodes) != 3 || len(diff.RemovedEdges) != 2 {
+		t.Errorf("Expected everything to be removed, got %+v", diff.Summary())
+	}
+}
+
+func TestGraphDiff_WriteText(t *testing.T) {
+	old := createSnapshot()
+	new := createSnapshot()
+	new.FindNode("aws:ec2:i-1").Metadata["instance_type"] = "t3.large"
+	new.RemoveNode("aws:ec2:i-2")
+
+	var b strings.Builder
+	if err := Diff(old, new).WriteText(&b); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+
+	out := b.String()
+	for _, want := range []string{
+		"Nodes: +0 -1 ~1",
+		"  - aws:ec2:i-2 (ec2)",
+		"  ~ aws:ec2:i-1 (ec2)",
+		`      ~ metadata.instance_type: "t3.micro" -> "t3.large"`,
+		"  - aws:vpc:vpc-1 -> aws:ec2:i-2 [network]",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
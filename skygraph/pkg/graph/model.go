@@ -1,6 +1,11 @@
 package graph
 
-import "time"
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
 
 // ResourceNode represents a cloud resource in the graph
 type ResourceNode struct {
@@ -16,6 +21,9 @@ type ResourceNode struct {
 	// Region or zone
 	Region string `json:"region,omitempty"`
 
+	// Cloud account (e.g., AWS account ID) the resource belongs to
+	Account string `json:"account,omitempty"`
+
 	// Human-readable name
 	Name string `json:"name,omitempty"`
 
@@ -30,6 +38,59 @@ type ResourceNode struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// AWSNodeID returns the node ID of an AWS resource.
+//
+// When the account is known the ID is "aws:<account>:<region>:<type>:<id>", so
+// that identical resource IDs (e.g. RDS identifiers) in different accounts or
+// regions never collide in a merged graph. Without an account it falls back to
+// the short form "aws:<type>:<id>", which scans of a single account and region
+// use.
+func AWSNodeID(account, region, resourceType, resourceID string) string {
+	if account == "" {
+		return "aws:" + resourceType + ":" + resourceID
+	}
+	return "aws:" + account + ":" + region + ":" + resourceType + ":" + resourceID
+}
+
+// ShortAWSNodeID returns the short form "aws:<type>:<id>" of an account-scoped
+// AWS node ID. Other IDs are returned unchanged.
+func ShortAWSNodeID(id string) string {
+	parts := strings.SplitN(id, ":", 5)
+	if len(parts) != 5 || parts[0] != "aws" || !isAWSAccountID(parts[1]) {
+		return id
+	}
+	return "aws:" + parts[3] + ":" + parts[4]
+}
+
+// isAWSAccountID reports whether s is a 12-digit AWS account ID
+func isAWSAccountID(s string) bool {
+	if len(s) != 12 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// K8sNodeID returns the node ID of a Kubernetes object.
+//
+// Namespaced objects use "<namespace>/<name>" as the object key. When the
+// cluster is known the ID is "k8s:<cluster>:<kind>:<key>", otherwise the short
+// form "k8s:<kind>:<key>".
+func K8sNodeID(cluster, kind, namespace, name string) string {
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	if cluster == "" {
+		return "k8s:" + kind + ":" + key
+	}
+	return "k8s:" + cluster + ":" + kind + ":" + key
+}
+
 // Edge represents a relationship between two resources
 type Edge struct {
 	// Source node ID
@@ -54,48 +115,248 @@ type Edge struct {
 }
 
 // Graph represents the complete resource graph
+//
+// Nodes and Edges are kept as plain slices for JSON compatibility. An internal
+// ID index and in/out adjacency lists are built by the writes (AddNode, AddEdge,
+// RemoveNode, RemoveEdge, Reindex and JSON decoding) and never by the reads, so
+// concurrent reads are safe. A graph built as a struct literal is searched
+// linearly until its first write. Code that mutates Nodes or Edges directly must
+// call Reindex afterwards.
 type Graph struct {
 	Nodes []ResourceNode `json:"nodes"`
 	Edges []Edge         `json:"edges"`
+
+	// nodeIndex maps node ID to its position in Nodes (first occurrence wins)
+	nodeIndex map[string]int
+
+	// outEdges / inEdges map node ID to positions in Edges
+	outEdges map[string][]int
+	inEdges  map[string][]int
 }
 
 // NewGraph creates a new empty graph
 func NewGraph() *Graph {
-	return &Graph{
+	g := &Graph{
 		Nodes: make([]ResourceNode, 0),
 		Edges: make([]Edge, 0),
 	}
+	g.Reindex()
+	return g
+}
+
+// Reindex rebuilds the node index and adjacency lists from Nodes and Edges
+func (g *Graph) Reindex() {
+	g.nodeIndex = make(map[string]int, len(g.Nodes))
+	g.outEdges = make(map[string][]int)
+	g.inEdges = make(map[string][]int)
+
+	for i := range g.Nodes {
+		if _, exists := g.nodeIndex[g.Nodes[i].ID]; !exists {
+			g.nodeIndex[g.Nodes[i].ID] = i
+		}
+	}
+	for i := range g.Edges {
+		g.outEdges[g.Edges[i].From] = append(g.outEdges[g.Edges[i].From], i)
+		g.inEdges[g.Edges[i].To] = append(g.inEdges[g.Edges[i].To], i)
+	}
+}
+
+// ensureIndex builds the index of a graph created as a struct literal.
+// Only the writes call it; the reads don't change the graph.
+func (g *Graph) ensureIndex() {
+	if g.nodeIndex == nil {
+		g.Reindex()
+	}
+}
+
+// UnmarshalJSON decodes the graph and rebuilds the index
+func (g *Graph) UnmarshalJSON(data []byte) error {
+	type plain struct {
+		Nodes []ResourceNode `json:"nodes"`
+		Edges []Edge         `json:"edges"`
+	}
+
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+
+	g.Nodes = p.Nodes
+	g.Edges = p.Edges
+	if g.Nodes == nil {
+		g.Nodes = make([]ResourceNode, 0)
+	}
+	if g.Edges == nil {
+		g.Edges = make([]Edge, 0)
+	}
+	g.Reindex()
+
+	return nil
 }
 
 // AddNode adds a node to the graph
 func (g *Graph) AddNode(node ResourceNode) {
+	g.ensureIndex()
+
 	g.Nodes = append(g.Nodes, node)
+	if _, exists := g.nodeIndex[node.ID]; !exists {
+		g.nodeIndex[node.ID] = len(g.Nodes) - 1
+	}
 }
 
 // AddEdge adds an edge to the graph
 func (g *Graph) AddEdge(edge Edge) {
+	g.ensureIndex()
+
 	g.Edges = append(g.Edges, edge)
+	i := len(g.Edges) - 1
+	g.outEdges[edge.From] = append(g.outEdges[edge.From], i)
+	g.inEdges[edge.To] = append(g.inEdges[edge.To], i)
+}
+
+// HasNode reports whether a node with the given ID exists
+func (g *Graph) HasNode(id string) bool {
+	return g.FindNode(id) != nil
 }
 
 // FindNode finds a node by ID
 func (g *Graph) FindNode(id string) *ResourceNode {
-	for i := range g.Nodes {
-		if g.Nodes[i].ID == id {
-			return &g.Nodes[i]
+	if g.nodeIndex == nil {
+		for i := range g.Nodes {
+			if g.Nodes[i].ID == id {
+				return &g.Nodes[i]
+			}
 		}
+		return nil
+	}
+
+	if i, exists := g.nodeIndex[id]; exists && i < len(g.Nodes) && g.Nodes[i].ID == id {
+		return &g.Nodes[i]
+	}
+	return nil
+}
+
+// edgePositions returns the positions in Edges of the edges leaving (out) or
+// entering the node. Without an index the edges are searched linearly.
+func (g *Graph) edgePositions(nodeID string, out bool) []int {
+	if g.nodeIndex != nil {
+		if out {
+			return g.outEdges[nodeID]
+		}
+		return g.inEdges[nodeID]
+	}
+
+	positions := make([]int, 0)
+	for i, edge := range g.Edges {
+		if (out && edge.From == nodeID) || (!out && edge.To == nodeID) {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+// LookupNode finds a node by ID like FindNode, but an account-scoped AWS ID
+// also matches the short form used by scans of a single account and region
+// (e.g. an ID built from a Terraform state ARN against such a scan)
+func (g *Graph) LookupNode(id string) *ResourceNode {
+	if node := g.FindNode(id); node != nil {
+		return node
+	}
+	if short := ShortAWSNodeID(id); short != id {
+		return g.FindNode(short)
 	}
 	return nil
 }
 
 // FindEdges finds all edges for a given node
 func (g *Graph) FindEdges(nodeID string) []Edge {
-	edges := make([]Edge, 0)
+	out := g.edgePositions(nodeID, true)
+	in := g.edgePositions(nodeID, false)
+
+	positions := make([]int, 0, len(out)+len(in))
+	positions = append(positions, out...)
+	for _, i := range in {
+		// Self-loops appear in both lists
+		if g.Edges[i].From != nodeID {
+			positions = append(positions, i)
+		}
+	}
+	sort.Ints(positions)
+
+	edges := make([]Edge, 0, len(positions))
+	for _, i := range positions {
+		edges = append(edges, g.Edges[i])
+	}
+	return edges
+}
+
+// OutEdges returns the edges whose From is the given node
+func (g *Graph) OutEdges(nodeID string) []Edge {
+	positions := g.edgePositions(nodeID, true)
+	edges := make([]Edge, 0, len(positions))
+	for _, i := range positions {
+		edges = append(edges, g.Edges[i])
+	}
+	return edges
+}
+
+// InEdges returns the edges whose To is the given node
+func (g *Graph) InEdges(nodeID string) []Edge {
+	positions := g.edgePositions(nodeID, false)
+	edges := make([]Edge, 0, len(positions))
+	for _, i := range positions {
+		edges = append(edges, g.Edges[i])
+	}
+	return edges
+}
+
+// RemoveNode removes all nodes with the given ID and every edge touching them.
+// It returns false if the node does not exist.
+func (g *Graph) RemoveNode(id string) bool {
+	if !g.HasNode(id) {
+		return false
+	}
+
+	nodes := g.Nodes[:0]
+	for _, node := range g.Nodes {
+		if node.ID != id {
+			nodes = append(nodes, node)
+		}
+	}
+	g.Nodes = nodes
+
+	edges := g.Edges[:0]
 	for _, edge := range g.Edges {
-		if edge.From == nodeID || edge.To == nodeID {
+		if edge.From != id && edge.To != id {
 			edges = append(edges, edge)
 		}
 	}
-	return edges
+	g.Edges = edges
+
+	g.Reindex()
+	return true
+}
+
+// RemoveEdge removes all edges matching from, to and edgeType.
+// An empty edgeType matches any type. It returns the number of removed edges.
+func (g *Graph) RemoveEdge(from, to, edgeType string) int {
+	g.ensureIndex()
+
+	removed := 0
+	edges := g.Edges[:0]
+	for _, edge := range g.Edges {
+		if edge.From == from && edge.To == to && (edgeType == "" || edge.Type == edgeType) {
+			removed++
+			continue
+		}
+		edges = append(edges, edge)
+	}
+	g.Edges = edges
+
+	if removed > 0 {
+		g.Reindex()
+	}
+	return removed
 }
 
 // NodeCount returns the number of nodes in the graph
@@ -1,6 +1,9 @@
 package graph
 
 import (
+	"encoding/json"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -97,6 +100,37 @@ func TestGraph_FindNode(t *testing.T) {
 	}
 }
 
+func TestGraph_LookupNode(t *testing.T) {
+	g := NewGraph()
+	g.AddNode(ResourceNode{ID: AWSNodeID("", "", "ec2", "i-1"), Type: "ec2"})
+	g.AddNode(ResourceNode{ID: AWSNodeID("222222222222", "us-west-2", "ec2", "i-2"), Type: "ec2"})
+
+	tests := []struct {
+		id   string
+		want string
+	}{
+		// 単一アカウントのスキャンの短い ID にも一致する
+		{"aws:111111111111:us-east-1:ec2:i-1", "aws:ec2:i-1"},
+		{"aws:ec2:i-1", "aws:ec2:i-1"},
+		{"aws:222222222222:us-west-2:ec2:i-2", "aws:222222222222:us-west-2:ec2:i-2"},
+		{"aws:ec2:i-2", ""},
+		{"aws:111111111111:us-east-1:ec2:i-3", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if node := g.LookupNode(tt.id); node != nil {
+			got = node.ID
+		}
+		if got != tt.want {
+			t.Errorf("LookupNode(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+
+	if got := ShortAWSNodeID("aws:s3:logs-bucket"); got != "aws:s3:logs-bucket" {
+		t.Errorf("ShortAWSNodeID of a short ID = %q", got)
+	}
+}
+
 func TestGraph_FindEdges(t *testing.T) {
 	g := NewGraph()
 
@@ -122,3 +156,205 @@ func TestGraph_FindEdges(t *testing.T) {
 		t.Errorf("Expected 0 edges for non-existent node, got %d", len(edges))
 	}
 }
+
+func TestGraph_Adjacency(t *testing.T) {
+	g := NewGraph()
+
+	g.AddNode(ResourceNode{ID: "node-1"})
+	g.AddNode(ResourceNode{ID: "node-2"})
+	g.AddNode(ResourceNode{ID: "node-3"})
+
+	g.AddEdge(Edge{From: "node-1", To: "node-2", Type: "network"})
+	g.AddEdge(Edge{From: "node-1", To: "node-3", Type: "network"})
+	g.AddEdge(Edge{From: "node-3", To: "node-2", Type: "dependency"})
+	g.AddEdge(Edge{From: "node-2", To: "node-2", Type: "self"})
+
+	if out := g.OutEdges("node-1"); len(out) != 2 {
+		t.Errorf("Expected 2 out edges for node-1, got %d", len(out))
+	}
+	if in := g.InEdges("node-2"); len(in) != 3 {
+		t.Errorf("Expected 3 in edges for node-2, got %d", len(in))
+	}
+
+	// 自己ループは1回だけ数える
+	edges := g.FindEdges("node-2")
+	if len(edges) != 3 {
+		t.Fatalf("Expected 3 edges for node-2, got %d", len(edges))
+	}
+
+	// 追加順が保たれる
+	if edges[0].From != "node-1" || edges[1].From != "node-3" || edges[2].Type != "self" {
+		t.Errorf("Expected edges in insertion order, got %+v", edges)
+	}
+}
+
+func TestGraph_DuplicateNodeID(t *testing.T) {
+	g := NewGraph()
+
+	g.AddNode(ResourceNode{ID: "node-1", Type: "vpc"})
+	g.AddNode(ResourceNode{ID: "node-1", Type: "ec2"})
+
+	// 従来通り最初に追加されたノードが返る
+	if found := g.FindNode("node-1"); found == nil || found.Type != "vpc" {
+		t.Errorf("Expected first node to win, got %+v", found)
+	}
+}
+
+func TestGraph_RemoveNode(t *testing.T) {
+	g := NewGraph()
+
+	g.AddNode(ResourceNode{ID: "node-1"})
+	g.AddNode(ResourceNode{ID: "node-2"})
+	g.AddNode(ResourceNode{ID: "node-3"})
+	g.AddEdge(Edge{From: "node-1", To: "node-2", Type: "network"})
+	g.AddEdge(Edge{From: "node-2", To: "node-3", Type: "network"})
+	g.AddEdge(Edge{From: "node-1", To: "node-3", Type: "network"})
+
+	if !g.RemoveNode("node-2") {
+		t.Fatal("Expected RemoveNode to return true")
+	}
+	if g.RemoveNode("node-2") {
+		t.Error("Expected second RemoveNode to return false")
+	}
+
+	if g.NodeCount() != 2 || g.EdgeCount() != 1 {
+		t.Errorf("Expected 2 nodes and 1 edge, got %d and %d", g.NodeCount(), g.EdgeCount())
+	}
+	if g.FindNode("node-2") != nil {
+		t.Error("Removed node should not be found")
+	}
+
+	// インデックスが詰め直されている
+	if found := g.FindNode("node-3"); found == nil || found.ID != "node-3" {
+		t.Errorf("Expected node-3 after removal, got %+v", found)
+	}
+	if edges := g.FindEdges("node-3"); len(edges) != 1 || edges[0].From != "node-1" {
+		t.Errorf("Unexpected edges for node-3: %+v", edges)
+	}
+}
+
+func TestGraph_RemoveEdge(t *testing.T) {
+	g := NewGraph()
+
+	g.AddEdge(Edge{From: "node-1", To: "node-2", Type: "network"})
+	g.AddEdge(Edge{From: "node-1", To: "node-2", Type: "dependency"})
+	g.AddEdge(Edge{From: "node-2", To: "node-3", Type: "network"})
+
+	if n := g.RemoveEdge("node-1", "node-2", "dependency"); n != 1 {
+		t.Errorf("Expected 1 removed edge, got %d", n)
+	}
+	if edges := g.OutEdges("node-1"); len(edges) != 1 || edges[0].Type != "network" {
+		t.Errorf("Unexpected out edges for node-1: %+v", edges)
+	}
+
+	if n := g.RemoveEdge("node-1", "node-2", ""); n != 1 {
+		t.Errorf("Expected 1 removed edge, got %d", n)
+	}
+	if edges := g.FindEdges("node-2"); len(edges) != 1 {
+		t.Errorf("Expected 1 edge for node-2, got %d", len(edges))
+	}
+}
+
+func TestGraph_JSONRoundTrip(t *testing.T) {
+	g := NewGraph()
+	g.AddNode(ResourceNode{ID: "node-1", Type: "vpc"})
+	g.AddNode(ResourceNode{ID: "node-2", Type: "subnet"})
+	g.AddEdge(Edge{From: "node-1", To: "node-2", Type: "ownership"})
+
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var decoded Graph
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if found := decoded.FindNode("node-2"); found == nil || found.Type != "subnet" {
+		t.Errorf("Expected node-2 after round-trip, got %+v", found)
+	}
+	if edges := decoded.InEdges("node-2"); len(edges) != 1 {
+		t.Errorf("Expected 1 in edge for node-2 after round-trip, got %d", len(edges))
+	}
+
+	// インデックスを持つグラフに追加しても整合性が保たれる
+	decoded.AddNode(ResourceNode{ID: "node-3"})
+	decoded.AddEdge(Edge{From: "node-2", To: "node-3", Type: "network"})
+	if edges := decoded.FindEdges("node-2"); len(edges) != 2 {
+		t.Errorf("Expected 2 edges for node-2, got %d", len(edges))
+	}
+}
+
+func TestGraph_DirectSliceMutation(t *testing.T) {
+	// 構造体リテラルや直接 append したグラフでも検索できる
+	g := &Graph{
+		Nodes: []ResourceNode{{ID: "node-1"}},
+		Edges: []Edge{{From: "node-1", To: "node-2"}},
+	}
+
+	if g.FindNode("node-1") == nil {
+		t.Error("Expected to find node-1 in literal graph")
+	}
+
+	g.Nodes = append(g.Nodes, ResourceNode{ID: "node-2"})
+	if g.FindNode("node-2") == nil {
+		t.Error("Expected to find directly appended node-2")
+	}
+	if edges := g.FindEdges("node-2"); len(edges) != 1 {
+		t.Errorf("Expected 1 edge for node-2, got %d", len(edges))
+	}
+}
+
+func TestGraph_ConcurrentReads(t *testing.T) {
+	// 読み取りはインデックスを作らないので、リテラルのグラフでも並行に読める（go test -race で検証）
+	g := &Graph{
+		Nodes: []ResourceNode{{ID: "node-1"}, {ID: "node-2"}},
+		Edges: []Edge{{From: "node-1", To: "node-2"}},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !g.HasNode("node-2") || g.FindNode("node-1") == nil || len(g.OutEdges("node-1")) != 1 {
+				t.Error("Expected concurrent reads to find node-1 and node-2")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if g.nodeIndex != nil {
+		t.Error("Expected reads not to build the index")
+	}
+}
+
+func TestGraph_Reindex(t *testing.T) {
+	g := NewGraph()
+	g.AddNode(ResourceNode{ID: "node-1"})
+	g.AddNode(ResourceNode{ID: "node-2"})
+
+	// インデックス作成後にスライスを直接書き換えたら Reindex で無効化する
+	g.Nodes[0].ID = "node-3"
+	if g.FindNode("node-1") != nil {
+		t.Error("Expected a stale index hit to be rejected")
+	}
+
+	g.Reindex()
+	if g.FindNode("node-3") == nil || g.FindNode("node-2") == nil {
+		t.Error("Expected to find node-3 and node-2 after Reindex")
+	}
+}
+
+func BenchmarkGraph_FindNode(b *testing.B) {
+	g := NewGraph()
+	for i := 0; i < 40000; i++ {
+		g.AddNode(ResourceNode{ID: fmt.Sprintf("aws:ec2:i-%d", i)})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.FindNode(fmt.Sprintf("aws:ec2:i-%d", i%40000))
+	}
+}
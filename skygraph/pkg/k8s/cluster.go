@@ -0,0 +1,101 @@
+package k8s
+
+import (
+	"context"
+	"strings"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewNamespaceScanner は Namespace スキャナーを作成
+// namespaces を指定した場合はその Namespace のみをノードにする
+func NewNamespaceScanner(client kubernetes.Interface, cluster string, namespaces []string) *ResourceScanner {
+	wanted := make(map[string]bool, len(namespaces))
+	for _, ns := range namespaces {
+		wanted[ns] = true
+	}
+
+	return newResourceScanner("namespace", nil, func(ctx context.Context, _ string, opts metav1.ListOptions) ([]graph.ResourceNode, string, error) {
+		list, err := client.CoreV1().Namespaces().List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+
+		nodes := make([]graph.ResourceNode, 0, len(list.Items))
+		for i := range list.Items {
+			ns := &list.Items[i]
+			if len(wanted) > 0 && !wanted[ns.Name] {
+				continue
+			}
+
+			node := newNode(cluster, "namespace", ns)
+			node.Metadata["phase"] = string(ns.Status.Phase)
+			nodes = append(nodes, node)
+		}
+		return nodes, list.Continue, nil
+	})
+}
+
+// NewNodeScanner は Node スキャナーを作成
+// AWS 上のノードは providerID から EC2 インスタンス ID を抽出して instance_id に入れる
+func NewNodeScanner(client kubernetes.Interface, cluster string) *ResourceScanner {
+	return newResourceScanner("node", nil, func(ctx context.Context, _ string, opts metav1.ListOptions) ([]graph.ResourceNode, string, error) {
+		list, err := client.CoreV1().Nodes().List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+
+		nodes := make([]graph.ResourceNode, 0, len(list.Items))
+		for i := range list.Items {
+			n := &list.Items[i]
+
+			node := newNode(cluster, "node", n)
+			node.Region = n.Labels[corev1.LabelTopologyRegion]
+			node.Metadata["provider_id"] = n.Spec.ProviderID
+			node.Metadata["zone"] = n.Labels[corev1.LabelTopologyZone]
+			node.Metadata["instance_type"] = n.Labels[corev1.LabelInstanceTypeStable]
+			node.Metadata["kubelet_version"] = n.Status.NodeInfo.KubeletVersion
+			node.Metadata["unschedulable"] = n.Spec.Unschedulable
+			node.Metadata["ready"] = nodeReady(n)
+
+			for _, addr := range n.Status.Addresses {
+				if addr.Type == corev1.NodeInternalIP {
+					node.Metadata["internal_ip"] = addr.Address
+				}
+			}
+
+			if instanceID := awsInstanceID(n.Spec.ProviderID); instanceID != "" {
+				node.Metadata["instance_id"] = instanceID
+			}
+
+			nodes = append(nodes, node)
+		}
+		return nodes, list.Continue, nil
+	})
+}
+
+// nodeReady は Node の Ready Condition が True かを判定
+func nodeReady(n *corev1.Node) bool {
+	for _, cond := range n.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// awsInstanceID は providerID（"aws:///us-east-1a/i-0123456789abcdef0"）から EC2 インスタンス ID を抽出
+// AWS 以外の providerID の場合は空文字を返す
+func awsInstanceID(providerID string) string {
+	if !strings.HasPrefix(providerID, "aws://") {
+		return ""
+	}
+	id := providerID[strings.LastIndex(providerID, "/")+1:]
+	if !strings.HasPrefix(id, "i-") {
+		return ""
+	}
+	return id
+}
@@ -0,0 +1,57 @@
+package k8s
+
+import (
+	"context"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewConfigMapScanner は ConfigMap スキャナーを作成
+// 値は取り込まず、キー名のみを保持する
+func NewConfigMapScanner(client kubernetes.Interface, cluster string, namespaces []string) *ResourceScanner {
+	return newResourceScanner("configmap", namespaces, func(ctx context.Context, namespace string, opts metav1.ListOptions) ([]graph.ResourceNode, string, error) {
+		list, err := client.CoreV1().ConfigMaps(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+
+		nodes := make([]graph.ResourceNode, 0, len(list.Items))
+		for i := range list.Items {
+			cm := &list.Items[i]
+
+			keys := sortedKeys(cm.Data)
+			keys = append(keys, sortedKeys(cm.BinaryData)...)
+
+			node := newNode(cluster, "configmap", cm)
+			node.Metadata["keys"] = keys
+
+			nodes = append(nodes, node)
+		}
+		return nodes, list.Continue, nil
+	})
+}
+
+// NewSecretScanner は Secret スキャナーを作成
+// メタデータのみを取り込み、キー名や値は保持しない
+func NewSecretScanner(client kubernetes.Interface, cluster string, namespaces []string) *ResourceScanner {
+	return newResourceScanner("secret", namespaces, func(ctx context.Context, namespace string, opts metav1.ListOptions) ([]graph.ResourceNode, string, error) {
+		list, err := client.CoreV1().Secrets(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+
+		nodes := make([]graph.ResourceNode, 0, len(list.Items))
+		for i := range list.Items {
+			secret := &list.Items[i]
+
+			node := newNode(cluster, "secret", secret)
+			node.Metadata["secret_type"] = string(secret.Type)
+			node.Metadata["key_count"] = len(secret.Data)
+
+			nodes = append(nodes, node)
+		}
+		return nodes, list.Continue, nil
+	})
+}
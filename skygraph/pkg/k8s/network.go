@@ -0,0 +1,94 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewServiceScanner は Service スキャナーを作成
+// selector は builder が Service → Pod の network エッジを推論するのに使う
+func NewServiceScanner(client kubernetes.Interface, cluster string, namespaces []string) *ResourceScanner {
+	return newResourceScanner("service", namespaces, func(ctx context.Context, namespace string, opts metav1.ListOptions) ([]graph.ResourceNode, string, error) {
+		list, err := client.CoreV1().Services(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+
+		nodes := make([]graph.ResourceNode, 0, len(list.Items))
+		for i := range list.Items {
+			svc := &list.Items[i]
+
+			ports := make([]string, 0, len(svc.Spec.Ports))
+			for _, p := range svc.Spec.Ports {
+				ports = append(ports, fmt.Sprintf("%d/%s", p.Port, p.Protocol))
+			}
+
+			node := newNode(cluster, "service", svc)
+			node.Metadata["service_type"] = string(svc.Spec.Type)
+			node.Metadata["cluster_ip"] = svc.Spec.ClusterIP
+			node.Metadata["ports"] = ports
+			if len(svc.Spec.Selector) > 0 {
+				node.Metadata["selector"] = copyLabels(svc.Spec.Selector)
+			}
+
+			nodes = append(nodes, node)
+		}
+		return nodes, list.Continue, nil
+	})
+}
+
+// NewIngressScanner は Ingress スキャナーを作成
+// バックエンドの Service 名を backend_services に入れる
+func NewIngressScanner(client kubernetes.Interface, cluster string, namespaces []string) *ResourceScanner {
+	return newResourceScanner("ingress", namespaces, func(ctx context.Context, namespace string, opts metav1.ListOptions) ([]graph.ResourceNode, string, error) {
+		list, err := client.NetworkingV1().Ingresses(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+
+		nodes := make([]graph.ResourceNode, 0, len(list.Items))
+		for i := range list.Items {
+			ing := &list.Items[i]
+
+			node := newNode(cluster, "ingress", ing)
+			if ing.Spec.IngressClassName != nil {
+				node.Metadata["ingress_class"] = *ing.Spec.IngressClassName
+			}
+			node.Metadata["hosts"], node.Metadata["backend_services"] = ingressRoutes(ing)
+
+			nodes = append(nodes, node)
+		}
+		return nodes, list.Continue, nil
+	})
+}
+
+// ingressRoutes は Ingress のホスト名とバックエンド Service 名を返す
+func ingressRoutes(ing *networkingv1.Ingress) (hosts, services []string) {
+	hosts = make([]string, 0)
+	services = make([]string, 0)
+
+	if backend := ing.Spec.DefaultBackend; backend != nil && backend.Service != nil {
+		services = appendUnique(services, backend.Service.Name)
+	}
+
+	for _, rule := range ing.Spec.Rules {
+		if rule.Host != "" {
+			hosts = appendUnique(hosts, rule.Host)
+		}
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service != nil {
+				services = appendUnique(services, path.Backend.Service.Name)
+			}
+		}
+	}
+
+	return hosts, services
+}
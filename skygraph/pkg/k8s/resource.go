@@ -0,0 +1,161 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/scanner"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// pageSize は List 1回あたりの最大取得件数
+const pageSize = 500
+
+// listFunc は1ページ分のオブジェクトを取得して ResourceNode に変換し、次ページの continue トークンを返す
+type listFunc func(ctx context.Context, namespace string, opts metav1.ListOptions) ([]graph.ResourceNode, string, error)
+
+// ResourceScanner は1種類の Kubernetes リソースをスキャンする
+type ResourceScanner struct {
+	name string
+	list listFunc
+
+	// namespaces は Namespace スコープのリソースのスキャン対象（nil はクラスタ全体）
+	namespaces []string
+
+	mu    sync.Mutex
+	stats scanner.ScanStats
+}
+
+// newResourceScanner は ResourceScanner を作成
+// namespaces が空の場合は全 Namespace をまとめて取得する
+func newResourceScanner(name string, namespaces []string, list listFunc) *ResourceScanner {
+	return &ResourceScanner{
+		name:       name,
+		list:       list,
+		namespaces: namespaces,
+	}
+}
+
+// Name はスキャナー名を返す
+func (s *ResourceScanner) Name() string {
+	return s.name
+}
+
+// Scan はリソースを全ページ分スキャン
+// 途中でエラーになった場合は、それまでに取得したノードとエラーを返す
+func (s *ResourceScanner) Scan(ctx context.Context) ([]graph.ResourceNode, error) {
+	nodes := make([]graph.ResourceNode, 0)
+	stats := scanner.ScanStats{}
+	defer func() { s.record(stats) }()
+
+	namespaces := s.namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	for _, namespace := range namespaces {
+		opts := metav1.ListOptions{Limit: pageSize}
+		for {
+			if err := ctx.Err(); err != nil {
+				return nodes, fmt.Errorf("scan interrupted after %d pages: %w", stats.Pages, err)
+			}
+
+			page, next, err := s.list(ctx, namespace, opts)
+			if err != nil {
+				return nodes, fmt.Errorf("failed to list %s (page %d): %w", s.name, stats.Pages+1, err)
+			}
+			stats.Pages++
+
+			nodes = append(nodes, page...)
+			stats.Items = len(nodes)
+
+			if next == "" {
+				break
+			}
+			opts.Continue = next
+		}
+	}
+
+	stats.Complete = true
+	return nodes, nil
+}
+
+// LastStats は直近の Scan の取得統計を返す
+func (s *ResourceScanner) LastStats() scanner.ScanStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// record は取得統計を記録
+func (s *ResourceScanner) record(stats scanner.ScanStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = stats
+}
+
+// newNode はオブジェクトのメタデータから ResourceNode の共通部分を作成
+// ラベルは Tags に入れる。アノテーションは Secret の内容を含むことがあるため取り込まない
+func newNode(cluster, kind string, obj metav1.Object) graph.ResourceNode {
+	node := graph.ResourceNode{
+		ID:       graph.K8sNodeID(cluster, kind, obj.GetNamespace(), obj.GetName()),
+		Type:     "k8s_" + kind,
+		Provider: "kubernetes",
+		Name:     obj.GetName(),
+		Metadata: map[string]interface{}{
+			"cluster": cluster,
+			"uid":     string(obj.GetUID()),
+		},
+		Tags:      copyLabels(obj.GetLabels()),
+		CreatedAt: obj.GetCreationTimestamp().Time,
+		UpdatedAt: time.Now(),
+	}
+
+	if namespace := obj.GetNamespace(); namespace != "" {
+		node.Metadata["namespace"] = namespace
+	}
+
+	// OwnerReferences は "Kind/name" 形式で保持（builder が ownership エッジに変換）
+	if refs := obj.GetOwnerReferences(); len(refs) > 0 {
+		owners := make([]string, 0, len(refs))
+		for _, ref := range refs {
+			owners = append(owners, ref.Kind+"/"+ref.Name)
+		}
+		node.Metadata["owner_refs"] = owners
+	}
+
+	return node
+}
+
+// copyLabels はラベルをコピー
+func copyLabels(labels map[string]string) map[string]string {
+	result := make(map[string]string, len(labels))
+	for k, v := range labels {
+		result[k] = v
+	}
+	return result
+}
+
+// sortedKeys は map のキーをソートして返す
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// appendUnique は重複しない場合のみ追加
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,147 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/scanner"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// K8sScanner は Kubernetes クラスタのリソースをスキャンする
+type K8sScanner struct {
+	client  kubernetes.Interface
+	cluster string
+
+	// namespaces はスキャン対象 Namespace（空の場合は全 Namespace）
+	namespaces []string
+}
+
+// NewK8sScanner は clientset から Kubernetes スキャナーを作成
+// cluster はノード ID に含めるクラスタ名（複数クラスタのグラフを統合する場合に ID の衝突を防ぐ）
+func NewK8sScanner(client kubernetes.Interface, cluster string, namespaces []string) *K8sScanner {
+	return &K8sScanner{
+		client:     client,
+		cluster:    cluster,
+		namespaces: namespaces,
+	}
+}
+
+// NewK8sScannerFromKubeconfig は kubeconfig から Kubernetes スキャナーを作成
+// kubeconfig が空の場合は KUBECONFIG 環境変数または ~/.kube/config を使う
+// kubeContext が空の場合は current-context を使う
+func NewK8sScannerFromKubeconfig(kubeconfig, kubeContext, cluster string, namespaces []string) (*K8sScanner, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		loadingRules.ExplicitPath = kubeconfig
+	}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: kubeContext},
+	)
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+
+	if cluster == "" {
+		cluster = clusterName(clientConfig, kubeContext)
+	}
+
+	return NewK8sScanner(client, cluster, namespaces), nil
+}
+
+// clusterName は kubeconfig のコンテキストからクラスタ名を決める
+// EKS の "arn:aws:eks:...:cluster/name" 形式は末尾の name のみを使う
+func clusterName(clientConfig clientcmd.ClientConfig, kubeContext string) string {
+	raw, err := clientConfig.RawConfig()
+	if err != nil {
+		return ""
+	}
+
+	if kubeContext == "" {
+		kubeContext = raw.CurrentContext
+	}
+	name := kubeContext
+	if ctx, ok := raw.Contexts[kubeContext]; ok && ctx.Cluster != "" {
+		name = ctx.Cluster
+	}
+
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// Cluster はノード ID に使うクラスタ名を返す
+func (s *K8sScanner) Cluster() string {
+	return s.cluster
+}
+
+// ScanAll は全ての Kubernetes リソースをスキャン
+// Errors と Stats のキーは "k8s/<cluster>/<scanner>" 形式
+func (s *K8sScanner) ScanAll(ctx context.Context) (*scanner.Result, error) {
+	result := &scanner.Result{
+		Nodes:  make([]graph.ResourceNode, 0),
+		Errors: make(map[string]error),
+		Stats:  make(map[string]scanner.ScanStats),
+	}
+
+	// 各リソーススキャナーを並列実行
+	scanners := []scanner.Scanner{
+		NewNamespaceScanner(s.client, s.cluster, s.namespaces),
+		NewNodeScanner(s.client, s.cluster),
+		NewDeploymentScanner(s.client, s.cluster, s.namespaces),
+		NewReplicaSetScanner(s.client, s.cluster, s.namespaces),
+		NewPodScanner(s.client, s.cluster, s.namespaces),
+		NewServiceScanner(s.client, s.cluster, s.namespaces),
+		NewIngressScanner(s.client, s.cluster, s.namespaces),
+		NewConfigMapScanner(s.client, s.cluster, s.namespaces),
+		NewSecretScanner(s.client, s.cluster, s.namespaces),
+	}
+
+	prefix := "k8s/"
+	if s.cluster != "" {
+		prefix += s.cluster + "/"
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, sc := range scanners {
+		wg.Add(1)
+		go func(sc scanner.Scanner) {
+			defer wg.Done()
+
+			nodes, err := sc.Scan(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			// エラー時もそれまでに取得したノードは結果に含める
+			key := prefix + sc.Name()
+			if err != nil {
+				result.Errors[key] = err
+			}
+			result.Nodes = append(result.Nodes, nodes...)
+
+			if reporter, ok := sc.(scanner.StatsReporter); ok {
+				result.Stats[key] = reporter.LastStats()
+			}
+		}(sc)
+	}
+
+	wg.Wait()
+
+	return result, nil
+}
@@ -0,0 +1,276 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/builder"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// createTestClientset は EKS 上の web アプリを模した fake clientset を作成
+// Ingress → Service → Pod ← ReplicaSet ← Deployment、Pod は ip-10-0-1-5 ノード上で動作
+func createTestClientset() *fake.Clientset {
+	isController := true
+	replicas := int32(2)
+
+	return fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "ip-10-0-1-5",
+				Labels: map[string]string{
+					corev1.LabelTopologyRegion: "us-east-1",
+					corev1.LabelTopologyZone:   "us-east-1a",
+				},
+			},
+			Spec: corev1.NodeSpec{ProviderID: "aws:///us-east-1a/i-0abc"},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "prod"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			},
+		},
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "web-7d8f9",
+				Namespace: "prod",
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "Deployment", Name: "web", Controller: &isController},
+				},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "web-7d8f9-abcde",
+				Namespace: "prod",
+				Labels:    map[string]string{"app": "web", "pod-template-hash": "7d8f9"},
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "ReplicaSet", Name: "web-7d8f9", Controller: &isController},
+				},
+			},
+			Spec: corev1.PodSpec{
+				NodeName: "ip-10-0-1-5",
+				Containers: []corev1.Container{{
+					Name:  "web",
+					Image: "nginx:1.25",
+					EnvFrom: []corev1.EnvFromSource{
+						{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "web-config"}}},
+					},
+				}},
+				Volumes: []corev1.Volume{
+					{Name: "tls", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "web-tls"}}},
+				},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "worker",
+				Namespace: "prod",
+				Labels:    map[string]string{"app": "worker"},
+			},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "prod"},
+			Spec: corev1.ServiceSpec{
+				Type:     corev1.ServiceTypeClusterIP,
+				Selector: map[string]string{"app": "web"},
+				Ports:    []corev1.ServicePort{{Port: 80, Protocol: corev1.ProtocolTCP}},
+			},
+		},
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "prod"},
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{{
+					Host: "web.example.com",
+					IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{
+							Path:    "/",
+							Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "web"}},
+						}},
+					}},
+				}},
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-config", Namespace: "prod"},
+			Data:       map[string]string{"LOG_LEVEL": "info"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-tls", Namespace: "prod"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{"tls.crt": []byte("cert"), "tls.key": []byte("key")},
+		},
+	)
+}
+
+func TestK8sScanner_ScanAll(t *testing.T) {
+	s := NewK8sScanner(createTestClientset(), "eks-prod", nil)
+
+	result, err := s.ScanAll(context.Background())
+	if err != nil {
+		t.Fatalf("ScanAll failed: %v", err)
+	}
+	if len(result.Errors) != 0 {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+
+	nodes := make(map[string]graph.ResourceNode)
+	for _, node := range result.Nodes {
+		nodes[node.ID] = node
+	}
+	if len(nodes) != 11 {
+		t.Errorf("Expected 11 nodes, got %d", len(nodes))
+	}
+
+	pod, ok := nodes["k8s:eks-prod:pod:prod/web-7d8f9-abcde"]
+	if !ok {
+		t.Fatalf("Expected pod node, got %v", result.Nodes)
+	}
+	if pod.Type != "k8s_pod" || pod.Provider != "kubernetes" || pod.Tags["app"] != "web" {
+		t.Errorf("Unexpected pod node: %+v", pod)
+	}
+
+	node := nodes["k8s:eks-prod:node:ip-10-0-1-5"]
+	if node.Metadata["instance_id"] != "i-0abc" || node.Region != "us-east-1" {
+		t.Errorf("Expected instance ID from providerID, got %+v", node)
+	}
+
+	// Secret はメタデータのみ
+	secret := nodes["k8s:eks-prod:secret:prod/web-tls"]
+	if secret.Metadata["key_count"] != 2 || secret.Metadata["keys"] != nil {
+		t.Errorf("Expected secret metadata only, got %+v", secret.Metadata)
+	}
+
+	if stats := result.Stats["k8s/eks-prod/pod"]; stats.Items != 2 || !stats.Complete {
+		t.Errorf("Unexpected pod stats: %+v", stats)
+	}
+}
+
+func TestK8sScanner_Namespaces(t *testing.T) {
+	s := NewK8sScanner(createTestClientset(), "", []string{"kube-system"})
+
+	result, err := s.ScanAll(context.Background())
+	if err != nil {
+		t.Fatalf("ScanAll failed: %v", err)
+	}
+
+	// kube-system Namespace と（クラスタスコープの）Node のみ
+	if len(result.Nodes) != 2 {
+		t.Errorf("Expected 2 nodes, got %+v", result.Nodes)
+	}
+	for _, node := range result.Nodes {
+		if node.ID != "k8s:namespace:kube-system" && node.ID != "k8s:node:ip-10-0-1-5" {
+			t.Errorf("Unexpected node %s", node.ID)
+		}
+	}
+}
+
+func TestK8sScanner_PartialFailure(t *testing.T) {
+	client := createTestClientset()
+	client.PrependReactor("list", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("forbidden")
+	})
+
+	result, err := NewK8sScanner(client, "eks-prod", nil).ScanAll(context.Background())
+	if err != nil {
+		t.Fatalf("ScanAll failed: %v", err)
+	}
+	if _, ok := result.Errors["k8s/eks-prod/secret"]; !ok || len(result.Errors) != 1 {
+		t.Errorf("Expected only the secret scanner to fail, got %v", result.Errors)
+	}
+	if len(result.Nodes) != 10 {
+		t.Errorf("Expected other resources to be scanned, got %d nodes", len(result.Nodes))
+	}
+}
+
+func TestK8sScanner_BuilderEdges(t *testing.T) {
+	result, err := NewK8sScanner(createTestClientset(), "eks-prod", nil).ScanAll(context.Background())
+	if err != nil {
+		t.Fatalf("ScanAll failed: %v", err)
+	}
+
+	ec2ID := graph.AWSNodeID("111111111111", "us-east-1", "ec2", "i-0abc")
+
+	b := builder.NewGraphBuilder()
+	b.AddNodes(result.Nodes)
+	b.AddNodes([]graph.ResourceNode{{
+		ID:       ec2ID,
+		Type:     "ec2",
+		Provider: "aws",
+		Region:   "us-east-1",
+		Account:  "111111111111",
+		Metadata: map[string]interface{}{"instance_id": "i-0abc"},
+	}})
+	if err := b.InferEdges(); err != nil {
+		t.Fatalf("InferEdges failed: %v", err)
+	}
+	g := b.Build()
+
+	pod := "k8s:eks-prod:pod:prod/web-7d8f9-abcde"
+	tests := []struct {
+		from, to, edgeType string
+	}{
+		{"k8s:eks-prod:namespace:prod", pod, "ownership"},
+		{"k8s:eks-prod:deployment:prod/web", "k8s:eks-prod:replicaset:prod/web-7d8f9", "ownership"},
+		{"k8s:eks-prod:replicaset:prod/web-7d8f9", pod, "ownership"},
+		{"k8s:eks-prod:service:prod/web", pod, "network"},
+		{"k8s:eks-prod:ingress:prod/web", "k8s:eks-prod:service:prod/web", "network"},
+		{pod, "k8s:eks-prod:configmap:prod/web-config", "dependency"},
+		{pod, "k8s:eks-prod:secret:prod/web-tls", "dependency"},
+		{"k8s:eks-prod:node:ip-10-0-1-5", pod, "network"},
+		{ec2ID, pod, "network"},
+		{ec2ID, "k8s:eks-prod:node:ip-10-0-1-5", "ownership"},
+	}
+
+	for _, tt := range tests {
+		found := false
+		for _, edge := range g.OutEdges(tt.from) {
+			if edge.To == tt.to && edge.Type == tt.edgeType {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected %s edge %s -> %s", tt.edgeType, tt.from, tt.to)
+		}
+	}
+
+	// selector に一致しない Pod には Service からのエッジを張らない
+	for _, edge := range g.OutEdges("k8s:eks-prod:service:prod/web") {
+		if edge.To == "k8s:eks-prod:pod:prod/worker" {
+			t.Error("Unexpected service edge to worker pod")
+		}
+	}
+}
+
+func TestAWSInstanceID(t *testing.T) {
+	tests := []struct {
+		providerID string
+		want       string
+	}{
+		{"aws:///us-east-1a/i-0123456789abcdef0", "i-0123456789abcdef0"},
+		{"aws:///us-east-1a/fargate-ip-10-0-1-5.ec2.internal", ""},
+		{"gce://project/us-central1-a/node-1", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := awsInstanceID(tt.providerID); got != tt.want {
+			t.Errorf("awsInstanceID(%q) = %q, want %q", tt.providerID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package k8s
+
+import (
+	"context"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewDeploymentScanner は Deployment スキャナーを作成
+func NewDeploymentScanner(client kubernetes.Interface, cluster string, namespaces []string) *ResourceScanner {
+	return newResourceScanner("deployment", namespaces, func(ctx context.Context, namespace string, opts metav1.ListOptions) ([]graph.ResourceNode, string, error) {
+		list, err := client.AppsV1().Deployments(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+
+		nodes := make([]graph.ResourceNode, 0, len(list.Items))
+		for i := range list.Items {
+			d := &list.Items[i]
+
+			node := newNode(cluster, "deployment", d)
+			if d.Spec.Replicas != nil {
+				node.Metadata["replicas"] = *d.Spec.Replicas
+			}
+			node.Metadata["ready_replicas"] = d.Status.ReadyReplicas
+			node.Metadata["available_replicas"] = d.Status.AvailableReplicas
+			node.Metadata["strategy"] = string(d.Spec.Strategy.Type)
+			if d.Spec.Selector != nil && len(d.Spec.Selector.MatchLabels) > 0 {
+				node.Metadata["selector"] = copyLabels(d.Spec.Selector.MatchLabels)
+			}
+
+			nodes = append(nodes, node)
+		}
+		return nodes, list.Continue, nil
+	})
+}
+
+// NewReplicaSetScanner は ReplicaSet スキャナーを作成
+func NewReplicaSetScanner(client kubernetes.Interface, cluster string, namespaces []string) *ResourceScanner {
+	return newResourceScanner("replicaset", namespaces, func(ctx context.Context, namespace string, opts metav1.ListOptions) ([]graph.ResourceNode, string, error) {
+		list, err := client.AppsV1().ReplicaSets(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+
+		nodes := make([]graph.ResourceNode, 0, len(list.Items))
+		for i := range list.Items {
+			rs := &list.Items[i]
+
+			node := newNode(cluster, "replicaset", rs)
+			if rs.Spec.Replicas != nil {
+				node.Metadata["replicas"] = *rs.Spec.Replicas
+			}
+			node.Metadata["ready_replicas"] = rs.Status.ReadyReplicas
+
+			nodes = append(nodes, node)
+		}
+		return nodes, list.Continue, nil
+	})
+}
+
+// NewPodScanner は Pod スキャナーを作成
+// 参照している ConfigMap / Secret 名を configmaps / secrets に入れる（builder が dependency エッジに変換）
+func NewPodScanner(client kubernetes.Interface, cluster string, namespaces []string) *ResourceScanner {
+	return newResourceScanner("pod", namespaces, func(ctx context.Context, namespace string, opts metav1.ListOptions) ([]graph.ResourceNode, string, error) {
+		list, err := client.CoreV1().Pods(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+
+		nodes := make([]graph.ResourceNode, 0, len(list.Items))
+		for i := range list.Items {
+			pod := &list.Items[i]
+
+			images := make([]string, 0, len(pod.Spec.Containers))
+			for _, c := range pod.Spec.Containers {
+				images = append(images, c.Image)
+			}
+
+			configMaps, secrets := podReferences(pod)
+
+			node := newNode(cluster, "pod", pod)
+			node.Metadata["phase"] = string(pod.Status.Phase)
+			node.Metadata["node_name"] = pod.Spec.NodeName
+			node.Metadata["pod_ip"] = pod.Status.PodIP
+			node.Metadata["host_ip"] = pod.Status.HostIP
+			node.Metadata["service_account"] = pod.Spec.ServiceAccountName
+			node.Metadata["images"] = images
+			node.Metadata["configmaps"] = configMaps
+			node.Metadata["secrets"] = secrets
+
+			nodes = append(nodes, node)
+		}
+		return nodes, list.Continue, nil
+	})
+}
+
+// podReferences は Pod が参照する ConfigMap と Secret の名前を返す
+// ボリューム、envFrom、env の valueFrom、imagePullSecrets を対象にする
+func podReferences(pod *corev1.Pod) (configMaps, secrets []string) {
+	configMaps = make([]string, 0)
+	secrets = make([]string, 0)
+
+	for _, v := range pod.Spec.Volumes {
+		if v.ConfigMap != nil {
+			configMaps = appendUnique(configMaps, v.ConfigMap.Name)
+		}
+		if v.Secret != nil {
+			secrets = appendUnique(secrets, v.Secret.SecretName)
+		}
+		if v.Projected != nil {
+			for _, src := range v.Projected.Sources {
+				if src.ConfigMap != nil {
+					configMaps = appendUnique(configMaps, src.ConfigMap.Name)
+				}
+				if src.Secret != nil {
+					secrets = appendUnique(secrets, src.Secret.Name)
+				}
+			}
+		}
+	}
+
+	containers := make([]corev1.Container, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	containers = append(containers, pod.Spec.InitContainers...)
+	containers = append(containers, pod.Spec.Containers...)
+
+	for _, c := range containers {
+		for _, envFrom := range c.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				configMaps = appendUnique(configMaps, envFrom.ConfigMapRef.Name)
+			}
+			if envFrom.SecretRef != nil {
+				secrets = appendUnique(secrets, envFrom.SecretRef.Name)
+			}
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				configMaps = appendUnique(configMaps, env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				secrets = appendUnique(secrets, env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+
+	for _, ref := range pod.Spec.ImagePullSecrets {
+		secrets = appendUnique(secrets, ref.Name)
+	}
+
+	return configMaps, secrets
+}
@@ -0,0 +1,289 @@
+// Package reachability は Security Group のルールから通信可否を判定する
+package reachability
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+// ProtocolAll は全プロトコルを表す（AWS の "-1"）
+const ProtocolAll = "all"
+
+// Permission は Security Group の 1 ルール（Ingress / Egress 共通）
+type Permission struct {
+	Protocol string
+	FromPort int
+	ToPort   int
+
+	// CIDRBlocks は相手の CIDR（IPv4 / IPv6）
+	CIDRBlocks []string
+
+	// SecurityGroups は相手の Security Group ID
+	SecurityGroups []string
+}
+
+// allows は Permission が指定したプロトコル・ポートを許可するかを判定
+func (p Permission) allows(protocol string, port int) bool {
+	if p.Protocol == ProtocolAll {
+		return true
+	}
+	if p.Protocol != protocol {
+		return false
+	}
+	// ICMP などポートのないルール（-1）は全ポート
+	if p.FromPort < 0 || p.ToPort < 0 {
+		return true
+	}
+	return p.FromPort <= port && port <= p.ToPort
+}
+
+// SecurityGroup は通信判定に使う Security Group のルール
+type SecurityGroup struct {
+	ID      string
+	Ingress []Permission
+	Egress  []Permission
+}
+
+// SecurityGroupFromNode は security_group ノードの Metadata からルールを読み込む
+// スキャン直後の型付きの値と、JSON から読み込んだグラフの値の両方に対応する
+func SecurityGroupFromNode(node graph.ResourceNode) (SecurityGroup, bool) {
+	if node.Type != "security_group" {
+		return SecurityGroup{}, false
+	}
+	id, _ := node.Metadata["group_id"].(string)
+	if id == "" {
+		return SecurityGroup{}, false
+	}
+	return SecurityGroup{
+		ID:      id,
+		Ingress: ParsePermissions(node.Metadata["ingress_rules"]),
+		Egress:  ParsePermissions(node.Metadata["egress_rules"]),
+	}, true
+}
+
+// ParsePermissions は ingress_rules / egress_rules の値を Permission に変換
+func ParsePermissions(v interface{}) []Permission {
+	var rules []map[string]interface{}
+	switch val := v.(type) {
+	case []map[string]interface{}:
+		rules = val
+	case []interface{}:
+		for _, item := range val {
+			if rule, ok := item.(map[string]interface{}); ok {
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	perms := make([]Permission, 0, len(rules))
+	for _, rule := range rules {
+		protocol, _ := rule["protocol"].(string)
+		perm := Permission{
+			Protocol: normalizeProtocol(protocol),
+			FromPort: -1,
+			ToPort:   -1,
+		}
+		if port, ok := ToInt(rule["from_port"]); ok {
+			perm.FromPort = port
+		}
+		if port, ok := ToInt(rule["to_port"]); ok {
+			perm.ToPort = port
+		}
+		perm.CIDRBlocks = append(toStrings(rule["cidr_blocks"]), toStrings(rule["ipv6_cidr_blocks"])...)
+		perm.SecurityGroups = toStrings(rule["security_groups"])
+		perms = append(perms, perm)
+	}
+	return perms
+}
+
+// normalizeProtocol はプロトコル番号を名前に揃える
+func normalizeProtocol(protocol string) string {
+	switch strings.ToLower(protocol) {
+	case "-1", "all", "":
+		return ProtocolAll
+	case "6", "tcp":
+		return "tcp"
+	case "17", "udp":
+		return "udp"
+	case "1", "icmp":
+		return "icmp"
+	case "58", "icmpv6":
+		return "icmpv6"
+	default:
+		return strings.ToLower(protocol)
+	}
+}
+
+// Endpoint は通信の送信元または宛先
+type Endpoint struct {
+	// VPCID は所属 VPC（CIDR ルールは同じ VPC 内でのみ評価する）
+	VPCID string
+
+	// SecurityGroups は適用されている Security Group ID
+	SecurityGroups []string
+
+	// Prefixes はアドレス範囲（EC2 のプライベート IP は /32、RDS は所属 Subnet の CIDR）
+	Prefixes []netip.Prefix
+}
+
+// Path は許可された通信と、それを許可したルール
+type Path struct {
+	Protocol string
+	Port     int
+
+	// Ingress は宛先側で許可したルール（"sg-xxx" または CIDR）
+	Ingress string
+
+	// Egress は送信元側で許可したルール（Security Group が未スキャンの場合は空）
+	Egress string
+}
+
+// Engine は Security Group のルールから Endpoint 間の通信可否を判定する
+type Engine struct {
+	groups map[string]SecurityGroup
+}
+
+// NewEngine は Security Group の一覧から Engine を作成
+func NewEngine(groups []SecurityGroup) *Engine {
+	e := &Engine{groups: make(map[string]SecurityGroup, len(groups))}
+	for _, sg := range groups {
+		e.groups[sg.ID] = sg
+	}
+	return e
+}
+
+// Reachable は src から dst へ protocol/port の通信が許可されているかを判定
+// dst の Ingress で許可され、かつ src の Egress で許可されている場合に true を返す
+// src の Security Group が 1 つもスキャンされていない場合、Egress は判定しない
+func (e *Engine) Reachable(src, dst Endpoint, protocol string, port int) (Path, bool) {
+	protocol = normalizeProtocol(protocol)
+	path := Path{Protocol: protocol, Port: port}
+
+	ingress, ok := e.match(dst.SecurityGroups, func(sg SecurityGroup) []Permission { return sg.Ingress }, src, dst, protocol, port)
+	if !ok {
+		return Path{}, false
+	}
+	path.Ingress = ingress
+
+	if !e.known(src.SecurityGroups) {
+		return path, true
+	}
+	egress, ok := e.match(src.SecurityGroups, func(sg SecurityGroup) []Permission { return sg.Egress }, dst, src, protocol, port)
+	if !ok {
+		return Path{}, false
+	}
+	path.Egress = egress
+
+	return path, true
+}
+
+// known は groups のいずれかがスキャン済みかを判定
+func (e *Engine) known(groups []string) bool {
+	for _, id := range groups {
+		if _, ok := e.groups[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// match は groups のルールのうち、peer からの（への）通信を許可するものを探す
+func (e *Engine) match(groups []string, rules func(SecurityGroup) []Permission, peer, self Endpoint, protocol string, port int) (string, bool) {
+	for _, id := range groups {
+		sg, ok := e.groups[id]
+		if !ok {
+			continue
+		}
+		for _, perm := range rules(sg) {
+			if !perm.allows(protocol, port) {
+				continue
+			}
+			for _, ref := range perm.SecurityGroups {
+				if contains(peer.SecurityGroups, ref) {
+					return ref, true
+				}
+			}
+			// プライベートアドレスは VPC をまたぐと比較できないため、CIDR は同じ VPC 内のみ評価
+			if peer.VPCID != "" && self.VPCID != "" && peer.VPCID != self.VPCID {
+				continue
+			}
+			for _, cidr := range perm.CIDRBlocks {
+				prefix, err := netip.ParsePrefix(cidr)
+				if err != nil {
+					continue
+				}
+				for _, p := range peer.Prefixes {
+					if prefix.Overlaps(p) {
+						return cidr, true
+					}
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// ParseAddress は IP アドレスまたは CIDR を Prefix に変換（IP アドレスは /32, /128）
+func ParseAddress(s string) (netip.Prefix, bool) {
+	if s == "" {
+		return netip.Prefix{}, false
+	}
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix, err == nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), true
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ToInt は Metadata の数値を int に変換（JSON から読み込んだ float64 にも対応）
+func ToInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// toStrings は Metadata の文字列配列を []string に変換
+func toStrings(v interface{}) []string {
+	switch val := v.(type) {
+	case []string:
+		return val
+	case []interface{}:
+		result := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,146 @@
+package reachability
+
+import (
+	"encoding/json"
+	"net/netip"
+	"testing"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+func endpoint(vpc string, groups []string, addrs ...string) Endpoint {
+	e := Endpoint{VPCID: vpc, SecurityGroups: groups}
+	for _, a := range addrs {
+		if p, ok := ParseAddress(a); ok {
+			e.Prefixes = append(e.Prefixes, p)
+		}
+	}
+	return e
+}
+
+var allowAllEgress = []Permission{{Protocol: ProtocolAll, FromPort: -1, ToPort: -1, CIDRBlocks: []string{"0.0.0.0/0"}}}
+
+func TestEngine_Reachable(t *testing.T) {
+	engine := NewEngine([]SecurityGroup{
+		{ID: "sg-app", Egress: allowAllEgress},
+		{ID: "sg-locked", Egress: []Permission{{Protocol: "tcp", FromPort: 443, ToPort: 443, CIDRBlocks: []string{"0.0.0.0/0"}}}},
+		{ID: "sg-db", Ingress: []Permission{
+			{Protocol: "tcp", FromPort: 5432, ToPort: 5432, SecurityGroups: []string{"sg-app", "sg-locked"}},
+			{Protocol: "tcp", FromPort: 5000, ToPort: 6000, CIDRBlocks: []string{"10.0.2.0/24"}},
+		}},
+	})
+	db := endpoint("vpc-1", []string{"sg-db"}, "10.0.1.0/24")
+
+	tests := []struct {
+		name    string
+		src     Endpoint
+		port    int
+		want    bool
+		ingress string
+	}{
+		{"security group reference", endpoint("vpc-1", []string{"sg-app"}, "10.0.9.9"), 5432, true, "sg-app"},
+		{"cidr within port range", endpoint("vpc-1", []string{"sg-app"}, "10.0.2.15"), 5500, true, "10.0.2.0/24"},
+		{"port not allowed", endpoint("vpc-1", []string{"sg-app"}, "10.0.9.9"), 3306, false, ""},
+		{"no matching group or cidr", endpoint("vpc-1", []string{"sg-other"}, "10.0.9.9"), 5432, false, ""},
+		{"cidr in another vpc", endpoint("vpc-2", []string{"sg-other"}, "10.0.2.15"), 5500, false, ""},
+		{"egress denied", endpoint("vpc-1", []string{"sg-locked"}, "10.0.9.9"), 5432, false, ""},
+		{"unknown source groups skip egress", endpoint("vpc-1", []string{"sg-unscanned"}, "10.0.2.20"), 5432, true, "10.0.2.0/24"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, ok := engine.Reachable(tt.src, db, "6", tt.port)
+			if ok != tt.want {
+				t.Fatalf("Reachable() = %v, want %v", ok, tt.want)
+			}
+			if !ok {
+				return
+			}
+			if path.Ingress != tt.ingress || path.Protocol != "tcp" || path.Port != tt.port {
+				t.Errorf("Unexpected path: %+v", path)
+			}
+		})
+	}
+}
+
+func TestEngine_EgressToSecurityGroup(t *testing.T) {
+	engine := NewEngine([]SecurityGroup{
+		{ID: "sg-app", Egress: []Permission{{Protocol: "tcp", FromPort: 3306, ToPort: 3306, SecurityGroups: []string{"sg-db"}}}},
+		{ID: "sg-db", Ingress: []Permission{{Protocol: ProtocolAll, FromPort: -1, ToPort: -1, SecurityGroups: []string{"sg-app"}}}},
+	})
+
+	path, ok := engine.Reachable(endpoint("vpc-1", []string{"sg-app"}), endpoint("vpc-1", []string{"sg-db"}), "tcp", 3306)
+	if !ok || path.Egress != "sg-db" || path.Ingress != "sg-app" {
+		t.Errorf("Expected reachable via security groups, got %+v, %v", path, ok)
+	}
+
+	if _, ok := engine.Reachable(endpoint("vpc-1", []string{"sg-app"}), endpoint("vpc-1", []string{"sg-db"}), "tcp", 5432); ok {
+		t.Error("Expected egress to deny port 5432")
+	}
+}
+
+func TestSecurityGroupFromNode_JSON(t *testing.T) {
+	node := graph.ResourceNode{
+		ID:   "aws:sg:sg-db",
+		Type: "security_group",
+		Metadata: map[string]interface{}{
+			"group_id": "sg-db",
+			"ingress_rules": []map[string]interface{}{
+				{"protocol": "tcp", "from_port": int32(5432), "to_port": int32(5432), "security_groups": []string{"sg-app"}},
+				{"protocol": "-1", "ipv6_cidr_blocks": []string{"::/0"}},
+			},
+		},
+	}
+
+	// JSON を経由すると数値は float64、配列は []interface{} になる
+	data, err := json.Marshal(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded graph.ResourceNode
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []graph.ResourceNode{node, decoded} {
+		sg, ok := SecurityGroupFromNode(n)
+		if !ok {
+			t.Fatal("Expected security group")
+		}
+		if len(sg.Ingress) != 2 || len(sg.Egress) != 0 {
+			t.Fatalf("Unexpected rules: %+v", sg)
+		}
+		first := sg.Ingress[0]
+		if first.Protocol != "tcp" || first.FromPort != 5432 || first.ToPort != 5432 ||
+			len(first.SecurityGroups) != 1 || first.SecurityGroups[0] != "sg-app" {
+			t.Errorf("Unexpected first rule: %+v", first)
+		}
+		if second := sg.Ingress[1]; second.Protocol != ProtocolAll || len(second.CIDRBlocks) != 1 {
+			t.Errorf("Unexpected second rule: %+v", second)
+		}
+	}
+
+	if _, ok := SecurityGroupFromNode(graph.ResourceNode{Type: "ec2"}); ok {
+		t.Error("Expected non security group node to be rejected")
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"10.0.1.5", "10.0.1.5/32", true},
+		{"10.0.0.0/16", "10.0.0.0/16", true},
+		{"2001:db8::1", "2001:db8::1/128", true},
+		{"", "", false},
+		{"not-an-ip", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseAddress(tt.in)
+		if ok != tt.ok || (ok && got != netip.MustParsePrefix(tt.want)) {
+			t.Errorf("ParseAddress(%q) = %v, %v", tt.in, got, ok)
+		}
+	}
+}
@@ -3,7 +3,7 @@ package scanner
 import (
 	"context"
 
-	"github.com/yourusername/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
 )
 
 // Scanner はクラウドリソースをスキャンするためのインターフェース
@@ -37,4 +37,50 @@ type Result struct {
 
 	// Errors は各スキャナーで発生したエラー（部分的失敗を許容）
 	Errors map[string]error
+
+	// Stats は各スキャナーの取得統計（ページ数・件数）
+	Stats map[string]ScanStats
+}
+
+// ScanStats はスキャナー1回分の取得統計
+type ScanStats struct {
+	// Pages は取得した API レスポンスのページ数
+	Pages int `json:"pages"`
+
+	// Items は取得したリソース数
+	Items int `json:"items"`
+
+	// Complete は最終ページまで取得できたかどうか
+	// false の場合、エラーやキャンセルにより途中までの結果しか含まれていない
+	Complete bool `json:"complete"`
+}
+
+// StatsReporter は直近のスキャンの取得統計を報告できるスキャナー
+type StatsReporter interface {
+	// LastStats は直近の Scan の取得統計を返す
+	LastStats() ScanStats
+}
+
+// Merge は other の結果をこの Result に統合する（複数プロバイダーのスキャン結果をまとめる用途）
+// Errors / Stats のキーが重複した場合は other の値で上書きする
+func (r *Result) Merge(other *Result) {
+	if other == nil {
+		return
+	}
+
+	r.Nodes = append(r.Nodes, other.Nodes...)
+
+	if len(other.Errors) > 0 && r.Errors == nil {
+		r.Errors = make(map[string]error, len(other.Errors))
+	}
+	for name, err := range other.Errors {
+		r.Errors[name] = err
+	}
+
+	if len(other.Stats) > 0 && r.Stats == nil {
+		r.Stats = make(map[string]ScanStats, len(other.Stats))
+	}
+	for name, stats := range other.Stats {
+		r.Stats[name] = stats
+	}
 }
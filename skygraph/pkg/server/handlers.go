@@ -0,0 +1,254 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+)
+
+// NodeFilter はノードの絞り込み条件
+type NodeFilter struct {
+	Type     string
+	Provider string
+	Region   string
+	Account  string
+
+	// Tags は key → value の条件（value が空の場合はキーの存在のみ確認）
+	Tags map[string]string
+}
+
+// parseNodeFilter はクエリパラメータから NodeFilter を作成
+// tag は "key=value" または "key" の形式で複数指定可能
+func parseNodeFilter(r *http.Request) NodeFilter {
+	query := r.URL.Query()
+
+	filter := NodeFilter{
+		Type:     query.Get("type"),
+		Provider: query.Get("provider"),
+		Region:   query.Get("region"),
+		Account:  query.Get("account"),
+	}
+
+	for _, tag := range query["tag"] {
+		if tag == "" {
+			continue
+		}
+		if filter.Tags == nil {
+			filter.Tags = make(map[string]string)
+		}
+		key, value, _ := strings.Cut(tag, "=")
+		filter.Tags[key] = value
+	}
+
+	return filter
+}
+
+// IsEmpty は条件が指定されていないかを判定
+func (f NodeFilter) IsEmpty() bool {
+	return f.Type == "" && f.Provider == "" && f.Region == "" && f.Account == "" && len(f.Tags) == 0
+}
+
+// Match はノードが条件に一致するかを判定
+func (f NodeFilter) Match(node *graph.ResourceNode) bool {
+	if f.Type != "" && node.Type != f.Type {
+		return false
+	}
+	if f.Provider != "" && node.Provider != f.Provider {
+		return false
+	}
+	if f.Region != "" && node.Region != f.Region {
+		return false
+	}
+	if f.Account != "" && node.Account != f.Account {
+		return false
+	}
+	for key, value := range f.Tags {
+		actual, ok := node.Tags[key]
+		if !ok {
+			return false
+		}
+		if value != "" && actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// filterGraph は条件に一致するノードと、両端が一致するエッジのみを含むグラフを返す
+func filterGraph(g *graph.Graph, filter NodeFilter) *graph.Graph {
+	if filter.IsEmpty() {
+		return g
+	}
+
+	result := graph.NewGraph()
+	kept := make(map[string]bool)
+	for i := range g.Nodes {
+		if filter.Match(&g.Nodes[i]) {
+			result.AddNode(g.Nodes[i])
+			kept[g.Nodes[i].ID] = true
+		}
+	}
+
+	for _, edge := range g.Edges {
+		if kept[edge.From] && kept[edge.To] {
+			result.AddEdge(edge)
+		}
+	}
+
+	return result
+}
+
+// handleHealth はヘルスチェック
+func (s *Server) handleHealth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		status := s.Status()
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"status":      "healthy",
+			"timestamp":   time.Now().Unix(),
+			"graph_ready": s.Graph() != nil,
+			"node_count":  status.NodeCount,
+			"edge_count":  status.EdgeCount,
+		})
+	}
+}
+
+// handleGraph はグラフ全体（またはフィルタ後のサブグラフ）を返す
+func (s *Server) handleGraph() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		g := s.Graph()
+		if g == nil {
+			respondError(w, http.StatusServiceUnavailable, "Graph is not available yet: initial scan has not completed")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, filterGraph(g, parseNodeFilter(r)))
+	}
+}
+
+// handleNodes はノード一覧を返す
+func (s *Server) handleNodes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		g := s.Graph()
+		if g == nil {
+			respondError(w, http.StatusServiceUnavailable, "Graph is not available yet: initial scan has not completed")
+			return
+		}
+
+		filter := parseNodeFilter(r)
+		limit := parseQueryInt(r, "limit", 0)
+
+		nodes := make([]graph.ResourceNode, 0)
+		for i := range g.Nodes {
+			if !filter.Match(&g.Nodes[i]) {
+				continue
+			}
+			nodes = append(nodes, g.Nodes[i])
+			if limit > 0 && len(nodes) >= limit {
+				break
+			}
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"nodes": nodes,
+			"count": len(nodes),
+			"total": g.NodeCount(),
+		})
+	}
+}
+
+// handleNodeByID は単一ノードとそのエッジを返す
+func (s *Server) handleNodeByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/nodes/")
+		if id == "" {
+			respondError(w, http.StatusBadRequest, "Invalid node ID")
+			return
+		}
+
+		g := s.Graph()
+		if g == nil {
+			respondError(w, http.StatusServiceUnavailable, "Graph is not available yet: initial scan has not completed")
+			return
+		}
+
+		// Terraform から求めたアカウント付きの ID は単一アカウントのスキャンの短い ID にも一致する
+		node := g.LookupNode(id)
+		if node == nil {
+			respondError(w, http.StatusNotFound, "Node not found: "+id)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"node":  node,
+			"edges": g.FindEdges(node.ID),
+		})
+	}
+}
+
+// handleScanStatus はスキャン状態を返す
+func (s *Server) handleScanStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, s.Status())
+	}
+}
+
+// respondJSON は JSON レスポンスを返す
+func respondJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if data != nil {
+		json.NewEncoder(w).Encode(data)
+	}
+}
+
+// respondError はエラーレスポンスを返す
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]interface{}{
+		"error":     message,
+		"status":    status,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// parseQueryInt は整数のクエリパラメータを解析
+func parseQueryInt(r *http.Request, key string, defaultValue int) int {
+	val := r.URL.Query().Get(key)
+	if val == "" {
+		return defaultValue
+	}
+	var result int
+	fmt.Sscanf(val, "%d", &result)
+	if result <= 0 {
+		return defaultValue
+	}
+	return result
+}
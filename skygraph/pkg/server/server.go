@@ -0,0 +1,299 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/scanner"
+)
+
+// ScanFunc はスキャンを実行してグラフを構築する関数
+// 部分的な失敗は scanner.Result.Errors に格納し、致命的な失敗のみ error を返す
+type ScanFunc func(ctx context.Context) (*graph.Graph, *scanner.Result, error)
+
+// Config はサーバーの設定
+type Config struct {
+	// Host は待ち受けホスト
+	Host string
+
+	// Port は待ち受けポート（DeepDrift は 8001 を前提としている）
+	Port int
+
+	// Interval は定期スキャンの間隔
+	Interval time.Duration
+
+	// ScanTimeout は1回のスキャンのタイムアウト
+	ScanTimeout time.Duration
+}
+
+// DefaultConfig はデフォルト設定を返す
+func DefaultConfig() *Config {
+	return &Config{
+		Host:        "0.0.0.0",
+		Port:        8001,
+		Interval:    5 * time.Minute,
+		ScanTimeout: 5 * time.Minute,
+	}
+}
+
+// ScanStatus はスキャンの状態
+type ScanStatus struct {
+	// Scanning はスキャン実行中かどうか
+	Scanning bool `json:"scanning"`
+
+	// ScanCount は完了したスキャン回数
+	ScanCount int `json:"scan_count"`
+
+	// LastStartedAt は最後にスキャンを開始した時刻（まだスキャンしていない場合は nil）
+	LastStartedAt *time.Time `json:"last_started_at,omitempty"`
+
+	// LastCompletedAt は最後にスキャンが完了した時刻（まだ完了していない場合は nil）
+	LastCompletedAt *time.Time `json:"last_completed_at,omitempty"`
+
+	// LastSuccessAt は最後にグラフを更新できた時刻（まだグラフがない場合は nil）
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+
+	// LastDurationSeconds は最後のスキャンの所要時間（秒）
+	LastDurationSeconds float64 `json:"last_duration_seconds"`
+
+	// LastError は最後のスキャンの致命的エラー
+	LastError string `json:"last_error,omitempty"`
+
+	// ScannerErrors はスキャナーごとのエラー（部分的失敗）
+	ScannerErrors map[string]string `json:"scanner_errors,omitempty"`
+
+	// ScannerStats はスキャナーごとの取得統計（ページ数・件数・完了したか）
+	ScannerStats map[string]scanner.ScanStats `json:"scanner_stats,omitempty"`
+
+	// NodeCount は現在のグラフのノード数
+	NodeCount int `json:"node_count"`
+
+	// EdgeCount は現在のグラフのエッジ数
+	EdgeCount int `json:"edge_count"`
+
+	// NextScanAt は次回スキャン予定時刻（定期スキャンしない場合は nil）
+	NextScanAt *time.Time `json:"next_scan_at,omitempty"`
+}
+
+// Server は最新のグラフを HTTP で提供する
+type Server struct {
+	config *Config
+	scan   ScanFunc
+
+	mu     sync.RWMutex
+	graph  *graph.Graph
+	status ScanStatus
+
+	// scanMu はスキャンの同時実行を防ぐ
+	scanMu sync.Mutex
+
+	mux    *http.ServeMux
+	server *http.Server
+}
+
+// NewServer は新しい Server を作成
+func NewServer(config *Config, scan ScanFunc) *Server {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	s := &Server{
+		config: config,
+		scan:   scan,
+		mux:    http.NewServeMux(),
+	}
+
+	s.registerRoutes()
+
+	s.server = &http.Server{
+		Addr:         fmt.Sprintf("%s:%d", config.Host, config.Port),
+		Handler:      s.mux,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	return s
+}
+
+// registerRoutes は API ルートを登録
+func (s *Server) registerRoutes() {
+	s.mux.HandleFunc("/health", s.handleHealth())
+	s.mux.HandleFunc("/api/v1/health", s.handleHealth())
+
+	s.mux.HandleFunc("/api/v1/graph", s.handleGraph())
+	s.mux.HandleFunc("/api/v1/nodes", s.handleNodes())
+	s.mux.HandleFunc("/api/v1/nodes/", s.handleNodeByID())
+	s.mux.HandleFunc("/api/v1/scan/status", s.handleScanStatus())
+}
+
+// Handler は HTTP ハンドラーを返す
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Addr は待ち受けアドレスを返す
+func (s *Server) Addr() string {
+	return s.server.Addr
+}
+
+// Start は HTTP サーバーを起動
+func (s *Server) Start() error {
+	log.Printf("Starting SkyGraph API server on %s", s.server.Addr)
+	return s.server.ListenAndServe()
+}
+
+// Shutdown は HTTP サーバーを停止
+func (s *Server) Shutdown(ctx context.Context) error {
+	log.Println("Shutting down SkyGraph API server...")
+	return s.server.Shutdown(ctx)
+}
+
+// Run は起動直後と Interval ごとにスキャンを実行（ctx がキャンセルされるまでブロック）
+func (s *Server) Run(ctx context.Context) error {
+	if err := s.ScanNow(ctx); err != nil {
+		log.Printf("Scan failed: %v", err)
+	}
+
+	if s.config.Interval <= 0 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.setNextScan(time.Now().Add(s.config.Interval))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := s.ScanNow(ctx); err != nil {
+				log.Printf("Scan failed: %v", err)
+			}
+		}
+	}
+}
+
+// ScanNow はスキャンを1回実行し、成功した場合はグラフを差し替える
+// スキャンが失敗した場合は直前のグラフを保持する
+func (s *Server) ScanNow(ctx context.Context) error {
+	if s.scan == nil {
+		return fmt.Errorf("no scan function configured")
+	}
+
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+
+	startedAt := time.Now()
+	s.mu.Lock()
+	s.status.Scanning = true
+	s.status.LastStartedAt = &startedAt
+	s.mu.Unlock()
+
+	scanCtx := ctx
+	if s.config.ScanTimeout > 0 {
+		var cancel context.CancelFunc
+		scanCtx, cancel = context.WithTimeout(ctx, s.config.ScanTimeout)
+		defer cancel()
+	}
+
+	g, result, err := s.scan(scanCtx)
+
+	completedAt := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.Scanning = false
+	s.status.ScanCount++
+	s.status.LastCompletedAt = &completedAt
+	s.status.LastDurationSeconds = completedAt.Sub(startedAt).Seconds()
+	s.status.ScannerErrors = nil
+	s.status.ScannerStats = nil
+
+	if result != nil && len(result.Errors) > 0 {
+		s.status.ScannerErrors = make(map[string]string, len(result.Errors))
+		for name, scanErr := range result.Errors {
+			s.status.ScannerErrors[name] = scanErr.Error()
+		}
+	}
+	if result != nil && len(result.Stats) > 0 {
+		s.status.ScannerStats = make(map[string]scanner.ScanStats, len(result.Stats))
+		for name, stats := range result.Stats {
+			s.status.ScannerStats[name] = stats
+		}
+	}
+
+	if err != nil {
+		s.status.LastError = err.Error()
+		return err
+	}
+	if g == nil {
+		s.status.LastError = "scan returned no graph"
+		return fmt.Errorf("scan returned no graph")
+	}
+
+	s.status.LastError = ""
+	s.status.LastSuccessAt = &completedAt
+	s.status.NodeCount = g.NodeCount()
+	s.status.EdgeCount = g.EdgeCount()
+	s.graph = g
+
+	log.Printf("Scan completed in %.2fs: %d nodes, %d edges", s.status.LastDurationSeconds, g.NodeCount(), g.EdgeCount())
+	return nil
+}
+
+// SetGraph はスキャンを経由せずにグラフを差し替える（ファイルからの読み込み用）
+func (s *Server) SetGraph(g *graph.Graph) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.graph = g
+	s.status.LastSuccessAt = &now
+	s.status.NodeCount = g.NodeCount()
+	s.status.EdgeCount = g.EdgeCount()
+}
+
+// Graph は現在のグラフを返す（未スキャンの場合は nil）
+func (s *Server) Graph() *graph.Graph {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.graph
+}
+
+// Status は現在のスキャン状態を返す
+func (s *Server) Status() ScanStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := s.status
+	if s.status.ScannerErrors != nil {
+		status.ScannerErrors = make(map[string]string, len(s.status.ScannerErrors))
+		for k, v := range s.status.ScannerErrors {
+			status.ScannerErrors[k] = v
+		}
+	}
+	if s.status.ScannerStats != nil {
+		status.ScannerStats = make(map[string]scanner.ScanStats, len(s.status.ScannerStats))
+		for k, v := range s.status.ScannerStats {
+			status.ScannerStats[k] = v
+		}
+	}
+	return status
+}
+
+// setNextScan は次回スキャン予定時刻を記録
+func (s *Server) setNextScan(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.NextScanAt = &t
+}
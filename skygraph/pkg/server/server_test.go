@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/higakikeita/airdig/skygraph/pkg/graph"
+	"github.com/higakikeita/airdig/skygraph/pkg/scanner"
+)
+
+// createTestGraph はテスト用のグラフを作成
+func createTestGraph() *graph.Graph {
+	g := graph.NewGraph()
+
+	g.AddNode(graph.ResourceNode{ID: "aws:vpc:vpc-1", Type: "vpc", Provider: "aws", Region: "us-east-1",
+		Tags: map[string]string{"Environment": "production"}})
+	g.AddNode(graph.ResourceNode{ID: "aws:subnet:subnet-1", Type: "subnet", Provider: "aws", Region: "us-east-1",
+		Tags: map[string]string{"Environment": "production"}})
+	g.AddNode(graph.ResourceNode{ID: "aws:ec2:i-1", Type: "ec2", Provider: "aws", Region: "us-east-1",
+		Tags: map[string]string{"Environment": "production", "Role": "web"}})
+	g.AddNode(graph.ResourceNode{ID: "aws:ec2:i-2", Type: "ec2", Provider: "aws", Region: "us-west-2",
+		Tags: map[string]string{"Environment": "staging"}})
+
+	g.AddEdge(graph.Edge{From: "aws:vpc:vpc-1", To: "aws:subnet:subnet-1", Type: "ownership"})
+	g.AddEdge(graph.Edge{From: "aws:subnet:subnet-1", To: "aws:ec2:i-1", Type: "network"})
+
+	return g
+}
+
+func newTestServer(t *testing.T, scan ScanFunc) *Server {
+	t.Helper()
+
+	s := NewServer(DefaultConfig(), scan)
+	if err := s.ScanNow(context.Background()); err != nil {
+		t.Fatalf("ScanNow failed: %v", err)
+	}
+	return s
+}
+
+func doGet(t *testing.T, s *Server, path string, out interface{}) int {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	if out != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("failed to decode response for %s: %v", path, err)
+		}
+	}
+	return rec.Code
+}
+
+func TestServer_GraphBeforeScan(t *testing.T) {
+	s := NewServer(DefaultConfig(), nil)
+
+	if code := doGet(t, s, "/api/v1/graph", nil); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 before first scan, got %d", code)
+	}
+
+	// まだスキャンしていない時刻は出力しない
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/scan/status", nil)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if body := rec.Body.String(); !strings.Contains(body, `"scan_count":0`) || strings.Contains(body, "_at") {
+		t.Errorf("Expected no timestamps before first scan, got %s", body)
+	}
+}
+
+func TestServer_Graph(t *testing.T) {
+	s := newTestServer(t, func(ctx context.Context) (*graph.Graph, *scanner.Result, error) {
+		return createTestGraph(), &scanner.Result{}, nil
+	})
+
+	var g graph.Graph
+	if code := doGet(t, s, "/api/v1/graph", &g); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if len(g.Nodes) != 4 || len(g.Edges) != 2 {
+		t.Errorf("Expected 4 nodes and 2 edges, got %d and %d", len(g.Nodes), len(g.Edges))
+	}
+
+	// フィルタ後はエッジも両端が残るものだけになる
+	g = graph.Graph{}
+	doGet(t, s, "/api/v1/graph?region=us-east-1&tag=Environment=production", &g)
+	if len(g.Nodes) != 3 || len(g.Edges) != 2 {
+		t.Errorf("Expected 3 nodes and 2 edges, got %d and %d", len(g.Nodes), len(g.Edges))
+	}
+
+	g = graph.Graph{}
+	doGet(t, s, "/api/v1/graph?type=ec2", &g)
+	if len(g.Nodes) != 2 || len(g.Edges) != 0 {
+		t.Errorf("Expected 2 nodes and 0 edges, got %d and %d", len(g.Nodes), len(g.Edges))
+	}
+}
+
+func TestServer_Nodes(t *testing.T) {
+	s := newTestServer(t, func(ctx context.Context) (*graph.Graph, *scanner.Result, error) {
+		return createTestGraph(), &scanner.Result{}, nil
+	})
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 4},
+		{"?type=ec2", 2},
+		{"?provider=aws&region=us-west-2", 1},
+		{"?tag=Role", 1},
+		{"?tag=Environment=staging", 1},
+		{"?tag=Environment=production&tag=Role=web", 1},
+		{"?type=ec2&limit=1", 1},
+		{"?provider=gcp", 0},
+	}
+
+	for _, tt := range tests {
+		var resp struct {
+			Nodes []graph.ResourceNode `json:"nodes"`
+			Count int                  `json:"count"`
+		}
+		if code := doGet(t, s, "/api/v1/nodes"+tt.query, &resp); code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", tt.query, code)
+		}
+		if resp.Count != tt.want || len(resp.Nodes) != tt.want {
+			t.Errorf("%s: expected %d nodes, got %d", tt.query, tt.want, resp.Count)
+		}
+	}
+}
+
+func TestServer_NodeByID(t *testing.T) {
+	s := newTestServer(t, func(ctx context.Context) (*graph.Graph, *scanner.Result, error) {
+		return createTestGraph(), &scanner.Result{}, nil
+	})
+
+	var resp struct {
+		Node  graph.ResourceNode `json:"node"`
+		Edges []graph.Edge       `json:"edges"`
+	}
+	if code := doGet(t, s, "/api/v1/nodes/aws:subnet:subnet-1", &resp); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if resp.Node.Type != "subnet" {
+		t.Errorf("Expected subnet node, got %s", resp.Node.Type)
+	}
+	if len(resp.Edges) != 2 {
+		t.Errorf("Expected 2 edges, got %d", len(resp.Edges))
+	}
+
+	// DeepDrift が Terraform state から求めたアカウント付きの ID でも単一アカウントのスキャンのノードを返す
+	resp.Node, resp.Edges = graph.ResourceNode{}, nil
+	if code := doGet(t, s, "/api/v1/nodes/aws:111111111111:us-east-1:subnet:subnet-1", &resp); code != http.StatusOK {
+		t.Fatalf("Expected 200 for account-scoped ID, got %d", code)
+	}
+	if resp.Node.ID != "aws:subnet:subnet-1" || len(resp.Edges) != 2 {
+		t.Errorf("Expected subnet-1 with 2 edges, got %s with %d edges", resp.Node.ID, len(resp.Edges))
+	}
+
+	if code := doGet(t, s, "/api/v1/nodes/aws:ec2:i-999", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown node, got %d", code)
+	}
+}
+
+func TestServer_ScanStatus(t *testing.T) {
+	fail := false
+	s := newTestServer(t, func(ctx context.Context) (*graph.Graph, *scanner.Result, error) {
+		if fail {
+			return nil, nil, errors.New("credentials expired")
+		}
+		return createTestGraph(), &scanner.Result{
+			Errors: map[string]error{"rds": errors.New("access denied")},
+		}, nil
+	})
+
+	var status ScanStatus
+	doGet(t, s, "/api/v1/scan/status", &status)
+	if status.ScanCount != 1 || status.NodeCount != 4 || status.EdgeCount != 2 {
+		t.Errorf("Unexpected status after first scan: %+v", status)
+	}
+	if status.ScannerErrors["rds"] != "access denied" {
+		t.Errorf("Expected rds scanner error to be reported, got %v", status.ScannerErrors)
+	}
+	if status.LastSuccessAt == nil || status.NextScanAt != nil {
+		t.Errorf("Expected last success time and no next scan, got %v / %v", status.LastSuccessAt, status.NextScanAt)
+	}
+
+	// 失敗したスキャンでは直前のグラフを保持する
+	fail = true
+	if err := s.ScanNow(context.Background()); err == nil {
+		t.Fatal("Expected scan error")
+	}
+
+	status = ScanStatus{}
+	doGet(t, s, "/api/v1/scan/status", &status)
+	if status.ScanCount != 2 || status.LastError != "credentials expired" {
+		t.Errorf("Unexpected status after failed scan: %+v", status)
+	}
+	if s.Graph() == nil || s.Graph().NodeCount() != 4 {
+		t.Error("Expected previous graph to be kept after failed scan")
+	}
+}
@@ -0,0 +1,248 @@
+// Command tracecore runs the OTLP receiver and the API server, or migrates
+// the ClickHouse schema:
+//
+//	tracecore [serve] [-auto-migrate]
+//	tracecore migrate [-to N] [-dry-run] [up|down|status]
+//
+// Connections and ports are configured with environment variables (see README).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/higakikeita/airdig/chmigrate"
+	"github.com/higakikeita/airdig/tracecore/pkg/api"
+	"github.com/higakikeita/airdig/tracecore/pkg/receiver"
+	"github.com/higakikeita/airdig/tracecore/pkg/storage/clickhouse"
+)
+
+func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && (args[0] == "serve" || args[0] == "migrate") {
+		command, args = args[0], args[1:]
+	}
+
+	var err error
+	switch command {
+	case "serve":
+		err = runServe(args)
+	case "migrate":
+		err = runMigrate(args)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runServe starts the OTLP receiver and the API server until SIGINT or SIGTERM
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	autoMigrate := flags.Bool("auto-migrate", envBool("TRACECORE_AUTO_MIGRATE"), "Apply pending schema migrations at startup (TRACECORE_AUTO_MIGRATE)")
+	flags.Parse(args)
+
+	logger := slog.Default()
+
+	chConfig, err := clickhouseConfig()
+	if err != nil {
+		return err
+	}
+	if *autoMigrate {
+		if err := clickhouse.CreateDatabase(chConfig); err != nil {
+			return fmt.Errorf("failed to create the ClickHouse database: %w", err)
+		}
+	}
+	chClient, err := clickhouse.NewClient(chConfig)
+	if err != nil {
+		return err
+	}
+	defer chClient.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	migrator, err := clickhouse.NewMigrator(chClient)
+	if err != nil {
+		return err
+	}
+	if *autoMigrate {
+		steps, err := migrator.Up(ctx, 0)
+		if err != nil {
+			return fmt.Errorf("failed to migrate the ClickHouse schema: %w", err)
+		}
+		for _, step := range steps {
+			logger.Info("Applied migration", "migration", step.Migration.String())
+		}
+	} else if steps, err := migrator.PlanUp(ctx, 0); err != nil {
+		logger.Error("Could not check the schema migrations", "error", err)
+	} else if len(steps) > 0 {
+		logger.Info("Schema migrations are pending: run tracecore migrate, or serve with -auto-migrate", "pending", len(steps))
+	}
+
+	traceStore := clickhouse.NewTraceStore(chClient)
+
+	receiverConfig := receiver.DefaultConfig()
+	receiverConfig.GRPCPort = envInt("TRACECORE_OTLP_GRPC_PORT", receiverConfig.GRPCPort)
+	receiverConfig.HTTPPort = envInt("TRACECORE_OTLP_HTTP_PORT", receiverConfig.HTTPPort)
+	otlpReceiver := receiver.NewOTLPReceiver(receiverConfig, traceStore, logger)
+
+	apiConfig := api.DefaultConfig()
+	apiConfig.Port = envInt("TRACECORE_API_PORT", apiConfig.Port)
+	server := api.NewServer(apiConfig, traceStore, chClient, logger)
+
+	if err := otlpReceiver.Start(ctx); err != nil {
+		return err
+	}
+	if err := server.Start(ctx); err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+	logger.Info("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	server.Stop(shutdownCtx)
+	return otlpReceiver.Stop(shutdownCtx)
+}
+
+// runMigrate applies or rolls back schema migrations. up migrates to -to
+// (default: latest), down rolls back to -to (default: one migration), and
+// -dry-run prints the statements without changing the database. Flags go
+// before the action; anything after it is rejected rather than ignored.
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	to := flags.Int("to", -1, "Target schema version (default: latest for up, one migration back for down)")
+	dryRun := flags.Bool("dry-run", false, "Print the statements without running them")
+	flags.Parse(args)
+
+	if flags.NArg() > 1 {
+		return fmt.Errorf("unexpected arguments after the migrate action: %s (flags go before the action)", strings.Join(flags.Args()[1:], " "))
+	}
+	action := flags.Arg(0)
+	if action == "" {
+		action = "up"
+	}
+	if action != "up" && action != "down" && action != "status" {
+		return fmt.Errorf("unknown migrate action: %s (available: up, down, status)", action)
+	}
+
+	chConfig, err := clickhouseConfig()
+	if err != nil {
+		return err
+	}
+	if action == "up" && !*dryRun {
+		if err := clickhouse.CreateDatabase(chConfig); err != nil {
+			return fmt.Errorf("failed to create the ClickHouse database: %w", err)
+		}
+	}
+	client, err := clickhouse.NewClient(chConfig)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	migrator, err := clickhouse.NewMigrator(client)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if action == "status" {
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state, appliedAt := "pending", ""
+			if status.Applied {
+				state, appliedAt = "applied", status.AppliedAt.Local().Format(time.RFC3339)
+			}
+			switch {
+			case status.Unknown:
+				state += " (unknown to this version)"
+			case status.Changed:
+				state += " (changed since)"
+			}
+			fmt.Printf("%-32s %-34s %s\n", status.Migration, state, appliedAt)
+		}
+		return nil
+	}
+
+	var steps []chmigrate.Step
+	if action == "up" {
+		steps, err = migrator.PlanUp(ctx, *to)
+	} else {
+		steps, err = migrator.PlanDown(ctx, max(*to, 0))
+		if *to < 0 && len(steps) > 1 {
+			steps = steps[:1]
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		fmt.Println("Schema is up to date")
+		return nil
+	}
+
+	for _, step := range steps {
+		if *dryRun {
+			fmt.Printf("-- %s\n", step)
+			for _, statement := range step.Statements() {
+				fmt.Printf("%s;\n\n", statement)
+			}
+			continue
+		}
+
+		fmt.Println(step)
+		if err := migrator.Apply(ctx, []chmigrate.Step{step}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clickhouseConfig reads the ClickHouse connection from CLICKHOUSE_ADDR and
+// CLICKHOUSE_DATABASE
+func clickhouseConfig() (*clickhouse.Config, error) {
+	config := clickhouse.DefaultConfig()
+	if addr := os.Getenv("CLICKHOUSE_ADDR"); addr != "" {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("CLICKHOUSE_ADDR: %w", err)
+		}
+		if config.Port, err = strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("CLICKHOUSE_ADDR: invalid port %q", port)
+		}
+		config.Host = host
+	}
+	if database := os.Getenv("CLICKHOUSE_DATABASE"); database != "" {
+		config.Database = database
+	}
+	return config, nil
+}
+
+// envInt returns the integer in an environment variable, or def if it is unset or invalid
+func envInt(key string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return n
+	}
+	return def
+}
+
+// envBool reports whether an environment variable is set to true
+func envBool(key string) bool {
+	b, _ := strconv.ParseBool(os.Getenv(key))
+	return b
+}
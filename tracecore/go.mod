@@ -4,6 +4,7 @@ go 1.23
 
 require (
 	github.com/ClickHouse/clickhouse-go/v2 v2.17.1
+	github.com/higakikeita/airdig/chmigrate v0.0.0
 	github.com/rs/zerolog v1.33.0
 	go.opentelemetry.io/collector/pdata v1.21.0
 	google.golang.org/grpc v1.67.1
@@ -37,3 +38,5 @@ require (
 	google.golang.org/protobuf v1.35.2 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/higakikeita/airdig/chmigrate => ../chmigrate
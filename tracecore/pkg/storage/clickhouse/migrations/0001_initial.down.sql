@@ -0,0 +1,7 @@
+DROP VIEW IF EXISTS mv_hourly_service_edges;
+DROP VIEW IF EXISTS mv_daily_trace_stats;
+
+DROP TABLE IF EXISTS resource_service_mappings;
+DROP TABLE IF EXISTS service_nodes;
+DROP TABLE IF EXISTS service_map;
+DROP TABLE IF EXISTS traces;
@@ -1,5 +1,5 @@
 -- TraceCore ClickHouse Schema
--- Database: tracecore
+-- Migrations run in the database the client is connected to (CLICKHOUSE_DATABASE).
 
 -- Traces Table: Stores individual spans from distributed traces
 CREATE TABLE IF NOT EXISTS traces (